@@ -125,4 +125,5 @@ type TokenCreateRequest struct {
 	NoParent    bool              `json:"no_parent,omitempty"`
 	DisplayName string            `json:"display_name"`
 	NumUses     int               `json:"num_uses"`
+	BoundCIDRs  []string          `json:"bound_cidrs,omitempty"`
 }
@@ -18,14 +18,23 @@ func (c *Sys) ListAuth() (map[string]*AuthMount, error) {
 }
 
 func (c *Sys) EnableAuth(path, authType, desc string) error {
+	return c.EnableAuthWithOptions(path, authType, desc, nil)
+}
+
+// EnableAuthWithOptions enables a credential backend, passing the given
+// options to the backend when it is created.
+func (c *Sys) EnableAuthWithOptions(path, authType, desc string, opts map[string]string) error {
 	if err := c.checkAuthPath(path); err != nil {
 		return err
 	}
 
-	body := map[string]string{
+	body := map[string]interface{}{
 		"type":        authType,
 		"description": desc,
 	}
+	if len(opts) > 0 {
+		body["options"] = opts
+	}
 
 	r := c.c.NewRequest("POST", fmt.Sprintf("/v1/sys/auth/%s", path))
 	if err := r.SetJSONBody(body); err != nil {
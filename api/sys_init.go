@@ -41,6 +41,7 @@ type InitStatusResponse struct {
 }
 
 type InitResponse struct {
-	Keys      []string
-	RootToken string `json:"root_token"`
+	Keys         []string
+	RecoveryKeys []string `json:"recovery_keys"`
+	RootToken    string   `json:"root_token"`
 }
@@ -54,6 +54,28 @@ func (c *Sys) RekeyUpdate(shard string) (*RekeyUpdateResponse, error) {
 	return &result, err
 }
 
+func (c *Sys) RefreshShares(shards []string, pgpKeys []string) (*RefreshSharesResponse, error) {
+	body := map[string]interface{}{
+		"keys":     shards,
+		"pgp_keys": pgpKeys,
+	}
+
+	r := c.c.NewRequest("PUT", "/v1/sys/refresh-shares")
+	if err := r.SetJSONBody(body); err != nil {
+		return nil, err
+	}
+
+	resp, err := c.c.RawRequest(r)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result RefreshSharesResponse
+	err = resp.DecodeJSON(&result)
+	return &result, err
+}
+
 type RekeyInitRequest struct {
 	SecretShares    int      `json:"secret_shares"`
 	SecretThreshold int      `json:"secret_threshold"`
@@ -72,3 +94,7 @@ type RekeyUpdateResponse struct {
 	Complete bool
 	Keys     []string
 }
+
+type RefreshSharesResponse struct {
+	Keys []string
+}
@@ -49,8 +49,12 @@ func sealStatusRequest(c *Sys, r *Request) (*SealStatusResponse, error) {
 }
 
 type SealStatusResponse struct {
-	Sealed   bool
-	T        int
-	N        int
-	Progress int
+	Sealed      bool
+	Initialized bool
+	T           int
+	N           int
+	Threshold   int
+	Progress    int
+	Version     string
+	Leader      string
 }
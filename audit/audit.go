@@ -21,6 +21,19 @@ type Backend interface {
 	// MUST not be modified in anyway. They should be deep copied if this is
 	// a possibility.
 	LogResponse(*logical.Auth, *logical.Request, *logical.Response, error) error
+
+	// GetHash is used to return the given value hashed with the salt of
+	// the backend, as it would appear in the audit log. This allows an
+	// operator to find a known value in the log.
+	GetHash(string) string
+}
+
+// Cleaner is an optional interface for backends holding resources, such
+// as background routines, that must be released once the backend is
+// removed. Cleanup is called when the backend is disabled or the vault
+// is sealed.
+type Cleaner interface {
+	Cleanup()
 }
 
 type BackendConfig struct {
@@ -160,6 +160,9 @@ type JSONSecret struct {
 
 // getRemoteAddr safely gets the remote address avoiding a nil pointer
 func getRemoteAddr(req *logical.Request) string {
+	if req != nil && req.RemoteAddr != "" {
+		return req.RemoteAddr
+	}
 	if req != nil && req.Connection != nil {
 		return req.Connection.RemoteAddr
 	}
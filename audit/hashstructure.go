@@ -72,6 +72,12 @@ func Hash(salter *salt.Salt, raw interface{}) error {
 	return nil
 }
 
+// HashString hashes the given string value the same way Hash does the
+// values of a structure.
+func HashString(salter *salt.Salt, data string) string {
+	return salter.GetIdentifiedHMAC(data)
+}
+
 // HashStructure takes an interface and hashes all the values within
 // the structure. Only _values_ are hashed: keys of objects are not.
 //
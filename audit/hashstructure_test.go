@@ -163,6 +163,30 @@ func TestHash(t *testing.T) {
 	}
 }
 
+func TestHashString(t *testing.T) {
+	inmemStorage := &logical.InmemStorage{}
+	inmemStorage.Put(&logical.StorageEntry{
+		Key:   "salt",
+		Value: []byte("foo"),
+	})
+	localSalt, err := salt.NewSalt(inmemStorage, &salt.Config{
+		HMAC:     sha256.New,
+		HMACType: "hmac-sha256",
+	})
+	if err != nil {
+		t.Fatalf("Error instantiating salt: %s", err)
+	}
+
+	// The value must hash as it does in a logged structure
+	auth := &logical.Auth{ClientToken: "foo"}
+	if err := Hash(localSalt, auth); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if out := HashString(localSalt, "foo"); out != auth.ClientToken {
+		t.Fatalf("bad: %s %s", out, auth.ClientToken)
+	}
+}
+
 func TestHashWalker(t *testing.T) {
 	replaceText := "foo"
 
@@ -43,7 +43,7 @@ func Factory(conf *audit.BackendConfig) (audit.Backend, error) {
 	// otherwise it will be too late to catch later without problems
 	// (ref: https://github.com/hashicorp/vault/issues/550)
 	if err := b.open(); err != nil {
-		return nil, fmt.Errorf("sanity check failed; unable to open %s for writing", path)
+		return nil, fmt.Errorf("sanity check failed; unable to open %s for writing: %v", path, err)
 	}
 
 	return b, nil
@@ -59,14 +59,13 @@ type Backend struct {
 	logRaw bool
 	salt   *salt.Salt
 
-	once sync.Once
-	f    *os.File
+	// fileLock serializes writes to the file, since requests are logged
+	// concurrently and a record may span several writes
+	fileLock sync.Mutex
+	f        *os.File
 }
 
 func (b *Backend) LogRequest(auth *logical.Auth, req *logical.Request, outerErr error) error {
-	if err := b.open(); err != nil {
-		return err
-	}
 	if !b.logRaw {
 		// Before we copy the structure we must nil out some data
 		// otherwise we will cause reflection to panic and die
@@ -101,6 +100,12 @@ func (b *Backend) LogRequest(auth *logical.Auth, req *logical.Request, outerErr
 		}
 	}
 
+	b.fileLock.Lock()
+	defer b.fileLock.Unlock()
+	if err := b.open(); err != nil {
+		return err
+	}
+
 	var format audit.FormatJSON
 	return format.FormatRequest(b.f, auth, req, outerErr)
 }
@@ -110,9 +115,6 @@ func (b *Backend) LogResponse(
 	req *logical.Request,
 	resp *logical.Response,
 	err error) error {
-	if err := b.open(); err != nil {
-		return err
-	}
 	if !b.logRaw {
 		// Before we copy the structure we must nil out some data
 		// otherwise we will cause reflection to panic and die
@@ -156,15 +158,23 @@ func (b *Backend) LogResponse(
 		}
 	}
 
+	b.fileLock.Lock()
+	defer b.fileLock.Unlock()
+	if err := b.open(); err != nil {
+		return err
+	}
+
 	var format audit.FormatJSON
 	return format.FormatResponse(b.f, auth, req, resp, err)
 }
 
+// open opens the file for appending if it isn't already. The caller must
+// hold fileLock.
 func (b *Backend) open() error {
 	if b.f != nil {
 		return nil
 	}
-	if err := os.MkdirAll(filepath.Dir(b.path), 0600); err != nil {
+	if err := os.MkdirAll(filepath.Dir(b.path), 0700); err != nil {
 		return err
 	}
 
@@ -176,3 +186,7 @@ func (b *Backend) open() error {
 
 	return nil
 }
+
+func (b *Backend) GetHash(data string) string {
+	return audit.HashString(b.salt, data)
+}
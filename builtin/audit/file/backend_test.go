@@ -0,0 +1,159 @@
+package file
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/vault/audit"
+	"github.com/hashicorp/vault/helper/salt"
+	"github.com/hashicorp/vault/logical"
+)
+
+func testBackend(t *testing.T, config map[string]string) audit.Backend {
+	s, err := salt.NewSalt(&logical.InmemStorage{}, &salt.Config{
+		HMAC:     sha256.New,
+		HMACType: "hmac-sha256",
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	b, err := Factory(&audit.BackendConfig{
+		Salt:   s,
+		Config: config,
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	return b
+}
+
+func testRecords(t *testing.T, path string) []map[string]interface{} {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer f.Close()
+
+	var records []map[string]interface{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var record map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("bad line %q: %v", scanner.Text(), err)
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	return records
+}
+
+func TestBackend_Log(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vault-audit")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Missing directories are created, and must be traversable
+	path := filepath.Join(dir, "nested", "audit.log")
+	b := testBackend(t, map[string]string{"path": path})
+	fi, err := os.Stat(filepath.Dir(path))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if fi.Mode().Perm() != 0700 {
+		t.Fatalf("bad: %v", fi.Mode())
+	}
+
+	req := &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "secret/foo",
+	}
+	if err := b.LogRequest(&logical.Auth{}, req, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	resp := &logical.Response{
+		Data: map[string]interface{}{
+			"foo": "bar",
+		},
+	}
+	if err := b.LogResponse(&logical.Auth{}, req, resp, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	records := testRecords(t, path)
+	if len(records) != 2 {
+		t.Fatalf("bad: %#v", records)
+	}
+	if records[0]["type"] != "request" || records[1]["type"] != "response" {
+		t.Fatalf("bad: %#v", records)
+	}
+
+	// The response data must be hashed
+	data := records[1]["response"].(map[string]interface{})["data"].(map[string]interface{})
+	if data["foo"] == "bar" {
+		t.Fatalf("bad: %#v", data)
+	}
+
+	// The original response is left untouched
+	if resp.Data["foo"] != "bar" {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+}
+
+func TestBackend_Concurrent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vault-audit")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "audit.log")
+	b := testBackend(t, map[string]string{"path": path})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := &logical.Request{
+				Operation: logical.ReadOperation,
+				Path:      "secret/foo",
+			}
+			if err := b.LogRequest(&logical.Auth{}, req, nil); err != nil {
+				t.Errorf("err: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Every record must be a complete line
+	if records := testRecords(t, path); len(records) != 20 {
+		t.Fatalf("bad: %d", len(records))
+	}
+}
+
+func TestFactory_MissingPath(t *testing.T) {
+	s, err := salt.NewSalt(&logical.InmemStorage{}, &salt.Config{
+		HMAC:     sha256.New,
+		HMACType: "hmac-sha256",
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	_, err = Factory(&audit.BackendConfig{
+		Salt:   s,
+		Config: map[string]string{},
+	})
+	if err == nil {
+		t.Fatalf("expected error")
+	}
+}
@@ -0,0 +1,337 @@
+package http
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/go-cleanhttp"
+	"github.com/hashicorp/vault/audit"
+	"github.com/hashicorp/vault/helper/salt"
+	"github.com/hashicorp/vault/logical"
+	"github.com/mitchellh/copystructure"
+)
+
+const (
+	// defaultBufferSize is the number of records buffered for delivery
+	defaultBufferSize = 1024
+
+	// defaultMaxRetries is the number of times delivery of a record is
+	// retried before it is considered failed
+	defaultMaxRetries = 3
+
+	// defaultRetryInterval is the base time between delivery attempts
+	defaultRetryInterval = time.Second
+
+	// defaultRequestTimeout is how long a single delivery attempt may
+	// take before it is abandoned and counted as failed
+	defaultRequestTimeout = 10 * time.Second
+)
+
+func Factory(conf *audit.BackendConfig) (audit.Backend, error) {
+	if conf.Salt == nil {
+		return nil, fmt.Errorf("Nil salt passed in")
+	}
+
+	url, ok := conf.Config["url"]
+	if !ok {
+		return nil, fmt.Errorf("url is required")
+	}
+
+	// Check if raw logging is enabled
+	logRaw := false
+	if raw, ok := conf.Config["log_raw"]; ok {
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, err
+		}
+		logRaw = b
+	}
+
+	// Check if the backend should fail open
+	failOpen := false
+	if raw, ok := conf.Config["fail_open"]; ok {
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, err
+		}
+		failOpen = b
+	}
+
+	bufferSize := defaultBufferSize
+	if raw, ok := conf.Config["buffer_size"]; ok {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("buffer_size must be a positive integer")
+		}
+		bufferSize = n
+	}
+
+	maxRetries := defaultMaxRetries
+	if raw, ok := conf.Config["max_retries"]; ok {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("max_retries must be a non-negative integer")
+		}
+		maxRetries = n
+	}
+
+	retryInterval := defaultRetryInterval
+	if raw, ok := conf.Config["retry_interval"]; ok {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid retry_interval: %v", err)
+		}
+		retryInterval = d
+	}
+
+	requestTimeout := defaultRequestTimeout
+	if raw, ok := conf.Config["request_timeout"]; ok {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid request_timeout: %v", err)
+		}
+		if d <= 0 {
+			return nil, fmt.Errorf("request_timeout must be positive")
+		}
+		requestTimeout = d
+	}
+
+	// A sink that never answers must not stall delivery forever
+	client := cleanhttp.DefaultClient()
+	client.Timeout = requestTimeout
+
+	b := &Backend{
+		url:           url,
+		logRaw:        logRaw,
+		failOpen:      failOpen,
+		maxRetries:    maxRetries,
+		retryInterval: retryInterval,
+		salt:          conf.Salt,
+		client:        client,
+		records:       make(chan *record, bufferSize),
+		stopCh:        make(chan struct{}),
+	}
+	go b.deliver()
+	return b, nil
+}
+
+// Backend is the audit backend that posts JSON audit records to an
+// HTTP endpoint.
+//
+// Records are buffered and delivered in order by a background routine,
+// retrying on errors and 5xx responses. If the backend fails closed,
+// which is the default, logging waits for the delivery of the record and
+// returns an error if the buffer is full or the record could not be
+// delivered. If the backend fails open, logging returns immediately and
+// such records are dropped.
+type Backend struct {
+	url           string
+	logRaw        bool
+	failOpen      bool
+	maxRetries    int
+	retryInterval time.Duration
+	salt          *salt.Salt
+	client        *http.Client
+
+	records chan *record
+
+	// stopCh stops the delivery when the backend is removed
+	stopCh chan struct{}
+}
+
+// record is a formatted audit record buffered for delivery. The result
+// of the delivery is sent on errCh, unless it is nil.
+type record struct {
+	data  []byte
+	errCh chan error
+}
+
+func (b *Backend) LogRequest(auth *logical.Auth, req *logical.Request, outerErr error) error {
+	if !b.logRaw {
+		// Before we copy the structure we must nil out some data
+		// otherwise we will cause reflection to panic and die
+		if req.Connection != nil && req.Connection.ConnState != nil {
+			origReq := req
+			origState := req.Connection.ConnState
+			req.Connection.ConnState = nil
+			defer func() {
+				origReq.Connection.ConnState = origState
+			}()
+		}
+
+		// Copy the structures
+		cp, err := copystructure.Copy(auth)
+		if err != nil {
+			return err
+		}
+		auth = cp.(*logical.Auth)
+
+		cp, err = copystructure.Copy(req)
+		if err != nil {
+			return err
+		}
+		req = cp.(*logical.Request)
+
+		// Hash any sensitive information
+		if err := audit.Hash(b.salt, auth); err != nil {
+			return err
+		}
+		if err := audit.Hash(b.salt, req); err != nil {
+			return err
+		}
+	}
+
+	// Encode the entry as JSON
+	var buf bytes.Buffer
+	var format audit.FormatJSON
+	if err := format.FormatRequest(&buf, auth, req, outerErr); err != nil {
+		return err
+	}
+	return b.enqueue(buf.Bytes())
+}
+
+func (b *Backend) LogResponse(auth *logical.Auth, req *logical.Request,
+	resp *logical.Response, err error) error {
+	if !b.logRaw {
+		// Before we copy the structure we must nil out some data
+		// otherwise we will cause reflection to panic and die
+		if req.Connection != nil && req.Connection.ConnState != nil {
+			origReq := req
+			origState := req.Connection.ConnState
+			req.Connection.ConnState = nil
+			defer func() {
+				origReq.Connection.ConnState = origState
+			}()
+		}
+
+		// Copy the structure
+		cp, err := copystructure.Copy(auth)
+		if err != nil {
+			return err
+		}
+		auth = cp.(*logical.Auth)
+
+		cp, err = copystructure.Copy(req)
+		if err != nil {
+			return err
+		}
+		req = cp.(*logical.Request)
+
+		cp, err = copystructure.Copy(resp)
+		if err != nil {
+			return err
+		}
+		resp = cp.(*logical.Response)
+
+		// Hash any sensitive information
+		if err := audit.Hash(b.salt, auth); err != nil {
+			return err
+		}
+		if err := audit.Hash(b.salt, req); err != nil {
+			return err
+		}
+		if err := audit.Hash(b.salt, resp); err != nil {
+			return err
+		}
+	}
+
+	// Encode the entry as JSON
+	var buf bytes.Buffer
+	var format audit.FormatJSON
+	if err := format.FormatResponse(&buf, auth, req, resp, err); err != nil {
+		return err
+	}
+	return b.enqueue(buf.Bytes())
+}
+
+// enqueue is used to buffer a record for delivery. Unless the backend
+// fails open, it waits for the record to be delivered.
+func (b *Backend) enqueue(data []byte) error {
+	r := &record{data: data}
+	if !b.failOpen {
+		r.errCh = make(chan error, 1)
+	}
+
+	select {
+	case b.records <- r:
+	default:
+		if b.failOpen {
+			return nil
+		}
+		return fmt.Errorf("audit buffer full; record not delivered to %s", b.url)
+	}
+	if b.failOpen {
+		return nil
+	}
+
+	select {
+	case err := <-r.errCh:
+		return err
+	case <-b.stopCh:
+		return fmt.Errorf("audit backend stopped; record not delivered to %s", b.url)
+	}
+}
+
+// deliver is a long running routine to post the buffered records
+func (b *Backend) deliver() {
+	for {
+		select {
+		case r := <-b.records:
+			err := b.post(r.data)
+			if err != nil {
+				log.Printf("[ERR] audit: failed to deliver record to %s: %v", b.url, err)
+			}
+			if r.errCh != nil {
+				r.errCh <- err
+			}
+
+		case <-b.stopCh:
+			return
+		}
+	}
+}
+
+// post is used to post a single record, retrying on failure
+func (b *Backend) post(record []byte) error {
+	var err error
+	for attempt := 0; attempt <= b.maxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(time.Duration(attempt) * b.retryInterval):
+			case <-b.stopCh:
+				return fmt.Errorf("audit backend stopped")
+			}
+		}
+
+		var resp *http.Response
+		resp, err = b.client.Post(b.url, "application/json", bytes.NewReader(record))
+		if err != nil {
+			continue
+		}
+		resp.Body.Close()
+
+		switch {
+		case resp.StatusCode >= 500:
+			err = fmt.Errorf("unexpected status code %d", resp.StatusCode)
+		case resp.StatusCode >= 400:
+			// The record is rejected, retrying will not help
+			return fmt.Errorf("record rejected with status code %d", resp.StatusCode)
+		default:
+			return nil
+		}
+	}
+	return err
+}
+
+// Cleanup stops the delivery of the buffered records
+func (b *Backend) Cleanup() {
+	close(b.stopCh)
+}
+
+func (b *Backend) GetHash(data string) string {
+	return audit.HashString(b.salt, data)
+}
@@ -0,0 +1,276 @@
+package http
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/audit"
+	"github.com/hashicorp/vault/helper/salt"
+	"github.com/hashicorp/vault/logical"
+)
+
+// testSink is a fake HTTP endpoint recording the posted audit records.
+// The first failures requests are answered with a 500.
+type testSink struct {
+	sync.Mutex
+	failures int
+	attempts int
+	records  []map[string]interface{}
+}
+
+func (s *testSink) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+	s.attempts++
+	if s.failures > 0 {
+		s.failures--
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var record map[string]interface{}
+	if err := json.Unmarshal(body, &record); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	s.records = append(s.records, record)
+}
+
+func (s *testSink) waitRecords(t *testing.T, n int) []map[string]interface{} {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		s.Lock()
+		if len(s.records) >= n {
+			records := s.records
+			s.Unlock()
+			return records
+		}
+		s.Unlock()
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %d records", n)
+	return nil
+}
+
+func testBackend(t *testing.T, config map[string]string) audit.Backend {
+	s, err := salt.NewSalt(&logical.InmemStorage{}, &salt.Config{
+		HMAC:     sha256.New,
+		HMACType: "hmac-sha256",
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	b, err := Factory(&audit.BackendConfig{
+		Salt:   s,
+		Config: config,
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	return b
+}
+
+func TestBackend_Post(t *testing.T) {
+	sink := &testSink{}
+	ts := httptest.NewServer(sink)
+	defer ts.Close()
+
+	b := testBackend(t, map[string]string{"url": ts.URL})
+
+	req := &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "secret/foo",
+	}
+	if err := b.LogRequest(&logical.Auth{}, req, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	resp := &logical.Response{
+		Data: map[string]interface{}{
+			"foo": "bar",
+		},
+	}
+	if err := b.LogResponse(&logical.Auth{}, req, resp, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	records := sink.waitRecords(t, 2)
+	if records[0]["type"] != "request" || records[1]["type"] != "response" {
+		t.Fatalf("bad: %#v", records)
+	}
+	path := records[0]["request"].(map[string]interface{})["path"]
+	if path != "secret/foo" {
+		t.Fatalf("bad: %#v", records[0])
+	}
+
+	// The response data must be hashed
+	data := records[1]["response"].(map[string]interface{})["data"].(map[string]interface{})
+	if data["foo"] == "bar" {
+		t.Fatalf("bad: %#v", data)
+	}
+}
+
+func TestBackend_Retry(t *testing.T) {
+	sink := &testSink{failures: 2}
+	ts := httptest.NewServer(sink)
+	defer ts.Close()
+
+	b := testBackend(t, map[string]string{
+		"url":            ts.URL,
+		"retry_interval": "10ms",
+	})
+
+	req := &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "secret/foo",
+	}
+	if err := b.LogRequest(&logical.Auth{}, req, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	sink.waitRecords(t, 1)
+	sink.Lock()
+	defer sink.Unlock()
+	if sink.attempts != 3 {
+		t.Fatalf("bad: %d", sink.attempts)
+	}
+}
+
+func TestBackend_FailClosed(t *testing.T) {
+	sink := &testSink{failures: 2}
+	ts := httptest.NewServer(sink)
+	defer ts.Close()
+
+	b := testBackend(t, map[string]string{
+		"url":            ts.URL,
+		"max_retries":    "1",
+		"retry_interval": "1ms",
+	})
+
+	req := &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "secret/foo",
+	}
+
+	// Logging fails with the failed delivery of the record itself
+	if err := b.LogRequest(&logical.Auth{}, req, nil); err == nil {
+		t.Fatalf("expected error")
+	}
+
+	// The next record is delivered and logged without the earlier error
+	if err := b.LogRequest(&logical.Auth{}, req, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	sink.Lock()
+	defer sink.Unlock()
+	if len(sink.records) != 1 {
+		t.Fatalf("bad: %#v", sink.records)
+	}
+}
+
+func TestBackend_RequestTimeout(t *testing.T) {
+	// The sink never answers
+	doneCh := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-doneCh
+	}))
+	defer ts.Close()
+	defer close(doneCh)
+
+	b := testBackend(t, map[string]string{
+		"url":             ts.URL,
+		"max_retries":     "0",
+		"request_timeout": "50ms",
+	})
+
+	req := &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "secret/foo",
+	}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- b.LogRequest(&logical.Auth{}, req, nil)
+	}()
+
+	// The delivery is abandoned and logging fails
+	select {
+	case err := <-errCh:
+		if err == nil {
+			t.Fatalf("expected error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("delivery did not time out")
+	}
+}
+
+func TestBackend_Cleanup(t *testing.T) {
+	sink := &testSink{}
+	ts := httptest.NewServer(sink)
+	defer ts.Close()
+
+	b := testBackend(t, map[string]string{"url": ts.URL})
+	b.(audit.Cleaner).Cleanup()
+
+	// The delivery routine is stopped, so the record is never posted
+	req := &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "secret/foo",
+	}
+	time.Sleep(10 * time.Millisecond)
+	if err := b.LogRequest(&logical.Auth{}, req, nil); err == nil {
+		t.Fatalf("expected error")
+	}
+	time.Sleep(50 * time.Millisecond)
+	sink.Lock()
+	defer sink.Unlock()
+	if sink.attempts != 0 {
+		t.Fatalf("bad: %d", sink.attempts)
+	}
+}
+
+func TestBackend_FailOpen(t *testing.T) {
+	b := testBackend(t, map[string]string{
+		"url":         "http://127.0.0.1:0",
+		"fail_open":   "true",
+		"buffer_size": "1",
+		"max_retries": "0",
+	})
+
+	// Records are dropped rather than failing the request
+	req := &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "secret/foo",
+	}
+	for i := 0; i < 10; i++ {
+		if err := b.LogRequest(&logical.Auth{}, req, nil); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+}
+
+func TestFactory_MissingURL(t *testing.T) {
+	s, err := salt.NewSalt(&logical.InmemStorage{}, &salt.Config{
+		HMAC:     sha256.New,
+		HMACType: "hmac-sha256",
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	_, err = Factory(&audit.BackendConfig{
+		Salt:   s,
+		Config: map[string]string{},
+	})
+	if err == nil {
+		t.Fatalf("expected error")
+	}
+}
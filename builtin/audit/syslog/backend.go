@@ -163,3 +163,7 @@ func (b *Backend) LogResponse(auth *logical.Auth, req *logical.Request,
 	_, err = b.logger.Write(buf.Bytes())
 	return err
 }
+
+func (b *Backend) GetHash(data string) string {
+	return audit.HashString(b.salt, data)
+}
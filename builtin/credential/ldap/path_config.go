@@ -49,6 +49,10 @@ func pathConfig(b *backend) *framework.Path {
 				Type:        framework.TypeBool,
 				Description: "Issue a StartTLS command after establishing unencrypted connection (optional)",
 			},
+			"policy_snapshot": &framework.FieldSchema{
+				Type:        framework.TypeBool,
+				Description: "Keep the policies a token was created with when it is renewed, instead of the policies of the current groups of the user (optional)",
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -98,6 +102,8 @@ func (b *backend) pathConfigRead(
 			"certificate":  cfg.Certificate,
 			"insecure_tls": cfg.InsecureTLS,
 			"starttls":     cfg.StartTLS,
+
+			"policy_snapshot": cfg.PolicySnapshot,
 		},
 	}, nil
 }
@@ -138,6 +144,10 @@ func (b *backend) pathConfigWrite(
 	if startTLS {
 		cfg.StartTLS = startTLS
 	}
+	policySnapshot := d.Get("policy_snapshot").(bool)
+	if policySnapshot {
+		cfg.PolicySnapshot = policySnapshot
+	}
 
 	// Try to connect to the LDAP server, to validate the URL configuration
 	// We can also check the URL at this stage, as anything else would probably
@@ -168,6 +178,8 @@ type ConfigEntry struct {
 	Certificate string
 	InsecureTLS bool
 	StartTLS    bool
+
+	PolicySnapshot bool
 }
 
 func (c *ConfigEntry) GetTLSConfig(host string) (*tls.Config, error) {
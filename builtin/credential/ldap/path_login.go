@@ -45,6 +45,11 @@ func (b *backend) pathLogin(
 
 	sort.Strings(policies)
 
+	cfg, err := b.Config(req)
+	if err != nil {
+		return nil, err
+	}
+
 	return &logical.Response{
 		Auth: &logical.Auth{
 			Policies: policies,
@@ -55,7 +60,8 @@ func (b *backend) pathLogin(
 			InternalData: map[string]interface{}{
 				"password": password,
 			},
-			DisplayName: username,
+			DisplayName:    username,
+			PolicySnapshot: cfg.PolicySnapshot,
 		},
 	}, nil
 }
@@ -72,9 +78,12 @@ func (b *backend) pathLoginRenew(
 		return resp, err
 	}
 
+	// Unless the token keeps the policies it was created with, renew it
+	// with the policies of the current groups of the user
 	sort.Strings(policies)
-	if strings.Join(policies, ",") != prevpolicies {
-		return logical.ErrorResponse("policies have changed, revoking login"), nil
+	if !req.Auth.PolicySnapshot && strings.Join(policies, ",") != prevpolicies {
+		req.Auth.Policies = policies
+		req.Auth.Metadata["policies"] = strings.Join(policies, ",")
 	}
 
 	return framework.LeaseExtend(1*time.Hour, 0, false)(req, d)
@@ -24,7 +24,7 @@ func Backend() *framework.Backend {
 		},
 
 		Paths: []*framework.Path{
-			pathConfigRoot(),
+			pathConfigRoot(&b),
 			pathConfigLease(&b),
 			pathRoles(),
 			pathUser(&b),
@@ -13,6 +13,7 @@ import (
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/iam"
 	"github.com/hashicorp/go-cleanhttp"
 	"github.com/hashicorp/vault/logical"
 	logicaltest "github.com/hashicorp/vault/logical/testing"
@@ -49,6 +50,129 @@ func TestBackend_policyCrud(t *testing.T) {
 	})
 }
 
+func TestBackend_rotateRoot(t *testing.T) {
+	// The root credentials are rotated for a throwaway IAM user, so the
+	// credentials from the environment are never replaced
+	if os.Getenv(logicaltest.TestEnvVar) == "" {
+		t.Skip(fmt.Sprintf(
+			"Acceptance tests skipped unless env '%s' set",
+			logicaltest.TestEnvVar))
+	}
+	testAccPreCheck(t)
+
+	admin := iam.New(session.New(&aws.Config{
+		Credentials: credentials.NewStaticCredentials(
+			os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY"), ""),
+		Region:     aws.String(os.Getenv("AWS_DEFAULT_REGION")),
+		HTTPClient: cleanhttp.DefaultClient(),
+	}))
+	username := fmt.Sprintf("vault-test-rotate-%d", time.Now().Unix())
+	if _, err := admin.CreateUser(&iam.CreateUserInput{
+		UserName: aws.String(username),
+	}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer func() {
+		if err := testAccDeleteUser(admin, username); err != nil {
+			t.Errorf("failed to delete user %s: %s", username, err)
+		}
+	}()
+	if _, err := admin.PutUserPolicy(&iam.PutUserPolicyInput{
+		UserName:       aws.String(username),
+		PolicyName:     aws.String("rotate"),
+		PolicyDocument: aws.String(testRotatePolicy),
+	}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	keyResp, err := admin.CreateAccessKey(&iam.CreateAccessKeyInput{
+		UserName: aws.String(username),
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	oldKeyId := *keyResp.AccessKey.AccessKeyId
+
+	log.Println("[WARN] Sleeping for 10 seconds waiting for AWS...")
+	time.Sleep(10 * time.Second)
+
+	logicaltest.Test(t, logicaltest.TestCase{
+		Backend: Backend(),
+		Steps: []logicaltest.TestStep{
+			logicaltest.TestStep{
+				Operation: logical.WriteOperation,
+				Path:      "config/root",
+				Data: map[string]interface{}{
+					"access_key": oldKeyId,
+					"secret_key": *keyResp.AccessKey.SecretAccessKey,
+					"region":     os.Getenv("AWS_DEFAULT_REGION"),
+				},
+			},
+			logicaltest.TestStep{
+				Operation: logical.RotateOperation,
+				Path:      "config/root",
+				Check: func(resp *logical.Response) error {
+					keys, err := admin.ListAccessKeys(&iam.ListAccessKeysInput{
+						UserName: aws.String(username),
+					})
+					if err != nil {
+						return err
+					}
+					if len(keys.AccessKeyMetadata) != 1 {
+						return fmt.Errorf("bad: %v", keys.AccessKeyMetadata)
+					}
+					if *keys.AccessKeyMetadata[0].AccessKeyId == oldKeyId {
+						return fmt.Errorf("access key was not rotated")
+					}
+					return nil
+				},
+			},
+		},
+	})
+}
+
+func TestBackend_rotateRootNotConfigured(t *testing.T) {
+	b := Backend()
+	if !b.HandlesOperation(logical.RotateOperation, "config/root") {
+		t.Fatalf("config/root should support rotation")
+	}
+
+	req := &logical.Request{
+		Operation: logical.RotateOperation,
+		Path:      "config/root",
+		Storage:   new(logical.InmemStorage),
+	}
+	if _, err := b.HandleRequest(req); err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+func testAccDeleteUser(client *iam.IAM, username string) error {
+	keys, err := client.ListAccessKeys(&iam.ListAccessKeysInput{
+		UserName: aws.String(username),
+	})
+	if err != nil {
+		return err
+	}
+	for _, k := range keys.AccessKeyMetadata {
+		if _, err := client.DeleteAccessKey(&iam.DeleteAccessKeyInput{
+			AccessKeyId: k.AccessKeyId,
+			UserName:    aws.String(username),
+		}); err != nil {
+			return err
+		}
+	}
+	if _, err := client.DeleteUserPolicy(&iam.DeleteUserPolicyInput{
+		UserName:   aws.String(username),
+		PolicyName: aws.String("rotate"),
+	}); err != nil {
+		return err
+	}
+	_, err = client.DeleteUser(&iam.DeleteUserInput{
+		UserName: aws.String(username),
+	})
+	return err
+}
+
 func testAccPreCheck(t *testing.T) {
 	if v := os.Getenv("AWS_ACCESS_KEY_ID"); v == "" {
 		t.Fatal("AWS_ACCESS_KEY_ID must be set for acceptance tests")
@@ -177,3 +301,22 @@ const testPolicy = `
     ]
 }
 `
+
+const testRotatePolicy = `
+{
+    "Version": "2012-10-17",
+    "Statement": [
+        {
+            "Effect": "Allow",
+            "Action": [
+                "iam:GetUser",
+                "iam:CreateAccessKey",
+                "iam:DeleteAccessKey"
+            ],
+            "Resource": [
+                "arn:aws:iam::*:user/${aws:username}"
+            ]
+        }
+    ]
+}
+`
@@ -12,6 +12,25 @@ import (
 )
 
 func clientIAM(s logical.Storage) (*iam.IAM, error) {
+	config, err := readRootConfig(s)
+	if err != nil {
+		return nil, err
+	}
+	return clientIAMForConfig(config), nil
+}
+
+func clientIAMForConfig(config *rootConfig) *iam.IAM {
+	creds := credentials.NewStaticCredentials(config.AccessKey, config.SecretKey, "")
+	awsConfig := &aws.Config{
+		Credentials: creds,
+		Region:      aws.String(config.Region),
+		HTTPClient:  cleanhttp.DefaultClient(),
+	}
+
+	return iam.New(session.New(awsConfig))
+}
+
+func readRootConfig(s logical.Storage) (*rootConfig, error) {
 	entry, err := s.Get("config/root")
 	if err != nil {
 		return nil, err
@@ -26,13 +45,5 @@ func clientIAM(s logical.Storage) (*iam.IAM, error) {
 	if err := entry.DecodeJSON(&config); err != nil {
 		return nil, fmt.Errorf("error reading root configuration: %s", err)
 	}
-
-	creds := credentials.NewStaticCredentials(config.AccessKey, config.SecretKey, "")
-	awsConfig := &aws.Config{
-		Credentials: creds,
-		Region:      aws.String(config.Region),
-		HTTPClient:  cleanhttp.DefaultClient(),
-	}
-
-	return iam.New(session.New(awsConfig)), nil
+	return &config, nil
 }
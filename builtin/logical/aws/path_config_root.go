@@ -1,11 +1,15 @@
 package aws
 
 import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/iam"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
 )
 
-func pathConfigRoot() *framework.Path {
+func pathConfigRoot(b *backend) *framework.Path {
 	return &framework.Path{
 		Pattern: "config/root",
 		Fields: map[string]*framework.FieldSchema{
@@ -26,7 +30,8 @@ func pathConfigRoot() *framework.Path {
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
-			logical.WriteOperation: pathConfigRootWrite,
+			logical.WriteOperation:  pathConfigRootWrite,
+			logical.RotateOperation: b.pathConfigRootRotate,
 		},
 
 		HelpSynopsis:    pathConfigRootHelpSyn,
@@ -57,6 +62,62 @@ func pathConfigRootWrite(
 	return nil, nil
 }
 
+// pathConfigRootRotate replaces the configured access key with a new
+// one for the same IAM user, and then deletes the old key.
+func (b *backend) pathConfigRootRotate(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	config, err := readRootConfig(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	client := clientIAMForConfig(config)
+
+	// Without a user name, IAM acts on the user owning the credentials
+	userResp, err := client.GetUser(&iam.GetUserInput{})
+	if err != nil {
+		return nil, fmt.Errorf("error looking up root user: %s", err)
+	}
+	username := userResp.User.UserName
+
+	keyResp, err := client.CreateAccessKey(&iam.CreateAccessKeyInput{
+		UserName: username,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error creating access key: %s", err)
+	}
+	newKeyId := keyResp.AccessKey.AccessKeyId
+
+	newConfig := *config
+	newConfig.AccessKey = *newKeyId
+	newConfig.SecretKey = *keyResp.AccessKey.SecretAccessKey
+	entry, err := logical.StorageEntryJSON("config/root", newConfig)
+	if err == nil {
+		err = req.Storage.Put(entry)
+	}
+	if err != nil {
+		// The old key is still in use, so remove the new one
+		client.DeleteAccessKey(&iam.DeleteAccessKeyInput{
+			AccessKeyId: newKeyId,
+			UserName:    username,
+		})
+		return nil, err
+	}
+
+	// The new key is stored at this point, so failing to delete the old
+	// one is not a failed rotation. The key has to be removed by hand.
+	_, err = client.DeleteAccessKey(&iam.DeleteAccessKeyInput{
+		AccessKeyId: aws.String(config.AccessKey),
+		UserName:    username,
+	})
+	if err != nil {
+		b.Logger().Printf(
+			"[WARN] aws: failed to delete old root access key %s: %s",
+			config.AccessKey, err)
+	}
+
+	return nil, nil
+}
+
 type rootConfig struct {
 	AccessKey string `json:"access_key"`
 	SecretKey string `json:"secret_key"`
@@ -72,4 +133,10 @@ Before doing anything, the AWS backend needs credentials that are able
 to manage IAM policies, users, access keys, etc. This endpoint is used
 to configure those credentials. They don't necessarilly need to be root
 keys as long as they have permission to manage IAM.
+
+The path supports rotation, which creates a new access key for the IAM
+user of the configured credentials and deletes the old one. The user
+needs permission to get itself and to create and delete its own access
+keys. Use "sys/rotation-schedule/<mount>/config/root" to rotate the
+credentials on a schedule.
 `
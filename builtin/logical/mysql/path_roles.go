@@ -34,6 +34,14 @@ func pathRoles(b *backend) *framework.Path {
 	}
 }
 
+func pathRolesDetailed(b *backend) *framework.PathRoles {
+	return &framework.PathRoles{
+		Read:            b.roleConfig,
+		HelpSynopsis:    pathRolesDetailedHelpSyn,
+		HelpDescription: pathRolesDetailedHelpDesc,
+	}
+}
+
 func (b *backend) Role(s logical.Storage, n string) (*roleEntry, error) {
 	entry, err := s.Get("role/" + n)
 	if err != nil {
@@ -63,18 +71,31 @@ func (b *backend) pathRoleDelete(
 
 func (b *backend) pathRoleRead(
 	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	role, err := b.Role(req.Storage, data.Get("name").(string))
+	config, err := b.roleConfig(req.Storage, data.Get("name").(string))
 	if err != nil {
 		return nil, err
 	}
-	if role == nil {
+	if config == nil {
 		return nil, nil
 	}
 
 	return &logical.Response{
-		Data: map[string]interface{}{
-			"sql": role.SQL,
-		},
+		Data: config,
+	}, nil
+}
+
+// roleConfig returns the configuration of a role as it is read
+func (b *backend) roleConfig(s logical.Storage, n string) (map[string]interface{}, error) {
+	role, err := b.Role(s, n)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return nil, nil
+	}
+
+	return map[string]interface{}{
+		"sql": role.SQL,
 	}, nil
 }
 
@@ -143,3 +164,12 @@ Example of a decent SQL query to use:
 Note the above user would be able to access anything in db1. Please see the MySQL
 manual on the GRANT command to learn how to do more fine grained access.
 `
+
+const pathRolesDetailedHelpSyn = `
+List the roles with their configuration.
+`
+
+const pathRolesDetailedHelpDesc = `
+This path returns the names of the roles and the SQL used to create the
+users of each of them.
+`
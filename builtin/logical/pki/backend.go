@@ -25,6 +25,7 @@ func Backend() *framework.Backend {
 				"config/*",
 				"revoke/*",
 				"crl/rotate",
+				"tidy",
 			},
 			Unauthenticated: []string{
 				"cert/*",
@@ -35,7 +36,7 @@ func Backend() *framework.Backend {
 			},
 		},
 
-		Paths: []*framework.Path{
+		Paths: append([]*framework.Path{
 			pathRoles(&b),
 			pathConfigCA(&b),
 			pathConfigCRL(&b),
@@ -46,7 +47,8 @@ func Backend() *framework.Backend {
 			pathFetchCRLViaCertPath(&b),
 			pathFetchValid(&b),
 			pathRevoke(&b),
-		},
+			pathTidy(&b),
+		}, pathRolesDetailed(&b).Paths()...),
 
 		Secrets: []*framework.Secret{
 			secretCerts(&b),
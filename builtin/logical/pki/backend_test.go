@@ -1,12 +1,18 @@
 package pki
 
 import (
+	crand "crypto/rand"
+	"crypto/rsa"
 	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/pem"
 	"fmt"
 	"math"
+	"math/big"
 	"math/rand"
 	"os"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -82,6 +88,370 @@ func TestBackend_roles(t *testing.T) {
 	logicaltest.Test(t, testCase)
 }
 
+// Issues certificates for allowed and disallowed domains, then revokes
+// one and ensures it is listed in the CRL
+func TestBackend_issueRevoke(t *testing.T) {
+	b, err := Factory(&logical.BackendConfig{
+		Logger: nil,
+		System: &logical.StaticSystemView{
+			DefaultLeaseTTLVal: time.Hour * 24,
+			MaxLeaseTTLVal:     time.Hour * 24 * 30,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Unable to create backend: %s", err)
+	}
+	storage := &logical.InmemStorage{}
+
+	request := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		req := logical.TestRequest(t, op, path)
+		req.Storage = storage
+		req.Data = data
+		resp, err := b.HandleRequest(req)
+		if err != nil {
+			t.Fatalf("%s %s: %s", op, path, err)
+		}
+		return resp
+	}
+
+	request(logical.WriteOperation, "config/ca", map[string]interface{}{
+		"pem_bundle": generateTestCA(t),
+	})
+	request(logical.WriteOperation, "roles/test", map[string]interface{}{
+		"allowed_base_domain": "example.com",
+		"allow_subdomains":    true,
+		"max_ttl":             "12h",
+	})
+
+	resp := request(logical.WriteOperation, "issue/test", map[string]interface{}{
+		"common_name": "foo.example.org",
+	})
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("Expected an error for a disallowed domain, got: %#v", resp)
+	}
+
+	resp = request(logical.WriteOperation, "issue/test", map[string]interface{}{
+		"common_name": "foo.example.com",
+		"ttl":         "1h",
+	})
+	if resp == nil || resp.IsError() {
+		t.Fatalf("Bad response: %#v", resp)
+	}
+	if resp.Secret == nil || resp.Secret.TTL != time.Hour {
+		t.Fatalf("Expected a lease for the validity period, got: %#v", resp.Secret)
+	}
+	var certBundle certutil.CertBundle
+	if err := mapstructure.Decode(resp.Data, &certBundle); err != nil {
+		t.Fatalf("Error decoding cert bundle: %s", err)
+	}
+	parsedCertBundle, err := certBundle.ToParsedCertBundle()
+	if err != nil {
+		t.Fatalf("Error parsing cert bundle: %s", err)
+	}
+	cert := parsedCertBundle.Certificate
+	if cert.Subject.CommonName != "foo.example.com" {
+		t.Fatalf("Bad common name: %s", cert.Subject.CommonName)
+	}
+
+	// The CRL is empty until the certificate is revoked
+	request(logical.ReadOperation, "crl/rotate", nil)
+	readCRL := func() *pkix.CertificateList {
+		resp := request(logical.ReadOperation, "crl", nil)
+		crl, err := x509.ParseCRL(resp.Data["http_raw_body"].([]byte))
+		if err != nil {
+			t.Fatalf("Error parsing CRL: %s", err)
+		}
+		return crl
+	}
+	if revoked := readCRL().TBSCertList.RevokedCertificates; len(revoked) != 0 {
+		t.Fatalf("Expected an empty CRL, got: %#v", revoked)
+	}
+
+	resp = request(logical.WriteOperation, "revoke", map[string]interface{}{
+		"serial_number": certBundle.SerialNumber,
+	})
+	if resp != nil && resp.IsError() {
+		t.Fatalf("Bad response: %#v", resp)
+	}
+
+	revoked := readCRL().TBSCertList.RevokedCertificates
+	if len(revoked) != 1 || revoked[0].SerialNumber.Cmp(cert.SerialNumber) != 0 {
+		t.Fatalf("Expected the revoked certificate in the CRL, got: %#v", revoked)
+	}
+}
+
+// Seeds expired and valid certificates in the cert store and the
+// revocation list, and ensures only the expired ones are tidied
+func TestBackend_rolesDetailed(t *testing.T) {
+	b, err := Factory(&logical.BackendConfig{
+		Logger: nil,
+		System: &logical.StaticSystemView{
+			DefaultLeaseTTLVal: time.Hour * 24,
+			MaxLeaseTTLVal:     time.Hour * 24 * 30,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Unable to create backend: %s", err)
+	}
+	storage := &logical.InmemStorage{}
+
+	request := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		req := logical.TestRequest(t, op, path)
+		req.Storage = storage
+		req.Data = data
+		resp, err := b.HandleRequest(req)
+		if err != nil {
+			t.Fatalf("%s %s: %s", op, path, err)
+		}
+		return resp
+	}
+
+	for _, name := range []string{"web", "api"} {
+		request(logical.WriteOperation, "roles/"+name, map[string]interface{}{
+			"allowed_base_domain": name + ".example.com",
+			"ttl":                 "1h",
+		})
+	}
+
+	resp := request(logical.ReadOperation, "list-roles-detailed", nil)
+	if keys := resp.Data["keys"].([]string); !reflect.DeepEqual(keys, []string{"api", "web"}) {
+		t.Fatalf("Bad keys: %#v", keys)
+	}
+	roles := resp.Data["roles"].(map[string]interface{})
+	for _, name := range []string{"web", "api"} {
+		read := request(logical.ReadOperation, "roles/"+name, nil)
+		if !reflect.DeepEqual(roles[name], read.Data) {
+			t.Fatalf("Bad role %s: %#v %#v", name, roles[name], read.Data)
+		}
+	}
+}
+
+func TestBackend_configCA(t *testing.T) {
+	b, err := Factory(&logical.BackendConfig{
+		Logger: nil,
+		System: &logical.StaticSystemView{
+			DefaultLeaseTTLVal: time.Hour * 24,
+			MaxLeaseTTLVal:     time.Hour * 24 * 30,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Unable to create backend: %s", err)
+	}
+	storage := &logical.InmemStorage{}
+
+	request := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		req := logical.TestRequest(t, op, path)
+		req.Storage = storage
+		req.Data = data
+		resp, err := b.HandleRequest(req)
+		if err != nil {
+			t.Fatalf("%s %s: %s", op, path, err)
+		}
+		return resp
+	}
+
+	// Split two CAs into their keys and certificates
+	split := func(bundle string) (string, string) {
+		i := strings.Index(bundle, "-----BEGIN CERTIFICATE-----")
+		return bundle[:i], bundle[i:]
+	}
+	key, cert := split(generateTestCA(t))
+	otherKey, otherCert := split(generateTestCA(t))
+
+	// A key that does not belong to the certificate is rejected
+	resp := request(logical.WriteOperation, "config/ca", map[string]interface{}{
+		"pem_bundle": otherKey + cert,
+	})
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("Expected an error for a mismatched key, got: %#v", resp)
+	}
+
+	// So is a bundle without a key
+	resp = request(logical.WriteOperation, "config/ca", map[string]interface{}{
+		"pem_bundle": cert,
+	})
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("Expected an error for a missing key, got: %#v", resp)
+	}
+
+	// Nothing was stored
+	if entry, err := storage.Get("config/ca_bundle"); err != nil || entry != nil {
+		t.Fatalf("Bad: %#v %v", entry, err)
+	}
+
+	// A matching pair is imported and served as the CA
+	resp = request(logical.WriteOperation, "config/ca", map[string]interface{}{
+		"pem_bundle": key + cert,
+	})
+	if resp != nil && resp.IsError() {
+		t.Fatalf("Unexpected error: %#v", resp)
+	}
+	resp = request(logical.ReadOperation, "ca/pem", nil)
+	if body := string(resp.Data[logical.HTTPRawBody].([]byte)); body != cert {
+		t.Fatalf("Bad: %s", body)
+	}
+
+	// The certificate may also come before the key
+	resp = request(logical.WriteOperation, "config/ca", map[string]interface{}{
+		"pem_bundle": otherCert + otherKey,
+	})
+	if resp != nil && resp.IsError() {
+		t.Fatalf("Unexpected error: %#v", resp)
+	}
+}
+
+func TestBackend_tidy(t *testing.T) {
+	b, err := Factory(&logical.BackendConfig{
+		Logger: nil,
+		System: &logical.StaticSystemView{
+			DefaultLeaseTTLVal: time.Hour * 24,
+			MaxLeaseTTLVal:     time.Hour * 24 * 30,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Unable to create backend: %s", err)
+	}
+	storage := &logical.InmemStorage{}
+
+	request := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		req := logical.TestRequest(t, op, path)
+		req.Storage = storage
+		req.Data = data
+		resp, err := b.HandleRequest(req)
+		if err != nil {
+			t.Fatalf("%s %s: %s", op, path, err)
+		}
+		return resp
+	}
+
+	request(logical.WriteOperation, "config/ca", map[string]interface{}{
+		"pem_bundle": generateTestCA(t),
+	})
+
+	key, err := rsa.GenerateKey(crand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("Error generating key: %s", err)
+	}
+	serial := 0
+	seed := func(prefix string, notAfter time.Time) string {
+		serial++
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(int64(serial)),
+			Subject:      pkix.Name{CommonName: "foo.example.com"},
+			NotBefore:    notAfter.Add(-24 * time.Hour),
+			NotAfter:     notAfter,
+		}
+		certBytes, err := x509.CreateCertificate(crand.Reader, template, template, key.Public(), key)
+		if err != nil {
+			t.Fatalf("Error generating certificate: %s", err)
+		}
+
+		name := fmt.Sprintf("%02x", serial)
+		entry := &logical.StorageEntry{Key: prefix + name, Value: certBytes}
+		if prefix == "revoked/" {
+			entry, err = logical.StorageEntryJSON(prefix+name, revocationInfo{
+				CertificateBytes: certBytes,
+				RevocationTime:   notAfter.Add(-time.Hour).Unix(),
+			})
+			if err != nil {
+				t.Fatalf("Error encoding revocation info: %s", err)
+			}
+		}
+		if err := storage.Put(entry); err != nil {
+			t.Fatalf("Error storing certificate: %s", err)
+		}
+		return name
+	}
+
+	now := time.Now()
+	expiredCert := seed("certs/", now.Add(-100*time.Hour))
+	bufferedCert := seed("certs/", now.Add(-time.Hour))
+	validCert := seed("certs/", now.Add(time.Hour))
+	expiredRevoked := seed("revoked/", now.Add(-100*time.Hour))
+	validRevoked := seed("revoked/", now.Add(time.Hour))
+
+	// Nothing is removed unless requested
+	resp := request(logical.WriteOperation, "tidy", nil)
+	if resp.Data["tidied_certs"] != 0 || resp.Data["tidied_revoked"] != 0 {
+		t.Fatalf("Bad response: %#v", resp.Data)
+	}
+
+	resp = request(logical.WriteOperation, "tidy", map[string]interface{}{
+		"tidy_cert_store":      true,
+		"tidy_revocation_list": true,
+	})
+	if resp.Data["tidied_certs"] != 1 || resp.Data["tidied_revoked"] != 1 {
+		t.Fatalf("Bad response: %#v", resp.Data)
+	}
+
+	exists := func(key string) bool {
+		entry, err := storage.Get(key)
+		if err != nil {
+			t.Fatalf("Error reading %s: %s", key, err)
+		}
+		return entry != nil
+	}
+	for key, expected := range map[string]bool{
+		"certs/" + expiredCert:      false,
+		"certs/" + bufferedCert:     true,
+		"certs/" + validCert:        true,
+		"revoked/" + expiredRevoked: false,
+		"revoked/" + validRevoked:   true,
+	} {
+		if exists(key) != expected {
+			t.Fatalf("Expected existence of %s to be %t", key, expected)
+		}
+	}
+
+	// The rebuilt CRL only lists the remaining revoked certificate
+	resp = request(logical.ReadOperation, "crl", nil)
+	crl, err := x509.ParseCRL(resp.Data["http_raw_body"].([]byte))
+	if err != nil {
+		t.Fatalf("Error parsing CRL: %s", err)
+	}
+	revoked := crl.TBSCertList.RevokedCertificates
+	if len(revoked) != 1 || revoked[0].SerialNumber.Int64() != 5 {
+		t.Fatalf("Bad CRL entries: %#v", revoked)
+	}
+
+	// A smaller safety buffer removes the recently expired certificate
+	resp = request(logical.WriteOperation, "tidy", map[string]interface{}{
+		"tidy_cert_store": true,
+		"safety_buffer":   "30m",
+	})
+	if resp.Data["tidied_certs"] != 1 || exists("certs/"+bufferedCert) || !exists("certs/"+validCert) {
+		t.Fatalf("Bad response: %#v", resp.Data)
+	}
+}
+
+// Generates a self-signed CA valid for a year, as a PEM bundle
+func generateTestCA(t *testing.T) string {
+	key, err := rsa.GenerateKey(crand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Error generating CA key: %s", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Vault Test CA"},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(365 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	certBytes, err := x509.CreateCertificate(crand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatalf("Error generating CA certificate: %s", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(key),
+	})) + string(pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: certBytes,
+	}))
+}
+
 // Performs some validity checking on the returned bundles
 func checkCertsAndPrivateKey(keyType string, usage certUsage, validity time.Duration, certBundle *certutil.CertBundle) (*certutil.ParsedCertBundle, error) {
 	parsedCertBundle, err := certBundle.ToParsedCertBundle()
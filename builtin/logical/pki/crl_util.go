@@ -144,7 +144,7 @@ func buildCRL(b *backend, req *logical.Request) error {
 		}
 
 		if revokedCert.NotAfter.Before(time.Now()) {
-			err = req.Storage.Delete(serial)
+			err = req.Storage.Delete("revoked/" + serial)
 			if err != nil {
 				return certutil.InternalError{Err: fmt.Sprintf("Unable to delete revoked, expired certificate with serial %s: %s", serial, err)}
 			}
@@ -48,6 +48,23 @@ func (b *backend) pathCAWrite(
 		parsedBundle.CertificateBytes = parsedBundle.IssuingCABytes
 	}
 
+	if parsedBundle.Certificate == nil {
+		return logical.ErrorResponse("The PEM bundle does not contain a certificate"), nil
+	}
+	if parsedBundle.PrivateKey == nil {
+		return logical.ErrorResponse("The PEM bundle does not contain a private key"), nil
+	}
+
+	// The private key must belong to the certificate, otherwise issued
+	// certificates would not verify against the CA
+	equal, err := certutil.ComparePublicKeys(parsedBundle.Certificate.PublicKey, parsedBundle.PrivateKey.Public())
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+	if !equal {
+		return logical.ErrorResponse("The private key does not match the public key of the certificate"), nil
+	}
+
 	// TODO?: CRLs can only be generated with RSA keys right now, in the
 	// Go standard library. The plubming is here to support non-RSA keys
 	// if the library gets support
@@ -152,6 +152,14 @@ the key_type.`,
 	}
 }
 
+func pathRolesDetailed(b *backend) *framework.PathRoles {
+	return &framework.PathRoles{
+		Read:            b.roleConfig,
+		HelpSynopsis:    pathRolesDetailedHelpSyn,
+		HelpDescription: pathRolesDetailedHelpDesc,
+	}
+}
+
 func (b *backend) getRole(s logical.Storage, n string) (*roleEntry, error) {
 	entry, err := s.Get("role/" + n)
 	if err != nil {
@@ -203,7 +211,24 @@ func (b *backend) pathRoleDelete(
 
 func (b *backend) pathRoleRead(
 	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	role, err := b.getRole(req.Storage, data.Get("name").(string))
+	config, err := b.roleConfig(req.Storage, data.Get("name").(string))
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return nil, nil
+	}
+
+	resp := &logical.Response{
+		Data: config,
+	}
+
+	return resp, nil
+}
+
+// roleConfig returns the configuration of a role as it is read
+func (b *backend) roleConfig(s logical.Storage, n string) (map[string]interface{}, error) {
+	role, err := b.getRole(s, n)
 	if err != nil {
 		return nil, err
 	}
@@ -224,11 +249,7 @@ func (b *backend) pathRoleRead(
 		}
 	}
 
-	resp := &logical.Response{
-		Data: structs.New(role).Map(),
-	}
-
-	return resp, nil
+	return structs.New(role).Map(), nil
 }
 
 func (b *backend) pathRoleCreate(
@@ -352,3 +373,12 @@ Manage the roles that can be created with this backend.
 const pathRoleHelpDesc = `
 This path lets you manage the roles that can be created with this backend.
 `
+
+const pathRolesDetailedHelpSyn = `
+List the roles with their configuration.
+`
+
+const pathRolesDetailedHelpDesc = `
+This path returns the names of the roles and the configuration of each
+of them, as returned when reading the role.
+`
@@ -0,0 +1,165 @@
+package pki
+
+import (
+	"crypto/x509"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/helper/certutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func pathTidy(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: `tidy`,
+		Fields: map[string]*framework.FieldSchema{
+			"tidy_cert_store": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `Set to true to remove expired certificates
+from the store of issued certificates`,
+			},
+			"tidy_revocation_list": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `Set to true to remove expired certificates
+from the revocation list and rebuild the CRL`,
+			},
+			"safety_buffer": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `The amount of time that must have passed
+since the expiration of a certificate before it
+is removed; defaults to 72h`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.WriteOperation: b.pathTidyWrite,
+		},
+
+		HelpSynopsis:    pathTidyHelpSyn,
+		HelpDescription: pathTidyHelpDesc,
+	}
+}
+
+func (b *backend) pathTidyWrite(req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	tidyCertStore := data.Get("tidy_cert_store").(bool)
+	tidyRevocationList := data.Get("tidy_revocation_list").(bool)
+
+	safetyBuffer := 72 * time.Hour
+	if raw := data.Get("safety_buffer").(string); raw != "" {
+		var err error
+		safetyBuffer, err = time.ParseDuration(raw)
+		if err != nil {
+			return logical.ErrorResponse(fmt.Sprintf(
+				"Invalid safety_buffer: %s", err)), nil
+		}
+		if safetyBuffer < 0 {
+			return logical.ErrorResponse("safety_buffer must not be negative"), nil
+		}
+	}
+
+	// Only certificates that expired before this time are removed
+	cutoff := time.Now().Add(-safetyBuffer)
+
+	b.revokeStorageLock.Lock()
+	defer b.revokeStorageLock.Unlock()
+
+	var tidiedCerts, tidiedRevoked int
+	if tidyCertStore {
+		serials, err := req.Storage.List("certs/")
+		if err != nil {
+			return nil, fmt.Errorf("Error fetching list of certs: %s", err)
+		}
+
+		for _, serial := range serials {
+			certEntry, err := req.Storage.Get("certs/" + serial)
+			if err != nil {
+				return nil, fmt.Errorf("Error fetching certificate %s: %s", serial, err)
+			}
+			if certEntry == nil {
+				continue
+			}
+
+			cert, err := x509.ParseCertificate(certEntry.Value)
+			if err != nil {
+				return nil, fmt.Errorf("Unable to parse stored certificate with serial %s: %s", serial, err)
+			}
+			if !cert.NotAfter.Before(cutoff) {
+				continue
+			}
+
+			if err := req.Storage.Delete("certs/" + serial); err != nil {
+				return nil, fmt.Errorf("Error deleting expired certificate with serial %s: %s", serial, err)
+			}
+			tidiedCerts++
+		}
+	}
+
+	if tidyRevocationList {
+		serials, err := req.Storage.List("revoked/")
+		if err != nil {
+			return nil, fmt.Errorf("Error fetching list of revoked certs: %s", err)
+		}
+
+		var revInfo revocationInfo
+		for _, serial := range serials {
+			revokedEntry, err := req.Storage.Get("revoked/" + serial)
+			if err != nil {
+				return nil, fmt.Errorf("Unable to fetch revoked cert with serial %s: %s", serial, err)
+			}
+			if revokedEntry == nil {
+				continue
+			}
+
+			if err := revokedEntry.DecodeJSON(&revInfo); err != nil {
+				return nil, fmt.Errorf("Error decoding revocation entry for serial %s: %s", serial, err)
+			}
+			revokedCert, err := x509.ParseCertificate(revInfo.CertificateBytes)
+			if err != nil {
+				return nil, fmt.Errorf("Unable to parse stored revoked certificate with serial %s: %s", serial, err)
+			}
+			if !revokedCert.NotAfter.Before(cutoff) {
+				continue
+			}
+
+			if err := req.Storage.Delete("revoked/" + serial); err != nil {
+				return nil, fmt.Errorf("Error deleting expired revoked certificate with serial %s: %s", serial, err)
+			}
+			tidiedRevoked++
+		}
+
+		// Expired certificates are not part of the CRL; rebuilding it
+		// keeps it consistent with the revocation list
+		if tidiedRevoked > 0 {
+			crlErr := buildCRL(b, req)
+			switch crlErr.(type) {
+			case certutil.UserError:
+				return logical.ErrorResponse(fmt.Sprintf("Error during CRL building: %s", crlErr)), nil
+			case certutil.InternalError:
+				return nil, fmt.Errorf("Error encountered during CRL building: %s", crlErr)
+			}
+		}
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"tidied_certs":   tidiedCerts,
+			"tidied_revoked": tidiedRevoked,
+		},
+	}, nil
+}
+
+const pathTidyHelpSyn = `
+Tidy up the backend by removing expired certificates.
+`
+
+const pathTidyHelpDesc = `
+This endpoint allows expired certificates to be removed from the backend,
+freeing up storage and keeping the CRL small. 'tidy_cert_store' removes them
+from the store of issued certificates, and 'tidy_revocation_list' removes
+them from the list of revoked certificates, after which the CRL is rebuilt.
+
+A certificate is only removed once the 'safety_buffer' duration has passed
+since its expiration; certificates that are still valid are never removed.
+A root token is required.
+`
@@ -26,12 +26,12 @@ func Backend() *framework.Backend {
 			},
 		},
 
-		Paths: []*framework.Path{
+		Paths: append([]*framework.Path{
 			pathConfigConnection(&b),
 			pathConfigLease(&b),
 			pathRoles(&b),
 			pathRoleCreate(&b),
-		},
+		}, pathRolesDetailed(&b).Paths()...),
 
 		Secrets: []*framework.Secret{
 			secretCreds(&b),
@@ -41,16 +41,20 @@ func Backend(conf *logical.BackendConfig) (*framework.Backend, error) {
 			},
 			Unauthenticated: []string{
 				"verify",
+				"public_key",
 			},
 		},
 
 		Paths: []*framework.Path{
 			pathConfigZeroAddress(&b),
+			pathConfigCA(&b),
+			pathPublicKey(&b),
 			pathKeys(&b),
 			pathRoles(&b),
 			pathCredsCreate(&b),
 			pathLookup(&b),
 			pathVerify(&b),
+			pathSign(&b),
 		},
 
 		Secrets: []*framework.Secret{
@@ -65,10 +69,10 @@ const backendHelp = `
 The SSH backend generates credentials allowing clients to establish SSH
 connections to remote hosts.
 
-There are two variants of the backend, which generate different types of
-credentials: dynamic keys and One-Time Passwords (OTPs). The desired behavior
-is role-specific and chosen at role creation time with the 'key_type'
-parameter.
+There are three variants of the backend, which generate different types of
+credentials: dynamic keys, One-Time Passwords (OTPs) and certificates signed
+by a CA managed by Vault. The desired behavior is role-specific and chosen at
+role creation time with the 'key_type' parameter.
 
 Please see the backend documentation for a thorough description of both
 types. The Vault team strongly recommends the OTP type.
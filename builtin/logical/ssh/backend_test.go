@@ -494,3 +494,164 @@ func testCredsWrite(t *testing.T, roleName string, data map[string]interface{},
 		},
 	}
 }
+
+func TestSSHBackend_CASign(t *testing.T) {
+	b, err := Factory(&logical.BackendConfig{
+		StorageView: &logical.InmemStorage{},
+		System: &logical.StaticSystemView{
+			DefaultLeaseTTLVal: 2 * time.Minute,
+			MaxLeaseTTLVal:     10 * time.Minute,
+		},
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	storage := &logical.InmemStorage{}
+
+	request := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		req := logical.TestRequest(t, op, path)
+		req.Storage = storage
+		req.Data = data
+		resp, err := b.HandleRequest(req)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		return resp
+	}
+
+	// Signing fails until the CA is configured
+	request(logical.WriteOperation, "roles/web", map[string]interface{}{
+		"key_type":      KeyTypeCA,
+		"default_user":  "ubuntu",
+		"allowed_users": "alice,bob",
+		"max_ttl":       "5m",
+	})
+	publicKey, _, err := generateRSAKeys(1024)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	resp := request(logical.WriteOperation, "sign/web", map[string]interface{}{
+		"public_key": publicKey,
+	})
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected error, got: %#v", resp)
+	}
+
+	resp = request(logical.WriteOperation, "config/ca", nil)
+	caPublicKey := resp.Data["public_key"].(string)
+	resp = request(logical.ReadOperation, "public_key", nil)
+	if resp.Data["public_key"] != caPublicKey {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+	if _, ok := resp.Data["private_key"]; ok {
+		t.Fatalf("private key returned")
+	}
+
+	// Principals outside the role are rejected
+	resp = request(logical.WriteOperation, "sign/web", map[string]interface{}{
+		"public_key":       publicKey,
+		"valid_principals": "alice,root",
+	})
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected error, got: %#v", resp)
+	}
+
+	// So are validity periods above the role max
+	resp = request(logical.WriteOperation, "sign/web", map[string]interface{}{
+		"public_key": publicKey,
+		"ttl":        "6m",
+	})
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected error, got: %#v", resp)
+	}
+
+	before := time.Now()
+	resp = request(logical.WriteOperation, "sign/web", map[string]interface{}{
+		"public_key":       publicKey,
+		"valid_principals": "alice,ubuntu",
+		"ttl":              "3m",
+	})
+	if resp == nil || resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+	parsed, _, _, _, err := ssh.ParseAuthorizedKey([]byte(resp.Data["signed_key"].(string)))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	cert, ok := parsed.(*ssh.Certificate)
+	if !ok {
+		t.Fatalf("bad: %#v", parsed)
+	}
+	if resp.Data["serial_number"] != fmt.Sprintf("%016x", cert.Serial) {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+	if cert.CertType != ssh.UserCert {
+		t.Fatalf("bad: %d", cert.CertType)
+	}
+	if !reflect.DeepEqual(cert.ValidPrincipals, []string{"alice", "ubuntu"}) {
+		t.Fatalf("bad: %#v", cert.ValidPrincipals)
+	}
+	validAfter := time.Unix(int64(cert.ValidAfter), 0)
+	validBefore := time.Unix(int64(cert.ValidBefore), 0)
+	if validAfter.Before(before.Add(-time.Second)) || validAfter.After(time.Now()) {
+		t.Fatalf("bad: %v", validAfter)
+	}
+	if validBefore.Sub(validAfter) != 3*time.Minute {
+		t.Fatalf("bad: %v", validBefore.Sub(validAfter))
+	}
+	if string(cert.Key.Marshal()) != string(mustParsePublicKey(t, publicKey).Marshal()) {
+		t.Fatalf("signed key does not match")
+	}
+
+	checker := &ssh.CertChecker{
+		IsAuthority: func(auth ssh.PublicKey) bool {
+			return string(auth.Marshal()) == string(mustParsePublicKey(t, caPublicKey).Marshal())
+		},
+	}
+	if err := checker.CheckCert("alice", cert); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := checker.CheckCert("bob", cert); err == nil {
+		t.Fatalf("expected error")
+	}
+	checker.Clock = func() time.Time { return validBefore.Add(time.Second) }
+	if err := checker.CheckCert("alice", cert); err == nil {
+		t.Fatalf("expected error")
+	}
+
+	// The role TTL is used by default
+	resp = request(logical.WriteOperation, "sign/web", map[string]interface{}{
+		"public_key": publicKey,
+	})
+	parsed, _, _, _, err = ssh.ParseAuthorizedKey([]byte(resp.Data["signed_key"].(string)))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	cert = parsed.(*ssh.Certificate)
+	if cert.ValidBefore-cert.ValidAfter != uint64((2 * time.Minute).Seconds()) {
+		t.Fatalf("bad: %d", cert.ValidBefore-cert.ValidAfter)
+	}
+	if !reflect.DeepEqual(cert.ValidPrincipals, []string{"ubuntu"}) {
+		t.Fatalf("bad: %#v", cert.ValidPrincipals)
+	}
+
+	// Deleting the CA stops signing
+	request(logical.DeleteOperation, "config/ca", nil)
+	if resp := request(logical.ReadOperation, "public_key", nil); resp != nil {
+		t.Fatalf("bad: %#v", resp)
+	}
+	resp = request(logical.WriteOperation, "sign/web", map[string]interface{}{
+		"public_key": publicKey,
+	})
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected error, got: %#v", resp)
+	}
+}
+
+func mustParsePublicKey(t *testing.T, key string) ssh.PublicKey {
+	parsed, _, _, _, err := ssh.ParseAuthorizedKey([]byte(key))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	return parsed
+}
@@ -0,0 +1,154 @@
+package ssh
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+const caStoragePath = "config/ca"
+
+type sshCA struct {
+	PublicKey  string `json:"public_key"`
+	PrivateKey string `json:"private_key"`
+}
+
+func pathConfigCA(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "config/ca",
+		Fields: map[string]*framework.FieldSchema{
+			"private_key": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `
+				[Optional] Private key of the CA used to sign SSH certificates. If not
+				supplied, Vault generates a new RSA key pair.`,
+			},
+		},
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathConfigCARead,
+			logical.WriteOperation:  b.pathConfigCAWrite,
+			logical.DeleteOperation: b.pathConfigCADelete,
+		},
+		HelpSynopsis:    pathConfigCASyn,
+		HelpDescription: pathConfigCADesc,
+	}
+}
+
+func pathPublicKey(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "public_key",
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathConfigCARead,
+		},
+		HelpSynopsis:    pathPublicKeySyn,
+		HelpDescription: pathPublicKeyDesc,
+	}
+}
+
+func (b *backend) getCA(s logical.Storage) (*sshCA, error) {
+	entry, err := s.Get(caStoragePath)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result sshCA
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (b *backend) pathConfigCARead(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	ca, err := b.getCA(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if ca == nil {
+		return nil, nil
+	}
+
+	// The private key is never returned
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"public_key": ca.PublicKey,
+		},
+	}, nil
+}
+
+func (b *backend) pathConfigCAWrite(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	var ca sshCA
+
+	privateKey := d.Get("private_key").(string)
+	if privateKey == "" {
+		publicKeyRsa, privateKeyRsa, err := generateRSAKeys(2048)
+		if err != nil {
+			return nil, err
+		}
+		ca = sshCA{
+			PublicKey:  publicKeyRsa,
+			PrivateKey: privateKeyRsa,
+		}
+	} else {
+		signer, err := ssh.ParsePrivateKey([]byte(privateKey))
+		if err != nil || signer == nil {
+			return logical.ErrorResponse("Invalid private_key"), nil
+		}
+		ca = sshCA{
+			PublicKey:  strings.TrimSpace(string(ssh.MarshalAuthorizedKey(signer.PublicKey()))),
+			PrivateKey: privateKey,
+		}
+	}
+
+	entry, err := logical.StorageEntryJSON(caStoragePath, ca)
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(entry); err != nil {
+		return nil, fmt.Errorf("error storing CA: %s", err)
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"public_key": ca.PublicKey,
+		},
+	}, nil
+}
+
+func (b *backend) pathConfigCADelete(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	err := req.Storage.Delete(caStoragePath)
+	if err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+const pathConfigCASyn = `
+Configure the CA used to sign SSH public keys.
+`
+
+const pathConfigCADesc = `
+Writing to this endpoint either imports the given 'private_key' or generates
+a new RSA key pair, which is then used by roles of type 'ca' to sign users'
+SSH public keys. Only the public key is ever returned. Hosts should list it
+in the 'TrustedUserCAKeys' file of their SSH server configuration.
+
+If the CA key is compromised, delete it using this endpoint and remove the
+public key from the hosts. Vault stops signing keys until a new CA is
+configured.
+`
+
+const pathPublicKeySyn = `
+Retrieve the public key of the SSH CA.
+`
+
+const pathPublicKeyDesc = `
+This endpoint returns the public key of the CA configured at 'config/ca'. It
+does not require authentication so that hosts can fetch the key to trust it.
+`
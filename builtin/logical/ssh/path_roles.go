@@ -11,6 +11,7 @@ import (
 const (
 	KeyTypeOTP     = "otp"
 	KeyTypeDynamic = "dynamic"
+	KeyTypeCA      = "ca"
 )
 
 // Structure that represents a role in SSH backend. This is a common role structure
@@ -28,6 +29,8 @@ type sshRole struct {
 	InstallScript   string `mapstructure:"install_script" json:"install_script"`
 	AllowedUsers    string `mapstructure:"allowed_users" json:"allowed_users"`
 	KeyOptionSpecs  string `mapstructure:"key_option_specs" json:"key_option_specs"`
+	TTL             string `mapstructure:"ttl" json:"ttl"`
+	MaxTTL          string `mapstructure:"max_ttl" json:"max_ttl"`
 }
 
 func pathRoles(b *backend) *framework.Path {
@@ -93,8 +96,9 @@ func pathRoles(b *backend) *framework.Path {
 				Type: framework.TypeString,
 				Description: `
 				[Required for both types] 
-				Type of key used to login to hosts. It can be either 'otp' or 'dynamic'.
-				'otp' type requires agent to be installed in remote hosts.`,
+				Type of key used to login to hosts. It can be 'otp', 'dynamic' or 'ca'.
+				'otp' type requires agent to be installed in remote hosts. 'ca' type
+				requires the hosts to trust the CA configured at 'config/ca'.`,
 			},
 			"key_bits": &framework.FieldSchema{
 				Type: framework.TypeInt,
@@ -130,6 +134,20 @@ func pathRoles(b *backend) *framework.Path {
 				file format and should not contain spaces.
 				`,
 			},
+			"ttl": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `
+				[Optional for CA type] [Not applicable for OTP and Dynamic types]
+				Default validity period of the signed certificates. Defaults to the
+				backend's default lease TTL, capped to 'max_ttl'.`,
+			},
+			"max_ttl": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `
+				[Optional for CA type] [Not applicable for OTP and Dynamic types]
+				Maximum validity period of the signed certificates. Defaults to the
+				backend's maximum lease TTL.`,
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -260,6 +278,27 @@ func (b *backend) pathRoleWrite(req *logical.Request, d *framework.FieldData) (*
 			AllowedUsers:    allowedUsers,
 			KeyOptionSpecs:  keyOptionSpecs,
 		}
+	} else if keyType == KeyTypeCA {
+		// The signing key is shared by all the roles of CA type and
+		// there is no need to login to remote machine.
+		adminUser := d.Get("admin_user").(string)
+		if adminUser != "" {
+			return logical.ErrorResponse("Admin user not required for CA type"), nil
+		}
+
+		ttl := d.Get("ttl").(string)
+		maxTTL := d.Get("max_ttl").(string)
+		if _, _, err := b.caTTLs(ttl, maxTTL); err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+
+		roleEntry = sshRole{
+			DefaultUser:  defaultUser,
+			KeyType:      KeyTypeCA,
+			AllowedUsers: allowedUsers,
+			TTL:          ttl,
+			MaxTTL:       maxTTL,
+		}
 	} else {
 		return logical.ErrorResponse("Invalid key type"), nil
 	}
@@ -313,6 +352,16 @@ func (b *backend) pathRoleRead(req *logical.Request, d *framework.FieldData) (*l
 				"allowed_users":     role.AllowedUsers,
 			},
 		}, nil
+	} else if role.KeyType == KeyTypeCA {
+		return &logical.Response{
+			Data: map[string]interface{}{
+				"default_user":  role.DefaultUser,
+				"key_type":      role.KeyType,
+				"allowed_users": role.AllowedUsers,
+				"ttl":           role.TTL,
+				"max_ttl":       role.MaxTTL,
+			},
+		}, nil
 	} else {
 		return &logical.Response{
 			Data: map[string]interface{}{
@@ -363,7 +412,9 @@ This path allows you to manage the roles that are used to generate credentials.
 
 Role takes a 'key_type' parameter that decides what type of credential this role
 can generate. If remote hosts have Vault SSH Agent installed, an 'otp' type can
-be used, otherwise 'dynamic' type can be used.
+be used, otherwise 'dynamic' type can be used. If remote hosts trust the CA
+configured at 'config/ca', a 'ca' type can be used to sign users' public keys
+at the 'sign/' endpoint.
 
 If the backend is mounted at "ssh" and the role is created at "ssh/roles/web",
 then a user could request for a credential at "ssh/creds/web" for an IP that
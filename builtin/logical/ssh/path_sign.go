@@ -0,0 +1,203 @@
+package ssh
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func pathSign(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "sign/" + framework.GenericNameRegex("role"),
+		Fields: map[string]*framework.FieldSchema{
+			"role": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "[Required] Name of the role",
+			},
+			"public_key": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "[Required] SSH public key to sign, in authorized_keys format",
+			},
+			"valid_principals": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `
+				[Optional] Comma separated usernames the certificate is valid for.
+				Defaults to the default user of the role.`,
+			},
+			"ttl": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `
+				[Optional] Validity period of the certificate. Defaults to the ttl
+				of the role and cannot exceed its max_ttl.`,
+			},
+		},
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.WriteOperation: b.pathSignWrite,
+		},
+		HelpSynopsis:    pathSignHelpSyn,
+		HelpDescription: pathSignHelpDesc,
+	}
+}
+
+func (b *backend) pathSignWrite(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	roleName := d.Get("role").(string)
+	if roleName == "" {
+		return logical.ErrorResponse("Missing role"), nil
+	}
+
+	role, err := b.getRole(req.Storage, roleName)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving role: %s", err)
+	}
+	if role == nil {
+		return logical.ErrorResponse(fmt.Sprintf("Role '%s' not found", roleName)), nil
+	}
+	if role.KeyType != KeyTypeCA {
+		return logical.ErrorResponse(fmt.Sprintf("Role '%s' is not of type '%s'", roleName, KeyTypeCA)), nil
+	}
+
+	publicKeyRaw := d.Get("public_key").(string)
+	if publicKeyRaw == "" {
+		return logical.ErrorResponse("Missing public_key"), nil
+	}
+	publicKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(publicKeyRaw))
+	if err != nil {
+		return logical.ErrorResponse(fmt.Sprintf("Invalid public_key: %s", err)), nil
+	}
+
+	// Every principal has to be allowed by the role, in the same way
+	// usernames are checked when creating credentials.
+	principals := []string{role.DefaultUser}
+	if principalsRaw := d.Get("valid_principals").(string); principalsRaw != "" {
+		principals = strings.Split(principalsRaw, ",")
+	}
+	for _, principal := range principals {
+		if principal == role.DefaultUser {
+			continue
+		}
+		if role.AllowedUsers == "" || validateUsername(principal, role.AllowedUsers) != nil {
+			return logical.ErrorResponse(fmt.Sprintf("Principal '%s' is not present in allowed users list", principal)), nil
+		}
+	}
+
+	ttl, maxTTL, err := b.caTTLs(role.TTL, role.MaxTTL)
+	if err != nil {
+		return nil, fmt.Errorf("error reading role TTLs: %s", err)
+	}
+	if ttlRaw := d.Get("ttl").(string); ttlRaw != "" {
+		ttl, err = time.ParseDuration(ttlRaw)
+		if err != nil {
+			return logical.ErrorResponse(fmt.Sprintf("Invalid ttl: %s", err)), nil
+		}
+		if ttl > maxTTL {
+			return logical.ErrorResponse("\"ttl\" value must be less than the role's \"max_ttl\" value"), nil
+		}
+	}
+
+	ca, err := b.getCA(req.Storage)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving CA: %s", err)
+	}
+	if ca == nil {
+		return logical.ErrorResponse("CA is not configured. Use 'config/ca' endpoint"), nil
+	}
+	signer, err := ssh.ParsePrivateKey([]byte(ca.PrivateKey))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing CA private key: %s", err)
+	}
+
+	var serialBytes [8]byte
+	if _, err := rand.Read(serialBytes[:]); err != nil {
+		return nil, fmt.Errorf("error generating serial number: %s", err)
+	}
+	serial := binary.BigEndian.Uint64(serialBytes[:])
+
+	now := time.Now()
+	cert := &ssh.Certificate{
+		Key:             publicKey,
+		Serial:          serial,
+		CertType:        ssh.UserCert,
+		KeyId:           fmt.Sprintf("vault-%s-%016x", roleName, serial),
+		ValidPrincipals: principals,
+		ValidAfter:      uint64(now.Unix()),
+		ValidBefore:     uint64(now.Add(ttl).Unix()),
+		Permissions: ssh.Permissions{
+			Extensions: map[string]string{
+				"permit-X11-forwarding":   "",
+				"permit-agent-forwarding": "",
+				"permit-port-forwarding":  "",
+				"permit-pty":              "",
+				"permit-user-rc":          "",
+			},
+		},
+	}
+	if err := cert.SignCert(rand.Reader, signer); err != nil {
+		return nil, fmt.Errorf("error signing public key: %s", err)
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"serial_number": fmt.Sprintf("%016x", serial),
+			"signed_key":    strings.TrimSpace(string(ssh.MarshalAuthorizedKey(cert))),
+		},
+	}, nil
+}
+
+// Returns the default and maximum validity periods of certificates signed
+// by a role of CA type, falling back to the values of the backend.
+func (b *backend) caTTLs(ttlRaw, maxTTLRaw string) (time.Duration, time.Duration, error) {
+	var err error
+
+	maxSystemTTL := b.System().MaxLeaseTTL()
+	maxTTL := maxSystemTTL
+	if maxTTLRaw != "" {
+		maxTTL, err = time.ParseDuration(maxTTLRaw)
+		if err != nil {
+			return 0, 0, fmt.Errorf("Invalid max_ttl: %s", err)
+		}
+	}
+	if maxTTL > maxSystemTTL {
+		return 0, 0, fmt.Errorf("Requested max TTL is higher than backend maximum")
+	}
+
+	ttl := b.System().DefaultLeaseTTL()
+	if ttlRaw != "" {
+		ttl, err = time.ParseDuration(ttlRaw)
+		if err != nil {
+			return 0, 0, fmt.Errorf("Invalid ttl: %s", err)
+		}
+	}
+	if ttl > maxTTL {
+		// If the backend default is used, cap it to the role max;
+		// if it was specified explicitly, make it an error
+		if ttlRaw == "" {
+			ttl = maxTTL
+		} else {
+			return 0, 0, fmt.Errorf("\"ttl\" value must be less than \"max_ttl\" and/or backend default max lease TTL value")
+		}
+	}
+
+	return ttl, maxTTL, nil
+}
+
+const pathSignHelpSyn = `
+Request signing an SSH public key with the CA of the backend.
+`
+
+const pathSignHelpDesc = `
+This path signs the given SSH public key using the CA configured at
+'config/ca', producing a user certificate valid for the requested principals
+and period. The role must be of type 'ca'; the principals must be the role's
+'default_user' or present in its 'allowed_users' list.
+
+If this backend is mounted at 'ssh', then "ssh/sign/web" would sign a key
+using the 'web' role. Hosts accepting the certificate must list the CA's
+public key, available at "ssh/public_key", in their 'TrustedUserCAKeys'.
+`
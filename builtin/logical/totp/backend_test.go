@@ -0,0 +1,125 @@
+package totp
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/helper/mfa/totp"
+	"github.com/hashicorp/vault/logical"
+)
+
+const testKey = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func testBackend(t *testing.T) (logical.Backend, logical.Storage) {
+	b, err := Factory(logical.TestBackendConfig())
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	return b, new(logical.InmemStorage)
+}
+
+func testRequest(t *testing.T, b logical.Backend, s logical.Storage,
+	op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+	req := logical.TestRequest(t, op, path)
+	req.Storage = s
+	if data != nil {
+		req.Data = data
+	}
+	resp, err := b.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v %#v", err, resp)
+	}
+	return resp
+}
+
+func testValidate(t *testing.T, b logical.Backend, s logical.Storage, name, code string) bool {
+	resp := testRequest(t, b, s, logical.WriteOperation, "code/"+name, map[string]interface{}{
+		"code": code,
+	})
+	return resp.Data["valid"].(bool)
+}
+
+func TestBackend_GenerateAndValidate(t *testing.T) {
+	b, s := testBackend(t)
+	resp := testRequest(t, b, s, logical.WriteOperation, "keys/test", map[string]interface{}{
+		"issuer":       "Vault",
+		"account_name": "test@example.com",
+	})
+	if resp == nil || resp.Data["key"] == "" {
+		t.Fatalf("bad: %#v", resp)
+	}
+	if url := resp.Data["url"].(string); !strings.HasPrefix(url, "otpauth://totp/Vault:test@example.com?") {
+		t.Fatalf("bad: %s", url)
+	}
+
+	// The configuration is readable without the secret
+	resp = testRequest(t, b, s, logical.ReadOperation, "keys/test", nil)
+	if _, ok := resp.Data["key"]; ok {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+	if resp.Data["issuer"] != "Vault" || resp.Data["period"] != uint(30) || resp.Data["digits"] != 6 {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	resp = testRequest(t, b, s, logical.ReadOperation, "code/test", nil)
+	code := resp.Data["code"].(string)
+	if len(code) != 6 {
+		t.Fatalf("bad: %s", code)
+	}
+	if !testValidate(t, b, s, "test", code) {
+		t.Fatalf("code should be valid")
+	}
+}
+
+func TestBackend_ValidateSkew(t *testing.T) {
+	b, s := testBackend(t)
+	testRequest(t, b, s, logical.WriteOperation, "keys/test", map[string]interface{}{
+		"key":    testKey,
+		"digits": 8,
+	})
+
+	opts := totp.Opts{Period: 30, Digits: 8}
+	now := time.Now()
+
+	// A code from the adjacent window is accepted
+	code, err := totp.GenerateCodeOpts(testKey, now.Add(-30*time.Second), opts)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !testValidate(t, b, s, "test", code) {
+		t.Fatalf("code from the previous window should be valid")
+	}
+
+	// A code from outside the window is rejected
+	code, err = totp.GenerateCodeOpts(testKey, now.Add(-5*time.Minute), opts)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if testValidate(t, b, s, "test", code) {
+		t.Fatalf("code from outside the window should be invalid")
+	}
+}
+
+func TestBackend_InvalidKey(t *testing.T) {
+	b, s := testBackend(t)
+	for _, data := range []map[string]interface{}{
+		{"key": "not base32!"},
+		{"digits": 7},
+		{"period": 0},
+		{"skew": -1},
+	} {
+		req := logical.TestRequest(t, logical.WriteOperation, "keys/test")
+		req.Storage = s
+		req.Data = data
+		if _, err := b.HandleRequest(req); err != logical.ErrInvalidRequest {
+			t.Fatalf("err: %v %#v", err, data)
+		}
+	}
+
+	req := logical.TestRequest(t, logical.ReadOperation, "code/missing")
+	req.Storage = s
+	if _, err := b.HandleRequest(req); err != logical.ErrInvalidRequest {
+		t.Fatalf("err: %v", err)
+	}
+}
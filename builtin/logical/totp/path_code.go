@@ -0,0 +1,97 @@
+package totp
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/helper/mfa/totp"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func pathCode(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "code/" + framework.GenericNameRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the key",
+			},
+
+			"code": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Code to validate",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:  b.pathCodeRead,
+			logical.WriteOperation: b.pathCodeWrite,
+		},
+
+		HelpSynopsis:    pathCodeHelpSyn,
+		HelpDescription: pathCodeHelpDesc,
+	}
+}
+
+func (b *backend) pathCodeRead(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+	key, err := b.key(req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return logical.ErrorResponse(
+				fmt.Sprintf("no existing key named %s could be found", name)),
+			logical.ErrInvalidRequest
+	}
+
+	code, err := totp.GenerateCodeOpts(key.Key, time.Now(), key.opts())
+	if err != nil {
+		return nil, err
+	}
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"code": code,
+		},
+	}, nil
+}
+
+func (b *backend) pathCodeWrite(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+	code := d.Get("code").(string)
+	if code == "" {
+		return logical.ErrorResponse("missing code"), logical.ErrInvalidRequest
+	}
+
+	key, err := b.key(req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return logical.ErrorResponse(
+				fmt.Sprintf("no existing key named %s could be found", name)),
+			logical.ErrInvalidRequest
+	}
+
+	valid, err := totp.ValidateOpts(code, key.Key, time.Now(), key.opts())
+	if err != nil {
+		return nil, err
+	}
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"valid": valid,
+		},
+	}, nil
+}
+
+const pathCodeHelpSyn = `Generate and validate TOTP codes`
+
+const pathCodeHelpDesc = `
+Reading this path generates the current code of the named key. Writing
+a code to this path validates it against the named key, accepting
+codes from the configured number of adjacent periods to allow for
+clock drift.
+`
@@ -0,0 +1,225 @@
+package totp
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/hashicorp/vault/helper/mfa/totp"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func pathKeys(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "keys/" + framework.GenericNameRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the key",
+			},
+
+			"key": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Base32 encoded shared secret. If not given,
+a random secret is generated and returned.`,
+			},
+
+			"issuer": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the issuing organization",
+			},
+
+			"account_name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the account the key is for",
+			},
+
+			"period": &framework.FieldSchema{
+				Type:        framework.TypeInt,
+				Default:     totp.Period,
+				Description: "Number of seconds a code is valid for. Defaults to 30.",
+			},
+
+			"digits": &framework.FieldSchema{
+				Type:        framework.TypeInt,
+				Default:     totp.Digits,
+				Description: "Number of digits in a code, 6 or 8. Defaults to 6.",
+			},
+
+			"skew": &framework.FieldSchema{
+				Type:    framework.TypeInt,
+				Default: totp.Skew,
+				Description: `Number of periods before and after the current
+one for which a code is still accepted. Defaults to 1.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathKeyRead,
+			logical.WriteOperation:  b.pathKeyWrite,
+			logical.DeleteOperation: b.pathKeyDelete,
+		},
+
+		HelpSynopsis:    pathKeysHelpSyn,
+		HelpDescription: pathKeysHelpDesc,
+	}
+}
+
+func (b *backend) key(s logical.Storage, n string) (*keyEntry, error) {
+	entry, err := s.Get("key/" + n)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result keyEntry
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (b *backend) pathKeyRead(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	key, err := b.key(req.Storage, d.Get("name").(string))
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, nil
+	}
+
+	// The shared secret is never returned after creation
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"issuer":       key.Issuer,
+			"account_name": key.AccountName,
+			"period":       key.Period,
+			"digits":       key.Digits,
+			"skew":         key.Skew,
+		},
+	}, nil
+}
+
+func (b *backend) pathKeyWrite(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+	period := d.Get("period").(int)
+	digits := d.Get("digits").(int)
+	skew := d.Get("skew").(int)
+	if period <= 0 {
+		return logical.ErrorResponse("period must be positive"), logical.ErrInvalidRequest
+	}
+	if skew < 0 {
+		return logical.ErrorResponse("skew cannot be negative"), logical.ErrInvalidRequest
+	}
+
+	key := &keyEntry{
+		Key:         d.Get("key").(string),
+		Issuer:      d.Get("issuer").(string),
+		AccountName: d.Get("account_name").(string),
+		Period:      uint(period),
+		Digits:      digits,
+		Skew:        uint(skew),
+	}
+	if err := key.opts().Validate(); err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+
+	// Generate the shared secret if necessary, and verify it otherwise
+	generated := key.Key == ""
+	if generated {
+		secret, err := totp.GenerateSecret()
+		if err != nil {
+			return nil, err
+		}
+		key.Key = secret
+	} else if _, err := totp.GenerateCode(key.Key, time.Now()); err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+
+	entry, err := logical.StorageEntryJSON("key/"+name, key)
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(entry); err != nil {
+		return nil, err
+	}
+
+	if !generated {
+		return nil, nil
+	}
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"key": key.Key,
+			"url": key.url(name),
+		},
+	}, nil
+}
+
+func (b *backend) pathKeyDelete(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	if err := req.Storage.Delete("key/" + d.Get("name").(string)); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+type keyEntry struct {
+	Key         string `json:"key"`
+	Issuer      string `json:"issuer"`
+	AccountName string `json:"account_name"`
+	Period      uint   `json:"period"`
+	Digits      int    `json:"digits"`
+	Skew        uint   `json:"skew"`
+}
+
+func (k *keyEntry) opts() totp.Opts {
+	return totp.Opts{
+		Period: k.Period,
+		Digits: k.Digits,
+		Skew:   k.Skew,
+	}
+}
+
+// url returns the otpauth URL used to import the key into an
+// authenticator application
+func (k *keyEntry) url(name string) string {
+	label := name
+	if k.AccountName != "" {
+		label = k.AccountName
+	}
+	if k.Issuer != "" {
+		label = k.Issuer + ":" + label
+	}
+
+	v := url.Values{}
+	v.Set("secret", k.Key)
+	if k.Issuer != "" {
+		v.Set("issuer", k.Issuer)
+	}
+	v.Set("period", fmt.Sprintf("%d", k.Period))
+	v.Set("digits", fmt.Sprintf("%d", k.Digits))
+	u := url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + label,
+		RawQuery: v.Encode(),
+	}
+	return u.String()
+}
+
+const pathKeysHelpSyn = `Manage the TOTP keys`
+
+const pathKeysHelpDesc = `
+This path is used to manage the named keys codes are generated and
+validated with. A key is configured with the shared secret, the issuer
+and account it is for, and the period and number of digits of its
+codes. If no shared secret is given, one is generated and returned
+together with an otpauth URL for authenticator applications. The
+shared secret cannot be read back afterwards.
+`
@@ -46,9 +46,26 @@ func (c *InitCommand) Run(args []string) int {
 	for i, key := range resp.Keys {
 		c.Ui.Output(fmt.Sprintf("Key %d: %s", i+1, key))
 	}
+	for i, key := range resp.RecoveryKeys {
+		c.Ui.Output(fmt.Sprintf("Recovery Key %d: %s", i+1, key))
+	}
 
 	c.Ui.Output(fmt.Sprintf("Initial Root Token: %s", resp.RootToken))
 
+	if len(resp.RecoveryKeys) > 0 {
+		c.Ui.Output(fmt.Sprintf(
+			"\n"+
+				"Vault initialized with %d recovery keys and a key threshold of %d.\n"+
+				"The master key is stored by the seal, so the Vault unseals without\n"+
+				"keys. Please securely distribute the above recovery keys: at least\n"+
+				"%d of them are required to rekey the Vault.",
+			shares,
+			threshold,
+			threshold,
+		))
+		return 0
+	}
+
 	c.Ui.Output(fmt.Sprintf(
 		"\n"+
 			"Vault initialized with %d keys and a key threshold of %d. Please\n"+
@@ -3,6 +3,8 @@ package command
 import (
 	"encoding/hex"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
 	"net"
 	"net/http"
@@ -103,6 +105,10 @@ func (c *ServerCommand) Run(args []string) int {
 		Writer:   logGate,
 	}, "", log.LstdFlags)
 
+	// Requests are logged unfiltered, since each mount may lower its own
+	// log level below the global level
+	requestLogger := log.New(logGate, "", log.LstdFlags)
+
 	// Initialize the backend
 	backend, err := physical.NewBackend(
 		config.Backend.Type, config.Backend.Config)
@@ -125,18 +131,100 @@ func (c *ServerCommand) Run(args []string) int {
 		}
 	}
 
+	// Initialize the telemetry
+	inm, err := c.setupTelementry(config)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error initializing telemetry: %s", err))
+		return 1
+	}
+
+	// Parse the disabled operations
+	disabledOps := make([]logical.Operation, 0, len(config.DisabledOperations))
+	for _, op := range config.DisabledOperations {
+		disabledOps = append(disabledOps, logical.Operation(op))
+	}
+
+	// Open the entropy source, such as a hardware RNG device
+	var entropySource io.Reader
+	if config.EntropySource != "" {
+		f, err := os.Open(config.EntropySource)
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf("Error opening entropy source: %s", err))
+			return 1
+		}
+		defer f.Close()
+
+		// A regular file yields the same bytes on every start, so only
+		// devices are accepted
+		fi, err := f.Stat()
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf("Error opening entropy source: %s", err))
+			return 1
+		}
+		if fi.Mode()&os.ModeCharDevice == 0 {
+			c.Ui.Error(fmt.Sprintf(
+				"Entropy source %s is not a character device", config.EntropySource))
+			return 1
+		}
+		entropySource = f
+	}
+
+	// Read the key encrypting the persisted unseal progress
+	var unsealProgressKey []byte
+	if config.UnsealProgressKeyFile != "" {
+		raw, err := ioutil.ReadFile(config.UnsealProgressKeyFile)
+		if err == nil {
+			unsealProgressKey, err = hex.DecodeString(strings.TrimSpace(string(raw)))
+		}
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf("Error reading unseal progress key: %s", err))
+			return 1
+		}
+	}
+
 	// Initialize the core
 	core, err := vault.NewCore(&vault.CoreConfig{
-		AdvertiseAddr:      config.Backend.AdvertiseAddr,
-		Physical:           backend,
-		AuditBackends:      c.AuditBackends,
-		CredentialBackends: c.CredentialBackends,
-		LogicalBackends:    c.LogicalBackends,
-		Logger:             logger,
-		DisableCache:       config.DisableCache,
-		DisableMlock:       config.DisableMlock,
-		MaxLeaseTTL:        config.MaxLeaseTTL,
-		DefaultLeaseTTL:    config.DefaultLeaseTTL,
+		AdvertiseAddr:       config.Backend.AdvertiseAddr,
+		Physical:            backend,
+		AuditBackends:       c.AuditBackends,
+		CredentialBackends:  c.CredentialBackends,
+		LogicalBackends:     c.LogicalBackends,
+		Logger:              logger,
+		DisableCache:        config.DisableCache,
+		DisableMlock:        config.DisableMlock,
+		MaxLeaseTTL:         config.MaxLeaseTTL,
+		DefaultLeaseTTL:     config.DefaultLeaseTTL,
+		BarrierAlgorithm:    config.BarrierAlgorithm,
+		RequiredSealType:    config.RequiredSealType,
+		MaxRequestsPerToken: config.MaxRequestsPerToken,
+		MaxPoliciesPerToken: config.MaxPoliciesPerToken,
+		MaxTokenDepth:       config.MaxTokenDepth,
+		MetricsSink:         inm,
+		BackendInitTimeout:  config.BackendInitTimeout,
+		UnsealFailureDelay:  config.UnsealFailureDelay,
+		MountRetention:      config.MountRetention,
+		TokenIDLength:       config.TokenIDLength,
+		MaxAuthTableSize:    config.MaxAuthTableSize,
+		MaxUnsealShares:     config.MaxUnsealShares,
+		GlobalRateLimit:     config.GlobalRateLimit,
+		GlobalRateBurst:     config.GlobalRateBurst,
+
+		StorageFailureThreshold: config.StorageFailureThreshold,
+		StorageFailureAction:    config.StorageFailureAction,
+		RotateDuringRewrap:      config.RotateDuringRewrap,
+		EntropySource:           entropySource,
+		EntropyReseedInterval:   config.EntropyReseedInterval,
+		UnsealProgressKey:       unsealProgressKey,
+		IdleSealPeriod:          config.IdleSealPeriod,
+		IdleSealHA:              config.IdleSealHA,
+		DisabledOperations:      disabledOps,
+		RevokeLeasesOnSeal:      config.RevokeLeasesOnSeal,
+		StandbyOnLeadershipLoss: config.StandbyOnLeadershipLoss,
+		MaxLeaseClockSkew:       config.MaxLeaseClockSkew,
+		DisableAuditReads:       config.DisableAuditReads,
+		DisableDenyOverrides:    config.DisableDenyOverrides,
+		LogLevel:                logLevel,
+		RequestLogger:           requestLogger,
 	})
 	if err != nil {
 		c.Ui.Error(fmt.Sprintf("Error initializing core: %s", err))
@@ -171,7 +259,7 @@ func (c *ServerCommand) Run(args []string) int {
 				"The unseal key and root token are reproduced below in case you\n"+
 				"want to seal/unseal the Vault or play with authentication.\n\n"+
 				"Unseal Key: %s\nRoot Token: %s\n",
-			hex.EncodeToString(init.SecretShares[0]),
+			hex.EncodeToString(init.UnsealShares[0]),
 			init.RootToken,
 		))
 	}
@@ -193,12 +281,6 @@ func (c *ServerCommand) Run(args []string) int {
 		infoKeys = append(infoKeys, "advertise address")
 	}
 
-	// Initialize the telemetry
-	if err := c.setupTelementry(config); err != nil {
-		c.Ui.Error(fmt.Sprintf("Error initializing telemetry: %s", err))
-		return 1
-	}
-
 	// Initialize the listeners
 	lns := make([]net.Listener, 0, len(config.Listeners))
 	for i, lnConfig := range config.Listeners {
@@ -275,8 +357,8 @@ func (c *ServerCommand) enableDev(core *vault.Core) (*vault.InitResult, error) {
 	}
 
 	// Copy the key so that it can be zeroed
-	key := make([]byte, len(init.SecretShares[0]))
-	copy(key, init.SecretShares[0])
+	key := make([]byte, len(init.UnsealShares[0]))
+	copy(key, init.UnsealShares[0])
 
 	// Unseal the core
 	unsealed, err := core.Unseal(key)
@@ -364,8 +446,9 @@ func (c *ServerCommand) detectAdvertise(detect physical.AdvertiseDetect,
 	return url.String(), nil
 }
 
-// setupTelementry is used ot setup the telemetry sub-systems
-func (c *ServerCommand) setupTelementry(config *server.Config) error {
+// setupTelementry is used ot setup the telemetry sub-systems. The
+// in-memory sink is returned so the core can snapshot the metrics.
+func (c *ServerCommand) setupTelementry(config *server.Config) (*metrics.InmemSink, error) {
 	/* Setup telemetry
 	Aggregate on 10 second intervals for 1 minute. Expose the
 	metrics over stderr when there is a SIGUSR1 received.
@@ -388,7 +471,7 @@ func (c *ServerCommand) setupTelementry(config *server.Config) error {
 	if telConfig.StatsiteAddr != "" {
 		sink, err := metrics.NewStatsiteSink(telConfig.StatsiteAddr)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		fanout = append(fanout, sink)
 	}
@@ -397,7 +480,7 @@ func (c *ServerCommand) setupTelementry(config *server.Config) error {
 	if telConfig.StatsdAddr != "" {
 		sink, err := metrics.NewStatsdSink(telConfig.StatsdAddr)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		fanout = append(fanout, sink)
 	}
@@ -410,7 +493,7 @@ func (c *ServerCommand) setupTelementry(config *server.Config) error {
 		metricsConf.EnableHostname = false
 		metrics.NewGlobal(metricsConf, inm)
 	}
-	return nil
+	return inm, nil
 }
 
 func (c *ServerCommand) Synopsis() string {
@@ -21,12 +21,61 @@ type Config struct {
 	DisableCache bool `hcl:"disable_cache"`
 	DisableMlock bool `hcl:"disable_mlock"`
 
+	RevokeLeasesOnSeal bool `hcl:"revoke_leases_on_seal"`
+
+	StandbyOnLeadershipLoss bool `hcl:"standby_on_leadership_loss"`
+	DisableAuditReads       bool `hcl:"disable_audit_reads"`
+	DisableDenyOverrides    bool `hcl:"disable_deny_overrides"`
+
+	BarrierAlgorithm string `hcl:"barrier_algorithm"`
+	RequiredSealType string `hcl:"required_seal_type"`
+
+	MaxRequestsPerToken int `hcl:"max_requests_per_token"`
+	MaxPoliciesPerToken int `hcl:"max_policies_per_token"`
+	MaxTokenDepth       int `hcl:"max_token_depth"`
+	TokenIDLength       int `hcl:"token_id_length"`
+	MaxAuthTableSize    int `hcl:"max_auth_table_size"`
+	MaxUnsealShares     int `hcl:"max_unseal_shares"`
+	GlobalRateLimit     int `hcl:"global_rate_limit"`
+	GlobalRateBurst     int `hcl:"global_rate_burst"`
+
+	StorageFailureThreshold int    `hcl:"storage_failure_threshold"`
+	StorageFailureAction    string `hcl:"storage_failure_action"`
+
+	RotateDuringRewrap string `hcl:"rotate_during_rewrap"`
+
+	EntropySource string `hcl:"entropy_source"`
+
+	UnsealProgressKeyFile string `hcl:"unseal_progress_key_file"`
+
+	IdleSealHA bool `hcl:"idle_seal_ha"`
+
+	DisabledOperations []string `hcl:"disabled_operations"`
+
 	Telemetry *Telemetry `hcl:"telemetry"`
 
 	MaxLeaseTTL        time.Duration `hcl:"-"`
 	MaxLeaseTTLRaw     string        `hcl:"max_lease_ttl"`
 	DefaultLeaseTTL    time.Duration `hcl:"-"`
 	DefaultLeaseTTLRaw string        `hcl:"default_lease_ttl"`
+
+	BackendInitTimeout    time.Duration `hcl:"-"`
+	BackendInitTimeoutRaw string        `hcl:"backend_init_timeout"`
+
+	UnsealFailureDelay    time.Duration `hcl:"-"`
+	UnsealFailureDelayRaw string        `hcl:"unseal_failure_delay"`
+
+	MountRetention    time.Duration `hcl:"-"`
+	MountRetentionRaw string        `hcl:"mount_retention"`
+
+	EntropyReseedInterval    time.Duration `hcl:"-"`
+	EntropyReseedIntervalRaw string        `hcl:"entropy_reseed_interval"`
+
+	IdleSealPeriod    time.Duration `hcl:"-"`
+	IdleSealPeriodRaw string        `hcl:"idle_seal_period"`
+
+	MaxLeaseClockSkew    time.Duration `hcl:"-"`
+	MaxLeaseClockSkewRaw string        `hcl:"max_lease_clock_skew"`
 }
 
 // DevConfig is a Config that is used for dev mode of Vault.
@@ -119,7 +168,113 @@ func (c *Config) Merge(c2 *Config) *Config {
 		result.DisableMlock = c2.DisableMlock
 	}
 
+	result.RevokeLeasesOnSeal = c.RevokeLeasesOnSeal
+	if c2.RevokeLeasesOnSeal {
+		result.RevokeLeasesOnSeal = c2.RevokeLeasesOnSeal
+	}
+
+	result.StandbyOnLeadershipLoss = c.StandbyOnLeadershipLoss
+	if c2.StandbyOnLeadershipLoss {
+		result.StandbyOnLeadershipLoss = c2.StandbyOnLeadershipLoss
+	}
+
+	result.DisableAuditReads = c.DisableAuditReads
+	if c2.DisableAuditReads {
+		result.DisableAuditReads = c2.DisableAuditReads
+	}
+
+	result.DisableDenyOverrides = c.DisableDenyOverrides
+	if c2.DisableDenyOverrides {
+		result.DisableDenyOverrides = c2.DisableDenyOverrides
+	}
+
+	result.BarrierAlgorithm = c.BarrierAlgorithm
+	if c2.BarrierAlgorithm != "" {
+		result.BarrierAlgorithm = c2.BarrierAlgorithm
+	}
+
+	result.RequiredSealType = c.RequiredSealType
+	if c2.RequiredSealType != "" {
+		result.RequiredSealType = c2.RequiredSealType
+	}
+
+	result.StorageFailureAction = c.StorageFailureAction
+	if c2.StorageFailureAction != "" {
+		result.StorageFailureAction = c2.StorageFailureAction
+	}
+
+	result.RotateDuringRewrap = c.RotateDuringRewrap
+	if c2.RotateDuringRewrap != "" {
+		result.RotateDuringRewrap = c2.RotateDuringRewrap
+	}
+
+	result.EntropySource = c.EntropySource
+	if c2.EntropySource != "" {
+		result.EntropySource = c2.EntropySource
+	}
+
+	result.UnsealProgressKeyFile = c.UnsealProgressKeyFile
+	if c2.UnsealProgressKeyFile != "" {
+		result.UnsealProgressKeyFile = c2.UnsealProgressKeyFile
+	}
+
+	// disabled operations are merged as a union
+	seen := make(map[string]bool)
+	for _, ops := range [][]string{c.DisabledOperations, c2.DisabledOperations} {
+		for _, op := range ops {
+			if !seen[op] {
+				seen[op] = true
+				result.DisabledOperations = append(result.DisabledOperations, op)
+			}
+		}
+	}
+
 	// merge these integers via a MAX operation
+	result.MaxRequestsPerToken = c.MaxRequestsPerToken
+	if c2.MaxRequestsPerToken > result.MaxRequestsPerToken {
+		result.MaxRequestsPerToken = c2.MaxRequestsPerToken
+	}
+
+	result.MaxPoliciesPerToken = c.MaxPoliciesPerToken
+	if c2.MaxPoliciesPerToken > result.MaxPoliciesPerToken {
+		result.MaxPoliciesPerToken = c2.MaxPoliciesPerToken
+	}
+
+	result.MaxTokenDepth = c.MaxTokenDepth
+	if c2.MaxTokenDepth > result.MaxTokenDepth {
+		result.MaxTokenDepth = c2.MaxTokenDepth
+	}
+
+	result.TokenIDLength = c.TokenIDLength
+	if c2.TokenIDLength > result.TokenIDLength {
+		result.TokenIDLength = c2.TokenIDLength
+	}
+
+	result.MaxAuthTableSize = c.MaxAuthTableSize
+	if c2.MaxAuthTableSize > result.MaxAuthTableSize {
+		result.MaxAuthTableSize = c2.MaxAuthTableSize
+	}
+
+	result.MaxUnsealShares = c.MaxUnsealShares
+	if c2.MaxUnsealShares > result.MaxUnsealShares {
+		result.MaxUnsealShares = c2.MaxUnsealShares
+	}
+
+	result.GlobalRateLimit = c.GlobalRateLimit
+	if c2.GlobalRateLimit > result.GlobalRateLimit {
+		result.GlobalRateLimit = c2.GlobalRateLimit
+	}
+
+	result.GlobalRateBurst = c.GlobalRateBurst
+	if c2.GlobalRateBurst > result.GlobalRateBurst {
+		result.GlobalRateBurst = c2.GlobalRateBurst
+	}
+
+	result.StorageFailureThreshold = c.StorageFailureThreshold
+	if c2.StorageFailureThreshold > result.StorageFailureThreshold {
+		result.StorageFailureThreshold = c2.StorageFailureThreshold
+	}
+
 	result.MaxLeaseTTL = c.MaxLeaseTTL
 	if c2.MaxLeaseTTL > result.MaxLeaseTTL {
 		result.MaxLeaseTTL = c2.MaxLeaseTTL
@@ -130,6 +285,41 @@ func (c *Config) Merge(c2 *Config) *Config {
 		result.DefaultLeaseTTL = c2.DefaultLeaseTTL
 	}
 
+	result.BackendInitTimeout = c.BackendInitTimeout
+	if c2.BackendInitTimeout > result.BackendInitTimeout {
+		result.BackendInitTimeout = c2.BackendInitTimeout
+	}
+
+	result.UnsealFailureDelay = c.UnsealFailureDelay
+	if c2.UnsealFailureDelay > result.UnsealFailureDelay {
+		result.UnsealFailureDelay = c2.UnsealFailureDelay
+	}
+
+	result.MountRetention = c.MountRetention
+	if c2.MountRetention > result.MountRetention {
+		result.MountRetention = c2.MountRetention
+	}
+
+	result.EntropyReseedInterval = c.EntropyReseedInterval
+	if c2.EntropyReseedInterval > result.EntropyReseedInterval {
+		result.EntropyReseedInterval = c2.EntropyReseedInterval
+	}
+
+	result.IdleSealPeriod = c.IdleSealPeriod
+	if c2.IdleSealPeriod > result.IdleSealPeriod {
+		result.IdleSealPeriod = c2.IdleSealPeriod
+	}
+
+	result.IdleSealHA = c.IdleSealHA
+	if c2.IdleSealHA {
+		result.IdleSealHA = c2.IdleSealHA
+	}
+
+	result.MaxLeaseClockSkew = c.MaxLeaseClockSkew
+	if c2.MaxLeaseClockSkew > result.MaxLeaseClockSkew {
+		result.MaxLeaseClockSkew = c2.MaxLeaseClockSkew
+	}
+
 	return result
 }
 
@@ -178,6 +368,36 @@ func LoadConfigFile(path string) (*Config, error) {
 			return nil, err
 		}
 	}
+	if result.BackendInitTimeoutRaw != "" {
+		if result.BackendInitTimeout, err = time.ParseDuration(result.BackendInitTimeoutRaw); err != nil {
+			return nil, err
+		}
+	}
+	if result.UnsealFailureDelayRaw != "" {
+		if result.UnsealFailureDelay, err = time.ParseDuration(result.UnsealFailureDelayRaw); err != nil {
+			return nil, err
+		}
+	}
+	if result.MountRetentionRaw != "" {
+		if result.MountRetention, err = time.ParseDuration(result.MountRetentionRaw); err != nil {
+			return nil, err
+		}
+	}
+	if result.EntropyReseedIntervalRaw != "" {
+		if result.EntropyReseedInterval, err = time.ParseDuration(result.EntropyReseedIntervalRaw); err != nil {
+			return nil, err
+		}
+	}
+	if result.IdleSealPeriodRaw != "" {
+		if result.IdleSealPeriod, err = time.ParseDuration(result.IdleSealPeriodRaw); err != nil {
+			return nil, err
+		}
+	}
+	if result.MaxLeaseClockSkewRaw != "" {
+		if result.MaxLeaseClockSkew, err = time.ParseDuration(result.MaxLeaseClockSkewRaw); err != nil {
+			return nil, err
+		}
+	}
 
 	if objs := obj.Get("listener", false); objs != nil {
 		result.Listeners, err = loadListeners(objs)
@@ -2,6 +2,10 @@ package certutil
 
 import (
 	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
 	"encoding/json"
 	"fmt"
 	"strings"
@@ -342,3 +346,46 @@ S/fXIHIOldeQxgYCqhdVmcDWJ1PtVaDfBsKVpRg1GRU8LUGw2E4AY+twd+J2FBfa
 G/7g4koczXLoUM3OQXd5Aq2cs4SS1vODrYmgbioFsQ3eDHd1fg==
 -----END CERTIFICATE-----`
 )
+
+func TestComparePublicKeys(t *testing.T) {
+	rsaKey1, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	rsaKey2, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	ecKey1, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	ecKey2, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	cases := []struct {
+		Key1, Key2 interface{}
+		Equal      bool
+	}{
+		{rsaKey1.Public(), rsaKey1.Public(), true},
+		{rsaKey1.Public(), rsaKey2.Public(), false},
+		{ecKey1.Public(), ecKey1.Public(), true},
+		{ecKey1.Public(), ecKey2.Public(), false},
+		{rsaKey1.Public(), ecKey1.Public(), false},
+	}
+	for _, tc := range cases {
+		equal, err := ComparePublicKeys(tc.Key1, tc.Key2)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if equal != tc.Equal {
+			t.Fatalf("bad: %#v", tc)
+		}
+	}
+
+	if _, err := ComparePublicKeys("foo", rsaKey1.Public()); err == nil {
+		t.Fatalf("expected error")
+	}
+}
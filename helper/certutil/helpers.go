@@ -3,6 +3,8 @@ package certutil
 import (
 	"bytes"
 	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
 	"crypto/sha1"
 	"crypto/x509"
 	"encoding/json"
@@ -178,3 +180,27 @@ func ParsePEMBundle(pemBundle string) (*ParsedCertBundle, error) {
 
 	return parsedBundle, nil
 }
+
+// ComparePublicKeys compares two public keys and returns true if they match
+func ComparePublicKeys(key1Iface, key2Iface crypto.PublicKey) (bool, error) {
+	switch key1Iface.(type) {
+	case *rsa.PublicKey:
+		key1 := key1Iface.(*rsa.PublicKey)
+		key2, ok := key2Iface.(*rsa.PublicKey)
+		if !ok {
+			return false, nil
+		}
+		return key1.N.Cmp(key2.N) == 0 && key1.E == key2.E, nil
+
+	case *ecdsa.PublicKey:
+		key1 := key1Iface.(*ecdsa.PublicKey)
+		key2, ok := key2Iface.(*ecdsa.PublicKey)
+		if !ok {
+			return false, nil
+		}
+		return key1.Curve == key2.Curve && key1.X.Cmp(key2.X) == 0 && key1.Y.Cmp(key2.Y) == 0, nil
+
+	default:
+		return false, fmt.Errorf("Cannot compare key with type %T", key1Iface)
+	}
+}
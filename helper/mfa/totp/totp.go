@@ -0,0 +1,165 @@
+// Package totp implements time-based one-time passwords as described in
+// RFC 6238, compatible with the common authenticator applications. It is
+// used as the "totp" MFA method required by ACL policies.
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// Period is the default number of seconds a passcode is valid for
+	Period = 30
+
+	// Digits is the default number of digits in a passcode
+	Digits = 6
+
+	// Skew is the default number of periods before and after the
+	// current one for which a passcode is still accepted, to allow for
+	// clock drift
+	Skew = 1
+
+	// secretSize is the size in bytes of generated secrets
+	secretSize = 20
+)
+
+// Opts are the parameters used to generate and validate passcodes
+type Opts struct {
+	Period uint
+	Digits int
+	Skew   uint
+}
+
+// DefaultOpts are the parameters used by the common authenticator
+// applications
+var DefaultOpts = Opts{
+	Period: Period,
+	Digits: Digits,
+	Skew:   Skew,
+}
+
+// Validate checks the parameters are supported
+func (o Opts) Validate() error {
+	if o.Period == 0 {
+		return fmt.Errorf("period must be positive")
+	}
+	if o.Digits != 6 && o.Digits != 8 {
+		return fmt.Errorf("digits must be 6 or 8")
+	}
+	return nil
+}
+
+// GenerateSecret returns a new random secret, base32 encoded without
+// padding so it can be entered into an authenticator application.
+func GenerateSecret() (string, error) {
+	buf := make([]byte, secretSize)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate secret: %v", err)
+	}
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(buf), nil
+}
+
+// GenerateCode returns the passcode for the given secret at time t,
+// using the default parameters.
+func GenerateCode(secret string, t time.Time) (string, error) {
+	return GenerateCodeOpts(secret, t, DefaultOpts)
+}
+
+// GenerateCodeOpts returns the passcode for the given secret at time t.
+func GenerateCodeOpts(secret string, t time.Time, opts Opts) (string, error) {
+	if err := opts.Validate(); err != nil {
+		return "", err
+	}
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	return code(key, uint64(t.Unix())/uint64(opts.Period), opts.Digits), nil
+}
+
+// Validate checks if the passcode is valid for the given secret at
+// time t, using the default parameters.
+func Validate(passcode, secret string, t time.Time) (bool, error) {
+	return ValidateOpts(passcode, secret, t, DefaultOpts)
+}
+
+// ValidateOpts checks if the passcode is valid for the given secret at
+// time t.
+func ValidateOpts(passcode, secret string, t time.Time, opts Opts) (bool, error) {
+	_, ok, err := ValidateCounterOpts(passcode, secret, t, opts)
+	return ok, err
+}
+
+// ValidateCounter checks if the passcode is valid for the given secret
+// at time t, using the default parameters. It also returns the time
+// step the passcode was generated for, so that callers can reject a
+// passcode that was already used.
+func ValidateCounter(passcode, secret string, t time.Time) (uint64, bool, error) {
+	return ValidateCounterOpts(passcode, secret, t, DefaultOpts)
+}
+
+// ValidateCounterOpts checks if the passcode is valid for the given
+// secret at time t, returning the time step it was generated for.
+func ValidateCounterOpts(passcode, secret string, t time.Time, opts Opts) (uint64, bool, error) {
+	if err := opts.Validate(); err != nil {
+		return 0, false, err
+	}
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return 0, false, err
+	}
+	passcode = strings.TrimSpace(passcode)
+	if len(passcode) != opts.Digits {
+		return 0, false, nil
+	}
+
+	counter := uint64(t.Unix()) / uint64(opts.Period)
+	first := uint64(0)
+	if skew := uint64(opts.Skew); counter > skew {
+		first = counter - skew
+	}
+	for c := first; c <= counter+uint64(opts.Skew); c++ {
+		expected := code(key, c, opts.Digits)
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(passcode)) == 1 {
+			return c, true, nil
+		}
+	}
+	return 0, false, nil
+}
+
+// decodeSecret decodes a base32 secret, with or without padding
+func decodeSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.TrimRight(strings.TrimSpace(secret), "="))
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		return nil, fmt.Errorf("invalid secret: %v", err)
+	}
+	return key, nil
+}
+
+// code computes the HOTP value of RFC 4226 for the given counter
+func code(key []byte, counter uint64, digits int) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	// Dynamic truncation
+	offset := sum[len(sum)-1] & 0xf
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", digits, value%mod)
+}
@@ -0,0 +1,115 @@
+package totp
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+)
+
+func TestGenerateCode_RFC6238(t *testing.T) {
+	// Test vectors from RFC 6238 appendix B, truncated to 6 digits
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+	cases := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	}
+	for ts, expected := range cases {
+		actual, err := GenerateCode(secret, time.Unix(ts, 0))
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if actual != expected {
+			t.Fatalf("bad: %d %s %s", ts, actual, expected)
+		}
+	}
+}
+
+func TestGenerateCodeOpts_Digits(t *testing.T) {
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+	opts := Opts{Period: 30, Digits: 8}
+	actual, err := GenerateCodeOpts(secret, time.Unix(59, 0), opts)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if actual != "94287082" {
+		t.Fatalf("bad: %s", actual)
+	}
+
+	opts.Digits = 7
+	if _, err := GenerateCodeOpts(secret, time.Unix(59, 0), opts); err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	now := time.Now()
+	passcode, err := GenerateCode(secret, now)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Accepted within the allowed skew
+	for _, at := range []time.Time{now, now.Add(-Period * time.Second), now.Add(Period * time.Second)} {
+		ok, err := Validate(passcode, secret, at)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if !ok {
+			t.Fatalf("passcode should be valid at %s", at)
+		}
+	}
+
+	// Rejected outside of it
+	ok, err := Validate(passcode, secret, now.Add(5*Period*time.Second))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if ok {
+		t.Fatalf("passcode should be expired")
+	}
+
+	for _, bad := range []string{"", "12345", "abcdef"} {
+		if ok, _ := Validate(bad, secret, now); ok {
+			t.Fatalf("bad passcode accepted: %q", bad)
+		}
+	}
+
+	if _, err := Validate(passcode, "not base32!", now); err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+func TestValidateCounter(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	now := time.Unix(1111111109, 0)
+	passcode, err := GenerateCode(secret, now)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The time step of the passcode is returned, even within the skew
+	expected := uint64(now.Unix()) / Period
+	for _, at := range []time.Time{now, now.Add(Period * time.Second)} {
+		counter, ok, err := ValidateCounter(passcode, secret, at)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if !ok {
+			t.Fatalf("passcode should be valid at %s", at)
+		}
+		if counter != expected {
+			t.Fatalf("bad counter: %d, expected %d", counter, expected)
+		}
+	}
+}
@@ -15,6 +15,10 @@ import (
 // AuthHeaderName is the name of the header containing the token.
 const AuthHeaderName = "X-Vault-Token"
 
+// MFAHeaderName is the name of the header containing the MFA payload,
+// given as "method:passcode". It may be repeated for several methods.
+const MFAHeaderName = "X-Vault-MFA"
+
 // Handler returns an http.Handler for the API. This can be used on
 // its own to mount the Vault API within another web server.
 func Handler(core *vault.Core) http.Handler {
@@ -36,12 +40,14 @@ func Handler(core *vault.Core) http.Handler {
 	mux.Handle("/v1/sys/auth/", proxySysRequest(core))
 	mux.Handle("/v1/sys/audit", handleSysListAudit(core))
 	mux.Handle("/v1/sys/audit/", handleSysAudit(core))
+	mux.Handle("/v1/sys/audit-hash/", proxySysRequest(core))
 	mux.Handle("/v1/sys/leader", handleSysLeader(core))
 	mux.Handle("/v1/sys/health", handleSysHealth(core))
 	mux.Handle("/v1/sys/rotate", proxySysRequest(core))
 	mux.Handle("/v1/sys/key-status", proxySysRequest(core))
 	mux.Handle("/v1/sys/rekey/init", handleSysRekeyInit(core))
 	mux.Handle("/v1/sys/rekey/update", handleSysRekeyUpdate(core))
+	mux.Handle("/v1/sys/refresh-shares", handleSysRefreshShares(core))
 	mux.Handle("/v1/", handleLogical(core, false))
 
 	// Wrap the handler in another handler to trigger all help paths.
@@ -77,6 +83,7 @@ func parseRequest(r *http.Request, out interface{}) error {
 // request is a helper to perform a request and properly exit in the
 // case of an error.
 func request(core *vault.Core, w http.ResponseWriter, rawReq *http.Request, r *logical.Request) (*logical.Response, bool) {
+	r.RemoteAddr = getRemoteAddr(rawReq)
 	resp, err := core.HandleRequest(r)
 	if err == vault.ErrStandby {
 		respondStandby(core, w, rawReq.URL)
@@ -143,12 +150,24 @@ func requestAuth(r *http.Request, req *logical.Request) *logical.Request {
 		req.ClientToken = v
 	}
 
+	// Attach the MFA payload
+	for _, v := range r.Header[MFAHeaderName] {
+		parts := strings.SplitN(v, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		if req.MFAPayload == nil {
+			req.MFAPayload = make(map[string]string)
+		}
+		req.MFAPayload[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+
 	return req
 }
 
 func respondError(w http.ResponseWriter, status int, err error) {
-	// Adjust status code when sealed
-	if err == vault.ErrSealed {
+	// Adjust status code when sealed or read-only
+	if err == vault.ErrSealed || err == vault.ErrReadOnly {
 		status = http.StatusServiceUnavailable
 	}
 
@@ -157,14 +176,23 @@ func respondError(w http.ResponseWriter, status int, err error) {
 		status = t.Code()
 	}
 
-	w.Header().Add("Content-Type", "application/json")
-	w.WriteHeader(status)
-
 	resp := &ErrorResponse{Errors: make([]string, 0, 1)}
 	if err != nil {
 		resp.Errors = append(resp.Errors, err.Error())
 	}
 
+	// A VaultError carries its own status and a hint whether to retry
+	if t, ok := err.(*logical.VaultError); ok {
+		if t.HTTPStatus != 0 {
+			status = t.HTTPStatus
+		}
+		resp.Code = t.Code
+		resp.Retryable = t.Retryable
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(status)
+
 	enc := json.NewEncoder(w)
 	enc.Encode(resp)
 }
@@ -175,23 +203,20 @@ func respondCommon(w http.ResponseWriter, resp *logical.Response, err error) boo
 	}
 
 	if resp.IsError() {
-		var statusCode int
-
-		switch err {
-		case logical.ErrPermissionDenied:
-			statusCode = http.StatusForbidden
-		case logical.ErrUnsupportedOperation:
-			statusCode = http.StatusMethodNotAllowed
-		case logical.ErrUnsupportedPath:
-			statusCode = http.StatusNotFound
-		case logical.ErrInvalidRequest:
-			statusCode = http.StatusBadRequest
-		default:
-			statusCode = http.StatusBadRequest
+		// The message of the response is returned with the code and
+		// hints of the error, if any
+		msg := resp.Data["error"].(string)
+		if t, ok := err.(*logical.VaultError); ok {
+			respondError(w, t.HTTPStatus, &logical.VaultError{
+				Code:       t.Code,
+				Message:    msg,
+				HTTPStatus: t.HTTPStatus,
+				Retryable:  t.Retryable,
+			})
+			return true
 		}
 
-		err := fmt.Errorf("%s", resp.Data["error"].(string))
-		respondError(w, statusCode, err)
+		respondError(w, http.StatusBadRequest, fmt.Errorf("%s", msg))
 		return true
 	}
 
@@ -215,5 +240,7 @@ func proxySysRequest(core *vault.Core) http.Handler {
 }
 
 type ErrorResponse struct {
-	Errors []string `json:"errors"`
+	Errors    []string `json:"errors"`
+	Code      string   `json:"code,omitempty"`
+	Retryable bool     `json:"retryable,omitempty"`
 }
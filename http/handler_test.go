@@ -1,6 +1,7 @@
 package http
 
 import (
+	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
@@ -9,6 +10,7 @@ import (
 
 	"github.com/hashicorp/go-cleanhttp"
 	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/physical"
 	"github.com/hashicorp/vault/vault"
 )
 
@@ -78,6 +80,32 @@ func TestHandler_sealed(t *testing.T) {
 	testResponseStatus(t, resp, 503)
 }
 
+func TestHandler_sealedSealStatus(t *testing.T) {
+	core, _, token := vault.TestCoreUnsealed(t)
+	server := httptest.NewServer(Handler(core))
+	defer server.Close()
+
+	core.Seal(token)
+
+	resp, err := http.Get(server.URL + "/v1/secret/foo")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	testResponseStatus(t, resp, 503)
+
+	// The seal status is still served
+	resp, err = http.Get(server.URL + "/v1/sys/seal-status")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	testResponseStatus(t, resp, 200)
+	var actual map[string]interface{}
+	testResponseBody(t, resp, &actual)
+	if actual["sealed"] != true {
+		t.Fatalf("bad: %#v", actual)
+	}
+}
+
 func TestHandler_error(t *testing.T) {
 	w := httptest.NewRecorder()
 
@@ -107,4 +135,109 @@ func TestHandler_error(t *testing.T) {
 		t.Fatalf("expected 503, got %d", w3.Code)
 	}
 
+	// A VaultError specifies the code and its hints are returned
+	w4 := httptest.NewRecorder()
+
+	respondError(w4, 500, logical.NewRateLimited("slow down"))
+
+	if w4.Code != 429 {
+		t.Fatalf("expected 429, got %d", w4.Code)
+	}
+	var actual map[string]interface{}
+	if err := json.NewDecoder(w4.Body).Decode(&actual); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := map[string]interface{}{
+		"errors":    []interface{}{"slow down"},
+		"code":      logical.ErrCodeRateLimited,
+		"retryable": true,
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("bad:\nExpected: %#v\nActual: %#v\n", expected, actual)
+	}
+}
+
+func TestHandler_VaultError(t *testing.T) {
+	core, _, token := vault.TestCoreUnsealed(t)
+	ln, addr := TestServer(t, core)
+	defer ln.Close()
+	TestServerAuth(t, addr, token)
+
+	cases := []struct {
+		method    string
+		path      string
+		body      interface{}
+		status    int
+		code      string
+		retryable bool
+	}{
+		{"DELETE", "/v1/sys/auth/token", nil, 400, logical.ErrCodeInvalidRequest, false},
+		{"DELETE", "/v1/sys/auth/missing", nil, 400, logical.ErrCodeInvalidRequest, false},
+		{"POST", "/v1/sys/auth/foo", map[string]interface{}{"type": "token"}, 400, logical.ErrCodeInvalidRequest, false},
+		{"POST", "/v1/sys/auth/foo", map[string]interface{}{"type": "noop"}, 204, "", false},
+		{"POST", "/v1/sys/auth/foo", map[string]interface{}{"type": "noop"}, 409, logical.ErrCodeConflict, false},
+		{"POST", "/v1/sys/auth/foo/bar", map[string]interface{}{"type": "noop"}, 400, logical.ErrCodeInvalidRequest, false},
+		{"GET", "/v1/nonexistent/foo", nil, 404, logical.ErrCodeUnsupportedPath, false},
+	}
+	for _, tc := range cases {
+		resp := testHttpData(t, tc.method, token, addr+tc.path, tc.body)
+		testResponseStatus(t, resp, tc.status)
+		if tc.status == 204 {
+			continue
+		}
+
+		var actual ErrorResponse
+		testResponseBody(t, resp, &actual)
+		if actual.Code != tc.code || actual.Retryable != tc.retryable {
+			t.Fatalf("%s %s: bad: %#v", tc.method, tc.path, actual)
+		}
+	}
+
+	// Requests to a sealed Vault may be retried
+	core.Seal(token)
+	resp := testHttpGet(t, token, addr+"/v1/secret/foo")
+	testResponseStatus(t, resp, 503)
+
+	var actual ErrorResponse
+	testResponseBody(t, resp, &actual)
+	if actual.Code != logical.ErrCodeUnavailable || !actual.Retryable {
+		t.Fatalf("bad: %#v", actual)
+	}
+}
+
+func TestHandler_GlobalRateLimit(t *testing.T) {
+	core, err := vault.NewCore(&vault.CoreConfig{
+		Physical:        physical.NewInmem(),
+		DisableMlock:    true,
+		GlobalRateLimit: 1,
+		GlobalRateBurst: 3,
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	key, token := vault.TestCoreInit(t, core)
+	if _, err := core.Unseal(vault.TestKeyCopy(key)); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	ln, addr := TestServer(t, core)
+	defer ln.Close()
+
+	// Drive traffic above the limit
+	limited := false
+	for i := 0; i < 10 && !limited; i++ {
+		resp := testHttpGet(t, token, addr+"/v1/secret/foo")
+		limited = resp.StatusCode == 429
+	}
+	if !limited {
+		t.Fatalf("requests should be rate limited")
+	}
+
+	// The health and seal status endpoints are not limited
+	for _, path := range []string{"/v1/sys/health", "/v1/sys/seal-status"} {
+		resp, err := http.Get(addr + path)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		testResponseStatus(t, resp, 200)
+	}
 }
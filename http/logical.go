@@ -4,6 +4,7 @@ import (
 	"io"
 	"net"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/hashicorp/vault/logical"
@@ -30,6 +31,11 @@ func handleLogical(core *vault.Core, dataOnly bool) http.Handler {
 			op = logical.DeleteOperation
 		case "GET":
 			op = logical.ReadOperation
+			if list, _ := strconv.ParseBool(r.URL.Query().Get("list")); list {
+				op = logical.ListOperation
+			}
+		case "LIST":
+			op = logical.ListOperation
 		case "POST":
 			fallthrough
 		case "PUT":
@@ -52,6 +58,9 @@ func handleLogical(core *vault.Core, dataOnly bool) http.Handler {
 				return
 			}
 		}
+		if op == logical.ListOperation {
+			req = listRequestData(r)
+		}
 
 		// Make the internal request. We attach the connection info
 		// as well in case this is an authentication request that requires
@@ -75,6 +84,19 @@ func handleLogical(core *vault.Core, dataOnly bool) http.Handler {
 	})
 }
 
+// listRequestData returns the pagination parameters of a list request
+// given in the query string
+func listRequestData(r *http.Request) map[string]interface{} {
+	data := make(map[string]interface{})
+	query := r.URL.Query()
+	for _, k := range []string{"limit", "next_token"} {
+		if v := query.Get(k); v != "" {
+			data[k] = v
+		}
+	}
+	return data
+}
+
 func respondLogical(w http.ResponseWriter, r *http.Request, path string, dataOnly bool, resp *logical.Response) {
 	var httpResp interface{}
 	if resp != nil {
@@ -180,20 +202,22 @@ func respondRaw(w http.ResponseWriter, r *http.Request, path string, resp *logic
 // getConnection is used to format the connection information for
 // attaching to a logical request
 func getConnection(r *http.Request) (connection *logical.Connection) {
-	var remoteAddr string
-
-	remoteAddr, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err != nil {
-		remoteAddr = ""
-	}
-
 	connection = &logical.Connection{
-		RemoteAddr: remoteAddr,
+		RemoteAddr: getRemoteAddr(r),
 		ConnState:  r.TLS,
 	}
 	return
 }
 
+// getRemoteAddr returns the source IP of the request, without the port
+func getRemoteAddr(r *http.Request) string {
+	remoteAddr, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return ""
+	}
+	return remoteAddr
+}
+
 type LogicalResponse struct {
 	LeaseID       string                 `json:"lease_id"`
 	Renewable     bool                   `json:"renewable"`
@@ -52,6 +52,62 @@ func TestLogical(t *testing.T) {
 	testResponseStatus(t, resp, 404)
 }
 
+func TestLogical_ListPagination(t *testing.T) {
+	core, _, token := vault.TestCoreUnsealed(t)
+	ln, addr := TestServer(t, core)
+	defer ln.Close()
+	TestServerAuth(t, addr, token)
+
+	for _, key := range []string{"a", "b", "c"} {
+		resp := testHttpPut(t, token, addr+"/v1/secret/"+key, map[string]interface{}{
+			"data": "bar",
+		})
+		testResponseStatus(t, resp, 204)
+	}
+
+	// First page
+	resp := testHttpGet(t, token, addr+"/v1/secret/?list=true&limit=2")
+	testResponseStatus(t, resp, 200)
+	var actual map[string]interface{}
+	testResponseBody(t, resp, &actual)
+	expected := map[string]interface{}{
+		"keys":       []interface{}{"a", "b"},
+		"next_token": "b",
+		"truncated":  true,
+	}
+	if !reflect.DeepEqual(actual["data"], expected) {
+		t.Fatalf("bad: %#v", actual)
+	}
+
+	// The continuation token fetches the last page
+	resp = testHttpGet(t, token, addr+"/v1/secret/?list=true&limit=2&next_token=b")
+	testResponseStatus(t, resp, 200)
+	actual = nil
+	testResponseBody(t, resp, &actual)
+	expected = map[string]interface{}{
+		"keys":       []interface{}{"c"},
+		"next_token": "",
+		"truncated":  false,
+	}
+	if !reflect.DeepEqual(actual["data"], expected) {
+		t.Fatalf("bad: %#v", actual)
+	}
+
+	// The LIST method is the same as a GET with list=true
+	resp = testHttpData(t, "LIST", token, addr+"/v1/secret/?limit=2", nil)
+	testResponseStatus(t, resp, 200)
+	actual = nil
+	testResponseBody(t, resp, &actual)
+	expected = map[string]interface{}{
+		"keys":       []interface{}{"a", "b"},
+		"next_token": "b",
+		"truncated":  true,
+	}
+	if !reflect.DeepEqual(actual["data"], expected) {
+		t.Fatalf("bad: %#v", actual)
+	}
+}
+
 func TestLogical_noExist(t *testing.T) {
 	core, _, token := vault.TestCoreUnsealed(t)
 	ln, addr := TestServer(t, core)
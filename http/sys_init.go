@@ -52,14 +52,19 @@ func handleSysInitPut(core *vault.Core, w http.ResponseWriter, r *http.Request)
 	}
 
 	// Encode the keys
-	keys := make([]string, 0, len(result.SecretShares))
-	for _, k := range result.SecretShares {
+	keys := make([]string, 0, len(result.UnsealShares))
+	for _, k := range result.UnsealShares {
 		keys = append(keys, hex.EncodeToString(k))
 	}
+	var recoveryKeys []string
+	for _, k := range result.RecoveryShares {
+		recoveryKeys = append(recoveryKeys, hex.EncodeToString(k))
+	}
 
 	respondOk(w, &InitResponse{
-		Keys:      keys,
-		RootToken: result.RootToken,
+		Keys:         keys,
+		RecoveryKeys: recoveryKeys,
+		RootToken:    result.RootToken,
 	})
 }
 
@@ -70,8 +75,9 @@ type InitRequest struct {
 }
 
 type InitResponse struct {
-	Keys      []string `json:"keys"`
-	RootToken string   `json:"root_token"`
+	Keys         []string `json:"keys"`
+	RecoveryKeys []string `json:"recovery_keys,omitempty"`
+	RootToken    string   `json:"root_token"`
 }
 
 type InitStatusResponse struct {
@@ -149,6 +149,56 @@ func handleSysRekeyUpdate(core *vault.Core) http.Handler {
 	})
 }
 
+func handleSysRefreshShares(core *vault.Core) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PUT" {
+			respondError(w, http.StatusMethodNotAllowed, nil)
+			return
+		}
+
+		// Parse the request
+		var req RefreshSharesRequest
+		if err := parseRequest(r, &req); err != nil {
+			respondError(w, http.StatusBadRequest, err)
+			return
+		}
+		if len(req.Keys) == 0 {
+			respondError(
+				w, http.StatusBadRequest,
+				errors.New("'keys' must specified in request body as JSON"))
+			return
+		}
+
+		// Decode the keys, which are hex encoded
+		keys := make([][]byte, 0, len(req.Keys))
+		for _, raw := range req.Keys {
+			key, err := hex.DecodeString(raw)
+			if err != nil {
+				respondError(
+					w, http.StatusBadRequest,
+					errors.New("'keys' must be valid hex-strings"))
+				return
+			}
+			keys = append(keys, key)
+		}
+
+		result, err := core.RefreshShares(keys, req.PGPKeys)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		// Encode the new keys
+		resp := &RefreshSharesResponse{
+			Keys: make([]string, 0, len(result.SecretShares)),
+		}
+		for _, k := range result.SecretShares {
+			resp.Keys = append(resp.Keys, hex.EncodeToString(k))
+		}
+		respondOk(w, resp)
+	})
+}
+
 type RekeyRequest struct {
 	SecretShares    int      `json:"secret_shares"`
 	SecretThreshold int      `json:"secret_threshold"`
@@ -171,3 +221,12 @@ type RekeyUpdateResponse struct {
 	Complete bool     `json:"complete"`
 	Keys     []string `json:"keys"`
 }
+
+type RefreshSharesRequest struct {
+	Keys    []string `json:"keys"`
+	PGPKeys []string `json:"pgp_keys"`
+}
+
+type RefreshSharesResponse struct {
+	Keys []string `json:"keys"`
+}
@@ -144,3 +144,28 @@ func TestSysRekey_Update(t *testing.T) {
 		t.Fatalf("bad: %#v", actual)
 	}
 }
+
+func TestSysRefreshShares(t *testing.T) {
+	core, master, token := vault.TestCoreUnsealed(t)
+	ln, addr := TestServer(t, core)
+	defer ln.Close()
+	TestServerAuth(t, addr, token)
+
+	resp := testHttpPut(t, token, addr+"/v1/sys/refresh-shares", map[string]interface{}{
+		"keys": []string{hex.EncodeToString(master)},
+	})
+
+	var actual map[string]interface{}
+	testResponseStatus(t, resp, 200)
+	testResponseBody(t, resp, &actual)
+	keys, ok := actual["keys"].([]interface{})
+	if !ok || len(keys) != 1 || keys[0] == hex.EncodeToString(master) {
+		t.Fatalf("bad: %#v", actual)
+	}
+
+	// The old key is no longer accepted
+	resp = testHttpPut(t, token, addr+"/v1/sys/refresh-shares", map[string]interface{}{
+		"keys": []string{hex.EncodeToString(master)},
+	})
+	testResponseStatus(t, resp, 400)
+}
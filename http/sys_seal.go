@@ -9,6 +9,7 @@ import (
 	"github.com/hashicorp/errwrap"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/vault"
+	"github.com/hashicorp/vault/version"
 )
 
 func handleSysSeal(core *vault.Core) http.Handler {
@@ -22,10 +23,12 @@ func handleSysSeal(core *vault.Core) http.Handler {
 		}
 
 		// Get the auth for the request so we can access the token directly
-		req := requestAuth(r, &logical.Request{})
+		req := requestAuth(r, &logical.Request{
+			RemoteAddr: getRemoteAddr(r),
+		})
 
 		// Seal with the token above
-		if err := core.Seal(req.ClientToken); err != nil {
+		if err := core.SealWithRequest(req); err != nil {
 			respondError(w, http.StatusInternalServerError, err)
 			return
 		}
@@ -50,13 +53,6 @@ func handleSysUnseal(core *vault.Core) http.Handler {
 			respondError(w, http.StatusBadRequest, err)
 			return
 		}
-		if !req.Reset && req.Key == "" {
-			respondError(
-				w, http.StatusBadRequest,
-				errors.New("'key' must specified in request body as JSON, or 'reset' set to true"))
-			return
-		}
-
 		if req.Reset {
 			sealed, err := core.Sealed()
 			if err != nil {
@@ -68,6 +64,18 @@ func handleSysUnseal(core *vault.Core) http.Handler {
 				return
 			}
 			core.ResetUnsealProcess()
+		} else if req.Key == "" {
+			// Without a key, unseal using the master key stored by the seal
+			if _, err := core.UnsealWithStoredKey(); err != nil {
+				if err == vault.ErrNoStoredKey {
+					respondError(
+						w, http.StatusBadRequest,
+						errors.New("'key' must specified in request body as JSON, or 'reset' set to true"))
+					return
+				}
+				respondError(w, http.StatusInternalServerError, err)
+				return
+			}
 		} else {
 			// Decode the key, which is hex encoded
 			key, err := hex.DecodeString(req.Key)
@@ -78,8 +86,12 @@ func handleSysUnseal(core *vault.Core) http.Handler {
 				return
 			}
 
-			// Attempt the unseal
-			if _, err := core.Unseal(key); err != nil {
+			// Attempt the unseal, bypassing the storage probe if forced
+			unseal := core.Unseal
+			if req.Force {
+				unseal = core.ForceUnseal
+			}
+			if _, err := unseal(key); err != nil {
 				// Ignore ErrInvalidKey because its a user error that we
 				// mask away. We just show them the seal status.
 				if !errwrap.ContainsType(err, new(vault.ErrInvalidKey)) {
@@ -112,6 +124,21 @@ func handleSysSealStatusRaw(core *vault.Core, w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	// A Vault that is not initialized has no seal configuration yet
+	init, err := core.Initialized()
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !init {
+		respondOk(w, &SealStatusResponse{
+			Sealed:      sealed,
+			Initialized: false,
+			Version:     versionString(),
+		})
+		return
+	}
+
 	sealConfig, err := core.SealConfig()
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, err)
@@ -123,22 +150,51 @@ func handleSysSealStatusRaw(core *vault.Core, w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	// The leader is only known while unsealed
+	_, leader, err := core.Leader()
+	if err == vault.ErrHANotEnabled || err == vault.ErrSealed {
+		err = nil
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err)
+		return
+	}
+
 	respondOk(w, &SealStatusResponse{
-		Sealed:   sealed,
-		T:        sealConfig.SecretThreshold,
-		N:        sealConfig.SecretShares,
-		Progress: core.SecretProgress(),
+		Sealed:      sealed,
+		Initialized: init,
+		T:           sealConfig.SecretThreshold,
+		N:           sealConfig.SecretShares,
+		Threshold:   sealConfig.SecretThreshold,
+		Progress:    core.SecretProgress(),
+		Version:     versionString(),
+		Leader:      leader,
 	})
 }
 
+// versionString returns the version of the server, including the
+// pre-release marker
+func versionString() string {
+	info := version.GetVersion()
+	if info.VersionPrerelease == "" {
+		return info.Version
+	}
+	return info.Version + "-" + info.VersionPrerelease
+}
+
 type SealStatusResponse struct {
-	Sealed   bool `json:"sealed"`
-	T        int  `json:"t"`
-	N        int  `json:"n"`
-	Progress int  `json:"progress"`
+	Sealed      bool   `json:"sealed"`
+	Initialized bool   `json:"initialized"`
+	T           int    `json:"t"`
+	N           int    `json:"n"`
+	Threshold   int    `json:"threshold"`
+	Progress    int    `json:"progress"`
+	Version     string `json:"version"`
+	Leader      string `json:"leader,omitempty"`
 }
 
 type UnsealRequest struct {
 	Key   string
 	Reset bool
+	Force bool
 }
@@ -6,6 +6,7 @@ import (
 	"reflect"
 	"testing"
 
+	"github.com/hashicorp/vault/physical"
 	"github.com/hashicorp/vault/vault"
 )
 
@@ -22,10 +23,13 @@ func TestSysSealStatus(t *testing.T) {
 
 	var actual map[string]interface{}
 	expected := map[string]interface{}{
-		"sealed":   true,
-		"t":        float64(1),
-		"n":        float64(1),
-		"progress": float64(0),
+		"sealed":      true,
+		"initialized": true,
+		"t":           float64(1),
+		"n":           float64(1),
+		"threshold":   float64(1),
+		"progress":    float64(0),
+		"version":     versionString(),
 	}
 	testResponseStatus(t, resp, 200)
 	testResponseBody(t, resp, &actual)
@@ -43,7 +47,126 @@ func TestSysSealStatus_uninit(t *testing.T) {
 	if err != nil {
 		t.Fatalf("err: %s", err)
 	}
-	testResponseStatus(t, resp, 400)
+
+	var actual map[string]interface{}
+	expected := map[string]interface{}{
+		"sealed":      true,
+		"initialized": false,
+		"t":           float64(0),
+		"n":           float64(0),
+		"threshold":   float64(0),
+		"progress":    float64(0),
+		"version":     versionString(),
+	}
+	testResponseStatus(t, resp, 200)
+	testResponseBody(t, resp, &actual)
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("bad: %#v", actual)
+	}
+}
+
+func TestSysSealStatus_unsealed(t *testing.T) {
+	core, _, _ := vault.TestCoreUnsealed(t)
+	ln, addr := TestServer(t, core)
+	defer ln.Close()
+
+	resp, err := http.Get(addr + "/v1/sys/seal-status")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	var actual map[string]interface{}
+	expected := map[string]interface{}{
+		"sealed":      false,
+		"initialized": true,
+		"t":           float64(1),
+		"n":           float64(1),
+		"threshold":   float64(1),
+		"progress":    float64(0),
+		"version":     versionString(),
+	}
+	testResponseStatus(t, resp, 200)
+	testResponseBody(t, resp, &actual)
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("bad: %#v", actual)
+	}
+}
+
+func TestSysSealStatus_progress(t *testing.T) {
+	core := vault.TestCore(t)
+	result, err := core.Initialize(&vault.SealConfig{
+		SecretShares:    5,
+		SecretThreshold: 3,
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := core.Unseal(result.UnsealShares[0]); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	ln, addr := TestServer(t, core)
+	defer ln.Close()
+
+	resp, err := http.Get(addr + "/v1/sys/seal-status")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	var actual map[string]interface{}
+	expected := map[string]interface{}{
+		"sealed":      true,
+		"initialized": true,
+		"t":           float64(3),
+		"n":           float64(5),
+		"threshold":   float64(3),
+		"progress":    float64(1),
+		"version":     versionString(),
+	}
+	testResponseStatus(t, resp, 200)
+	testResponseBody(t, resp, &actual)
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("bad: %#v", actual)
+	}
+}
+
+func TestSysSealStatus_leader(t *testing.T) {
+	core, err := vault.NewCore(&vault.CoreConfig{
+		Physical:      physical.NewInmemHA(),
+		AdvertiseAddr: "http://127.0.0.1:8200",
+		DisableMlock:  true,
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	key, _ := vault.TestCoreInit(t, core)
+	ln, addr := TestServer(t, core)
+	defer ln.Close()
+
+	// The leader is not reported while sealed
+	resp, err := http.Get(addr + "/v1/sys/seal-status")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	var actual map[string]interface{}
+	testResponseStatus(t, resp, 200)
+	testResponseBody(t, resp, &actual)
+	if _, ok := actual["leader"]; ok {
+		t.Fatalf("bad: %#v", actual)
+	}
+
+	if _, err := core.Unseal(vault.TestKeyCopy(key)); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	resp, err = http.Get(addr + "/v1/sys/seal-status")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	actual = map[string]interface{}{}
+	testResponseStatus(t, resp, 200)
+	testResponseBody(t, resp, &actual)
+	if actual["leader"] != "http://127.0.0.1:8200" {
+		t.Fatalf("bad: %#v", actual)
+	}
 }
 
 func TestSysSeal(t *testing.T) {
@@ -94,10 +217,13 @@ func TestSysUnseal(t *testing.T) {
 
 	var actual map[string]interface{}
 	expected := map[string]interface{}{
-		"sealed":   false,
-		"t":        float64(1),
-		"n":        float64(1),
-		"progress": float64(0),
+		"sealed":      false,
+		"initialized": true,
+		"t":           float64(1),
+		"n":           float64(1),
+		"threshold":   float64(1),
+		"progress":    float64(0),
+		"version":     versionString(),
 	}
 	testResponseStatus(t, resp, 200)
 	testResponseBody(t, resp, &actual)
@@ -118,10 +244,13 @@ func TestSysUnseal_badKey(t *testing.T) {
 
 	var actual map[string]interface{}
 	expected := map[string]interface{}{
-		"sealed":   true,
-		"t":        float64(1),
-		"n":        float64(1),
-		"progress": float64(0),
+		"sealed":      true,
+		"initialized": true,
+		"t":           float64(1),
+		"n":           float64(1),
+		"threshold":   float64(1),
+		"progress":    float64(0),
+		"version":     versionString(),
 	}
 	testResponseStatus(t, resp, 200)
 	testResponseBody(t, resp, &actual)
@@ -130,6 +259,64 @@ func TestSysUnseal_badKey(t *testing.T) {
 	}
 }
 
+// storedKeySeal stores the master key in memory
+type storedKeySeal struct {
+	key []byte
+}
+
+func (s *storedKeySeal) Type() string {
+	return "test"
+}
+
+func (s *storedKeySeal) SetStoredKey(key []byte) error {
+	s.key = append([]byte(nil), key...)
+	return nil
+}
+
+func (s *storedKeySeal) StoredKey() ([]byte, error) {
+	return append([]byte(nil), s.key...), nil
+}
+
+func TestSysUnseal_storedKey(t *testing.T) {
+	core, err := vault.NewCore(&vault.CoreConfig{
+		Physical:     physical.NewInmem(),
+		DisableMlock: true,
+		Seal:         &storedKeySeal{},
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := core.Initialize(&vault.SealConfig{
+		SecretShares:    1,
+		SecretThreshold: 1,
+	}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	ln, addr := TestServer(t, core)
+	defer ln.Close()
+
+	// Without a key, the master key stored by the seal is used
+	resp := testHttpPut(t, "", addr+"/v1/sys/unseal", map[string]interface{}{})
+
+	var actual map[string]interface{}
+	testResponseStatus(t, resp, 200)
+	testResponseBody(t, resp, &actual)
+	if actual["sealed"] != false {
+		t.Fatalf("bad: %#v", actual)
+	}
+}
+
+func TestSysUnseal_noKey(t *testing.T) {
+	core := vault.TestCore(t)
+	vault.TestCoreInit(t, core)
+	ln, addr := TestServer(t, core)
+	defer ln.Close()
+
+	// Without a seal storing the master key, a key is required
+	resp := testHttpPut(t, "", addr+"/v1/sys/unseal", map[string]interface{}{})
+	testResponseStatus(t, resp, 400)
+}
+
 func TestSysUnseal_Reset(t *testing.T) {
 	core := vault.TestCore(t)
 	ln, addr := TestServer(t, core)
@@ -159,10 +346,13 @@ func TestSysUnseal_Reset(t *testing.T) {
 
 		var actual map[string]interface{}
 		expected := map[string]interface{}{
-			"sealed":   true,
-			"t":        float64(3),
-			"n":        float64(5),
-			"progress": float64(i + 1),
+			"sealed":      true,
+			"initialized": true,
+			"t":           float64(3),
+			"n":           float64(5),
+			"threshold":   float64(3),
+			"progress":    float64(i + 1),
+			"version":     versionString(),
 		}
 		testResponseStatus(t, resp, 200)
 		testResponseBody(t, resp, &actual)
@@ -177,10 +367,13 @@ func TestSysUnseal_Reset(t *testing.T) {
 
 	actual = map[string]interface{}{}
 	expected := map[string]interface{}{
-		"sealed":   true,
-		"t":        float64(3),
-		"n":        float64(5),
-		"progress": float64(0),
+		"sealed":      true,
+		"initialized": true,
+		"t":           float64(3),
+		"n":           float64(5),
+		"threshold":   float64(3),
+		"progress":    float64(0),
+		"version":     versionString(),
 	}
 	testResponseStatus(t, resp, 200)
 	testResponseBody(t, resp, &actual)
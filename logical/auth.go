@@ -24,6 +24,12 @@ type Auth struct {
 	// is associated with.
 	Policies []string
 
+	// PolicySnapshot, if set, keeps the policies the token was created
+	// with when it is renewed. Otherwise the policies returned by the
+	// backend on renewal replace them, so that changes to the policies
+	// mapped to the user, e.g. through its groups, are picked up.
+	PolicySnapshot bool
+
 	// Metadata is used to attach arbitrary string-type metadata to
 	// an authenticated user. This metadata will be outputted into the
 	// audit log.
@@ -213,6 +213,17 @@ func (b *Backend) Route(path string) *Path {
 	return result
 }
 
+// HandlesOperation checks if the path matching the given path string has
+// a callback for the operation.
+func (b *Backend) HandlesOperation(op logical.Operation, path string) bool {
+	p := b.Route(path)
+	if p == nil {
+		return false
+	}
+	_, ok := p.Callbacks[op]
+	return ok
+}
+
 // Secret is used to look up the secret with the given type.
 func (b *Backend) Secret(k string) *Secret {
 	for _, s := range b.Secrets {
@@ -563,3 +563,28 @@ func TestFieldSchemaDefaultOrZero(t *testing.T) {
 		}
 	}
 }
+
+func TestBackendHandlesOperation(t *testing.T) {
+	b := &Backend{
+		Paths: []*Path{
+			&Path{
+				Pattern: "foo/bar",
+				Callbacks: map[logical.Operation]OperationFunc{
+					logical.ReadOperation: func(*logical.Request, *FieldData) (*logical.Response, error) {
+						return nil, nil
+					},
+				},
+			},
+		},
+	}
+
+	if !b.HandlesOperation(logical.ReadOperation, "foo/bar") {
+		t.Fatal("should handle read")
+	}
+	if b.HandlesOperation(logical.RotateOperation, "foo/bar") {
+		t.Fatal("should not handle rotate")
+	}
+	if b.HandlesOperation(logical.ReadOperation, "foo/baz") {
+		t.Fatal("should not handle unknown path")
+	}
+}
@@ -0,0 +1,106 @@
+package framework
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+// rolePrefix is the storage prefix the roles of a backend are kept under
+const rolePrefix = "role/"
+
+// PathRoles can be used by backends that store their roles under the
+// "role/" prefix to serve the "list-roles-detailed" path, which returns
+// every role with its configuration in a single call instead of a list
+// followed by a read per role.
+type PathRoles struct {
+	// Read returns the configuration of the named role as shown to
+	// operators, or nil if the role does not exist. It is usually the
+	// data returned when reading the role.
+	Read func(s logical.Storage, name string) (map[string]interface{}, error)
+
+	// SensitiveFields are removed from the configuration of every role
+	SensitiveFields []string
+
+	HelpSynopsis    string
+	HelpDescription string
+}
+
+// ListDetailed returns the sorted names of the roles and the configuration
+// of each of them, without the sensitive fields
+func (p *PathRoles) ListDetailed(s logical.Storage) ([]string, map[string]interface{}, error) {
+	keys, err := s.List(rolePrefix)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	names := make([]string, 0, len(keys))
+	roles := make(map[string]interface{}, len(keys))
+	for _, name := range keys {
+		// Role names do not nest
+		if strings.HasSuffix(name, "/") {
+			continue
+		}
+
+		config, err := p.Read(s, name)
+		if err != nil {
+			return nil, nil, err
+		}
+		if config == nil {
+			continue
+		}
+
+		filtered := make(map[string]interface{}, len(config))
+		for k, v := range config {
+			if !strListContains(p.SensitiveFields, k) {
+				filtered[k] = v
+			}
+		}
+		names = append(names, name)
+		roles[name] = filtered
+	}
+	sort.Strings(names)
+	return names, roles, nil
+}
+
+// Paths are the paths to append to the Backend paths.
+func (p *PathRoles) Paths() []*Path {
+	return []*Path{
+		&Path{
+			Pattern: "list-roles-detailed/?$",
+
+			Callbacks: map[logical.Operation]OperationFunc{
+				logical.ReadOperation: p.pathListDetailed,
+				logical.ListOperation: p.pathListDetailed,
+			},
+
+			HelpSynopsis:    p.HelpSynopsis,
+			HelpDescription: p.HelpDescription,
+		},
+	}
+}
+
+func (p *PathRoles) pathListDetailed(
+	req *logical.Request, d *FieldData) (*logical.Response, error) {
+	names, roles, err := p.ListDetailed(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"keys":  names,
+			"roles": roles,
+		},
+	}, nil
+}
+
+func strListContains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
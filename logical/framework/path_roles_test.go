@@ -0,0 +1,66 @@
+package framework
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestPathRoles(t *testing.T) {
+	storage := new(logical.InmemStorage)
+	roles := map[string]map[string]interface{}{
+		"web": {"ttl": "1h", "password": "secret"},
+		"db":  {"ttl": "2h", "password": "hunter2"},
+		"ops": {"ttl": "3h"},
+	}
+	for name := range roles {
+		if err := storage.Put(&logical.StorageEntry{Key: "role/" + name}); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+
+	// Entries that are not roles are skipped
+	if err := storage.Put(&logical.StorageEntry{Key: "role/nested/foo"}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := storage.Put(&logical.StorageEntry{Key: "other/foo"}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	p := &PathRoles{
+		Read: func(s logical.Storage, name string) (map[string]interface{}, error) {
+			return roles[name], nil
+		},
+		SensitiveFields: []string{"password"},
+	}
+	var b logical.Backend = &Backend{Paths: p.Paths()}
+
+	for _, op := range []logical.Operation{logical.ReadOperation, logical.ListOperation} {
+		resp, err := b.HandleRequest(&logical.Request{
+			Operation: op,
+			Path:      "list-roles-detailed",
+			Storage:   storage,
+		})
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+
+		expected := map[string]interface{}{
+			"keys": []string{"db", "ops", "web"},
+			"roles": map[string]interface{}{
+				"db":  map[string]interface{}{"ttl": "2h"},
+				"ops": map[string]interface{}{"ttl": "3h"},
+				"web": map[string]interface{}{"ttl": "1h"},
+			},
+		}
+		if !reflect.DeepEqual(resp.Data, expected) {
+			t.Fatalf("bad: %#v", resp.Data)
+		}
+	}
+
+	// The configuration returned by Read is not modified
+	if roles["web"]["password"] != "secret" {
+		t.Fatalf("bad: %#v", roles["web"])
+	}
+}
@@ -30,6 +30,44 @@ type Backend interface {
 	Cleanup()
 }
 
+// Initializer is an optional interface for backends that depend on
+// external services which may not be available when the backend is
+// mounted. Requests to the backend are rejected until Initialize
+// succeeds.
+type Initializer interface {
+	Initialize() error
+}
+
+// Validator is an optional interface for backends that validate
+// requests before they are handled. If Validate returns an error, the
+// request is rejected as an invalid request without being handled.
+type Validator interface {
+	Validate(*Request) error
+}
+
+// OperationChecker is an optional interface for backends that can tell
+// whether they handle an operation on a path, without handling it.
+type OperationChecker interface {
+	HandlesOperation(op Operation, path string) bool
+}
+
+// Warner is an optional interface for backends that detect a default
+// or insecure configuration. The warnings are returned to the operator
+// enabling the backend, without failing the request.
+type Warner interface {
+	Warnings() []string
+}
+
+// Upgrader is an optional interface for backends that version the
+// layout of their storage. SchemaVersion returns the oldest version the
+// backend can upgrade from and the version it uses. When the backend is
+// set up on storage of an older version, Upgrade is called to migrate
+// it before the backend is mounted.
+type Upgrader interface {
+	SchemaVersion() (min, current int)
+	Upgrade(from, to int, storage Storage) error
+}
+
 // BackendConfig is provided to the factory to initialize the backend
 type BackendConfig struct {
 	// View should not be stored, and should only be used for initialization
@@ -56,4 +94,10 @@ type Paths struct {
 
 	// Unauthenticated are the paths that can be accessed without any auth.
 	Unauthenticated []string
+
+	// Deprecated maps deprecated paths to the paths replacing them,
+	// relative to the mount. Deprecated paths are still served, but a
+	// warning suggesting the replacement is attached to the responses.
+	// The replacement may be empty if there is none.
+	Deprecated map[string]string
 }
@@ -1,8 +1,8 @@
 package logical
 
 import (
-	"errors"
 	"fmt"
+	"net/http"
 )
 
 // Request is a struct that stores the parameters and context
@@ -37,12 +37,22 @@ type Request struct {
 	// authentication/protection.
 	Connection *Connection
 
+	// RemoteAddr is the source IP of the client. Unlike Connection, it is
+	// available to every backend and is used to enforce CIDR restrictions
+	// in policies.
+	RemoteAddr string
+
 	// ClientToken is provided to the core so that the identity
 	// can be verified and ACLs applied. This value is passed
 	// through to the logical backends but after being salted and
 	// hashed.
 	ClientToken string
 
+	// MFAPayload holds the credentials for the MFA methods required by
+	// the policies of the path, keyed by the method name. It is checked
+	// by the core and is not passed to the logical backends.
+	MFAPayload map[string]string
+
 	// DisplayName is provided to the logical backend to help associate
 	// dynamic secrets with the source entity. This is not a sensitive
 	// name, but is useful for operators.
@@ -52,6 +62,18 @@ type Request struct {
 	// paths relative to itself. The `Path` is effectively the client
 	// request path with the MountPoint trimmed off.
 	MountPoint string
+
+	// Rollbacks are compensating actions registered by the backend
+	// while handling an operation that makes several changes. If the
+	// operation returns an error, they are invoked by the router in the
+	// reverse order of registration to undo the partial changes.
+	Rollbacks []func() error
+}
+
+// AddRollback registers a compensating action to undo a change made
+// while handling the request, in case a later step fails
+func (r *Request) AddRollback(f func() error) {
+	r.Rollbacks = append(r.Rollbacks, f)
 }
 
 // Get returns a data field and guards for nil Data
@@ -131,20 +153,23 @@ const (
 	RevokeOperation   Operation = "revoke"
 	RenewOperation              = "renew"
 	RollbackOperation           = "rollback"
+	RotateOperation             = "rotate"
 )
 
 var (
 	// ErrUnsupportedOperation is returned if the operation is not supported
 	// by the logical backend.
-	ErrUnsupportedOperation = errors.New("unsupported operation")
+	ErrUnsupportedOperation = NewVaultError(
+		ErrCodeUnsupportedOp, http.StatusMethodNotAllowed, false, "unsupported operation")
 
 	// ErrUnsupportedPath is returned if the path is not supported
 	// by the logical backend.
-	ErrUnsupportedPath = errors.New("unsupported path")
+	ErrUnsupportedPath = NewVaultError(
+		ErrCodeUnsupportedPath, http.StatusNotFound, false, "unsupported path")
 
 	// ErrInvalidRequest is returned if the request is invalid
-	ErrInvalidRequest = errors.New("invalid request")
+	ErrInvalidRequest = NewInvalidRequest("invalid request")
 
 	// ErrPermissionDenied is returned if the client is not authorized
-	ErrPermissionDenied = errors.New("permission denied")
+	ErrPermissionDenied = NewPermissionDenied("permission denied")
 )
@@ -3,6 +3,7 @@ package logical
 import (
 	"fmt"
 	"reflect"
+	"sort"
 
 	"github.com/mitchellh/copystructure"
 )
@@ -147,3 +148,35 @@ func ListResponse(keys []string) *Response {
 		},
 	}
 }
+
+// ListPageResponse is used to format a response to a list operation that
+// returns a single page of keys. An empty nextToken marks the last page.
+func ListPageResponse(keys []string, nextToken string) *Response {
+	resp := ListResponse(keys)
+	if nextToken != "" {
+		resp.Data["next_token"] = nextToken
+	}
+	return resp
+}
+
+// ListPage returns the page of keys that sorts after the continuation token
+// "after", holding at most limit keys. A limit of zero or less returns all
+// remaining keys. If more keys remain, the returned continuation token can
+// be passed back as "after" to fetch the next page.
+func ListPage(keys []string, after string, limit int) ([]string, string) {
+	sorted := make([]string, len(keys))
+	copy(sorted, keys)
+	sort.Strings(sorted)
+
+	start := sort.SearchStrings(sorted, after)
+	if start < len(sorted) && after != "" && sorted[start] == after {
+		start++
+	}
+	page := sorted[start:]
+	if limit <= 0 || len(page) <= limit {
+		return page, ""
+	}
+
+	page = page[:limit]
+	return page, page[limit-1]
+}
@@ -3,6 +3,8 @@ package logical
 import (
 	"bytes"
 	"encoding/json"
+	"io"
+	"io/ioutil"
 )
 
 // Storage is the way that logical backends are able read/write data.
@@ -13,6 +15,50 @@ type Storage interface {
 	Delete(string) error
 }
 
+// StreamingStorage is implemented by storage that can write and read
+// values in chunks, so that the plaintext of large values is not held in
+// memory in full. The encrypted value is still held in full, since the
+// physical backends store each value as a single entry. Use PutStream
+// and GetStream to fall back to the buffered methods of storage that does
+// not implement it.
+type StreamingStorage interface {
+	// PutStream stores the value read from r under the key
+	PutStream(key string, r io.Reader) error
+
+	// GetStream returns a reader of the value of the key, or nil if the
+	// key does not exist. The reader must be closed.
+	GetStream(key string) (io.ReadCloser, error)
+}
+
+// PutStream stores the value read from r under the key, reading the
+// whole value first if the storage does not stream
+func PutStream(s Storage, key string, r io.Reader) error {
+	if ss, ok := s.(StreamingStorage); ok {
+		return ss.PutStream(key, r)
+	}
+
+	value, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return s.Put(&StorageEntry{Key: key, Value: value})
+}
+
+// GetStream returns a reader of the value of the key, or nil if the key
+// does not exist. The whole value is read first if the storage does not
+// stream.
+func GetStream(s Storage, key string) (io.ReadCloser, error) {
+	if ss, ok := s.(StreamingStorage); ok {
+		return ss.GetStream(key)
+	}
+
+	entry, err := s.Get(key)
+	if err != nil || entry == nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(bytes.NewReader(entry.Value)), nil
+}
+
 // StorageEntry is the entry for an item in a Storage implementation.
 type StorageEntry struct {
 	Key   string
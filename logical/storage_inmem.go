@@ -15,11 +15,23 @@ type InmemStorage struct {
 func (s *InmemStorage) List(prefix string) ([]string, error) {
 	s.once.Do(s.init)
 
+	// Return keys relative to the prefix, with nested keys collapsed
+	// into their directory, like the physical backends do
 	var result []string
+	seen := make(map[string]struct{})
 	for k, _ := range s.Data {
-		if strings.HasPrefix(k, prefix) {
-			result = append(result, k)
+		if !strings.HasPrefix(k, prefix) {
+			continue
 		}
+		trimmed := strings.TrimPrefix(k, prefix)
+		if sep := strings.Index(trimmed, "/"); sep != -1 {
+			trimmed = trimmed[:sep+1]
+			if _, ok := seen[trimmed]; ok {
+				continue
+			}
+			seen[trimmed] = struct{}{}
+		}
+		result = append(result, trimmed)
 	}
 
 	return result, nil
@@ -32,7 +44,12 @@ func (s *InmemStorage) Get(key string) (*StorageEntry, error) {
 
 func (s *InmemStorage) Put(entry *StorageEntry) error {
 	s.once.Do(s.init)
-	s.Data[entry.Key] = entry
+
+	// Store a copy, since callers may reuse the entry after putting it
+	s.Data[entry.Key] = &StorageEntry{
+		Key:   entry.Key,
+		Value: entry.Value,
+	}
 	return nil
 }
 
@@ -1,9 +1,47 @@
 package logical
 
 import (
+	"reflect"
+	"sort"
 	"testing"
 )
 
 func TestInmemStorage(t *testing.T) {
 	TestStorage(t, new(InmemStorage))
 }
+
+func TestInmemStorage_ListPutCopy(t *testing.T) {
+	s := new(InmemStorage)
+	for _, k := range []string{"foo/bar", "foo/baz/zip", "foo/baz/zap", "other"} {
+		if err := s.Put(&StorageEntry{Key: k, Value: []byte(k)}); err != nil {
+			t.Fatalf("put error: %s", err)
+		}
+	}
+
+	keys, err := s.List("foo/")
+	if err != nil {
+		t.Fatalf("list error: %s", err)
+	}
+	sort.Strings(keys)
+	if !reflect.DeepEqual(keys, []string{"bar", "baz/"}) {
+		t.Fatalf("bad keys: %#v", keys)
+	}
+
+	// Reusing an entry after putting it must not change the stored one
+	entry := &StorageEntry{Key: "reuse", Value: []byte("first")}
+	if err := s.Put(entry); err != nil {
+		t.Fatalf("put error: %s", err)
+	}
+	entry.Key = "reuse2"
+	entry.Value = []byte("second")
+	if err := s.Put(entry); err != nil {
+		t.Fatalf("put error: %s", err)
+	}
+	actual, err := s.Get("reuse")
+	if err != nil {
+		t.Fatalf("get error: %s", err)
+	}
+	if actual == nil || actual.Key != "reuse" || string(actual.Value) != "first" {
+		t.Fatalf("bad: %#v", actual)
+	}
+}
@@ -0,0 +1,40 @@
+package logical
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func TestStorage_StreamBuffered(t *testing.T) {
+	s := new(InmemStorage)
+	value := bytes.Repeat([]byte("foo"), 1024)
+	if err := PutStream(s, "test", bytes.NewReader(value)); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	entry, err := s.Get("test")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if entry == nil || !bytes.Equal(entry.Value, value) {
+		t.Fatalf("bad: %#v", entry)
+	}
+
+	r, err := GetStream(s, "test")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer r.Close()
+	out, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !bytes.Equal(out, value) {
+		t.Fatalf("bad: %s", out)
+	}
+
+	if r, err := GetStream(s, "missing"); err != nil || r != nil {
+		t.Fatalf("bad: %v %v", r, err)
+	}
+}
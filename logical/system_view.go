@@ -16,12 +16,17 @@ type SystemView interface {
 	// SudoPrivilege returns true if given path has sudo privileges
 	// for the given client token
 	SudoPrivilege(path string, token string) bool
+
+	// GeneratePasswordFromPolicy generates a password conforming to
+	// the named password policy
+	GeneratePasswordFromPolicy(policyName string) (string, error)
 }
 
 type StaticSystemView struct {
 	DefaultLeaseTTLVal time.Duration
 	MaxLeaseTTLVal     time.Duration
 	SudoPrivilegeVal   bool
+	PasswordVal        string
 }
 
 func (d StaticSystemView) DefaultLeaseTTL() time.Duration {
@@ -35,3 +40,7 @@ func (d StaticSystemView) MaxLeaseTTL() time.Duration {
 func (d StaticSystemView) SudoPrivilege(path string, token string) bool {
 	return d.SudoPrivilegeVal
 }
+
+func (d StaticSystemView) GeneratePasswordFromPolicy(policyName string) (string, error) {
+	return d.PasswordVal, nil
+}
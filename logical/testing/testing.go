@@ -144,7 +144,7 @@ func Test(t TestT, c TestCase) {
 	}
 
 	// Unseal the core
-	if unsealed, err := core.Unseal(init.SecretShares[0]); err != nil {
+	if unsealed, err := core.Unseal(init.UnsealShares[0]); err != nil {
 		t.Fatal("error unsealing core: ", err)
 		return
 	} else if !unsealed {
@@ -196,6 +196,7 @@ func Test(t TestT, c TestCase) {
 		}
 		if s.RemoteAddr != "" {
 			req.Connection = &logical.Connection{RemoteAddr: s.RemoteAddr}
+			req.RemoteAddr = s.RemoteAddr
 		}
 		if s.ConnState != nil {
 			req.Connection = &logical.Connection{ConnState: s.ConnState}
@@ -0,0 +1,68 @@
+package logical
+
+import (
+	"fmt"
+	"net/http"
+)
+
+const (
+	// Machine readable codes of a VaultError
+	ErrCodeInvalidRequest   = "invalid_request"
+	ErrCodePermissionDenied = "permission_denied"
+	ErrCodeUnsupportedPath  = "unsupported_path"
+	ErrCodeUnsupportedOp    = "unsupported_operation"
+	ErrCodeConflict         = "conflict"
+	ErrCodeUnavailable      = "unavailable"
+	ErrCodeRateLimited      = "rate_limited"
+	ErrCodeInternal         = "internal"
+)
+
+// VaultError is an error carrying a machine readable code, along with
+// hints on how it is surfaced over HTTP. Sentinel errors defined as a
+// *VaultError can still be compared by identity.
+type VaultError struct {
+	Code       string // Machine readable code, one of the ErrCode constants
+	Message    string // Human readable message returned as the error
+	HTTPStatus int    // Status code of the HTTP response
+	Retryable  bool   // Whether the request may succeed when retried
+}
+
+func (e *VaultError) Error() string {
+	return e.Message
+}
+
+// NewVaultError returns a VaultError with the given code and status
+func NewVaultError(code string, status int, retryable bool, format string, args ...interface{}) *VaultError {
+	return &VaultError{
+		Code:       code,
+		Message:    fmt.Sprintf(format, args...),
+		HTTPStatus: status,
+		Retryable:  retryable,
+	}
+}
+
+// NewInvalidRequest returns an error for a malformed or invalid request
+func NewInvalidRequest(format string, args ...interface{}) *VaultError {
+	return NewVaultError(ErrCodeInvalidRequest, http.StatusBadRequest, false, format, args...)
+}
+
+// NewPermissionDenied returns an error for a request that is not authorized
+func NewPermissionDenied(format string, args ...interface{}) *VaultError {
+	return NewVaultError(ErrCodePermissionDenied, http.StatusForbidden, false, format, args...)
+}
+
+// NewConflict returns an error for a request conflicting with existing state
+func NewConflict(format string, args ...interface{}) *VaultError {
+	return NewVaultError(ErrCodeConflict, http.StatusConflict, false, format, args...)
+}
+
+// NewUnavailable returns an error for a request that cannot be served
+// for now but may succeed when retried
+func NewUnavailable(format string, args ...interface{}) *VaultError {
+	return NewVaultError(ErrCodeUnavailable, http.StatusServiceUnavailable, true, format, args...)
+}
+
+// NewRateLimited returns an error for a request rejected by a rate limit
+func NewRateLimited(format string, args ...interface{}) *VaultError {
+	return NewVaultError(ErrCodeRateLimited, 429, true, format, args...)
+}
@@ -17,6 +17,11 @@ import (
 	"github.com/hashicorp/errwrap"
 )
 
+const (
+	// consulMaxValueSize is the largest value the Consul KV store accepts
+	consulMaxValueSize = 512 * 1024
+)
+
 // ConsulBackend is a physical backend that stores data at specific
 // prefix within Consul. It is used for most production situations as
 // it allows Vault to run on multiple machines in a highly-available manner.
@@ -27,9 +32,9 @@ type ConsulBackend struct {
 	permitPool *PermitPool
 }
 
-// newConsulBackend constructs a Consul backend using the given API client
+// NewConsulBackend constructs a Consul backend using the given API client
 // and the prefix in the KV store.
-func newConsulBackend(conf map[string]string) (Backend, error) {
+func NewConsulBackend(conf map[string]string) (Backend, error) {
 	// Get the path in Consul
 	path, ok := conf["path"]
 	if !ok {
@@ -141,6 +146,13 @@ func setupTLSConfig(conf map[string]string) (*tls.Config, error) {
 // Put is used to insert or update an entry
 func (c *ConsulBackend) Put(entry *Entry) error {
 	defer metrics.MeasureSince([]string{"consul", "put"}, time.Now())
+
+	// Fail with a clear error rather than the rejection by Consul
+	if len(entry.Value) > consulMaxValueSize {
+		return fmt.Errorf("value of key '%s' is %d bytes, exceeding the Consul limit of %d bytes",
+			entry.Key, len(entry.Value), consulMaxValueSize)
+	}
+
 	pair := &api.KVPair{
 		Key:   c.path + entry.Key,
 		Value: entry.Value,
@@ -1,14 +1,161 @@
 package physical
 
 import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/hashicorp/consul/api"
 )
 
+// testConsulKV serves the subset of the Consul KV HTTP API used by the
+// backend from memory
+type testConsulKV struct {
+	sync.Mutex
+	data map[string][]byte
+}
+
+func (kv *testConsulKV) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	kv.Lock()
+	defer kv.Unlock()
+
+	key := strings.TrimPrefix(r.URL.Path, "/v1/kv/")
+	switch r.Method {
+	case "PUT":
+		value, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		kv.data[key] = value
+		w.Write([]byte("true"))
+	case "DELETE":
+		delete(kv.data, key)
+		w.Write([]byte("true"))
+	case "GET":
+		if _, ok := r.URL.Query()["keys"]; ok {
+			kv.keys(w, key, r.URL.Query().Get("separator"))
+			return
+		}
+		value, ok := kv.data[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode([]*api.KVPair{{Key: key, Value: value}})
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (kv *testConsulKV) keys(w http.ResponseWriter, prefix, separator string) {
+	seen := make(map[string]bool)
+	var keys []string
+	for key := range kv.data {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		rest := strings.TrimPrefix(key, prefix)
+		if separator != "" {
+			if idx := strings.Index(rest, separator); idx != -1 {
+				key = prefix + rest[:idx+len(separator)]
+			}
+		}
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	sort.Strings(keys)
+	json.NewEncoder(w).Encode(keys)
+}
+
+func testConsulFake(t *testing.T) (Backend, *testConsulKV, func()) {
+	kv := &testConsulKV{data: make(map[string][]byte)}
+	srv := httptest.NewServer(kv)
+	b, err := NewConsulBackend(map[string]string{
+		"address": strings.TrimPrefix(srv.URL, "http://"),
+		"path":    "/vault",
+	})
+	if err != nil {
+		srv.Close()
+		t.Fatalf("err: %s", err)
+	}
+	return b, kv, srv.Close
+}
+
+func TestConsulBackend_Fake(t *testing.T) {
+	b, kv, done := testConsulFake(t)
+	defer done()
+
+	testBackend(t, b)
+	testBackend_ListPrefix(t, b)
+
+	// The path is normalized and prefixes every key
+	if err := b.Put(&Entry{Key: "auth/foo/config", Value: []byte("bar")}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	kv.Lock()
+	_, ok := kv.data["vault/auth/foo/config"]
+	kv.Unlock()
+	if !ok {
+		t.Fatalf("bad: %v", kv.data)
+	}
+}
+
+func TestConsulBackend_LargeValue(t *testing.T) {
+	b, _, done := testConsulFake(t)
+	defer done()
+
+	// A large auth table round-trips byte for byte
+	value := make([]byte, consulMaxValueSize)
+	if _, err := rand.Read(value); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := b.Put(&Entry{Key: "core/auth", Value: value}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	out, err := b.Get("core/auth")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if out == nil || out.Key != "core/auth" || !bytes.Equal(out.Value, value) {
+		t.Fatalf("value changed")
+	}
+
+	// A value Consul would reject fails before it is sent
+	if err := b.Put(&Entry{Key: "core/auth", Value: append(value, 0)}); err == nil {
+		t.Fatalf("expected error")
+	}
+	out, err = b.Get("core/auth")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if out == nil || !bytes.Equal(out.Value, value) {
+		t.Fatalf("value changed")
+	}
+
+	// A missing key is not an error
+	out, err = b.Get("core/missing")
+	if err != nil || out != nil {
+		t.Fatalf("bad: %v %v", out, err)
+	}
+}
+
 func TestConsulBackend(t *testing.T) {
 	addr := os.Getenv("CONSUL_ADDR")
 	if addr == "" {
@@ -3,11 +3,19 @@ package physical
 import (
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"sync"
 )
 
+const (
+	// fileTempPrefix is the prefix of the temporary files written by Put
+	// before they are renamed into place. Entries are stored in files with
+	// a "_" prefix, so temporary files never collide with them.
+	fileTempPrefix = ".tmp"
+)
+
 // FileBackend is a physical backend that stores data on disk
 // at a given file path. It can be used for durable single server
 // situations, or to develop locally where durability is not critical.
@@ -21,6 +29,12 @@ type FileBackend struct {
 	l sync.Mutex
 }
 
+// NewFileBackend constructs a FileBackend storing data under the
+// given directory
+func NewFileBackend(path string) *FileBackend {
+	return &FileBackend{Path: path}
+}
+
 // newFileBackend constructs a Filebackend using the given directory
 func newFileBackend(conf map[string]string) (Backend, error) {
 	path, ok := conf["path"]
@@ -28,7 +42,7 @@ func newFileBackend(conf map[string]string) (Backend, error) {
 		return nil, fmt.Errorf("'path' must be set")
 	}
 
-	return &FileBackend{Path: path}, nil
+	return NewFileBackend(path), nil
 }
 
 func (b *FileBackend) Delete(k string) error {
@@ -83,17 +97,34 @@ func (b *FileBackend) Put(entry *Entry) error {
 		return err
 	}
 
-	// JSON encode the entry and write it
-	f, err := os.OpenFile(
-		filepath.Join(path, key),
-		os.O_CREATE|os.O_TRUNC|os.O_WRONLY,
-		0600)
+	// JSON encode the entry and write it to a temporary file, which is
+	// renamed over the entry once complete. A crash while writing leaves
+	// either the previous entry or the new one, never a partial entry.
+	f, err := ioutil.TempFile(path, fileTempPrefix)
 	if err != nil {
 		return err
 	}
-	defer f.Close()
+	tmp := f.Name()
 	enc := json.NewEncoder(f)
-	return enc.Encode(entry)
+	if err := enc.Encode(entry); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(path, key)); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
 }
 
 func (b *FileBackend) List(prefix string) ([]string, error) {
@@ -116,20 +147,25 @@ func (b *FileBackend) List(prefix string) ([]string, error) {
 	}
 	defer f.Close()
 
-	names, err := f.Readdirnames(-1)
+	infos, err := f.Readdir(-1)
 	if err != nil {
 		return nil, err
 	}
 
-	for i, name := range names {
-		if name[0] == '_' {
-			names[i] = name[1:]
-		} else {
-			names[i] = name + "/"
+	// Directories are key prefixes, while entries are files with a "_"
+	// prefix. Other files are temporary files of incomplete writes.
+	keys := make([]string, 0, len(infos))
+	for _, info := range infos {
+		name := info.Name()
+		switch {
+		case info.IsDir():
+			keys = append(keys, name+"/")
+		case name[0] == '_':
+			keys = append(keys, name[1:])
 		}
 	}
 
-	return names, nil
+	return keys, nil
 }
 
 func (b *FileBackend) path(k string) (string, string) {
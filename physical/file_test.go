@@ -3,6 +3,9 @@ package physical
 import (
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
 	"testing"
 )
 
@@ -23,3 +26,101 @@ func TestFileBackend(t *testing.T) {
 	testBackend(t, b)
 	testBackend_ListPrefix(t, b)
 }
+
+func TestFileBackend_Nested(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vault")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	b := NewFileBackend(dir)
+
+	keys := []string{
+		"auth/9c2e5e0a-6c3b-4c1a-8f0e-2d0f4b7e1a11/config",
+		"auth/9c2e5e0a-6c3b-4c1a-8f0e-2d0f4b7e1a11/role/foo",
+		"auth/1f0b8f66-0d2f-4a4e-9d55-7a9e0c3b2e22/config",
+		"core/auth",
+	}
+	for _, key := range keys {
+		if err := b.Put(&Entry{Key: key, Value: []byte(key)}); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+	for _, key := range keys {
+		out, err := b.Get(key)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if out == nil || string(out.Value) != key {
+			t.Fatalf("bad: %#v", out)
+		}
+	}
+
+	// Lists return the child keys and prefixes
+	for prefix, expect := range map[string][]string{
+		"":      {"auth/", "core/"},
+		"auth/": {"1f0b8f66-0d2f-4a4e-9d55-7a9e0c3b2e22/", "9c2e5e0a-6c3b-4c1a-8f0e-2d0f4b7e1a11/"},
+		"auth/9c2e5e0a-6c3b-4c1a-8f0e-2d0f4b7e1a11/": {"config", "role/"},
+	} {
+		out, err := b.List(prefix)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		sort.Strings(out)
+		if !reflect.DeepEqual(out, expect) {
+			t.Fatalf("%q: bad: %v", prefix, out)
+		}
+	}
+}
+
+func TestFileBackend_AtomicPut(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vault")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	b := NewFileBackend(dir)
+
+	if err := b.Put(&Entry{Key: "core/auth", Value: []byte("old")}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// A temporary file left by an interrupted write is ignored
+	tmp := filepath.Join(dir, "core", fileTempPrefix+"123")
+	if err := ioutil.WriteFile(tmp, []byte(`{"Key":"core/auth","Val`), 0600); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	out, err := b.Get("core/auth")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if out == nil || string(out.Value) != "old" {
+		t.Fatalf("bad: %#v", out)
+	}
+	keys, err := b.List("core/")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(keys, []string{"auth"}) {
+		t.Fatalf("bad: %v", keys)
+	}
+
+	// An overwrite replaces the entry and leaves no temporary file
+	if err := b.Put(&Entry{Key: "core/auth", Value: []byte("new")}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	out, err = b.Get("core/auth")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if out == nil || string(out.Value) != "new" {
+		t.Fatalf("bad: %#v", out)
+	}
+	names, err := filepath.Glob(filepath.Join(dir, "core", fileTempPrefix+"*"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(names) != 1 || names[0] != tmp {
+		t.Fatalf("bad: %v", names)
+	}
+}
@@ -0,0 +1,77 @@
+package physical
+
+import (
+	"fmt"
+)
+
+// MirrorBackend is used to wrap two physical backends, synchronously
+// mirroring every write to a secondary backend. Reads are served by the
+// primary backend, failing over to the secondary if the primary errors.
+type MirrorBackend struct {
+	primary   Backend
+	secondary Backend
+
+	// strict causes writes to fail if the secondary backend fails.
+	// Otherwise, only a failure of the primary backend is returned.
+	strict bool
+}
+
+// NewMirror returns a MirrorBackend writing to both the primary and
+// secondary backend. If strict is set, a write fails when the
+// secondary cannot be written.
+func NewMirror(primary, secondary Backend, strict bool) *MirrorBackend {
+	m := &MirrorBackend{
+		primary:   primary,
+		secondary: secondary,
+		strict:    strict,
+	}
+	return m
+}
+
+func (m *MirrorBackend) Put(entry *Entry) error {
+	if err := m.primary.Put(entry); err != nil {
+		return err
+	}
+	if err := m.secondary.Put(entry); err != nil && m.strict {
+		return fmt.Errorf("failed to mirror write to secondary: %v", err)
+	}
+	return nil
+}
+
+func (m *MirrorBackend) Get(key string) (*Entry, error) {
+	ent, err := m.primary.Get(key)
+	if err == nil {
+		return ent, nil
+	}
+
+	// Fail over to the secondary
+	ent, err2 := m.secondary.Get(key)
+	if err2 != nil {
+		return nil, fmt.Errorf("primary failed: %v; secondary failed: %v", err, err2)
+	}
+	return ent, nil
+}
+
+func (m *MirrorBackend) Delete(key string) error {
+	if err := m.primary.Delete(key); err != nil {
+		return err
+	}
+	if err := m.secondary.Delete(key); err != nil && m.strict {
+		return fmt.Errorf("failed to mirror delete to secondary: %v", err)
+	}
+	return nil
+}
+
+func (m *MirrorBackend) List(prefix string) ([]string, error) {
+	keys, err := m.primary.List(prefix)
+	if err == nil {
+		return keys, nil
+	}
+
+	// Fail over to the secondary
+	keys, err2 := m.secondary.List(prefix)
+	if err2 != nil {
+		return nil, fmt.Errorf("primary failed: %v; secondary failed: %v", err, err2)
+	}
+	return keys, nil
+}
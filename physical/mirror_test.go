@@ -0,0 +1,143 @@
+package physical
+
+import (
+	"errors"
+	"testing"
+)
+
+// faultyBackend fails every operation once broken is set
+type faultyBackend struct {
+	*InmemBackend
+	broken bool
+}
+
+func (f *faultyBackend) Put(entry *Entry) error {
+	if f.broken {
+		return errors.New("faulty backend")
+	}
+	return f.InmemBackend.Put(entry)
+}
+
+func (f *faultyBackend) Get(key string) (*Entry, error) {
+	if f.broken {
+		return nil, errors.New("faulty backend")
+	}
+	return f.InmemBackend.Get(key)
+}
+
+func (f *faultyBackend) Delete(key string) error {
+	if f.broken {
+		return errors.New("faulty backend")
+	}
+	return f.InmemBackend.Delete(key)
+}
+
+func (f *faultyBackend) List(prefix string) ([]string, error) {
+	if f.broken {
+		return nil, errors.New("faulty backend")
+	}
+	return f.InmemBackend.List(prefix)
+}
+
+func TestMirror(t *testing.T) {
+	mirror := NewMirror(NewInmem(), NewInmem(), true)
+	testBackend(t, mirror)
+	testBackend_ListPrefix(t, mirror)
+}
+
+func TestMirror_WritesBoth(t *testing.T) {
+	primary, secondary := NewInmem(), NewInmem()
+	mirror := NewMirror(primary, secondary, true)
+
+	ent := &Entry{
+		Key:   "foo",
+		Value: []byte("bar"),
+	}
+	if err := mirror.Put(ent); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for _, b := range []Backend{primary, secondary} {
+		out, err := b.Get("foo")
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if out == nil || string(out.Value) != "bar" {
+			t.Fatalf("bad: %#v", out)
+		}
+	}
+
+	if err := mirror.Delete("foo"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for _, b := range []Backend{primary, secondary} {
+		out, err := b.Get("foo")
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if out != nil {
+			t.Fatalf("bad: %#v", out)
+		}
+	}
+}
+
+func TestMirror_ReadFailover(t *testing.T) {
+	primary := &faultyBackend{InmemBackend: NewInmem()}
+	mirror := NewMirror(primary, NewInmem(), true)
+
+	ent := &Entry{
+		Key:   "foo/bar",
+		Value: []byte("baz"),
+	}
+	if err := mirror.Put(ent); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Reads should be served by the secondary
+	primary.broken = true
+	out, err := mirror.Get("foo/bar")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if out == nil || string(out.Value) != "baz" {
+		t.Fatalf("bad: %#v", out)
+	}
+	keys, err := mirror.List("foo/")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(keys) != 1 || keys[0] != "bar" {
+		t.Fatalf("bad: %v", keys)
+	}
+
+	// Writes must not silently skip the primary
+	if err := mirror.Put(ent); err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+func TestMirror_SecondaryFailure(t *testing.T) {
+	secondary := &faultyBackend{InmemBackend: NewInmem(), broken: true}
+	ent := &Entry{
+		Key:   "foo",
+		Value: []byte("bar"),
+	}
+
+	// Strict mirroring fails the write
+	strict := NewMirror(NewInmem(), secondary, true)
+	if err := strict.Put(ent); err == nil {
+		t.Fatalf("expected error")
+	}
+
+	// Otherwise only the primary is required
+	lax := NewMirror(NewInmem(), secondary, false)
+	if err := lax.Put(ent); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	out, err := lax.Get("foo")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if out == nil {
+		t.Fatalf("missing entry")
+	}
+}
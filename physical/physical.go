@@ -81,7 +81,7 @@ var BuiltinBackends = map[string]Factory{
 	"inmem": func(map[string]string) (Backend, error) {
 		return NewInmem(), nil
 	},
-	"consul":    newConsulBackend,
+	"consul":    NewConsulBackend,
 	"zookeeper": newZookeeperBackend,
 	"file":      newFileBackend,
 	"s3":        newS3Backend,
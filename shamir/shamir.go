@@ -10,6 +10,11 @@ const (
 	// when using Split on a secret. This is caused by appending
 	// a one byte tag to the share.
 	ShareOverhead = 1
+
+	// MaxShares is the maximum number of shares a secret can be split
+	// into, as each share is tagged with a distinct non-zero element
+	// of GF(256).
+	MaxShares = 255
 )
 
 // polynomial represents a polynomial of arbitrary degree
@@ -122,14 +127,14 @@ func Split(secret []byte, parts, threshold int) ([][]byte, error) {
 	if parts < threshold {
 		return nil, fmt.Errorf("parts cannot be less than threshold")
 	}
-	if parts > 255 {
-		return nil, fmt.Errorf("parts cannot exceed 255")
+	if parts > MaxShares {
+		return nil, fmt.Errorf("parts cannot exceed %d", MaxShares)
 	}
 	if threshold < 2 {
 		return nil, fmt.Errorf("threshold must be at least 2")
 	}
-	if threshold > 255 {
-		return nil, fmt.Errorf("threshold cannot exceed 255")
+	if threshold > MaxShares {
+		return nil, fmt.Errorf("threshold cannot exceed %d", MaxShares)
 	}
 	if len(secret) == 0 {
 		return nil, fmt.Errorf("cannot split an empty secret")
@@ -7,9 +7,10 @@ import (
 
 var (
 	// Policy lists are used to restrict what is eligible for an operation
-	anyPolicy     = []string{PathPolicyDeny}
-	readWriteSudo = []string{PathPolicyRead, PathPolicyWrite, PathPolicySudo}
-	writeSudo     = []string{PathPolicyWrite, PathPolicySudo}
+	anyPolicy         = []string{PathPolicyDeny}
+	listReadWriteSudo = []string{PathPolicyList, PathPolicyRead, PathPolicyWrite, PathPolicySudo}
+	readWriteSudo     = []string{PathPolicyRead, PathPolicyWrite, PathPolicySudo}
+	writeSudo         = []string{PathPolicyWrite, PathPolicySudo}
 
 	// permittedPolicyLevel is used to map each logical operation
 	// into the set of policies that allow the operation.
@@ -17,11 +18,12 @@ var (
 		logical.ReadOperation:     readWriteSudo,
 		logical.WriteOperation:    writeSudo,
 		logical.DeleteOperation:   writeSudo,
-		logical.ListOperation:     readWriteSudo,
+		logical.ListOperation:     listReadWriteSudo,
 		logical.HelpOperation:     anyPolicy,
 		logical.RevokeOperation:   writeSudo,
 		logical.RenewOperation:    writeSudo,
 		logical.RollbackOperation: writeSudo,
+		logical.RotateOperation:   writeSudo,
 	}
 )
 
@@ -36,6 +38,54 @@ type ACL struct {
 
 	// root is enabled if the "root" named policy is present.
 	root bool
+
+	// denyOverrides makes a deny rule matching a path win over every
+	// other rule, even more specific ones. It is set by default.
+	denyOverrides bool
+
+	// remoteAddr is the client address the rules restricted by CIDR are
+	// checked against. Without an address they never apply.
+	remoteAddr string
+}
+
+// pathRules are the rules of the policies for a single path. The rules
+// restricted by CIDR are kept apart from the merged unrestricted rule,
+// so that a restricted rule never replaces an unrestricted grant.
+type pathRules struct {
+	rule       *PathPolicy
+	restricted []*PathPolicy
+
+	// mfaMethods is the union of the MFA methods of the unrestricted
+	// rules, so that no policy can drop the requirement of another
+	mfaMethods []string
+}
+
+// forRemoteAddr returns the rule that applies to a request from the
+// given address, merging the unrestricted rule with the restricted
+// rules allowing the address. It is nil if none applies.
+func (r *pathRules) forRemoteAddr(remoteAddr string) *PathPolicy {
+	result := r.rule
+	for _, pp := range r.restricted {
+		if !pp.AllowsRemoteAddr(remoteAddr) {
+			continue
+		}
+		if result == nil || pp.TakesPrecedence(result) {
+			result = pp
+		}
+	}
+	return result
+}
+
+// mfaMethodsForRemoteAddr returns the union of the MFA methods of the
+// rules that apply to a request from the given address
+func (r *pathRules) mfaMethodsForRemoteAddr(remoteAddr string) []string {
+	methods := r.mfaMethods
+	for _, pp := range r.restricted {
+		if pp.AllowsRemoteAddr(remoteAddr) {
+			methods = strListUnion(methods, pp.MFAMethods)
+		}
+	}
+	return methods
 }
 
 // New is used to construct a policy based ACL from a set of policies.
@@ -45,6 +95,8 @@ func NewACL(policies []*Policy) (*ACL, error) {
 		exactRules: radix.New(),
 		globRules:  radix.New(),
 		root:       false,
+
+		denyOverrides: true,
 	}
 
 	// Inject each policy
@@ -64,23 +116,42 @@ func NewACL(policies []*Policy) (*ACL, error) {
 				tree = a.globRules
 			}
 
-			// Check for an existing policy
-			raw, ok := tree.Get(pp.Prefix)
-			if !ok {
-				tree.Insert(pp.Prefix, pp)
+			// Check for existing rules
+			var rules *pathRules
+			if raw, ok := tree.Get(pp.Prefix); ok {
+				rules = raw.(*pathRules)
+			} else {
+				rules = &pathRules{}
+				tree.Insert(pp.Prefix, rules)
+			}
+
+			// Rules restricted by CIDR are only merged per request
+			if len(pp.cidrs) != 0 {
+				rules.restricted = append(rules.restricted, pp)
 				continue
 			}
-			existing := raw.(*PathPolicy)
+
+			// Every MFA method required for the path applies
+			rules.mfaMethods = strListUnion(rules.mfaMethods, pp.MFAMethods)
 
 			// Check if this policy is takes precedence
-			if pp.TakesPrecedence(existing) {
-				tree.Insert(pp.Prefix, pp)
+			if rules.rule == nil || pp.TakesPrecedence(rules.rule) {
+				rules.rule = pp
 			}
 		}
 	}
 	return a, nil
 }
 
+// ForRemoteAddr returns a copy of the ACL for requests from the given
+// client address, to which the rules restricted by CIDR containing the
+// address also apply.
+func (a *ACL) ForRemoteAddr(remoteAddr string) *ACL {
+	acl := *a
+	acl.remoteAddr = remoteAddr
+	return &acl
+}
+
 // AllowOperation is used to check if the given operation is permitted
 func (a *ACL) AllowOperation(op logical.Operation, path string) bool {
 	// Fast-path root
@@ -88,29 +159,22 @@ func (a *ACL) AllowOperation(op logical.Operation, path string) bool {
 		return true
 	}
 
-	// Check if any policy level allows this operation
-	permitted := permittedPolicyLevels[op]
+	// Check if any policy level allows this operation, denying
+	// unknown operations
+	permitted, ok := permittedPolicyLevels[op]
+	if !ok {
+		return false
+	}
 	if permitted[0] == PathPolicyDeny {
 		return true
 	}
 
-	// Find an exact matching rule, look for glob if no match
-	var policy *PathPolicy
-	raw, ok := a.exactRules.Get(path)
-	if ok {
-		policy = raw.(*PathPolicy)
-		goto CHECK
-	}
-
-	// Find a glob rule, default deny if no match
-	_, raw, ok = a.globRules.LongestPrefix(path)
-	if !ok {
+	// Find the matching rule, default deny if no match
+	policy := a.pathPolicy(path)
+	if policy == nil {
 		return false
-	} else {
-		policy = raw.(*PathPolicy)
 	}
 
-CHECK:
 	// Check if the minimum permissions are met
 	for _, allowed := range permitted {
 		if allowed == policy.Policy {
@@ -120,6 +184,55 @@ CHECK:
 	return false
 }
 
+// AllowRemoteAddr checks if a request for the given path may come
+// from the given client address, based on the CIDR restrictions of
+// the matching rules.
+func (a *ACL) AllowRemoteAddr(path, remoteAddr string) bool {
+	// Fast-path root
+	if a.root {
+		return true
+	}
+
+	// Without a matching rule there is nothing restricting the address;
+	// the operation check denies the request anyways
+	rules := a.pathRules(path)
+	if rules == nil {
+		return true
+	}
+	return rules.forRemoteAddr(remoteAddr) != nil
+}
+
+// MFAMethods returns the names of the MFA methods a request for the
+// given path must satisfy. These are the methods required by any of the
+// matching rules, not only by the one taking precedence.
+func (a *ACL) MFAMethods(path string) []string {
+	// Fast-path root
+	if a.root {
+		return nil
+	}
+
+	rules := a.pathRules(path)
+	if rules == nil {
+		return nil
+	}
+	return rules.mfaMethodsForRemoteAddr(a.remoteAddr)
+}
+
+// FilterResponseData removes the fields of the response data for the
+// given path that are not exposed by the matching rule.
+func (a *ACL) FilterResponseData(path string, data map[string]interface{}) map[string]interface{} {
+	// Fast-path root
+	if a.root {
+		return data
+	}
+
+	policy := a.pathPolicy(path)
+	if policy == nil {
+		return data
+	}
+	return policy.FilterResponseData(data)
+}
+
 // RootPrivilege checks if the user has root level permission
 // to given path. This requires that the user be root, or that
 // sudo privilege is available on that path.
@@ -129,23 +242,59 @@ func (a *ACL) RootPrivilege(path string) bool {
 		return true
 	}
 
-	// Find an exact matching rule, look for glob if no match
-	var policy *PathPolicy
-	raw, ok := a.exactRules.Get(path)
-	if ok {
-		policy = raw.(*PathPolicy)
-		goto CHECK
-	}
-
 	// Check the rules for a match, default deny if no match
-	_, raw, ok = a.globRules.LongestPrefix(path)
-	if !ok {
+	policy := a.pathPolicy(path)
+	if policy == nil {
 		return false
-	} else {
-		policy = raw.(*PathPolicy)
 	}
 
-CHECK:
 	// Check the policy level
 	return policy.Policy == PathPolicySudo
 }
+
+// pathPolicy returns the rule that applies to the given path for the
+// address of the ACL. The rules of an exact match are used first,
+// otherwise those of the longest glob.
+func (a *ACL) pathPolicy(path string) *PathPolicy {
+	if a.denyOverrides {
+		if deny := a.denyPolicy(path); deny != nil {
+			return deny
+		}
+	}
+	rules := a.pathRules(path)
+	if rules == nil {
+		return nil
+	}
+	return rules.forRemoteAddr(a.remoteAddr)
+}
+
+// pathRules returns the rules of the exact match of the path, otherwise
+// those of the longest glob, or nil if there is no match
+func (a *ACL) pathRules(path string) *pathRules {
+	if raw, ok := a.exactRules.Get(path); ok {
+		return raw.(*pathRules)
+	}
+	if _, raw, ok := a.globRules.LongestPrefix(path); ok {
+		return raw.(*pathRules)
+	}
+	return nil
+}
+
+// denyPolicy returns a deny rule applying to the path, either exactly or
+// as a glob of any length, or nil if there is none
+func (a *ACL) denyPolicy(path string) *PathPolicy {
+	if raw, ok := a.exactRules.Get(path); ok {
+		if pp := raw.(*pathRules).forRemoteAddr(a.remoteAddr); pp != nil && pp.Policy == PathPolicyDeny {
+			return pp
+		}
+	}
+	var deny *PathPolicy
+	a.globRules.WalkPath(path, func(prefix string, raw interface{}) bool {
+		if pp := raw.(*pathRules).forRemoteAddr(a.remoteAddr); pp != nil && pp.Policy == PathPolicyDeny {
+			deny = pp
+			return true
+		}
+		return false
+	})
+	return deny
+}
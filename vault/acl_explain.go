@@ -0,0 +1,143 @@
+package vault
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+// AccessExplanation describes how the ACL of a token decides on an
+// operation on a path
+type AccessExplanation struct {
+	// Policies are the names of the policies of the token
+	Policies []string
+
+	// Matches are the rules of the policies that match the path
+	Matches []*RuleMatch
+
+	// Allowed is the resulting decision, and DecidingRule the rule it
+	// is based on. DecidingRule is nil if no rule was needed, such as for
+	// the root policy, or if no rule matched.
+	Allowed      bool
+	DecidingRule *RuleMatch
+
+	// Reason explains the decision
+	Reason string
+}
+
+// RuleMatch is a rule of a policy matching a path
+type RuleMatch struct {
+	PolicyName string // Name of the policy containing the rule
+	Prefix     string // Path or prefix of the rule
+	Glob       bool   // Whether the rule matches by prefix
+	Policy     string // Capability of the rule, such as "read" or "deny"
+
+	rule *PathPolicy
+}
+
+// String returns the rule as it appears in the policy
+func (r *RuleMatch) String() string {
+	prefix := r.Prefix
+	if r.Glob {
+		prefix += "*"
+	}
+	return fmt.Sprintf("path %q { policy = %q } in policy '%s'", prefix, r.Policy, r.PolicyName)
+}
+
+// ExplainAccess is used to explain why the given token is allowed or
+// denied the operation on the path. The CIDR restrictions of the token
+// are not considered and rules restricted by CIDR never apply, as they
+// depend on the client.
+func (c *Core) ExplainAccess(token, path string, op logical.Operation) (*AccessExplanation, error) {
+	c.stateLock.RLock()
+	defer c.stateLock.RUnlock()
+	if c.sealed {
+		return nil, ErrSealed
+	}
+	if c.standby {
+		return nil, ErrStandby
+	}
+
+	if _, ok := permittedPolicyLevels[op]; !ok {
+		return nil, logical.NewInvalidRequest("unknown operation '%s'", op)
+	}
+
+	te, err := c.tokenStore.Lookup(token)
+	if err != nil {
+		c.logger.Printf("[ERR] core: failed to lookup token: %v", err)
+		return nil, ErrInternalError
+	}
+	if te == nil {
+		return nil, logical.NewInvalidRequest("token not found")
+	}
+
+	// Collect the rules matching the path
+	explanation := &AccessExplanation{Policies: te.Policies}
+	var policies []*Policy
+	for _, name := range te.Policies {
+		policy, err := c.policyStore.GetPolicy(name)
+		if err != nil {
+			return nil, err
+		}
+		if policy == nil {
+			continue
+		}
+		policies = append(policies, policy)
+
+		for _, pp := range policy.Paths {
+			if pp.Prefix != path && !(pp.Glob && strings.HasPrefix(path, pp.Prefix)) {
+				continue
+			}
+			explanation.Matches = append(explanation.Matches, &RuleMatch{
+				PolicyName: name,
+				Prefix:     pp.Prefix,
+				Glob:       pp.Glob,
+				Policy:     pp.Policy,
+				rule:       pp,
+			})
+		}
+	}
+
+	// Decide using the same ACL as requests
+	acl, err := NewACL(policies)
+	if err != nil {
+		return nil, err
+	}
+	acl.denyOverrides = !c.policyStore.disableDenyOverrides
+	if acl.root {
+		explanation.Allowed = true
+		explanation.Reason = "the root policy allows every operation"
+		return explanation, nil
+	}
+
+	if deciding := acl.pathPolicy(path); deciding != nil {
+		for _, match := range explanation.Matches {
+			if match.rule == deciding {
+				explanation.DecidingRule = match
+				break
+			}
+		}
+	}
+
+	switch {
+	case c.router.RootPath(path) && !acl.RootPrivilege(path):
+		explanation.Reason = "the path is root protected and requires the sudo policy"
+	case !acl.AllowOperation(op, path):
+		if explanation.DecidingRule == nil {
+			explanation.Reason = "no rule matches the path"
+		} else {
+			explanation.Reason = fmt.Sprintf("the %s operation is not permitted by %s",
+				op, explanation.DecidingRule)
+		}
+	default:
+		explanation.Allowed = true
+		if permittedPolicyLevels[op][0] == PathPolicyDeny {
+			explanation.Reason = fmt.Sprintf("the %s operation is always permitted", op)
+		} else {
+			explanation.Reason = fmt.Sprintf("the %s operation is permitted by %s",
+				op, explanation.DecidingRule)
+		}
+	}
+	return explanation, nil
+}
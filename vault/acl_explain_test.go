@@ -0,0 +1,124 @@
+package vault
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+const explainDevPolicy = `
+path "secret/*" {
+	policy = "write"
+}
+path "secret/prod/*" {
+	policy = "read"
+}
+`
+
+const explainLockdownPolicy = `
+path "secret/prod/*" {
+	policy = "deny"
+}
+`
+
+func testExplainCore(t *testing.T) (*Core, string, string) {
+	c, _, root := TestCoreUnsealed(t)
+	for name, rules := range map[string]string{
+		"dev":      explainDevPolicy,
+		"lockdown": explainLockdownPolicy,
+	} {
+		p, err := Parse(rules)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		p.Name = name
+		if err := c.policyStore.SetPolicy(p); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+
+	ent := &TokenEntry{Path: "test", Policies: []string{"dev", "lockdown"}}
+	if err := c.tokenStore.create(ent); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	return c, root, ent.ID
+}
+
+func TestCore_ExplainAccess(t *testing.T) {
+	c, _, token := testExplainCore(t)
+
+	// The deny rule of lockdown overrides the read rule of dev
+	out, err := c.ExplainAccess(token, "secret/prod/db", logical.ReadOperation)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if out.Allowed {
+		t.Fatalf("should be denied: %#v", out)
+	}
+	if len(out.Policies) != 2 || len(out.Matches) != 3 {
+		t.Fatalf("bad: %#v", out)
+	}
+	if r := out.DecidingRule; r == nil || r.PolicyName != "lockdown" || r.Prefix != "secret/prod/" || r.Policy != PathPolicyDeny {
+		t.Fatalf("bad: %#v", out.DecidingRule)
+	}
+	if !strings.Contains(out.Reason, "lockdown") {
+		t.Fatalf("bad: %s", out.Reason)
+	}
+
+	// Outside of the locked down prefix the write rule of dev applies
+	out, err = c.ExplainAccess(token, "secret/dev/db", logical.WriteOperation)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !out.Allowed || len(out.Matches) != 1 {
+		t.Fatalf("bad: %#v", out)
+	}
+	if r := out.DecidingRule; r == nil || r.PolicyName != "dev" || r.Prefix != "secret/" || r.Policy != PathPolicyWrite {
+		t.Fatalf("bad: %#v", out.DecidingRule)
+	}
+
+	// Without a matching rule access is denied
+	out, err = c.ExplainAccess(token, "other/foo", logical.ReadOperation)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if out.Allowed || out.DecidingRule != nil || len(out.Matches) != 0 {
+		t.Fatalf("bad: %#v", out)
+	}
+
+	// The explanation agrees with request handling
+	req := logical.TestRequest(t, logical.ReadOperation, "secret/prod/db")
+	req.ClientToken = token
+	if _, err := c.HandleRequest(req); err != logical.ErrPermissionDenied {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestCore_ExplainAccess_Root(t *testing.T) {
+	c, root, _ := testExplainCore(t)
+
+	out, err := c.ExplainAccess(root, "sys/raw/foo", logical.ReadOperation)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !out.Allowed || out.DecidingRule != nil {
+		t.Fatalf("bad: %#v", out)
+	}
+
+	if _, err := c.ExplainAccess("missing", "secret/foo", logical.ReadOperation); err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+func TestCore_ExplainAccess_RootPath(t *testing.T) {
+	c, _, token := testExplainCore(t)
+
+	out, err := c.ExplainAccess(token, "sys/raw/foo", logical.ReadOperation)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if out.Allowed || !strings.Contains(out.Reason, "root protected") {
+		t.Fatalf("bad: %#v", out)
+	}
+}
@@ -1,6 +1,8 @@
 package vault
 
 import (
+	"reflect"
+	"sort"
 	"testing"
 
 	"github.com/hashicorp/vault/logical"
@@ -22,6 +24,20 @@ func TestACL_Root(t *testing.T) {
 	}
 }
 
+func TestACL_UnknownOperation(t *testing.T) {
+	policy, err := Parse(aclPolicy)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	acl, err := NewACL([]*Policy{policy})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if acl.AllowOperation(logical.Operation("bogus"), "dev/foo") {
+		t.Fatalf("unexpected permission")
+	}
+}
+
 func TestACL_Single(t *testing.T) {
 	policy, err := Parse(aclPolicy)
 	if err != nil {
@@ -58,6 +74,10 @@ func TestACL_Single(t *testing.T) {
 		{logical.ReadOperation, "prod/foo", true},
 		{logical.ListOperation, "prod/foo", true},
 		{logical.ReadOperation, "prod/aws/foo", false},
+
+		{logical.ListOperation, "index/foo", true},
+		{logical.ReadOperation, "index/foo", false},
+		{logical.WriteOperation, "index/foo", false},
 	}
 
 	for _, tc := range tcases {
@@ -86,6 +106,268 @@ func TestACL_Layered(t *testing.T) {
 	testLayeredACL(t, acl)
 }
 
+func TestACL_DenyOverrides(t *testing.T) {
+	allow, err := Parse(aclPolicyDenyOverridesAllow)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	deny, err := Parse(aclPolicyDenyOverridesDeny)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	acl, err := NewACL([]*Policy{allow, deny})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	type tcase struct {
+		op             logical.Operation
+		path           string
+		expectDefault  bool
+		expectDisabled bool
+	}
+	tcases := []tcase{
+		// Allow and deny on the same path
+		{logical.ReadOperation, "secret/foo", false, false},
+		{logical.WriteOperation, "secret/foo", false, false},
+
+		// A more specific allow below a denied glob
+		{logical.ReadOperation, "prod/app/config", false, true},
+		{logical.ReadOperation, "prod/app/other", false, true},
+		{logical.ReadOperation, "prod/db", false, false},
+
+		// A deny glob below an allowed one
+		{logical.ReadOperation, "dev/foo", true, true},
+		{logical.ReadOperation, "dev/secret/foo", false, false},
+
+		// Operations permitted regardless of the rules
+		{logical.HelpOperation, "prod/db", true, true},
+	}
+
+	for _, tc := range tcases {
+		acl.denyOverrides = true
+		if out := acl.AllowOperation(tc.op, tc.path); out != tc.expectDefault {
+			t.Fatalf("bad: case %#v: %v", tc, out)
+		}
+		acl.denyOverrides = false
+		if out := acl.AllowOperation(tc.op, tc.path); out != tc.expectDisabled {
+			t.Fatalf("bad without overrides: case %#v: %v", tc, out)
+		}
+	}
+}
+
+func TestACL_RemoteAddr(t *testing.T) {
+	policy, err := Parse(aclPolicyCIDR)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	acl, err := NewACL([]*Policy{policy})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	type tcase struct {
+		path       string
+		remoteAddr string
+		expect     bool
+	}
+	tcases := []tcase{
+		{"dev/foo", "", true},
+		{"dev/foo", "192.168.1.1", true},
+		{"prod/foo", "10.1.2.3", true},
+		{"prod/foo", "172.16.0.1", true},
+		{"prod/foo", "192.168.1.1", false},
+		{"prod/foo", "", false},
+		{"prod/foo", "not-an-ip", false},
+	}
+
+	for _, tc := range tcases {
+		out := acl.AllowRemoteAddr(tc.path, tc.remoteAddr)
+		if out != tc.expect {
+			t.Fatalf("bad: case %#v: %v", tc, out)
+		}
+	}
+}
+
+func TestACL_RemoteAddr_Merge(t *testing.T) {
+	policy1, err := Parse(aclPolicyCIDR)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	policy2, err := Parse(aclPolicyCIDR2)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	acl, err := NewACL([]*Policy{policy1, policy2})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	type tcase struct {
+		op         logical.Operation
+		path       string
+		remoteAddr string
+		expect     bool
+	}
+	tcases := []tcase{
+		// The restricted sudo does not replace the unrestricted write
+		{logical.WriteOperation, "dev/foo", "", true},
+		{logical.WriteOperation, "dev/foo", "192.168.1.1", true},
+		{logical.WriteOperation, "dev/foo", "10.1.2.3", true},
+
+		// Only restricted rules match
+		{logical.ReadOperation, "prod/foo", "10.1.2.3", true},
+		{logical.WriteOperation, "prod/foo", "10.1.2.3", false},
+		{logical.WriteOperation, "prod/foo", "192.168.1.1", true},
+		{logical.ReadOperation, "prod/foo", "8.8.8.8", false},
+		{logical.ReadOperation, "prod/foo", "", false},
+	}
+
+	for _, tc := range tcases {
+		out := acl.ForRemoteAddr(tc.remoteAddr).AllowOperation(tc.op, tc.path)
+		if out != tc.expect {
+			t.Fatalf("bad: case %#v: %v", tc, out)
+		}
+	}
+
+	// Sudo only applies from the restricted network
+	if acl.ForRemoteAddr("192.168.1.1").RootPrivilege("dev/foo") {
+		t.Fatalf("unexpected root")
+	}
+	if !acl.ForRemoteAddr("10.1.2.3").RootPrivilege("dev/foo") {
+		t.Fatalf("expected root")
+	}
+	if !acl.AllowRemoteAddr("dev/foo", "192.168.1.1") {
+		t.Fatalf("expected address allowed")
+	}
+	if acl.AllowRemoteAddr("prod/foo", "8.8.8.8") {
+		t.Fatalf("unexpected address allowed")
+	}
+}
+
+func TestACL_FilterResponseData(t *testing.T) {
+	policy, err := Parse(aclPolicyResponseFields)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	acl, err := NewACL([]*Policy{policy})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	data := map[string]interface{}{
+		"username": "foo",
+		"password": "bar",
+		"ttl":      3600,
+	}
+
+	type tcase struct {
+		path   string
+		expect []string
+	}
+	tcases := []tcase{
+		{"dev/foo", []string{"password", "ttl", "username"}},
+		{"prod/foo", []string{"ttl", "username"}},
+		{"stage/foo", []string{"username"}},
+		{"stage/bar", []string{"password", "username"}},
+	}
+
+	for _, tc := range tcases {
+		out := acl.FilterResponseData(tc.path, data)
+		var keys []string
+		for k := range out {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		if !reflect.DeepEqual(keys, tc.expect) {
+			t.Fatalf("bad: case %#v: %v", tc, keys)
+		}
+	}
+
+	// The original data must not be modified
+	if len(data) != 3 {
+		t.Fatalf("bad: %#v", data)
+	}
+}
+
+func TestParse_InvalidCIDR(t *testing.T) {
+	_, err := Parse(`path "prod/*" { policy = "read" cidr_list = ["10.0.0.0/33"] }`)
+	if err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+func TestParse_InvalidMFAMethod(t *testing.T) {
+	_, err := Parse(`path "prod/*" { policy = "read" mfa_methods = ["sms"] }`)
+	if err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+func TestACL_MFAMethods(t *testing.T) {
+	policy, err := Parse(`
+path "dev/*" {
+	policy = "write"
+}
+path "prod/*" {
+	policy = "read"
+	mfa_methods = ["totp"]
+}
+`)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	acl, err := NewACL([]*Policy{policy})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if methods := acl.MFAMethods("dev/foo"); len(methods) != 0 {
+		t.Fatalf("bad: %v", methods)
+	}
+	if methods := acl.MFAMethods("prod/foo"); !reflect.DeepEqual(methods, []string{"totp"}) {
+		t.Fatalf("bad: %v", methods)
+	}
+}
+
+func TestACL_MFAMethods_Union(t *testing.T) {
+	mfa, err := Parse(`
+path "prod/*" {
+	policy = "read"
+	mfa_methods = ["totp"]
+}
+`)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	sudo, err := Parse(`
+path "prod/*" {
+	policy = "sudo"
+}
+`)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The rule taking precedence does not drop the MFA requirement,
+	// whatever the order of the policies
+	for _, policies := range [][]*Policy{{mfa, sudo}, {sudo, mfa}} {
+		acl, err := NewACL(policies)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if !acl.AllowOperation(logical.WriteOperation, "prod/foo") {
+			t.Fatalf("sudo rule should apply")
+		}
+		if methods := acl.MFAMethods("prod/foo"); !reflect.DeepEqual(methods, []string{"totp"}) {
+			t.Fatalf("bad: %v", methods)
+		}
+	}
+}
+
 func testLayeredACL(t *testing.T, acl *ACL) {
 	if acl.RootPrivilege("sys/mount/foo") {
 		t.Fatalf("unexpected root")
@@ -113,8 +395,13 @@ func testLayeredACL(t *testing.T, acl *ACL) {
 		{logical.ListOperation, "prod/foo", true},
 		{logical.ReadOperation, "prod/aws/foo", false},
 
+		// The deny glob wins over the more specific sudo rule
 		{logical.ReadOperation, "sys/status", false},
-		{logical.WriteOperation, "sys/seal", true},
+		{logical.WriteOperation, "sys/seal", false},
+
+		{logical.ListOperation, "index/foo", false},
+		{logical.ListOperation, "index/bar", true},
+		{logical.ReadOperation, "index/bar", true},
 	}
 
 	for _, tc := range tcases {
@@ -148,6 +435,9 @@ path "prod/aws/*" {
 path "sys/*" {
 	policy = "deny"
 }
+path "index/*" {
+	policy = "list"
+}
 `
 
 var aclPolicy2 = `
@@ -164,4 +454,78 @@ path "prod/*" {
 path "sys/seal" {
 	policy = "write"
 }
+path "index/foo" {
+	policy = "deny"
+}
+path "index/bar" {
+	policy = "read"
+}
+`
+
+var aclPolicyCIDR = `
+name = "cidr"
+path "dev/*" {
+	policy = "write"
+}
+path "prod/*" {
+	policy = "read"
+	cidr_list = ["10.0.0.0/8", "172.16.0.0/12"]
+}
+`
+
+var aclPolicyCIDR2 = `
+name = "cidr2"
+path "dev/*" {
+	policy = "sudo"
+	cidr_list = ["10.0.0.0/8"]
+}
+path "prod/*" {
+	policy = "write"
+	cidr_list = ["192.168.0.0/16"]
+}
+`
+
+var aclPolicyResponseFields = `
+name = "fields"
+path "dev/*" {
+	policy = "read"
+}
+path "prod/*" {
+	policy = "read"
+	denied_response_fields = ["password"]
+}
+path "stage/*" {
+	policy = "read"
+	allowed_response_fields = ["username"]
+}
+path "stage/bar" {
+	policy = "read"
+	allowed_response_fields = ["username", "password"]
+}
+`
+
+var aclPolicyDenyOverridesAllow = `
+name = "allow"
+path "secret/foo" {
+	policy = "write"
+}
+path "prod/app/*" {
+	policy = "read"
+}
+path "dev/*" {
+	policy = "write"
+}
+`
+
+var aclPolicyDenyOverridesDeny = `
+name = "deny"
+path "secret/foo" {
+	policy = "deny"
+}
+path "prod/*" {
+	policy = "deny"
+}
+path "dev/secret/*" {
+	policy = "deny"
+}
 `
@@ -74,6 +74,7 @@ func (c *Core) enableAudit(entry *MountEntry) error {
 	newTable := c.audit.ShallowClone()
 	newTable.Entries = append(newTable.Entries, entry)
 	if err := c.persistAudit(newTable); err != nil {
+		cleanupAuditBackend(backend)
 		return errors.New("failed to update audit table")
 	}
 	c.audit = newTable
@@ -182,6 +183,7 @@ func (c *Core) setupAudits() error {
 			c.logger.Printf(
 				"[ERR] core: failed to create audit entry %s: %v",
 				entry.Path, err)
+			broker.DeregisterAll()
 			return errLoadAuditFailed
 		}
 
@@ -195,11 +197,33 @@ func (c *Core) setupAudits() error {
 // teardownAudit is used before we seal the vault to reset the audit
 // backends to their unloaded state. This is reversed by loadAudits.
 func (c *Core) teardownAudits() error {
+	if c.auditBroker != nil {
+		c.auditBroker.DeregisterAll()
+	}
 	c.audit = nil
 	c.auditBroker = nil
 	return nil
 }
 
+// auditRequest checks if a request is recorded in the audit log. Read
+// and list operations are skipped if auditing reads is disabled for
+// their mount, or globally if the mount does not override it. Other
+// operations and logins are always audited.
+func (c *Core) auditRequest(req *logical.Request) bool {
+	switch req.Operation {
+	case logical.ReadOperation, logical.ListOperation:
+	default:
+		return true
+	}
+	if c.router.LoginPath(req.Path) {
+		return true
+	}
+	if me := c.router.MatchingMountEntry(req.Path); me != nil && me.Config.AuditReads != nil {
+		return *me.Config.AuditReads
+	}
+	return !c.disableAuditReads
+}
+
 // newAuditBackend is used to create and configure a new audit backend by name
 func (c *Core) newAuditBackend(t string, view logical.Storage, conf map[string]string) (audit.Backend, error) {
 	f, ok := c.auditBackends[t]
@@ -261,9 +285,30 @@ func (a *AuditBroker) Register(name string, b audit.Backend, v *BarrierView) {
 func (a *AuditBroker) Deregister(name string) {
 	a.l.Lock()
 	defer a.l.Unlock()
+	if be, ok := a.backends[name]; ok {
+		cleanupAuditBackend(be.backend)
+	}
 	delete(a.backends, name)
 }
 
+// DeregisterAll is used to remove every audit backend from the broker
+func (a *AuditBroker) DeregisterAll() {
+	a.l.Lock()
+	defer a.l.Unlock()
+	for name, be := range a.backends {
+		cleanupAuditBackend(be.backend)
+		delete(a.backends, name)
+	}
+}
+
+// cleanupAuditBackend is used to release the resources of a removed
+// audit backend, if it holds any
+func cleanupAuditBackend(b audit.Backend) {
+	if c, ok := b.(audit.Cleaner); ok {
+		c.Cleanup()
+	}
+}
+
 // IsRegistered is used to check if a given audit backend is registered
 func (a *AuditBroker) IsRegistered(name string) bool {
 	a.l.RLock()
@@ -272,6 +317,18 @@ func (a *AuditBroker) IsRegistered(name string) bool {
 	return ok
 }
 
+// GetHash returns the given value hashed by the audit backend mounted at
+// the given path
+func (a *AuditBroker) GetHash(name string, input string) (string, error) {
+	a.l.RLock()
+	defer a.l.RUnlock()
+	be, ok := a.backends[name]
+	if !ok {
+		return "", fmt.Errorf("unknown audit backend %s", name)
+	}
+	return be.backend.GetHash(input), nil
+}
+
 // LogRequest is used to ensure all the audit backends have an opportunity to
 // log the given request and that *at least one* succeeds.
 func (a *AuditBroker) LogRequest(auth *logical.Auth, req *logical.Request, outerErr error) (reterr error) {
@@ -26,6 +26,8 @@ type NoopAudit struct {
 	RespReq  []*logical.Request
 	Resp     []*logical.Response
 	RespErrs []error
+
+	Cleanups int
 }
 
 func (n *NoopAudit) LogRequest(a *logical.Auth, r *logical.Request, err error) error {
@@ -43,6 +45,14 @@ func (n *NoopAudit) LogResponse(a *logical.Auth, r *logical.Request, re *logical
 	return n.RespErr
 }
 
+func (n *NoopAudit) GetHash(data string) string {
+	return audit.HashString(n.Config.Salt, data)
+}
+
+func (n *NoopAudit) Cleanup() {
+	n.Cleanups++
+}
+
 func TestCore_EnableAudit(t *testing.T) {
 	c, key, _ := TestCoreUnsealed(t)
 	c.auditBackends["noop"] = func(config *audit.BackendConfig) (audit.Backend, error) {
@@ -151,6 +161,44 @@ func TestCore_DisableAudit(t *testing.T) {
 	}
 }
 
+func TestCore_DisableAudit_Cleanup(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+	backends := make(map[string]*NoopAudit)
+	c.auditBackends["noop"] = func(config *audit.BackendConfig) (audit.Backend, error) {
+		b := &NoopAudit{
+			Config: config,
+		}
+		backends[config.Config["name"]] = b
+		return b, nil
+	}
+	for _, name := range []string{"foo", "bar"} {
+		me := &MountEntry{
+			Path:    name,
+			Type:    "noop",
+			Options: map[string]string{"name": name},
+		}
+		if err := c.enableAudit(me); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+
+	// Disabling a backend cleans it up
+	if err := c.disableAudit("foo"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if backends["foo"].Cleanups != 1 || backends["bar"].Cleanups != 0 {
+		t.Fatalf("bad: %d %d", backends["foo"].Cleanups, backends["bar"].Cleanups)
+	}
+
+	// Sealing cleans up the remaining ones
+	if err := c.Seal(root); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if backends["foo"].Cleanups != 1 || backends["bar"].Cleanups != 1 {
+		t.Fatalf("bad: %d %d", backends["foo"].Cleanups, backends["bar"].Cleanups)
+	}
+}
+
 func TestCore_DefaultAuditTable(t *testing.T) {
 	c, key, _ := TestCoreUnsealed(t)
 	verifyDefaultAuditTable(t, c.audit)
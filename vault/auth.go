@@ -5,7 +5,9 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
+	"github.com/hashicorp/go-multierror"
 	"github.com/hashicorp/uuid"
 	"github.com/hashicorp/vault/logical"
 )
@@ -20,8 +22,26 @@ const (
 	// barrier view for the credential backends.
 	credentialBarrierPrefix = "auth/"
 
+	// credentialPathBarrierPrefix is the prefix to the mount path used in
+	// the barrier view for credential backends with a human-readable
+	// prefix. It is kept apart from the UUID prefixes, so that a path
+	// can never name the storage of another backend.
+	credentialPathBarrierPrefix = credentialBarrierPrefix + "path/"
+
 	// credentialRoutePrefix is the mount prefix used for the router
 	credentialRoutePrefix = "auth/"
+
+	// backendInitTimeout is the default time a credential backend may
+	// take to initialize before it fails permanently
+	backendInitTimeout = 5 * time.Minute
+
+	// backendInitRetryInterval is the time between attempts to
+	// initialize a credential backend
+	backendInitRetryInterval = 5 * time.Second
+
+	// backendInitCallTimeout is the time a single attempt to initialize
+	// a credential backend may take before it is retried
+	backendInitCallTimeout = 30 * time.Second
 )
 
 var (
@@ -29,8 +49,31 @@ var (
 	errLoadAuthFailed = errors.New("failed to setup auth table")
 )
 
-// enableCredential is used to enable a new credential backend
-func (c *Core) enableCredential(entry *MountEntry) error {
+// ListAuth returns a copy of the entries of the enabled credential
+// backends, including the token backend.
+func (c *Core) ListAuth() ([]*MountEntry, error) {
+	c.stateLock.RLock()
+	defer c.stateLock.RUnlock()
+	if c.sealed {
+		return nil, ErrSealed
+	}
+	if c.standby {
+		return nil, ErrStandby
+	}
+
+	c.auth.RLock()
+	defer c.auth.RUnlock()
+
+	entries := make([]*MountEntry, 0, len(c.auth.Entries))
+	for _, entry := range c.auth.Entries {
+		entries = append(entries, entry.Clone())
+	}
+	return entries, nil
+}
+
+// enableCredential is used to enable a new credential backend. Any
+// warnings about the configuration of the backend are returned.
+func (c *Core) enableCredential(entry *MountEntry) ([]string, error) {
 	c.auth.Lock()
 	defer c.auth.Unlock()
 
@@ -39,9 +82,9 @@ func (c *Core) enableCredential(entry *MountEntry) error {
 		entry.Path += "/"
 	}
 
-	// Ensure there is a name
-	if entry.Path == "/" {
-		return fmt.Errorf("backend path must be specified")
+	// Ensure the name is usable as a single path segment
+	if err := validateMountName(entry.Path); err != nil {
+		return nil, err
 	}
 
 	// Look for matching name
@@ -52,41 +95,77 @@ func (c *Core) enableCredential(entry *MountEntry) error {
 		case strings.HasPrefix(ent.Path, entry.Path):
 			fallthrough
 		case strings.HasPrefix(entry.Path, ent.Path):
-			return logical.CodedError(409, "path is already in use")
+			return nil, logical.NewConflict("path is already in use")
 		}
 	}
 
 	// Ensure the token backend is a singleton
 	if entry.Type == "token" {
-		return fmt.Errorf("token credential backend cannot be instantiated")
+		return nil, logical.NewInvalidRequest("token credential backend cannot be instantiated")
+	}
+
+	// A human-readable prefix must not mix with retained data at the path
+	if entry.HumanReadablePrefix && c.retainedStorageConflict(credentialStoragePrefix(entry)) {
+		return nil, logical.NewConflict("path is retained for recovery of a disabled backend")
 	}
 
 	// Generate a new UUID and view
-	entry.UUID = uuid.GenerateUUID()
-	view := NewBarrierView(c.barrier, credentialBarrierPrefix+entry.UUID+"/")
+	entryUUID, err := c.newMountUUID(c.auth, credentialStoragePrefix)
+	if err != nil {
+		return nil, err
+	}
+	entry.UUID = entryUUID
+	view := NewBarrierView(c.barrier, credentialStoragePrefix(entry))
 
 	// Create the new backend
-	backend, err := c.newCredentialBackend(entry.Type, c.mountEntrySysView(entry), view, nil)
+	backend, err := c.newCredentialBackend(entry.Type, c.mountEntrySysView(entry), view, entry.Options)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	// A new backend starts with the current storage layout
+	if u, ok := backend.(logical.Upgrader); ok {
+		_, entry.SchemaVersion = u.SchemaVersion()
 	}
 
 	// Update the auth table
 	newTable := c.auth.ShallowClone()
 	newTable.Entries = append(newTable.Entries, entry)
 	if err := c.persistAuth(newTable); err != nil {
-		return errors.New("failed to update auth table")
+		return nil, fmt.Errorf("failed to update auth table: %v", err)
 	}
 	c.auth = newTable
 
 	// Mount the backend
 	path := credentialRoutePrefix + entry.Path
 	if err := c.router.Mount(backend, path, entry, view); err != nil {
-		return err
+		return nil, err
 	}
+	c.initializeCredential(path, backend)
 	c.logger.Printf("[INFO] core: enabled credential backend '%s' type: %s",
 		entry.Path, entry.Type)
-	return nil
+
+	// Surface any insecure defaults of the backend
+	var warnings []string
+	if w, ok := backend.(logical.Warner); ok {
+		warnings = w.Warnings()
+		for _, warning := range warnings {
+			c.logger.Printf("[WARN] core: credential backend '%s': %s", entry.Path, warning)
+		}
+	}
+	return warnings, nil
+}
+
+// credentialStoragePrefix returns the barrier prefix used for the
+// storage of a credential backend. This is derived from the UUID unless
+// the entry requested a human-readable prefix, in which case the mount
+// path is used under its own namespace. Since the prefix then encodes
+// the path, such backends must never be moved to another path.
+func credentialStoragePrefix(entry *MountEntry) string {
+	if entry.HumanReadablePrefix {
+		return credentialPathBarrierPrefix + entry.Path
+	}
+	return credentialBarrierPrefix + entry.UUID + "/"
 }
 
 // disableCredential is used to disable an existing credential backend
@@ -101,14 +180,14 @@ func (c *Core) disableCredential(path string) error {
 
 	// Ensure the token backend is not affected
 	if path == "token/" {
-		return fmt.Errorf("token credential backend cannot be disabled")
+		return logical.NewInvalidRequest("token credential backend cannot be disabled")
 	}
 
 	// Store the view for this backend
 	fullPath := credentialRoutePrefix + path
 	view := c.router.MatchingStorageView(fullPath)
 	if view == nil {
-		return fmt.Errorf("no matching backend")
+		return logical.NewInvalidRequest("no matching backend")
 	}
 
 	// Mark the entry as tainted
@@ -126,16 +205,22 @@ func (c *Core) disableCredential(path string) error {
 		return err
 	}
 
+	// Stop rotating the credentials of the backend
+	if err := c.rotation.UnschedulePrefix(fullPath); err != nil {
+		return err
+	}
+
+	// Stop initializing the backend
+	c.stopCredentialInit(fullPath)
+
 	// Unmount the backend
 	if err := c.router.Unmount(fullPath); err != nil {
 		return err
 	}
 
-	// Clear the data in the view
-	if view != nil {
-		if err := ClearView(view); err != nil {
-			return err
-		}
+	// Clear the data in the view, or keep it for recovery
+	if err := c.retainMount(fullPath, c.auth.Find(path), view); err != nil {
+		return err
 	}
 
 	// Remove the mount table entry
@@ -146,6 +231,171 @@ func (c *Core) disableCredential(path string) error {
 	return nil
 }
 
+// validateMountName checks that the name of a credential backend, with or
+// without a trailing slash, is a single path segment. Paths are built from
+// the name, so a slash or surrounding whitespace would break the prefix
+// matching of the router. The name of the token backend is reserved.
+func validateMountName(name string) error {
+	name = strings.TrimSuffix(name, "/")
+	switch {
+	case name == "":
+		return logical.NewInvalidRequest("backend path must be specified")
+	case strings.Contains(name, "/"):
+		return logical.NewInvalidRequest("backend path '%s' cannot contain '/'", name)
+	case strings.TrimSpace(name) != name:
+		return logical.NewInvalidRequest("backend path '%s' cannot begin or end with whitespace", name)
+	case name == "token":
+		return logical.NewInvalidRequest("backend path 'token' is reserved")
+	}
+	if err := validateUTF8("backend path", name); err != nil {
+		return logical.NewInvalidRequest("%v", err)
+	}
+	return nil
+}
+
+// remountCredential is used to move a credential backend to a new path.
+// The backend keeps its UUID, so its data is served at the new path.
+func (c *Core) remountCredential(src, dst string) error {
+	c.auth.Lock()
+	defer c.auth.Unlock()
+
+	// Ensure we end the paths in a slash
+	if !strings.HasSuffix(src, "/") {
+		src += "/"
+	}
+	if !strings.HasSuffix(dst, "/") {
+		dst += "/"
+	}
+
+	// Ensure the token backend is not affected
+	if src == "token/" {
+		return logical.NewInvalidRequest("token credential backend cannot be remounted")
+	}
+
+	// Ensure there is a source, and the new name is usable
+	if src == "/" {
+		return logical.NewInvalidRequest("backend path must be specified")
+	}
+	if err := validateMountName(dst); err != nil {
+		return err
+	}
+
+	entry := c.auth.Find(src)
+	if entry == nil {
+		return fmt.Errorf("no matching backend at '%s'", src)
+	}
+
+	// The storage of a human-readable prefix is tied to the path
+	if entry.HumanReadablePrefix {
+		return fmt.Errorf("backend at '%s' uses a human-readable prefix and cannot be remounted", src)
+	}
+
+	// Look for matching name
+	for _, ent := range c.auth.Entries {
+		if ent == entry {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(ent.Path, dst):
+			fallthrough
+		case strings.HasPrefix(dst, ent.Path):
+			return logical.NewConflict("path is already in use")
+		}
+	}
+
+	srcPath := credentialRoutePrefix + src
+	dstPath := credentialRoutePrefix + dst
+
+	// Mark the entry as tainted
+	if err := c.taintCredEntry(src); err != nil {
+		return err
+	}
+
+	// Taint the router path to prevent routing
+	if err := c.router.Taint(srcPath); err != nil {
+		return err
+	}
+
+	// Revoke credentials from the old path
+	if err := c.expiration.RevokePrefix(srcPath); err != nil {
+		return err
+	}
+
+	// Stop rotating the credentials of the backend at the old path
+	if err := c.rotation.UnschedulePrefix(srcPath); err != nil {
+		return err
+	}
+
+	// Update the entry in the auth table
+	newTable := c.auth.ShallowClone()
+	entry.Path = dst
+	entry.Tainted = false
+	if err := c.persistAuth(newTable); err != nil {
+		entry.Path = src
+		entry.Tainted = true
+		return errors.New("failed to update auth table")
+	}
+	c.auth = newTable
+
+	// A pending initialization is restarted at the new path
+	pending := c.stopCredentialInit(srcPath)
+
+	// Remount the backend
+	if err := c.router.Remount(srcPath, dstPath); err != nil {
+		return err
+	}
+
+	// Un-taint the path
+	if err := c.router.Untaint(dstPath); err != nil {
+		return err
+	}
+
+	if pending {
+		c.initializeCredential(dstPath, c.router.MatchingBackend(dstPath))
+	}
+
+	c.logger.Printf("[INFO] core: remounted credential backend '%s' to '%s'", src, dst)
+	return nil
+}
+
+// updateAuthDescription is used to change the description of an
+// enabled credential backend
+func (c *Core) updateAuthDescription(path, description string) error {
+	c.auth.Lock()
+	defer c.auth.Unlock()
+
+	// Ensure we end the path in a slash
+	if !strings.HasSuffix(path, "/") {
+		path += "/"
+	}
+
+	entry := c.auth.Find(path)
+	if entry == nil {
+		return logical.NewInvalidRequest("no matching backend at '%s'", path)
+	}
+
+	// Persist a table with an updated copy of the entry, so that a
+	// failure leaves the current table untouched
+	newTable := c.auth.ShallowClone()
+	updated := entry.Clone()
+	updated.Tainted = entry.Tainted
+	updated.Description = description
+	for i, ent := range newTable.Entries {
+		if ent == entry {
+			newTable.Entries[i] = updated
+		}
+	}
+	if err := c.persistAuth(newTable); err != nil {
+		return errors.New("failed to update auth table")
+	}
+
+	// The router shares the entry with the table, so it is updated in
+	// place rather than swapping in the copy
+	entry.Description = description
+	c.logger.Printf("[INFO] core: updated description of credential backend '%s'", path)
+	return nil
+}
+
 // removeCredEntry is used to remove an entry in the auth table
 func (c *Core) removeCredEntry(path string) error {
 	// Taint the entry from the auth table
@@ -168,7 +418,7 @@ func (c *Core) taintCredEntry(path string) error {
 
 	// Ensure there was a match
 	if !found {
-		return fmt.Errorf("no matching backend")
+		return logical.NewInvalidRequest("no matching backend")
 	}
 
 	// Update the auth table
@@ -218,6 +468,16 @@ func (c *Core) persistAuth(table *MountTable) error {
 		return err
 	}
 
+	// Refuse a table the physical backend may not be able to store
+	if c.maxAuthTableSize > 0 && len(raw) > c.maxAuthTableSize {
+		err := fmt.Errorf(
+			"auth table is %d bytes, exceeding the limit of %d bytes; "+
+				"remove unused credential backends or shorten their descriptions",
+			len(raw), c.maxAuthTableSize)
+		c.logger.Printf("[ERR] core: failed to persist auth table: %v", err)
+		return err
+	}
+
 	// Create an entry
 	entry := &Entry{
 		Key:   coreAuthConfigPath,
@@ -238,12 +498,26 @@ func (c *Core) setupCredentials() error {
 	var backend logical.Backend
 	var view *BarrierView
 	var err error
+	var persist bool
+
+	// Backends sharing a storage prefix could read each other's data
+	prefixes := make(map[string]string, len(c.auth.Entries))
 	for _, entry := range c.auth.Entries {
-		// Create a barrier view using the UUID
-		view = NewBarrierView(c.barrier, credentialBarrierPrefix+entry.UUID+"/")
+		prefix := credentialStoragePrefix(entry)
+		if other, ok := prefixes[prefix]; ok {
+			c.logger.Printf("[ERR] core: credential entries %s and %s share the storage prefix %s",
+				other, entry.Path, prefix)
+			return errLoadAuthFailed
+		}
+		prefixes[prefix] = entry.Path
+	}
+
+	for _, entry := range c.auth.Entries {
+		// Create a barrier view using the storage prefix
+		view = NewBarrierView(c.barrier, credentialStoragePrefix(entry))
 
 		// Initialize the backend
-		backend, err = c.newCredentialBackend(entry.Type, c.mountEntrySysView(entry), view, nil)
+		backend, err = c.newCredentialBackend(entry.Type, c.mountEntrySysView(entry), view, entry.Options)
 		if err != nil {
 			c.logger.Printf(
 				"[ERR] core: failed to create credential entry %s: %v",
@@ -251,6 +525,14 @@ func (c *Core) setupCredentials() error {
 			return errLoadAuthFailed
 		}
 
+		// Migrate the storage of the backend before it is used
+		upgraded, err := c.upgradeCredential(entry, backend, view)
+		if err != nil {
+			c.logger.Printf("[ERR] core: failed to upgrade credential entry %s: %v", entry.Path, err)
+			return errLoadAuthFailed
+		}
+		persist = persist || upgraded
+
 		// Mount the backend
 		path := credentialRoutePrefix + entry.Path
 		err = c.router.Mount(backend, path, entry, view)
@@ -264,6 +546,9 @@ func (c *Core) setupCredentials() error {
 			c.router.Taint(path)
 		}
 
+		// Initialize the backend in the background if required
+		c.initializeCredential(path, backend)
+
 		// Check if this is the token store
 		if entry.Type == "token" {
 			c.tokenStore = backend.(*TokenStore)
@@ -273,15 +558,206 @@ func (c *Core) setupCredentials() error {
 			c.tokenStore.cubbyholeBackend = c.router.MatchingBackend("cubbyhole/").(*CubbyholeBackend)
 		}
 	}
+
+	// Record the new schema versions
+	if persist {
+		if err := c.persistAuth(c.auth); err != nil {
+			c.logger.Printf("[ERR] core: failed to persist auth table after upgrade: %v", err)
+			return errLoadAuthFailed
+		}
+	}
 	return nil
 }
 
+// upgradeCredential is used to migrate the storage of a credential
+// backend to the schema version it uses. Entries without a version
+// predate the versioning of the backend and are assumed to be at its
+// oldest supported version. It returns whether the version of the entry
+// changed and must be persisted.
+func (c *Core) upgradeCredential(entry *MountEntry, backend logical.Backend, view *BarrierView) (bool, error) {
+	u, ok := backend.(logical.Upgrader)
+	if !ok {
+		return false, nil
+	}
+	min, current := u.SchemaVersion()
+
+	from := entry.SchemaVersion
+	if from == 0 {
+		from = min
+	}
+	switch {
+	case from > current:
+		return false, fmt.Errorf("storage schema version %d is newer than the supported version %d", from, current)
+	case from < min:
+		return false, fmt.Errorf("storage schema version %d is older than the oldest supported version %d", from, min)
+	case from < current:
+		c.logger.Printf("[INFO] core: upgrading credential backend '%s' from schema version %d to %d",
+			entry.Path, from, current)
+		if err := u.Upgrade(from, current, view); err != nil {
+			return false, err
+		}
+	}
+
+	if entry.SchemaVersion == current {
+		return false, nil
+	}
+	entry.SchemaVersion = current
+	return true, nil
+}
+
 // teardownCredentials is used before we seal the vault to reset the credential
 // backends to their unloaded state. This is reversed by loadCredentials.
 func (c *Core) teardownCredentials() error {
+	c.stopCredentialInits()
+
+	// Unmount the credential backends before dropping the table, so that
+	// the router never routes to a backend whose table entry is gone
+	var result error
+	if c.auth != nil {
+		c.auth.Lock()
+		for _, entry := range c.auth.Entries {
+			path := credentialRoutePrefix + entry.Path
+			if err := c.router.Unmount(path); err != nil {
+				c.logger.Printf("[ERR] core: failed to unmount credential backend at %s: %v", path, err)
+				result = multierror.Append(result, err)
+			}
+		}
+		c.auth.Unlock()
+	}
 	c.auth = nil
 	c.tokenStore = nil
-	return nil
+	return result
+}
+
+// initializeCredential is used to initialize a credential backend that
+// depends on external services. The backend is left mounted but pending
+// until Initialize succeeds, and fails permanently if that does not
+// happen within the timeout. An attempt that does not return within
+// backendInitCallTimeout is abandoned and retried.
+func (c *Core) initializeCredential(path string, backend logical.Backend) {
+	init, ok := backend.(logical.Initializer)
+	if !ok {
+		return
+	}
+
+	// The router is replaced when sealing, so use the one the backend
+	// is mounted in
+	router := c.router
+	router.SetInitError(path, ErrBackendNotReady)
+
+	stopCh := make(chan struct{})
+	c.credInitLock.Lock()
+	if c.credInits == nil {
+		c.credInits = make(map[string]chan struct{})
+	}
+	c.credInits[path] = stopCh
+	c.credInitLock.Unlock()
+
+	callTimeout := c.backendInitCallTimeout
+	retry := c.backendInitRetry
+	deadline := time.Now().Add(c.backendInitTimeout)
+	go func() {
+		for {
+			// The result channel is buffered, so an abandoned attempt
+			// does not block once Initialize returns
+			resultCh := make(chan error, 1)
+			go func() {
+				resultCh <- init.Initialize()
+			}()
+
+			var err error
+			select {
+			case err = <-resultCh:
+			case <-time.After(callTimeout):
+				err = fmt.Errorf("initialization did not complete within %s", callTimeout)
+			case <-stopCh:
+				return
+			}
+
+			if err == nil {
+				if c.finishCredentialInit(path, stopCh, router, nil) {
+					c.logger.Printf("[INFO] core: credential backend '%s' is ready", path)
+				}
+				return
+			}
+			if time.Now().After(deadline) {
+				if c.finishCredentialInit(path, stopCh, router, ErrBackendInitFailed) {
+					c.logger.Printf("[ERR] core: credential backend '%s' failed to initialize: %v", path, err)
+				}
+				return
+			}
+
+			select {
+			case <-time.After(retry):
+			case <-stopCh:
+				return
+			}
+		}
+	}()
+}
+
+// finishCredentialInit is used to record the outcome of the
+// initialization of the credential backend at the path. Nothing is
+// recorded if the initialization was stopped in the meantime, in which
+// case false is returned.
+func (c *Core) finishCredentialInit(path string, stopCh chan struct{}, router *Router, initErr error) bool {
+	c.credInitLock.Lock()
+	defer c.credInitLock.Unlock()
+	if c.credInits[path] != stopCh {
+		return false
+	}
+	delete(c.credInits, path)
+	router.SetInitError(path, initErr)
+	return true
+}
+
+// stopCredentialInit is used to stop initializing the credential backend
+// at the path. It returns true if the initialization was still pending.
+func (c *Core) stopCredentialInit(path string) bool {
+	c.credInitLock.Lock()
+	defer c.credInitLock.Unlock()
+	stopCh, ok := c.credInits[path]
+	if !ok {
+		return false
+	}
+	close(stopCh)
+	delete(c.credInits, path)
+	return true
+}
+
+// stopCredentialInits is used to stop initializing every credential
+// backend, when sealing
+func (c *Core) stopCredentialInits() {
+	c.credInitLock.Lock()
+	defer c.credInitLock.Unlock()
+	for path, stopCh := range c.credInits {
+		close(stopCh)
+		delete(c.credInits, path)
+	}
+}
+
+// tokenTTLs returns the default and max TTL of tokens issued by a login
+// on the credential backend matching the given path. The token TTLs tuned
+// on the mount take precedence over the lease TTLs of the backend, but
+// can never exceed the system max lease TTL.
+func (c *Core) tokenTTLs(path string, sysView logical.SystemView) (def, max time.Duration) {
+	def = sysView.DefaultLeaseTTL()
+	max = sysView.MaxLeaseTTL()
+
+	me := c.router.MatchingMountEntry(path)
+	if me == nil {
+		return
+	}
+	if me.Config.TokenDefaultTTL != 0 {
+		def = me.Config.TokenDefaultTTL
+	}
+	if me.Config.TokenMaxTTL != 0 {
+		max = me.Config.TokenMaxTTL
+	}
+	if _, sysMax := c.leaseTTLs(); max > sysMax {
+		max = sysMax
+	}
+	return
 }
 
 // newCredentialBackend is used to create and configure a new credential backend by name
@@ -1,8 +1,15 @@
 package vault
 
 import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/vault/logical"
 )
@@ -44,7 +51,7 @@ func TestCore_EnableCredential(t *testing.T) {
 		Path: "foo",
 		Type: "noop",
 	}
-	err := c.enableCredential(me)
+	_, err := c.enableCredential(me)
 	if err != nil {
 		t.Fatalf("err: %v", err)
 	}
@@ -79,6 +86,137 @@ func TestCore_EnableCredential(t *testing.T) {
 	}
 }
 
+// warningBackend warns that it is enabled with insecure defaults
+type warningBackend struct {
+	NoopBackend
+}
+
+func (b *warningBackend) Warnings() []string {
+	return []string{"no password policy is configured"}
+}
+
+func TestCore_EnableCredential_Warnings(t *testing.T) {
+	c, _, _ := TestCoreUnsealed(t)
+	c.credentialBackends["noop"] = func(*logical.BackendConfig) (logical.Backend, error) {
+		return &NoopBackend{}, nil
+	}
+	c.credentialBackends["warning"] = func(*logical.BackendConfig) (logical.Backend, error) {
+		return &warningBackend{}, nil
+	}
+
+	warnings, err := c.enableCredential(&MountEntry{Path: "foo", Type: "noop"})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(warnings) != 0 {
+		t.Fatalf("bad: %v", warnings)
+	}
+
+	// Warnings do not prevent enabling the backend
+	warnings, err = c.enableCredential(&MountEntry{Path: "bar", Type: "warning"})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !reflect.DeepEqual(warnings, []string{"no password policy is configured"}) {
+		t.Fatalf("bad: %v", warnings)
+	}
+	if match := c.router.MatchingMount("auth/bar/baz"); match != "auth/bar/" {
+		t.Fatalf("missing mount: %s", match)
+	}
+}
+
+// upgradeBackend stores a value in the format of its schema version,
+// prefixed by the version, and records the upgrades it runs
+type upgradeBackend struct {
+	NoopBackend
+	current  int
+	upgrades [][2]int
+}
+
+func (b *upgradeBackend) SchemaVersion() (int, int) {
+	return 1, b.current
+}
+
+func (b *upgradeBackend) Upgrade(from, to int, s logical.Storage) error {
+	b.upgrades = append(b.upgrades, [2]int{from, to})
+	entry, err := s.Get("value")
+	if err != nil || entry == nil {
+		return err
+	}
+	entry.Value = []byte(fmt.Sprintf("v%d:%s", to, entry.Value[3:]))
+	return s.Put(entry)
+}
+
+func TestCore_SetupCredentials_Upgrade(t *testing.T) {
+	c, key, _ := TestCoreUnsealed(t)
+	b := &upgradeBackend{current: 1}
+	c.credentialBackends["upgrade"] = func(*logical.BackendConfig) (logical.Backend, error) {
+		return b, nil
+	}
+
+	me := &MountEntry{Path: "foo", Type: "upgrade"}
+	if _, err := c.enableCredential(me); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if me.SchemaVersion != 1 {
+		t.Fatalf("bad: %d", me.SchemaVersion)
+	}
+	view := NewBarrierView(c.barrier, credentialStoragePrefix(me))
+	if err := view.Put(&logical.StorageEntry{Key: "value", Value: []byte("v1:foo")}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// unseal starts a new core on the same storage with the backend
+	// at the given version
+	unseal := func(current int) (*Core, *upgradeBackend, error) {
+		c2, err := NewCore(&CoreConfig{
+			Physical:     c.physical,
+			DisableMlock: true,
+		})
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		b := &upgradeBackend{current: current}
+		c2.credentialBackends["upgrade"] = func(*logical.BackendConfig) (logical.Backend, error) {
+			return b, nil
+		}
+		_, err = c2.Unseal(TestKeyCopy(key))
+		return c2, b, err
+	}
+
+	// The data is migrated when the backend is mounted
+	c2, b, err := unseal(2)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !reflect.DeepEqual(b.upgrades, [][2]int{{1, 2}}) {
+		t.Fatalf("bad: %v", b.upgrades)
+	}
+	entry, err := view.Get("value")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if string(entry.Value) != "v2:foo" {
+		t.Fatalf("bad: %s", entry.Value)
+	}
+	if me2 := c2.router.MatchingMountEntry("auth/foo/"); me2 == nil || me2.SchemaVersion != 2 {
+		t.Fatalf("bad: %#v", me2)
+	}
+	// Nothing to do once current
+	_, b, err = unseal(2)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(b.upgrades) != 0 {
+		t.Fatalf("bad: %v", b.upgrades)
+	}
+
+	// Storage newer than the backend is refused
+	if _, _, err := unseal(1); err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
 func TestCore_EnableCredential_twice_409(t *testing.T) {
 	c, _, _ := TestCoreUnsealed(t)
 	c.credentialBackends["noop"] = func(*logical.BackendConfig) (logical.Backend, error) {
@@ -89,17 +227,18 @@ func TestCore_EnableCredential_twice_409(t *testing.T) {
 		Path: "foo",
 		Type: "noop",
 	}
-	err := c.enableCredential(me)
+	_, err := c.enableCredential(me)
 	if err != nil {
 		t.Fatalf("err: %v", err)
 	}
 
 	// 2nd should be a 409 error
-	err2 := c.enableCredential(me)
+	_, err2 := c.enableCredential(me)
 	switch err2.(type) {
-	case logical.HTTPCodedError:
-		if err2.(logical.HTTPCodedError).Code() != 409 {
-			t.Fatalf("invalid code given")
+	case *logical.VaultError:
+		verr := err2.(*logical.VaultError)
+		if verr.HTTPStatus != 409 || verr.Code != logical.ErrCodeConflict {
+			t.Fatalf("invalid code given: %#v", verr)
 		}
 	default:
 		t.Fatalf("expected a different error type")
@@ -112,12 +251,356 @@ func TestCore_EnableCredential_Token(t *testing.T) {
 		Path: "foo",
 		Type: "token",
 	}
-	err := c.enableCredential(me)
+	_, err := c.enableCredential(me)
 	if err.Error() != "token credential backend cannot be instantiated" {
 		t.Fatalf("err: %v", err)
 	}
 }
 
+func TestCore_EnableCredential_InvalidPath(t *testing.T) {
+	c, _, _ := TestCoreUnsealed(t)
+	c.credentialBackends["noop"] = func(*logical.BackendConfig) (logical.Backend, error) {
+		return &NoopBackend{}, nil
+	}
+
+	paths := []string{
+		"foo\x00bar", "foo\xff\xfe", "foo\nbar",
+		"", "/", "foo/bar", "auth/foo", " github", "github ", "token",
+	}
+	for _, path := range paths {
+		me := &MountEntry{
+			Path: path,
+			Type: "noop",
+		}
+		_, err := c.enableCredential(me)
+		if verr, ok := err.(*logical.VaultError); !ok || verr.Code != logical.ErrCodeInvalidRequest {
+			t.Fatalf("expected invalid request for %q, got: %v", path, err)
+		}
+	}
+	if len(c.auth.Entries) != len(defaultAuthTable().Entries) {
+		t.Fatalf("bad: %#v", c.auth.Entries)
+	}
+}
+
+func TestCore_EnableCredential_StoragePrefix(t *testing.T) {
+	c, key, _ := TestCoreUnsealed(t)
+	noop := func(*logical.BackendConfig) (logical.Backend, error) {
+		return &NoopBackend{}, nil
+	}
+	c.credentialBackends["noop"] = noop
+
+	uuidEntry := &MountEntry{
+		Path: "foo",
+		Type: "noop",
+	}
+	if _, err := c.enableCredential(uuidEntry); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	readable := &MountEntry{
+		Path:                "bar",
+		Type:                "noop",
+		HumanReadablePrefix: true,
+	}
+	if _, err := c.enableCredential(readable); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Write through the storage view of each backend
+	for _, path := range []string{"auth/foo/", "auth/bar/"} {
+		view := c.router.MatchingStorageView(path)
+		if view == nil {
+			t.Fatalf("missing view for %s", path)
+		}
+		if err := view.Put(&logical.StorageEntry{Key: "test", Value: []byte("value")}); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+
+	// Verify the storage prefix used in the barrier
+	expected := []string{
+		"auth/" + uuidEntry.UUID + "/test",
+		"auth/path/bar/test",
+	}
+	for _, k := range expected {
+		out, err := c.barrier.Get(k)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if out == nil {
+			t.Fatalf("missing key: %s", k)
+		}
+	}
+	out, err := c.barrier.Get("auth/" + readable.UUID + "/test")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if out != nil {
+		t.Fatalf("should not be stored under the UUID: %#v", out)
+	}
+
+	// The prefix must be preserved on reload
+	conf := &CoreConfig{
+		Physical:     c.physical,
+		DisableMlock: true,
+	}
+	c2, err := NewCore(conf)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	c2.credentialBackends["noop"] = noop
+	if _, err := c2.Unseal(key); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	view := c2.router.MatchingStorageView("auth/bar/")
+	if view == nil {
+		t.Fatalf("missing view")
+	}
+	entry, err := view.Get("test")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if entry == nil || string(entry.Value) != "value" {
+		t.Fatalf("bad: %#v", entry)
+	}
+}
+
+func TestCore_EnableCredential_StoragePrefixUUIDPath(t *testing.T) {
+	c, _, _ := TestCoreUnsealed(t)
+	c.credentialBackends["noop"] = func(*logical.BackendConfig) (logical.Backend, error) {
+		return &NoopBackend{}, nil
+	}
+
+	uuidEntry := &MountEntry{
+		Path: "foo",
+		Type: "noop",
+	}
+	if _, err := c.enableCredential(uuidEntry); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// A human-readable path equal to the UUID of another backend must
+	// not share its storage
+	readable := &MountEntry{
+		Path:                uuidEntry.UUID,
+		Type:                "noop",
+		HumanReadablePrefix: true,
+	}
+	if _, err := c.enableCredential(readable); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	view := c.router.MatchingStorageView("auth/" + uuidEntry.UUID + "/")
+	if err := view.Put(&logical.StorageEntry{Key: "test", Value: []byte("value")}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	out, err := c.router.MatchingStorageView("auth/foo/").Get("test")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if out != nil {
+		t.Fatalf("storage shared between backends: %#v", out)
+	}
+}
+
+// initBackend is a backend that fails to initialize until ready is set
+type initBackend struct {
+	NoopBackend
+
+	ready    bool
+	attempts int
+	lock     sync.Mutex
+}
+
+func (b *initBackend) Initialize() error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.attempts++
+	if !b.ready {
+		return fmt.Errorf("dependency unavailable")
+	}
+	return nil
+}
+
+func (b *initBackend) getAttempts() int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.attempts
+}
+
+func (b *initBackend) setReady() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.ready = true
+}
+
+// testWaitRouteErr waits until a request to the path returns the error
+func testWaitRouteErr(t *testing.T, c *Core, path string, expect error) {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		req := logical.TestRequest(t, logical.ReadOperation, path)
+		_, err := c.router.Route(req)
+		if err == expect {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("err: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestCore_EnableCredential_Initialize(t *testing.T) {
+	c, key, _ := TestCoreUnsealed(t)
+	c.backendInitRetry = 10 * time.Millisecond
+	backend := &initBackend{}
+	c.credentialBackends["init"] = func(*logical.BackendConfig) (logical.Backend, error) {
+		return backend, nil
+	}
+
+	me := &MountEntry{
+		Path: "foo",
+		Type: "init",
+	}
+	if _, err := c.enableCredential(me); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The backend is pending until it initializes
+	testWaitRouteErr(t, c, "auth/foo/bar", ErrBackendNotReady)
+	backend.setReady()
+	testWaitRouteErr(t, c, "auth/foo/bar", nil)
+
+	// The backend is pending again when set up after an unseal
+	conf := &CoreConfig{
+		Physical:     c.physical,
+		DisableMlock: true,
+	}
+	c2, err := NewCore(conf)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	c2.backendInitRetry = 10 * time.Millisecond
+	backend2 := &initBackend{}
+	c2.credentialBackends["init"] = func(*logical.BackendConfig) (logical.Backend, error) {
+		return backend2, nil
+	}
+	if _, err := c2.Unseal(key); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	testWaitRouteErr(t, c2, "auth/foo/bar", ErrBackendNotReady)
+	backend2.setReady()
+	testWaitRouteErr(t, c2, "auth/foo/bar", nil)
+}
+
+func TestCore_EnableCredential_InitializeTimeout(t *testing.T) {
+	c, _, _ := TestCoreUnsealed(t)
+	c.backendInitRetry = 10 * time.Millisecond
+	c.backendInitTimeout = 50 * time.Millisecond
+	backend := &initBackend{}
+	c.credentialBackends["init"] = func(*logical.BackendConfig) (logical.Backend, error) {
+		return backend, nil
+	}
+
+	me := &MountEntry{
+		Path: "foo",
+		Type: "init",
+	}
+	if _, err := c.enableCredential(me); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The mount fails permanently after the timeout
+	testWaitRouteErr(t, c, "auth/foo/bar", ErrBackendInitFailed)
+	backend.setReady()
+	time.Sleep(50 * time.Millisecond)
+	testWaitRouteErr(t, c, "auth/foo/bar", ErrBackendInitFailed)
+}
+
+func TestCore_DisableCredential_StopsInitialize(t *testing.T) {
+	c, _, _ := TestCoreUnsealed(t)
+	c.backendInitRetry = 10 * time.Millisecond
+	backend := &initBackend{}
+	c.credentialBackends["init"] = func(*logical.BackendConfig) (logical.Backend, error) {
+		return backend, nil
+	}
+	if _, err := c.enableCredential(&MountEntry{Path: "foo", Type: "init"}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	testWaitRouteErr(t, c, "auth/foo/bar", ErrBackendNotReady)
+
+	if err := c.disableCredential("foo"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// No attempt is made once the backend is disabled
+	time.Sleep(30 * time.Millisecond)
+	attempts := backend.getAttempts()
+	time.Sleep(50 * time.Millisecond)
+	if n := backend.getAttempts(); n != attempts {
+		t.Fatalf("attempts after disable: %d, expected %d", n, attempts)
+	}
+}
+
+func TestCore_RemountCredential_Initialize(t *testing.T) {
+	c, _, _ := TestCoreUnsealed(t)
+	c.backendInitRetry = 10 * time.Millisecond
+	backend := &initBackend{}
+	c.credentialBackends["init"] = func(*logical.BackendConfig) (logical.Backend, error) {
+		return backend, nil
+	}
+	if _, err := c.enableCredential(&MountEntry{Path: "foo", Type: "init"}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	testWaitRouteErr(t, c, "auth/foo/bar", ErrBackendNotReady)
+
+	if err := c.remountCredential("foo", "bar"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The initialization continues at the new path
+	testWaitRouteErr(t, c, "auth/bar/baz", ErrBackendNotReady)
+	backend.setReady()
+	testWaitRouteErr(t, c, "auth/bar/baz", nil)
+}
+
+// hangingInitBackend is a backend whose first attempt to initialize
+// never returns
+type hangingInitBackend struct {
+	NoopBackend
+
+	attempts int
+	lock     sync.Mutex
+	doneCh   chan struct{}
+}
+
+func (b *hangingInitBackend) Initialize() error {
+	b.lock.Lock()
+	b.attempts++
+	first := b.attempts == 1
+	b.lock.Unlock()
+	if first {
+		<-b.doneCh
+	}
+	return nil
+}
+
+func TestCore_EnableCredential_InitializeCallTimeout(t *testing.T) {
+	c, _, _ := TestCoreUnsealed(t)
+	c.backendInitRetry = 10 * time.Millisecond
+	c.backendInitCallTimeout = 20 * time.Millisecond
+	backend := &hangingInitBackend{doneCh: make(chan struct{})}
+	defer close(backend.doneCh)
+	c.credentialBackends["init"] = func(*logical.BackendConfig) (logical.Backend, error) {
+		return backend, nil
+	}
+	if _, err := c.enableCredential(&MountEntry{Path: "foo", Type: "init"}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The hanging attempt is abandoned and the next one succeeds
+	testWaitRouteErr(t, c, "auth/foo/bar", nil)
+}
+
 func TestCore_DisableCredential(t *testing.T) {
 	c, key, _ := TestCoreUnsealed(t)
 	c.credentialBackends["noop"] = func(*logical.BackendConfig) (logical.Backend, error) {
@@ -133,7 +616,7 @@ func TestCore_DisableCredential(t *testing.T) {
 		Path: "foo",
 		Type: "noop",
 	}
-	err = c.enableCredential(me)
+	_, err = c.enableCredential(me)
 	if err != nil {
 		t.Fatalf("err: %v", err)
 	}
@@ -170,6 +653,47 @@ func TestCore_DisableCredential(t *testing.T) {
 	}
 }
 
+func TestCore_PersistAuth_MaxSize(t *testing.T) {
+	c, _, _ := TestCoreUnsealed(t)
+	c.credentialBackends["noop"] = func(*logical.BackendConfig) (logical.Backend, error) {
+		return &NoopBackend{}, nil
+	}
+
+	newTable := c.auth.ShallowClone()
+	newTable.Entries = append(newTable.Entries, &MountEntry{
+		Path:        "foo/",
+		Type:        "noop",
+		Description: "a very long description",
+	})
+	raw, err := json.Marshal(newTable)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Just over the limit
+	c.maxAuthTableSize = len(raw) - 1
+	if err := c.persistAuth(newTable); err == nil {
+		t.Fatalf("expected error")
+	}
+	me := &MountEntry{
+		Path:        "foo",
+		Type:        "noop",
+		Description: "a very long description",
+	}
+	if _, err := c.enableCredential(me); err == nil {
+		t.Fatalf("expected error")
+	}
+	if match := c.router.MatchingMount("auth/foo/bar"); match != "" {
+		t.Fatalf("bad: %s", match)
+	}
+
+	// Just under the limit
+	c.maxAuthTableSize = len(raw) + 1
+	if err := c.persistAuth(newTable); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
+
 func TestCore_DisableCredential_Protected(t *testing.T) {
 	c, _, _ := TestCoreUnsealed(t)
 	err := c.disableCredential("token")
@@ -191,7 +715,7 @@ func TestCore_DisableCredential_Cleanup(t *testing.T) {
 		Path: "foo",
 		Type: "noop",
 	}
-	err := c.enableCredential(me)
+	_, err := c.enableCredential(me)
 	if err != nil {
 		t.Fatalf("err: %v", err)
 	}
@@ -278,3 +802,352 @@ func verifyDefaultAuthTable(t *testing.T, table *MountTable) {
 		}
 	}
 }
+
+func TestCore_EnableCredential_Options(t *testing.T) {
+	c, key, _ := TestCoreUnsealed(t)
+	var configs []map[string]string
+	factory := func(conf *logical.BackendConfig) (logical.Backend, error) {
+		configs = append(configs, conf.Config)
+		return &NoopBackend{}, nil
+	}
+	c.credentialBackends["noop"] = factory
+
+	me := &MountEntry{
+		Path:    "foo",
+		Type:    "noop",
+		Options: map[string]string{"url": "ldap://127.0.0.1"},
+	}
+	_, err := c.enableCredential(me)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	conf := &CoreConfig{
+		Physical:     c.physical,
+		DisableMlock: true,
+	}
+	c2, err := NewCore(conf)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	c2.credentialBackends["noop"] = factory
+	unseal, err := c2.Unseal(key)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !unseal {
+		t.Fatalf("should be unsealed")
+	}
+
+	// The backend receives the options on enable and on unseal
+	expected := map[string]string{"url": "ldap://127.0.0.1"}
+	if len(configs) != 2 {
+		t.Fatalf("bad: %#v", configs)
+	}
+	if !reflect.DeepEqual(configs[1], expected) {
+		t.Fatalf("bad: %#v", configs[1])
+	}
+	if !reflect.DeepEqual(c.auth, c2.auth) {
+		t.Fatalf("mismatch: %v %v", c.auth, c2.auth)
+	}
+}
+
+func TestCore_ListAuth(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+	c.credentialBackends["noop"] = func(*logical.BackendConfig) (logical.Backend, error) {
+		return &NoopBackend{}, nil
+	}
+
+	me := &MountEntry{
+		Path:    "foo",
+		Type:    "noop",
+		Options: map[string]string{"url": "ldap://127.0.0.1"},
+	}
+	if _, err := c.enableCredential(me); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	entries, err := c.ListAuth()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("bad: %#v", entries)
+	}
+	if entries[0].Type != "token" || entries[1].Path != "foo/" {
+		t.Fatalf("bad: %#v %#v", entries[0], entries[1])
+	}
+
+	// Modifying the result must not change the auth table
+	entries[1].Options["url"] = "ldap://10.0.0.1"
+	entries[1].Path = "bar/"
+	if me.Options["url"] != "ldap://127.0.0.1" || me.Path != "foo/" {
+		t.Fatalf("auth table modified: %#v", me)
+	}
+
+	if err := c.Seal(root); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := c.ListAuth(); err != ErrSealed {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestCore_RemountCredential(t *testing.T) {
+	c, key, _ := TestCoreUnsealed(t)
+	c.credentialBackends["noop"] = func(*logical.BackendConfig) (logical.Backend, error) {
+		return &NoopBackend{}, nil
+	}
+
+	me := &MountEntry{
+		Path: "github",
+		Type: "noop",
+	}
+	if _, err := c.enableCredential(me); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	view := c.router.MatchingStorageView("auth/github/")
+	if err := view.Put(&logical.StorageEntry{Key: "test", Value: []byte("data")}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if err := c.remountCredential("github", "ghe"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if match := c.router.MatchingMount("auth/github/bar"); match != "" {
+		t.Fatalf("old path still mounted")
+	}
+	if match := c.router.MatchingMount("auth/ghe/bar"); match != "auth/ghe/" {
+		t.Fatalf("missing mount")
+	}
+
+	// The data is served at the new path
+	view = c.router.MatchingStorageView("auth/ghe/")
+	entry, err := view.Get("test")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if entry == nil || string(entry.Value) != "data" {
+		t.Fatalf("bad: %#v", entry)
+	}
+
+	// The move is persisted
+	conf := &CoreConfig{
+		Physical:     c.physical,
+		DisableMlock: true,
+	}
+	c2, err := NewCore(conf)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	c2.credentialBackends["noop"] = c.credentialBackends["noop"]
+	if _, err := c2.Unseal(key); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if out := c2.auth.Find("ghe/"); out == nil || out.UUID != me.UUID || out.Tainted {
+		t.Fatalf("bad: %#v", out)
+	}
+	if c2.auth.Find("github/") != nil {
+		t.Fatalf("old entry still present")
+	}
+}
+
+func TestCore_RemountCredential_Invalid(t *testing.T) {
+	c, _, _ := TestCoreUnsealed(t)
+	c.credentialBackends["noop"] = func(*logical.BackendConfig) (logical.Backend, error) {
+		return &NoopBackend{}, nil
+	}
+	for _, path := range []string{"foo", "bar", "human"} {
+		me := &MountEntry{
+			Path:                path,
+			Type:                "noop",
+			HumanReadablePrefix: path == "human",
+		}
+		if _, err := c.enableCredential(me); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+
+	cases := []struct {
+		src, dst string
+	}{
+		{"token", "foo2"},
+		{"foo", "token"},
+		{"unknown", "foo2"},
+		{"foo", "bar"},
+		{"foo", ""},
+		{"foo", "foo2/bar"},
+		{"foo", " foo2"},
+		{"human", "human2"},
+	}
+	for _, tc := range cases {
+		if err := c.remountCredential(tc.src, tc.dst); err == nil {
+			t.Fatalf("expected error remounting '%s' to '%s'", tc.src, tc.dst)
+		}
+	}
+	if match := c.router.MatchingMount("auth/foo/bar"); match != "auth/foo/" {
+		t.Fatalf("missing mount")
+	}
+}
+
+func TestCore_UpdateAuthDescription(t *testing.T) {
+	c, key, _ := TestCoreUnsealed(t)
+	c.credentialBackends["noop"] = func(*logical.BackendConfig) (logical.Backend, error) {
+		return &NoopBackend{}, nil
+	}
+	me := &MountEntry{
+		Path:        "foo",
+		Type:        "noop",
+		Description: "foo",
+	}
+	if _, err := c.enableCredential(me); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if err := c.updateAuthDescription("foo", "bar"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if desc := c.auth.Find("foo/").Description; desc != "bar" {
+		t.Fatalf("bad: %s", desc)
+	}
+
+	// Unknown paths are rejected
+	if err := c.updateAuthDescription("unknown", "bar"); err == nil {
+		t.Fatalf("expected error")
+	}
+
+	// A failed persist leaves the table untouched
+	c.maxAuthTableSize = 1
+	if err := c.updateAuthDescription("foo", "baz"); err == nil {
+		t.Fatalf("expected error")
+	}
+	if desc := c.auth.Find("foo/").Description; desc != "bar" {
+		t.Fatalf("bad: %s", desc)
+	}
+
+	// The description is persisted
+	conf := &CoreConfig{
+		Physical:     c.physical,
+		DisableMlock: true,
+		CredentialBackends: map[string]logical.Factory{
+			"noop": c.credentialBackends["noop"],
+		},
+	}
+	c2, err := NewCore(conf)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := c2.Unseal(key); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if desc := c2.auth.Find("foo/").Description; desc != "bar" {
+		t.Fatalf("bad: %s", desc)
+	}
+}
+
+func TestCore_EnableCredential_UUIDCollision(t *testing.T) {
+	c, _, _ := TestCoreUnsealed(t)
+	c.credentialBackends["noop"] = func(*logical.BackendConfig) (logical.Backend, error) {
+		return &NoopBackend{}, nil
+	}
+
+	// The first UUID drawn collides with the token backend
+	existing, err := hex.DecodeString(strings.Replace(c.auth.Find("token/").UUID, "-", "", -1))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	fresh := bytes.Repeat([]byte{0x01}, 16)
+
+	old := uuidRand
+	defer func() { uuidRand = old }()
+	uuidRand = bytes.NewReader(append(existing, fresh...))
+
+	me := &MountEntry{
+		Path: "foo",
+		Type: "noop",
+	}
+	if _, err := c.enableCredential(me); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if me.UUID != "01010101-0101-0101-0101-010101010101" {
+		t.Fatalf("bad: %s", me.UUID)
+	}
+
+	// Without randomness no backend is enabled
+	uuidRand = bytes.NewReader(nil)
+	if _, err := c.enableCredential(&MountEntry{Path: "bar", Type: "noop"}); err == nil {
+		t.Fatalf("expected error")
+	}
+	if match := c.router.MatchingMount("auth/bar/"); match != "" {
+		t.Fatalf("bad: %s", match)
+	}
+}
+
+func TestCore_SetupCredentials_SharedPrefix(t *testing.T) {
+	c, _, _ := TestCoreUnsealed(t)
+	c.credentialBackends["noop"] = func(*logical.BackendConfig) (logical.Backend, error) {
+		return &NoopBackend{}, nil
+	}
+	if _, err := c.enableCredential(&MountEntry{Path: "foo", Type: "noop"}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Two entries with the same UUID must not be mounted
+	c.auth.Entries = append(c.auth.Entries, &MountEntry{
+		Path: "bar/",
+		Type: "noop",
+		UUID: c.auth.Find("foo/").UUID,
+	})
+	c.router = NewRouter()
+	if err := c.setupCredentials(); err != errLoadAuthFailed {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestCore_TeardownCredentials_Unmounts(t *testing.T) {
+	c, key, root := TestCoreUnsealed(t)
+	c.credentialBackends["noop"] = func(*logical.BackendConfig) (logical.Backend, error) {
+		return &NoopBackend{}, nil
+	}
+
+	me := &MountEntry{
+		Path: "foo",
+		Type: "noop",
+	}
+	if _, err := c.enableCredential(me); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Tearing down removes the credential routes but leaves the mounts
+	if err := c.teardownCredentials(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for _, path := range []string{"auth/foo/bar", "auth/token/lookup"} {
+		if match := c.router.MatchingMount(path); match != "" {
+			t.Fatalf("backend present at %s: %s", path, match)
+		}
+	}
+	if match := c.router.MatchingMount("secret/foo"); match != "secret/" {
+		t.Fatalf("bad: %s", match)
+	}
+	if c.auth != nil {
+		t.Fatalf("auth table present")
+	}
+
+	// Restore the credentials and cycle through a seal and unseal
+	if err := c.loadCredentials(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := c.setupCredentials(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := c.Seal(root); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if unseal, err := c.Unseal(key); err != nil || !unseal {
+		t.Fatalf("err: %v", err)
+	}
+	if match := c.router.MatchingMount("auth/foo/bar"); match != "auth/foo/" {
+		t.Fatalf("bad: %s", match)
+	}
+}
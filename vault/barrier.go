@@ -119,6 +119,11 @@ type SecurityBarrier interface {
 	// Rekey is used to change the master key used to protect the keyring
 	Rekey([]byte) error
 
+	// VerifyEntry is used to check that the stored entry for the key
+	// decrypts and authenticates, indicating it was not corrupted or
+	// tampered with
+	VerifyEntry(key string) (bool, error)
+
 	// SecurityBarrier must provide the storage APIs
 	BarrierStorage
 }
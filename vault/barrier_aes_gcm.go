@@ -9,12 +9,15 @@ import (
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/armon/go-metrics"
 	"github.com/hashicorp/vault/physical"
+	"golang.org/x/crypto/chacha20poly1305"
 )
 
 const (
@@ -32,6 +35,12 @@ const (
 	AESGCMVersion2 = 0x2
 )
 
+// Encryption algorithms that can be used for the keyring terms
+const (
+	BarrierAlgorithmAESGCM           = "aes256-gcm"
+	BarrierAlgorithmChaCha20Poly1305 = "chacha20-poly1305"
+)
+
 // barrierInit is the JSON encoded value stored
 type barrierInit struct {
 	Version int    // Version is the current format version
@@ -62,6 +71,17 @@ type AESGCMBarrier struct {
 	// future versioning of barrier implementations. It's var instead
 	// of const to allow for testing
 	currentAESGCMVersionByte byte
+
+	// algorithm is the encryption algorithm recorded for new keyring
+	// terms. Existing terms keep the algorithm they were created with.
+	algorithm string
+
+	// keyRand is the source of randomness for generated keys
+	keyRand io.Reader
+
+	// streamChunkSize is the size of the chunks written by PutStream. If
+	// zero, barrierStreamChunkSize is used.
+	streamChunkSize int
 }
 
 // NewAESGCMBarrier is used to construct a new barrier that uses
@@ -72,10 +92,34 @@ func NewAESGCMBarrier(physical physical.Backend) (*AESGCMBarrier, error) {
 		sealed:  true,
 		cache:   make(map[uint32]cipher.AEAD),
 		currentAESGCMVersionByte: byte(AESGCMVersion2),
+		algorithm:                BarrierAlgorithmAESGCM,
+		keyRand:                  rand.Reader,
 	}
 	return b, nil
 }
 
+// SetRand sets the source of randomness for keys generated by
+// GenerateKey. It must be called before the barrier is used.
+func (b *AESGCMBarrier) SetRand(r io.Reader) {
+	b.keyRand = r
+}
+
+// SetAlgorithm sets the encryption algorithm used for keys created by
+// Initialize and Rotate. Data written under earlier terms can still be
+// read since each term records its own algorithm.
+func (b *AESGCMBarrier) SetAlgorithm(algorithm string) error {
+	switch algorithm {
+	case BarrierAlgorithmAESGCM, BarrierAlgorithmChaCha20Poly1305:
+	default:
+		return fmt.Errorf("unsupported barrier algorithm '%s'", algorithm)
+	}
+
+	b.l.Lock()
+	defer b.l.Unlock()
+	b.algorithm = algorithm
+	return nil
+}
+
 // Initialized checks if the barrier has been initialized
 // and has a master key set.
 func (b *AESGCMBarrier) Initialized() (bool, error) {
@@ -122,9 +166,10 @@ func (b *AESGCMBarrier) Initialize(key []byte) error {
 	keyring := NewKeyring()
 	keyring = keyring.SetMasterKey(key)
 	keyring, err = keyring.AddKey(&Key{
-		Term:    1,
-		Version: 1,
-		Value:   encrypt,
+		Term:      1,
+		Version:   1,
+		Value:     encrypt,
+		Algorithm: b.algorithm,
 	})
 	if err != nil {
 		return fmt.Errorf("failed to create keyring: %v", err)
@@ -174,7 +219,7 @@ func (b *AESGCMBarrier) persistKeyring(keyring *Keyring) error {
 
 	// Encrypt the master key
 	activeKey := keyring.ActiveKey()
-	aead, err := b.aeadFromKey(activeKey.Value)
+	aead, err := b.aeadForKey(activeKey)
 	if err != nil {
 		return err
 	}
@@ -195,7 +240,7 @@ func (b *AESGCMBarrier) persistKeyring(keyring *Keyring) error {
 func (b *AESGCMBarrier) GenerateKey() ([]byte, error) {
 	// Generate a 256bit key
 	buf := make([]byte, 2*aes.BlockSize)
-	_, err := rand.Read(buf)
+	_, err := io.ReadFull(b.keyRand, buf)
 	return buf, err
 }
 
@@ -437,9 +482,10 @@ func (b *AESGCMBarrier) Rotate() (uint32, error) {
 
 	// Add a new encryption key
 	newKeyring, err := b.keyring.AddKey(&Key{
-		Term:    newTerm,
-		Version: 1,
-		Value:   encrypt,
+		Term:      newTerm,
+		Version:   1,
+		Value:     encrypt,
+		Algorithm: b.algorithm,
 	})
 	if err != nil {
 		return 0, fmt.Errorf("failed to add new encryption key: %v", err)
@@ -631,6 +677,11 @@ func (b *AESGCMBarrier) Get(key string) (*Entry, error) {
 		return nil, fmt.Errorf("decryption failed: %v", err)
 	}
 
+	// An entry with an empty value is present, distinct from a missing key
+	if plain == nil {
+		plain = []byte{}
+	}
+
 	// Wrap in a logical entry
 	entry := &Entry{
 		Key:   key,
@@ -639,6 +690,39 @@ func (b *AESGCMBarrier) Get(key string) (*Entry, error) {
 	return entry, nil
 }
 
+// VerifyEntry is used to check that the entry stored under the key
+// decrypts and its authentication tag validates. An error is only
+// returned if the entry could not be read.
+func (b *AESGCMBarrier) VerifyEntry(key string) (bool, error) {
+	b.l.RLock()
+	defer b.l.RUnlock()
+	if b.sealed {
+		return false, ErrBarrierSealed
+	}
+
+	pe, err := b.backend.Get(key)
+	if err != nil {
+		return false, err
+	} else if pe == nil {
+		// Removed since it was listed
+		return true, nil
+	}
+
+	// The keyring is encrypted by the master key rather than a key
+	// of the keyring
+	if key == keyringPath {
+		gcm, err := b.aeadFromKey(b.keyring.MasterKey())
+		if err != nil {
+			return false, err
+		}
+		_, err = b.decrypt(key, gcm, pe.Value)
+		return err == nil, nil
+	}
+
+	_, err = b.decryptKeyring(key, pe.Value)
+	return err == nil, nil
+}
+
 // Delete is used to permanently delete an entry
 func (b *AESGCMBarrier) Delete(key string) error {
 	defer metrics.MeasureSince([]string{"barrier", "delete"}, time.Now())
@@ -664,7 +748,7 @@ func (b *AESGCMBarrier) List(prefix string) ([]string, error) {
 	return b.backend.List(prefix)
 }
 
-// aeadForTerm returns the AES-GCM AEAD for the given term
+// aeadForTerm returns the AEAD for the given term
 func (b *AESGCMBarrier) aeadForTerm(term uint32) (cipher.AEAD, error) {
 	// Check for the keyring
 	keyring := b.keyring
@@ -687,7 +771,7 @@ func (b *AESGCMBarrier) aeadForTerm(term uint32) (cipher.AEAD, error) {
 	}
 
 	// Create a new aead
-	aead, err := b.aeadFromKey(key.Value)
+	aead, err := b.aeadForKey(key)
 	if err != nil {
 		return nil, err
 	}
@@ -699,6 +783,23 @@ func (b *AESGCMBarrier) aeadForTerm(term uint32) (cipher.AEAD, error) {
 	return aead, nil
 }
 
+// aeadForKey returns the AEAD for the algorithm recorded with the given
+// keyring key.
+func (b *AESGCMBarrier) aeadForKey(key *Key) (cipher.AEAD, error) {
+	switch key.Algorithm {
+	case "", BarrierAlgorithmAESGCM:
+		return b.aeadFromKey(key.Value)
+	case BarrierAlgorithmChaCha20Poly1305:
+		aead, err := chacha20poly1305.New(key.Value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create cipher: %v", err)
+		}
+		return aead, nil
+	default:
+		return nil, fmt.Errorf("unsupported barrier algorithm '%s'", key.Algorithm)
+	}
+}
+
 // aeadFromKey returns an AES-GCM AEAD using the given key.
 func (b *AESGCMBarrier) aeadFromKey(key []byte) (cipher.AEAD, error) {
 	// Create the AES cipher
@@ -748,6 +849,10 @@ func (b *AESGCMBarrier) encrypt(path string, term uint32, gcm cipher.AEAD, plain
 
 // decrypt is used to decrypt a value
 func (b *AESGCMBarrier) decrypt(path string, gcm cipher.AEAD, cipher []byte) ([]byte, error) {
+	if len(cipher) < 5+gcm.NonceSize()+gcm.Overhead() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+
 	// Verify the term is always just one
 	term := binary.BigEndian.Uint32(cipher[:4])
 	if term != initialKeyTerm {
@@ -772,6 +877,10 @@ func (b *AESGCMBarrier) decrypt(path string, gcm cipher.AEAD, cipher []byte) ([]
 
 // decryptKeyring is used to decrypt a value using the keyring
 func (b *AESGCMBarrier) decryptKeyring(path string, cipher []byte) ([]byte, error) {
+	if len(cipher) < 5 {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+
 	// Verify the term
 	term := binary.BigEndian.Uint32(cipher[:4])
 
@@ -785,6 +894,22 @@ func (b *AESGCMBarrier) decryptKeyring(path string, cipher []byte) ([]byte, erro
 	if gcm == nil {
 		return nil, fmt.Errorf("no decryption key available for term %d", term)
 	}
+	if len(cipher) < 5+gcm.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+
+	// Values written by PutStream are decrypted in full
+	if cipher[4] == AESGCMVersionStream {
+		r, err := newStreamReader(path, gcm, cipher)
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return ioutil.ReadAll(r)
+	}
+	if len(cipher) < 5+gcm.NonceSize()+gcm.Overhead() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
 
 	nonce := cipher[5 : 5+gcm.NonceSize()]
 	raw := cipher[5+gcm.NonceSize():]
@@ -41,6 +41,80 @@ func TestAESGCMBarrier_Rotate(t *testing.T) {
 	testBarrier_Rotate(t, b)
 }
 
+func TestAESGCMBarrier_Rotate_ChaCha20Poly1305(t *testing.T) {
+	inm := physical.NewInmem()
+	b, err := NewAESGCMBarrier(inm)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := b.SetAlgorithm(BarrierAlgorithmChaCha20Poly1305); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	testBarrier_Rotate(t, b)
+}
+
+func TestAESGCMBarrier_MixedAlgorithms(t *testing.T) {
+	inm, barrier, key := mockBarrier(t)
+	b := barrier.(*AESGCMBarrier)
+
+	// Write under AES-GCM
+	aesEntry := &Entry{Key: "aes", Value: []byte("aes-gcm")}
+	if err := b.Put(aesEntry); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Rotate to ChaCha20-Poly1305 and write again
+	if err := b.SetAlgorithm(BarrierAlgorithmChaCha20Poly1305); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	term, err := b.Rotate()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	info, err := b.ActiveKeyInfo()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if info.Term != int(term) {
+		t.Fatalf("bad: %#v", info)
+	}
+	if alg := b.keyring.TermKey(term).Algorithm; alg != BarrierAlgorithmChaCha20Poly1305 {
+		t.Fatalf("bad: %s", alg)
+	}
+	chachaEntry := &Entry{Key: "chacha", Value: []byte("chacha20-poly1305")}
+	if err := b.Put(chachaEntry); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// A fresh barrier using the default algorithm must read both terms
+	b2, err := NewAESGCMBarrier(inm)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := b2.Unseal(key); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for _, expect := range []*Entry{aesEntry, chachaEntry} {
+		out, err := b2.Get(expect.Key)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if out == nil || !bytes.Equal(out.Value, expect.Value) {
+			t.Fatalf("bad: %#v", out)
+		}
+	}
+}
+
+func TestAESGCMBarrier_SetAlgorithm_Invalid(t *testing.T) {
+	b, err := NewAESGCMBarrier(physical.NewInmem())
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := b.SetAlgorithm("rot13"); err == nil {
+		t.Fatalf("should fail")
+	}
+}
+
 func TestAESGCMBarrier_Upgrade(t *testing.T) {
 	inm := physical.NewInmem()
 	b1, err := NewAESGCMBarrier(inm)
@@ -193,6 +267,33 @@ func TestAESGCMBarrier_Confidential(t *testing.T) {
 	}
 }
 
+func TestAESGCMBarrier_EmptyValue(t *testing.T) {
+	_, b, _ := mockBarrier(t)
+
+	entry := &Entry{Key: "test"}
+	if err := b.Put(entry); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The key is present with an empty value
+	out, err := b.Get("test")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if out == nil || out.Value == nil || len(out.Value) != 0 {
+		t.Fatalf("bad: %#v", out)
+	}
+
+	// A missing key is absent
+	out, err = b.Get("missing")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if out != nil {
+		t.Fatalf("bad: %#v", out)
+	}
+}
+
 // Verify data sent through cannot be tampered with
 func TestAESGCMBarrier_Integrity(t *testing.T) {
 	inm := physical.NewInmem()
@@ -413,3 +514,47 @@ func TestInitialize_KeyLength(t *testing.T) {
 		t.Fatalf("key length protection failed")
 	}
 }
+
+func TestAESGCMBarrier_Truncated(t *testing.T) {
+	inm := physical.NewInmem()
+	b, err := NewAESGCMBarrier(inm)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Initialize and unseal
+	key, _ := b.GenerateKey()
+	b.Initialize(key)
+	b.Unseal(key)
+
+	entry := &Entry{Key: "test", Value: []byte("test")}
+	if err := b.Put(entry); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Every truncation of the entry and of the keyring fails to verify
+	for _, path := range []string{"test", keyringPath} {
+		pe, err := inm.Get(path)
+		if err != nil || pe == nil {
+			t.Fatalf("err: %v %v", err, pe)
+		}
+		value := pe.Value
+		for n := 0; n < len(value); n++ {
+			pe.Value = value[:n]
+			if err := inm.Put(pe); err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			ok, err := b.VerifyEntry(path)
+			if err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			if ok {
+				t.Fatalf("truncated to %d bytes verified: %s", n, path)
+			}
+		}
+	}
+
+	if _, err := b.Get("test"); err == nil {
+		t.Fatalf("expected error")
+	}
+}
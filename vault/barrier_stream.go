@@ -0,0 +1,230 @@
+package vault
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"github.com/armon/go-metrics"
+	"github.com/hashicorp/vault/physical"
+)
+
+const (
+	// AESGCMVersionStream marks a value encrypted in chunks by PutStream.
+	// After the term and version byte, the header holds the chunk size
+	// and the nonce prefix of the chunks.
+	AESGCMVersionStream = 0x3
+
+	// barrierStreamChunkSize is the default size of the plaintext of
+	// each chunk of a streamed value
+	barrierStreamChunkSize = 64 * 1024
+
+	// streamHeaderSize is the size of the header of a streamed value,
+	// excluding the nonce prefix
+	streamHeaderSize = termSize + 1 + 4
+)
+
+// PutStream is used to insert or update an entry with the value read
+// from r. The value is encrypted a chunk at a time, so the plaintext is
+// never held in full. The physical backend stores a value as a single
+// entry though, so the whole ciphertext is buffered before it is written.
+// Each chunk is authenticated along with its index and whether it is the
+// last one, so chunks cannot be reordered or the value truncated.
+func (b *AESGCMBarrier) PutStream(key string, r io.Reader) error {
+	defer metrics.MeasureSince([]string{"barrier", "put_stream"}, time.Now())
+	b.l.RLock()
+	defer b.l.RUnlock()
+	if b.sealed {
+		return ErrBarrierSealed
+	}
+
+	term := b.keyring.ActiveTerm()
+	primary, err := b.aeadForTerm(term)
+	if err != nil {
+		return err
+	}
+
+	chunkSize := b.streamChunkSize
+	if chunkSize <= 0 {
+		chunkSize = barrierStreamChunkSize
+	}
+
+	// Write the header
+	out := make([]byte, streamHeaderSize+primary.NonceSize())
+	binary.BigEndian.PutUint32(out[:4], term)
+	out[4] = AESGCMVersionStream
+	binary.BigEndian.PutUint32(out[5:9], uint32(chunkSize))
+	prefix := out[streamHeaderSize:]
+	if _, err := rand.Read(prefix); err != nil {
+		return err
+	}
+
+	// Read ahead by a chunk to know which one is the last
+	cur, next := make([]byte, chunkSize), make([]byte, chunkSize)
+	defer memzero(cur)
+	defer memzero(next)
+	n, err := io.ReadFull(r, cur)
+	for idx := uint64(0); ; idx++ {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			out = sealChunk(out, key, primary, prefix, idx, true, cur[:n])
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		m, nextErr := io.ReadFull(r, next)
+		if nextErr == io.EOF {
+			out = sealChunk(out, key, primary, prefix, idx, true, cur[:n])
+			break
+		}
+		out = sealChunk(out, key, primary, prefix, idx, false, cur[:n])
+		cur, next = next, cur
+		n, err = m, nextErr
+	}
+
+	return b.backend.Put(&physical.Entry{Key: key, Value: out})
+}
+
+// GetStream is used to fetch a reader of the value of an entry, or nil
+// if it does not exist. The whole ciphertext is read from the physical
+// backend, but values written by PutStream are decrypted a chunk at a time
+// as they are read.
+func (b *AESGCMBarrier) GetStream(key string) (io.ReadCloser, error) {
+	defer metrics.MeasureSince([]string{"barrier", "get_stream"}, time.Now())
+	b.l.RLock()
+	defer b.l.RUnlock()
+	if b.sealed {
+		return nil, ErrBarrierSealed
+	}
+
+	pe, err := b.backend.Get(key)
+	if err != nil {
+		return nil, err
+	} else if pe == nil {
+		return nil, nil
+	}
+
+	if len(pe.Value) > termSize && pe.Value[4] == AESGCMVersionStream {
+		gcm, err := b.aeadForTerm(binary.BigEndian.Uint32(pe.Value[:4]))
+		if err != nil {
+			return nil, err
+		}
+		if gcm == nil {
+			return nil, fmt.Errorf("decryption failed: no decryption key available")
+		}
+		return newStreamReader(key, gcm, pe.Value)
+	}
+
+	plain, err := b.decryptKeyring(key, pe.Value)
+	if err != nil {
+		return nil, fmt.Errorf("decryption failed: %v", err)
+	}
+	return ioutil.NopCloser(bytes.NewReader(plain)), nil
+}
+
+// sealChunk appends the encrypted chunk of a streamed value to out
+func sealChunk(out []byte, path string, gcm cipher.AEAD, prefix []byte,
+	idx uint64, final bool, plain []byte) []byte {
+	return gcm.Seal(out, chunkNonce(prefix, idx), plain, chunkData(path, idx, final))
+}
+
+// chunkNonce returns the nonce of a chunk, which is the nonce prefix
+// of the value with the chunk index mixed into its last bytes
+func chunkNonce(prefix []byte, idx uint64) []byte {
+	nonce := make([]byte, len(prefix))
+	copy(nonce, prefix)
+	tail := nonce[len(nonce)-8:]
+	binary.BigEndian.PutUint64(tail, binary.BigEndian.Uint64(tail)^idx)
+	return nonce
+}
+
+// chunkData returns the additional data authenticated with a chunk
+func chunkData(path string, idx uint64, final bool) []byte {
+	data := make([]byte, len(path)+9)
+	copy(data, path)
+	binary.BigEndian.PutUint64(data[len(path):], idx)
+	if final {
+		data[len(data)-1] = 1
+	}
+	return data
+}
+
+// streamReader decrypts the chunks of a streamed value as it is read
+type streamReader struct {
+	path      string
+	gcm       cipher.AEAD
+	prefix    []byte
+	chunkSize int
+	remain    []byte
+	idx       uint64
+	done      bool
+	plain     []byte
+	buf       []byte
+}
+
+// newStreamReader returns a reader of the value encrypted in chunks
+func newStreamReader(path string, gcm cipher.AEAD, value []byte) (*streamReader, error) {
+	if len(value) < streamHeaderSize+gcm.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	chunkSize := int(binary.BigEndian.Uint32(value[5:9]))
+	if chunkSize <= 0 {
+		return nil, fmt.Errorf("invalid chunk size")
+	}
+	return &streamReader{
+		path:      path,
+		gcm:       gcm,
+		prefix:    value[streamHeaderSize : streamHeaderSize+gcm.NonceSize()],
+		chunkSize: chunkSize,
+		remain:    value[streamHeaderSize+gcm.NonceSize():],
+	}, nil
+}
+
+func (s *streamReader) Read(p []byte) (int, error) {
+	for len(s.plain) == 0 {
+		if s.done {
+			return 0, io.EOF
+		}
+		if err := s.openChunk(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, s.plain)
+	s.plain = s.plain[n:]
+	return n, nil
+}
+
+// openChunk decrypts the next chunk. Every chunk but the last holds a
+// full chunk of plaintext.
+func (s *streamReader) openChunk() error {
+	size := s.chunkSize + s.gcm.Overhead()
+	final := len(s.remain) <= size
+	if final {
+		size = len(s.remain)
+	}
+
+	memzero(s.buf)
+	plain, err := s.gcm.Open(s.buf[:0], chunkNonce(s.prefix, s.idx),
+		s.remain[:size], chunkData(s.path, s.idx, final))
+	if err != nil {
+		return fmt.Errorf("decryption failed: %v", err)
+	}
+	s.buf = plain
+	s.plain = plain
+	s.remain = s.remain[size:]
+	s.done = final
+	s.idx++
+	return nil
+}
+
+func (s *streamReader) Close() error {
+	memzero(s.buf)
+	s.plain = nil
+	return nil
+}
@@ -0,0 +1,199 @@
+package vault
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/physical"
+)
+
+func testRandomValue(t *testing.T, size int) []byte {
+	value := make([]byte, size)
+	if _, err := rand.Read(value); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	return value
+}
+
+func testReadStream(t *testing.T, r io.ReadCloser) []byte {
+	if r == nil {
+		t.Fatalf("missing value")
+	}
+	defer r.Close()
+	out, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	return out
+}
+
+func TestAESGCMBarrier_Stream(t *testing.T) {
+	inm, barrier, _ := mockBarrier(t)
+	b := barrier.(*AESGCMBarrier)
+
+	// A large value with the default chunk size
+	value := testRandomValue(t, 4*barrierStreamChunkSize+7)
+	if err := b.PutStream("test", bytes.NewReader(value)); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	r, err := b.GetStream("test")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if out := testReadStream(t, r); !bytes.Equal(out, value) {
+		t.Fatalf("value mismatch")
+	}
+
+	// Every chunk is sealed separately
+	pe, err := inm.Get("test")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if pe.Value[4] != AESGCMVersionStream {
+		t.Fatalf("bad version: %d", pe.Value[4])
+	}
+	if expected := streamHeaderSize + 12 + len(value) + 5*16; len(pe.Value) != expected {
+		t.Fatalf("bad size: %d %d", len(pe.Value), expected)
+	}
+
+	// Values on chunk boundaries round-trip through both read paths
+	b.streamChunkSize = 16
+	for _, size := range []int{0, 1, 15, 16, 17, 32, 100} {
+		value := testRandomValue(t, size)
+		if err := b.PutStream("test", bytes.NewReader(value)); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		r, err := b.GetStream("test")
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if out := testReadStream(t, r); !bytes.Equal(out, value) {
+			t.Fatalf("value mismatch for size %d", size)
+		}
+		entry, err := b.Get("test")
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if entry == nil || !bytes.Equal(entry.Value, value) {
+			t.Fatalf("value mismatch for size %d", size)
+		}
+	}
+
+	// Buffered values are streamed too
+	if err := b.Put(&Entry{Key: "buffered", Value: []byte("foo")}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	r, err = b.GetStream("buffered")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if out := testReadStream(t, r); string(out) != "foo" {
+		t.Fatalf("bad: %s", out)
+	}
+
+	// Missing keys return nil
+	if r, err := b.GetStream("missing"); err != nil || r != nil {
+		t.Fatalf("bad: %v %v", r, err)
+	}
+
+	// Streaming fails while sealed
+	b.Seal()
+	if err := b.PutStream("test", bytes.NewReader(value)); err != ErrBarrierSealed {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := b.GetStream("test"); err != ErrBarrierSealed {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestAESGCMBarrier_StreamIntegrity(t *testing.T) {
+	inm, barrier, _ := mockBarrier(t)
+	b := barrier.(*AESGCMBarrier)
+	b.streamChunkSize = 16
+
+	value := testRandomValue(t, 40)
+	if err := b.PutStream("test", bytes.NewReader(value)); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	pe, _ := inm.Get("test")
+	orig := pe.Value
+	header := streamHeaderSize + 12
+	chunk := 16 + 16
+
+	tampered := map[string][]byte{
+		// Drop the last chunk
+		"truncated": orig[:header+2*chunk],
+
+		// Swap the first two chunks
+		"reordered": append(append(append(append([]byte{}, orig[:header]...),
+			orig[header+chunk:header+2*chunk]...), orig[header:header+chunk]...),
+			orig[header+2*chunk:]...),
+	}
+	for name, value := range tampered {
+		if err := inm.Put(&physical.Entry{Key: "test", Value: value}); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		r, err := b.GetStream("test")
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if _, err := ioutil.ReadAll(r); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+		if _, err := b.Get("test"); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+
+	// Moving the value to another key is detected
+	if err := inm.Put(&physical.Entry{Key: "moved", Value: orig}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := b.Get("moved"); err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+// bufferedBarrier hides the streaming methods of a barrier
+type bufferedBarrier struct {
+	BarrierStorage
+}
+
+func TestBarrierView_Stream(t *testing.T) {
+	_, barrier, _ := mockBarrier(t)
+	value := testRandomValue(t, 3*barrierStreamChunkSize)
+
+	for _, storage := range []BarrierStorage{barrier, bufferedBarrier{barrier}} {
+		view := NewBarrierView(storage, "foo/")
+		if err := logical.PutStream(view, "test", bytes.NewReader(value)); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		r, err := logical.GetStream(view, "test")
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if out := testReadStream(t, r); !bytes.Equal(out, value) {
+			t.Fatalf("value mismatch")
+		}
+
+		// The value is stored under the prefix
+		entry, err := barrier.Get("foo/test")
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if entry == nil || !bytes.Equal(entry.Value, value) {
+			t.Fatalf("value mismatch")
+		}
+
+		if r, err := view.GetStream("missing"); err != nil || r != nil {
+			t.Fatalf("bad: %v %v", r, err)
+		}
+		if err := view.PutStream("../test", bytes.NewReader(value)); err == nil {
+			t.Fatalf("expected error")
+		}
+	}
+}
@@ -1,7 +1,10 @@
 package vault
 
 import (
+	"bytes"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"strings"
 
 	"github.com/hashicorp/vault/logical"
@@ -86,6 +89,40 @@ func (v *BarrierView) Delete(key string) error {
 	return v.barrier.Delete(v.expandKey(key))
 }
 
+// logical.StreamingStorage impl. The value is buffered if the barrier
+// does not stream.
+func (v *BarrierView) PutStream(key string, r io.Reader) error {
+	if err := v.sanityCheck(key); err != nil {
+		return err
+	}
+	if s, ok := v.barrier.(logical.StreamingStorage); ok {
+		return s.PutStream(v.expandKey(key), r)
+	}
+
+	value, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return v.Put(&logical.StorageEntry{Key: key, Value: value})
+}
+
+// logical.StreamingStorage impl. The value is buffered if the barrier
+// does not stream.
+func (v *BarrierView) GetStream(key string) (io.ReadCloser, error) {
+	if err := v.sanityCheck(key); err != nil {
+		return nil, err
+	}
+	if s, ok := v.barrier.(logical.StreamingStorage); ok {
+		return s.GetStream(v.expandKey(key))
+	}
+
+	entry, err := v.Get(key)
+	if err != nil || entry == nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(bytes.NewReader(entry.Value)), nil
+}
+
 // SubView constructs a nested sub-view using the given prefix
 func (v *BarrierView) SubView(prefix string) *BarrierView {
 	sub := v.expandKey(prefix)
@@ -6,7 +6,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log"
+	"math/rand"
 	"net/url"
 	"os"
 	"strings"
@@ -58,16 +60,35 @@ const (
 	// leaderPrefixCleanDelay is how long to wait between deletions
 	// of orphaned leader keys, to prevent slamming the backend.
 	leaderPrefixCleanDelay = 200 * time.Millisecond
+
+	// revokeLeasesTimeout is how long Seal spends revoking leases when
+	// configured to revoke them, before sealing regardless
+	revokeLeasesTimeout = 30 * time.Second
+
+	// coreStorageProbePath is the path under which the physical backend
+	// is verified to accept writes before the unseal is completed. Each
+	// probe writes to its own random key, so that the nodes of an HA
+	// cluster never collide. The value is random, stored in plaintext
+	// and removed once verified.
+	coreStorageProbePath = "core/storage-probe"
+
+	// unsealFailureDelay is the default minimum time taken by an unseal
+	// attempt that does not unseal the Vault
+	unsealFailureDelay = 100 * time.Millisecond
 )
 
+// SealTypeShamir is the seal type of unsealing by submitting key shares.
+// Any other seal type is the Type of the Seal configured for the core.
+const SealTypeShamir = "shamir"
+
 var (
 	// ErrSealed is returned if an operation is performed on
 	// a sealed barrier. No operation is expected to succeed before unsealing
-	ErrSealed = errors.New("Vault is sealed")
+	ErrSealed = logical.NewUnavailable("Vault is sealed")
 
 	// ErrStandby is returned if an operation is performed on
 	// a standby Vault. No operation is expected to succeed until active.
-	ErrStandby = errors.New("Vault is in standby mode")
+	ErrStandby = logical.NewUnavailable("Vault is in standby mode")
 
 	// ErrAlreadyInit is returned if the core is already
 	// initialized. This prevents a re-initialization.
@@ -75,7 +96,7 @@ var (
 
 	// ErrNotInit is returned if a non-initialized barrier
 	// is attempted to be unsealed.
-	ErrNotInit = errors.New("Vault is not initialized")
+	ErrNotInit = logical.NewInvalidRequest("Vault is not initialized")
 
 	// ErrInternalError is returned when we don't want to leak
 	// any information about an internal error
@@ -84,6 +105,14 @@ var (
 	// ErrHANotEnabled is returned if the operation only makes sense
 	// in an HA setting
 	ErrHANotEnabled = errors.New("Vault is not configured for highly-available mode")
+
+	// ErrSealTypeNotPermitted is returned if the Vault is unsealed using
+	// a seal type other than the one required by the configuration
+	ErrSealTypeNotPermitted = logical.NewInvalidRequest("unseal using this seal type is not permitted")
+
+	// ErrNoStoredKey is returned if the Vault is unsealed using the
+	// stored master key but no seal storing the master key is configured
+	ErrNoStoredKey = errors.New("no seal storing the master key is configured")
 )
 
 // SealConfig is used to describe the seal configuration
@@ -114,11 +143,11 @@ func (s *SealConfig) Validate() error {
 	if s.SecretShares > 1 && s.SecretThreshold == 1 {
 		return fmt.Errorf("secret threshold must be greater than one for multiple shares")
 	}
-	if s.SecretShares > 255 {
-		return fmt.Errorf("secret shares must be less than 256")
+	if s.SecretShares > shamir.MaxShares {
+		return fmt.Errorf("secret shares must be less than %d", shamir.MaxShares+1)
 	}
-	if s.SecretThreshold > 255 {
-		return fmt.Errorf("secret threshold must be less than 256")
+	if s.SecretThreshold > shamir.MaxShares {
+		return fmt.Errorf("secret threshold must be less than %d", shamir.MaxShares+1)
 	}
 	if s.SecretThreshold > s.SecretShares {
 		return fmt.Errorf("secret threshold cannot be larger than secret shares")
@@ -142,10 +171,13 @@ func (s *SealConfig) Validate() error {
 }
 
 // InitResult is used to provide the key parts back after
-// they are generated as part of the initialization.
+// they are generated as part of the initialization. If the master key
+// is stored by a seal, recovery shares are generated instead of unseal
+// shares.
 type InitResult struct {
-	SecretShares [][]byte
-	RootToken    string
+	UnsealShares   [][]byte
+	RecoveryShares [][]byte
+	RootToken      string
 }
 
 // RekeyResult is used to provide the key parts back after
@@ -200,6 +232,14 @@ type Core struct {
 	standbyDoneCh chan struct{}
 	standbyStopCh chan struct{}
 
+	// standbyOnLeadershipLoss returns an active node that loses the HA
+	// lock to standby, rather than sealing it
+	standbyOnLeadershipLoss bool
+
+	// unsealProgressKey, if set, encrypts the unlockParts persisted so
+	// that the unseal progress survives a restart
+	unsealProgressKey []byte
+
 	// unlockParts has the keys provided to Unseal until
 	// the threshold number of parts is available.
 	unlockParts [][]byte
@@ -236,15 +276,28 @@ type Core struct {
 	// rollback manager is used to run rollbacks periodically
 	rollback *RollbackManager
 
+	// rotation manager is used to rotate static credentials on a schedule
+	rotation *RotationManager
+
 	// policy store is used to manage named ACL policies
 	policyStore *PolicyStore
 
+	// passwordPolicyStore is used to manage the policies of passwords
+	// generated by backends
+	passwordPolicyStore *PasswordPolicyStore
+
+	// mfaStore is used to manage the MFA secrets enrolled for tokens
+	mfaStore *MFAStore
+
 	// token store is used to manage authentication tokens
 	tokenStore *TokenStore
 
 	// metricsCh is used to stop the metrics streaming
 	metricsCh chan struct{}
 
+	// metricsSink is the in-memory sink the metrics are snapshotted from
+	metricsSink *metrics.InmemSink
+
 	// metricsMutex is used to prevent a race condition between
 	// metrics emission and sealing leading to a nil pointer
 	metricsMutex sync.Mutex
@@ -252,22 +305,220 @@ type Core struct {
 	defaultLeaseTTL time.Duration
 	maxLeaseTTL     time.Duration
 
+	// systemConfig is the system configuration set at runtime, which
+	// overrides the lease TTLs above
+	systemConfig     *systemConfig
+	systemConfigLock sync.RWMutex
+
+	// requiredSealType, if set, is the only seal type that is
+	// permitted to unseal the Vault
+	requiredSealType string
+
+	// seal, if set, stores the master key so that the Vault is
+	// unsealed without key shares
+	seal Seal
+
+	// tokenIDLength is the number of random bytes in generated token
+	// IDs. Zero generates a UUID.
+	tokenIDLength int
+
+	// disableAuditReads excludes read operations from the audit log,
+	// unless overridden by the mount
+	disableAuditReads bool
+
+	// revokeLeasesOnSeal drains every lease when sealing through Seal,
+	// giving up after revokeLeasesTimeout
+	revokeLeasesOnSeal  bool
+	revokeLeasesTimeout time.Duration
+
+	// storageBreaker wraps the physical backend to act on repeated
+	// write failures, if configured
+	storageBreaker *storageBreaker
+
+	// maxPoliciesPerToken limits the number of policies a token may
+	// have, not counting the root and default policies. Zero is unlimited.
+	maxPoliciesPerToken int
+
+	// disableDenyOverrides makes the most specific rule matching a path
+	// win, even over a matching deny rule
+	disableDenyOverrides bool
+
+	// maxTokenDepth limits the depth of a token in the token hierarchy.
+	// Zero is unlimited.
+	maxTokenDepth int
+
+	// maxLeaseClockSkew is how far ahead of the current time the issue
+	// time of a restored lease may be. Zero disables the check.
+	maxLeaseClockSkew time.Duration
+
+	// maxAuthTableSize limits the size in bytes of the serialized auth
+	// table. Zero is unlimited.
+	maxAuthTableSize int
+
+	// maxUnsealShares limits the number of key shares generated when
+	// initializing or rekeying
+	maxUnsealShares int
+
+	// keyringLock is held for reading by rewraps and for writing by key
+	// rotations, so that a rewrap sees a stable keyring. rewrapsInFlight
+	// counts the rewraps in progress, which reject rotations if
+	// rotateDuringRewrap is RotateDuringRewrapReject.
+	keyringLock        sync.RWMutex
+	rewrapsInFlight    int32
+	rotateDuringRewrap string
+
+	// backendInitTimeout is how long a credential backend may take to
+	// initialize before it fails permanently, backendInitRetry is the
+	// time between attempts and backendInitCallTimeout bounds a single
+	// attempt. credInits holds the channels stopping the pending
+	// initializations by path, protected by credInitLock.
+	backendInitTimeout     time.Duration
+	backendInitRetry       time.Duration
+	backendInitCallTimeout time.Duration
+	credInits              map[string]chan struct{}
+	credInitLock           sync.Mutex
+
+	// unsealFailureDelay is the minimum time taken by an unseal attempt
+	// that does not unseal the Vault, so the reason cannot be told apart
+	// by timing. Zero or negative disables it.
+	unsealFailureDelay time.Duration
+
+	// mountRetention is how long the data of a disabled mount is kept
+	// so the mount can be recovered. Zero deletes it immediately.
+	// retainedMounts is protected by retainedLock, and the reaper
+	// deleting expired data is stopped by closing retainedStopCh.
+	mountRetention time.Duration
+	retainedMounts []*retainedMount
+	retainedLock   sync.Mutex
+	retainedStopCh chan struct{}
+
+	// entropy, if set, generates the randomness of tokens and keys from
+	// the configured entropy source. It is reseeded every
+	// entropyReseedInterval until entropyStopCh is closed.
+	entropy               *entropyPool
+	entropyReseedInterval time.Duration
+	entropyStopCh         chan struct{}
+
+	// tokenSweepStopCh stops the periodic revocation of expired tokens,
+	// which closes tokenSweepDoneCh once it has exited
+	tokenSweepStopCh chan struct{}
+	tokenSweepDoneCh chan struct{}
+
+	// idleSealPeriod, if set, is how long the Vault stays unsealed
+	// without handling a request. lastRequest is the time of the last
+	// request in Unix nanoseconds, accessed atomically. The idle check is
+	// stopped by closing idleSealStopCh.
+	idleSealPeriod time.Duration
+	idleSealHA     bool
+	lastRequest    int64
+	idleSealStopCh chan struct{}
+
 	logger *log.Logger
 }
 
 // CoreConfig is used to parameterize a core
 type CoreConfig struct {
-	LogicalBackends    map[string]logical.Factory
-	CredentialBackends map[string]logical.Factory
-	AuditBackends      map[string]audit.Factory
-	Physical           physical.Backend
-	Logger             *log.Logger
-	DisableCache       bool   // Disables the LRU cache on the physical backend
-	DisableMlock       bool   // Disables mlock syscall
-	CacheSize          int    // Custom cache size of zero for default
-	AdvertiseAddr      string // Set as the leader address for HA
-	DefaultLeaseTTL    time.Duration
-	MaxLeaseTTL        time.Duration
+	LogicalBackends     map[string]logical.Factory
+	CredentialBackends  map[string]logical.Factory
+	AuditBackends       map[string]audit.Factory
+	Physical            physical.Backend
+	Logger              *log.Logger
+	DisableCache        bool   // Disables the LRU cache on the physical backend
+	DisableMlock        bool   // Disables mlock syscall
+	CacheSize           int    // Custom cache size of zero for default
+	AdvertiseAddr       string // Set as the leader address for HA
+	DefaultLeaseTTL     time.Duration
+	MaxLeaseTTL         time.Duration
+	BarrierAlgorithm    string        // Encryption algorithm for new barrier keys
+	RequiredSealType    string        // Only seal type permitted to unseal, if set
+	Seal                Seal          // Seal storing the master key; nil for key shares
+	MaxRequestsPerToken int           // Limit of concurrent requests per token; zero for none
+	MaxPoliciesPerToken int           // Limit of policies attached to a token; zero for none
+	MaxTokenDepth       int           // Limit of the depth of the token hierarchy; zero for none
+	BackendInitTimeout  time.Duration // How long a credential backend may take to become ready
+	UnsealFailureDelay  time.Duration // Minimum time of a failed unseal; zero for the default, negative for none
+	MountRetention      time.Duration // How long data of disabled mounts is kept for recovery; zero for none
+	TokenIDLength       int           // Bytes of entropy in generated token IDs; zero for a UUID
+	MaxAuthTableSize    int           // Limit in bytes of the serialized auth table; zero for none
+	MaxUnsealShares     int           // Limit of generated key shares; zero for the Shamir limit
+	GlobalRateLimit     int           // Limit of requests per second for all backends; zero for none
+	GlobalRateBurst     int           // Requests allowed in a burst above GlobalRateLimit
+
+	// StorageFailureThreshold is the number of consecutive storage write
+	// failures after which StorageFailureAction is taken. Zero disables it.
+	StorageFailureThreshold int
+	StorageFailureAction    string // StorageFailureReadOnly (default) or StorageFailureSeal
+
+	// RotateDuringRewrap is RotateDuringRewrapQueue (default) or
+	// RotateDuringRewrapReject
+	RotateDuringRewrap string
+
+	// EntropySource, if set, is mixed with crypto/rand to seed the
+	// generator of token IDs and barrier keys. Other identifiers, such as
+	// the UUIDs of mounts, only use crypto/rand. The generator is reseeded
+	// from both every EntropyReseedInterval; zero never reseeds.
+	EntropySource         io.Reader
+	EntropyReseedInterval time.Duration
+
+	// UnsealProgressKey, if set, is the 32 byte key used to encrypt the
+	// key shares provided so far, which are then persisted to the
+	// physical backend so that a restart resumes the unseal. This weakens
+	// security, since the shares are stored outside the operators'
+	// control: anyone holding the key and the storage has them.
+	UnsealProgressKey []byte
+
+	// IdleSealPeriod, if set, seals the Vault once no request has been
+	// handled for this long. It is ignored on HA nodes unless IdleSealHA
+	// is set, so that an idle active node does not seal the cluster.
+	IdleSealPeriod time.Duration
+	IdleSealHA     bool
+
+	// Tracer, if set, emits spans around handling each request, with
+	// children for checking the ACL, resolving the mount and handling it
+	// in the backend
+	Tracer Tracer
+
+	// DisabledOperations are rejected for every path. Only operations
+	// that clients can request may be disabled.
+	DisabledOperations []logical.Operation
+
+	// LogLevel is the global log level, used to decide whether requests
+	// are logged for mounts that do not set their own level
+	LogLevel string
+
+	// DisableDenyOverrides makes the most specific rule matching a path
+	// decide, even over a matching deny rule. By default a deny rule wins
+	// over every other rule for the path.
+	DisableDenyOverrides bool
+
+	// RequestLogger, if set, is used to log requests instead of Logger.
+	// It must not filter by level, since the level of each mount decides
+	// whether its requests are logged.
+	RequestLogger *log.Logger
+
+	// DisableAuditReads excludes read and list operations from the audit
+	// log, unless a mount overrides it. Other operations and logins are
+	// always audited.
+	DisableAuditReads bool
+
+	// RevokeLeasesOnSeal revokes every lease when the Vault is sealed
+	// through Seal, rather than leaving them to expire
+	RevokeLeasesOnSeal bool
+
+	// StandbyOnLeadershipLoss returns an active HA node that loses the
+	// lock to standby. By default it seals itself, so that it must be
+	// unsealed again before it can take over.
+	StandbyOnLeadershipLoss bool
+
+	// MaxLeaseClockSkew, if set, is how far ahead of the current time the
+	// issue time of a lease restored from storage may be. Leases issued
+	// further ahead, e.g. by a node whose clock jumped forward, are
+	// rebased to expire after their original TTL from now.
+	MaxLeaseClockSkew time.Duration
+
+	// MetricsSink is the in-memory sink of the metrics, used to take
+	// snapshots of the metrics
+	MetricsSink *metrics.InmemSink
 }
 
 // NewCore is used to construct a new core
@@ -292,6 +543,72 @@ func NewCore(conf *CoreConfig) (*Core, error) {
 		return nil, fmt.Errorf("cannot have DefaultLeaseTTL larger than MaxLeaseTTL")
 	}
 
+	if conf.Seal != nil && conf.Seal.Type() == SealTypeShamir {
+		return nil, fmt.Errorf("seal type '%s' is reserved for key shares", SealTypeShamir)
+	}
+	switch {
+	case conf.RequiredSealType == "":
+	case conf.Seal != nil:
+		if conf.RequiredSealType != conf.Seal.Type() {
+			return nil, fmt.Errorf("required seal type '%s' does not match the seal type '%s'",
+				conf.RequiredSealType, conf.Seal.Type())
+		}
+	case conf.RequiredSealType != SealTypeShamir:
+		return nil, fmt.Errorf("unknown required seal type '%s'", conf.RequiredSealType)
+	}
+
+	if conf.TokenIDLength != 0 && conf.TokenIDLength < minTokenIDLength {
+		return nil, fmt.Errorf("token ID length must be at least %d bytes", minTokenIDLength)
+	}
+
+	if conf.GlobalRateLimit < 0 || conf.GlobalRateBurst < 0 {
+		return nil, fmt.Errorf("global rate limit must not be negative")
+	}
+
+	if conf.MaxTokenDepth < 0 {
+		return nil, fmt.Errorf("max token depth must not be negative")
+	}
+
+	if conf.MaxAuthTableSize < 0 {
+		return nil, fmt.Errorf("max auth table size must not be negative")
+	}
+
+	if conf.MaxUnsealShares == 0 {
+		conf.MaxUnsealShares = shamir.MaxShares
+	}
+	if conf.MaxUnsealShares < 0 || conf.MaxUnsealShares > shamir.MaxShares {
+		return nil, fmt.Errorf("max unseal shares must be between 1 and %d", shamir.MaxShares)
+	}
+
+	switch conf.StorageFailureAction {
+	case "":
+		conf.StorageFailureAction = StorageFailureReadOnly
+	case StorageFailureReadOnly, StorageFailureSeal:
+	default:
+		return nil, fmt.Errorf("unknown storage failure action '%s'", conf.StorageFailureAction)
+	}
+
+	switch conf.RotateDuringRewrap {
+	case "":
+		conf.RotateDuringRewrap = RotateDuringRewrapQueue
+	case RotateDuringRewrapQueue, RotateDuringRewrapReject:
+	default:
+		return nil, fmt.Errorf("unknown rotate during rewrap behavior '%s'", conf.RotateDuringRewrap)
+	}
+
+	if conf.LogLevel != "" && logLevelIndex(conf.LogLevel) < 0 {
+		return nil, fmt.Errorf("unknown log level '%s'", conf.LogLevel)
+	}
+
+	for _, op := range conf.DisabledOperations {
+		switch op {
+		case logical.ReadOperation, logical.WriteOperation, logical.DeleteOperation,
+			logical.ListOperation, logical.HelpOperation:
+		default:
+			return nil, fmt.Errorf("cannot disable operation '%s'", op)
+		}
+	}
+
 	// Validate the advertise addr if its given to us
 	if conf.AdvertiseAddr != "" {
 		u, err := url.Parse(conf.AdvertiseAddr)
@@ -330,11 +647,49 @@ func NewCore(conf *CoreConfig) (*Core, error) {
 		}
 	}
 
+	// Track consecutive write failures if configured
+	var breaker *storageBreaker
+	if conf.StorageFailureThreshold > 0 {
+		breaker = newStorageBreaker(conf.Physical, conf.StorageFailureThreshold, conf.StorageFailureAction)
+		conf.Physical = breaker
+	}
+
 	// Construct a new AES-GCM barrier
 	barrier, err := NewAESGCMBarrier(conf.Physical)
 	if err != nil {
 		return nil, fmt.Errorf("barrier setup failed: %v", err)
 	}
+	if conf.BarrierAlgorithm != "" {
+		if err := barrier.SetAlgorithm(conf.BarrierAlgorithm); err != nil {
+			return nil, fmt.Errorf("barrier setup failed: %v", err)
+		}
+	}
+
+	// Seed the generator from the entropy source
+	if conf.EntropyReseedInterval < 0 {
+		return nil, fmt.Errorf("entropy reseed interval must not be negative")
+	}
+	if conf.EntropyReseedInterval > 0 && conf.EntropySource == nil {
+		return nil, fmt.Errorf("entropy reseed interval requires an entropy source")
+	}
+	var entropy *entropyPool
+	if conf.EntropySource != nil {
+		entropy, err = newEntropyPool(conf.EntropySource)
+		if err != nil {
+			return nil, fmt.Errorf("entropy setup failed: %v", err)
+		}
+		barrier.SetRand(entropy)
+	}
+
+	if n := len(conf.UnsealProgressKey); n != 0 && n != unsealProgressKeySize {
+		return nil, fmt.Errorf("unseal progress key must be %d bytes", unsealProgressKeySize)
+	}
+	if conf.IdleSealPeriod < 0 {
+		return nil, fmt.Errorf("idle seal period must not be negative")
+	}
+	if conf.MaxLeaseClockSkew < 0 {
+		return nil, fmt.Errorf("max lease clock skew must not be negative")
+	}
 
 	// Make a default logger if not provided
 	if conf.Logger == nil {
@@ -343,17 +698,65 @@ func NewCore(conf *CoreConfig) (*Core, error) {
 
 	// Setup the core
 	c := &Core{
-		ha:              haBackend,
-		advertiseAddr:   conf.AdvertiseAddr,
-		physical:        conf.Physical,
-		barrier:         barrier,
-		router:          NewRouter(),
-		sealed:          true,
-		standby:         true,
-		logger:          conf.Logger,
-		defaultLeaseTTL: conf.DefaultLeaseTTL,
-		maxLeaseTTL:     conf.MaxLeaseTTL,
-	}
+		ha:               haBackend,
+		advertiseAddr:    conf.AdvertiseAddr,
+		physical:         conf.Physical,
+		barrier:          barrier,
+		router:           NewRouter(),
+		sealed:           true,
+		standby:          true,
+		logger:           conf.Logger,
+		defaultLeaseTTL:  conf.DefaultLeaseTTL,
+		maxLeaseTTL:      conf.MaxLeaseTTL,
+		requiredSealType: conf.RequiredSealType,
+		seal:             conf.Seal,
+
+		maxPoliciesPerToken: conf.MaxPoliciesPerToken,
+		maxTokenDepth:       conf.MaxTokenDepth,
+		metricsSink:         conf.MetricsSink,
+		tokenIDLength:       conf.TokenIDLength,
+		maxAuthTableSize:    conf.MaxAuthTableSize,
+		maxUnsealShares:     conf.MaxUnsealShares,
+		rotateDuringRewrap:  conf.RotateDuringRewrap,
+		backendInitTimeout:  conf.BackendInitTimeout,
+		unsealFailureDelay:  conf.UnsealFailureDelay,
+		mountRetention:      conf.MountRetention,
+		backendInitRetry:    backendInitRetryInterval,
+		storageBreaker:      breaker,
+		revokeLeasesOnSeal:  conf.RevokeLeasesOnSeal,
+		disableAuditReads:   conf.DisableAuditReads,
+		revokeLeasesTimeout: revokeLeasesTimeout,
+
+		entropy:               entropy,
+		entropyReseedInterval: conf.EntropyReseedInterval,
+		unsealProgressKey:     conf.UnsealProgressKey,
+		idleSealPeriod:        conf.IdleSealPeriod,
+		idleSealHA:            conf.IdleSealHA,
+		maxLeaseClockSkew:     conf.MaxLeaseClockSkew,
+
+		standbyOnLeadershipLoss: conf.StandbyOnLeadershipLoss,
+		backendInitCallTimeout:  backendInitCallTimeout,
+	}
+	if breaker != nil {
+		breaker.trip = c.storageFailureTripped
+	}
+	if c.backendInitTimeout == 0 {
+		c.backendInitTimeout = backendInitTimeout
+	}
+	if c.unsealFailureDelay == 0 {
+		c.unsealFailureDelay = unsealFailureDelay
+	}
+
+	c.disableDenyOverrides = conf.DisableDenyOverrides
+	c.router.SetTokenConcurrencyLimit(conf.MaxRequestsPerToken)
+	c.router.SetDisabledOperations(conf.DisabledOperations)
+	c.router.SetGlobalRateLimit(conf.GlobalRateLimit, conf.GlobalRateBurst)
+	requestLogger := conf.RequestLogger
+	if requestLogger == nil {
+		requestLogger = c.logger
+	}
+	c.router.SetRequestLogger(requestLogger, conf.LogLevel)
+	c.router.SetTracer(conf.Tracer)
 
 	// Setup the backends
 	logicalBackends := make(map[string]logical.Factory)
@@ -384,6 +787,11 @@ func NewCore(conf *CoreConfig) (*Core, error) {
 		auditBackends[k] = f
 	}
 	c.auditBackends = auditBackends
+
+	// Resume the unseal progress from before a restart
+	if err := c.loadUnsealProgress(); err != nil {
+		c.logger.Printf("[ERR] core: failed to resume unseal progress: %v", err)
+	}
 	return c, nil
 }
 
@@ -402,8 +810,14 @@ func (c *Core) Shutdown() error {
 	return c.sealInternal()
 }
 
-// HandleRequest is used to handle a new incoming request
+// HandleRequest is used to handle a new incoming request. The client
+// token is checked against the policies, and the request is routed to the
+// backend mounted at the longest prefix of the path.
 func (c *Core) HandleRequest(req *logical.Request) (resp *logical.Response, err error) {
+	if req == nil {
+		return nil, logical.ErrInvalidRequest
+	}
+
 	c.stateLock.RLock()
 	defer c.stateLock.RUnlock()
 	if c.sealed {
@@ -412,12 +826,28 @@ func (c *Core) HandleRequest(req *logical.Request) (resp *logical.Response, err
 	if c.standby {
 		return nil, ErrStandby
 	}
+	if !c.router.allowClientRequest() {
+		return logical.ErrorResponse(ErrGlobalRateLimitExceeded.Error()), ErrGlobalRateLimitExceeded
+	}
+	c.markRequest()
+	c.router.markAccessed(req.Path)
+	if modifiesStorage(req.Operation) && c.StorageReadOnly() {
+		return nil, ErrReadOnly
+	}
+
+	// Trace the request, with the stages of handling it as children
+	span := startSpan(c.router.requestTracer(), nil, SpanRequest, req.Operation)
+	span.SetAttribute(SpanAttrMount, c.router.MatchingMount(req.Path))
+	defer func() {
+		endSpan(span, err)
+	}()
 
 	var auth *logical.Auth
+	audit := c.auditRequest(req)
 	if c.router.LoginPath(req.Path) {
-		resp, auth, err = c.handleLoginRequest(req)
+		resp, auth, err = c.handleLoginRequest(req, span)
 	} else {
-		resp, auth, err = c.handleRequest(req)
+		resp, auth, err = c.handleRequest(req, audit, span)
 	}
 
 	// Ensure we don't leak internal data
@@ -431,20 +861,40 @@ func (c *Core) HandleRequest(req *logical.Request) (resp *logical.Response, err
 	}
 
 	// Create an audit trail of the response
-	if err := c.auditBroker.LogResponse(auth, req, resp, err); err != nil {
-		c.logger.Printf("[ERR] core: failed to audit response (request path: %s): %v",
-			req.Path, err)
-		return nil, ErrInternalError
+	if audit {
+		if err := c.auditBroker.LogResponse(auth, req, resp, err); err != nil {
+			c.logger.Printf("[ERR] core: failed to audit response (request path: %s): %v",
+				req.Path, err)
+			return nil, ErrInternalError
+		}
 	}
 
 	return
 }
 
-func (c *Core) handleRequest(req *logical.Request) (retResp *logical.Response, retAuth *logical.Auth, retErr error) {
+func (c *Core) handleRequest(req *logical.Request, audit bool, reqSpan Span) (retResp *logical.Response, retAuth *logical.Auth, retErr error) {
 	defer metrics.MeasureSince([]string{"core", "handle_request"}, time.Now())
 
 	// Validate the token
-	auth, te, err := c.checkToken(req.Operation, req.Path, req.ClientToken)
+	span := startSpan(nil, reqSpan, SpanACLCheck, req.Operation)
+	span.SetAttribute(SpanAttrMount, c.router.MatchingMount(req.Path))
+	auth, te, acl, err := c.checkToken(req.Operation, req.Path, req.ClientToken, req.RemoteAddr)
+
+	// Check the MFA methods required by the policies
+	if err == nil {
+		if err = c.checkMFA(req.Path, req.MFAPayload, te, acl); err != nil {
+			auth, te, acl = nil, nil, nil
+		}
+	}
+
+	// Check that the token may renew the lease, since the system backend
+	// only sees the salted token
+	if err == nil && strings.HasPrefix(req.Path, leaseRenewPrefix) {
+		if err = c.checkLeaseRenewal(req.Path, req.ClientToken, acl); err != nil {
+			auth, te, acl = nil, nil, nil
+		}
+	}
+	endSpan(span, err)
 	if te != nil {
 		defer func() {
 			// Attempt to use the token (decrement num_uses)
@@ -477,9 +927,11 @@ func (c *Core) handleRequest(req *logical.Request) (retResp *logical.Response, r
 			errType = logical.ErrInvalidRequest
 		}
 
-		if err := c.auditBroker.LogRequest(auth, req, err); err != nil {
-			c.logger.Printf("[ERR] core: failed to audit request with path (%s): %v",
-				req.Path, err)
+		if audit {
+			if err := c.auditBroker.LogRequest(auth, req, err); err != nil {
+				c.logger.Printf("[ERR] core: failed to audit request with path (%s): %v",
+					req.Path, err)
+			}
 		}
 
 		return logical.ErrorResponse(err.Error()), nil, errType
@@ -489,14 +941,21 @@ func (c *Core) handleRequest(req *logical.Request) (retResp *logical.Response, r
 	req.DisplayName = auth.DisplayName
 
 	// Create an audit trail of the request
-	if err := c.auditBroker.LogRequest(auth, req, nil); err != nil {
-		c.logger.Printf("[ERR] core: failed to audit request with path (%s): %v",
-			req.Path, err)
-		return nil, auth, ErrInternalError
+	if audit {
+		if err := c.auditBroker.LogRequest(auth, req, nil); err != nil {
+			c.logger.Printf("[ERR] core: failed to audit request with path (%s): %v",
+				req.Path, err)
+			return nil, auth, ErrInternalError
+		}
 	}
 
 	// Route the request
-	resp, err := c.router.Route(req)
+	resp, err := c.router.routeRequest(req, reqSpan)
+
+	// Remove any response fields the policy does not expose
+	if resp != nil && resp.Data != nil && !resp.IsError() {
+		resp.Data = acl.FilterResponseData(req.Path, resp.Data)
+	}
 
 	// If there is a secret, we must register it with the expiration manager.
 	// We exclude renewal of a lease, since it does not need to be re-registered
@@ -588,7 +1047,7 @@ func (c *Core) handleRequest(req *logical.Request) (retResp *logical.Response, r
 
 // handleLoginRequest is used to handle a login request, which is an
 // unauthenticated request to the backend.
-func (c *Core) handleLoginRequest(req *logical.Request) (*logical.Response, *logical.Auth, error) {
+func (c *Core) handleLoginRequest(req *logical.Request, reqSpan Span) (*logical.Response, *logical.Auth, error) {
 	defer metrics.MeasureSince([]string{"core", "handle_login_request"}, time.Now())
 
 	// Create an audit trail of the request, auth is not available on login requests
@@ -599,7 +1058,7 @@ func (c *Core) handleLoginRequest(req *logical.Request) (*logical.Response, *log
 	}
 
 	// Route the request
-	resp, err := c.router.Route(req)
+	resp, err := c.router.routeRequest(req, reqSpan)
 
 	// A login request should never return a secret!
 	if resp != nil && resp.Secret != nil {
@@ -629,13 +1088,14 @@ func (c *Core) handleLoginRequest(req *logical.Request) (*logical.Response, *log
 		}
 
 		// Set the default lease if non-provided, root tokens are exempt
+		defaultTTL, maxTTL := c.tokenTTLs(req.Path, sysView)
 		if auth.TTL == 0 && !strListContains(auth.Policies, "root") {
-			auth.TTL = sysView.DefaultLeaseTTL()
+			auth.TTL = defaultTTL
 		}
 
 		// Limit the lease duration
-		if auth.TTL > sysView.MaxLeaseTTL() {
-			auth.TTL = sysView.MaxLeaseTTL()
+		if auth.TTL > maxTTL {
+			auth.TTL = maxTTL
 		}
 
 		// Generate a token
@@ -646,9 +1106,14 @@ func (c *Core) handleLoginRequest(req *logical.Request) (*logical.Response, *log
 			DisplayName:  auth.DisplayName,
 			CreationTime: time.Now().Unix(),
 			TTL:          auth.TTL,
+
+			PolicySnapshot: auth.PolicySnapshot,
 		}
 
 		if err := c.tokenStore.create(&te); err != nil {
+			if err == ErrTooManyPolicies {
+				return logical.ErrorResponse(err.Error()), nil, logical.ErrInvalidRequest
+			}
 			c.logger.Printf("[ERR] core: failed to create token: %v", err)
 			return nil, auth, ErrInternalError
 		}
@@ -671,46 +1136,57 @@ func (c *Core) handleLoginRequest(req *logical.Request) (*logical.Response, *log
 }
 
 func (c *Core) checkToken(
-	op logical.Operation, path string, token string) (*logical.Auth, *TokenEntry, error) {
+	op logical.Operation, path string, token string, remoteAddr string) (*logical.Auth, *TokenEntry, *ACL, error) {
 	defer metrics.MeasureSince([]string{"core", "check_token"}, time.Now())
 
 	// Ensure there is a client token
 	if token == "" {
-		return nil, nil, fmt.Errorf("missing client token")
+		return nil, nil, nil, fmt.Errorf("missing client token")
 	}
 
 	if c.tokenStore == nil {
 		c.logger.Printf("[ERR] core: token store is unavailable")
-		return nil, nil, ErrInternalError
+		return nil, nil, nil, ErrInternalError
 	}
 
 	// Resolve the token policy
 	te, err := c.tokenStore.Lookup(token)
 	if err != nil {
 		c.logger.Printf("[ERR] core: failed to lookup token: %v", err)
-		return nil, nil, ErrInternalError
+		return nil, nil, nil, ErrInternalError
 	}
 
 	// Ensure the token is valid
 	if te == nil {
-		return nil, nil, logical.ErrPermissionDenied
+		return nil, nil, nil, logical.ErrPermissionDenied
+	}
+
+	// Ensure the token is used from one of its bound networks
+	if !te.AllowsRemoteAddr(remoteAddr) {
+		return nil, nil, nil, logical.ErrPermissionDenied
 	}
 
 	// Construct the corresponding ACL object
 	acl, err := c.policyStore.ACL(te.Policies...)
 	if err != nil {
 		c.logger.Printf("[ERR] core: failed to construct ACL: %v", err)
-		return nil, nil, ErrInternalError
+		return nil, nil, nil, ErrInternalError
 	}
+	acl = acl.ForRemoteAddr(remoteAddr)
 
 	// Check if this is a root protected path
 	if c.router.RootPath(path) && !acl.RootPrivilege(path) {
-		return nil, nil, logical.ErrPermissionDenied
+		return nil, nil, nil, logical.ErrPermissionDenied
 	}
 
 	// Check the standard non-root ACLs
 	if !acl.AllowOperation(op, path) {
-		return nil, nil, logical.ErrPermissionDenied
+		return nil, nil, nil, logical.ErrPermissionDenied
+	}
+
+	// Check the CIDR restrictions of the ACLs
+	if !acl.AllowRemoteAddr(path, remoteAddr) {
+		return nil, nil, nil, logical.ErrPermissionDenied
 	}
 
 	// Create the auth response
@@ -720,7 +1196,7 @@ func (c *Core) checkToken(
 		Metadata:    te.Meta,
 		DisplayName: te.DisplayName,
 	}
-	return auth, te, nil
+	return auth, te, acl, nil
 }
 
 // Initialized checks if the Vault is already initialized
@@ -758,6 +1234,10 @@ func (c *Core) Initialize(config *SealConfig) (*InitResult, error) {
 		c.logger.Printf("[ERR] core: invalid seal configuration: %v", err)
 		return nil, fmt.Errorf("invalid seal configuration: %v", err)
 	}
+	if err := c.checkUnsealShares(config); err != nil {
+		c.logger.Printf("[ERR] core: invalid seal configuration: %v", err)
+		return nil, fmt.Errorf("invalid seal configuration: %v", err)
+	}
 
 	// Avoid an initialization race
 	c.stateLock.Lock()
@@ -795,26 +1275,21 @@ func (c *Core) Initialize(config *SealConfig) (*InitResult, error) {
 		return nil, fmt.Errorf("master key generation failed: %v", err)
 	}
 
-	// Return the master key if only a single key part is used
+	// Split the master key into unseal shares, unless it is stored by
+	// the seal, in which case a recovery key is split instead
 	results := new(InitResult)
-	if config.SecretShares == 1 {
-		results.SecretShares = append(results.SecretShares, masterKey)
-	} else {
-		// Split the master key using the Shamir algorithm
-		shares, err := shamir.Split(masterKey, config.SecretShares, config.SecretThreshold)
+	var recoveryKey []byte
+	if c.seal == nil {
+		shares, err := c.splitMasterKey(masterKey, config)
 		if err != nil {
-			c.logger.Printf("[ERR] core: failed to generate shares: %v", err)
-			return nil, fmt.Errorf("failed to generate shares: %v", err)
+			return nil, err
 		}
-		results.SecretShares = shares
-	}
-
-	if len(config.PGPKeys) > 0 {
-		encryptedShares, err := pgpkeys.EncryptShares(results.SecretShares, config.PGPKeys)
+		results.UnsealShares = shares.SecretShares
+	} else {
+		recoveryKey, results.RecoveryShares, err = c.initRecoveryKey(masterKey, config)
 		if err != nil {
 			return nil, err
 		}
-		results.SecretShares = encryptedShares
 	}
 
 	// Initialize the barrier
@@ -844,6 +1319,12 @@ func (c *Core) Initialize(config *SealConfig) (*InitResult, error) {
 		return nil, err
 	}
 
+	if recoveryKey != nil {
+		if err := c.storeRecoveryKey(recoveryKey); err != nil {
+			return nil, err
+		}
+	}
+
 	// Generate a new root token
 	rootToken, err := c.tokenStore.rootToken()
 	if err != nil {
@@ -972,6 +1453,9 @@ func (c *Core) ResetUnsealProcess() {
 		return
 	}
 	c.unlockParts = nil
+	if err := c.persistUnsealProgress(nil); err != nil {
+		c.logger.Printf("[ERR] core: %v", err)
+	}
 }
 
 // Unseal is used to provide one of the key parts to unseal the Vault.
@@ -980,8 +1464,49 @@ func (c *Core) ResetUnsealProcess() {
 // this method is done with it. If you want to keep the key around, a copy
 // should be made.
 func (c *Core) Unseal(key []byte) (bool, error) {
+	return c.unseal(key, false)
+}
+
+// delayUnsealFailure is used to wait until the minimum time of a failed
+// unseal attempt has passed since start, plus up to a tenth of it as
+// jitter
+func (c *Core) delayUnsealFailure(start time.Time) {
+	if c.unsealFailureDelay <= 0 {
+		return
+	}
+	delay := c.unsealFailureDelay
+	if jitter := int64(delay / 10); jitter > 0 {
+		delay += time.Duration(rand.Int63n(jitter))
+	}
+	time.Sleep(delay - time.Since(start))
+}
+
+// ForceUnseal is like Unseal, but does not refuse to complete the unseal
+// if the physical backend fails the storage write probe. This allows an
+// operator to bring up a Vault on top of a degraded or read-only backend.
+func (c *Core) ForceUnseal(key []byte) (bool, error) {
+	return c.unseal(key, true)
+}
+
+// unseal is the implementation of Unseal and ForceUnseal
+func (c *Core) unseal(key []byte, force bool) (unsealed bool, retErr error) {
 	defer metrics.MeasureSince([]string{"core", "unseal"}, time.Now())
 
+	// Take the same time for every attempt that does not unseal, whether
+	// the key is malformed, invalid or valid but not yet sufficient. This
+	// runs after the state lock is released.
+	start := time.Now()
+	defer func() {
+		if !unsealed {
+			c.delayUnsealFailure(start)
+		}
+	}()
+
+	// Verify that key shares are permitted to unseal
+	if err := c.checkSealType(SealTypeShamir); err != nil {
+		return false, err
+	}
+
 	// Verify the key length
 	min, max := c.barrier.KeyLength()
 	max += shamir.ShareOverhead
@@ -1018,6 +1543,14 @@ func (c *Core) Unseal(key []byte) (bool, error) {
 		}
 	}
 
+	// Verify the storage before the final key is consumed, so that the
+	// keys entered so far are kept if it is degraded
+	if len(c.unlockParts)+1 >= config.SecretThreshold {
+		if err := c.checkStorage(force); err != nil {
+			return false, err
+		}
+	}
+
 	// Store this key
 	c.unlockParts = append(c.unlockParts, key)
 
@@ -1025,6 +1558,9 @@ func (c *Core) Unseal(key []byte) (bool, error) {
 	if len(c.unlockParts) < config.SecretThreshold {
 		c.logger.Printf("[DEBUG] core: cannot unseal, have %d of %d keys",
 			len(c.unlockParts), config.SecretThreshold)
+		if err := c.persistUnsealProgress(config); err != nil {
+			c.logger.Printf("[ERR] core: %v", err)
+		}
 		return false, nil
 	}
 
@@ -1036,12 +1572,23 @@ func (c *Core) Unseal(key []byte) (bool, error) {
 	} else {
 		masterKey, err = shamir.Combine(c.unlockParts)
 		c.unlockParts = nil
-		if err != nil {
-			return false, fmt.Errorf("failed to compute master key: %v", err)
-		}
+	}
+
+	// The shares are used up, whether or not they unseal
+	if perr := c.persistUnsealProgress(config); perr != nil {
+		c.logger.Printf("[ERR] core: %v", perr)
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to compute master key: %v", err)
 	}
 	defer memzero(masterKey)
+	return c.unsealBarrier(masterKey)
+}
 
+// unsealBarrier is used to unseal the barrier with the recovered master
+// key and complete the unseal. The storage must already have been
+// verified with checkStorage. The state lock must be held.
+func (c *Core) unsealBarrier(masterKey []byte) (bool, error) {
 	// Attempt to unlock
 	if err := c.barrier.Unseal(masterKey); err != nil {
 		return false, err
@@ -1069,9 +1616,88 @@ func (c *Core) Unseal(key []byte) (bool, error) {
 	return true, nil
 }
 
+// checkUnsealShares verifies that the seal configuration does not
+// generate more key shares than the configured maximum
+func (c *Core) checkUnsealShares(config *SealConfig) error {
+	if config.SecretShares > c.maxUnsealShares {
+		return fmt.Errorf("secret shares cannot exceed the maximum of %d", c.maxUnsealShares)
+	}
+	return nil
+}
+
+// checkSealType verifies that the given seal type is permitted to
+// unseal the Vault
+func (c *Core) checkSealType(sealType string) error {
+	if c.requiredSealType != "" && c.requiredSealType != sealType {
+		c.logger.Printf("[ERR] core: refusing %s unseal, seal type %s is required",
+			sealType, c.requiredSealType)
+		return ErrSealTypeNotPermitted
+	}
+	if c.seal != nil && c.seal.Type() != sealType {
+		c.logger.Printf("[ERR] core: refusing %s unseal, the master key is stored by the %s seal",
+			sealType, c.seal.Type())
+		return ErrSealTypeNotPermitted
+	}
+	return nil
+}
+
+// checkStorage is used to verify the physical backend is writable before
+// unsealing, otherwise writes accepted after unseal would fail in
+// confusing ways later on. If forced, a failed probe is only logged.
+func (c *Core) checkStorage(force bool) error {
+	// Unsealing again clears the read-only mode after storage failures,
+	// which would otherwise reject the probe
+	if c.storageBreaker != nil {
+		c.storageBreaker.Reset()
+	}
+
+	if err := c.probeStorage(); err != nil {
+		if !force {
+			c.logger.Printf("[ERR] core: storage probe failed, refusing to unseal: %v", err)
+			return logical.NewUnavailable("storage backend is degraded, refusing to unseal: %v", err)
+		}
+		c.logger.Printf("[WARN] core: storage probe failed, forcing unseal: %v", err)
+	}
+	return nil
+}
+
+// probeStorage is used to verify that the physical backend is able to
+// durably store data by writing, reading back and deleting a canary value.
+func (c *Core) probeStorage() error {
+	canary := uuid.GenerateUUID()
+	key := coreStorageProbePath + "/" + canary
+	pe := &physical.Entry{
+		Key:   key,
+		Value: []byte(canary),
+	}
+	if err := c.physical.Put(pe); err != nil {
+		return fmt.Errorf("failed to write probe: %v", err)
+	}
+
+	out, err := c.physical.Get(key)
+	if err != nil {
+		return fmt.Errorf("failed to read probe: %v", err)
+	}
+	if out == nil || string(out.Value) != canary {
+		return fmt.Errorf("probe value mismatch")
+	}
+
+	if err := c.physical.Delete(key); err != nil {
+		return fmt.Errorf("failed to delete probe: %v", err)
+	}
+	return nil
+}
+
 // Seal is used to re-seal the Vault. This requires the Vault to
 // be unsealed again to perform any further operations.
-func (c *Core) Seal(token string) (retErr error) {
+func (c *Core) Seal(token string) error {
+	return c.SealWithRequest(&logical.Request{ClientToken: token})
+}
+
+// SealWithRequest is used to re-seal the Vault on behalf of the client
+// of the request. Its RemoteAddr is checked against the CIDR restrictions
+// of the token and of the policies on sys/seal.
+func (c *Core) SealWithRequest(req *logical.Request) (retErr error) {
 	defer metrics.MeasureSince([]string{"core", "seal"}, time.Now())
 	c.stateLock.Lock()
 	defer c.stateLock.Unlock()
@@ -1080,7 +1706,7 @@ func (c *Core) Seal(token string) (retErr error) {
 	}
 
 	// Validate the token is a root token
-	_, te, err := c.checkToken(logical.WriteOperation, "sys/seal", token)
+	_, te, _, err := c.checkToken(logical.WriteOperation, "sys/seal", req.ClientToken, req.RemoteAddr)
 	if te != nil {
 		// Attempt to use the token (decrement num_uses)
 		if err := c.tokenStore.UseToken(te); err != nil {
@@ -1092,6 +1718,11 @@ func (c *Core) Seal(token string) (retErr error) {
 		return err
 	}
 
+	// Clean up the leases while the backends are still mounted
+	if c.revokeLeasesOnSeal {
+		c.revokeLeases()
+	}
+
 	// Seal the Vault
 	err = c.sealInternal()
 	if err == nil && retErr == ErrInternalError {
@@ -1103,6 +1734,45 @@ func (c *Core) Seal(token string) (retErr error) {
 	return
 }
 
+// revokeLeases is used to revoke every lease before sealing. Failures
+// are logged but do not prevent the seal. The stateLock must be held
+// prior to calling.
+func (c *Core) revokeLeases() {
+	if c.standby || c.expiration == nil {
+		return
+	}
+
+	c.logger.Printf("[INFO] core: revoking all leases before seal")
+	deadline := time.Now().Add(c.revokeLeasesTimeout)
+	if err := c.expiration.revokePrefix("", c.preservedLeasePrefixes(), deadline); err != nil {
+		c.logger.Printf("[ERR] core: failed to revoke leases before seal: %v", err)
+		return
+	}
+	c.logger.Printf("[INFO] core: revoked all leases")
+}
+
+// preservedLeasePrefixes returns the lease prefixes of the logical and
+// credential mounts whose leases are kept when sealing
+func (c *Core) preservedLeasePrefixes() []string {
+	var prefixes []string
+	for _, table := range []struct {
+		prefix string
+		mounts *MountTable
+	}{{"", c.mounts}, {credentialRoutePrefix, c.auth}} {
+		if table.mounts == nil {
+			continue
+		}
+		table.mounts.RLock()
+		for _, entry := range table.mounts.Entries {
+			if entry.Config.PreserveLeasesOnSeal {
+				prefixes = append(prefixes, table.prefix+entry.Path)
+			}
+		}
+		table.mounts.RUnlock()
+	}
+	return prefixes
+}
+
 // sealInternal is an internal method used to seal the vault.
 // It does not do any authorization checking. The stateLock must
 // be held prior to calling.
@@ -1126,6 +1796,13 @@ func (c *Core) sealInternal() error {
 		c.stateLock.Lock()
 	}
 
+	return c.sealBarrier()
+}
+
+// sealBarrier is used to seal the barrier once the Vault is marked sealed
+// and torn down. The stateLock must be held prior to calling.
+func (c *Core) sealBarrier() error {
+	defer metrics.MeasureSince([]string{"core", "seal_barrier"}, time.Now())
 	if err := c.barrier.Seal(); err != nil {
 		return err
 	}
@@ -1179,6 +1856,10 @@ func (c *Core) RekeyInit(config *SealConfig) error {
 		c.logger.Printf("[ERR] core: invalid rekey seal configuration: %v", err)
 		return fmt.Errorf("invalid rekey seal configuration: %v", err)
 	}
+	if err := c.checkUnsealShares(config); err != nil {
+		c.logger.Printf("[ERR] core: invalid rekey seal configuration: %v", err)
+		return fmt.Errorf("invalid rekey seal configuration: %v", err)
+	}
 
 	c.stateLock.RLock()
 	defer c.stateLock.RUnlock()
@@ -1273,54 +1954,48 @@ func (c *Core) RekeyUpdate(key []byte) (*RekeyResult, error) {
 		}
 	}
 
-	// Verify the master key
-	if err := c.barrier.VerifyMaster(masterKey); err != nil {
-		c.logger.Printf("[ERR] core: rekey aborted, master key verification failed: %v", err)
-		return nil, err
-	}
-
-	// Generate a new master key
-	newMasterKey, err := c.barrier.GenerateKey()
+	// Encode the seal configuration
+	buf, err := json.Marshal(c.rekeyConfig)
 	if err != nil {
-		c.logger.Printf("[ERR] core: failed to generate master key: %v", err)
-		return nil, fmt.Errorf("master key generation failed: %v", err)
+		return nil, fmt.Errorf("failed to encode seal configuration: %v", err)
 	}
 
-	// Return the master key if only a single key part is used
-	results := new(RekeyResult)
-	if c.rekeyConfig.SecretShares == 1 {
-		results.SecretShares = append(results.SecretShares, newMasterKey)
+	// The shares are of the recovery key if the master key is stored
+	// by the seal, so the recovery key is replaced instead
+	var results *RekeyResult
+	if c.seal != nil {
+		results, err = c.rekeyRecoveryKey(masterKey, c.rekeyConfig)
+		if err != nil {
+			return nil, err
+		}
 	} else {
-		// Split the master key using the Shamir algorithm
-		shares, err := shamir.Split(newMasterKey, c.rekeyConfig.SecretShares, c.rekeyConfig.SecretThreshold)
+		// Verify the master key
+		if err := c.barrier.VerifyMaster(masterKey); err != nil {
+			c.logger.Printf("[ERR] core: rekey aborted, master key verification failed: %v", err)
+			return nil, err
+		}
+
+		// Generate a new master key
+		newMasterKey, err := c.barrier.GenerateKey()
 		if err != nil {
-			c.logger.Printf("[ERR] core: failed to generate shares: %v", err)
-			return nil, fmt.Errorf("failed to generate shares: %v", err)
+			c.logger.Printf("[ERR] core: failed to generate master key: %v", err)
+			return nil, fmt.Errorf("master key generation failed: %v", err)
 		}
-		results.SecretShares = shares
-	}
 
-	if len(c.rekeyConfig.PGPKeys) > 0 {
-		encryptedShares, err := pgpkeys.EncryptShares(results.SecretShares, c.rekeyConfig.PGPKeys)
+		// Split the new master key
+		results, err = c.splitMasterKey(newMasterKey, c.rekeyConfig)
 		if err != nil {
 			return nil, err
 		}
-		results.SecretShares = encryptedShares
-	}
-
-	// Encode the seal configuration
-	buf, err := json.Marshal(c.rekeyConfig)
-	if err != nil {
-		return nil, fmt.Errorf("failed to encode seal configuration: %v", err)
-	}
 
-	// Rekey the barrier
-	if err := c.barrier.Rekey(newMasterKey); err != nil {
-		c.logger.Printf("[ERR] core: failed to rekey barrier: %v", err)
-		return nil, fmt.Errorf("failed to rekey barrier: %v", err)
+		// Rekey the barrier
+		if err := c.barrier.Rekey(newMasterKey); err != nil {
+			c.logger.Printf("[ERR] core: failed to rekey barrier: %v", err)
+			return nil, fmt.Errorf("failed to rekey barrier: %v", err)
+		}
+		c.logger.Printf("[INFO] core: security barrier rekeyed (shares: %d, threshold: %d)",
+			c.rekeyConfig.SecretShares, c.rekeyConfig.SecretThreshold)
 	}
-	c.logger.Printf("[INFO] core: security barrier rekeyed (shares: %d, threshold: %d)",
-		c.rekeyConfig.SecretShares, c.rekeyConfig.SecretThreshold)
 
 	// Store the seal configuration
 	pe := &physical.Entry{
@@ -1332,12 +2007,44 @@ func (c *Core) RekeyUpdate(key []byte) (*RekeyResult, error) {
 		return nil, fmt.Errorf("failed to update seal configuration: %v", err)
 	}
 
+	// Unseal progress of a sealed node holds shares of the old key
+	if err := c.clearUnsealProgress(); err != nil {
+		c.logger.Printf("[ERR] core: %v", err)
+	}
+
 	// Done!
 	c.rekeyProgress = nil
 	c.rekeyConfig = nil
 	return results, nil
 }
 
+// splitMasterKey is used to split the master key into the shares of
+// the given seal configuration, encrypting them if PGP keys are given
+func (c *Core) splitMasterKey(masterKey []byte, config *SealConfig) (*RekeyResult, error) {
+	// Return the master key if only a single key part is used
+	results := new(RekeyResult)
+	if config.SecretShares == 1 {
+		results.SecretShares = append(results.SecretShares, masterKey)
+	} else {
+		// Split the master key using the Shamir algorithm
+		shares, err := shamir.Split(masterKey, config.SecretShares, config.SecretThreshold)
+		if err != nil {
+			c.logger.Printf("[ERR] core: failed to generate shares: %v", err)
+			return nil, fmt.Errorf("failed to generate shares: %v", err)
+		}
+		results.SecretShares = shares
+	}
+
+	if len(config.PGPKeys) > 0 {
+		encryptedShares, err := pgpkeys.EncryptShares(results.SecretShares, config.PGPKeys)
+		if err != nil {
+			return nil, err
+		}
+		results.SecretShares = encryptedShares
+	}
+	return results, nil
+}
+
 // RekeyCancel is used to cancel an inprogress rekey
 func (c *Core) RekeyCancel() error {
 	c.stateLock.RLock()
@@ -1385,6 +2092,9 @@ func (c *Core) postUnseal() (retErr error) {
 			return err
 		}
 	}
+	if err := c.loadSystemConfig(); err != nil {
+		return err
+	}
 	if err := c.loadMounts(); err != nil {
 		return err
 	}
@@ -1397,21 +2107,36 @@ func (c *Core) postUnseal() (retErr error) {
 	if err := c.setupPolicyStore(); err != nil {
 		return err
 	}
+	if err := c.setupPasswordPolicyStore(); err != nil {
+		return err
+	}
+	if err := c.setupMFAStore(); err != nil {
+		return err
+	}
 	if err := c.loadCredentials(); err != nil {
 		return err
 	}
 	if err := c.setupCredentials(); err != nil {
 		return err
 	}
+	if err := c.loadRetainedMounts(); err != nil {
+		return err
+	}
 	if err := c.setupExpiration(); err != nil {
 		return err
 	}
+	c.startTokenSweep()
+	if err := c.startRotation(); err != nil {
+		return err
+	}
 	if err := c.loadAudits(); err != nil {
 		return err
 	}
 	if err := c.setupAudits(); err != nil {
 		return err
 	}
+	c.startEntropyReseed()
+	c.startIdleSeal()
 	c.metricsCh = make(chan struct{})
 	go c.emitMetrics(c.metricsCh)
 	c.logger.Printf("[INFO] core: post-unseal setup complete")
@@ -1436,21 +2161,35 @@ func (c *Core) preSeal() error {
 	if err := c.teardownAudits(); err != nil {
 		result = multierror.Append(result, errwrap.Wrapf("[ERR] error tearing down audits: {{err}}", err))
 	}
+	if err := c.stopRotation(); err != nil {
+		result = multierror.Append(result, errwrap.Wrapf("[ERR] error stopping rotation: {{err}}", err))
+	}
+	c.stopTokenSweep()
 	if err := c.stopExpiration(); err != nil {
 		result = multierror.Append(result, errwrap.Wrapf("[ERR] error stopping expiration: {{err}}", err))
 	}
+	c.stopMountReaper()
+	c.stopEntropyReseed()
+	c.stopIdleSeal()
 	if err := c.teardownCredentials(); err != nil {
 		result = multierror.Append(result, errwrap.Wrapf("[ERR] error tearing down credentials: {{err}}", err))
 	}
 	if err := c.teardownPolicyStore(); err != nil {
 		result = multierror.Append(result, errwrap.Wrapf("[ERR] error tearing down policy store: {{err}}", err))
 	}
+	if err := c.teardownPasswordPolicyStore(); err != nil {
+		result = multierror.Append(result, errwrap.Wrapf("[ERR] error tearing down password policy store: {{err}}", err))
+	}
+	if err := c.teardownMFAStore(); err != nil {
+		result = multierror.Append(result, errwrap.Wrapf("[ERR] error tearing down MFA store: {{err}}", err))
+	}
 	if err := c.stopRollback(); err != nil {
 		result = multierror.Append(result, errwrap.Wrapf("[ERR] error stopping rollback: {{err}}", err))
 	}
 	if err := c.unloadMounts(); err != nil {
 		result = multierror.Append(result, errwrap.Wrapf("[ERR] error unloading mounts: {{err}}", err))
 	}
+	c.unloadSystemConfig()
 	if cache, ok := c.physical.(*physical.Cache); ok {
 		cache.Purge()
 	}
@@ -1522,9 +2261,11 @@ func (c *Core) runStandby(doneCh, stopCh chan struct{}) {
 		}
 
 		// Monitor a loss of leadership
+		lost := false
 		select {
 		case <-leaderLostCh:
 			c.logger.Printf("[WARN] core: leadership lost, stopping active operation")
+			lost = true
 		case <-stopCh:
 			c.logger.Printf("[WARN] core: stopping active operation")
 		}
@@ -1534,10 +2275,21 @@ func (c *Core) runStandby(doneCh, stopCh chan struct{}) {
 			c.logger.Printf("[ERR] core: clearing leader advertisement failed: %v", err)
 		}
 
-		// Attempt the pre-seal process
+		// Attempt the pre-seal process, sealing if leadership was lost
+		// unless we are to return to standby. The standby loop cannot be
+		// stopped from within, so only the barrier is sealed.
+		sealOnLoss := lost && !c.standbyOnLeadershipLoss
 		c.stateLock.Lock()
 		c.standby = true
+		if sealOnLoss {
+			c.sealed = true
+		}
 		preSealErr := c.preSeal()
+		if sealOnLoss {
+			if err := c.sealBarrier(); err != nil {
+				c.logger.Printf("[ERR] core: failed to seal after losing leadership: %v", err)
+			}
+		}
 		c.stateLock.Unlock()
 
 		// Give up leadership
@@ -1545,7 +2297,11 @@ func (c *Core) runStandby(doneCh, stopCh chan struct{}) {
 
 		// Check for a failure to prepare to seal
 		if preSealErr != nil {
-			c.logger.Printf("[ERR] core: pre-seal teardown failed: %v", err)
+			c.logger.Printf("[ERR] core: pre-seal teardown failed: %v", preSealErr)
+		}
+
+		if sealOnLoss {
+			return
 		}
 	}
 }
@@ -1,7 +1,11 @@
 package vault
 
 import (
+	"fmt"
 	"reflect"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -9,6 +13,7 @@ import (
 	"github.com/hashicorp/uuid"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/physical"
+	"github.com/hashicorp/vault/shamir"
 )
 
 var (
@@ -80,7 +85,7 @@ func TestCore_Init(t *testing.T) {
 		t.Fatalf("err: %v", err)
 	}
 
-	if len(res.SecretShares) != 1 {
+	if len(res.UnsealShares) != 1 {
 		t.Fatalf("Bad: %v", res)
 	}
 	if res.RootToken == "" {
@@ -151,7 +156,7 @@ func TestCore_Init_MultiShare(t *testing.T) {
 		t.Fatalf("err: %v", err)
 	}
 
-	if len(res.SecretShares) != 5 {
+	if len(res.UnsealShares) != 5 {
 		t.Fatalf("Bad: %v", res)
 	}
 	if res.RootToken == "" {
@@ -198,13 +203,13 @@ func TestCore_Unseal_MultiShare(t *testing.T) {
 	}
 
 	for i := 0; i < 5; i++ {
-		unseal, err := c.Unseal(res.SecretShares[i])
+		unseal, err := c.Unseal(res.UnsealShares[i])
 		if err != nil {
 			t.Fatalf("err: %v", err)
 		}
 
 		// Ignore redundant
-		_, err = c.Unseal(res.SecretShares[i])
+		_, err = c.Unseal(res.UnsealShares[i])
 		if err != nil {
 			t.Fatalf("err: %v", err)
 		}
@@ -253,6 +258,116 @@ func TestCore_Unseal_MultiShare(t *testing.T) {
 	}
 }
 
+func TestCore_Unseal_FailureDelay(t *testing.T) {
+	c := TestCore(t)
+	res, err := c.Initialize(&SealConfig{SecretShares: 5, SecretThreshold: 3})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	other := TestCore(t)
+	otherRes, err := other.Initialize(&SealConfig{SecretShares: 5, SecretThreshold: 3})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	delay := 50 * time.Millisecond
+	c.unsealFailureDelay = delay
+	timed := func(key []byte) time.Duration {
+		start := time.Now()
+		unsealed, _ := c.Unseal(key)
+		if unsealed {
+			t.Fatalf("should not be unsealed")
+		}
+		return time.Since(start)
+	}
+
+	// Every failure mode takes at least the delay
+	cases := map[string][][]byte{
+		"malformed":    {[]byte("short")},
+		"insufficient": {res.UnsealShares[0]},
+		"redundant":    {res.UnsealShares[0]},
+		"invalid":      {otherRes.UnsealShares[1], otherRes.UnsealShares[2]},
+	}
+	for _, name := range []string{"malformed", "insufficient", "redundant", "invalid"} {
+		for _, key := range cases[name] {
+			if elapsed := timed(key); elapsed < delay {
+				t.Fatalf("%s: responded in %s", name, elapsed)
+			}
+		}
+	}
+	if prog := c.SecretProgress(); prog != 0 {
+		t.Fatalf("bad progress: %d", prog)
+	}
+
+	// A successful unseal is not delayed
+	c.unsealFailureDelay = -1
+	for i := 0; i < 2; i++ {
+		if _, err := c.Unseal(res.UnsealShares[i]); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+	c.unsealFailureDelay = time.Hour
+	if _, err := c.Unseal(res.UnsealShares[2]); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if sealed, _ := c.Sealed(); sealed {
+		t.Fatalf("should be unsealed")
+	}
+}
+
+func TestCore_Unseal_WrongShare(t *testing.T) {
+	c := TestCore(t)
+	c.unsealFailureDelay = -1
+	sealConf := &SealConfig{
+		SecretShares:    5,
+		SecretThreshold: 3,
+	}
+	res, err := c.Initialize(sealConf)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	other := TestCore(t)
+	otherRes, err := other.Initialize(sealConf)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// A share submitted twice only counts once
+	for i := 0; i < 2; i++ {
+		if unseal, err := c.Unseal(res.UnsealShares[0]); err != nil || unseal {
+			t.Fatalf("bad: %v %v", unseal, err)
+		}
+	}
+	if prog := c.SecretProgress(); prog != 1 {
+		t.Fatalf("bad progress: %d", prog)
+	}
+
+	// A share of another master key fails the reconstruction and
+	// discards the progress, leaving the barrier sealed
+	if _, err := c.Unseal(res.UnsealShares[1]); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := c.Unseal(otherRes.UnsealShares[4]); err == nil {
+		t.Fatalf("expected error")
+	}
+	if sealed, _ := c.Sealed(); !sealed {
+		t.Fatalf("should be sealed")
+	}
+	if prog := c.SecretProgress(); prog != 0 {
+		t.Fatalf("bad progress: %d", prog)
+	}
+
+	// The correct shares still unseal
+	for i := 0; i < 3; i++ {
+		if _, err := c.Unseal(res.UnsealShares[i]); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+	if sealed, _ := c.Sealed(); sealed {
+		t.Fatalf("should be unsealed")
+	}
+}
+
 func TestCore_Unseal_Single(t *testing.T) {
 	c := TestCore(t)
 
@@ -282,7 +397,7 @@ func TestCore_Unseal_Single(t *testing.T) {
 		t.Fatalf("bad progress: %d", prog)
 	}
 
-	unseal, err := c.Unseal(res.SecretShares[0])
+	unseal, err := c.Unseal(res.UnsealShares[0])
 	if err != nil {
 		t.Fatalf("err: %v", err)
 	}
@@ -303,6 +418,325 @@ func TestCore_Unseal_Single(t *testing.T) {
 	}
 }
 
+// readOnlyInmem is an in-memory physical backend that can be switched
+// into a degraded mode where all writes fail.
+type readOnlyInmem struct {
+	*physical.InmemBackend
+	readOnly bool
+}
+
+func (r *readOnlyInmem) Put(entry *physical.Entry) error {
+	if r.readOnly {
+		return fmt.Errorf("backend is read-only")
+	}
+	return r.InmemBackend.Put(entry)
+}
+
+func (r *readOnlyInmem) Delete(key string) error {
+	if r.readOnly {
+		return fmt.Errorf("backend is read-only")
+	}
+	return r.InmemBackend.Delete(key)
+}
+
+func TestCore_Unseal_StorageDegraded(t *testing.T) {
+	inm := &readOnlyInmem{InmemBackend: physical.NewInmem()}
+	c, err := NewCore(&CoreConfig{
+		Physical:     inm,
+		DisableMlock: true,
+		DisableCache: true,
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	key, _ := TestCoreInit(t, c)
+
+	// Degrade the backend, unseal should be refused
+	inm.readOnly = true
+	unseal, err := c.Unseal(TestKeyCopy(key))
+	if err == nil {
+		t.Fatalf("expected error")
+	}
+	if !strings.Contains(err.Error(), "refusing to unseal") {
+		t.Fatalf("bad: %v", err)
+	}
+	if verr, ok := err.(*logical.VaultError); !ok || verr.HTTPStatus != 503 || !verr.Retryable {
+		t.Fatalf("bad: %#v", err)
+	}
+	if unseal {
+		t.Fatalf("should not be unsealed")
+	}
+	if sealed, _ := c.Sealed(); !sealed {
+		t.Fatalf("should be sealed")
+	}
+	if prog := c.SecretProgress(); prog != 0 {
+		t.Fatalf("bad progress: %d", prog)
+	}
+
+	// Forcing should get past the probe
+	unseal, err = c.ForceUnseal(TestKeyCopy(key))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !unseal {
+		t.Fatalf("should be unsealed")
+	}
+	if sealed, _ := c.Sealed(); sealed {
+		t.Fatalf("should not be sealed")
+	}
+}
+
+func TestCore_Unseal_StorageProbeCleanup(t *testing.T) {
+	inm := physical.NewInmem()
+	c, err := NewCore(&CoreConfig{
+		Physical:     inm,
+		DisableMlock: true,
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	key, _ := TestCoreInit(t, c)
+	if _, err := c.Unseal(TestKeyCopy(key)); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The probe should not leave anything behind
+	out, err := inm.List(coreStorageProbePath + "/")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(out) != 0 {
+		t.Fatalf("probe entries left behind: %#v", out)
+	}
+}
+
+func TestCore_Unseal_StorageDegradedKeepsProgress(t *testing.T) {
+	inm := &readOnlyInmem{InmemBackend: physical.NewInmem()}
+	c, err := NewCore(&CoreConfig{
+		Physical:     inm,
+		DisableMlock: true,
+		DisableCache: true,
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	res, err := c.Initialize(&SealConfig{SecretShares: 5, SecretThreshold: 3})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Degrade the backend, the final key is refused but not consumed
+	inm.readOnly = true
+	for i := 0; i < 3; i++ {
+		unseal, err := c.Unseal(res.UnsealShares[i])
+		if i < 2 && err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if i == 2 && err == nil {
+			t.Fatalf("expected error")
+		}
+		if unseal {
+			t.Fatalf("should not be unsealed")
+		}
+	}
+	if prog := c.SecretProgress(); prog != 2 {
+		t.Fatalf("bad progress: %d", prog)
+	}
+
+	// Once the backend recovers, the final key completes the unseal
+	inm.readOnly = false
+	unseal, err := c.Unseal(res.UnsealShares[2])
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !unseal {
+		t.Fatalf("should be unsealed")
+	}
+}
+
+func TestCore_Unseal_RequiredSealType(t *testing.T) {
+	c, key, _ := TestCoreUnsealed(t)
+
+	// A core requiring its seal's type must refuse key shares
+	conf := &CoreConfig{
+		Physical:         c.physical,
+		DisableMlock:     true,
+		RequiredSealType: testSealType,
+		Seal:             &testSeal{},
+	}
+	c2, err := NewCore(conf)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	unseal, err := c2.Unseal(TestKeyCopy(key))
+	if err != ErrSealTypeNotPermitted {
+		t.Fatalf("err: %v", err)
+	}
+	if unseal {
+		t.Fatalf("should not be unsealed")
+	}
+	if sealed, _ := c2.Sealed(); !sealed {
+		t.Fatalf("should be sealed")
+	}
+
+	// Requiring Shamir allows key shares
+	conf.RequiredSealType = SealTypeShamir
+	conf.Seal = nil
+	c3, err := NewCore(conf)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	unseal, err = c3.Unseal(TestKeyCopy(key))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !unseal {
+		t.Fatalf("should be unsealed")
+	}
+}
+
+func TestNewCore_badRequiredSealType(t *testing.T) {
+	// Without a seal, only Shamir can be required
+	for _, sealType := range []string{"tpm", testSealType} {
+		conf := &CoreConfig{
+			Physical:         physical.NewInmem(),
+			DisableMlock:     true,
+			RequiredSealType: sealType,
+		}
+		if _, err := NewCore(conf); err == nil {
+			t.Fatalf("should fail for %s", sealType)
+		}
+	}
+}
+
+func TestNewCore_badMaxUnsealShares(t *testing.T) {
+	for _, max := range []int{-1, shamir.MaxShares + 1} {
+		conf := &CoreConfig{
+			Physical:        physical.NewInmem(),
+			DisableMlock:    true,
+			MaxUnsealShares: max,
+		}
+		if _, err := NewCore(conf); err == nil {
+			t.Fatalf("should fail for %d", max)
+		}
+	}
+}
+
+func TestCore_Init_MaxUnsealShares(t *testing.T) {
+	c, err := NewCore(&CoreConfig{
+		Physical:        physical.NewInmem(),
+		DisableMlock:    true,
+		MaxUnsealShares: 5,
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Over the configured limit
+	_, err = c.Initialize(&SealConfig{
+		SecretShares:    6,
+		SecretThreshold: 3,
+	})
+	if err == nil || !strings.Contains(err.Error(), "maximum of 5") {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Over the Shamir limit, regardless of the configuration
+	_, err = c.Initialize(&SealConfig{
+		SecretShares:    10000,
+		SecretThreshold: 3,
+	})
+	if err == nil {
+		t.Fatalf("should fail")
+	}
+	if init, _ := c.Initialized(); init {
+		t.Fatalf("should not be init")
+	}
+
+	// At the limit
+	res, err := c.Initialize(&SealConfig{
+		SecretShares:    5,
+		SecretThreshold: 3,
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(res.UnsealShares) != 5 {
+		t.Fatalf("bad: %d", len(res.UnsealShares))
+	}
+}
+
+func TestCore_Rekey_MaxUnsealShares(t *testing.T) {
+	c, _, _ := TestCoreUnsealed(t)
+	c.maxUnsealShares = 5
+
+	err := c.RekeyInit(&SealConfig{
+		SecretShares:    6,
+		SecretThreshold: 3,
+	})
+	if err == nil || !strings.Contains(err.Error(), "maximum of 5") {
+		t.Fatalf("err: %v", err)
+	}
+	if conf, _ := c.RekeyConfig(); conf != nil {
+		t.Fatalf("bad: %#v", conf)
+	}
+}
+
+func TestNewCore_badTokenIDLength(t *testing.T) {
+	conf := &CoreConfig{
+		Physical:      physical.NewInmem(),
+		DisableMlock:  true,
+		TokenIDLength: minTokenIDLength - 1,
+	}
+	if _, err := NewCore(conf); err == nil {
+		t.Fatalf("should fail")
+	}
+}
+
+func TestNewCore_badMaxTokenDepth(t *testing.T) {
+	conf := &CoreConfig{
+		Physical:      physical.NewInmem(),
+		DisableMlock:  true,
+		MaxTokenDepth: -1,
+	}
+	if _, err := NewCore(conf); err == nil {
+		t.Fatalf("should fail")
+	}
+}
+
+func TestNewCore_badMaxAuthTableSize(t *testing.T) {
+	conf := &CoreConfig{
+		Physical:         physical.NewInmem(),
+		DisableMlock:     true,
+		MaxAuthTableSize: -1,
+	}
+	if _, err := NewCore(conf); err == nil {
+		t.Fatalf("should fail")
+	}
+}
+
+func TestNewCore_badMaxLeaseClockSkew(t *testing.T) {
+	conf := &CoreConfig{
+		Physical:          physical.NewInmem(),
+		DisableMlock:      true,
+		MaxLeaseClockSkew: -time.Minute,
+	}
+	if _, err := NewCore(conf); err == nil {
+		t.Fatalf("should fail")
+	}
+}
+
+func TestNewCore_badDisabledOperations(t *testing.T) {
+	conf := &CoreConfig{
+		Physical:           physical.NewInmem(),
+		DisableMlock:       true,
+		DisabledOperations: []logical.Operation{logical.RevokeOperation},
+	}
+	if _, err := NewCore(conf); err == nil {
+		t.Fatalf("should fail")
+	}
+}
+
 func TestCore_Route_Sealed(t *testing.T) {
 	c := TestCore(t)
 	sealConf := &SealConfig{
@@ -325,7 +759,7 @@ func TestCore_Route_Sealed(t *testing.T) {
 		t.Fatalf("err: %v", err)
 	}
 
-	unseal, err := c.Unseal(res.SecretShares[0])
+	unseal, err := c.Unseal(res.UnsealShares[0])
 	if err != nil {
 		t.Fatalf("err: %v", err)
 	}
@@ -352,6 +786,157 @@ func TestCore_SealUnseal(t *testing.T) {
 	}
 }
 
+func TestCore_Seal_RevokeLeases(t *testing.T) {
+	for _, revoke := range []bool{true, false} {
+		c, key, root := TestCoreUnsealed(t)
+		c.revokeLeasesOnSeal = revoke
+
+		// Create a lease
+		req := &logical.Request{
+			Operation: logical.WriteOperation,
+			Path:      "secret/test",
+			Data: map[string]interface{}{
+				"foo":   "bar",
+				"lease": "1h",
+			},
+			ClientToken: root,
+		}
+		if _, err := c.HandleRequest(req); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		req.Operation = logical.ReadOperation
+		req.Data = nil
+		resp, err := c.HandleRequest(req)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if resp == nil || resp.Secret == nil || resp.Secret.LeaseID == "" {
+			t.Fatalf("bad: %#v", resp)
+		}
+
+		if err := c.Seal(root); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if unseal, err := c.Unseal(TestKeyCopy(key)); err != nil || !unseal {
+			t.Fatalf("err: %v", err)
+		}
+
+		info, err := c.expiration.Lookup(resp.Secret.LeaseID)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if revoke && info != nil {
+			t.Fatalf("lease should be revoked: %#v", info)
+		}
+		if !revoke && info == nil {
+			t.Fatalf("lease should be intact")
+		}
+	}
+}
+
+func TestCore_Seal_PreserveLeases(t *testing.T) {
+	c, key, root := TestCoreUnsealed(t)
+	c.revokeLeasesOnSeal = true
+
+	// Mount a second generic backend whose leases are preserved
+	req := logical.TestRequest(t, logical.WriteOperation, "sys/mounts/preserved")
+	req.Data["type"] = "generic"
+	req.ClientToken = root
+	if _, err := c.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	req = logical.TestRequest(t, logical.WriteOperation, "sys/mounts/preserved/tune")
+	req.Data["preserve_leases_on_seal"] = "true"
+	req.ClientToken = root
+	if _, err := c.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Create a lease on both mounts
+	leases := make(map[string]string)
+	for _, path := range []string{"secret/test", "preserved/test"} {
+		req := &logical.Request{
+			Operation: logical.WriteOperation,
+			Path:      path,
+			Data: map[string]interface{}{
+				"foo":   "bar",
+				"lease": "1h",
+			},
+			ClientToken: root,
+		}
+		if _, err := c.HandleRequest(req); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		req.Operation = logical.ReadOperation
+		req.Data = nil
+		resp, err := c.HandleRequest(req)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if resp == nil || resp.Secret == nil || resp.Secret.LeaseID == "" {
+			t.Fatalf("bad: %#v", resp)
+		}
+		leases[path] = resp.Secret.LeaseID
+	}
+
+	if err := c.Seal(root); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if unseal, err := c.Unseal(TestKeyCopy(key)); err != nil || !unseal {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Only the lease of the exempt mount survives
+	info, err := c.expiration.Lookup(leases["secret/test"])
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if info != nil {
+		t.Fatalf("lease should be revoked: %#v", info)
+	}
+	info, err = c.expiration.Lookup(leases["preserved/test"])
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if info == nil {
+		t.Fatalf("lease should be intact")
+	}
+
+	// The setting is persisted with the mount table
+	if me := c.router.MatchingMountEntry("preserved/"); me == nil || !me.Config.PreserveLeasesOnSeal {
+		t.Fatalf("bad: %#v", me)
+	}
+}
+
+func TestCore_Seal_PreserveLeases_Credential(t *testing.T) {
+	c, key, root := TestCoreUnsealed(t)
+	c.credentialBackends["noop"] = func(*logical.BackendConfig) (logical.Backend, error) {
+		return &NoopBackend{}, nil
+	}
+	if _, err := c.enableCredential(&MountEntry{Path: "foo", Type: "noop"}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	req := logical.TestRequest(t, logical.WriteOperation, "sys/mounts/auth/foo/tune")
+	req.Data["preserve_leases_on_seal"] = "true"
+	req.ClientToken = root
+	if _, err := c.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if err := c.Seal(root); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if unseal, err := c.Unseal(TestKeyCopy(key)); err != nil || !unseal {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The setting is persisted with the auth table
+	if me := c.router.MatchingMountEntry("auth/foo/"); me == nil || !me.Config.PreserveLeasesOnSeal {
+		t.Fatalf("bad: %#v", me)
+	}
+}
+
 // Attempt to shutdown after unseal
 func TestCore_Shutdown(t *testing.T) {
 	c, _, _ := TestCoreUnsealed(t)
@@ -518,6 +1103,30 @@ func TestCore_HandleRequest_MissingToken(t *testing.T) {
 	}
 }
 
+func TestCore_HandleRequest_BadRequest(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+
+	if _, err := c.HandleRequest(nil); err != logical.ErrInvalidRequest {
+		t.Fatalf("err: %v", err)
+	}
+
+	req := &logical.Request{
+		Operation:   logical.Operation("bogus"),
+		Path:        "secret/test",
+		ClientToken: root,
+	}
+	if _, err := c.HandleRequest(req); err != logical.ErrUnsupportedOperation {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Unknown operations are denied by the policies
+	testCoreMakeToken(t, c, root, "client", []string{"foo"})
+	req.ClientToken = "client"
+	if _, err := c.HandleRequest(req); err != logical.ErrPermissionDenied {
+		t.Fatalf("err: %v", err)
+	}
+}
+
 func TestCore_HandleRequest_InvalidToken(t *testing.T) {
 	c, _, _ := TestCoreUnsealed(t)
 
@@ -671,6 +1280,113 @@ func TestCore_HandleRequest_PermissionAllowed(t *testing.T) {
 	}
 }
 
+func TestCore_HandleRequest_PermissionCIDR(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+	testCoreMakeToken(t, c, root, "child", []string{"test"})
+
+	// Set the 'test' policy object to permit access to secret/ from 10/8
+	req := &logical.Request{
+		Operation: logical.WriteOperation,
+		Path:      "sys/policy/test",
+		Data: map[string]interface{}{
+			"rules": `path "secret/*" { policy = "write" cidr_list = ["10.0.0.0/8"] }`,
+		},
+		ClientToken: root,
+	}
+	resp, err := c.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if resp != nil {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	write := func(remoteAddr string) error {
+		req := &logical.Request{
+			Operation: logical.WriteOperation,
+			Path:      "secret/test",
+			Data: map[string]interface{}{
+				"foo": "bar",
+			},
+			ClientToken: "child",
+			RemoteAddr:  remoteAddr,
+		}
+		_, err := c.HandleRequest(req)
+		return err
+	}
+
+	// Only writes from within the CIDR should be allowed
+	if err := write("10.1.2.3"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := write("192.168.1.1"); err != logical.ErrPermissionDenied {
+		t.Fatalf("err: %v", err)
+	}
+	if err := write(""); err != logical.ErrPermissionDenied {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestCore_HandleRequest_ResponseFields(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+	testCoreMakeToken(t, c, root, "child", []string{"test"})
+
+	// Write a secret with a sensitive field
+	req := &logical.Request{
+		Operation: logical.WriteOperation,
+		Path:      "secret/test",
+		Data: map[string]interface{}{
+			"username": "foo",
+			"password": "bar",
+		},
+		ClientToken: root,
+	}
+	if _, err := c.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Set the 'test' policy object to hide the password
+	req = &logical.Request{
+		Operation: logical.WriteOperation,
+		Path:      "sys/policy/test",
+		Data: map[string]interface{}{
+			"rules": `path "secret/*" { policy = "read" denied_response_fields = ["password"] }`,
+		},
+		ClientToken: root,
+	}
+	if _, err := c.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	read := func(token string) map[string]interface{} {
+		req := &logical.Request{
+			Operation:   logical.ReadOperation,
+			Path:        "secret/test",
+			ClientToken: token,
+		}
+		resp, err := c.HandleRequest(req)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if resp == nil {
+			t.Fatalf("missing response")
+		}
+		return resp.Data
+	}
+
+	// The password should be redacted for the child only
+	exp := map[string]interface{}{
+		"username": "foo",
+	}
+	if out := read("child"); !reflect.DeepEqual(out, exp) {
+		t.Fatalf("bad: %#v", out)
+	}
+	exp["password"] = "bar"
+	if out := read(root); !reflect.DeepEqual(out, exp) {
+		t.Fatalf("bad: %#v", out)
+	}
+}
+
 func TestCore_HandleRequest_NoConnection(t *testing.T) {
 	noop := &NoopBackend{
 		Response: &logical.Response{},
@@ -757,16 +1473,98 @@ func TestCore_HandleRequest_ConnOnLogin(t *testing.T) {
 		t.Fatalf("err: %v", err)
 	}
 
-	// Attempt to request with connection data
-	req = &logical.Request{
-		Path:       "auth/foo/login",
-		Connection: &logical.Connection{},
+	// Attempt to request with connection data
+	req = &logical.Request{
+		Path:       "auth/foo/login",
+		Connection: &logical.Connection{},
+	}
+	if _, err := c.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if noop.Requests[0].Connection == nil {
+		t.Fatalf("bad: %#v", noop.Requests)
+	}
+}
+
+func TestCore_HandleRequest_AuditReads(t *testing.T) {
+	noop := &NoopAudit{}
+	c, _, root := TestCoreUnsealed(t)
+	c.auditBackends["noop"] = func(config *audit.BackendConfig) (audit.Backend, error) {
+		noop = &NoopAudit{
+			Config: config,
+		}
+		return noop, nil
+	}
+	req := logical.TestRequest(t, logical.WriteOperation, "sys/audit/noop")
+	req.Data["type"] = "noop"
+	req.ClientToken = root
+	if _, err := c.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// audited returns the operations audited by a write and a read
+	audited := func() []logical.Operation {
+		noop.Req = nil
+		noop.RespReq = nil
+		for _, op := range []logical.Operation{logical.WriteOperation, logical.ReadOperation} {
+			req := logical.TestRequest(t, op, "secret/test")
+			req.Data["foo"] = "bar"
+			req.ClientToken = root
+			if _, err := c.HandleRequest(req); err != nil {
+				t.Fatalf("err: %v", err)
+			}
+		}
+		if len(noop.Req) != len(noop.RespReq) {
+			t.Fatalf("bad: %#v %#v", noop.Req, noop.RespReq)
+		}
+		var ops []logical.Operation
+		for _, r := range noop.Req {
+			ops = append(ops, r.Operation)
+		}
+		return ops
+	}
+	all := []logical.Operation{logical.WriteOperation, logical.ReadOperation}
+	writes := []logical.Operation{logical.WriteOperation}
+
+	// Reads are audited by default
+	if ops := audited(); !reflect.DeepEqual(ops, all) {
+		t.Fatalf("bad: %v", ops)
+	}
+
+	// Writes are still audited with reads disabled
+	c.disableAuditReads = true
+	if ops := audited(); !reflect.DeepEqual(ops, writes) {
+		t.Fatalf("bad: %v", ops)
+	}
+
+	// The mount can override the global setting
+	tune := func(value string) {
+		req := logical.TestRequest(t, logical.WriteOperation, "sys/mounts/secret/tune")
+		req.Data["audit_reads"] = value
+		req.ClientToken = root
+		if _, err := c.HandleRequest(req); err != nil {
+			t.Fatalf("err: %v", err)
+		}
 	}
-	if _, err := c.HandleRequest(req); err != nil {
-		t.Fatalf("err: %v", err)
+	tune("true")
+	if ops := audited(); !reflect.DeepEqual(ops, all) {
+		t.Fatalf("bad: %v", ops)
 	}
-	if noop.Requests[0].Connection == nil {
-		t.Fatalf("bad: %#v", noop.Requests)
+	c.disableAuditReads = false
+	tune("false")
+	if ops := audited(); !reflect.DeepEqual(ops, writes) {
+		t.Fatalf("bad: %v", ops)
+	}
+	tune("system")
+	if ops := audited(); !reflect.DeepEqual(ops, all) {
+		t.Fatalf("bad: %v", ops)
+	}
+
+	req = logical.TestRequest(t, logical.WriteOperation, "sys/mounts/secret/tune")
+	req.Data["audit_reads"] = "sometimes"
+	req.ClientToken = root
+	if _, err := c.HandleRequest(req); err != logical.ErrInvalidRequest {
+		t.Fatalf("err: %v", err)
 	}
 }
 
@@ -829,6 +1627,7 @@ func TestCore_HandleLogin_Token(t *testing.T) {
 		DisplayName:  "foo-armon",
 		TTL:          time.Hour * 24,
 		CreationTime: te.CreationTime,
+		MFAIdentity:  "auth/foo/login\x00foo-armon",
 	}
 
 	if !reflect.DeepEqual(te, expect) {
@@ -841,6 +1640,77 @@ func TestCore_HandleLogin_Token(t *testing.T) {
 	}
 }
 
+func TestCore_HandleLogin_TokenTTLs(t *testing.T) {
+	noop := &NoopBackend{
+		Login: []string{"login"},
+		Response: &logical.Response{
+			Auth: &logical.Auth{
+				Policies: []string{"foo"},
+			},
+		},
+	}
+	c, _, root := TestCoreUnsealed(t)
+	c.credentialBackends["noop"] = func(conf *logical.BackendConfig) (logical.Backend, error) {
+		return noop, nil
+	}
+
+	// Enable two credential backends with different token TTLs
+	mounts := map[string]map[string]interface{}{
+		"ci": map[string]interface{}{
+			"token_default_ttl": "10m",
+			"token_max_ttl":     "1h",
+		},
+		"human": map[string]interface{}{
+			"token_default_ttl": "8h",
+		},
+	}
+	for path, config := range mounts {
+		req := logical.TestRequest(t, logical.WriteOperation, "sys/auth/"+path)
+		req.Data["type"] = "noop"
+		req.Data["config"] = config
+		req.ClientToken = root
+		if _, err := c.HandleRequest(req); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+
+	login := func(path string, ttl time.Duration) *logical.Auth {
+		noop.Response.Auth.TTL = ttl
+		resp, err := c.HandleRequest(&logical.Request{
+			Path: "auth/" + path + "/login",
+		})
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		te, err := c.tokenStore.Lookup(resp.Auth.ClientToken)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if te.TTL != resp.Auth.TTL {
+			t.Fatalf("bad: %v %v", te.TTL, resp.Auth.TTL)
+		}
+		return resp.Auth
+	}
+
+	if auth := login("ci", 0); auth.TTL != 10*time.Minute {
+		t.Fatalf("bad: %v", auth.TTL)
+	}
+	if auth := login("human", 0); auth.TTL != 8*time.Hour {
+		t.Fatalf("bad: %v", auth.TTL)
+	}
+
+	// A TTL requested by the backend is limited by the token max
+	if auth := login("ci", 2*time.Hour); auth.TTL != time.Hour {
+		t.Fatalf("bad: %v", auth.TTL)
+	}
+
+	// The token max is limited by the system max
+	c.router.MatchingMountEntry("auth/ci/").Config.TokenMaxTTL = 2 * c.maxLeaseTTL
+	if auth := login("ci", 3*c.maxLeaseTTL); auth.TTL != c.maxLeaseTTL {
+		t.Fatalf("bad: %v", auth.TTL)
+	}
+}
+
 func TestCore_HandleRequest_AuditTrail(t *testing.T) {
 	// Create a noop audit backend
 	noop := &NoopAudit{}
@@ -1025,6 +1895,7 @@ func TestCore_HandleRequest_CreateToken_Lease(t *testing.T) {
 		DisplayName:  "token",
 		CreationTime: te.CreationTime,
 		TTL:          time.Hour * 24 * 30,
+		MFAIdentity:  "auth/token/create\x00token",
 	}
 	if !reflect.DeepEqual(te, expect) {
 		t.Fatalf("Bad: %#v expect: %#v", te, expect)
@@ -1036,6 +1907,105 @@ func TestCore_HandleRequest_CreateToken_Lease(t *testing.T) {
 	}
 }
 
+func TestCore_BoundCIDRToken(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+
+	req := logical.TestRequest(t, logical.WriteOperation, "auth/token/create")
+	req.ClientToken = root
+	req.Data["bound_cidrs"] = []string{"10.0.0.0/8", "192.168.1.0/24"}
+	resp, err := c.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	clientToken := resp.Auth.ClientToken
+
+	// Used from within a bound network
+	req = logical.TestRequest(t, logical.ReadOperation, "auth/token/lookup-self")
+	req.ClientToken = clientToken
+	req.RemoteAddr = "10.1.2.3"
+	resp, err = c.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !reflect.DeepEqual(resp.Data["bound_cidrs"], []string{"10.0.0.0/8", "192.168.1.0/24"}) {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	// Used from outside the bound networks, or an unknown address
+	for _, addr := range []string{"192.168.2.1", ""} {
+		req = logical.TestRequest(t, logical.ReadOperation, "secret/foo")
+		req.ClientToken = clientToken
+		req.RemoteAddr = addr
+		if _, err := c.HandleRequest(req); err != logical.ErrPermissionDenied {
+			t.Fatalf("err: %v", err)
+		}
+	}
+}
+
+func TestCore_RevokeToken_Leases(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+
+	req := logical.TestRequest(t, logical.WriteOperation, "auth/token/create")
+	req.ClientToken = root
+	resp, err := c.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	clientToken := resp.Auth.ClientToken
+
+	// Issue leases under the token
+	req = &logical.Request{
+		Operation: logical.WriteOperation,
+		Path:      "secret/foo",
+		Data: map[string]interface{}{
+			"foo":   "bar",
+			"lease": "1h",
+		},
+		ClientToken: root,
+	}
+	if _, err := c.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	var leaseIDs []string
+	for i := 0; i < 2; i++ {
+		req = &logical.Request{
+			Operation:   logical.ReadOperation,
+			Path:        "secret/foo",
+			ClientToken: clientToken,
+		}
+		resp, err := c.HandleRequest(req)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		leaseIDs = append(leaseIDs, resp.Secret.LeaseID)
+	}
+	sort.Strings(leaseIDs)
+
+	out, err := c.expiration.ListLeasesByToken(clientToken)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !reflect.DeepEqual(out, leaseIDs) {
+		t.Fatalf("bad: %v %v", out, leaseIDs)
+	}
+
+	// Revoking the token revokes its leases
+	req = logical.TestRequest(t, logical.WriteOperation, "auth/token/revoke/"+clientToken)
+	req.ClientToken = root
+	if _, err := c.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for _, leaseID := range leaseIDs {
+		info, err := c.expiration.Lookup(leaseID)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if info != nil {
+			t.Fatalf("bad: %#v", info)
+		}
+	}
+}
+
 func TestCore_LimitedUseToken(t *testing.T) {
 	c, _, root := TestCoreUnsealed(t)
 
@@ -2106,3 +3076,251 @@ func TestCore_Standby_Rekey(t *testing.T) {
 		t.Fatalf("rekey failed")
 	}
 }
+
+func TestCore_SealUnseal_TokenConcurrencyLimit(t *testing.T) {
+	c, key, root := TestCoreUnsealed(t)
+	c.router.SetTokenConcurrencyLimit(3)
+
+	if err := c.Seal(root); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if unseal, err := c.Unseal(TestKeyCopy(key)); err != nil || !unseal {
+		t.Fatalf("err: %v %v", err, unseal)
+	}
+
+	// The limit survives the reset of the router
+	if c.router.tokenLimit != 3 {
+		t.Fatalf("bad: %d", c.router.tokenLimit)
+	}
+}
+
+func TestCore_SealUnseal_DisabledOperations(t *testing.T) {
+	c, key, root := TestCoreUnsealed(t)
+	c.router.SetDisabledOperations([]logical.Operation{logical.DeleteOperation})
+
+	if err := c.Seal(root); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if unseal, err := c.Unseal(TestKeyCopy(key)); err != nil || !unseal {
+		t.Fatalf("err: %v %v", err, unseal)
+	}
+
+	req := &logical.Request{
+		Operation:   logical.DeleteOperation,
+		Path:        "secret/foo",
+		ClientToken: root,
+	}
+	if _, err := c.HandleRequest(req); err != ErrOperationDisabled {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestCore_SealUnseal_RequestLogger(t *testing.T) {
+	c, key, root := TestCoreUnsealed(t)
+	c.router.SetRequestLogger(c.logger, "debug")
+
+	if err := c.Seal(root); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if unseal, err := c.Unseal(TestKeyCopy(key)); err != nil || !unseal {
+		t.Fatalf("err: %v %v", err, unseal)
+	}
+
+	if c.router.logger != c.logger || c.router.logLevel != "debug" {
+		t.Fatalf("bad: %v %q", c.router.logger, c.router.logLevel)
+	}
+}
+
+func TestCore_SealUnseal_GlobalRateLimit(t *testing.T) {
+	c, key, root := TestCoreUnsealed(t)
+	c.router.SetGlobalRateLimit(10, 10)
+
+	if err := c.Seal(root); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if unseal, err := c.Unseal(TestKeyCopy(key)); err != nil || !unseal {
+		t.Fatalf("err: %v %v", err, unseal)
+	}
+
+	if c.router.rateLimiter == nil {
+		t.Fatalf("rate limit lost")
+	}
+}
+
+// lossyHABackend is an HA backend whose held locks are lost when lose
+// is called
+type lossyHABackend struct {
+	*physical.InmemHABackend
+
+	l        sync.Mutex
+	lostCh   chan struct{}
+	acquired int
+}
+
+type lossyLock struct {
+	lock    physical.Lock
+	backend *lossyHABackend
+}
+
+func (b *lossyHABackend) LockWith(key, value string) (physical.Lock, error) {
+	lock, err := b.InmemHABackend.LockWith(key, value)
+	if err != nil {
+		return nil, err
+	}
+	return &lossyLock{lock: lock, backend: b}, nil
+}
+
+// lose loses the locks held, but not the ones acquired afterwards
+func (b *lossyHABackend) lose() {
+	b.l.Lock()
+	defer b.l.Unlock()
+	close(b.lostCh)
+	b.lostCh = make(chan struct{})
+}
+
+func (b *lossyHABackend) acquisitions() int {
+	b.l.Lock()
+	defer b.l.Unlock()
+	return b.acquired
+}
+
+func (l *lossyLock) Lock(stopCh <-chan struct{}) (<-chan struct{}, error) {
+	leaderCh, err := l.lock.Lock(stopCh)
+	if leaderCh == nil || err != nil {
+		return leaderCh, err
+	}
+	l.backend.l.Lock()
+	defer l.backend.l.Unlock()
+	l.backend.acquired++
+	return l.backend.lostCh, nil
+}
+
+func (l *lossyLock) Unlock() error {
+	return l.lock.Unlock()
+}
+
+func (l *lossyLock) Value() (bool, string, error) {
+	return l.lock.Value()
+}
+
+func TestCore_Standby_SealOnLeadershipLoss(t *testing.T) {
+	inm := &lossyHABackend{
+		InmemHABackend: physical.NewInmemHA(),
+		lostCh:         make(chan struct{}),
+	}
+	core, err := NewCore(&CoreConfig{
+		Physical:      inm,
+		AdvertiseAddr: "http://127.0.0.1:8200",
+		DisableMlock:  true,
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	key, _ := TestCoreInit(t, core)
+	if _, err := core.Unseal(TestKeyCopy(key)); err != nil {
+		t.Fatalf("unseal err: %s", err)
+	}
+	testWaitActive(t, core)
+
+	// Losing the lock seals the active node by default
+	inm.lose()
+	<-core.standbyDoneCh
+	if sealed, err := core.Sealed(); err != nil || !sealed {
+		t.Fatalf("bad: %v %v", sealed, err)
+	}
+}
+
+func TestCore_Standby_StandbyOnLeadershipLoss(t *testing.T) {
+	inm := &lossyHABackend{
+		InmemHABackend: physical.NewInmemHA(),
+		lostCh:         make(chan struct{}),
+	}
+	core, err := NewCore(&CoreConfig{
+		Physical:                inm,
+		AdvertiseAddr:           "http://127.0.0.1:8200",
+		DisableMlock:            true,
+		StandbyOnLeadershipLoss: true,
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	key, _ := TestCoreInit(t, core)
+	if _, err := core.Unseal(TestKeyCopy(key)); err != nil {
+		t.Fatalf("unseal err: %s", err)
+	}
+	testWaitActive(t, core)
+
+	// Losing the lock returns the node to standby, from which it takes
+	// the lock again
+	inm.lose()
+	start := time.Now()
+	for inm.acquisitions() < 2 {
+		if time.Now().Sub(start) > time.Second {
+			t.Fatalf("lock not acquired again")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	testWaitActive(t, core)
+	if sealed, err := core.Sealed(); err != nil || sealed {
+		t.Fatalf("bad: %v %v", sealed, err)
+	}
+}
+
+func TestCore_SealWithRequest_BoundCIDRs(t *testing.T) {
+	c, _, _ := TestCoreUnsealed(t)
+
+	ent := &TokenEntry{
+		Path:       "test",
+		Policies:   []string{"root"},
+		BoundCIDRs: []string{"10.0.0.0/8"},
+	}
+	if err := c.tokenStore.create(ent); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Without the address of the client the token cannot be used
+	if err := c.Seal(ent.ID); err != logical.ErrPermissionDenied {
+		t.Fatalf("err: %v", err)
+	}
+	req := &logical.Request{
+		ClientToken: ent.ID,
+		RemoteAddr:  "192.168.1.1",
+	}
+	if err := c.SealWithRequest(req); err != logical.ErrPermissionDenied {
+		t.Fatalf("err: %v", err)
+	}
+
+	req.RemoteAddr = "10.1.2.3"
+	if err := c.SealWithRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if sealed, err := c.Sealed(); err != nil || !sealed {
+		t.Fatalf("bad: %v %v", sealed, err)
+	}
+}
+
+func TestCore_GlobalRateLimit_InternalRequests(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+	c.router.SetGlobalRateLimit(1, 1)
+
+	req := &logical.Request{
+		Operation:   logical.ReadOperation,
+		Path:        "sys/mounts",
+		ClientToken: root,
+	}
+	if _, err := c.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := c.HandleRequest(req); err != ErrGlobalRateLimitExceeded {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Requests routed by the core itself are not limited
+	req = &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "sys/mounts",
+	}
+	if _, err := c.router.Route(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
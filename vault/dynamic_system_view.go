@@ -41,11 +41,17 @@ func (d dynamicSystemView) SudoPrivilege(path string, token string) bool {
 	return acl.RootPrivilege(path)
 }
 
+func (d dynamicSystemView) GeneratePasswordFromPolicy(policyName string) (string, error) {
+	if d.core.passwordPolicyStore == nil {
+		return "", ErrSealed
+	}
+	return d.core.passwordPolicyStore.GeneratePassword(policyName)
+}
+
 // TTLsByPath returns the default and max TTLs corresponding to a particular
 // mount point, or the system default
 func (d dynamicSystemView) fetchTTLs() (def, max time.Duration) {
-	def = d.core.defaultLeaseTTL
-	max = d.core.maxLeaseTTL
+	def, max = d.core.leaseTTLs()
 
 	if d.mountEntry.Config.DefaultLeaseTTL != 0 {
 		def = d.mountEntry.Config.DefaultLeaseTTL
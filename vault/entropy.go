@@ -0,0 +1,138 @@
+package vault
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	// entropySeedSize is the number of bytes read from the entropy
+	// source for every seed, and the size of the generator key
+	entropySeedSize = 32
+)
+
+// entropyPool is a random generator seeded from an entropy source, such
+// as a hardware RNG, together with crypto/rand. Output is the AES-CTR
+// keystream of a key that is replaced after every read, so earlier output
+// cannot be recovered from the state. Reseeding mixes fresh entropy from
+// both into the key, so the source can only add entropy and a weak or
+// repeating source never weakens the output below crypto/rand.
+type entropyPool struct {
+	l       sync.Mutex
+	source  io.Reader
+	osRand  io.Reader
+	key     []byte
+	reseeds uint64
+}
+
+// newEntropyPool returns a generator seeded from the given source and
+// crypto/rand
+func newEntropyPool(source io.Reader) (*entropyPool, error) {
+	p := &entropyPool{
+		source: source,
+		osRand: rand.Reader,
+		key:    make([]byte, entropySeedSize),
+	}
+	if err := p.reseed(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// reseed is used to mix fresh entropy from the source and crypto/rand
+// into the key
+func (p *entropyPool) reseed() error {
+	seed := make([]byte, entropySeedSize)
+	defer memzero(seed)
+	if _, err := io.ReadFull(p.source, seed); err != nil {
+		return fmt.Errorf("failed to read from entropy source: %v", err)
+	}
+	osSeed := make([]byte, entropySeedSize)
+	defer memzero(osSeed)
+	if _, err := io.ReadFull(p.osRand, osSeed); err != nil {
+		return fmt.Errorf("failed to read from crypto/rand: %v", err)
+	}
+
+	p.l.Lock()
+	defer p.l.Unlock()
+	h := sha256.New()
+	h.Write(p.key)
+	h.Write(seed)
+	h.Write(osSeed)
+	memzero(p.key)
+	p.key = h.Sum(nil)
+	p.reseeds++
+	return nil
+}
+
+// Read fills buf with random bytes
+func (p *entropyPool) Read(buf []byte) (int, error) {
+	p.l.Lock()
+	defer p.l.Unlock()
+
+	block, err := aes.NewCipher(p.key)
+	if err != nil {
+		return 0, err
+	}
+
+	// The key is used once, so a zero IV is safe. The keystream past the
+	// output becomes the next key.
+	out := make([]byte, len(buf)+entropySeedSize)
+	defer memzero(out)
+	cipher.NewCTR(block, make([]byte, aes.BlockSize)).XORKeyStream(out, out)
+	copy(buf, out)
+	copy(p.key, out[len(buf):])
+	return len(buf), nil
+}
+
+// entropyReader returns the source of randomness for generated tokens
+// and keys
+func (c *Core) entropyReader() io.Reader {
+	if c.entropy == nil {
+		return rand.Reader
+	}
+	return c.entropy
+}
+
+// startEntropyReseed is invoked as part of postUnseal to periodically
+// reseed the entropy pool, if configured
+func (c *Core) startEntropyReseed() {
+	if c.entropy == nil || c.entropyReseedInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(c.entropyReseedInterval)
+	c.entropyStopCh = make(chan struct{})
+	go func(stopCh chan struct{}) {
+		defer ticker.Stop()
+		c.runEntropyReseed(ticker.C, stopCh)
+	}(c.entropyStopCh)
+}
+
+// stopEntropyReseed is invoked as part of preSeal to stop reseeding
+func (c *Core) stopEntropyReseed() {
+	if c.entropyStopCh != nil {
+		close(c.entropyStopCh)
+		c.entropyStopCh = nil
+	}
+}
+
+// runEntropyReseed reseeds the entropy pool on every tick until stopped.
+// A failed reseed keeps the current key, which remains usable.
+func (c *Core) runEntropyReseed(tickCh <-chan time.Time, stopCh chan struct{}) {
+	for {
+		select {
+		case <-tickCh:
+			if err := c.entropy.reseed(); err != nil {
+				c.logger.Printf("[ERR] core: failed to reseed entropy: %v", err)
+			}
+		case <-stopCh:
+			return
+		}
+	}
+}
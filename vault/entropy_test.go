@@ -0,0 +1,169 @@
+package vault
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/physical"
+)
+
+// countingReader counts the reads from the wrapped source
+type countingReader struct {
+	source io.Reader
+	reads  int32
+}
+
+func (r *countingReader) Read(buf []byte) (int, error) {
+	atomic.AddInt32(&r.reads, 1)
+	return r.source.Read(buf)
+}
+
+func TestEntropyPool(t *testing.T) {
+	seed := bytes.Repeat([]byte{0x42}, 4*entropySeedSize)
+	p1, err := newEntropyPool(bytes.NewReader(seed))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	p2, err := newEntropyPool(bytes.NewReader(seed))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// A repeating source does not repeat the output, as crypto/rand is
+	// mixed into the seed
+	out1, out2 := make([]byte, 64), make([]byte, 64)
+	if _, err := io.ReadFull(p1, out1); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := io.ReadFull(p2, out2); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if bytes.Equal(out1, out2) {
+		t.Fatalf("output repeated across pools: %x", out1)
+	}
+	if _, err := io.ReadFull(p1, out2); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if bytes.Equal(out1, out2) {
+		t.Fatalf("output repeated")
+	}
+
+	// Reseeding reads from both the source and crypto/rand
+	source := &countingReader{source: bytes.NewReader(seed[:entropySeedSize])}
+	osRand := &countingReader{source: rand.Reader}
+	p1.source, p1.osRand = source, osRand
+	if err := p1.reseed(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if source.reads == 0 || osRand.reads == 0 || p1.reseeds != 2 {
+		t.Fatalf("bad: %d %d %d", source.reads, osRand.reads, p1.reseeds)
+	}
+	if _, err := io.ReadFull(p1, out1); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// A failed reseed keeps the generator usable
+	empty, err := newEntropyPool(bytes.NewReader(seed[:entropySeedSize]))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := empty.reseed(); err == nil {
+		t.Fatalf("expected error")
+	}
+	if _, err := io.ReadFull(empty, out1); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestNewCore_badEntropyReseedInterval(t *testing.T) {
+	for _, conf := range []*CoreConfig{
+		{EntropyReseedInterval: time.Minute},
+		{EntropySource: rand.Reader, EntropyReseedInterval: -time.Minute},
+	} {
+		conf.Physical = physical.NewInmem()
+		conf.DisableMlock = true
+		if _, err := NewCore(conf); err == nil {
+			t.Fatalf("should fail: %#v", conf)
+		}
+	}
+}
+
+func TestCore_EntropyReseed(t *testing.T) {
+	source := &countingReader{source: rand.Reader}
+	c, err := NewCore(&CoreConfig{
+		Physical:              physical.NewInmem(),
+		DisableMlock:          true,
+		EntropySource:         source,
+		EntropyReseedInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	key, root := TestCoreInit(t, c)
+	if _, err := c.Unseal(TestKeyCopy(key)); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if c.entropyStopCh == nil {
+		t.Fatalf("reseed not started")
+	}
+	if reads := atomic.LoadInt32(&source.reads); reads != 1 {
+		t.Fatalf("bad: %d", reads)
+	}
+
+	// Drive the reseed loop from a fake clock
+	tickCh := make(chan time.Time)
+	stopCh := make(chan struct{})
+	doneCh := make(chan struct{})
+	go func() {
+		c.runEntropyReseed(tickCh, stopCh)
+		close(doneCh)
+	}()
+
+	createToken := func() string {
+		req := logical.TestRequest(t, logical.WriteOperation, "auth/token/create")
+		req.ClientToken = root
+		resp, err := c.HandleRequest(req)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		return resp.Auth.ClientToken
+	}
+	seen := map[string]bool{createToken(): true}
+	for i := 0; i < 2; i++ {
+		tickCh <- time.Now()
+		tickCh <- time.Now()
+
+		// Generation keeps working across reseeds
+		token := createToken()
+		if seen[token] {
+			t.Fatalf("duplicate token: %s", token)
+		}
+		seen[token] = true
+	}
+	close(stopCh)
+	<-doneCh
+
+	// The initial seed and one per tick
+	c.entropy.l.Lock()
+	reseeds := c.entropy.reseeds
+	c.entropy.l.Unlock()
+	if reseeds != 5 {
+		t.Fatalf("bad: %d", reseeds)
+	}
+	if reads := atomic.LoadInt32(&source.reads); reads != 5 {
+		t.Fatalf("bad: %d", reads)
+	}
+
+	// Sealing stops the reseed loop
+	if err := c.Seal(root); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if c.entropyStopCh != nil {
+		t.Fatalf("reseed not stopped")
+	}
+}
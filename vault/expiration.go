@@ -2,15 +2,18 @@ package vault
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"path"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/armon/go-metrics"
+	"github.com/hashicorp/go-multierror"
 	"github.com/hashicorp/uuid"
 	"github.com/hashicorp/vault/logical"
 )
@@ -26,12 +29,24 @@ const (
 	// tokenViewPrefix is the prefix used for the token based lookup of leases.
 	tokenViewPrefix = "token/"
 
+	// leaseRenewPrefix is the request path used to renew a lease by ID
+	leaseRenewPrefix = "sys/leases/renew/"
+
 	// maxRevokeAttempts limits how many revoke attempts are made
 	maxRevokeAttempts = 6
 
 	// revokeRetryBase is a baseline retry time
 	revokeRetryBase = 10 * time.Second
 
+	// revokeGracePeriod is how long a revocation abandoned at a deadline
+	// is given to stop, so that it is not left running while the Vault
+	// is torn down
+	revokeGracePeriod = 2 * time.Second
+
+	// revokeRescheduleDelay is how long to wait before trying to revoke a
+	// lease again once maxRevokeAttempts have failed
+	revokeRescheduleDelay = time.Hour
+
 	// minRevokeDelay is used to prevent an instant revoke on restore
 	minRevokeDelay = 5 * time.Second
 
@@ -42,6 +57,12 @@ const (
 	defaultLeaseTTL = maxLeaseTTL
 )
 
+var (
+	// ErrRevokeTimeout is returned if leases could not all be revoked
+	// before the deadline
+	ErrRevokeTimeout = errors.New("timed out revoking leases")
+)
+
 // ExpirationManager is used by the Core to manage leases. Secrets
 // can provide a lease, meaning that they can be renewed or revoked.
 // If a secret is not renewed in timely manner, it may be expired, and
@@ -53,8 +74,24 @@ type ExpirationManager struct {
 	tokenStore *TokenStore
 	logger     *log.Logger
 
+	// maxClockSkew, if set, is how far ahead of the current time the
+	// issue time of a restored lease may be before it is considered to
+	// have been written with a wrong clock
+	maxClockSkew time.Duration
+
+	// revokeRetryBase is the delay before retrying a failed revocation,
+	// doubled on every attempt
+	revokeRetryBase time.Duration
+
+	// revokeGracePeriod is how long a revocation abandoned at a deadline
+	// is waited for once cancelled
+	revokeGracePeriod time.Duration
+
 	pending     map[string]*time.Timer
 	pendingLock sync.Mutex
+
+	// quitCh is closed by Stop to abort the retries of failed revocations
+	quitCh chan struct{}
 }
 
 // NewExpirationManager creates a new ExpirationManager that is backed
@@ -70,6 +107,10 @@ func NewExpirationManager(router *Router, view *BarrierView, ts *TokenStore, log
 		tokenStore: ts,
 		logger:     logger,
 		pending:    make(map[string]*time.Timer),
+		quitCh:     make(chan struct{}),
+
+		revokeRetryBase:   revokeRetryBase,
+		revokeGracePeriod: revokeGracePeriod,
 	}
 	return exp
 }
@@ -84,6 +125,7 @@ func (c *Core) setupExpiration() error {
 
 	// Create the manager
 	mgr := NewExpirationManager(c.router, view, c.tokenStore, c.logger)
+	mgr.maxClockSkew = c.maxLeaseClockSkew
 	c.expiration = mgr
 
 	// Link the token store to this
@@ -135,6 +177,13 @@ func (m *ExpirationManager) Restore() error {
 			continue
 		}
 
+		// Correct the lease if it was issued with a clock that was ahead
+		if m.checkIssueTime(le, time.Now().UTC()) {
+			if err := m.persistEntry(le); err != nil {
+				return err
+			}
+		}
+
 		// If there is no expiry time, don't do anything
 		if le.ExpireTime.IsZero() {
 			continue
@@ -157,6 +206,25 @@ func (m *ExpirationManager) Restore() error {
 	return nil
 }
 
+// checkIssueTime validates the issue time of a restored lease against the
+// current time. A lease issued further ahead than maxClockSkew was written
+// with a wrong clock, so its expire time cannot be trusted either: the
+// lease is rebased to expire after its original TTL from now. It returns
+// whether the lease was changed.
+func (m *ExpirationManager) checkIssueTime(le *leaseEntry, now time.Time) bool {
+	if m.maxClockSkew <= 0 || !le.IssueTime.After(now.Add(m.maxClockSkew)) {
+		return false
+	}
+
+	m.logger.Printf("[WARN] expire: lease '%s' was issued at %s, ahead of the current time %s; rebasing it",
+		le.LeaseID, le.IssueTime, now)
+	if !le.ExpireTime.IsZero() {
+		le.ExpireTime = now.Add(le.ExpireTime.Sub(le.IssueTime))
+	}
+	le.IssueTime = now
+	return true
+}
+
 // Stop is used to prevent further automatic revocations.
 // This must be called before sealing the view.
 func (m *ExpirationManager) Stop() error {
@@ -166,12 +234,23 @@ func (m *ExpirationManager) Stop() error {
 		timer.Stop()
 	}
 	m.pending = make(map[string]*time.Timer)
+	close(m.quitCh)
+	m.quitCh = make(chan struct{})
 	m.pendingLock.Unlock()
 	return nil
 }
 
 // Revoke is used to revoke a secret named by the given LeaseID
 func (m *ExpirationManager) Revoke(leaseID string) error {
+	return m.revoke(leaseID, nil)
+}
+
+// revoke is the implementation of Revoke. If cancelCh is closed before
+// the secret is revoked, nothing is done. If it is closed while the
+// secret is being revoked, the lease entry is kept rather than deleted
+// from storage that may be torn down; the lease is then revoked again
+// after the next unseal. ErrRevokeTimeout is returned in both cases.
+func (m *ExpirationManager) revoke(leaseID string, cancelCh <-chan struct{}) error {
 	defer metrics.MeasureSince([]string{"expire", "revoke"}, time.Now())
 	// Load the entry
 	le, err := m.loadEntry(leaseID)
@@ -185,9 +264,15 @@ func (m *ExpirationManager) Revoke(leaseID string) error {
 	}
 
 	// Revoke the entry
+	if isClosed(cancelCh) {
+		return ErrRevokeTimeout
+	}
 	if err := m.revokeEntry(le); err != nil {
 		return err
 	}
+	if isClosed(cancelCh) {
+		return fmt.Errorf("secret revoked but lease kept: %v", ErrRevokeTimeout)
+	}
 
 	// Delete the entry
 	if err := m.deleteEntry(leaseID); err != nil {
@@ -213,9 +298,20 @@ func (m *ExpirationManager) Revoke(leaseID string) error {
 // The prefix maps to that of the mount table to make this simpler
 // to reason about.
 func (m *ExpirationManager) RevokePrefix(prefix string) error {
+	return m.revokePrefix(prefix, nil, time.Time{})
+}
+
+// revokePrefix is the implementation of RevokePrefix. Leases under any
+// of the exempt prefixes are kept. If the deadline is non-zero,
+// revocation stops with ErrRevokeTimeout once it passes, including while
+// waiting on a single lease, whose revocation is then cancelled. An empty
+// prefix revokes every lease. A lease that fails to revoke does not stop
+// the others from being revoked; the failures are returned together,
+// along with ErrRevokeTimeout if the deadline passed.
+func (m *ExpirationManager) revokePrefix(prefix string, exempt []string, deadline time.Time) error {
 	defer metrics.MeasureSince([]string{"expire", "revoke-prefix"}, time.Now())
 	// Ensure there is a trailing slash
-	if !strings.HasSuffix(prefix, "/") {
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
 		prefix = prefix + "/"
 	}
 
@@ -227,34 +323,177 @@ func (m *ExpirationManager) RevokePrefix(prefix string) error {
 	}
 
 	// Revoke all the keys
-	for idx, suffix := range existing {
+	var result error
+	for _, suffix := range existing {
 		leaseID := prefix + suffix
-		if err := m.Revoke(leaseID); err != nil {
-			return fmt.Errorf("failed to revoke '%s' (%d / %d): %v",
-				leaseID, idx+1, len(existing), err)
+		if hasAnyPrefix(leaseID, exempt) {
+			continue
+		}
+		ok, err := m.revokeBefore(leaseID, deadline)
+		if err != nil {
+			result = multierror.Append(result, fmt.Errorf("failed to revoke '%s': %v", leaseID, err))
+		}
+		if !ok {
+			if result == nil {
+				return ErrRevokeTimeout
+			}
+			return multierror.Append(result, ErrRevokeTimeout)
 		}
 	}
-	return nil
+	return result
+}
+
+// revokeBefore is used to revoke the lease, waiting no longer than the
+// deadline if it is non-zero. If the deadline passes first, false is
+// returned and the revocation is cancelled. It is then waited for up to
+// the grace period, so that it is not left running unseen; its error is
+// returned, or an error if it is still running.
+func (m *ExpirationManager) revokeBefore(leaseID string, deadline time.Time) (bool, error) {
+	if deadline.IsZero() {
+		return true, m.Revoke(leaseID)
+	}
+	remaining := deadline.Sub(time.Now())
+	if remaining <= 0 {
+		return false, nil
+	}
+
+	// The result channel is buffered, so an abandoned revocation does
+	// not block once it returns
+	cancelCh := make(chan struct{})
+	resultCh := make(chan error, 1)
+	go func() {
+		resultCh <- m.revoke(leaseID, cancelCh)
+	}()
+
+	select {
+	case err := <-resultCh:
+		return true, err
+	case <-time.After(remaining):
+	}
+
+	close(cancelCh)
+	select {
+	case err := <-resultCh:
+		return false, err
+	case <-time.After(m.revokeGracePeriod):
+		return false, fmt.Errorf("revocation still in progress after %s", m.revokeGracePeriod)
+	}
+}
+
+// isClosed checks if the channel is closed. A nil channel never is.
+func isClosed(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+// hasAnyPrefix checks if the lease ID is under any of the prefixes
+func hasAnyPrefix(leaseID string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(leaseID, prefix) {
+			return true
+		}
+	}
+	return false
 }
 
 // RevokeByToken is used to revoke all the secrets issued with
-// a given token. This is done by using the secondary index.
-func (m *ExpirationManager) RevokeByToken(token string) error {
+// a given token. This is done by using the secondary index. The
+// number of leases revoked is returned.
+func (m *ExpirationManager) RevokeByToken(token string) (int, error) {
 	defer metrics.MeasureSince([]string{"expire", "revoke-by-token"}, time.Now())
 	// Lookup the leases
 	existing, err := m.lookupByToken(token)
 	if err != nil {
-		return fmt.Errorf("failed to scan for leases: %v", err)
+		return 0, fmt.Errorf("failed to scan for leases: %v", err)
 	}
 
 	// Revoke all the keys
 	for idx, leaseID := range existing {
 		if err := m.Revoke(leaseID); err != nil {
-			return fmt.Errorf("failed to revoke '%s' (%d / %d): %v",
+			return idx, fmt.Errorf("failed to revoke '%s' (%d / %d): %v",
 				leaseID, idx+1, len(existing), err)
 		}
 	}
-	return nil
+	return len(existing), nil
+}
+
+// ListLeasesByToken is used to list the LeaseIDs of the secrets issued
+// with a given token, sorted
+func (m *ExpirationManager) ListLeasesByToken(token string) ([]string, error) {
+	defer metrics.MeasureSince([]string{"expire", "list-leases-by-token"}, time.Now())
+	leaseIDs, err := m.lookupByToken(token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan for leases: %v", err)
+	}
+	sort.Strings(leaseIDs)
+	return leaseIDs, nil
+}
+
+// LeaseInfo is the metadata of a lease, as returned by Lookup. It
+// intentionally excludes the secret data.
+type LeaseInfo struct {
+	LeaseID    string
+	Path       string
+	IssueTime  time.Time
+	ExpireTime time.Time
+	TTL        time.Duration
+	Renewable  bool
+}
+
+// Lookup is used to read the metadata of the lease named by the given
+// LeaseID. A nil LeaseInfo is returned if there is no such lease.
+func (m *ExpirationManager) Lookup(leaseID string) (*LeaseInfo, error) {
+	defer metrics.MeasureSince([]string{"expire", "lookup"}, time.Now())
+	le, err := m.loadEntry(leaseID)
+	if err != nil {
+		return nil, err
+	}
+	if le == nil {
+		return nil, nil
+	}
+
+	info := &LeaseInfo{
+		LeaseID:    le.LeaseID,
+		Path:       le.Path,
+		IssueTime:  le.IssueTime,
+		ExpireTime: le.ExpireTime,
+		Renewable:  le.renewable() == nil,
+	}
+	if !le.ExpireTime.IsZero() {
+		info.TTL = le.ExpireTime.Sub(time.Now().UTC())
+		if info.TTL < 0 {
+			info.TTL = 0
+		}
+	}
+	return info, nil
+}
+
+// ListLeases is used to list the LeaseIDs under the given prefix, sorted.
+// As with RevokePrefix, the prefix maps to that of the mount table.
+func (m *ExpirationManager) ListLeases(prefix string) ([]string, error) {
+	defer metrics.MeasureSince([]string{"expire", "list-leases"}, time.Now())
+	// Ensure there is a trailing slash
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix = prefix + "/"
+	}
+
+	// Accumulate existing leases
+	sub := m.idView.SubView(prefix)
+	existing, err := CollectKeys(sub)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan for leases: %v", err)
+	}
+
+	leaseIDs := make([]string, 0, len(existing))
+	for _, suffix := range existing {
+		leaseIDs = append(leaseIDs, prefix+suffix)
+	}
+	sort.Strings(leaseIDs)
+	return leaseIDs, nil
 }
 
 // Renew is used to renew a secret using the given leaseID
@@ -306,6 +545,32 @@ func (m *ExpirationManager) Renew(leaseID string, increment time.Duration) (*log
 	return resp, nil
 }
 
+// ownedBy is used to check if the lease named by the given LeaseID
+// was created by a request using the given client token
+func (m *ExpirationManager) ownedBy(leaseID, token string) (bool, error) {
+	le, err := m.loadEntry(leaseID)
+	if err != nil {
+		return false, err
+	}
+	return le != nil && le.ClientToken != "" && le.ClientToken == token, nil
+}
+
+// checkLeaseRenewal is used to verify that the token of a request to
+// the lease renewal path either created the lease or has sudo access
+// to the path
+func (c *Core) checkLeaseRenewal(path, token string, acl *ACL) error {
+	leaseID := strings.TrimPrefix(path, leaseRenewPrefix)
+	owned, err := c.expiration.ownedBy(leaseID, token)
+	if err != nil {
+		c.logger.Printf("[ERR] core: failed to check owner of lease '%s': %v", leaseID, err)
+		return ErrInternalError
+	}
+	if !owned && !acl.RootPrivilege(path) {
+		return logical.ErrPermissionDenied
+	}
+	return nil
+}
+
 // RenewToken is used to renew a token which does not need to
 // invoke a logical backend.
 func (m *ExpirationManager) RenewToken(source string, token string,
@@ -322,7 +587,7 @@ func (m *ExpirationManager) RenewToken(source string, token string,
 
 	// Check if the lease is renewable
 	if err := le.renewable(); err != nil {
-		return nil, err
+		return nil, logical.NewInvalidRequest("%v", err)
 	}
 
 	// Attempt to renew the auth entry
@@ -355,6 +620,42 @@ func (m *ExpirationManager) RenewToken(source string, token string,
 	return resp.Auth, nil
 }
 
+// tokenPolicies returns the policies the credential backend of a token
+// currently maps it to, by renewing the auth of the token with the
+// backend without extending its lease. It is nil if the token has no
+// renewable lease or the backend returns no policies.
+func (m *ExpirationManager) tokenPolicies(source string, token string) ([]string, error) {
+	le, err := m.loadEntry(path.Join(source, m.tokenStore.SaltID(token)))
+	if err != nil || le == nil {
+		return nil, err
+	}
+	if err := le.renewable(); err != nil {
+		return nil, nil
+	}
+
+	resp, err := m.renewAuthEntry(le, 0)
+	if err != nil {
+		return nil, err
+	}
+	if resp == nil || resp.IsError() || resp.Auth == nil {
+		return nil, nil
+	}
+	return resp.Auth.Policies, nil
+}
+
+// tokenExpireTime returns the time the lease of a token expires. It is
+// zero if the token has no lease or the lease does not expire.
+func (m *ExpirationManager) tokenExpireTime(source string, token string) (time.Time, error) {
+	le, err := m.loadEntry(path.Join(source, m.tokenStore.SaltID(token)))
+	if err != nil {
+		return time.Time{}, err
+	}
+	if le == nil {
+		return time.Time{}, nil
+	}
+	return le.ExpireTime, nil
+}
+
 // Register is used to take a request and response with an associated
 // lease. The secret gets assigned a LeaseID and the management of
 // of lease is assumed by the expiration manager.
@@ -459,6 +760,7 @@ func (m *ExpirationManager) expireID(leaseID string) {
 	// Clear from the pending expiration
 	m.pendingLock.Lock()
 	delete(m.pending, leaseID)
+	quitCh := m.quitCh
 	m.pendingLock.Unlock()
 
 	for attempt := uint(0); attempt < maxRevokeAttempts; attempt++ {
@@ -468,9 +770,29 @@ func (m *ExpirationManager) expireID(leaseID string) {
 			return
 		}
 		m.logger.Printf("[ERR] expire: failed to revoke '%s': %v", leaseID, err)
-		time.Sleep((1 << attempt) * revokeRetryBase)
+
+		select {
+		case <-quitCh:
+			return
+		case <-time.After((1 << attempt) * m.revokeRetryBase):
+		}
+	}
+	m.logger.Printf("[ERR] expire: maximum revoke attempts for '%s' reached, retrying in %s",
+		leaseID, revokeRescheduleDelay)
+
+	// Try again later, rather than leaving the lease until the next unseal
+	m.pendingLock.Lock()
+	defer m.pendingLock.Unlock()
+	select {
+	case <-quitCh:
+		return
+	default:
+	}
+	if _, ok := m.pending[leaseID]; !ok {
+		m.pending[leaseID] = time.AfterFunc(revokeRescheduleDelay, func() {
+			m.expireID(leaseID)
+		})
 	}
-	m.logger.Printf("[ERR] expire: maximum revoke attempts for '%s' reached", leaseID)
 }
 
 // revokeEntry is used to attempt revocation of an internal entry
@@ -518,6 +840,10 @@ func (m *ExpirationManager) renewAuthEntry(le *leaseEntry, increment time.Durati
 
 	req := logical.RenewAuthRequest(le.Path, &auth, nil)
 	resp, err := m.router.Route(req)
+	if _, ok := err.(*logical.VaultError); ok {
+		// Keep the code of errors such as a backend not being ready
+		return nil, err
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to renew entry: %v", err)
 	}
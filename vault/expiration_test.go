@@ -7,6 +7,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/hashicorp/go-multierror"
 	"github.com/hashicorp/uuid"
 	"github.com/hashicorp/vault/logical"
 )
@@ -83,6 +84,63 @@ func TestExpiration_Restore(t *testing.T) {
 	}
 }
 
+func TestExpiration_Restore_ClockSkew(t *testing.T) {
+	exp := mockExpiration(t)
+	exp.maxClockSkew = time.Hour
+	defer exp.Stop()
+
+	// One lease issued by a node with a correct clock, and one by a node
+	// whose clock was a year ahead
+	now := time.Now().UTC()
+	skewed := now.Add(365 * 24 * time.Hour)
+	leases := []*leaseEntry{
+		&leaseEntry{
+			LeaseID:    "prod/aws/normal",
+			Path:       "prod/aws/normal",
+			Secret:     &logical.Secret{},
+			IssueTime:  now.Add(time.Minute),
+			ExpireTime: now.Add(time.Minute + time.Hour),
+		},
+		&leaseEntry{
+			LeaseID:    "prod/aws/skewed",
+			Path:       "prod/aws/skewed",
+			Secret:     &logical.Secret{},
+			IssueTime:  skewed,
+			ExpireTime: skewed.Add(time.Hour),
+		},
+	}
+	for _, le := range leases {
+		if err := exp.persistEntry(le); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+
+	if err := exp.Restore(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The lease within the tolerance is untouched
+	le, err := exp.loadEntry("prod/aws/normal")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !le.IssueTime.Equal(leases[0].IssueTime) || !le.ExpireTime.Equal(leases[0].ExpireTime) {
+		t.Fatalf("bad: %#v", le)
+	}
+
+	// The skewed lease keeps its TTL from now
+	le, err = exp.loadEntry("prod/aws/skewed")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if le.IssueTime.After(time.Now().UTC()) {
+		t.Fatalf("bad: %#v", le)
+	}
+	if ttl := le.ExpireTime.Sub(le.IssueTime); ttl != time.Hour {
+		t.Fatalf("bad: %s", ttl)
+	}
+}
+
 func TestExpiration_Register(t *testing.T) {
 	exp := mockExpiration(t)
 	req := &logical.Request{
@@ -208,6 +266,67 @@ func TestExpiration_Revoke(t *testing.T) {
 	}
 }
 
+func TestExpiration_RevokeOnExpire_Retry(t *testing.T) {
+	exp := mockExpiration(t)
+	exp.revokeRetryBase = time.Millisecond
+	defer exp.Stop()
+
+	// Nothing is mounted at the path of the leases, so revoking fails
+	var ids []string
+	for _, path := range []string{"prod/aws/foo", "prod/aws/bar"} {
+		req := &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      path,
+		}
+		resp := &logical.Response{
+			Secret: &logical.Secret{
+				LeaseOptions: logical.LeaseOptions{
+					TTL: time.Hour,
+				},
+			},
+		}
+		id, err := exp.Register(req, resp)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	isPending := func(id string) bool {
+		exp.pendingLock.Lock()
+		defer exp.pendingLock.Unlock()
+		_, ok := exp.pending[id]
+		return ok
+	}
+
+	// Once the attempts are exhausted, the revocation is tried again later
+	exp.expireID(ids[0])
+	if !isPending(ids[0]) {
+		t.Fatalf("revocation not rescheduled")
+	}
+
+	// Stopping aborts the retries
+	exp.revokeRetryBase = time.Hour
+	doneCh := make(chan struct{})
+	go func() {
+		exp.expireID(ids[1])
+		close(doneCh)
+	}()
+	for isPending(ids[1]) {
+		time.Sleep(time.Millisecond)
+	}
+	if err := exp.Stop(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	select {
+	case <-doneCh:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("retries not stopped")
+	}
+	if isPending(ids[0]) || isPending(ids[1]) {
+		t.Fatalf("revocations still pending")
+	}
+}
+
 func TestExpiration_RevokeOnExpire(t *testing.T) {
 	exp := mockExpiration(t)
 	noop := &NoopBackend{}
@@ -317,6 +436,302 @@ func TestExpiration_RevokePrefix(t *testing.T) {
 	}
 }
 
+func TestExpiration_RevokePrefix_Deadline(t *testing.T) {
+	exp := mockExpiration(t)
+	noop := &NoopBackend{}
+	_, barrier, _ := mockBarrier(t)
+	view := NewBarrierView(barrier, "logical/")
+	exp.router.Mount(noop, "prod/aws/", &MountEntry{UUID: uuid.GenerateUUID()}, view)
+
+	req := &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "prod/aws/foo",
+	}
+	resp := &logical.Response{
+		Secret: &logical.Secret{
+			LeaseOptions: logical.LeaseOptions{
+				TTL: time.Hour,
+			},
+		},
+	}
+	if _, err := exp.Register(req, resp); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Nothing is revoked past the deadline
+	err := exp.revokePrefix("", nil, time.Now().Add(-time.Second))
+	if err != ErrRevokeTimeout {
+		t.Fatalf("err: %v", err)
+	}
+	if len(noop.Requests) != 0 {
+		t.Fatalf("bad: %v", noop.Requests)
+	}
+
+	// An empty prefix revokes every lease
+	if err := exp.revokePrefix("", nil, time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(noop.Requests) != 1 {
+		t.Fatalf("bad: %v", noop.Requests)
+	}
+}
+
+func TestExpiration_RevokePrefix_PartialFailure(t *testing.T) {
+	exp := mockExpiration(t)
+	defer exp.Stop()
+	noop := &NoopBackend{}
+	_, barrier, _ := mockBarrier(t)
+	view := NewBarrierView(barrier, "logical/")
+	exp.router.Mount(noop, "prod/aws/", &MountEntry{UUID: uuid.GenerateUUID()}, view)
+
+	// Nothing is mounted at prod/gcp/, so its lease fails to revoke
+	ids := make(map[string]string)
+	for _, path := range []string{"prod/aws/foo", "prod/gcp/bar", "prod/aws/zip"} {
+		req := &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      path,
+		}
+		resp := &logical.Response{
+			Secret: &logical.Secret{
+				LeaseOptions: logical.LeaseOptions{
+					TTL: time.Hour,
+				},
+			},
+		}
+		id, err := exp.Register(req, resp)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		ids[path] = id
+	}
+
+	// The failure is reported with the lease it concerns
+	err := exp.RevokePrefix("prod/")
+	if err == nil {
+		t.Fatalf("expected error")
+	}
+	if !strings.Contains(err.Error(), ids["prod/gcp/bar"]) {
+		t.Fatalf("bad: %v", err)
+	}
+
+	// The other leases were revoked anyway
+	if len(noop.Requests) != 2 {
+		t.Fatalf("Bad: %v", noop.Requests)
+	}
+	for path, id := range ids {
+		le, err := exp.loadEntry(id)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if (le != nil) != (path == "prod/gcp/bar") {
+			t.Fatalf("bad: %s %#v", path, le)
+		}
+	}
+}
+
+func TestExpiration_RevokePrefix_TimeoutDuringLease(t *testing.T) {
+	exp := mockExpiration(t)
+	exp.revokeGracePeriod = 10 * time.Millisecond
+	defer exp.Stop()
+	blocking := &blockingBackend{
+		started: make(chan struct{}, 10),
+		release: make(chan struct{}),
+	}
+	defer close(blocking.release)
+	_, barrier, _ := mockBarrier(t)
+	view := NewBarrierView(barrier, "logical/")
+	exp.router.Mount(blocking, "prod/aws/", &MountEntry{UUID: uuid.GenerateUUID()}, view)
+
+	// Nothing is mounted at prod/gcp/, so its lease fails to revoke,
+	// and the lease at prod/aws/, which is scanned after it, does not
+	// revoke before the deadline
+	ids := make(map[string]string)
+	for _, path := range []string{"prod/aws/foo", "prod/gcp/bar"} {
+		req := &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      path,
+		}
+		resp := &logical.Response{
+			Secret: &logical.Secret{
+				LeaseOptions: logical.LeaseOptions{
+					TTL: time.Hour,
+				},
+			},
+		}
+		id, err := exp.Register(req, resp)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		ids[path] = id
+	}
+
+	// Both the failure and the timeout are reported
+	start := time.Now()
+	err := exp.revokePrefix("prod/", nil, time.Now().Add(50*time.Millisecond))
+	if time.Since(start) > 5*time.Second {
+		t.Fatalf("revocation did not stop at the deadline")
+	}
+	merr, ok := err.(*multierror.Error)
+	if !ok {
+		t.Fatalf("err: %v", err)
+	}
+	var timedOut bool
+	for _, err := range merr.Errors {
+		if err == ErrRevokeTimeout {
+			timedOut = true
+		}
+	}
+	if !timedOut || !strings.Contains(err.Error(), ids["prod/gcp/bar"]) {
+		t.Fatalf("bad: %v", err)
+	}
+
+	// The revocation still running past the grace period is reported
+	if !strings.Contains(err.Error(), ids["prod/aws/foo"]) ||
+		!strings.Contains(err.Error(), "still in progress") {
+		t.Fatalf("bad: %v", err)
+	}
+}
+
+func TestExpiration_RevokePrefix_CancelDuringLease(t *testing.T) {
+	exp := mockExpiration(t)
+	exp.revokeGracePeriod = 5 * time.Second
+	defer exp.Stop()
+	blocking := &blockingBackend{
+		started: make(chan struct{}, 10),
+		release: make(chan struct{}),
+	}
+	_, barrier, _ := mockBarrier(t)
+	view := NewBarrierView(barrier, "logical/")
+	exp.router.Mount(blocking, "prod/aws/", &MountEntry{UUID: uuid.GenerateUUID()}, view)
+
+	req := &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "prod/aws/foo",
+	}
+	resp := &logical.Response{
+		Secret: &logical.Secret{
+			LeaseOptions: logical.LeaseOptions{
+				TTL: time.Hour,
+			},
+		},
+	}
+	id, err := exp.Register(req, resp)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The backend finishes revoking the secret after the deadline,
+	// within the grace period
+	go func() {
+		<-blocking.started
+		time.Sleep(100 * time.Millisecond)
+		close(blocking.release)
+	}()
+	err = exp.revokePrefix("prod/", nil, time.Now().Add(50*time.Millisecond))
+	if err == nil || !strings.Contains(err.Error(), id) ||
+		!strings.Contains(err.Error(), "lease kept") {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The revocation stopped before deleting the lease, so it is still
+	// known and revoked again later
+	le, err := exp.loadEntry(id)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if le == nil {
+		t.Fatalf("lease should be kept")
+	}
+}
+
+func TestExpiration_LookupListLeases(t *testing.T) {
+	exp := mockExpiration(t)
+	noop := &NoopBackend{}
+	_, barrier, _ := mockBarrier(t)
+	view := NewBarrierView(barrier, "logical/")
+	exp.router.Mount(noop, "prod/aws/", &MountEntry{UUID: uuid.GenerateUUID()}, view)
+	exp.router.Mount(noop, "prod/db/", &MountEntry{UUID: uuid.GenerateUUID()}, view)
+
+	paths := []string{
+		"prod/aws/foo",
+		"prod/aws/sub/bar",
+		"prod/db/zip",
+	}
+	var awsIDs []string
+	for _, path := range paths {
+		req := &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      path,
+		}
+		resp := &logical.Response{
+			Secret: &logical.Secret{
+				LeaseOptions: logical.LeaseOptions{
+					TTL:       time.Hour,
+					Renewable: true,
+				},
+			},
+			Data: map[string]interface{}{
+				"access_key": "xyz",
+				"secret_key": "abcd",
+			},
+		}
+		id, err := exp.Register(req, resp)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if strings.HasPrefix(id, "prod/aws/") {
+			awsIDs = append(awsIDs, id)
+		}
+	}
+
+	// Listing should only return the leases under the prefix
+	out, err := exp.ListLeases("prod/aws")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	sort.Strings(awsIDs)
+	if !reflect.DeepEqual(out, awsIDs) {
+		t.Fatalf("bad: %v %v", out, awsIDs)
+	}
+	out, err = exp.ListLeases("")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(out) != 3 {
+		t.Fatalf("bad: %v", out)
+	}
+
+	// Lookup should return the metadata
+	info, err := exp.Lookup(awsIDs[0])
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if info == nil {
+		t.Fatalf("missing lease")
+	}
+	if info.LeaseID != awsIDs[0] || !strings.HasPrefix(info.Path, "prod/aws/") {
+		t.Fatalf("bad: %#v", info)
+	}
+	if !info.Renewable {
+		t.Fatalf("bad: %#v", info)
+	}
+	if info.TTL <= 59*time.Minute || info.TTL > time.Hour {
+		t.Fatalf("bad: %#v", info)
+	}
+	if info.IssueTime.IsZero() || !info.ExpireTime.After(info.IssueTime) {
+		t.Fatalf("bad: %#v", info)
+	}
+
+	// Unknown leases have no metadata
+	info, err = exp.Lookup("prod/aws/missing")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if info != nil {
+		t.Fatalf("bad: %#v", info)
+	}
+}
+
 func TestExpiration_RevokeByToken(t *testing.T) {
 	exp := mockExpiration(t)
 	noop := &NoopBackend{}
@@ -352,10 +767,22 @@ func TestExpiration_RevokeByToken(t *testing.T) {
 		}
 	}
 
+	leaseIDs, err := exp.ListLeasesByToken("foobarbaz")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(leaseIDs) != 3 {
+		t.Fatalf("bad: %v", leaseIDs)
+	}
+
 	// Should nuke all the keys
-	if err := exp.RevokeByToken("foobarbaz"); err != nil {
+	n, err := exp.RevokeByToken("foobarbaz")
+	if err != nil {
 		t.Fatalf("err: %v", err)
 	}
+	if n != 3 {
+		t.Fatalf("bad: %d", n)
+	}
 
 	if len(noop.Requests) != 3 {
 		t.Fatalf("Bad: %v", noop.Requests)
@@ -376,6 +803,14 @@ func TestExpiration_RevokeByToken(t *testing.T) {
 	if !reflect.DeepEqual(noop.Paths, expect) {
 		t.Fatalf("bad: %v", noop.Paths)
 	}
+
+	leaseIDs, err = exp.ListLeasesByToken("foobarbaz")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(leaseIDs) != 0 {
+		t.Fatalf("bad: %v", leaseIDs)
+	}
 }
 
 func TestExpiration_RenewToken(t *testing.T) {
@@ -0,0 +1,110 @@
+package vault
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+const (
+	// idleSealMinCheckInterval and idleSealMaxCheckInterval bound how
+	// often the time since the last request is checked, which is
+	// otherwise a tenth of the idle seal period
+	idleSealMinCheckInterval = time.Second
+	idleSealMaxCheckInterval = time.Minute
+)
+
+// markRequest records that a request is being handled, resetting the
+// idle seal period
+func (c *Core) markRequest() {
+	if c.idleSealPeriod > 0 {
+		atomic.StoreInt64(&c.lastRequest, time.Now().UnixNano())
+	}
+}
+
+// startIdleSeal is invoked as part of postUnseal to seal the Vault once
+// no request is handled for the idle seal period, if configured
+func (c *Core) startIdleSeal() {
+	if c.idleSealPeriod <= 0 {
+		return
+	}
+	if c.ha != nil && !c.idleSealHA {
+		c.logger.Printf("[WARN] core: idle seal is disabled since HA is enabled")
+		return
+	}
+
+	interval := c.idleSealPeriod / 10
+	if interval < idleSealMinCheckInterval {
+		interval = idleSealMinCheckInterval
+	}
+	if interval > idleSealMaxCheckInterval {
+		interval = idleSealMaxCheckInterval
+	}
+
+	atomic.StoreInt64(&c.lastRequest, time.Now().UnixNano())
+	ticker := time.NewTicker(interval)
+	c.idleSealStopCh = make(chan struct{})
+	go func(stopCh chan struct{}) {
+		defer ticker.Stop()
+		c.runIdleSeal(ticker.C, stopCh)
+	}(c.idleSealStopCh)
+}
+
+// stopIdleSeal is invoked as part of preSeal to stop the idle check. It
+// does not wait for the check to exit, since the check itself seals.
+func (c *Core) stopIdleSeal() {
+	if c.idleSealStopCh != nil {
+		close(c.idleSealStopCh)
+		c.idleSealStopCh = nil
+	}
+}
+
+// runIdleSeal checks on every tick if the idle seal period has passed
+// since the last request, sealing the Vault if so, until stopped
+func (c *Core) runIdleSeal(tickCh <-chan time.Time, stopCh chan struct{}) {
+	for {
+		select {
+		case now := <-tickCh:
+			last := time.Unix(0, atomic.LoadInt64(&c.lastRequest))
+			if idle := now.Sub(last); idle >= c.idleSealPeriod {
+				c.idleSeal(idle, stopCh)
+				return
+			}
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+// idleSeal seals the Vault after it has been idle for the given duration.
+// Nothing is done if the check was stopped in the meantime, since the
+// Vault was sealed or stepped down.
+func (c *Core) idleSeal(idle time.Duration, stopCh chan struct{}) {
+	c.stateLock.Lock()
+	defer c.stateLock.Unlock()
+	select {
+	case <-stopCh:
+		return
+	default:
+	}
+	if c.sealed || c.standby {
+		return
+	}
+
+	c.logger.Printf("[WARN] core: sealing after %s without requests", idle)
+	req := &logical.Request{
+		Operation: logical.WriteOperation,
+		Path:      "sys/seal",
+	}
+	if err := c.auditBroker.LogRequest(nil, req, nil); err != nil {
+		c.logger.Printf("[ERR] core: failed to audit idle seal: %v", err)
+	}
+
+	if c.revokeLeasesOnSeal {
+		c.revokeLeases()
+	}
+	if err := c.sealInternal(); err != nil {
+		c.logger.Printf("[ERR] core: idle seal failed: %v", err)
+	}
+}
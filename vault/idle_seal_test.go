@@ -0,0 +1,129 @@
+package vault
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/physical"
+)
+
+func TestNewCore_badIdleSealPeriod(t *testing.T) {
+	conf := &CoreConfig{
+		Physical:       physical.NewInmem(),
+		DisableMlock:   true,
+		IdleSealPeriod: -time.Minute,
+	}
+	if _, err := NewCore(conf); err == nil {
+		t.Fatalf("should fail")
+	}
+}
+
+func TestCore_IdleSeal(t *testing.T) {
+	c, err := NewCore(&CoreConfig{
+		Physical:       physical.NewInmem(),
+		DisableMlock:   true,
+		IdleSealPeriod: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	key, root := TestCoreInit(t, c)
+	if _, err := c.Unseal(TestKeyCopy(key)); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if c.idleSealStopCh == nil {
+		t.Fatalf("idle seal not started")
+	}
+
+	// Drive the idle check from a fake clock. Every send returns once the
+	// previous tick was handled.
+	tickCh := make(chan time.Time)
+	doneCh := make(chan struct{})
+	go func() {
+		c.runIdleSeal(tickCh, c.idleSealStopCh)
+		close(doneCh)
+	}()
+	lastRequest := func() time.Time {
+		return time.Unix(0, atomic.LoadInt64(&c.lastRequest))
+	}
+
+	tickCh <- lastRequest().Add(30 * time.Minute)
+
+	// A request resets the idle period
+	req := logical.TestRequest(t, logical.ReadOperation, "secret/foo")
+	req.ClientToken = root
+	if _, err := c.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	tickCh <- lastRequest().Add(59 * time.Minute)
+	if sealed, _ := c.Sealed(); sealed {
+		t.Fatalf("should not be sealed")
+	}
+
+	// No request for the idle period seals
+	tickCh <- lastRequest().Add(2 * time.Hour)
+	<-doneCh
+	if sealed, _ := c.Sealed(); !sealed {
+		t.Fatalf("should be sealed")
+	}
+	if c.idleSealStopCh != nil {
+		t.Fatalf("idle seal not stopped")
+	}
+}
+
+func TestCore_IdleSeal_Stopped(t *testing.T) {
+	c, err := NewCore(&CoreConfig{
+		Physical:       physical.NewInmem(),
+		DisableMlock:   true,
+		IdleSealPeriod: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	key, root := TestCoreInit(t, c)
+	if _, err := c.Unseal(TestKeyCopy(key)); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// A check from before a seal does not seal after the next unseal
+	stopCh := c.idleSealStopCh
+	if err := c.Seal(root); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := c.Unseal(TestKeyCopy(key)); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	c.idleSeal(2*time.Hour, stopCh)
+	if sealed, _ := c.Sealed(); sealed {
+		t.Fatalf("should not be sealed")
+	}
+}
+
+func TestCore_IdleSeal_HA(t *testing.T) {
+	for _, allowHA := range []bool{false, true} {
+		c, err := NewCore(&CoreConfig{
+			Physical:       physical.NewInmemHA(),
+			AdvertiseAddr:  "http://127.0.0.1:8200",
+			DisableMlock:   true,
+			IdleSealPeriod: time.Hour,
+			IdleSealHA:     allowHA,
+		})
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		key, _ := TestCoreInit(t, c)
+		if _, err := c.Unseal(TestKeyCopy(key)); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		testWaitActive(t, c)
+
+		c.stateLock.RLock()
+		started := c.idleSealStopCh != nil
+		c.stateLock.RUnlock()
+		if started != allowHA {
+			t.Fatalf("bad: %v %v", started, allowHA)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package vault
+
+import (
+	"strings"
+	"time"
+
+	"github.com/armon/go-metrics"
+	"golang.org/x/net/context"
+)
+
+// barrierPlaintextPaths are the paths written directly to the physical
+// backend, which are not protected by the barrier
+var barrierPlaintextPaths = []string{
+	barrierInitPath,
+	coreSealConfigPath,
+	coreStorageProbePath,
+	coreLockPath,
+	coreUnsealProgressPath,
+}
+
+// VerifyBarrier is used to read and decrypt every entry protected by the
+// barrier, checking that the authentication tag of each validates. It
+// returns the number of entries checked and the keys that failed,
+// indicating corruption or tampering. This may only be run on the active
+// node, and stops early if the context is cancelled.
+func (c *Core) VerifyBarrier(ctx context.Context) (int, []string, error) {
+	defer metrics.MeasureSince([]string{"core", "verify_barrier"}, time.Now())
+	c.stateLock.RLock()
+	defer c.stateLock.RUnlock()
+	if c.sealed {
+		return 0, nil, ErrSealed
+	}
+	if c.standby {
+		return 0, nil, ErrStandby
+	}
+
+	var checked int
+	var failures []string
+	var walk func(prefix string) error
+	walk = func(prefix string) error {
+		keys, err := c.barrier.List(prefix)
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			default:
+			}
+
+			path := prefix + key
+			if strings.HasSuffix(key, "/") {
+				if err := walk(path); err != nil {
+					return err
+				}
+				continue
+			}
+			if isBarrierPlaintextPath(path) {
+				continue
+			}
+
+			ok, err := c.barrier.VerifyEntry(path)
+			if err != nil {
+				return err
+			}
+			checked++
+			if !ok {
+				c.logger.Printf("[ERR] core: barrier entry '%s' failed verification", path)
+				failures = append(failures, path)
+			}
+		}
+		return nil
+	}
+	if err := walk(""); err != nil {
+		return checked, failures, err
+	}
+	return checked, failures, nil
+}
+
+// isBarrierPlaintextPath checks if the path is not protected by the barrier
+func isBarrierPlaintextPath(path string) bool {
+	for _, p := range barrierPlaintextPaths {
+		if path == p || strings.HasPrefix(path, p+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package vault
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/physical"
+	"golang.org/x/net/context"
+)
+
+func TestCore_VerifyBarrier(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+
+	for _, path := range []string{"secret/foo", "secret/bar"} {
+		req := &logical.Request{
+			Operation: logical.WriteOperation,
+			Path:      path,
+			Data: map[string]interface{}{
+				"foo": "bar",
+			},
+			ClientToken: root,
+		}
+		if _, err := c.HandleRequest(req); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+
+	// The unseal progress is stored outside of the barrier
+	pe := &physical.Entry{
+		Key:   coreUnsealProgressPath,
+		Value: []byte("not encrypted by the barrier"),
+	}
+	if err := c.physical.Put(pe); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	checked, failures, err := c.VerifyBarrier(context.Background())
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if checked == 0 || len(failures) != 0 {
+		t.Fatalf("bad: %d %v", checked, failures)
+	}
+
+	// Corrupt the ciphertext of one entry
+	me := c.router.MatchingMountEntry("secret/")
+	key := "logical/" + me.UUID + "/foo"
+	pe, err = c.physical.Get(key)
+	if err != nil || pe == nil {
+		t.Fatalf("err: %v %v", err, pe)
+	}
+	pe.Value[len(pe.Value)-1] ^= 0xff
+	if err := c.physical.Put(pe); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	checked2, failures, err := c.VerifyBarrier(context.Background())
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if checked2 != checked {
+		t.Fatalf("bad: %d %d", checked2, checked)
+	}
+	if !reflect.DeepEqual(failures, []string{key}) {
+		t.Fatalf("bad: %v", failures)
+	}
+}
+
+func TestCore_VerifyBarrier_Cancel(t *testing.T) {
+	c, _, _ := TestCoreUnsealed(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := c.VerifyBarrier(ctx); err != context.Canceled {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestCore_VerifyBarrier_Standby(t *testing.T) {
+	c, _, _ := TestCoreUnsealed(t)
+
+	c.stateLock.Lock()
+	c.standby = true
+	c.stateLock.Unlock()
+	if _, _, err := c.VerifyBarrier(context.Background()); err != ErrStandby {
+		t.Fatalf("err: %v", err)
+	}
+}
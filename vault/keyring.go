@@ -33,6 +33,10 @@ type Key struct {
 	Version     int
 	Value       []byte
 	InstallTime time.Time
+
+	// Algorithm is the AEAD used with this key. Keys installed before
+	// the algorithm was recorded leave it empty and use AES-GCM.
+	Algorithm string `json:",omitempty"`
 }
 
 // Serialize is used to create a byte encoded key
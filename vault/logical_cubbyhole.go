@@ -99,6 +99,11 @@ func (b *CubbyholeBackend) handleWrite(
 	if req.ClientToken == "" {
 		return nil, fmt.Errorf("[ERR] cubbyhole write: Client token empty")
 	}
+	// Check that the key is safe to persist
+	if err := validateUTF8("key", req.Path); err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+
 	// Check that some fields are given
 	if len(req.Data) == 0 {
 		return nil, fmt.Errorf("missing data fields")
@@ -43,6 +43,14 @@ func LeaseSwitchedPassthroughBackend(conf *logical.BackendConfig, leases bool) (
 						Type:        framework.TypeString,
 						Description: "TTL time for this key when read. Ex: 1h",
 					},
+					"limit": &framework.FieldSchema{
+						Type:        framework.TypeInt,
+						Description: "Maximum number of keys to return when listing.",
+					},
+					"next_token": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: "Continuation token returned by a previous list.",
+					},
 				},
 
 				Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -105,8 +113,15 @@ func (b *PassthroughBackend) handleRead(
 
 	// Decode the data
 	var rawData map[string]interface{}
-	if err := json.Unmarshal(out.Value, &rawData); err != nil {
-		return nil, fmt.Errorf("json decoding failed: %v", err)
+	if len(out.Value) != 0 {
+		if err := json.Unmarshal(out.Value, &rawData); err != nil {
+			return nil, fmt.Errorf("json decoding failed: %v", err)
+		}
+	}
+
+	// The key exists, so it is reported even with an empty value
+	if rawData == nil {
+		rawData = map[string]interface{}{}
 	}
 
 	var resp *logical.Response
@@ -147,9 +162,9 @@ func (b *PassthroughBackend) handleRead(
 
 func (b *PassthroughBackend) handleWrite(
 	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	// Check that some fields are given
-	if len(req.Data) == 0 {
-		return nil, fmt.Errorf("missing data fields")
+	// Check that the key is safe to persist
+	if err := validateUTF8("key", req.Path); err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
 	}
 
 	// Check if there is a ttl key; verify parseability if so
@@ -169,8 +184,13 @@ func (b *PassthroughBackend) handleWrite(
 		}
 	}
 
-	// JSON encode the data
-	buf, err := json.Marshal(req.Data)
+	// JSON encode the data. A key without any fields is stored as an
+	// empty object, so its presence is preserved.
+	rawData := req.Data
+	if rawData == nil {
+		rawData = map[string]interface{}{}
+	}
+	buf, err := json.Marshal(rawData)
 	if err != nil {
 		return nil, fmt.Errorf("json encoding failed: %v", err)
 	}
@@ -205,8 +225,15 @@ func (b *PassthroughBackend) handleList(
 		return nil, err
 	}
 
+	// Return only the requested page of keys
+	limit := data.Get("limit").(int)
+	if limit < 0 {
+		return logical.ErrorResponse("limit must not be negative"), logical.ErrInvalidRequest
+	}
+	page, next := logical.ListPage(keys, data.Get("next_token").(string), limit)
+
 	// Generate the response
-	return logical.ListResponse(keys), nil
+	return logical.ListPageResponse(page, next), nil
 }
 
 func (b *PassthroughBackend) GeneratesLeases() bool {
@@ -239,4 +266,11 @@ can be used as a hint from the writer of a secret to the consumer of a secret
 that the consumer should re-read the value before the TTL has expired.
 However, any revocation must be handled by the user of this backend; the lease
 duration does not affect the provided data in any way.
+
+A key may be written without any fields. Reading it then returns an
+empty set of data, unlike a key that does not exist.
+
+When listing, the "limit" field caps the number of keys returned. If more
+keys remain, the response carries a "next_token" that can be passed back
+as "next_token" to fetch the next page.
 `
@@ -48,6 +48,63 @@ func TestPassthroughBackend_Write(t *testing.T) {
 	test(b)
 }
 
+func TestPassthroughBackend_EmptyValue(t *testing.T) {
+	test := func(b logical.Backend) {
+		req := logical.TestRequest(t, logical.WriteOperation, "foo")
+		if _, err := b.HandleRequest(req); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+
+		// The key is present, with no data
+		req.Operation = logical.ReadOperation
+		resp, err := b.HandleRequest(req)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if resp == nil || resp.Data == nil || len(resp.Data) != 0 {
+			t.Fatalf("bad: %#v", resp)
+		}
+
+		// A missing key is not found
+		req.Path = "bar"
+		resp, err = b.HandleRequest(req)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if resp != nil {
+			t.Fatalf("bad: %#v", resp)
+		}
+	}
+	b := testPassthroughBackend()
+	test(b)
+	b = testPassthroughLeasedBackend()
+	test(b)
+}
+
+func TestPassthroughBackend_Write_InvalidKey(t *testing.T) {
+	b := testPassthroughBackend()
+	for _, key := range []string{"foo\x00bar", "foo\xff\xfe"} {
+		req := logical.TestRequest(t, logical.WriteOperation, key)
+		req.Data["raw"] = "test"
+
+		resp, err := b.HandleRequest(req)
+		if err != logical.ErrInvalidRequest {
+			t.Fatalf("err: %v", err)
+		}
+		if resp == nil || !resp.IsError() {
+			t.Fatalf("bad: %v", resp)
+		}
+
+		out, err := req.Storage.Get(key)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if out != nil {
+			t.Fatalf("should not be written: %q", key)
+		}
+	}
+}
+
 func TestPassthroughBackend_Read(t *testing.T) {
 	test := func(b logical.Backend, ttlType string, leased bool) {
 		req := logical.TestRequest(t, logical.WriteOperation, "foo")
@@ -35,11 +35,15 @@ func NewSystemBackend(core *Core, config *logical.BackendConfig) logical.Backend
 				"revoke-prefix/*",
 				"policy",
 				"policy/*",
+				"password-policies",
+				"password-policies/*",
 				"audit",
 				"audit/*",
 				"seal", // Must be set for Core.Seal() logic
 				"raw/*",
 				"rotate",
+				"rotation-schedule/*",
+				"recover-mount",
 			},
 		},
 
@@ -60,6 +64,22 @@ func NewSystemBackend(core *Core, config *logical.BackendConfig) logical.Backend
 						Type:        framework.TypeString,
 						Description: strings.TrimSpace(sysHelp["tune_max_lease_ttl"][0]),
 					},
+					"log_level": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: strings.TrimSpace(sysHelp["tune_log_level"][0]),
+					},
+					"audit_reads": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: strings.TrimSpace(sysHelp["tune_audit_reads"][0]),
+					},
+					"response_cache_ttl": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: strings.TrimSpace(sysHelp["tune_response_cache_ttl"][0]),
+					},
+					"preserve_leases_on_seal": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: strings.TrimSpace(sysHelp["tune_preserve_leases_on_seal"][0]),
+					},
 				},
 
 				Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -135,6 +155,24 @@ func NewSystemBackend(core *Core, config *logical.BackendConfig) logical.Backend
 				HelpDescription: strings.TrimSpace(sysHelp["remount"][1]),
 			},
 
+			&framework.Path{
+				Pattern: "recover-mount",
+
+				Fields: map[string]*framework.FieldSchema{
+					"path": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: strings.TrimSpace(sysHelp["recover_mount_path"][0]),
+					},
+				},
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.WriteOperation: b.handleRecoverMount,
+				},
+
+				HelpSynopsis:    strings.TrimSpace(sysHelp["recover_mount"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["recover_mount"][1]),
+			},
+
 			&framework.Path{
 				Pattern: "renew/(?P<lease_id>.+)",
 
@@ -157,6 +195,96 @@ func NewSystemBackend(core *Core, config *logical.BackendConfig) logical.Backend
 				HelpDescription: strings.TrimSpace(sysHelp["renew"][1]),
 			},
 
+			&framework.Path{
+				Pattern: "rotation-schedule/(?P<path>.+)",
+
+				Fields: map[string]*framework.FieldSchema{
+					"path": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: strings.TrimSpace(sysHelp["rotation_path"][0]),
+					},
+					"interval": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: strings.TrimSpace(sysHelp["rotation_interval"][0]),
+					},
+				},
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation:   b.handleRotationScheduleRead,
+					logical.WriteOperation:  b.handleRotationScheduleWrite,
+					logical.DeleteOperation: b.handleRotationScheduleDelete,
+				},
+
+				HelpSynopsis:    strings.TrimSpace(sysHelp["rotation-schedule"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["rotation-schedule"][1]),
+			},
+
+			&framework.Path{
+				Pattern: "leases/renew/(?P<lease_id>.+)",
+
+				Fields: map[string]*framework.FieldSchema{
+					"lease_id": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: strings.TrimSpace(sysHelp["lease_id"][0]),
+					},
+					"increment": &framework.FieldSchema{
+						Type:        framework.TypeDurationSecond,
+						Description: strings.TrimSpace(sysHelp["increment"][0]),
+					},
+				},
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.WriteOperation: b.handleLeaseRenew,
+				},
+
+				HelpSynopsis:    strings.TrimSpace(sysHelp["leases-renew"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["leases-renew"][1]),
+			},
+
+			&framework.Path{
+				Pattern: "leases/lookup/(?P<lease_id>.+)",
+
+				Fields: map[string]*framework.FieldSchema{
+					"lease_id": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: strings.TrimSpace(sysHelp["lease_id"][0]),
+					},
+				},
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation: b.handleLeaseLookup,
+				},
+
+				HelpSynopsis:    strings.TrimSpace(sysHelp["leases-lookup"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["leases-lookup"][1]),
+			},
+
+			&framework.Path{
+				Pattern: "leases/list/(?P<prefix>.*)",
+
+				Fields: map[string]*framework.FieldSchema{
+					"prefix": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: strings.TrimSpace(sysHelp["leases-list-prefix"][0]),
+					},
+					"limit": &framework.FieldSchema{
+						Type:        framework.TypeInt,
+						Description: strings.TrimSpace(sysHelp["list-limit"][0]),
+					},
+					"next_token": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: strings.TrimSpace(sysHelp["list-next-token"][0]),
+					},
+				},
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ListOperation: b.handleLeaseList,
+				},
+
+				HelpSynopsis:    strings.TrimSpace(sysHelp["leases-list"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["leases-list"][1]),
+			},
+
 			&framework.Path{
 				Pattern: "revoke/(?P<lease_id>.+)",
 
@@ -204,6 +332,33 @@ func NewSystemBackend(core *Core, config *logical.BackendConfig) logical.Backend
 				HelpDescription: strings.TrimSpace(sysHelp["auth-table"][1]),
 			},
 
+			&framework.Path{
+				Pattern: "auth/(?P<path>.+?)/tune$",
+
+				Fields: map[string]*framework.FieldSchema{
+					"path": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: strings.TrimSpace(sysHelp["auth_path"][0]),
+					},
+					"token_default_ttl": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: strings.TrimSpace(sysHelp["tune_token_default_ttl"][0]),
+					},
+					"token_max_ttl": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: strings.TrimSpace(sysHelp["tune_token_max_ttl"][0]),
+					},
+				},
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation:  b.handleAuthTuneRead,
+					logical.WriteOperation: b.handleAuthTuneWrite,
+				},
+
+				HelpSynopsis:    strings.TrimSpace(sysHelp["auth_tune"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["auth_tune"][1]),
+			},
+
 			&framework.Path{
 				Pattern: "auth/(?P<path>.+)",
 
@@ -220,6 +375,18 @@ func NewSystemBackend(core *Core, config *logical.BackendConfig) logical.Backend
 						Type:        framework.TypeString,
 						Description: strings.TrimSpace(sysHelp["auth_desc"][0]),
 					},
+					"config": &framework.FieldSchema{
+						Type:        framework.TypeMap,
+						Description: strings.TrimSpace(sysHelp["auth_config"][0]),
+					},
+					"options": &framework.FieldSchema{
+						Type:        framework.TypeMap,
+						Description: strings.TrimSpace(sysHelp["auth_opts"][0]),
+					},
+					"human_readable_prefix": &framework.FieldSchema{
+						Type:        framework.TypeBool,
+						Description: strings.TrimSpace(sysHelp["auth_human_readable_prefix"][0]),
+					},
 				},
 
 				Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -266,6 +433,59 @@ func NewSystemBackend(core *Core, config *logical.BackendConfig) logical.Backend
 				HelpDescription: strings.TrimSpace(sysHelp["policy"][1]),
 			},
 
+			&framework.Path{
+				Pattern: "password-policies$",
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation: b.handlePasswordPolicyList,
+				},
+
+				HelpSynopsis:    strings.TrimSpace(sysHelp["password-policy-list"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["password-policy-list"][1]),
+			},
+
+			&framework.Path{
+				Pattern: "password-policies/" + framework.GenericNameRegex("name") + "/generate$",
+
+				Fields: map[string]*framework.FieldSchema{
+					"name": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: strings.TrimSpace(sysHelp["password-policy-name"][0]),
+					},
+				},
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation: b.handlePasswordPolicyGenerate,
+				},
+
+				HelpSynopsis:    strings.TrimSpace(sysHelp["password-policy-generate"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["password-policy-generate"][1]),
+			},
+
+			&framework.Path{
+				Pattern: "password-policies/" + framework.GenericNameRegex("name"),
+
+				Fields: map[string]*framework.FieldSchema{
+					"name": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: strings.TrimSpace(sysHelp["password-policy-name"][0]),
+					},
+					"policy": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: strings.TrimSpace(sysHelp["password-policy-rules"][0]),
+					},
+				},
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation:   b.handlePasswordPolicyRead,
+					logical.WriteOperation:  b.handlePasswordPolicySet,
+					logical.DeleteOperation: b.handlePasswordPolicyDelete,
+				},
+
+				HelpSynopsis:    strings.TrimSpace(sysHelp["password-policy"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["password-policy"][1]),
+			},
+
 			&framework.Path{
 				Pattern: "audit$",
 
@@ -308,6 +528,28 @@ func NewSystemBackend(core *Core, config *logical.BackendConfig) logical.Backend
 				HelpDescription: strings.TrimSpace(sysHelp["audit"][1]),
 			},
 
+			&framework.Path{
+				Pattern: "audit-hash/(?P<path>.+)",
+
+				Fields: map[string]*framework.FieldSchema{
+					"path": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: strings.TrimSpace(sysHelp["audit_path"][0]),
+					},
+					"input": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: strings.TrimSpace(sysHelp["audit_hash_input"][0]),
+					},
+				},
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.WriteOperation: b.handleAuditHash,
+				},
+
+				HelpSynopsis:    strings.TrimSpace(sysHelp["audit-hash"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["audit-hash"][1]),
+			},
+
 			&framework.Path{
 				Pattern: "raw/(?P<path>.+)",
 
@@ -445,9 +687,9 @@ func (b *SystemBackend) handleMount(
 			logical.ErrInvalidRequest
 	}
 
-	if config.DefaultLeaseTTL > b.Core.maxLeaseTTL {
+	if _, sysMax := b.Core.leaseTTLs(); config.DefaultLeaseTTL > sysMax {
 		return logical.ErrorResponse(fmt.Sprintf(
-				"given default lease TTL greater than system max lease TTL of %d", int(b.Core.maxLeaseTTL.Seconds()))),
+				"given default lease TTL greater than system max lease TTL of %d", int(sysMax.Seconds()))),
 			logical.ErrInvalidRequest
 	}
 
@@ -474,11 +716,11 @@ func (b *SystemBackend) handleMount(
 	return nil, nil
 }
 
-// used to intercept an HTTPCodedError so it goes back to callee
+// used to intercept an HTTPCodedError or VaultError so it goes back to callee
 func handleError(
 	err error) (*logical.Response, error) {
 	switch err.(type) {
-	case logical.HTTPCodedError:
+	case logical.HTTPCodedError, *logical.VaultError:
 		return logical.ErrorResponse(err.Error()), err
 	default:
 		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
@@ -514,6 +756,25 @@ func (b *SystemBackend) handleRemount(
 			logical.ErrInvalidRequest
 	}
 
+	// Credential backends are moved within the auth table
+	fromAuth := strings.HasPrefix(fromPath, credentialRoutePrefix)
+	toAuth := strings.HasPrefix(toPath, credentialRoutePrefix)
+	if fromAuth != toAuth {
+		return logical.ErrorResponse(
+				"credential backends can only be remounted within 'auth/'"),
+			logical.ErrInvalidRequest
+	}
+	if fromAuth {
+		err := b.Core.remountCredential(
+			strings.TrimPrefix(fromPath, credentialRoutePrefix),
+			strings.TrimPrefix(toPath, credentialRoutePrefix))
+		if err != nil {
+			b.Backend.Logger().Printf("[ERR] sys: remount '%s' to '%s' failed: %v", fromPath, toPath, err)
+			return handleError(err)
+		}
+		return nil, nil
+	}
+
 	// Attempt remount
 	if err := b.Core.remount(fromPath, toPath); err != nil {
 		b.Backend.Logger().Printf("[ERR] sys: remount '%s' to '%s' failed: %v", fromPath, toPath, err)
@@ -523,6 +784,25 @@ func (b *SystemBackend) handleRemount(
 	return nil, nil
 }
 
+// handleRecoverMount is used to re-enable a disabled backend whose
+// data is still retained
+func (b *SystemBackend) handleRecoverMount(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	path := data.Get("path").(string)
+	if path == "" {
+		return logical.ErrorResponse(
+				"path must be specified as a string"),
+			logical.ErrInvalidRequest
+	}
+
+	if err := b.Core.recoverMount(path); err != nil {
+		b.Backend.Logger().Printf("[ERR] sys: recover mount '%s' failed: %v", path, err)
+		return handleError(err)
+	}
+
+	return nil, nil
+}
+
 // handleMountTuneRead is used to get config settings on a backend
 func (b *SystemBackend) handleMountTuneRead(
 	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
@@ -550,6 +830,20 @@ func (b *SystemBackend) handleMountTuneRead(
 			"max_lease_ttl":     int(sysView.MaxLeaseTTL().Seconds()),
 		},
 	}
+	if me := b.Core.router.MatchingMountEntry(path); me != nil {
+		if me.Config.LogLevel != "" {
+			resp.Data["log_level"] = me.Config.LogLevel
+		}
+		if me.Config.AuditReads != nil {
+			resp.Data["audit_reads"] = *me.Config.AuditReads
+		}
+		if me.Config.ResponseCacheTTL > 0 {
+			resp.Data["response_cache_ttl"] = int(me.Config.ResponseCacheTTL.Seconds())
+		}
+		if me.Config.PreserveLeasesOnSeal {
+			resp.Data["preserve_leases_on_seal"] = true
+		}
+	}
 
 	return resp, nil
 }
@@ -623,6 +917,38 @@ func (b *SystemBackend) handleMountTuneWrite(
 		}
 	}
 
+	// Request logging
+	if level := data.Get("log_level").(string); level != "" {
+		if err := b.tuneMountLogLevel(path, &mountEntry.Config, level); err != nil {
+			b.Backend.Logger().Printf("[ERR] sys: tune of path '%s' failed: %v", path, err)
+			return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+		}
+	}
+
+	// Read auditing
+	if auditReads := data.Get("audit_reads").(string); auditReads != "" {
+		if err := b.tuneMountAuditReads(path, &mountEntry.Config, auditReads); err != nil {
+			b.Backend.Logger().Printf("[ERR] sys: tune of path '%s' failed: %v", path, err)
+			return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+		}
+	}
+
+	// Response caching
+	if cacheTTL := data.Get("response_cache_ttl").(string); cacheTTL != "" {
+		if err := b.tuneMountResponseCacheTTL(path, &mountEntry.Config, cacheTTL); err != nil {
+			b.Backend.Logger().Printf("[ERR] sys: tune of path '%s' failed: %v", path, err)
+			return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+		}
+	}
+
+	// Lease revocation on seal
+	if preserve := data.Get("preserve_leases_on_seal").(string); preserve != "" {
+		if err := b.tuneMountPreserveLeases(path, &mountEntry.Config, preserve); err != nil {
+			b.Backend.Logger().Printf("[ERR] sys: tune of path '%s' failed: %v", path, err)
+			return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+		}
+	}
+
 	return nil, nil
 }
 
@@ -645,6 +971,37 @@ func (b *SystemBackend) handleRenew(
 	return resp, err
 }
 
+// handleLeaseRenew is used to renew a lease with a given LeaseID and
+// return its new TTL. The core verifies that the token owns the lease
+// or has sudo access to the path.
+func (b *SystemBackend) handleLeaseRenew(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	leaseID := data.Get("lease_id").(string)
+	increment := time.Duration(data.Get("increment").(int)) * time.Second
+
+	resp, err := b.Core.expiration.Renew(leaseID, increment)
+	if err != nil {
+		b.Backend.Logger().Printf("[ERR] sys: renew '%s' failed: %v", leaseID, err)
+		return handleError(err)
+	}
+
+	// Return the new TTL rather than the secret, which was returned
+	// when the lease was created
+	var ttl time.Duration
+	renewable := false
+	if resp != nil && resp.Secret != nil {
+		ttl = resp.Secret.LeaseTotal()
+		renewable = resp.Secret.Renewable
+	}
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"lease_id":  leaseID,
+			"ttl":       int64(ttl.Seconds()),
+			"renewable": renewable,
+		},
+	}, nil
+}
+
 // handleRevoke is used to revoke a given LeaseID
 func (b *SystemBackend) handleRevoke(
 	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
@@ -673,51 +1030,291 @@ func (b *SystemBackend) handleRevokePrefix(
 	return nil, nil
 }
 
-// handleAuthTable handles the "auth" endpoint to provide the auth table
-func (b *SystemBackend) handleAuthTable(
+// handleLeaseLookup is used to read the metadata of a lease
+func (b *SystemBackend) handleLeaseLookup(
 	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	b.Core.auth.Lock()
-	defer b.Core.auth.Unlock()
+	leaseID := data.Get("lease_id").(string)
+
+	info, err := b.Core.expiration.Lookup(leaseID)
+	if err != nil {
+		b.Backend.Logger().Printf("[ERR] sys: lease lookup '%s' failed: %v", leaseID, err)
+		return handleError(err)
+	}
+	if info == nil {
+		return nil, nil
+	}
 
 	resp := &logical.Response{
-		Data: make(map[string]interface{}),
+		Data: map[string]interface{}{
+			"lease_id":   info.LeaseID,
+			"path":       info.Path,
+			"issue_time": info.IssueTime,
+			"ttl":        int64(info.TTL.Seconds()),
+			"renewable":  info.Renewable,
+		},
 	}
-	for _, entry := range b.Core.auth.Entries {
-		info := map[string]string{
-			"type":        entry.Type,
-			"description": entry.Description,
-		}
-		resp.Data[entry.Path] = info
+	if !info.ExpireTime.IsZero() {
+		resp.Data["expire_time"] = info.ExpireTime
 	}
 	return resp, nil
 }
 
-// handleEnableAuth is used to enable a new credential backend
-func (b *SystemBackend) handleEnableAuth(
+// handleRotationScheduleRead is used to read the rotation schedule of a path
+func (b *SystemBackend) handleRotationScheduleRead(
 	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	// Get all the options
 	path := data.Get("path").(string)
-	logicalType := data.Get("type").(string)
-	description := data.Get("description").(string)
 
-	if logicalType == "" {
-		return logical.ErrorResponse(
-				"backend type must be specified as a string"),
-			logical.ErrInvalidRequest
+	info := b.Core.rotation.Lookup(path)
+	if info == nil {
+		return nil, nil
 	}
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"path":          info.Path,
+			"interval":      int64(info.Interval.Seconds()),
+			"last_rotation": info.LastRotation,
+			"next_rotation": info.NextRotation,
+		},
+	}, nil
+}
 
-	// Create the mount entry
-	me := &MountEntry{
-		Path:        path,
-		Type:        logicalType,
-		Description: description,
+// handleRotationScheduleWrite is used to schedule the rotation of a path
+func (b *SystemBackend) handleRotationScheduleWrite(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	path := data.Get("path").(string)
+
+	raw := data.Get("interval").(string)
+	if raw == "" {
+		return logical.ErrorResponse("interval must be specified"), logical.ErrInvalidRequest
+	}
+	interval, err := time.ParseDuration(raw)
+	if err != nil {
+		return logical.ErrorResponse(fmt.Sprintf(
+			"invalid interval: %v", err)), logical.ErrInvalidRequest
+	}
+
+	if err := b.Core.rotation.Schedule(path, interval); err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+	return nil, nil
+}
+
+// handleRotationScheduleDelete is used to stop rotating a path
+func (b *SystemBackend) handleRotationScheduleDelete(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	path := data.Get("path").(string)
+
+	if err := b.Core.rotation.Unschedule(path); err != nil {
+		b.Backend.Logger().Printf("[ERR] sys: unschedule rotation '%s' failed: %v", path, err)
+		return handleError(err)
+	}
+	return nil, nil
+}
+
+// handleLeaseList is used to list the LeaseIDs under a prefix
+func (b *SystemBackend) handleLeaseList(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	prefix := data.Get("prefix").(string)
+
+	leaseIDs, err := b.Core.expiration.ListLeases(prefix)
+	if err != nil {
+		b.Backend.Logger().Printf("[ERR] sys: lease list '%s' failed: %v", prefix, err)
+		return handleError(err)
+	}
+
+	// Return only the requested page of LeaseIDs
+	limit := data.Get("limit").(int)
+	if limit < 0 {
+		return logical.ErrorResponse("limit must not be negative"), logical.ErrInvalidRequest
+	}
+	page, next := logical.ListPage(leaseIDs, data.Get("next_token").(string), limit)
+	return logical.ListPageResponse(page, next), nil
+}
+
+// handleAuthTable handles the "auth" endpoint to provide the auth table
+func (b *SystemBackend) handleAuthTable(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	b.Core.auth.Lock()
+	defer b.Core.auth.Unlock()
+
+	resp := &logical.Response{
+		Data: make(map[string]interface{}),
+	}
+	for _, entry := range b.Core.auth.Entries {
+		info := map[string]string{
+			"type":        entry.Type,
+			"description": entry.Description,
+		}
+		resp.Data[entry.Path] = info
+	}
+	return resp, nil
+}
+
+// handleEnableAuth is used to enable a new credential backend
+func (b *SystemBackend) handleEnableAuth(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	// Get all the options
+	path := data.Get("path").(string)
+	logicalType := data.Get("type").(string)
+	description := data.Get("description").(string)
+	humanReadable := data.Get("human_readable_prefix").(bool)
+	options := data.Get("options").(map[string]interface{})
+
+	if logicalType == "" {
+		return logical.ErrorResponse(
+				"backend type must be specified as a string"),
+			logical.ErrInvalidRequest
+	}
+
+	optionMap := make(map[string]string)
+	for k, v := range options {
+		vStr, ok := v.(string)
+		if !ok {
+			return logical.ErrorResponse("options must be string valued"),
+				logical.ErrInvalidRequest
+		}
+		optionMap[k] = vStr
+	}
+
+	var config MountConfig
+
+	var apiConfig struct {
+		TokenDefaultTTL string `json:"token_default_ttl" structs:"token_default_ttl" mapstructure:"token_default_ttl"`
+		TokenMaxTTL     string `json:"token_max_ttl" structs:"token_max_ttl" mapstructure:"token_max_ttl"`
+	}
+	configMap := data.Get("config").(map[string]interface{})
+	if configMap != nil && len(configMap) != 0 {
+		err := mapstructure.Decode(configMap, &apiConfig)
+		if err != nil {
+			return logical.ErrorResponse(
+					"unable to convert given auth config information"),
+				logical.ErrInvalidRequest
+		}
+	}
+
+	newDefault, err := parseTuneTTL(apiConfig.TokenDefaultTTL)
+	if err != nil {
+		return logical.ErrorResponse(fmt.Sprintf(
+				"unable to parse token default TTL of %s: %s", apiConfig.TokenDefaultTTL, err)),
+			logical.ErrInvalidRequest
+	}
+	newMax, err := parseTuneTTL(apiConfig.TokenMaxTTL)
+	if err != nil {
+		return logical.ErrorResponse(fmt.Sprintf(
+				"unable to parse token max TTL of %s: %s", apiConfig.TokenMaxTTL, err)),
+			logical.ErrInvalidRequest
+	}
+	if err := b.validateTokenTTLs(&config, newDefault, newMax); err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+	if newDefault != nil {
+		config.TokenDefaultTTL = *newDefault
+	}
+	if newMax != nil {
+		config.TokenMaxTTL = *newMax
+	}
+
+	// Create the mount entry
+	me := &MountEntry{
+		Path:        path,
+		Type:        logicalType,
+		Description: description,
+		Config:      config,
+		Options:     optionMap,
+
+		HumanReadablePrefix: humanReadable,
 	}
 
 	// Attempt enabling
-	if err := b.Core.enableCredential(me); err != nil {
+	warnings, err := b.Core.enableCredential(me)
+	if err != nil {
 		b.Backend.Logger().Printf("[ERR] sys: enable auth %s failed: %v", me.Path, err)
 		return handleError(err)
 	}
+	if len(warnings) == 0 {
+		return nil, nil
+	}
+
+	resp := &logical.Response{}
+	for _, warning := range warnings {
+		resp.AddWarning(warning)
+	}
+	return resp, nil
+}
+
+// handleAuthTuneRead is used to get the token settings of a credential backend
+func (b *SystemBackend) handleAuthTuneRead(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	path := data.Get("path").(string)
+	if path == "" {
+		return logical.ErrorResponse(
+				"path must be specified as a string"),
+			logical.ErrInvalidRequest
+	}
+
+	if !strings.HasSuffix(path, "/") {
+		path += "/"
+	}
+
+	fullPath := credentialRoutePrefix + path
+	sysView := b.Core.router.MatchingSystemView(fullPath)
+	if sysView == nil {
+		err := fmt.Errorf("[ERR] sys: cannot fetch sysview for path %s", fullPath)
+		b.Backend.Logger().Print(err)
+		return handleError(err)
+	}
+
+	def, max := b.Core.tokenTTLs(fullPath, sysView)
+	resp := &logical.Response{
+		Data: map[string]interface{}{
+			"token_default_ttl": int(def.Seconds()),
+			"token_max_ttl":     int(max.Seconds()),
+		},
+	}
+
+	return resp, nil
+}
+
+// handleAuthTuneWrite is used to set the token settings of a credential backend
+func (b *SystemBackend) handleAuthTuneWrite(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	path := data.Get("path").(string)
+	if path == "" {
+		return logical.ErrorResponse(
+				"path must be specified as a string"),
+			logical.ErrInvalidRequest
+	}
+
+	if !strings.HasSuffix(path, "/") {
+		path += "/"
+	}
+
+	b.Core.auth.Lock()
+	defer b.Core.auth.Unlock()
+
+	mountEntry := b.Core.auth.Find(path)
+	if mountEntry == nil {
+		err := fmt.Errorf("[ERR] sys: tune of auth path '%s' failed: no auth entry found", path)
+		b.Backend.Logger().Print(err)
+		return handleError(err)
+	}
+
+	newDefault, err := parseTuneTTL(data.Get("token_default_ttl").(string))
+	if err != nil {
+		return handleError(err)
+	}
+	newMax, err := parseTuneTTL(data.Get("token_max_ttl").(string))
+	if err != nil {
+		return handleError(err)
+	}
+
+	if newDefault != nil || newMax != nil {
+		if err := b.tuneAuthTokenTTLs(path, &mountEntry.Config, newDefault, newMax); err != nil {
+			b.Backend.Logger().Printf("[ERR] sys: tune of auth path '%s' failed: %v", path, err)
+			return handleError(err)
+		}
+	}
+
 	return nil, nil
 }
 
@@ -740,12 +1337,11 @@ func (b *SystemBackend) handleDisableAuth(
 // handlePolicyList handles the "policy" endpoint to provide the enabled policies
 func (b *SystemBackend) handlePolicyList(
 	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	// Get all the configured policies
-	policies, err := b.Core.policyStore.ListPolicies()
-
-	// Add the special "root" policy
-	policies = append(policies, "root")
-	return logical.ListResponse(policies), err
+	policies, err := b.Core.listPolicies()
+	if err != nil {
+		return handleError(err)
+	}
+	return logical.ListResponse(policies), nil
 }
 
 // handlePolicyRead handles the "policy/<name>" endpoint to read a policy
@@ -753,7 +1349,7 @@ func (b *SystemBackend) handlePolicyRead(
 	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
 	name := data.Get("name").(string)
 
-	policy, err := b.Core.policyStore.GetPolicy(name)
+	policy, err := b.Core.getPolicy(name)
 	if err != nil {
 		return handleError(err)
 	}
@@ -776,32 +1372,110 @@ func (b *SystemBackend) handlePolicySet(
 	name := data.Get("name").(string)
 	rules := data.Get("rules").(string)
 
-	// Validate the rules parse
-	parse, err := Parse(rules)
+	if err := b.Core.setPolicy(name, rules); err != nil {
+		return handleError(err)
+	}
+	return nil, nil
+}
+
+// handlePolicyDelete handles the "policy/<name>" endpoint to delete a policy
+func (b *SystemBackend) handlePolicyDelete(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("name").(string)
+	if err := b.Core.deletePolicy(name); err != nil {
+		return handleError(err)
+	}
+	return nil, nil
+}
+
+// handlePasswordPolicyList handles the "password-policies" endpoint to
+// list the password policies
+func (b *SystemBackend) handlePasswordPolicyList(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	policies, err := b.Core.passwordPolicyStore.ListPolicies()
+	if err != nil {
+		return handleError(err)
+	}
+	return logical.ListResponse(policies), nil
+}
+
+// handlePasswordPolicyRead handles the "password-policies/<name>"
+// endpoint to read a password policy
+func (b *SystemBackend) handlePasswordPolicyRead(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("name").(string)
+
+	policy, err := b.Core.passwordPolicyStore.GetPolicy(name)
 	if err != nil {
 		return handleError(err)
 	}
+	if policy == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"name":   policy.Name,
+			"policy": policy.Raw,
+		},
+	}, nil
+}
 
-	// Override the name
-	parse.Name = strings.ToLower(name)
+// handlePasswordPolicySet handles the "password-policies/<name>"
+// endpoint to set a password policy
+func (b *SystemBackend) handlePasswordPolicySet(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("name").(string)
 
-	// Update the policy
-	if err := b.Core.policyStore.SetPolicy(parse); err != nil {
+	policy, err := ParsePasswordPolicy(data.Get("policy").(string))
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+	policy.Name = name
+
+	if err := b.Core.passwordPolicyStore.SetPolicy(policy); err != nil {
 		return handleError(err)
 	}
 	return nil, nil
 }
 
-// handlePolicyDelete handles the "policy/<name>" endpoint to delete a policy
-func (b *SystemBackend) handlePolicyDelete(
+// handlePasswordPolicyDelete handles the "password-policies/<name>"
+// endpoint to delete a password policy
+func (b *SystemBackend) handlePasswordPolicyDelete(
 	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
 	name := data.Get("name").(string)
-	if err := b.Core.policyStore.DeletePolicy(name); err != nil {
+	if err := b.Core.passwordPolicyStore.DeletePolicy(name); err != nil {
 		return handleError(err)
 	}
 	return nil, nil
 }
 
+// handlePasswordPolicyGenerate handles the
+// "password-policies/<name>/generate" endpoint to generate a password
+func (b *SystemBackend) handlePasswordPolicyGenerate(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("name").(string)
+
+	policy, err := b.Core.passwordPolicyStore.GetPolicy(name)
+	if err != nil {
+		return handleError(err)
+	}
+	if policy == nil {
+		return logical.ErrorResponse(fmt.Sprintf("password policy '%s' not found", name)),
+			logical.ErrInvalidRequest
+	}
+
+	password, err := policy.Generate()
+	if err != nil {
+		return handleError(err)
+	}
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"password": password,
+		},
+	}, nil
+}
+
 // handleAuditTable handles the "audit" endpoint to provide the audit table
 func (b *SystemBackend) handleAuditTable(
 	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
@@ -870,6 +1544,32 @@ func (b *SystemBackend) handleDisableAudit(
 	return nil, nil
 }
 
+// handleAuditHash is used to hash a value with the salt of an audit backend
+func (b *SystemBackend) handleAuditHash(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	path := data.Get("path").(string)
+	input := data.Get("input").(string)
+	if input == "" {
+		return logical.ErrorResponse("the \"input\" parameter is empty"), logical.ErrInvalidRequest
+	}
+
+	// Ensure we end the path in a slash
+	if !strings.HasSuffix(path, "/") {
+		path += "/"
+	}
+
+	hash, err := b.Core.auditBroker.GetHash(path, input)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"hash": hash,
+		},
+	}, nil
+}
+
 // handleRawRead is used to read directly from the barrier
 func (b *SystemBackend) handleRawRead(
 	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
@@ -962,28 +1662,9 @@ func (b *SystemBackend) handleKeyStatus(
 // handleRotate is used to trigger a key rotation
 func (b *SystemBackend) handleRotate(
 	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	// Rotate to the new term
-	newTerm, err := b.Core.barrier.Rotate()
-	if err != nil {
-		b.Backend.Logger().Printf("[ERR] sys: failed to create new encryption key: %v", err)
+	if err := b.Core.rotateWithUpgrade(); err != nil {
 		return handleError(err)
 	}
-	b.Backend.Logger().Printf("[INFO] sys: installed new encryption key")
-
-	// In HA mode, we need to an upgrade path for the standby instances
-	if b.Core.ha != nil {
-		// Create the upgrade path to the new term
-		if err := b.Core.barrier.CreateUpgrade(newTerm); err != nil {
-			b.Backend.Logger().Printf("[ERR] sys: failed to create new upgrade for key term %d: %v", newTerm, err)
-		}
-
-		// Schedule the destroy of the upgrade path
-		time.AfterFunc(keyRotateGracePeriod, func() {
-			if err := b.Core.barrier.DestroyUpgrade(newTerm); err != nil {
-				b.Backend.Logger().Printf("[ERR] sys: failed to destroy upgrade for key term %d: %v", newTerm, err)
-			}
-		})
-	}
 	return nil, nil
 }
 
@@ -1041,10 +1722,50 @@ and max_lease_ttl.`,
 		`The max lease TTL for this mount.`,
 	},
 
+	"tune_log_level": {
+		`The log level for requests to this mount, overriding the global
+level. One of "trace", "debug", "info", "warn" or "err", or "system" to
+use the global level.`,
+	},
+
+	"tune_audit_reads": {
+		`Whether read and list operations on this mount are audited,
+overriding the global setting. One of "true", "false", or "system" to use
+the global setting.`,
+	},
+
+	"tune_response_cache_ttl": {
+		`How long responses of read operations on this mount are cached, such
+as "30s". Cached responses are invalidated by writes and deletes of their
+path. "0" or "system" disables caching.`,
+	},
+
+	"tune_preserve_leases_on_seal": {
+		`Whether the leases of this mount are kept when the Vault is sealed
+with revoke_leases_on_seal enabled. One of "true" or "false".`,
+	},
+
+	"recover_mount": {
+		"Re-enable a disabled backend whose data is still retained.",
+		`
+When a retention period is configured, the data of unmounted and disabled
+backends is kept for that period before it is deleted. Within the period,
+the backend can be enabled again at the path it was disabled from, with
+its data intact.
+		`,
+	},
+
+	"recover_mount_path": {
+		`The path the backend was disabled from. Credential backends are
+prefixed with "auth/".`,
+		"",
+	},
+
 	"remount": {
 		"Move the mount point of an already-mounted backend.",
 		`
-Change the mount point of an already-mounted backend.
+Change the mount point of an already-mounted backend. Credential backends
+are remounted by giving both paths with the "auth/" prefix.
 		`,
 	},
 
@@ -1104,6 +1825,70 @@ all matching leases.
 		`,
 	},
 
+	"rotation-schedule": {
+		"Configure the scheduled rotation of a static credential.",
+		`
+A backend path can be scheduled for rotation at a fixed interval. Once
+the interval has elapsed since the last rotation, the active node asks
+the backend to rotate the credential at that path. The backend must
+support the rotate operation for the path, which is checked when the
+path is scheduled. The rotations of a mount are unscheduled when it is
+unmounted or remounted.
+		`,
+	},
+
+	"rotation_path": {
+		`The backend path of the credential to rotate, for example
+"mysql/static-roles/app".`,
+		"",
+	},
+
+	"rotation_interval": {
+		`The interval between rotations, as a duration string such as "24h".`,
+		"",
+	},
+
+	"leases-renew": {
+		"Renew a lease owned by the token",
+		`
+Extends the validity of the lease with the given ID by the given
+increment, and returns the new TTL of the lease. A token may renew
+the leases it created. Renewing the lease of another token requires
+sudo access to this path.
+		`,
+	},
+
+	"leases-lookup": {
+		"Read the metadata of a lease",
+		`
+Returns the path, issue time, remaining TTL and renewability of
+the lease with the given ID. The secret data is never returned.
+		`,
+	},
+
+	"leases-list": {
+		"List the IDs of the leases under a prefix",
+		`
+Lists the lease IDs under the given mount prefix, in sorted order.
+The result can be paginated with the "limit" and "next_token" fields.
+		`,
+	},
+
+	"leases-list-prefix": {
+		`The path to list the leases under. Example: "prod/aws/ops"`,
+		"",
+	},
+
+	"list-limit": {
+		"Maximum number of keys to return.",
+		"",
+	},
+
+	"list-next-token": {
+		"Continuation token returned by a previous list.",
+		"",
+	},
+
 	"revoke-prefix-path": {
 		`The path to revoke keys under. Example: "prod/aws/ops"`,
 		"",
@@ -1141,6 +1926,39 @@ Example: you might have an OAuth backend for GitHub, and one for Google Apps.
 		"",
 	},
 
+	"auth_config": {
+		`Configuration for this credential backend, such as token_default_ttl
+and token_max_ttl.`,
+	},
+
+	"auth_opts": {
+		`Configuration options passed to the credential backend when it is
+created, such as the URL of a remote identity provider.`,
+		"",
+	},
+
+	"auth_human_readable_prefix": {
+		`If true, the backend data is stored under "auth/path/<path>/" rather
+than a generated UUID. Defaults to false.`,
+	},
+
+	"auth_tune": {
+		"Tune the TTLs of tokens issued by this credential backend.",
+		`
+Tune the default and max TTL of tokens issued by logins on this credential
+backend. These override the lease TTLs for the mount, but are always
+limited by the system max lease TTL.
+		`,
+	},
+
+	"tune_token_default_ttl": {
+		`The default TTL of tokens issued by this credential backend.`,
+	},
+
+	"tune_token_max_ttl": {
+		`The max TTL of tokens issued by this credential backend.`,
+	},
+
 	"policy-list": {
 		`List the configured access control policies.`,
 		`
@@ -1167,6 +1985,41 @@ or delete a policy.
 		"",
 	},
 
+	"password-policy-list": {
+		`List the configured password policies.`,
+		`
+List the names of the configured password policies. Password policies describe
+the passwords generated by backends that issue credentials.
+		`,
+	},
+
+	"password-policy": {
+		`Read, Modify, or Delete a password policy.`,
+		`
+Read the rules of an existing password policy, create or update the rules of
+a password policy, or delete a password policy.
+		`,
+	},
+
+	"password-policy-name": {
+		`The name of the password policy, which is case insensitive. Example: "database"`,
+		"",
+	},
+
+	"password-policy-rules": {
+		`The rules of the password policy in HCL or JSON format: the length, and
+the charsets the password is drawn from with the minimum number of characters
+of each.`,
+		"",
+	},
+
+	"password-policy-generate": {
+		`Generate a password from a password policy.`,
+		`
+Generate a password conforming to the named password policy.
+		`,
+	},
+
 	"audit-table": {
 		"List the currently enabled audit backends.",
 		`
@@ -1195,6 +2048,22 @@ a user friendly description of the audit backend, and it's configuration options
 		"",
 	},
 
+	"audit-hash": {
+		"Hash a value with the salt of an audit backend.",
+		`
+Hash the given input with the salt of the audit backend mounted at the given
+path, as the backend hashes sensitive values in its log. This allows finding
+a known value, such as a token, in the log. The salt is stored in the barrier,
+so the hash is stable across restarts but differs between backends and
+between Vaults.
+		`,
+	},
+
+	"audit_hash_input": {
+		`The value to hash.`,
+		"",
+	},
+
 	"audit": {
 		`Enable or disable audit backends.`,
 		`
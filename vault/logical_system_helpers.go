@@ -3,6 +3,8 @@ package vault
 import (
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -39,9 +41,9 @@ func (b *SystemBackend) tuneMountTTLs(path string, meConfig *MountConfig, newDef
 
 	if newDefault != nil {
 		if meConfig.MaxLeaseTTL == 0 {
-			if newMax == nil && *newDefault > b.Core.maxLeaseTTL {
+			if _, sysMax := b.Core.leaseTTLs(); newMax == nil && *newDefault > sysMax {
 				return fmt.Errorf("new backend default lease TTL of %d greater than system max lease TTL of %d",
-					int(newDefault.Seconds()), int(b.Core.maxLeaseTTL.Seconds()))
+					int(newDefault.Seconds()), int(sysMax.Seconds()))
 			}
 		} else {
 			if meConfig.MaxLeaseTTL < *newDefault {
@@ -67,3 +69,172 @@ func (b *SystemBackend) tuneMountTTLs(path string, meConfig *MountConfig, newDef
 
 	return nil
 }
+
+// tuneMountLogLevel is used to set the request log level of a mount. The
+// level "system" clears the override.
+func (b *SystemBackend) tuneMountLogLevel(path string, meConfig *MountConfig, level string) error {
+	level = strings.ToLower(level)
+	switch {
+	case level == "system":
+		level = ""
+	case logLevelIndex(level) < 0:
+		return fmt.Errorf("unknown log level '%s'", level)
+	}
+	if level == meConfig.LogLevel {
+		return nil
+	}
+
+	meConfig.LogLevel = level
+	if err := b.Core.persistMounts(b.Core.mounts); err != nil {
+		return errors.New("failed to update mount table")
+	}
+
+	b.Core.logger.Printf("[INFO] core: tuned log level of '%s'", path)
+	return nil
+}
+
+// tuneMountAuditReads is used to set whether reads on a mount are
+// audited. The value "system" restores the global setting.
+func (b *SystemBackend) tuneMountAuditReads(path string, meConfig *MountConfig, value string) error {
+	var auditReads *bool
+	if value != "system" {
+		v, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid value '%s' for audit_reads", value)
+		}
+		auditReads = &v
+	}
+
+	meConfig.AuditReads = auditReads
+	if err := b.Core.persistMounts(b.Core.mounts); err != nil {
+		return errors.New("failed to update mount table")
+	}
+
+	b.Core.logger.Printf("[INFO] core: tuned read auditing of '%s'", path)
+	return nil
+}
+
+// tuneMountPreserveLeases is used to set whether the leases of a mount
+// are exempt from revocation when sealing
+func (b *SystemBackend) tuneMountPreserveLeases(path string, meConfig *MountConfig, value string) error {
+	preserve, err := strconv.ParseBool(value)
+	if err != nil {
+		return fmt.Errorf("invalid value '%s' for preserve_leases_on_seal", value)
+	}
+
+	// Credential mounts are persisted with the auth table
+	if strings.HasPrefix(path, credentialRoutePrefix) {
+		b.Core.auth.Lock()
+		defer b.Core.auth.Unlock()
+
+		meConfig.PreserveLeasesOnSeal = preserve
+		if err := b.Core.persistAuth(b.Core.auth); err != nil {
+			return errors.New("failed to update auth table")
+		}
+	} else {
+		meConfig.PreserveLeasesOnSeal = preserve
+		if err := b.Core.persistMounts(b.Core.mounts); err != nil {
+			return errors.New("failed to update mount table")
+		}
+	}
+
+	b.Core.logger.Printf("[INFO] core: tuned lease preservation on seal of '%s' to %v", path, preserve)
+	return nil
+}
+
+// tuneMountResponseCacheTTL is used to set how long responses of reads on
+// a mount are cached. The value "system" or a zero duration disables it.
+func (b *SystemBackend) tuneMountResponseCacheTTL(path string, meConfig *MountConfig, value string) error {
+	var ttl time.Duration
+	if value != "system" {
+		var err error
+		ttl, err = time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid value '%s' for response_cache_ttl: %v", value, err)
+		}
+		if ttl < 0 {
+			return fmt.Errorf("response_cache_ttl must not be negative")
+		}
+	}
+
+	meConfig.ResponseCacheTTL = ttl
+	if err := b.Core.persistMounts(b.Core.mounts); err != nil {
+		return errors.New("failed to update mount table")
+	}
+
+	// Responses cached with the old TTL are dropped
+	if err := b.Core.router.ClearResponseCache(path); err != nil {
+		return err
+	}
+
+	b.Core.logger.Printf("[INFO] core: tuned response cache TTL of '%s' to %s", path, ttl)
+	return nil
+}
+
+// parseTuneTTL parses a tunable TTL value. An empty value is returned
+// as nil, meaning unchanged, while "system" resets it to the default.
+func parseTuneTTL(raw string) (*time.Duration, error) {
+	switch raw {
+	case "":
+		return nil, nil
+	case "system":
+		ttl := time.Duration(0)
+		return &ttl, nil
+	default:
+		ttl, err := time.ParseDuration(raw)
+		if err != nil {
+			return nil, err
+		}
+		if ttl < 0 {
+			return nil, fmt.Errorf("TTL must be positive")
+		}
+		return &ttl, nil
+	}
+}
+
+// validateTokenTTLs checks the new token TTLs of a credential
+// mount against its current config and the system max lease TTL
+func (b *SystemBackend) validateTokenTTLs(meConfig *MountConfig, newDefault, newMax *time.Duration) error {
+	def := meConfig.TokenDefaultTTL
+	if newDefault != nil {
+		def = *newDefault
+	}
+	max := meConfig.TokenMaxTTL
+	if newMax != nil {
+		max = *newMax
+	}
+
+	if max != 0 && def > max {
+		return fmt.Errorf("token default TTL of %d greater than token max TTL of %d",
+			int(def.Seconds()), int(max.Seconds()))
+	}
+	if _, sysMax := b.Core.leaseTTLs(); def > sysMax {
+		return fmt.Errorf("token default TTL of %d greater than system max lease TTL of %d",
+			int(def.Seconds()), int(sysMax.Seconds()))
+	}
+	return nil
+}
+
+// tuneAuthTokenTTLs is used to set the token TTLs on a credential mount.
+// The auth table lock must be held.
+func (b *SystemBackend) tuneAuthTokenTTLs(path string, meConfig *MountConfig, newDefault, newMax *time.Duration) error {
+	if err := b.validateTokenTTLs(meConfig, newDefault, newMax); err != nil {
+		return err
+	}
+
+	if newMax != nil {
+		meConfig.TokenMaxTTL = *newMax
+	}
+	if newDefault != nil {
+		meConfig.TokenDefaultTTL = *newDefault
+	}
+
+	// Update the auth table
+	if err := b.Core.persistAuth(b.Core.auth); err != nil {
+		return fmt.Errorf("failed to update auth table: %v", err)
+	}
+
+	b.Core.logger.Printf("[INFO] core: tuned auth '%s'", path)
+
+	return nil
+}
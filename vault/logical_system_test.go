@@ -2,6 +2,7 @@ package vault
 
 import (
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -18,11 +19,15 @@ func TestSystemBackend_RootPaths(t *testing.T) {
 		"revoke-prefix/*",
 		"policy",
 		"policy/*",
+		"password-policies",
+		"password-policies/*",
 		"audit",
 		"audit/*",
 		"seal",
 		"raw/*",
 		"rotate",
+		"rotation-schedule/*",
+		"recover-mount",
 	}
 
 	b := testSystemBackend(t)
@@ -339,6 +344,195 @@ func TestSystemBackend_revokePrefix(t *testing.T) {
 	}
 }
 
+func TestSystemBackend_rotationSchedule(t *testing.T) {
+	c, b, _ := testCoreSystemBackend(t)
+	c.logicalBackends["rotating"] = func(*logical.BackendConfig) (logical.Backend, error) {
+		return &rotatingBackend{}, nil
+	}
+	if err := c.mount(&MountEntry{Path: "db/", Type: "rotating"}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The path must belong to a mount
+	req := logical.TestRequest(t, logical.WriteOperation, "rotation-schedule/foo/bar")
+	req.Data["interval"] = "1h"
+	resp, err := b.HandleRequest(req)
+	if err != logical.ErrInvalidRequest {
+		t.Fatalf("err: %v %v", err, resp)
+	}
+
+	// The backend must support rotation
+	req = logical.TestRequest(t, logical.WriteOperation, "rotation-schedule/secret/foo")
+	req.Data["interval"] = "1h"
+	resp, err = b.HandleRequest(req)
+	if err != logical.ErrInvalidRequest {
+		t.Fatalf("err: %v %v", err, resp)
+	}
+
+	req = logical.TestRequest(t, logical.WriteOperation, "rotation-schedule/db/foo")
+	req.Data["interval"] = "bad"
+	resp, err = b.HandleRequest(req)
+	if err != logical.ErrInvalidRequest {
+		t.Fatalf("err: %v %v", err, resp)
+	}
+
+	req.Data["interval"] = "1h"
+	resp, err = b.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v %v", err, resp)
+	}
+
+	req = logical.TestRequest(t, logical.ReadOperation, "rotation-schedule/db/foo")
+	resp, err = b.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if resp == nil || resp.Data["interval"] != int64(3600) || resp.Data["path"] != "db/foo" {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	req = logical.TestRequest(t, logical.DeleteOperation, "rotation-schedule/db/foo")
+	if _, err := b.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	req = logical.TestRequest(t, logical.ReadOperation, "rotation-schedule/db/foo")
+	resp, err = b.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if resp != nil {
+		t.Fatalf("bad: %#v", resp)
+	}
+}
+
+func TestSystemBackend_leases(t *testing.T) {
+	core, b, root := testCoreSystemBackend(t)
+
+	// Create a key with a lease
+	req := logical.TestRequest(t, logical.WriteOperation, "secret/foo")
+	req.Data["foo"] = "bar"
+	req.Data["lease"] = "1h"
+	req.ClientToken = root
+	resp, err := core.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if resp != nil {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	// Read a key with a LeaseID
+	req = logical.TestRequest(t, logical.ReadOperation, "secret/foo")
+	req.ClientToken = root
+	resp, err = core.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if resp == nil || resp.Secret == nil || resp.Secret.LeaseID == "" {
+		t.Fatalf("bad: %#v", resp)
+	}
+	leaseID := resp.Secret.LeaseID
+
+	// Lookup should not include the secret data
+	req = logical.TestRequest(t, logical.ReadOperation, "leases/lookup/"+leaseID)
+	resp, err = b.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if resp.Data["lease_id"] != leaseID || resp.Data["path"] != "secret/foo" {
+		t.Fatalf("bad: %#v", resp)
+	}
+	if _, ok := resp.Data["foo"]; ok {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	// List should return the lease
+	req = logical.TestRequest(t, logical.ListOperation, "leases/list/secret/")
+	resp, err = b.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !reflect.DeepEqual(resp.Data["keys"], []string{leaseID}) {
+		t.Fatalf("bad: %#v", resp)
+	}
+}
+
+func TestSystemBackend_leaseRenew(t *testing.T) {
+	core, _, root := testCoreSystemBackend(t)
+
+	policy, err := Parse(`
+path "secret/*" {
+	policy = "write"
+}
+path "sys/leases/renew/*" {
+	policy = "write"
+}
+`)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	policy.Name = "renewer"
+	if err := core.policyStore.SetPolicy(policy); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	var tokens []string
+	for i := 0; i < 2; i++ {
+		te := &TokenEntry{Path: "test", Policies: []string{"renewer"}}
+		if err := core.tokenStore.create(te); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		tokens = append(tokens, te.ID)
+	}
+	owner, other := tokens[0], tokens[1]
+
+	// Create a key with a renewable lease
+	req := logical.TestRequest(t, logical.WriteOperation, "secret/foo")
+	req.Data["foo"] = "bar"
+	req.Data["lease"] = "1h"
+	req.ClientToken = owner
+	if _, err := core.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	req = logical.TestRequest(t, logical.ReadOperation, "secret/foo")
+	req.ClientToken = owner
+	resp, err := core.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if resp == nil || resp.Secret == nil || resp.Secret.LeaseID == "" {
+		t.Fatalf("bad: %#v", resp)
+	}
+	leaseID := resp.Secret.LeaseID
+
+	// The owner renews and gets the new TTL
+	req = logical.TestRequest(t, logical.WriteOperation, "sys/leases/renew/"+leaseID)
+	req.Data["increment"] = "2h"
+	req.ClientToken = owner
+	resp, err = core.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if resp.Data["lease_id"] != leaseID || resp.Data["ttl"] != int64(3600) || resp.Data["renewable"] != true {
+		t.Fatalf("bad: %#v", resp)
+	}
+	if resp.Secret != nil || resp.Data["foo"] != nil {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	// Another token without sudo access is denied
+	req.ClientToken = other
+	if _, err := core.HandleRequest(req); err != logical.ErrPermissionDenied {
+		t.Fatalf("err: %v", err)
+	}
+
+	// A token with sudo access may renew any lease
+	req.ClientToken = root
+	if _, err := core.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
+
 func TestSystemBackend_authTable(t *testing.T) {
 	b := testSystemBackend(t)
 	req := logical.TestRequest(t, logical.ReadOperation, "auth")
@@ -376,6 +570,180 @@ func TestSystemBackend_enableAuth(t *testing.T) {
 	}
 }
 
+func TestSystemBackend_enableAuth_warnings(t *testing.T) {
+	c, b, _ := testCoreSystemBackend(t)
+	c.credentialBackends["warning"] = func(*logical.BackendConfig) (logical.Backend, error) {
+		return &warningBackend{}, nil
+	}
+
+	req := logical.TestRequest(t, logical.WriteOperation, "auth/foo")
+	req.Data["type"] = "warning"
+
+	resp, err := b.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if resp == nil || !reflect.DeepEqual(resp.Warnings(), []string{"no password policy is configured"}) {
+		t.Fatalf("bad: %#v", resp)
+	}
+}
+
+func TestSystemBackend_authTune(t *testing.T) {
+	c, b, _ := testCoreSystemBackend(t)
+	c.credentialBackends["noop"] = func(*logical.BackendConfig) (logical.Backend, error) {
+		return &NoopBackend{}, nil
+	}
+
+	req := logical.TestRequest(t, logical.WriteOperation, "auth/foo")
+	req.Data["type"] = "noop"
+	if _, err := b.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	req = logical.TestRequest(t, logical.WriteOperation, "auth/foo/tune")
+	req.Data["token_default_ttl"] = "1h"
+	req.Data["token_max_ttl"] = "2h"
+	if _, err := b.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	req = logical.TestRequest(t, logical.ReadOperation, "auth/foo/tune")
+	resp, err := b.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	exp := map[string]interface{}{
+		"token_default_ttl": 3600,
+		"token_max_ttl":     7200,
+	}
+	if !reflect.DeepEqual(resp.Data, exp) {
+		t.Fatalf("got: %#v expect: %#v", resp.Data, exp)
+	}
+
+	// Default greater than max is rejected
+	req = logical.TestRequest(t, logical.WriteOperation, "auth/foo/tune")
+	req.Data["token_default_ttl"] = "3h"
+	if _, err := b.HandleRequest(req); err != logical.ErrInvalidRequest {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestSystemBackend_mountTune_logLevel(t *testing.T) {
+	b := testSystemBackend(t)
+
+	req := logical.TestRequest(t, logical.WriteOperation, "mounts/secret/tune")
+	req.Data["log_level"] = "DEBUG"
+	if _, err := b.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	req = logical.TestRequest(t, logical.ReadOperation, "mounts/secret/tune")
+	resp, err := b.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if resp.Data["log_level"] != "debug" {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	// Unknown levels are rejected
+	req = logical.TestRequest(t, logical.WriteOperation, "mounts/secret/tune")
+	req.Data["log_level"] = "verbose"
+	if _, err := b.HandleRequest(req); err != logical.ErrInvalidRequest {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The global level is restored with "system"
+	req = logical.TestRequest(t, logical.WriteOperation, "mounts/secret/tune")
+	req.Data["log_level"] = "system"
+	if _, err := b.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	req = logical.TestRequest(t, logical.ReadOperation, "mounts/secret/tune")
+	resp, err = b.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, ok := resp.Data["log_level"]; ok {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+}
+
+func TestSystemBackend_passwordPolicies(t *testing.T) {
+	b := testSystemBackend(t)
+
+	req := logical.TestRequest(t, logical.WriteOperation, "password-policies/database")
+	req.Data["policy"] = testPasswordPolicy
+	if _, err := b.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	req = logical.TestRequest(t, logical.ReadOperation, "password-policies/database")
+	resp, err := b.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if resp.Data["policy"] != testPasswordPolicy {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	req = logical.TestRequest(t, logical.ReadOperation, "password-policies/database/generate")
+	resp, err = b.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	p, _ := ParsePasswordPolicy(testPasswordPolicy)
+	if err := p.Check(resp.Data["password"].(string)); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	req = logical.TestRequest(t, logical.ReadOperation, "password-policies")
+	resp, err = b.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !reflect.DeepEqual(resp.Data["keys"], []string{"database"}) {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	// Invalid policies are rejected
+	req = logical.TestRequest(t, logical.WriteOperation, "password-policies/bad")
+	req.Data["policy"] = "length = 0"
+	if _, err := b.HandleRequest(req); err != logical.ErrInvalidRequest {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Names are case insensitive
+	req = logical.TestRequest(t, logical.ReadOperation, "password-policies/Database")
+	resp, err = b.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if resp == nil || resp.Data["name"] != "database" {
+		t.Fatalf("bad: %#v", resp)
+	}
+	req = logical.TestRequest(t, logical.ReadOperation, "password-policies/DATABASE/generate")
+	if _, err := b.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Names must be plain identifiers
+	req = logical.TestRequest(t, logical.WriteOperation, "password-policies/foo bar")
+	req.Data["policy"] = testPasswordPolicy
+	if _, err := b.HandleRequest(req); err == nil {
+		t.Fatalf("expected error")
+	}
+
+	req = logical.TestRequest(t, logical.DeleteOperation, "password-policies/Database")
+	if _, err := b.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	req = logical.TestRequest(t, logical.ReadOperation, "password-policies/database/generate")
+	if _, err := b.HandleRequest(req); err != logical.ErrInvalidRequest {
+		t.Fatalf("err: %v", err)
+	}
+}
+
 func TestSystemBackend_enableAuth_invalid(t *testing.T) {
 	b := testSystemBackend(t)
 	req := logical.TestRequest(t, logical.WriteOperation, "auth/foo")
@@ -416,7 +784,8 @@ func TestSystemBackend_disableAuth_invalid(t *testing.T) {
 
 	req := logical.TestRequest(t, logical.DeleteOperation, "auth/foo")
 	resp, err := b.HandleRequest(req)
-	if err != logical.ErrInvalidRequest {
+	verr, ok := err.(*logical.VaultError)
+	if !ok || verr.Code != logical.ErrCodeInvalidRequest || verr.HTTPStatus != 400 {
 		t.Fatalf("err: %v", err)
 	}
 	if resp.Data["error"] != "no matching backend" {
@@ -595,6 +964,64 @@ func TestSystemBackend_auditTable(t *testing.T) {
 	}
 }
 
+func TestSystemBackend_auditHash(t *testing.T) {
+	c, key, root := TestCoreUnsealed(t)
+	c.auditBackends["noop"] = func(config *audit.BackendConfig) (audit.Backend, error) {
+		return &NoopAudit{
+			Config: config,
+		}, nil
+	}
+	for _, path := range []string{"foo", "bar"} {
+		req := logical.TestRequest(t, logical.WriteOperation, "sys/audit/"+path)
+		req.Data["type"] = "noop"
+		req.ClientToken = root
+		if _, err := c.HandleRequest(req); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+
+	hash := func(path string) string {
+		req := logical.TestRequest(t, logical.WriteOperation, "sys/audit-hash/"+path)
+		req.Data["input"] = "bar"
+		req.ClientToken = root
+		resp, err := c.HandleRequest(req)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		return resp.Data["hash"].(string)
+	}
+
+	expect := hash("foo")
+	if expect == "" || expect == "bar" || !strings.HasPrefix(expect, "hmac-sha256:") {
+		t.Fatalf("bad: %s", expect)
+	}
+
+	// Every backend has its own salt
+	if other := hash("bar"); other == expect {
+		t.Fatalf("bad: %s", other)
+	}
+
+	// The salt is kept across a seal and unseal
+	if err := c.Seal(root); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if unseal, err := c.Unseal(TestKeyCopy(key)); err != nil || !unseal {
+		t.Fatalf("err: %v", err)
+	}
+	if actual := hash("foo"); actual != expect {
+		t.Fatalf("bad: %s %s", actual, expect)
+	}
+
+	// Unknown backends are rejected
+	req := logical.TestRequest(t, logical.WriteOperation, "sys/audit-hash/baz")
+	req.Data["input"] = "bar"
+	req.ClientToken = root
+	resp, err := c.HandleRequest(req)
+	if err != logical.ErrInvalidRequest {
+		t.Fatalf("err: %v %v", err, resp)
+	}
+}
+
 func TestSystemBackend_disableAudit(t *testing.T) {
 	c, b, _ := testCoreSystemBackend(t)
 	c.auditBackends["noop"] = func(config *audit.BackendConfig) (audit.Backend, error) {
@@ -802,3 +1229,30 @@ func testCoreSystemBackend(t *testing.T) (*Core, logical.Backend, string) {
 	}
 	return c, NewSystemBackend(c, bc), root
 }
+
+func TestSystemBackend_remount_auth(t *testing.T) {
+	c, b, _ := testCoreSystemBackend(t)
+	c.credentialBackends["noop"] = func(*logical.BackendConfig) (logical.Backend, error) {
+		return &NoopBackend{}, nil
+	}
+	if _, err := c.enableCredential(&MountEntry{Path: "foo", Type: "noop"}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	req := logical.TestRequest(t, logical.WriteOperation, "remount")
+	req.Data["from"] = "auth/foo"
+	req.Data["to"] = "bar"
+	resp, err := b.HandleRequest(req)
+	if err != logical.ErrInvalidRequest {
+		t.Fatalf("err: %v %#v", err, resp)
+	}
+
+	req.Data["to"] = "auth/bar"
+	resp, err = b.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v %#v", err, resp)
+	}
+	if match := c.router.MatchingMount("auth/bar/baz"); match != "auth/bar/" {
+		t.Fatalf("missing mount")
+	}
+}
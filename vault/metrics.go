@@ -0,0 +1,27 @@
+package vault
+
+// MetricsSnapshot returns the current value of every counter and gauge
+// held by the in-memory metrics sink, keyed by the flattened metric
+// name. Counters are summed over the intervals retained by the sink, so
+// they are totals over that window rather than since startup, and
+// gauges have their most recently set value within it. Without a sink
+// configured, the snapshot is empty.
+func (c *Core) MetricsSnapshot() map[string]float64 {
+	snapshot := make(map[string]float64)
+	if c.metricsSink == nil {
+		return snapshot
+	}
+
+	// Intervals are ordered from oldest to newest
+	for _, intv := range c.metricsSink.Data() {
+		intv.RLock()
+		for key, agg := range intv.Counters {
+			snapshot[key] += agg.Sum
+		}
+		for key, val := range intv.Gauges {
+			snapshot[key] = float64(val)
+		}
+		intv.RUnlock()
+	}
+	return snapshot
+}
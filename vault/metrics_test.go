@@ -0,0 +1,73 @@
+package vault
+
+import (
+	"testing"
+	"time"
+
+	"github.com/armon/go-metrics"
+	"github.com/hashicorp/vault/physical"
+)
+
+func TestCore_MetricsSnapshot(t *testing.T) {
+	// Without a sink the snapshot is empty
+	c := TestCore(t)
+	if snapshot := c.MetricsSnapshot(); len(snapshot) != 0 {
+		t.Fatalf("bad: %v", snapshot)
+	}
+
+	sink := metrics.NewInmemSink(10*time.Second, time.Minute)
+	c, err := NewCore(&CoreConfig{
+		Physical:     physical.NewInmem(),
+		DisableMlock: true,
+		MetricsSink:  sink,
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	sink.IncrCounter([]string{"vault", "test", "counter"}, 1)
+	sink.IncrCounter([]string{"vault", "test", "counter"}, 2)
+	sink.SetGauge([]string{"vault", "test", "gauge"}, 2)
+
+	snapshot := c.MetricsSnapshot()
+	if snapshot["vault.test.counter"] != 3 {
+		t.Fatalf("bad: %v", snapshot)
+	}
+	if snapshot["vault.test.gauge"] != 2 {
+		t.Fatalf("bad: %v", snapshot)
+	}
+
+	// The gauge reflects the latest value
+	sink.SetGauge([]string{"vault", "test", "gauge"}, 1)
+	if snapshot := c.MetricsSnapshot(); snapshot["vault.test.gauge"] != 1 {
+		t.Fatalf("bad: %v", snapshot)
+	}
+}
+
+func TestCore_MetricsSnapshot_Window(t *testing.T) {
+	// The sink retains two intervals
+	sink := metrics.NewInmemSink(10*time.Millisecond, 20*time.Millisecond)
+	c, err := NewCore(&CoreConfig{
+		Physical:     physical.NewInmem(),
+		DisableMlock: true,
+		MetricsSink:  sink,
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	sink.IncrCounter([]string{"vault", "test", "counter"}, 1)
+	if snapshot := c.MetricsSnapshot(); snapshot["vault.test.counter"] != 1 {
+		t.Fatalf("bad: %v", snapshot)
+	}
+
+	// Counters are summed over the retained intervals only, so the
+	// count drops out once its interval is no longer retained
+	for i := 0; i < 3; i++ {
+		time.Sleep(15 * time.Millisecond)
+		c.MetricsSnapshot()
+	}
+	if snapshot := c.MetricsSnapshot(); snapshot["vault.test.counter"] != 0 {
+		t.Fatalf("bad: %v", snapshot)
+	}
+}
@@ -0,0 +1,223 @@
+package vault
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/vault/helper/mfa/totp"
+	"github.com/hashicorp/vault/logical"
+)
+
+const (
+	// mfaSubPath is the sub-path used for the MFA enrollment store view.
+	// This is nested under the system view.
+	mfaSubPath = "mfa/"
+
+	// MFAMethodTOTP is the MFA method checking a time-based one-time
+	// password generated from the secret enrolled for the identity
+	MFAMethodTOTP = "totp"
+)
+
+// mfaMethods are the MFA methods policies can require
+var mfaMethods = map[string]struct{}{
+	MFAMethodTOTP: struct{}{},
+}
+
+// MFAStore is used to provide durable storage of the MFA secrets
+// enrolled for login identities. Enrollments are keyed by the method and
+// the salted identity, so they outlive the tokens of the identity and
+// are shared by their child tokens.
+type MFAStore struct {
+	view *BarrierView
+
+	// l serializes enrollments and the checks of passcodes, so that the
+	// time step of accepted passcodes only moves forward
+	l sync.Mutex
+}
+
+// mfaEnrollment is used to store the MFA secret of an identity
+type mfaEnrollment struct {
+	Secret string
+
+	// LastCounter is the time step of the latest accepted passcode.
+	// Passcodes of earlier steps are replays.
+	LastCounter uint64
+}
+
+// NewMFAStore creates a new MFAStore that is backed using a given view
+func NewMFAStore(view *BarrierView) *MFAStore {
+	return &MFAStore{
+		view: view,
+	}
+}
+
+// setupMFAStore is used to initialize the MFA store when the vault is
+// being unsealed.
+func (c *Core) setupMFAStore() error {
+	view := c.systemBarrierView.SubView(mfaSubPath)
+	c.mfaStore = NewMFAStore(view)
+	return nil
+}
+
+// teardownMFAStore is used to reverse setupMFAStore when the vault is
+// being sealed.
+func (c *Core) teardownMFAStore() error {
+	c.mfaStore = nil
+	return nil
+}
+
+// Enroll is used to store the secret of the given method for an
+// identity, replacing any previous secret
+func (ms *MFAStore) Enroll(method, saltedID, secret string) error {
+	ms.l.Lock()
+	defer ms.l.Unlock()
+
+	return ms.persist(method, saltedID, &mfaEnrollment{
+		Secret: secret,
+	})
+}
+
+// Validate is used to check a passcode of the given method for an identity.
+// A passcode is accepted for any number of requests within its time step.
+// Once a passcode of a later step is accepted, passcodes of earlier steps
+// are replays and are rejected, including with another token of the
+// identity. The step is only persisted when it moves forward. Identities
+// that are not enrolled are never valid.
+func (ms *MFAStore) Validate(method, saltedID, passcode string, now time.Time) (bool, error) {
+	ms.l.Lock()
+	defer ms.l.Unlock()
+
+	entry, err := ms.enrollment(method, saltedID)
+	if err != nil {
+		return false, err
+	}
+	if entry == nil {
+		return false, nil
+	}
+
+	switch method {
+	case MFAMethodTOTP:
+		counter, ok, err := totp.ValidateCounter(passcode, entry.Secret, now)
+		if err != nil {
+			return false, err
+		}
+		if !ok || counter < entry.LastCounter {
+			return false, nil
+		}
+		if counter == entry.LastCounter {
+			return true, nil
+		}
+		entry.LastCounter = counter
+	default:
+		return false, nil
+	}
+
+	if err := ms.persist(method, saltedID, entry); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// enrollment is used to fetch the enrollment of the given method for an
+// identity. It is nil if the identity is not enrolled.
+func (ms *MFAStore) enrollment(method, saltedID string) (*mfaEnrollment, error) {
+	out, err := ms.view.Get(method + "/" + saltedID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read MFA enrollment: %v", err)
+	}
+	if out == nil {
+		return nil, nil
+	}
+
+	var entry mfaEnrollment
+	if err := out.DecodeJSON(&entry); err != nil {
+		return nil, fmt.Errorf("failed to decode MFA enrollment: %v", err)
+	}
+	return &entry, nil
+}
+
+// persist is used to store the enrollment of the given method for an identity
+func (ms *MFAStore) persist(method, saltedID string, entry *mfaEnrollment) error {
+	out, err := logical.StorageEntryJSON(method+"/"+saltedID, entry)
+	if err != nil {
+		return fmt.Errorf("failed to create entry: %v", err)
+	}
+	if err := ms.view.Put(out); err != nil {
+		return fmt.Errorf("failed to persist MFA enrollment: %v", err)
+	}
+	return nil
+}
+
+// EnrollMFA is used by an operator to enroll the identity of a token in
+// the given MFA method. A new secret is generated and returned, replacing
+// any previous enrollment. Every token of the identity, including child
+// tokens, then requires passcodes generated from the secret.
+func (c *Core) EnrollMFA(token, method string) (string, error) {
+	c.stateLock.RLock()
+	defer c.stateLock.RUnlock()
+	if c.sealed {
+		return "", ErrSealed
+	}
+	if c.standby {
+		return "", ErrStandby
+	}
+
+	te, err := c.tokenStore.Lookup(token)
+	if err != nil {
+		return "", err
+	}
+	if te == nil {
+		return "", fmt.Errorf("token not found")
+	}
+	return c.enrollMFA(te, method)
+}
+
+// enrollMFA is used to enroll the identity of a token in the given MFA
+// method, returning the new secret. The stateLock must be held.
+func (c *Core) enrollMFA(te *TokenEntry, method string) (string, error) {
+	if _, ok := mfaMethods[method]; !ok {
+		return "", fmt.Errorf("unknown MFA method '%s'", method)
+	}
+	if c.mfaStore == nil {
+		return "", ErrSealed
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return "", err
+	}
+	err = c.mfaStore.Enroll(method, c.tokenStore.SaltID(te.mfaIdentity()), secret)
+	if err != nil {
+		c.logger.Printf("[ERR] core: failed to enroll identity in MFA: %v", err)
+		return "", err
+	}
+	return secret, nil
+}
+
+// checkMFA is used to verify the MFA payload of a request satisfies
+// every MFA method required by the policies of the path
+func (c *Core) checkMFA(path string, payload map[string]string, te *TokenEntry, acl *ACL) error {
+	methods := acl.MFAMethods(path)
+	if len(methods) == 0 {
+		return nil
+	}
+
+	saltedID := c.tokenStore.SaltID(te.mfaIdentity())
+	for _, method := range methods {
+		passcode := payload[method]
+		if passcode == "" {
+			return logical.ErrPermissionDenied
+		}
+
+		ok, err := c.mfaStore.Validate(method, saltedID, passcode, time.Now())
+		if err != nil {
+			c.logger.Printf("[ERR] core: failed to validate MFA passcode: %v", err)
+			return ErrInternalError
+		}
+		if !ok {
+			return logical.ErrPermissionDenied
+		}
+	}
+	return nil
+}
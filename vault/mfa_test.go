@@ -0,0 +1,223 @@
+package vault
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/helper/mfa/totp"
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestCore_HandleRequest_MFA(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+	testCoreMakeToken(t, c, root, "child", []string{"test"})
+
+	// Set the 'test' policy object to require TOTP for secret/prod/
+	req := &logical.Request{
+		Operation: logical.WriteOperation,
+		Path:      "sys/policy/test",
+		Data: map[string]interface{}{
+			"rules": `
+path "secret/*" {
+	policy = "write"
+}
+path "secret/prod/*" {
+	policy = "write"
+	mfa_methods = ["totp"]
+}
+`,
+		},
+		ClientToken: root,
+	}
+	if _, err := c.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	write := func(path string, payload map[string]string) error {
+		req := &logical.Request{
+			Operation: logical.WriteOperation,
+			Path:      path,
+			Data: map[string]interface{}{
+				"foo": "bar",
+			},
+			ClientToken: "child",
+			MFAPayload:  payload,
+		}
+		_, err := c.HandleRequest(req)
+		return err
+	}
+
+	// Paths without MFA are not affected
+	if err := write("secret/dev/foo", nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Denied until the token is enrolled
+	if err := write("secret/prod/foo", map[string]string{"totp": "123456"}); err != logical.ErrPermissionDenied {
+		t.Fatalf("err: %v", err)
+	}
+
+	secret, err := c.EnrollMFA("child", MFAMethodTOTP)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	now := time.Now()
+	passcode, err := totp.GenerateCode(secret, now)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	earlier, err := totp.GenerateCode(secret, now.Add(-totp.Period*time.Second))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	wrong := "000000"
+	if passcode == wrong {
+		wrong = "111111"
+	}
+
+	// Missing or incorrect passcodes are denied
+	if err := write("secret/prod/foo", nil); err != logical.ErrPermissionDenied {
+		t.Fatalf("err: %v", err)
+	}
+	if err := write("secret/prod/foo", map[string]string{"totp": wrong}); err != logical.ErrPermissionDenied {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The correct passcode is allowed
+	if err := write("secret/prod/foo", map[string]string{"totp": passcode}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The passcode is allowed for every request within its time step
+	for i := 0; i < 2; i++ {
+		req := &logical.Request{
+			Operation:   logical.ReadOperation,
+			Path:        "secret/prod/foo",
+			ClientToken: "child",
+			MFAPayload:  map[string]string{"totp": passcode},
+		}
+		if _, err := c.HandleRequest(req); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+
+	// But the passcode of an earlier time step is a replay
+	if err := write("secret/prod/foo", map[string]string{"totp": earlier}); err != logical.ErrPermissionDenied {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Root is never required to use MFA
+	req = &logical.Request{
+		Operation:   logical.ReadOperation,
+		Path:        "secret/prod/foo",
+		ClientToken: root,
+	}
+	if _, err := c.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestCore_EnrollMFA_Invalid(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+	if _, err := c.EnrollMFA(root, "sms"); err == nil {
+		t.Fatalf("expected error")
+	}
+	if _, err := c.EnrollMFA("nope", MFAMethodTOTP); err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+func TestCore_HandleRequest_MFAEnroll(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+	testCoreMakeToken(t, c, root, "child", []string{"test"})
+
+	req := &logical.Request{
+		Operation: logical.WriteOperation,
+		Path:      "sys/policy/test",
+		Data: map[string]interface{}{
+			"rules": `
+path "auth/token/*" {
+	policy = "write"
+}
+path "secret/*" {
+	policy = "write"
+	mfa_methods = ["totp"]
+}
+`,
+		},
+		ClientToken: root,
+	}
+	if _, err := c.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	enroll := func(client string) (*logical.Response, error) {
+		req := &logical.Request{
+			Operation: logical.WriteOperation,
+			Path:      "auth/token/mfa-enroll/totp",
+			Data: map[string]interface{}{
+				"token": "child",
+			},
+			ClientToken: client,
+		}
+		return c.HandleRequest(req)
+	}
+
+	// The token being protected cannot enroll itself
+	resp, err := enroll("child")
+	if err != logical.ErrPermissionDenied {
+		t.Fatalf("err: %v %v", err, resp)
+	}
+
+	// An operator enrolls it
+	resp, err = enroll(root)
+	if err != nil {
+		t.Fatalf("err: %v %v", err, resp)
+	}
+	secret, _ := resp.Data["secret"].(string)
+	if secret == "" {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	// A child token shares the enrollment, so it cannot be used to
+	// start over without the second factor
+	req = &logical.Request{
+		Operation:   logical.WriteOperation,
+		Path:        "auth/token/create",
+		ClientToken: "child",
+	}
+	resp, err = c.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v %v", err, resp)
+	}
+	grandchild := resp.Auth.ClientToken
+
+	write := func(payload map[string]string) error {
+		req := &logical.Request{
+			Operation: logical.WriteOperation,
+			Path:      "secret/foo",
+			Data: map[string]interface{}{
+				"foo": "bar",
+			},
+			ClientToken: grandchild,
+			MFAPayload:  payload,
+		}
+		_, err := c.HandleRequest(req)
+		return err
+	}
+	if err := write(nil); err != logical.ErrPermissionDenied {
+		t.Fatalf("err: %v", err)
+	}
+	if resp, err := enroll(grandchild); err != logical.ErrPermissionDenied {
+		t.Fatalf("err: %v %v", err, resp)
+	}
+
+	// The enrolled secret satisfies the policy for the child token
+	passcode, err := totp.GenerateCode(secret, time.Now())
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := write(map[string]string{"totp": passcode}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
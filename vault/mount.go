@@ -26,6 +26,14 @@ const (
 	// systemBarrierPrefix is the prefix used for the
 	// system logical backend.
 	systemBarrierPrefix = "sys/"
+
+	// maxUUIDAttempts is the number of UUIDs generated for a new mount
+	// before giving up on finding an unused one
+	maxUUIDAttempts = 5
+
+	// mountDrainTimeout is how long an operation on the storage of a
+	// mount waits for the requests in flight to the mount to finish
+	mountDrainTimeout = 30 * time.Second
 )
 
 var (
@@ -109,6 +117,18 @@ func (t *MountTable) SetTaint(path string, value bool) bool {
 	return false
 }
 
+// SetQuarantine is used to set the quarantine flag on given entry
+func (t *MountTable) SetQuarantine(path string, value bool) bool {
+	n := len(t.Entries)
+	for i := 0; i < n; i++ {
+		if t.Entries[i].Path == path {
+			t.Entries[i].Quarantined = value
+			return true
+		}
+	}
+	return false
+}
+
 // Remove is used to remove a given path entry
 func (t *MountTable) Remove(path string) bool {
 	n := len(t.Entries)
@@ -124,19 +144,34 @@ func (t *MountTable) Remove(path string) bool {
 
 // MountEntry is used to represent a mount table entry
 type MountEntry struct {
-	Path        string            `json:"path"`              // Mount Path
-	Type        string            `json:"type"`              // Logical backend Type
-	Description string            `json:"description"`       // User-provided description
-	UUID        string            `json:"uuid"`              // Barrier view UUID
-	Config      MountConfig       `json:"config"`            // Configuration related to this mount (but not backend-derived)
-	Options     map[string]string `json:"options"`           // Backend options
-	Tainted     bool              `json:"tainted,omitempty"` // Set as a Write-Ahead flag for unmount/remount
+	Path        string            `json:"path"`                  // Mount Path
+	Type        string            `json:"type"`                  // Logical backend Type
+	Description string            `json:"description"`           // User-provided description
+	UUID        string            `json:"uuid"`                  // Barrier view UUID
+	Config      MountConfig       `json:"config"`                // Configuration related to this mount (but not backend-derived)
+	Options     map[string]string `json:"options"`               // Backend options
+	Tainted     bool              `json:"tainted,omitempty"`     // Set as a Write-Ahead flag for unmount/remount
+	Quarantined bool              `json:"quarantined,omitempty"` // Set when all requests to the mount are rejected
+
+	HumanReadablePrefix bool `json:"human_readable_prefix,omitempty"` // Store data under the mount path instead of the UUID
+	SchemaVersion       int  `json:"schema_version,omitempty"`        // Storage schema version of a backend implementing logical.Upgrader
 }
 
 // MountConfig is used to hold settable options
 type MountConfig struct {
 	DefaultLeaseTTL time.Duration `json:"default_lease_ttl" structs:"default_lease_ttl" mapstructure:"default_lease_ttl"` // Override for global default
 	MaxLeaseTTL     time.Duration `json:"max_lease_ttl" structs:"max_lease_ttl" mapstructure:"max_lease_ttl"`             // Override for global default
+	TokenDefaultTTL time.Duration `json:"token_default_ttl" structs:"token_default_ttl" mapstructure:"token_default_ttl"` // Default TTL of tokens issued by a credential backend
+	TokenMaxTTL     time.Duration `json:"token_max_ttl" structs:"token_max_ttl" mapstructure:"token_max_ttl"`             // Max TTL of tokens issued by a credential backend
+	LogLevel        string        `json:"log_level,omitempty" structs:"log_level" mapstructure:"log_level"`               // Overrides the global level for requests to this mount
+	AuditReads      *bool         `json:"audit_reads,omitempty" structs:"audit_reads" mapstructure:"audit_reads"`         // Overrides the global setting for auditing reads
+
+	// ResponseCacheTTL is how long responses of reads are cached; zero disables caching
+	ResponseCacheTTL time.Duration `json:"response_cache_ttl,omitempty" structs:"response_cache_ttl" mapstructure:"response_cache_ttl"`
+
+	// PreserveLeasesOnSeal exempts the leases of the mount from being
+	// revoked when sealing with revoke_leases_on_seal
+	PreserveLeasesOnSeal bool `json:"preserve_leases_on_seal,omitempty" structs:"preserve_leases_on_seal" mapstructure:"preserve_leases_on_seal"`
 }
 
 // Returns a deep copy of the mount entry
@@ -145,13 +180,22 @@ func (e *MountEntry) Clone() *MountEntry {
 	for k, v := range e.Options {
 		optClone[k] = v
 	}
+	configClone := e.Config
+	if e.Config.AuditReads != nil {
+		auditReads := *e.Config.AuditReads
+		configClone.AuditReads = &auditReads
+	}
 	return &MountEntry{
 		Path:        e.Path,
 		Type:        e.Type,
 		Description: e.Description,
 		UUID:        e.UUID,
-		Config:      e.Config,
+		Config:      configClone,
 		Options:     optClone,
+		Quarantined: e.Quarantined,
+
+		HumanReadablePrefix: e.HumanReadablePrefix,
+		SchemaVersion:       e.SchemaVersion,
 	}
 }
 
@@ -164,6 +208,9 @@ func (c *Core) mount(me *MountEntry) error {
 	if !strings.HasSuffix(me.Path, "/") {
 		me.Path += "/"
 	}
+	if err := validateUTF8("mount path", me.Path); err != nil {
+		return err
+	}
 
 	// Prevent protected paths from being mounted
 	for _, p := range protectedMounts {
@@ -185,8 +232,12 @@ func (c *Core) mount(me *MountEntry) error {
 	}
 
 	// Generate a new UUID and view
-	me.UUID = uuid.GenerateUUID()
-	view := NewBarrierView(c.barrier, backendBarrierPrefix+me.UUID+"/")
+	entryUUID, err := c.newMountUUID(c.mounts, mountStoragePrefix)
+	if err != nil {
+		return err
+	}
+	me.UUID = entryUUID
+	view := NewBarrierView(c.barrier, mountStoragePrefix(me))
 
 	backend, err := c.newLogicalBackend(me.Type, c.mountEntrySysView(me), view, nil)
 	if err != nil {
@@ -209,6 +260,38 @@ func (c *Core) mount(me *MountEntry) error {
 	return nil
 }
 
+// mountStoragePrefix returns the barrier prefix of the data of a
+// logical backend
+func mountStoragePrefix(entry *MountEntry) string {
+	return backendBarrierPrefix + entry.UUID + "/"
+}
+
+// newMountUUID is used to generate the UUID of a new entry of the table,
+// whose data is stored under storagePrefix. The UUID determines where the
+// backend stores its data, so one that is already in use by the table or
+// by retained data is never returned. The table lock must be held.
+func (c *Core) newMountUUID(table *MountTable, storagePrefix func(*MountEntry) string) (string, error) {
+	for i := 0; i < maxUUIDAttempts; i++ {
+		id, err := generateUUID()
+		if err != nil {
+			return "", err
+		}
+
+		prefix := storagePrefix(&MountEntry{UUID: id})
+		inUse := c.retainedStorageConflict(prefix)
+		for _, ent := range table.Entries {
+			if ent.UUID == id || storagePrefix(ent) == prefix {
+				inUse = true
+			}
+		}
+		if !inUse {
+			return id, nil
+		}
+		c.logger.Printf("[WARN] core: generated UUID %s is already in use", id)
+	}
+	return "", fmt.Errorf("failed to generate a unique UUID after %d attempts", maxUUIDAttempts)
+}
+
 // Unmount is used to unmount a path.
 func (c *Core) unmount(path string) error {
 	c.mounts.Lock()
@@ -255,13 +338,18 @@ func (c *Core) unmount(path string) error {
 		return err
 	}
 
+	// Stop rotating the credentials of the backend
+	if err := c.rotation.UnschedulePrefix(path); err != nil {
+		return err
+	}
+
 	// Unmount the backend entirely
 	if err := c.router.Unmount(path); err != nil {
 		return err
 	}
 
-	// Clear the data in the view
-	if err := ClearView(view); err != nil {
+	// Clear the data in the view, or keep it for recovery
+	if err := c.retainMount(path, c.mounts.Find(path), view); err != nil {
 		return err
 	}
 
@@ -301,6 +389,314 @@ func (c *Core) taintMountEntry(path string) error {
 	return nil
 }
 
+// quarantineMount is used to quarantine or release a mount. While
+// quarantined, the router rejects every request to the mount but the
+// backend stays mounted and its data is left untouched.
+func (c *Core) quarantineMount(name string, on bool) error {
+	c.mounts.Lock()
+	defer c.mounts.Unlock()
+
+	// Ensure we end the path in a slash
+	if !strings.HasSuffix(name, "/") {
+		name += "/"
+	}
+
+	// Prevent protected paths from being quarantined
+	for _, p := range protectedMounts {
+		if strings.HasPrefix(name, p) {
+			return fmt.Errorf("cannot quarantine '%s'", name)
+		}
+	}
+
+	// Verify exact match of the route
+	match := c.router.MatchingMount(name)
+	if match == "" || name != match {
+		return fmt.Errorf("no matching mount at '%s'", name)
+	}
+
+	// Update the mount table
+	newTable := c.mounts.ShallowClone()
+	newTable.SetQuarantine(name, on)
+	if err := c.persistMounts(newTable); err != nil {
+		return errors.New("failed to update mount table")
+	}
+	c.mounts = newTable
+
+	// Update the router
+	if err := c.router.Quarantine(name, on); err != nil {
+		return err
+	}
+
+	if on {
+		c.logger.Printf("[WARN] core: quarantined mount '%s'", name)
+	} else {
+		c.logger.Printf("[INFO] core: released mount '%s' from quarantine", name)
+	}
+	return nil
+}
+
+// rewrapMount is used to re-encrypt the data of a single mount under
+// the current keyring term, without rewrapping the rest of the barrier.
+// Requests to the mount are rejected while it is being rewrapped, and
+// the requests already in flight are drained first, so that no
+// concurrent write is overwritten.
+func (c *Core) rewrapMount(name string) error {
+	c.mounts.Lock()
+	defer c.mounts.Unlock()
+
+	// Ensure we end the path in a slash
+	if !strings.HasSuffix(name, "/") {
+		name += "/"
+	}
+
+	// Prevent protected paths from being rewrapped
+	for _, p := range protectedMounts {
+		if strings.HasPrefix(name, p) {
+			return fmt.Errorf("cannot rewrap '%s'", name)
+		}
+	}
+
+	// Verify exact match of the mount
+	entry := c.mounts.Find(name)
+	view := c.router.MatchingStorageView(name)
+	if entry == nil || view == nil || c.router.MatchingMount(name) != name {
+		return fmt.Errorf("no matching mount at '%s'", name)
+	}
+
+	// Hold off requests to the mount, restoring the quarantine
+	// state from the mount table once done
+	if err := c.router.Quarantine(name, true); err != nil {
+		return err
+	}
+	defer c.router.Quarantine(name, entry.Quarantined)
+	if err := c.router.Drain(name, mountDrainTimeout); err != nil {
+		return err
+	}
+
+	// Hold the keyring stable so that every entry ends up under the
+	// same term
+	c.beginRewrap()
+	defer c.endRewrap()
+
+	keys, err := CollectKeys(view)
+	if err != nil {
+		return fmt.Errorf("failed to list keys: %v", err)
+	}
+
+	// Every write goes through the current term, so rewriting each
+	// entry is enough to re-encrypt it
+	for _, key := range keys {
+		raw, err := view.Get(key)
+		if err != nil {
+			return fmt.Errorf("failed to read '%s': %v", key, err)
+		}
+		if raw == nil {
+			continue
+		}
+		if err := view.Put(raw); err != nil {
+			return fmt.Errorf("failed to rewrap '%s': %v", key, err)
+		}
+	}
+
+	c.logger.Printf("[INFO] core: rewrapped %d keys of mount '%s'", len(keys), name)
+	return nil
+}
+
+// cloneMount is used to mount a new backend at dstName using the type,
+// configuration and options of the mount at srcName. The new mount gets
+// its own UUID and starts out empty; no stored data is copied.
+func (c *Core) cloneMount(srcName, dstName string) error {
+	// Ensure we end the path in a slash
+	if !strings.HasSuffix(srcName, "/") {
+		srcName += "/"
+	}
+
+	// Prevent protected paths from being cloned
+	for _, p := range protectedMounts {
+		if strings.HasPrefix(srcName, p) {
+			return fmt.Errorf("cannot clone '%s'", srcName)
+		}
+	}
+
+	c.mounts.Lock()
+	src := c.mounts.Find(srcName)
+	if src != nil {
+		src = src.Clone()
+	}
+	c.mounts.Unlock()
+	if src == nil {
+		return fmt.Errorf("no matching mount at '%s'", srcName)
+	}
+
+	me := &MountEntry{
+		Path:        dstName,
+		Type:        src.Type,
+		Description: src.Description,
+		Config:      src.Config,
+		Options:     src.Options,
+	}
+	if err := c.mount(me); err != nil {
+		return err
+	}
+	c.logger.Printf("[INFO] core: cloned mount '%s' to '%s'", srcName, me.Path)
+	return nil
+}
+
+// swapMounts is used to exchange the paths of two mounts, so that each
+// serves the data of the other. The swapped mount table is persisted
+// first, built from copies of the entries so that nothing in use changes
+// if it cannot be. Leases issued under either path are then revoked, as
+// they are bound to the backend at that path, before the router and the
+// mount table are swapped. The persisted mount table is restored if a
+// later step fails.
+func (c *Core) swapMounts(nameA, nameB string) error {
+	c.mounts.Lock()
+	defer c.mounts.Unlock()
+
+	// Ensure we end the paths in a slash
+	if !strings.HasSuffix(nameA, "/") {
+		nameA += "/"
+	}
+	if !strings.HasSuffix(nameB, "/") {
+		nameB += "/"
+	}
+	if nameA == nameB {
+		return fmt.Errorf("cannot swap '%s' with itself", nameA)
+	}
+
+	for _, name := range []string{nameA, nameB} {
+		// Prevent protected paths from being swapped
+		for _, p := range protectedMounts {
+			if strings.HasPrefix(name, p) {
+				return fmt.Errorf("cannot swap '%s'", name)
+			}
+		}
+
+		// Verify exact match of the route
+		if match := c.router.MatchingMount(name); match != name {
+			return fmt.Errorf("no matching mount at '%s'", name)
+		}
+	}
+
+	// Swap copies of the entries in a new mount table
+	newTable := c.mounts.ShallowClone()
+	var entA, entB *MountEntry
+	for i, ent := range newTable.Entries {
+		switch ent.Path {
+		case nameA:
+			entA = ent.Clone()
+			entA.Path = nameB
+			newTable.Entries[i] = entA
+		case nameB:
+			entB = ent.Clone()
+			entB.Path = nameA
+			newTable.Entries[i] = entB
+		}
+	}
+	if entA == nil || entB == nil {
+		return fmt.Errorf("no matching mount at '%s' or '%s'", nameA, nameB)
+	}
+
+	// Update the mount table
+	if err := c.persistMounts(newTable); err != nil {
+		return errors.New("failed to update mount table")
+	}
+
+	// Clean up the leases while the backends are still at their paths,
+	// and swap the backends
+	if err := c.swapMountBackends(nameA, nameB, entA, entB); err != nil {
+		if err := c.persistMounts(c.mounts); err != nil {
+			c.logger.Printf("[ERR] core: failed to restore mount table after failed swap: %v", err)
+		}
+		return err
+	}
+	c.mounts = newTable
+
+	c.logger.Printf("[INFO] core: swapped '%s' and '%s'", nameA, nameB)
+	return nil
+}
+
+// swapMountBackends is used to revoke the leases of two mounts and swap
+// their backends in the router, giving them their swapped entries
+func (c *Core) swapMountBackends(nameA, nameB string, entA, entB *MountEntry) error {
+	for _, name := range []string{nameA, nameB} {
+		// Invoke the rollback manager a final time
+		if err := c.rollback.Rollback(name); err != nil {
+			return err
+		}
+
+		// Revoke all the dynamic keys
+		if err := c.expiration.RevokePrefix(name); err != nil {
+			return err
+		}
+	}
+	return c.router.Swap(nameA, nameB, entA, entB)
+}
+
+// StaleMounts returns the logical and credential mounts that have not
+// been accessed for longer than the threshold, so that they can be
+// reviewed and removed. Access times are tracked since the mount was
+// loaded, so every mount is considered used when the Vault is unsealed.
+// The returned entries are copies whose Path is the full routing path,
+// such as "auth/github/" for a credential backend. Mounts that cannot
+// be removed are never returned.
+func (c *Core) StaleMounts(threshold time.Duration) ([]*MountEntry, error) {
+	c.stateLock.RLock()
+	defer c.stateLock.RUnlock()
+	if c.sealed {
+		return nil, ErrSealed
+	}
+	if c.standby {
+		return nil, ErrStandby
+	}
+
+	var candidates []*MountEntry
+	c.mounts.RLock()
+	for _, entry := range c.mounts.Entries {
+		if strListContains(singletonMounts, entry.Type) {
+			continue
+		}
+		candidates = append(candidates, entry.Clone())
+	}
+	c.mounts.RUnlock()
+
+	c.auth.RLock()
+	for _, entry := range c.auth.Entries {
+		if entry.Type == "token" {
+			continue
+		}
+		clone := entry.Clone()
+		clone.Path = credentialRoutePrefix + entry.Path
+		candidates = append(candidates, clone)
+	}
+	c.auth.RUnlock()
+
+	cutoff := time.Now().Add(-threshold)
+	var stale []*MountEntry
+	for _, entry := range candidates {
+		last, ok := c.router.LastAccessed(entry.Path)
+		if !ok || !last.Before(cutoff) {
+			continue
+		}
+		c.logger.Printf("[WARN] core: mount '%s' has not been accessed since %s",
+			entry.Path, last.Format(time.RFC3339))
+		stale = append(stale, entry)
+	}
+	return stale, nil
+}
+
+// MatchingMount returns the mount prefix serving a request path, such as
+// "auth/github/", without routing the request. It returns an empty string
+// if no mount matches, including while sealed or in standby.
+func (c *Core) MatchingMount(path string) string {
+	c.stateLock.RLock()
+	defer c.stateLock.RUnlock()
+	if c.sealed || c.standby {
+		return ""
+	}
+	return c.router.MatchingMount(path)
+}
+
 // Remount is used to remount a path at a new mount point.
 func (c *Core) remount(src, dst string) error {
 	c.mounts.Lock()
@@ -351,6 +747,11 @@ func (c *Core) remount(src, dst string) error {
 		return err
 	}
 
+	// Stop rotating the credentials of the backend at the old path
+	if err := c.rotation.UnschedulePrefix(src); err != nil {
+		return err
+	}
+
 	// Update the entry in the mount table
 	newTable := c.mounts.ShallowClone()
 	var ent *MountEntry
@@ -467,7 +868,7 @@ func (c *Core) setupMounts() error {
 	var err error
 	for _, entry := range c.mounts.Entries {
 		// Initialize the backend, special casing for system
-		barrierPath := backendBarrierPrefix + entry.UUID + "/"
+		barrierPath := mountStoragePrefix(entry)
 		if entry.Type == "system" {
 			barrierPath = systemBarrierPrefix
 		}
@@ -507,6 +908,11 @@ func (c *Core) setupMounts() error {
 		if entry.Tainted {
 			c.router.Taint(entry.Path)
 		}
+
+		// Ensure the path is quarantined if set in the mount table
+		if entry.Quarantined {
+			c.router.Quarantine(entry.Path, true)
+		}
 	}
 	return nil
 }
@@ -524,7 +930,7 @@ func (c *Core) unloadMounts() error {
 		}
 	}
 	c.mounts = nil
-	c.router = NewRouter()
+	c.router = c.router.withoutMounts()
 	c.systemBarrierView = nil
 	return nil
 }
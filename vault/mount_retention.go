@@ -0,0 +1,269 @@
+package vault
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+const (
+	// coreRetainedMountsPath is the path used to store the mounts that
+	// were disabled but whose data is kept for recovery
+	coreRetainedMountsPath = "core/retained-mounts"
+
+	// retainedMountReapInterval is how often the data of retained mounts
+	// is checked for expiry
+	retainedMountReapInterval = time.Minute
+)
+
+// retainedMount is a disabled logical or credential mount whose data is
+// kept until DeleteTime, so that it can be recovered
+type retainedMount struct {
+	Path       string      `json:"path"`        // Router path, prefixed with "auth/" for credential backends
+	Entry      *MountEntry `json:"entry"`       // Entry of the mount when it was disabled
+	DeleteTime time.Time   `json:"delete_time"` // Time after which the data is deleted
+}
+
+// credential returns if the retained mount is a credential backend
+func (r *retainedMount) credential() bool {
+	return strings.HasPrefix(r.Path, credentialRoutePrefix)
+}
+
+// storagePrefix returns the barrier prefix of the data of the mount
+func (r *retainedMount) storagePrefix() string {
+	if r.credential() {
+		return credentialStoragePrefix(r.Entry)
+	}
+	return mountStoragePrefix(r.Entry)
+}
+
+// loadRetainedMounts is invoked as part of postUnseal to load the
+// retained mounts and start deleting the expired ones
+func (c *Core) loadRetainedMounts() error {
+	c.retainedLock.Lock()
+	defer c.retainedLock.Unlock()
+
+	c.retainedMounts = nil
+	raw, err := c.barrier.Get(coreRetainedMountsPath)
+	if err != nil {
+		c.logger.Printf("[ERR] core: failed to read retained mounts: %v", err)
+		return err
+	}
+	if raw != nil {
+		if err := json.Unmarshal(raw.Value, &c.retainedMounts); err != nil {
+			c.logger.Printf("[ERR] core: failed to decode retained mounts: %v", err)
+			return err
+		}
+	}
+
+	c.retainedStopCh = make(chan struct{})
+	go c.runMountReaper(c.retainedStopCh)
+	return nil
+}
+
+// stopMountReaper is invoked as part of preSeal to stop deleting
+// the data of expired mounts
+func (c *Core) stopMountReaper() {
+	if c.retainedStopCh != nil {
+		close(c.retainedStopCh)
+		c.retainedStopCh = nil
+	}
+}
+
+// persistRetainedMounts is used to persist the retained mounts. The
+// retainedLock must be held.
+func (c *Core) persistRetainedMounts(retained []*retainedMount) error {
+	raw, err := json.Marshal(retained)
+	if err != nil {
+		c.logger.Printf("[ERR] core: failed to encode retained mounts: %v", err)
+		return err
+	}
+
+	entry := &Entry{
+		Key:   coreRetainedMountsPath,
+		Value: raw,
+	}
+	if err := c.barrier.Put(entry); err != nil {
+		c.logger.Printf("[ERR] core: failed to persist retained mounts: %v", err)
+		return err
+	}
+	return nil
+}
+
+// retainMount is used when a mount is disabled. If a retention period is
+// configured, its data is kept for recovery until the period passes,
+// otherwise the data is deleted.
+func (c *Core) retainMount(path string, entry *MountEntry, view *BarrierView) error {
+	if c.mountRetention <= 0 {
+		return ClearView(view)
+	}
+
+	c.retainedLock.Lock()
+	defer c.retainedLock.Unlock()
+
+	clone := entry.Clone()
+	clone.Tainted = false
+	retained := &retainedMount{
+		Path:       path,
+		Entry:      clone,
+		DeleteTime: time.Now().Add(c.mountRetention),
+	}
+
+	newRetained := append(append([]*retainedMount{}, c.retainedMounts...), retained)
+	if err := c.persistRetainedMounts(newRetained); err != nil {
+		return err
+	}
+	c.retainedMounts = newRetained
+	c.logger.Printf("[INFO] core: retaining data of '%s' until %s", path, retained.DeleteTime)
+	return nil
+}
+
+// retainedStorageConflict returns if the storage prefix is used by the data
+// of a retained mount
+func (c *Core) retainedStorageConflict(prefix string) bool {
+	c.retainedLock.Lock()
+	defer c.retainedLock.Unlock()
+
+	for _, retained := range c.retainedMounts {
+		if retained.storagePrefix() == prefix {
+			return true
+		}
+	}
+	return false
+}
+
+// runMountReaper periodically deletes the data of expired retained
+// mounts until stopCh is closed
+func (c *Core) runMountReaper(stopCh chan struct{}) {
+	for {
+		select {
+		case <-time.After(retainedMountReapInterval):
+			if err := c.reapRetainedMounts(time.Now()); err != nil {
+				c.logger.Printf("[ERR] core: failed to delete data of disabled mounts: %v", err)
+			}
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+// reapRetainedMounts deletes the data of the retained mounts that expired
+// before now. Mounts that fail to be deleted are retried on the next run.
+func (c *Core) reapRetainedMounts(now time.Time) error {
+	c.retainedLock.Lock()
+	defer c.retainedLock.Unlock()
+
+	var remaining []*retainedMount
+	var retErr error
+	for _, retained := range c.retainedMounts {
+		if now.Before(retained.DeleteTime) {
+			remaining = append(remaining, retained)
+			continue
+		}
+
+		view := NewBarrierView(c.barrier, retained.storagePrefix())
+		if err := ClearView(view); err != nil {
+			remaining = append(remaining, retained)
+			retErr = err
+			continue
+		}
+		c.logger.Printf("[INFO] core: deleted data of disabled mount '%s'", retained.Path)
+	}
+
+	if len(remaining) == len(c.retainedMounts) {
+		return retErr
+	}
+	if err := c.persistRetainedMounts(remaining); err != nil {
+		return err
+	}
+	c.retainedMounts = remaining
+	return retErr
+}
+
+// recoverMount re-enables a disabled mount whose data is still retained,
+// at the path it was disabled from
+func (c *Core) recoverMount(path string) error {
+	// Ensure we end the path in a slash
+	if !strings.HasSuffix(path, "/") {
+		path += "/"
+	}
+
+	// Hold the lock of the table the mount is restored into
+	credential := strings.HasPrefix(path, credentialRoutePrefix)
+	table := c.mounts
+	if credential {
+		table = c.auth
+	}
+	table.Lock()
+	defer table.Unlock()
+
+	c.retainedLock.Lock()
+	defer c.retainedLock.Unlock()
+
+	var retained *retainedMount
+	var remaining []*retainedMount
+	for _, r := range c.retainedMounts {
+		if r.Path == path && retained == nil {
+			retained = r
+			continue
+		}
+		remaining = append(remaining, r)
+	}
+	if retained == nil {
+		return fmt.Errorf("no retained mount at '%s'", path)
+	}
+
+	if match := c.router.MatchingMount(path); match != "" {
+		return logical.CodedError(409, fmt.Sprintf("existing mount at %s", match))
+	}
+
+	entry := retained.Entry.Clone()
+	view := NewBarrierView(c.barrier, retained.storagePrefix())
+
+	// Create the backend and restore the table entry
+	var backend logical.Backend
+	var err error
+	if credential {
+		backend, err = c.newCredentialBackend(entry.Type, c.mountEntrySysView(entry), view, entry.Options)
+		if err != nil {
+			return err
+		}
+
+		newTable := c.auth.ShallowClone()
+		newTable.Entries = append(newTable.Entries, entry)
+		if err := c.persistAuth(newTable); err != nil {
+			return fmt.Errorf("failed to update auth table: %v", err)
+		}
+		c.auth = newTable
+	} else {
+		backend, err = c.newLogicalBackend(entry.Type, c.mountEntrySysView(entry), view, nil)
+		if err != nil {
+			return err
+		}
+
+		newTable := c.mounts.ShallowClone()
+		newTable.Entries = append(newTable.Entries, entry)
+		if err := c.persistMounts(newTable); err != nil {
+			return fmt.Errorf("failed to update mount table: %v", err)
+		}
+		c.mounts = newTable
+	}
+
+	// The data is no longer retained once it is in use again
+	if err := c.persistRetainedMounts(remaining); err != nil {
+		return err
+	}
+	c.retainedMounts = remaining
+
+	if err := c.router.Mount(backend, path, entry, view); err != nil {
+		return err
+	}
+	if credential {
+		c.initializeCredential(path, backend)
+	}
+	c.logger.Printf("[INFO] core: recovered disabled mount '%s' type: %s", path, entry.Type)
+	return nil
+}
@@ -0,0 +1,203 @@
+package vault
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestCore_RecoverMount_Credential(t *testing.T) {
+	c, key, _ := TestCoreUnsealed(t)
+	c.mountRetention = time.Hour
+	c.credentialBackends["noop"] = func(*logical.BackendConfig) (logical.Backend, error) {
+		return &NoopBackend{}, nil
+	}
+
+	me := &MountEntry{
+		Path: "foo",
+		Type: "noop",
+	}
+	if _, err := c.enableCredential(me); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Store some data in the backend
+	view := c.router.MatchingStorageView("auth/foo/")
+	if err := view.Put(&logical.StorageEntry{Key: "test", Value: []byte("data")}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if err := c.disableCredential("foo"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if match := c.router.MatchingMount("auth/foo/bar"); match != "" {
+		t.Fatalf("backend still mounted")
+	}
+	out, err := c.barrier.Get(credentialStoragePrefix(me) + "test")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if out == nil {
+		t.Fatalf("data not retained")
+	}
+
+	// Retained mounts survive a restart
+	conf := &CoreConfig{
+		Physical:       c.physical,
+		DisableMlock:   true,
+		MountRetention: time.Hour,
+	}
+	c2, err := NewCore(conf)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	c2.credentialBackends["noop"] = c.credentialBackends["noop"]
+	if _, err := c2.Unseal(key); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if err := c2.recoverMount("auth/foo"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if match := c2.router.MatchingMount("auth/foo/bar"); match != "auth/foo/" {
+		t.Fatalf("missing mount")
+	}
+	view = c2.router.MatchingStorageView("auth/foo/")
+	entry, err := view.Get("test")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if entry == nil || string(entry.Value) != "data" {
+		t.Fatalf("bad: %#v", entry)
+	}
+	if c2.auth.Find("foo/") == nil {
+		t.Fatalf("missing auth table entry")
+	}
+
+	// A recovered mount is no longer retained
+	if err := c2.recoverMount("auth/foo"); err == nil {
+		t.Fatalf("expected error")
+	}
+	if err := c2.reapRetainedMounts(time.Now().Add(2 * time.Hour)); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if entry, err := view.Get("test"); err != nil || entry == nil {
+		t.Fatalf("bad: %#v %v", entry, err)
+	}
+}
+
+func TestCore_RecoverMount_Expired(t *testing.T) {
+	c, _, _ := TestCoreUnsealed(t)
+	c.mountRetention = time.Hour
+
+	me := &MountEntry{
+		Path: "test",
+		Type: "generic",
+	}
+	if err := c.mount(me); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	view := c.router.MatchingStorageView("test/")
+	if err := view.Put(&logical.StorageEntry{Key: "test", Value: []byte("data")}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := c.unmount("test"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	prefix := backendBarrierPrefix + me.UUID + "/"
+
+	// Nothing is deleted within the retention period
+	if err := c.reapRetainedMounts(time.Now().Add(30 * time.Minute)); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	out, err := c.barrier.Get(prefix + "test")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if out == nil {
+		t.Fatalf("data not retained")
+	}
+
+	// After it, the data is deleted and the mount cannot be recovered
+	if err := c.reapRetainedMounts(time.Now().Add(2 * time.Hour)); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	out, err = c.barrier.Get(prefix + "test")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if out != nil {
+		t.Fatalf("data not deleted")
+	}
+	if err := c.recoverMount("test"); err == nil {
+		t.Fatalf("expected error")
+	}
+	if match := c.router.MatchingMount("test/foo"); match != "" {
+		t.Fatalf("mount recovered")
+	}
+}
+
+func TestCore_RecoverMount_Conflict(t *testing.T) {
+	c, _, _ := TestCoreUnsealed(t)
+	c.mountRetention = time.Hour
+
+	me := &MountEntry{
+		Path: "test",
+		Type: "generic",
+	}
+	if err := c.mount(me); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := c.unmount("test"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Recovery fails while another backend uses the path
+	if err := c.mount(&MountEntry{Path: "test", Type: "generic"}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := c.recoverMount("test"); err == nil {
+		t.Fatalf("expected error")
+	}
+	if err := c.unmount("test"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := c.recoverMount("test"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if c.mounts.Find("test/").UUID != me.UUID {
+		t.Fatalf("wrong mount recovered")
+	}
+}
+
+func TestCore_RetainMount_Disabled(t *testing.T) {
+	c, _, _ := TestCoreUnsealed(t)
+
+	me := &MountEntry{
+		Path: "test",
+		Type: "generic",
+	}
+	if err := c.mount(me); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	view := c.router.MatchingStorageView("test/")
+	if err := view.Put(&logical.StorageEntry{Key: "test", Value: []byte("data")}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := c.unmount("test"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Without a retention period the data is deleted immediately
+	out, err := c.barrier.Get(backendBarrierPrefix + me.UUID + "/test")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if out != nil {
+		t.Fatalf("data not deleted")
+	}
+	if err := c.recoverMount("test"); err == nil {
+		t.Fatalf("expected error")
+	}
+}
@@ -1,7 +1,14 @@
 package vault
 
 import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
 	"reflect"
+	"sort"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -315,6 +322,559 @@ func TestCore_Remount_Protected(t *testing.T) {
 	}
 }
 
+func TestCore_QuarantineMount(t *testing.T) {
+	c, key, root := TestCoreUnsealed(t)
+	me := &MountEntry{
+		Path: "foo",
+		Type: "generic",
+	}
+	if err := c.mount(me); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if err := c.quarantineMount("foo", true); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	route := func(c *Core, path string) error {
+		req := logical.TestRequest(t, logical.WriteOperation, path)
+		req.Data["value"] = "bar"
+		req.ClientToken = root
+		_, err := c.HandleRequest(req)
+		return err
+	}
+
+	// The quarantined mount should be rejected
+	if err := route(c, "foo/test"); err != ErrMountQuarantined {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Other mounts should still route
+	if err := route(c, "secret/test"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The quarantine should survive a reload
+	conf := &CoreConfig{
+		Physical:     c.physical,
+		DisableMlock: true,
+	}
+	c2, err := NewCore(conf)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	unseal, err := c2.Unseal(key)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !unseal {
+		t.Fatalf("should be unsealed")
+	}
+	if err := route(c2, "foo/test"); err != ErrMountQuarantined {
+		t.Fatalf("err: %v", err)
+	}
+	if err := route(c2, "secret/test"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Releasing the mount should route again
+	if err := c2.quarantineMount("foo", false); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := route(c2, "foo/test"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestCore_RewrapMount(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+	for _, path := range []string{"foo", "bar"} {
+		me := &MountEntry{
+			Path: path,
+			Type: "generic",
+		}
+		if err := c.mount(me); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+
+		req := logical.TestRequest(t, logical.WriteOperation, path+"/test")
+		req.Data["value"] = "baz"
+		req.ClientToken = root
+		if _, err := c.HandleRequest(req); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+
+	// termOf returns the keyring term the physical entry is encrypted with
+	termOf := func(path string) uint32 {
+		entry := c.mounts.Find(path + "/")
+		out, err := c.physical.Get(backendBarrierPrefix + entry.UUID + "/test")
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if out == nil {
+			t.Fatalf("missing entry for %s", path)
+		}
+		return binary.BigEndian.Uint32(out.Value[:4])
+	}
+	oldTerm := termOf("foo")
+
+	newTerm, err := c.barrier.Rotate()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if err := c.rewrapMount("foo"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Only the rewrapped mount uses the new term
+	if term := termOf("foo"); term != newTerm {
+		t.Fatalf("bad: %d", term)
+	}
+	if term := termOf("bar"); term != oldTerm {
+		t.Fatalf("bad: %d", term)
+	}
+
+	// The data is still readable and the mount routes again
+	req := logical.TestRequest(t, logical.ReadOperation, "foo/test")
+	req.ClientToken = root
+	resp, err := c.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if resp == nil || resp.Data["value"] != "baz" {
+		t.Fatalf("bad: %#v", resp)
+	}
+}
+
+func TestCore_RewrapMount_Drain(t *testing.T) {
+	noop := &NoopBackend{}
+	c, _, root := TestCoreUnsealed(t)
+	c.logicalBackends["noop"] = func(*logical.BackendConfig) (logical.Backend, error) {
+		return noop, nil
+	}
+	me := &MountEntry{
+		Path: "foo",
+		Type: "noop",
+	}
+	if err := c.mount(me); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Hold a request in flight to the mount
+	noop.Lock()
+	go func() {
+		req := logical.TestRequest(t, logical.WriteOperation, "foo/test")
+		req.ClientToken = root
+		c.HandleRequest(req)
+	}()
+	raw, _ := c.router.root.Get("foo/")
+	re := raw.(*routeEntry)
+	for i := 0; ; i++ {
+		re.inflightLock.Lock()
+		inflight := re.inflight
+		re.inflightLock.Unlock()
+		if inflight > 0 {
+			break
+		}
+		if i > 100 {
+			t.Fatalf("request not in flight")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// The rewrap waits for the request before touching the storage
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- c.rewrapMount("foo")
+	}()
+	select {
+	case err := <-errCh:
+		t.Fatalf("rewrap did not wait: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	noop.Unlock()
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("rewrap not finished")
+	}
+}
+
+func TestCore_RewrapMount_Invalid(t *testing.T) {
+	c, _, _ := TestCoreUnsealed(t)
+	if err := c.rewrapMount("sys"); err == nil {
+		t.Fatalf("should fail")
+	}
+	if err := c.rewrapMount("missing"); err == nil {
+		t.Fatalf("should fail")
+	}
+}
+
+func TestMountEntry_Clone(t *testing.T) {
+	auditReads := false
+	me := &MountEntry{
+		Path:    "foo/",
+		Type:    "generic",
+		UUID:    "abcd",
+		Options: map[string]string{"foo": "bar"},
+		Config: MountConfig{
+			AuditReads: &auditReads,
+		},
+	}
+	clone := me.Clone()
+	if !reflect.DeepEqual(me, clone) {
+		t.Fatalf("bad: %#v", clone)
+	}
+
+	// Changing the clone must not change the entry
+	clone.Options["foo"] = "baz"
+	*clone.Config.AuditReads = true
+	if me.Options["foo"] != "bar" || *me.Config.AuditReads {
+		t.Fatalf("bad: %#v", me)
+	}
+}
+
+func TestCore_CloneMount(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+	me := &MountEntry{
+		Path:        "foo",
+		Type:        "generic",
+		Description: "foo secrets",
+		Config: MountConfig{
+			DefaultLeaseTTL: time.Hour,
+			MaxLeaseTTL:     2 * time.Hour,
+		},
+	}
+	if err := c.mount(me); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	req := logical.TestRequest(t, logical.WriteOperation, "foo/test")
+	req.Data["value"] = "baz"
+	req.ClientToken = root
+	if _, err := c.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if err := c.cloneMount("foo", "bar"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The configuration matches but the mount is new
+	clone := c.mounts.Find("bar/")
+	if clone == nil {
+		t.Fatalf("missing mount")
+	}
+	if clone.Type != me.Type || clone.Description != me.Description {
+		t.Fatalf("bad: %#v", clone)
+	}
+	if !reflect.DeepEqual(clone.Config, me.Config) {
+		t.Fatalf("bad: %#v", clone.Config)
+	}
+	if clone.UUID == me.UUID {
+		t.Fatalf("bad: %#v", clone)
+	}
+
+	// No data is copied
+	view := c.router.MatchingStorageView("bar/")
+	keys, err := CollectKeys(view)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(keys) != 0 {
+		t.Fatalf("bad: %v", keys)
+	}
+	req = logical.TestRequest(t, logical.ReadOperation, "bar/test")
+	req.ClientToken = root
+	resp, err := c.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if resp != nil {
+		t.Fatalf("bad: %#v", resp)
+	}
+}
+
+func TestCore_CloneMount_Invalid(t *testing.T) {
+	c, _, _ := TestCoreUnsealed(t)
+	if err := c.cloneMount("sys", "foo"); err == nil {
+		t.Fatalf("should fail")
+	}
+	if err := c.cloneMount("missing", "foo"); err == nil {
+		t.Fatalf("should fail")
+	}
+	if err := c.cloneMount("secret", "secret"); err == nil {
+		t.Fatalf("should fail")
+	}
+}
+
+func TestCore_SwapMounts(t *testing.T) {
+	c, key, root := TestCoreUnsealed(t)
+	for _, path := range []string{"app-blue", "app-green"} {
+		me := &MountEntry{
+			Path: path,
+			Type: "generic",
+		}
+		if err := c.mount(me); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+
+		req := logical.TestRequest(t, logical.WriteOperation, path+"/test")
+		req.Data["value"] = path
+		req.ClientToken = root
+		if _, err := c.HandleRequest(req); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+	blue := c.mounts.Find("app-blue/").UUID
+	green := c.mounts.Find("app-green/").UUID
+
+	if err := c.swapMounts("app-blue", "app-green"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Each path now routes to the data of the other
+	read := func(path string) interface{} {
+		req := logical.TestRequest(t, logical.ReadOperation, path+"/test")
+		req.ClientToken = root
+		resp, err := c.HandleRequest(req)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if resp == nil {
+			t.Fatalf("missing response for %s", path)
+		}
+		return resp.Data["value"]
+	}
+	if out := read("app-blue"); out != "app-green" {
+		t.Fatalf("bad: %v", out)
+	}
+	if out := read("app-green"); out != "app-blue" {
+		t.Fatalf("bad: %v", out)
+	}
+	if c.mounts.Find("app-blue/").UUID != green || c.mounts.Find("app-green/").UUID != blue {
+		t.Fatalf("bad: %#v", c.mounts.Entries)
+	}
+	if me := c.router.MatchingMountEntry("app-blue/"); me.UUID != green || me.Path != "app-blue/" {
+		t.Fatalf("bad: %#v", me)
+	}
+
+	// The swap must survive a restart
+	c2, err := NewCore(&CoreConfig{
+		Physical:     c.physical,
+		DisableMlock: true,
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := c2.Unseal(key); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if c2.mounts.Find("app-blue/").UUID != green || c2.mounts.Find("app-green/").UUID != blue {
+		t.Fatalf("bad: %#v", c2.mounts.Entries)
+	}
+}
+
+// failPutBarrier is a barrier that fails to write the given key
+type failPutBarrier struct {
+	SecurityBarrier
+	key string
+}
+
+func (b *failPutBarrier) Put(entry *Entry) error {
+	if entry.Key == b.key {
+		return fmt.Errorf("failed to write '%s'", entry.Key)
+	}
+	return b.SecurityBarrier.Put(entry)
+}
+
+func TestCore_SwapMounts_PersistFailure(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+	for _, path := range []string{"app-blue", "app-green"} {
+		if err := c.mount(&MountEntry{Path: path + "/", Type: "generic"}); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+
+	// Generate a leased secret
+	req := logical.TestRequest(t, logical.WriteOperation, "app-blue/test")
+	req.Data["value"] = "app-blue"
+	req.Data["ttl"] = "1h"
+	req.ClientToken = root
+	if _, err := c.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	req = logical.TestRequest(t, logical.ReadOperation, "app-blue/test")
+	req.ClientToken = root
+	resp, err := c.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if resp == nil || resp.Secret == nil || resp.Secret.LeaseID == "" {
+		t.Fatalf("bad: %#v", resp)
+	}
+	leaseID := resp.Secret.LeaseID
+
+	// Fail to persist the mount table
+	blue := c.mounts.Find("app-blue/")
+	barrier := c.barrier
+	c.barrier = &failPutBarrier{SecurityBarrier: barrier, key: coreMountConfigPath}
+	err = c.swapMounts("app-blue", "app-green")
+	c.barrier = barrier
+	if err == nil {
+		t.Fatalf("should fail")
+	}
+
+	// Nothing is changed, and the lease is kept
+	if c.mounts.Find("app-blue/") != blue || blue.Path != "app-blue/" {
+		t.Fatalf("bad: %#v", blue)
+	}
+	if c.router.MatchingMountEntry("app-blue/") != blue {
+		t.Fatalf("bad: %#v", c.router.MatchingMountEntry("app-blue/"))
+	}
+	resp, err = c.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if resp == nil || resp.Data["value"] != "app-blue" {
+		t.Fatalf("bad: %#v", resp)
+	}
+	if le, err := c.expiration.loadEntry(leaseID); err != nil || le == nil {
+		t.Fatalf("bad: %#v %v", le, err)
+	}
+}
+
+func TestCore_SwapMounts_Invalid(t *testing.T) {
+	c, _, _ := TestCoreUnsealed(t)
+	if err := c.swapMounts("sys", "secret"); err == nil {
+		t.Fatalf("should fail")
+	}
+	if err := c.swapMounts("secret", "missing"); err == nil {
+		t.Fatalf("should fail")
+	}
+	if err := c.swapMounts("secret", "secret"); err == nil {
+		t.Fatalf("should fail")
+	}
+
+	// Nothing is changed on failure
+	if me := c.mounts.Find("secret/"); me == nil || me.Type != "generic" {
+		t.Fatalf("bad: %#v", c.mounts.Entries)
+	}
+}
+
+func TestCore_StaleMounts(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+	if err := c.mount(&MountEntry{Path: "foo/", Type: "generic"}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := c.enableCredential(&MountEntry{Path: "github/", Type: "noop"}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// setAccessed overrides the last access time of a mount
+	setAccessed := func(path string, age time.Duration) {
+		raw, ok := c.router.root.Get(path)
+		if !ok {
+			t.Fatalf("missing mount %s", path)
+		}
+		atomic.StoreInt64(&raw.(*routeEntry).lastAccessed, time.Now().Add(-age).UnixNano())
+	}
+	setAccessed("foo/", 2*time.Hour)
+	setAccessed("auth/github/", 3*time.Hour)
+	setAccessed("secret/", 30*time.Minute)
+	setAccessed("sys/", 5*time.Hour)
+
+	stalePaths := func(threshold time.Duration) []string {
+		stale, err := c.StaleMounts(threshold)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		var paths []string
+		for _, entry := range stale {
+			paths = append(paths, entry.Path)
+		}
+		sort.Strings(paths)
+		return paths
+	}
+
+	if out := stalePaths(time.Hour); !reflect.DeepEqual(out, []string{"auth/github/", "foo/"}) {
+		t.Fatalf("bad: %v", out)
+	}
+	if out := stalePaths(150 * time.Minute); !reflect.DeepEqual(out, []string{"auth/github/"}) {
+		t.Fatalf("bad: %v", out)
+	}
+	if out := stalePaths(10 * time.Minute); !reflect.DeepEqual(out, []string{"auth/github/", "foo/", "secret/"}) {
+		t.Fatalf("bad: %v", out)
+	}
+
+	// Rollbacks are not client requests, so they leave mounts stale
+	c.rollback.triggerRollbacks()
+	c.rollback.inflightAll.Wait()
+	if out := stalePaths(time.Hour); !reflect.DeepEqual(out, []string{"auth/github/", "foo/"}) {
+		t.Fatalf("bad: %v", out)
+	}
+
+	// Accessing a mount makes it fresh again
+	req := logical.TestRequest(t, logical.ReadOperation, "foo/test")
+	req.ClientToken = root
+	if _, err := c.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if out := stalePaths(time.Hour); !reflect.DeepEqual(out, []string{"auth/github/"}) {
+		t.Fatalf("bad: %v", out)
+	}
+}
+
+func TestCore_MatchingMount(t *testing.T) {
+	c, key, root := TestCoreUnsealed(t)
+	if _, err := c.enableCredential(&MountEntry{Path: "github/", Type: "noop"}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	cases := map[string]string{
+		"auth/github/login": "auth/github/",
+		"auth/token/create": "auth/token/",
+		"secret/foo":        "secret/",
+		"sys/mounts":        "sys/",
+		"missing/foo":       "",
+	}
+	for path, expected := range cases {
+		if mount := c.MatchingMount(path); mount != expected {
+			t.Fatalf("bad: %s %s", path, mount)
+		}
+	}
+
+	// Nothing is mounted while sealed
+	if err := c.Seal(root); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if mount := c.MatchingMount("secret/foo"); mount != "" {
+		t.Fatalf("bad: %s", mount)
+	}
+	if _, err := c.Unseal(TestKeyCopy(key)); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if mount := c.MatchingMount("auth/github/login"); mount != "auth/github/" {
+		t.Fatalf("bad: %s", mount)
+	}
+}
+
+func TestCore_QuarantineMount_Protected(t *testing.T) {
+	c, _, _ := TestCoreUnsealed(t)
+	if err := c.quarantineMount("sys", true); err == nil {
+		t.Fatalf("should fail")
+	}
+	if err := c.quarantineMount("missing", true); err == nil {
+		t.Fatalf("should fail")
+	}
+}
+
 func TestDefaultMountTable(t *testing.T) {
 	table := defaultMountTable()
 	verifyDefaultTable(t, table)
@@ -357,3 +917,38 @@ func verifyDefaultTable(t *testing.T, table *MountTable) {
 	}
 
 }
+
+func TestCore_Mount_UUIDCollision(t *testing.T) {
+	c, _, _ := TestCoreUnsealed(t)
+
+	// The first UUID drawn collides with the secret mount
+	existing, err := hex.DecodeString(strings.Replace(c.mounts.Find("secret/").UUID, "-", "", -1))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	fresh := bytes.Repeat([]byte{0x02}, 16)
+
+	old := uuidRand
+	defer func() { uuidRand = old }()
+	uuidRand = bytes.NewReader(append(existing, fresh...))
+
+	me := &MountEntry{
+		Path: "foo",
+		Type: "generic",
+	}
+	if err := c.mount(me); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if me.UUID != "02020202-0202-0202-0202-020202020202" {
+		t.Fatalf("bad: %s", me.UUID)
+	}
+
+	// Without randomness nothing is mounted
+	uuidRand = bytes.NewReader(nil)
+	if err := c.mount(&MountEntry{Path: "bar", Type: "generic"}); err == nil {
+		t.Fatalf("expected error")
+	}
+	if match := c.router.MatchingMount("bar/"); match != "" {
+		t.Fatalf("bad: %s", match)
+	}
+}
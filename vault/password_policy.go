@@ -0,0 +1,265 @@
+package vault
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/armon/go-metrics"
+	"github.com/hashicorp/hcl"
+	"github.com/hashicorp/vault/logical"
+)
+
+const (
+	// passwordPolicySubPath is the sub-path used for the password policy
+	// store view. This is nested under the system view.
+	passwordPolicySubPath = "password-policy/"
+
+	// maxPasswordLength limits the length of generated passwords
+	maxPasswordLength = 1024
+
+	// defaultPasswordCharset is used by policies without any charset
+	defaultPasswordCharset = "abcdefghijklmnopqrstuvwxyz" +
+		"ABCDEFGHIJKLMNOPQRSTUVWXYZ" +
+		"0123456789"
+)
+
+// PasswordPolicy describes the passwords generated for backends that
+// issue credentials. A password has the given length, is drawn from
+// the union of the charsets and contains at least the minimum number
+// of characters of each charset.
+type PasswordPolicy struct {
+	Name     string             `hcl:"name"`
+	Length   int                `hcl:"length"`
+	Charsets []*PasswordCharset `hcl:"charset,expand"`
+	Raw      string
+}
+
+// PasswordCharset is a class of characters a password may contain
+type PasswordCharset struct {
+	Name     string `hcl:",key"`
+	Chars    string `hcl:"chars"`
+	MinChars int    `hcl:"min_chars"`
+}
+
+// ParsePasswordPolicy is used to parse and validate the rules of a
+// password policy
+func ParsePasswordPolicy(rules string) (*PasswordPolicy, error) {
+	p := &PasswordPolicy{Raw: rules}
+	if err := hcl.Decode(p, rules); err != nil {
+		return nil, fmt.Errorf("failed to parse password policy: %v", err)
+	}
+
+	if p.Length <= 0 || p.Length > maxPasswordLength {
+		return nil, fmt.Errorf("length must be between 1 and %d", maxPasswordLength)
+	}
+	required := 0
+	for _, cs := range p.Charsets {
+		if cs.Chars == "" {
+			return nil, fmt.Errorf("charset '%s' has no chars", cs.Name)
+		}
+		if cs.MinChars < 0 {
+			return nil, fmt.Errorf("charset '%s' has a negative min_chars", cs.Name)
+		}
+		required += cs.MinChars
+	}
+	if required > p.Length {
+		return nil, fmt.Errorf("charsets require %d characters, more than the length of %d",
+			required, p.Length)
+	}
+	return p, nil
+}
+
+// Generate is used to generate a password conforming to the policy.
+// The charsets may contain multi-byte characters, so the password is
+// built from runes.
+func (p *PasswordPolicy) Generate() (string, error) {
+	var all []rune
+	var out []rune
+	for _, cs := range p.Charsets {
+		chars := []rune(cs.Chars)
+		all = append(all, chars...)
+		for i := 0; i < cs.MinChars; i++ {
+			c, err := randomChar(chars)
+			if err != nil {
+				return "", err
+			}
+			out = append(out, c)
+		}
+	}
+	if len(all) == 0 {
+		all = []rune(defaultPasswordCharset)
+	}
+
+	for len(out) < p.Length {
+		c, err := randomChar(all)
+		if err != nil {
+			return "", err
+		}
+		out = append(out, c)
+	}
+
+	// Shuffle so the required characters are not at the start
+	for i := len(out) - 1; i > 0; i-- {
+		j, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+		if err != nil {
+			return "", fmt.Errorf("failed to generate password: %v", err)
+		}
+		out[i], out[j.Int64()] = out[j.Int64()], out[i]
+	}
+	return string(out), nil
+}
+
+// Check is used to verify a password conforms to the policy
+func (p *PasswordPolicy) Check(password string) error {
+	if utf8.RuneCountInString(password) != p.Length {
+		return fmt.Errorf("password must be %d characters", p.Length)
+	}
+
+	all := defaultPasswordCharset
+	if len(p.Charsets) > 0 {
+		all = ""
+		for _, cs := range p.Charsets {
+			all += cs.Chars
+		}
+	}
+	for _, c := range password {
+		if !strings.ContainsRune(all, c) {
+			return fmt.Errorf("password contains invalid character '%c'", c)
+		}
+	}
+
+	for _, cs := range p.Charsets {
+		n := 0
+		for _, c := range password {
+			if strings.ContainsRune(cs.Chars, c) {
+				n++
+			}
+		}
+		if n < cs.MinChars {
+			return fmt.Errorf("password must contain %d characters of charset '%s'",
+				cs.MinChars, cs.Name)
+		}
+	}
+	return nil
+}
+
+// randomChar returns a uniformly chosen character of the charset
+func randomChar(charset []rune) (rune, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(len(charset))))
+	if err != nil {
+		return 0, fmt.Errorf("failed to generate password: %v", err)
+	}
+	return charset[n.Int64()], nil
+}
+
+// PasswordPolicyStore is used to provide durable storage of named
+// password policies
+type PasswordPolicyStore struct {
+	view *BarrierView
+}
+
+// passwordPolicyEntry is used to store a password policy by name
+type passwordPolicyEntry struct {
+	Raw string
+}
+
+// NewPasswordPolicyStore creates a new PasswordPolicyStore that is
+// backed using a given view
+func NewPasswordPolicyStore(view *BarrierView) *PasswordPolicyStore {
+	return &PasswordPolicyStore{
+		view: view,
+	}
+}
+
+// setupPasswordPolicyStore is used to initialize the password policy
+// store when the vault is being unsealed.
+func (c *Core) setupPasswordPolicyStore() error {
+	view := c.systemBarrierView.SubView(passwordPolicySubPath)
+	c.passwordPolicyStore = NewPasswordPolicyStore(view)
+	return nil
+}
+
+// teardownPasswordPolicyStore is used to reverse
+// setupPasswordPolicyStore when the vault is being sealed.
+func (c *Core) teardownPasswordPolicyStore() error {
+	c.passwordPolicyStore = nil
+	return nil
+}
+
+// SetPolicy is used to create or update the given password policy. The
+// name is normalized to lower case.
+func (ps *PasswordPolicyStore) SetPolicy(p *PasswordPolicy) error {
+	defer metrics.MeasureSince([]string{"password_policy", "set_policy"}, time.Now())
+	if p.Name == "" {
+		return fmt.Errorf("password policy name missing")
+	}
+	p.Name = strings.ToLower(p.Name)
+
+	entry, err := logical.StorageEntryJSON(p.Name, &passwordPolicyEntry{
+		Raw: p.Raw,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create entry: %v", err)
+	}
+	if err := ps.view.Put(entry); err != nil {
+		return fmt.Errorf("failed to persist password policy: %v", err)
+	}
+	return nil
+}
+
+// GetPolicy is used to fetch the named password policy
+func (ps *PasswordPolicyStore) GetPolicy(name string) (*PasswordPolicy, error) {
+	defer metrics.MeasureSince([]string{"password_policy", "get_policy"}, time.Now())
+	name = strings.ToLower(name)
+	out, err := ps.view.Get(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read password policy: %v", err)
+	}
+	if out == nil {
+		return nil, nil
+	}
+
+	var entry passwordPolicyEntry
+	if err := out.DecodeJSON(&entry); err != nil {
+		return nil, fmt.Errorf("failed to decode password policy: %v", err)
+	}
+	p, err := ParsePasswordPolicy(entry.Raw)
+	if err != nil {
+		return nil, err
+	}
+	p.Name = name
+	return p, nil
+}
+
+// ListPolicies is used to list the available password policies
+func (ps *PasswordPolicyStore) ListPolicies() ([]string, error) {
+	defer metrics.MeasureSince([]string{"password_policy", "list_policies"}, time.Now())
+	return CollectKeys(ps.view)
+}
+
+// DeletePolicy is used to delete the named password policy
+func (ps *PasswordPolicyStore) DeletePolicy(name string) error {
+	defer metrics.MeasureSince([]string{"password_policy", "delete_policy"}, time.Now())
+	name = strings.ToLower(name)
+	if err := ps.view.Delete(name); err != nil {
+		return fmt.Errorf("failed to delete password policy: %v", err)
+	}
+	return nil
+}
+
+// GeneratePassword is used to generate a password conforming to the
+// named password policy
+func (ps *PasswordPolicyStore) GeneratePassword(name string) (string, error) {
+	p, err := ps.GetPolicy(name)
+	if err != nil {
+		return "", err
+	}
+	if p == nil {
+		return "", fmt.Errorf("password policy '%s' not found", name)
+	}
+	return p.Generate()
+}
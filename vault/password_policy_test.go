@@ -0,0 +1,205 @@
+package vault
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+const testPasswordPolicy = `
+length = 20
+
+charset "lower" {
+	chars = "abcdefghijklmnopqrstuvwxyz"
+	min_chars = 2
+}
+
+charset "upper" {
+	chars = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	min_chars = 2
+}
+
+charset "digits" {
+	chars = "0123456789"
+	min_chars = 3
+}
+
+charset "symbols" {
+	chars = "!@#$%^&*"
+	min_chars = 1
+}
+`
+
+func TestPasswordPolicy_Generate(t *testing.T) {
+	p, err := ParsePasswordPolicy(testPasswordPolicy)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if p.Length != 20 || len(p.Charsets) != 4 {
+		t.Fatalf("bad: %#v", p)
+	}
+
+	count := func(password, chars string) int {
+		n := 0
+		for _, c := range password {
+			if strings.ContainsRune(chars, c) {
+				n++
+			}
+		}
+		return n
+	}
+
+	for i := 0; i < 100; i++ {
+		password, err := p.Generate()
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if err := p.Check(password); err != nil {
+			t.Fatalf("err: %v: %s", err, password)
+		}
+		if len(password) != 20 {
+			t.Fatalf("bad: %s", password)
+		}
+		if count(password, "0123456789") < 3 || count(password, "!@#$%^&*") < 1 {
+			t.Fatalf("bad: %s", password)
+		}
+	}
+}
+
+func TestPasswordPolicy_Default(t *testing.T) {
+	p, err := ParsePasswordPolicy(`length = 32`)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	password, err := p.Generate()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(password) != 32 {
+		t.Fatalf("bad: %s", password)
+	}
+	for _, c := range password {
+		if !strings.ContainsRune(defaultPasswordCharset, c) {
+			t.Fatalf("bad: %s", password)
+		}
+	}
+}
+
+func TestPasswordPolicy_Generate_Multibyte(t *testing.T) {
+	p, err := ParsePasswordPolicy(`
+length = 16
+
+charset "greek" {
+	chars = "αβγδε"
+	min_chars = 4
+}
+
+charset "digits" {
+	chars = "0123456789"
+}
+`)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for i := 0; i < 100; i++ {
+		password, err := p.Generate()
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if !utf8.ValidString(password) {
+			t.Fatalf("invalid UTF-8: %q", password)
+		}
+		if err := p.Check(password); err != nil {
+			t.Fatalf("err: %v: %s", err, password)
+		}
+	}
+}
+
+func TestPasswordPolicy_Check(t *testing.T) {
+	p, err := ParsePasswordPolicy(testPasswordPolicy)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for _, password := range []string{
+		"short",
+		"abcdefghijABCDEFGHIJ",
+		"abAB123!abAB123!abA~",
+	} {
+		if err := p.Check(password); err == nil {
+			t.Fatalf("expected error for %s", password)
+		}
+	}
+	if err := p.Check("abAB123!abAB123!abAB"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestParsePasswordPolicy_Invalid(t *testing.T) {
+	for _, rules := range []string{
+		`length = 0`,
+		`length = 100000`,
+		`length = 2
+charset "digits" {
+	chars = "0123456789"
+	min_chars = 3
+}`,
+		`length = 8
+charset "empty" {
+	min_chars = 1
+}`,
+		`length = `,
+	} {
+		if _, err := ParsePasswordPolicy(rules); err == nil {
+			t.Fatalf("expected error for %s", rules)
+		}
+	}
+}
+
+func TestPasswordPolicyStore(t *testing.T) {
+	c, _, _ := TestCoreUnsealed(t)
+	ps := c.passwordPolicyStore
+
+	p, err := ParsePasswordPolicy(testPasswordPolicy)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	p.Name = "database"
+	if err := ps.SetPolicy(p); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	out, err := ps.GetPolicy("database")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if out == nil || out.Name != "database" || out.Raw != testPasswordPolicy {
+		t.Fatalf("bad: %#v", out)
+	}
+
+	names, err := ps.ListPolicies()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(names) != 1 || names[0] != "database" {
+		t.Fatalf("bad: %v", names)
+	}
+
+	// Backends generate passwords through their system view
+	sysView := c.router.MatchingSystemView("secret/")
+	password, err := sysView.GeneratePasswordFromPolicy("database")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := p.Check(password); err != nil {
+		t.Fatalf("err: %v: %s", err, password)
+	}
+
+	if err := ps.DeletePolicy("database"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := sysView.GeneratePasswordFromPolicy("database"); err == nil {
+		t.Fatalf("expected error")
+	}
+}
@@ -2,6 +2,7 @@ package vault
 
 import (
 	"fmt"
+	"net"
 	"strings"
 
 	"github.com/hashicorp/hcl"
@@ -9,6 +10,7 @@ import (
 
 const (
 	PathPolicyDeny  = "deny"
+	PathPolicyList  = "list"
 	PathPolicyRead  = "read"
 	PathPolicyWrite = "write"
 	PathPolicySudo  = "sudo"
@@ -24,9 +26,63 @@ type Policy struct {
 
 // PathPolicy represents a policy for a path in the namespace
 type PathPolicy struct {
-	Prefix string `hcl:",key"`
-	Policy string
-	Glob   bool
+	Prefix   string `hcl:",key"`
+	Policy   string
+	Glob     bool
+	CIDRList []string `hcl:"cidr_list"`
+
+	// AllowedResponseFields and DeniedResponseFields restrict the keys
+	// of the response data returned for the path. If any fields are
+	// allowed, all others are removed. Denied fields are always removed.
+	AllowedResponseFields []string `hcl:"allowed_response_fields"`
+	DeniedResponseFields  []string `hcl:"denied_response_fields"`
+
+	// MFAMethods are the names of the MFA methods the request must
+	// satisfy, using the MFA payload, for the policy to apply.
+	MFAMethods []string `hcl:"mfa_methods"`
+
+	// cidrs is the parsed CIDRList. If non-empty, the policy only
+	// applies to requests coming from one of these networks.
+	cidrs []*net.IPNet
+}
+
+// AllowsRemoteAddr checks if the policy applies to a request coming
+// from the given address. A policy restricted by CIDR never applies
+// to a request without a known address.
+func (p *PathPolicy) AllowsRemoteAddr(remoteAddr string) bool {
+	if len(p.cidrs) == 0 {
+		return true
+	}
+	ip := net.ParseIP(remoteAddr)
+	if ip == nil {
+		return false
+	}
+	for _, cidr := range p.cidrs {
+		if cidr.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// FilterResponseData returns the response data with only the fields
+// exposed by the policy. The given map is not modified.
+func (p *PathPolicy) FilterResponseData(data map[string]interface{}) map[string]interface{} {
+	if len(p.AllowedResponseFields) == 0 && len(p.DeniedResponseFields) == 0 {
+		return data
+	}
+
+	filtered := make(map[string]interface{}, len(data))
+	for k, v := range data {
+		if len(p.AllowedResponseFields) > 0 && !strListContains(p.AllowedResponseFields, k) {
+			continue
+		}
+		if strListContains(p.DeniedResponseFields, k) {
+			continue
+		}
+		filtered[k] = v
+	}
+	return filtered
 }
 
 // TakesPrecedence is used when multiple policies
@@ -39,13 +95,23 @@ func (p *PathPolicy) TakesPrecedence(other *PathPolicy) bool {
 		// Deny always takes precendence
 		return true
 
-	case PathPolicyRead:
-		// Read never takes precedence
+	case PathPolicyList:
+		// List never takes precedence
 		return false
 
+	case PathPolicyRead:
+		switch other.Policy {
+		case PathPolicyList:
+			return true
+		case PathPolicyDeny, PathPolicyRead, PathPolicyWrite, PathPolicySudo:
+			return false
+		default:
+			panic("missing case")
+		}
+
 	case PathPolicyWrite:
 		switch other.Policy {
-		case PathPolicyRead:
+		case PathPolicyList, PathPolicyRead:
 			return true
 		case PathPolicyDeny, PathPolicyWrite, PathPolicySudo:
 			return false
@@ -55,7 +121,7 @@ func (p *PathPolicy) TakesPrecedence(other *PathPolicy) bool {
 
 	case PathPolicySudo:
 		switch other.Policy {
-		case PathPolicyRead, PathPolicyWrite:
+		case PathPolicyList, PathPolicyRead, PathPolicyWrite:
 			return true
 		case PathPolicyDeny, PathPolicySudo:
 			return false
@@ -89,12 +155,29 @@ func Parse(rules string) (*Policy, error) {
 		// Check the policy is valid
 		switch pp.Policy {
 		case PathPolicyDeny:
+		case PathPolicyList:
 		case PathPolicyRead:
 		case PathPolicyWrite:
 		case PathPolicySudo:
 		default:
 			return nil, fmt.Errorf("Invalid path policy: %#v", pp)
 		}
+
+		// Parse the CIDR restrictions
+		for _, raw := range pp.CIDRList {
+			_, cidr, err := net.ParseCIDR(raw)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid CIDR '%s' for path '%s': %v", raw, pp.Prefix, err)
+			}
+			pp.cidrs = append(pp.cidrs, cidr)
+		}
+
+		// Check the MFA methods are known
+		for _, method := range pp.MFAMethods {
+			if _, ok := mfaMethods[method]; !ok {
+				return nil, fmt.Errorf("Invalid MFA method '%s' for path '%s'", method, pp.Prefix)
+			}
+		}
 	}
 	return p, nil
 }
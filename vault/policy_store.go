@@ -2,6 +2,7 @@ package vault
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/armon/go-metrics"
@@ -23,6 +24,10 @@ const (
 type PolicyStore struct {
 	view *BarrierView
 	lru  *lru.Cache
+
+	// disableDenyOverrides makes the most specific rule decide in the
+	// ACLs built by the store, even over a matching deny rule
+	disableDenyOverrides bool
 }
 
 // PolicyEntry is used to store a policy by name
@@ -50,6 +55,7 @@ func (c *Core) setupPolicyStore() error {
 
 	// Create the policy store
 	c.policyStore = NewPolicyStore(view)
+	c.policyStore.disableDenyOverrides = c.disableDenyOverrides
 
 	/*
 		// Ensure that the default policy exists, and if not, create it
@@ -71,6 +77,58 @@ func (c *Core) teardownPolicyStore() error {
 	return nil
 }
 
+// getPolicy is used to fetch the named policy. The stateLock must be held.
+func (c *Core) getPolicy(name string) (*Policy, error) {
+	if c.policyStore == nil {
+		return nil, ErrSealed
+	}
+	return c.policyStore.GetPolicy(name)
+}
+
+// setPolicy is used to parse the given rules and store them as the named
+// policy. The name is normalized to lower case. The stateLock must be held.
+func (c *Core) setPolicy(name string, rules string) error {
+	if c.policyStore == nil {
+		return ErrSealed
+	}
+	p, err := Parse(rules)
+	if err != nil {
+		return err
+	}
+	p.Name = strings.ToLower(name)
+	if err := c.policyStore.SetPolicy(p); err != nil {
+		return err
+	}
+	c.logger.Printf("[INFO] core: updated policy '%s'", p.Name)
+	return nil
+}
+
+// deletePolicy is used to delete the named policy. The stateLock must
+// be held.
+func (c *Core) deletePolicy(name string) error {
+	if c.policyStore == nil {
+		return ErrSealed
+	}
+	if err := c.policyStore.DeletePolicy(name); err != nil {
+		return err
+	}
+	c.logger.Printf("[INFO] core: deleted policy '%s'", name)
+	return nil
+}
+
+// listPolicies is used to list the names of the policies, including the
+// special "root" policy. The stateLock must be held.
+func (c *Core) listPolicies() ([]string, error) {
+	if c.policyStore == nil {
+		return nil, ErrSealed
+	}
+	policies, err := c.policyStore.ListPolicies()
+	if err != nil {
+		return nil, err
+	}
+	return append(policies, "root"), nil
+}
+
 // SetPolicy is used to create or update the given policy
 func (ps *PolicyStore) SetPolicy(p *Policy) error {
 	defer metrics.MeasureSince([]string{"policy", "set_policy"}, time.Now())
@@ -196,6 +254,7 @@ func (ps *PolicyStore) ACL(names ...string) (*ACL, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to construct ACL: %v", err)
 	}
+	acl.denyOverrides = !ps.disableDenyOverrides
 	return acl, nil
 }
 
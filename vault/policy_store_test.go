@@ -153,3 +153,45 @@ func TestPolicyStore_v1Upgrade(t *testing.T) {
 		t.Fatalf("should enable glob")
 	}
 }
+
+func TestCore_PolicyCRUD(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+
+	if err := c.setPolicy("Dev", aclPolicy); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := c.setPolicy("bad", `path "foo/" { policy = "bad" }`); err == nil {
+		t.Fatalf("expected error")
+	}
+
+	p, err := c.getPolicy("dev")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if p == nil || p.Name != "dev" || p.Raw != aclPolicy {
+		t.Fatalf("bad: %#v", p)
+	}
+
+	out, err := c.listPolicies()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if expect := []string{"dev", "root"}; !reflect.DeepEqual(out, expect) {
+		t.Fatalf("bad: %v", out)
+	}
+
+	if err := c.deletePolicy("dev"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if p, err := c.getPolicy("dev"); err != nil || p != nil {
+		t.Fatalf("bad: %#v %v", p, err)
+	}
+
+	// The policy store is unavailable while sealed
+	if err := c.Seal(root); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := c.listPolicies(); err != ErrSealed {
+		t.Fatalf("err: %v", err)
+	}
+}
@@ -12,21 +12,31 @@ func TestPolicy_TakesPrecedence(t *testing.T) {
 	}
 	tests := []tcase{
 		tcase{PathPolicyDeny, PathPolicyDeny, true},
+		tcase{PathPolicyDeny, PathPolicyList, true},
 		tcase{PathPolicyDeny, PathPolicyRead, true},
 		tcase{PathPolicyDeny, PathPolicyWrite, true},
 		tcase{PathPolicyDeny, PathPolicySudo, true},
 
+		tcase{PathPolicyList, PathPolicyDeny, false},
+		tcase{PathPolicyList, PathPolicyList, false},
+		tcase{PathPolicyList, PathPolicyRead, false},
+		tcase{PathPolicyList, PathPolicyWrite, false},
+		tcase{PathPolicyList, PathPolicySudo, false},
+
 		tcase{PathPolicyRead, PathPolicyDeny, false},
+		tcase{PathPolicyRead, PathPolicyList, true},
 		tcase{PathPolicyRead, PathPolicyRead, false},
 		tcase{PathPolicyRead, PathPolicyWrite, false},
 		tcase{PathPolicyRead, PathPolicySudo, false},
 
 		tcase{PathPolicyWrite, PathPolicyDeny, false},
+		tcase{PathPolicyWrite, PathPolicyList, true},
 		tcase{PathPolicyWrite, PathPolicyRead, true},
 		tcase{PathPolicyWrite, PathPolicyWrite, false},
 		tcase{PathPolicyWrite, PathPolicySudo, false},
 
 		tcase{PathPolicySudo, PathPolicyDeny, false},
+		tcase{PathPolicySudo, PathPolicyList, true},
 		tcase{PathPolicySudo, PathPolicyRead, true},
 		tcase{PathPolicySudo, PathPolicyWrite, true},
 		tcase{PathPolicySudo, PathPolicySudo, false},
@@ -52,9 +62,9 @@ func TestPolicy_Parse(t *testing.T) {
 	}
 
 	expect := []*PathPolicy{
-		&PathPolicy{"", "deny", true},
-		&PathPolicy{"stage/", "sudo", true},
-		&PathPolicy{"prod/version", "read", false},
+		&PathPolicy{Prefix: "", Policy: "deny", Glob: true},
+		&PathPolicy{Prefix: "stage/", Policy: "sudo", Glob: true},
+		&PathPolicy{Prefix: "prod/version", Policy: "read", Glob: false},
 	}
 	if !reflect.DeepEqual(p.Paths, expect) {
 		t.Fatalf("bad: %#v", p)
@@ -0,0 +1,56 @@
+package vault
+
+import (
+	"sync"
+	"time"
+)
+
+// rateLimiter is a token bucket allowing a sustained rate of requests
+// per second, with bursts of up to burst requests
+type rateLimiter struct {
+	l      sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+
+	// now is used to read the time, and can be replaced by tests
+	now func() time.Time
+}
+
+// newRateLimiter returns a limiter with a full bucket. A burst lower
+// than one is raised to one, so requests can be allowed at all.
+func newRateLimiter(rate, burst int) *rateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{
+		rate:   float64(rate),
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+		now:    time.Now,
+	}
+}
+
+// allow takes a token from the bucket, refilled at the rate since the
+// last call. It returns false without waiting if the bucket is empty.
+func (l *rateLimiter) allow() bool {
+	l.l.Lock()
+	defer l.l.Unlock()
+
+	now := l.now()
+	if elapsed := now.Sub(l.last); elapsed > 0 {
+		l.tokens += elapsed.Seconds() * l.rate
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+	}
+	l.last = now
+
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
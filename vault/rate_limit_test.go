@@ -0,0 +1,42 @@
+package vault
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Now()
+	l := newRateLimiter(2, 3)
+	l.now = func() time.Time { return now }
+
+	// The bucket starts full
+	for i := 0; i < 3; i++ {
+		if !l.allow() {
+			t.Fatalf("request %d should be allowed", i)
+		}
+	}
+	if l.allow() {
+		t.Fatalf("burst should be exhausted")
+	}
+
+	// Refilled at the rate
+	now = now.Add(500 * time.Millisecond)
+	if !l.allow() {
+		t.Fatalf("should be refilled")
+	}
+	if l.allow() {
+		t.Fatalf("should be exhausted")
+	}
+
+	// Never above the burst
+	now = now.Add(time.Minute)
+	for i := 0; i < 3; i++ {
+		if !l.allow() {
+			t.Fatalf("request %d should be allowed", i)
+		}
+	}
+	if l.allow() {
+		t.Fatalf("burst should be exhausted")
+	}
+}
@@ -0,0 +1,158 @@
+package vault
+
+import (
+	"sync"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/mitchellh/copystructure"
+)
+
+const (
+	// maxResponseCacheEntries limits the number of responses cached
+	// for a single mount
+	maxResponseCacheEntries = 1024
+)
+
+// responseCache caches the responses of read operations on a mount, for
+// the response cache TTL of the mount. Responses are scoped to the salted
+// client token, so that responses depending on the token are never shared,
+// and are cached as returned by the backend; the core filters them by the
+// ACL of every request, including those served from the cache.
+type responseCache struct {
+	l sync.Mutex
+
+	// entries maps a path to the responses cached for each token
+	entries map[string]map[string]*cachedResponse
+	size    int
+
+	// generation is incremented every time the cache is cleared, so that
+	// a read started before a write does not cache a stale response
+	generation uint64
+}
+
+type cachedResponse struct {
+	resp    *logical.Response
+	expires time.Time
+}
+
+func newResponseCache() *responseCache {
+	return &responseCache{
+		entries: make(map[string]map[string]*cachedResponse),
+	}
+}
+
+// cacheableResponse checks if the result of a read may be cached. Errors,
+// renewable or leased secrets, authentication and redirects are never
+// cached. A secret that cannot be renewed and has no lease, such as those
+// of the generic backend, only carries a TTL and may be cached.
+func cacheableResponse(resp *logical.Response, err error) bool {
+	if err != nil || resp == nil || resp.IsError() {
+		return false
+	}
+	if resp.Secret != nil && (resp.Secret.Renewable || resp.Secret.LeaseID != "") {
+		return false
+	}
+	return resp.Auth == nil && resp.Redirect == ""
+}
+
+// copyResponse returns a deep copy of a response, so that the cached
+// response and the one served may each be modified without affecting
+// the other
+func copyResponse(resp *logical.Response) (*logical.Response, error) {
+	cp, err := copystructure.Copy(resp)
+	if err != nil {
+		return nil, err
+	}
+	return cp.(*logical.Response), nil
+}
+
+// get returns the cached response for the path and token, if any. The
+// current generation is returned for a subsequent call to put.
+func (c *responseCache) get(path, token string, now time.Time) (*logical.Response, uint64, bool) {
+	c.l.Lock()
+	defer c.l.Unlock()
+
+	cached, ok := c.entries[path][token]
+	if !ok {
+		return nil, c.generation, false
+	}
+	if now.After(cached.expires) {
+		c.remove(path, token)
+		return nil, c.generation, false
+	}
+	resp, err := copyResponse(cached.resp)
+	if err != nil {
+		return nil, c.generation, false
+	}
+	return resp, c.generation, true
+}
+
+// put caches the response for the path and token until expires, unless
+// the cache was invalidated since generation
+func (c *responseCache) put(path, token string, resp *logical.Response, generation uint64, expires time.Time) {
+	c.l.Lock()
+	defer c.l.Unlock()
+
+	if generation != c.generation {
+		return
+	}
+	cp, err := copyResponse(resp)
+	if err != nil {
+		return
+	}
+	if c.size >= maxResponseCacheEntries {
+		c.purgeExpired(time.Now())
+		if c.size >= maxResponseCacheEntries {
+			return
+		}
+	}
+
+	tokens, ok := c.entries[path]
+	if !ok {
+		tokens = make(map[string]*cachedResponse)
+		c.entries[path] = tokens
+	}
+	if _, ok := tokens[token]; !ok {
+		c.size++
+	}
+	tokens[token] = &cachedResponse{
+		resp:    cp,
+		expires: expires,
+	}
+}
+
+// clear removes every cached response
+func (c *responseCache) clear() {
+	c.l.Lock()
+	defer c.l.Unlock()
+
+	c.generation++
+	c.size = 0
+	c.entries = make(map[string]map[string]*cachedResponse)
+}
+
+// remove deletes a single cached response. The lock must be held.
+func (c *responseCache) remove(path, token string) {
+	tokens := c.entries[path]
+	if _, ok := tokens[token]; !ok {
+		return
+	}
+	delete(tokens, token)
+	c.size--
+	if len(tokens) == 0 {
+		delete(c.entries, path)
+	}
+}
+
+// purgeExpired deletes the responses that expired before now. The lock
+// must be held.
+func (c *responseCache) purgeExpired(now time.Time) {
+	for path, tokens := range c.entries {
+		for token, cached := range tokens {
+			if now.After(cached.expires) {
+				c.remove(path, token)
+			}
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package vault
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestCore_ResponseCache_ResponseFields(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+	testCoreMakeToken(t, c, root, "child", []string{"test"})
+
+	requests := []*logical.Request{
+		// Cache the responses of the generic backend
+		&logical.Request{
+			Operation: logical.WriteOperation,
+			Path:      "sys/mounts/secret/tune",
+			Data: map[string]interface{}{
+				"response_cache_ttl": "1h",
+			},
+		},
+		&logical.Request{
+			Operation: logical.WriteOperation,
+			Path:      "secret/test",
+			Data: map[string]interface{}{
+				"username": "foo",
+				"password": "bar",
+			},
+		},
+		&logical.Request{
+			Operation: logical.WriteOperation,
+			Path:      "sys/policy/test",
+			Data: map[string]interface{}{
+				"rules": `path "secret/*" { policy = "write" denied_response_fields = ["password"] }`,
+			},
+		},
+	}
+	for _, req := range requests {
+		req.ClientToken = root
+		if _, err := c.HandleRequest(req); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+	if me := c.router.MatchingMountEntry("secret/"); me.Config.ResponseCacheTTL.Hours() != 1 {
+		t.Fatalf("bad: %#v", me.Config)
+	}
+
+	read := func(token string) map[string]interface{} {
+		req := &logical.Request{
+			Operation:   logical.ReadOperation,
+			Path:        "secret/test",
+			ClientToken: token,
+		}
+		resp, err := c.HandleRequest(req)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if resp == nil {
+			t.Fatalf("missing response")
+		}
+		return resp.Data
+	}
+
+	// Cached responses are filtered for every request
+	filtered := map[string]interface{}{"username": "foo"}
+	full := map[string]interface{}{"username": "foo", "password": "bar"}
+	for i := 0; i < 2; i++ {
+		if out := read("child"); !reflect.DeepEqual(out, filtered) {
+			t.Fatalf("bad: %#v", out)
+		}
+		if out := read(root); !reflect.DeepEqual(out, full) {
+			t.Fatalf("bad: %#v", out)
+		}
+	}
+
+	// A write is visible immediately
+	req := &logical.Request{
+		Operation:   logical.WriteOperation,
+		Path:        "secret/test",
+		Data:        map[string]interface{}{"username": "baz"},
+		ClientToken: "child",
+	}
+	if _, err := c.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if out := read(root); !reflect.DeepEqual(out, map[string]interface{}{"username": "baz"}) {
+		t.Fatalf("bad: %#v", out)
+	}
+}
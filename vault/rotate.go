@@ -0,0 +1,97 @@
+package vault
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+const (
+	// RotateDuringRewrapQueue waits for rewraps in progress to finish
+	// before rotating the encryption key
+	RotateDuringRewrapQueue = "queue"
+
+	// RotateDuringRewrapReject rejects a rotation of the encryption key
+	// while a rewrap is in progress
+	RotateDuringRewrapReject = "reject"
+)
+
+var (
+	// ErrRewrapInProgress is returned if the encryption key is rotated
+	// while a rewrap is in progress and rotations are rejected
+	ErrRewrapInProgress = logical.NewVaultError(logical.ErrCodeConflict, http.StatusConflict, true,
+		"cannot rotate the encryption key while a rewrap is in progress")
+)
+
+// Rotate is used to install a new encryption key in the barrier. New
+// writes are encrypted with the new key term, while existing entries
+// remain readable under the term they were written with.
+func (c *Core) Rotate() error {
+	c.stateLock.RLock()
+	defer c.stateLock.RUnlock()
+	if c.sealed {
+		return ErrSealed
+	}
+	if c.standby {
+		return ErrStandby
+	}
+	return c.rotateWithUpgrade()
+}
+
+// rotateWithUpgrade is used to rotate the encryption key and, in HA
+// mode, provide an upgrade path to the new term for the standby
+// instances. The state lock must be held.
+func (c *Core) rotateWithUpgrade() error {
+	newTerm, err := c.rotate()
+	if err != nil {
+		c.logger.Printf("[ERR] core: failed to create new encryption key: %v", err)
+		return err
+	}
+	c.logger.Printf("[INFO] core: installed new encryption key (term: %d)", newTerm)
+
+	if c.ha == nil {
+		return nil
+	}
+
+	// Create the upgrade path to the new term
+	if err := c.barrier.CreateUpgrade(newTerm); err != nil {
+		c.logger.Printf("[ERR] core: failed to create new upgrade for key term %d: %v", newTerm, err)
+	}
+
+	// Schedule the destroy of the upgrade path
+	time.AfterFunc(keyRotateGracePeriod, func() {
+		if err := c.barrier.DestroyUpgrade(newTerm); err != nil {
+			c.logger.Printf("[ERR] core: failed to destroy upgrade for key term %d: %v", newTerm, err)
+		}
+	})
+	return nil
+}
+
+// rotate is used to rotate the encryption key of the barrier. A rewrap
+// works against the keyring it started with, so the rotation is either
+// queued until rewraps finish or rejected, depending on the configuration.
+func (c *Core) rotate() (uint32, error) {
+	if c.rotateDuringRewrap == RotateDuringRewrapReject && atomic.LoadInt32(&c.rewrapsInFlight) > 0 {
+		c.logger.Printf("[WARN] core: rejecting key rotation, rewrap in progress")
+		return 0, ErrRewrapInProgress
+	}
+
+	c.keyringLock.Lock()
+	defer c.keyringLock.Unlock()
+	return c.barrier.Rotate()
+}
+
+// beginRewrap is used to hold the keyring stable for the duration of a
+// rewrap. It must be followed by a call to endRewrap.
+func (c *Core) beginRewrap() {
+	atomic.AddInt32(&c.rewrapsInFlight, 1)
+	c.keyringLock.RLock()
+}
+
+// endRewrap releases the keyring held by beginRewrap
+func (c *Core) endRewrap() {
+	c.keyringLock.RUnlock()
+	atomic.AddInt32(&c.rewrapsInFlight, -1)
+}
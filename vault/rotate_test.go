@@ -0,0 +1,181 @@
+package vault
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/physical"
+)
+
+func TestNewCore_badRotateDuringRewrap(t *testing.T) {
+	conf := &CoreConfig{
+		Physical:           physical.NewInmem(),
+		DisableMlock:       true,
+		RotateDuringRewrap: "ignore",
+	}
+	if _, err := NewCore(conf); err == nil {
+		t.Fatalf("should fail")
+	}
+}
+
+func TestCore_Rotate_QueuedDuringRewrap(t *testing.T) {
+	c, _, _ := TestCoreUnsealed(t)
+	if c.rotateDuringRewrap != RotateDuringRewrapQueue {
+		t.Fatalf("bad: %s", c.rotateDuringRewrap)
+	}
+	before := c.barrier.(*AESGCMBarrier).keyring.ActiveTerm()
+
+	c.beginRewrap()
+	doneCh := make(chan error, 1)
+	go func() {
+		_, err := c.rotate()
+		doneCh <- err
+	}()
+
+	// The rotation waits for the rewrap to finish
+	select {
+	case err := <-doneCh:
+		t.Fatalf("rotated during rewrap: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if term := c.barrier.(*AESGCMBarrier).keyring.ActiveTerm(); term != before {
+		t.Fatalf("bad: %d", term)
+	}
+
+	c.endRewrap()
+	select {
+	case err := <-doneCh:
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("rotation not resumed")
+	}
+	if term := c.barrier.(*AESGCMBarrier).keyring.ActiveTerm(); term != before+1 {
+		t.Fatalf("bad: %d", term)
+	}
+}
+
+func TestCore_Rotate_RejectedDuringRewrap(t *testing.T) {
+	c, _, _ := TestCoreUnsealed(t)
+	c.rotateDuringRewrap = RotateDuringRewrapReject
+
+	c.beginRewrap()
+	if _, err := c.rotate(); err != ErrRewrapInProgress {
+		t.Fatalf("err: %v", err)
+	}
+	c.endRewrap()
+
+	if _, err := c.rotate(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestCore_Rotate_InterleavedRewrap(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+	me := &MountEntry{
+		Path: "foo",
+		Type: "generic",
+	}
+	if err := c.mount(me); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 20; i++ {
+		req := logical.TestRequest(t, logical.WriteOperation, fmt.Sprintf("foo/test%d", i))
+		req.Data["value"] = fmt.Sprintf("value%d", i)
+		req.ClientToken = root
+		if _, err := c.HandleRequest(req); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+
+	// Interleave rewraps and rotations
+	var wg sync.WaitGroup
+	errCh := make(chan error, 10)
+	for i := 0; i < 5; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if err := c.rewrapMount("foo"); err != nil {
+				errCh <- err
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if _, err := c.rotate(); err != nil {
+				errCh <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errCh)
+	for err := range errCh {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The data is decryptable after any order of operations
+	for i := 0; i < 20; i++ {
+		req := logical.TestRequest(t, logical.ReadOperation, fmt.Sprintf("foo/test%d", i))
+		req.ClientToken = root
+		resp, err := c.HandleRequest(req)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if resp == nil || resp.Data["value"] != fmt.Sprintf("value%d", i) {
+			t.Fatalf("bad: %#v", resp)
+		}
+	}
+}
+
+func TestCore_Rotate_Sealed(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+	if err := c.Seal(root); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := c.Rotate(); err != ErrSealed {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestCore_Rotate_Tables(t *testing.T) {
+	c, key, root := TestCoreUnsealed(t)
+	c.credentialBackends["noop"] = func(*logical.BackendConfig) (logical.Backend, error) {
+		return &NoopBackend{}, nil
+	}
+	if _, err := c.enableCredential(&MountEntry{Path: "before", Type: "noop"}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	before := c.barrier.(*AESGCMBarrier).keyring.ActiveTerm()
+
+	if err := c.Rotate(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if term := c.barrier.(*AESGCMBarrier).keyring.ActiveTerm(); term != before+1 {
+		t.Fatalf("bad: %d", term)
+	}
+
+	// Written under the new term
+	if _, err := c.enableCredential(&MountEntry{Path: "after", Type: "noop"}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	mounts := len(c.mounts.Entries)
+
+	// Both terms decrypt after a seal and unseal
+	if err := c.Seal(root); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if unseal, err := c.Unseal(key); err != nil || !unseal {
+		t.Fatalf("err: %v", err)
+	}
+	for _, path := range []string{"auth/before/", "auth/after/"} {
+		if match := c.router.MatchingMount(path + "foo"); match != path {
+			t.Fatalf("bad: %s", match)
+		}
+	}
+	if len(c.auth.Entries) != 3 || len(c.mounts.Entries) != mounts {
+		t.Fatalf("bad: %#v %#v", c.auth.Entries, c.mounts.Entries)
+	}
+}
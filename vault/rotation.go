@@ -0,0 +1,337 @@
+package vault
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/armon/go-metrics"
+	"github.com/hashicorp/vault/logical"
+)
+
+const (
+	// rotationSubPath is the sub-path used for the rotation manager
+	// view. This is nested under the system view.
+	rotationSubPath = "rotation/"
+
+	// rotationPeriod is how often we check for credentials due for rotation
+	rotationPeriod = time.Minute
+)
+
+// RotationManager is responsible for rotating static credentials held
+// by logical backends on a schedule.
+//
+// Each scheduled path is rotated by issuing a logical.RotateOperation to
+// the backend mounted at that path once the configured interval has
+// elapsed since the last rotation. The time of the last rotation is
+// persisted so that the schedule resumes correctly after a restart.
+// The manager is only run on the active node.
+type RotationManager struct {
+	router *Router
+	view   *BarrierView
+	logger *log.Logger
+	period time.Duration
+
+	// now is used to get the current time, and can be replaced for testing
+	now func() time.Time
+
+	entries     map[string]*rotationEntry
+	entriesLock sync.Mutex
+
+	doneCh       chan struct{}
+	shutdown     bool
+	shutdownCh   chan struct{}
+	shutdownLock sync.Mutex
+}
+
+// rotationEntry is used to track the schedule of a single path
+type rotationEntry struct {
+	Path         string        `json:"path"`
+	Interval     time.Duration `json:"interval"`
+	LastRotation time.Time     `json:"last_rotation"`
+}
+
+// RotationInfo is the information about a scheduled rotation
+type RotationInfo struct {
+	Path         string
+	Interval     time.Duration
+	LastRotation time.Time
+	NextRotation time.Time
+}
+
+// NewRotationManager is used to create a new rotation manager backed
+// using a given view, and using the provided router for rotation.
+func NewRotationManager(router *Router, view *BarrierView, logger *log.Logger) *RotationManager {
+	if logger == nil {
+		logger = log.New(os.Stderr, "", log.LstdFlags)
+	}
+	m := &RotationManager{
+		router:     router,
+		view:       view,
+		logger:     logger,
+		period:     rotationPeriod,
+		now:        time.Now,
+		entries:    make(map[string]*rotationEntry),
+		doneCh:     make(chan struct{}),
+		shutdownCh: make(chan struct{}),
+	}
+	return m
+}
+
+// Restore is used to recover the rotation schedule when starting
+func (m *RotationManager) Restore() error {
+	m.entriesLock.Lock()
+	defer m.entriesLock.Unlock()
+
+	existing, err := CollectKeys(m.view)
+	if err != nil {
+		return fmt.Errorf("failed to scan for rotations: %v", err)
+	}
+	for _, key := range existing {
+		raw, err := m.view.Get(key)
+		if err != nil {
+			return fmt.Errorf("failed to read rotation entry: %v", err)
+		}
+		if raw == nil {
+			continue
+		}
+		var re rotationEntry
+		if err := json.Unmarshal(raw.Value, &re); err != nil {
+			return fmt.Errorf("failed to decode rotation entry: %v", err)
+		}
+		m.entries[re.Path] = &re
+	}
+	if len(m.entries) > 0 {
+		m.logger.Printf("[INFO] rotation: restored %d scheduled rotations", len(m.entries))
+	}
+	return nil
+}
+
+// Start starts the rotation manager
+func (m *RotationManager) Start() {
+	go m.run()
+}
+
+// Stop stops the running manager. This will wait for any in-flight
+// rotation to complete.
+func (m *RotationManager) Stop() {
+	m.shutdownLock.Lock()
+	defer m.shutdownLock.Unlock()
+	if !m.shutdown {
+		m.shutdown = true
+		close(m.shutdownCh)
+		<-m.doneCh
+	}
+}
+
+// run is a long running routine to periodically trigger rotations
+func (m *RotationManager) run() {
+	m.logger.Printf("[INFO] rotation: starting rotation manager")
+	tick := time.NewTicker(m.period)
+	defer tick.Stop()
+	defer close(m.doneCh)
+	for {
+		select {
+		case <-tick.C:
+			m.triggerRotations()
+
+		case <-m.shutdownCh:
+			m.logger.Printf("[INFO] rotation: stopping rotation manager")
+			return
+		}
+	}
+}
+
+// triggerRotations is used to rotate every path that is due
+func (m *RotationManager) triggerRotations() {
+	now := m.now()
+
+	// Copy the due entries so that the lock is not held while the
+	// backends rotate, which can mean calls to remote systems
+	m.entriesLock.Lock()
+	var due []string
+	for path, re := range m.entries {
+		if now.Before(re.LastRotation.Add(re.Interval)) {
+			continue
+		}
+		due = append(due, path)
+	}
+	m.entriesLock.Unlock()
+
+	for _, path := range due {
+		if err := m.rotate(path, now); err != nil {
+			m.logger.Printf("[ERR] rotation: error rotating %s: %v", path, err)
+		}
+	}
+}
+
+// rotate invokes a RotateOperation for the path and records the
+// rotation time. The time is not recorded if the path was unscheduled
+// during the rotation.
+func (m *RotationManager) rotate(path string, now time.Time) error {
+	defer metrics.MeasureSince([]string{"rotation", "rotate", strings.Replace(path, "/", "-", -1)}, time.Now())
+	req := &logical.Request{
+		Operation: logical.RotateOperation,
+		Path:      path,
+	}
+	resp, err := m.router.Route(req)
+	if err != nil {
+		return err
+	}
+	if resp != nil && resp.IsError() {
+		return fmt.Errorf("%v", resp.Data["error"])
+	}
+
+	m.entriesLock.Lock()
+	defer m.entriesLock.Unlock()
+
+	re, ok := m.entries[path]
+	if !ok {
+		return nil
+	}
+	updated := *re
+	updated.LastRotation = now
+	if err := m.persistEntry(&updated); err != nil {
+		return err
+	}
+	*re = updated
+	return nil
+}
+
+// Schedule is used to rotate the given path at the interval. The first
+// rotation happens once the interval has elapsed. Rescheduling an
+// existing path keeps the time of its last rotation.
+func (m *RotationManager) Schedule(path string, interval time.Duration) error {
+	if interval <= 0 {
+		return fmt.Errorf("rotation interval must be positive")
+	}
+	if m.router.MatchingMount(path) == "" {
+		return fmt.Errorf("no matching mount for '%s'", path)
+	}
+	if !m.router.HandlesOperation(logical.RotateOperation, path) {
+		return fmt.Errorf("backend mounted at '%s' does not support rotation", path)
+	}
+
+	m.entriesLock.Lock()
+	defer m.entriesLock.Unlock()
+
+	re := &rotationEntry{
+		Path:         path,
+		Interval:     interval,
+		LastRotation: m.now(),
+	}
+	if existing, ok := m.entries[path]; ok {
+		re.LastRotation = existing.LastRotation
+	}
+	if err := m.persistEntry(re); err != nil {
+		return err
+	}
+	m.entries[path] = re
+	return nil
+}
+
+// Unschedule is used to stop rotating the given path
+func (m *RotationManager) Unschedule(path string) error {
+	m.entriesLock.Lock()
+	defer m.entriesLock.Unlock()
+
+	if err := m.view.Delete(path); err != nil {
+		return fmt.Errorf("failed to delete rotation entry: %v", err)
+	}
+	delete(m.entries, path)
+	return nil
+}
+
+// UnschedulePrefix is used to stop rotating every path under the given
+// prefix, when the mount is removed or moved
+func (m *RotationManager) UnschedulePrefix(prefix string) error {
+	m.entriesLock.Lock()
+	defer m.entriesLock.Unlock()
+
+	for path := range m.entries {
+		if !strings.HasPrefix(path, prefix) {
+			continue
+		}
+		if err := m.view.Delete(path); err != nil {
+			return fmt.Errorf("failed to delete rotation entry: %v", err)
+		}
+		delete(m.entries, path)
+	}
+	return nil
+}
+
+// Lookup is used to get the schedule of a path. If the path is not
+// scheduled, nil is returned.
+func (m *RotationManager) Lookup(path string) *RotationInfo {
+	m.entriesLock.Lock()
+	defer m.entriesLock.Unlock()
+
+	re, ok := m.entries[path]
+	if !ok {
+		return nil
+	}
+	return &RotationInfo{
+		Path:         re.Path,
+		Interval:     re.Interval,
+		LastRotation: re.LastRotation,
+		NextRotation: re.LastRotation.Add(re.Interval),
+	}
+}
+
+// List is used to get the sorted scheduled paths
+func (m *RotationManager) List() []string {
+	m.entriesLock.Lock()
+	defer m.entriesLock.Unlock()
+
+	paths := make([]string, 0, len(m.entries))
+	for path := range m.entries {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// persistEntry is used to persist a rotation entry
+func (m *RotationManager) persistEntry(re *rotationEntry) error {
+	buf, err := json.Marshal(re)
+	if err != nil {
+		return fmt.Errorf("failed to encode rotation entry: %v", err)
+	}
+	ent := &logical.StorageEntry{
+		Key:   re.Path,
+		Value: buf,
+	}
+	if err := m.view.Put(ent); err != nil {
+		return fmt.Errorf("failed to persist rotation entry: %v", err)
+	}
+	return nil
+}
+
+// The methods below are the hooks from core that are called pre/post seal.
+
+// startRotation is used to restore and start the rotation manager
+// after unsealing
+func (c *Core) startRotation() error {
+	view := c.systemBarrierView.SubView(rotationSubPath)
+	mgr := NewRotationManager(c.router, view, c.logger)
+	if err := mgr.Restore(); err != nil {
+		return fmt.Errorf("rotation state restore failed: %v", err)
+	}
+	c.rotation = mgr
+	c.rotation.Start()
+	return nil
+}
+
+// stopRotation is used to stop the rotation manager before sealing
+func (c *Core) stopRotation() error {
+	if c.rotation != nil {
+		c.rotation.Stop()
+		c.rotation = nil
+	}
+	return nil
+}
@@ -0,0 +1,250 @@
+package vault
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+// rotatingBackend stores a new credential for every rotate request
+type rotatingBackend struct {
+	NoopBackend
+
+	rotations int
+	lock      sync.Mutex
+
+	// onRotate is called at the start of every rotation when set
+	onRotate func()
+}
+
+func (b *rotatingBackend) HandleRequest(req *logical.Request) (*logical.Response, error) {
+	if req.Operation != logical.RotateOperation {
+		return b.NoopBackend.HandleRequest(req)
+	}
+	if b.onRotate != nil {
+		b.onRotate()
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.rotations++
+	err := req.Storage.Put(&logical.StorageEntry{
+		Key:   req.Path,
+		Value: []byte(fmt.Sprintf("secret-%d", b.rotations)),
+	})
+	return nil, err
+}
+
+func (b *rotatingBackend) HandlesOperation(op logical.Operation, path string) bool {
+	return op == logical.RotateOperation
+}
+
+func testRotationCore(t *testing.T) (*Core, []byte, *rotatingBackend) {
+	c, key, _ := TestCoreUnsealed(t)
+	backend := &rotatingBackend{}
+	c.logicalBackends["rotating"] = func(*logical.BackendConfig) (logical.Backend, error) {
+		return backend, nil
+	}
+	me := &MountEntry{
+		Path: "db/",
+		Type: "rotating",
+	}
+	if err := c.mount(me); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	return c, key, backend
+}
+
+func TestRotationManager_Rotate(t *testing.T) {
+	c, _, backend := testRotationCore(t)
+
+	now := time.Now()
+	c.rotation.now = func() time.Time { return now }
+	if err := c.rotation.Schedule("db/static-roles/app", time.Hour); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Nothing is due yet
+	now = now.Add(30 * time.Minute)
+	c.rotation.triggerRotations()
+	if backend.rotations != 0 {
+		t.Fatalf("bad: %d", backend.rotations)
+	}
+
+	// Advance past the interval
+	now = now.Add(time.Hour)
+	c.rotation.triggerRotations()
+	if backend.rotations != 1 {
+		t.Fatalf("bad: %d", backend.rotations)
+	}
+
+	// The new credential should be stored by the backend
+	view := c.router.MatchingStorageView("db/static-roles/app")
+	out, err := view.Get("static-roles/app")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if out == nil || string(out.Value) != "secret-1" {
+		t.Fatalf("bad: %#v", out)
+	}
+
+	info := c.rotation.Lookup("db/static-roles/app")
+	if info == nil {
+		t.Fatalf("missing rotation")
+	}
+	if !info.LastRotation.Equal(now) || !info.NextRotation.Equal(now.Add(time.Hour)) {
+		t.Fatalf("bad: %#v", info)
+	}
+}
+
+func TestRotationManager_Restore(t *testing.T) {
+	c, key, _ := testRotationCore(t)
+
+	now := time.Now().Add(-90 * time.Minute)
+	c.rotation.now = func() time.Time { return now }
+	if err := c.rotation.Schedule("db/static-roles/app", time.Hour); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Start a second core with same physical
+	conf := &CoreConfig{
+		Physical:     c.physical,
+		DisableMlock: true,
+	}
+	c2, err := NewCore(conf)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	backend := &rotatingBackend{}
+	c2.logicalBackends["rotating"] = func(*logical.BackendConfig) (logical.Backend, error) {
+		return backend, nil
+	}
+	if _, err := c2.Unseal(key); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The last rotation time should be restored
+	info := c2.rotation.Lookup("db/static-roles/app")
+	if info == nil {
+		t.Fatalf("missing rotation")
+	}
+	if !info.LastRotation.Equal(now) {
+		t.Fatalf("bad: %#v", info)
+	}
+
+	// The rotation is overdue after the restart
+	c2.rotation.triggerRotations()
+	if backend.rotations != 1 {
+		t.Fatalf("bad: %d", backend.rotations)
+	}
+}
+
+func TestRotationManager_Schedule_Invalid(t *testing.T) {
+	c, _, _ := testRotationCore(t)
+
+	if err := c.rotation.Schedule("db/static-roles/app", 0); err == nil {
+		t.Fatalf("expected error")
+	}
+	if err := c.rotation.Schedule("missing/static-roles/app", time.Hour); err == nil {
+		t.Fatalf("expected error")
+	}
+
+	// The generic backend does not support rotation
+	if err := c.rotation.Schedule("secret/foo", time.Hour); err == nil {
+		t.Fatalf("expected error")
+	}
+	if paths := c.rotation.List(); len(paths) != 0 {
+		t.Fatalf("bad: %v", paths)
+	}
+}
+
+func TestRotationManager_Unschedule(t *testing.T) {
+	c, _, backend := testRotationCore(t)
+
+	now := time.Now()
+	c.rotation.now = func() time.Time { return now }
+	if err := c.rotation.Schedule("db/static-roles/app", time.Hour); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := c.rotation.Unschedule("db/static-roles/app"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	now = now.Add(2 * time.Hour)
+	c.rotation.triggerRotations()
+	if backend.rotations != 0 {
+		t.Fatalf("bad: %d", backend.rotations)
+	}
+	if info := c.rotation.Lookup("db/static-roles/app"); info != nil {
+		t.Fatalf("bad: %#v", info)
+	}
+}
+
+func TestRotationManager_UnscheduleDuringRotation(t *testing.T) {
+	c, _, backend := testRotationCore(t)
+
+	now := time.Now()
+	c.rotation.now = func() time.Time { return now }
+	if err := c.rotation.Schedule("db/static-roles/app", time.Hour); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The schedule must not be locked while the backend rotates
+	backend.onRotate = func() {
+		if err := c.rotation.Unschedule("db/static-roles/app"); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+	now = now.Add(2 * time.Hour)
+	c.rotation.triggerRotations()
+	if backend.rotations != 1 {
+		t.Fatalf("bad: %d", backend.rotations)
+	}
+
+	// Recording the rotation should not schedule the path again
+	if info := c.rotation.Lookup("db/static-roles/app"); info != nil {
+		t.Fatalf("bad: %#v", info)
+	}
+	out, err := c.rotation.view.Get("db/static-roles/app")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if out != nil {
+		t.Fatalf("bad: %#v", out)
+	}
+}
+
+func TestRotationManager_Unmount(t *testing.T) {
+	c, _, _ := testRotationCore(t)
+	if err := c.mount(&MountEntry{Path: "db2/", Type: "rotating"}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for _, path := range []string{"db/static-roles/app", "db2/static-roles/app"} {
+		if err := c.rotation.Schedule(path, time.Hour); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+
+	// Unmounting and remounting stop the rotations of the old path
+	if err := c.unmount("db/"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := c.remount("db2/", "db3/"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if paths := c.rotation.List(); len(paths) != 0 {
+		t.Fatalf("bad: %v", paths)
+	}
+
+	// The entries are not restored either
+	keys, err := CollectKeys(c.rotation.view)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(keys) != 0 {
+		t.Fatalf("bad: %v", keys)
+	}
+}
@@ -2,8 +2,11 @@ package vault
 
 import (
 	"fmt"
+	"log"
+	"net/http"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/armon/go-metrics"
@@ -12,29 +15,320 @@ import (
 	"github.com/hashicorp/vault/logical"
 )
 
+var (
+	// ErrMountQuarantined is returned when routing a request to a mount
+	// that has been quarantined
+	ErrMountQuarantined = logical.NewUnavailable("mount quarantined")
+
+	// ErrTooManyConcurrentRequests is returned when routing a request
+	// for a token that already has the maximum number of requests
+	// in flight
+	ErrTooManyConcurrentRequests = logical.NewRateLimited("too many concurrent requests")
+
+	// ErrBackendNotReady is returned when routing a request to a backend
+	// that is still initializing
+	ErrBackendNotReady = logical.NewUnavailable("backend not ready")
+
+	// ErrBackendInitFailed is returned when routing a request to a
+	// backend that did not initialize in time
+	ErrBackendInitFailed = logical.NewUnavailable("backend failed to initialize")
+
+	// ErrOperationDisabled is returned when routing a request for an
+	// operation that has been disabled by the configuration
+	ErrOperationDisabled = logical.NewVaultError(
+		logical.ErrCodeUnsupportedOp, http.StatusMethodNotAllowed, false, "operation disabled")
+
+	// ErrGlobalRateLimitExceeded is returned when routing a request
+	// while the global request rate is above the configured limit
+	ErrGlobalRateLimitExceeded = logical.NewRateLimited("global rate limit exceeded")
+)
+
 // Router is used to do prefix based routing of a request to a logical backend
 type Router struct {
 	l              sync.RWMutex
 	root           *radix.Tree
 	tokenStoreSalt *salt.Salt
+
+	// tokenLimit is the maximum number of requests a single token can
+	// have in flight, with inflight tracking the current count per token.
+	// A zero limit disables the check.
+	tokenLimit   int
+	inflight     map[string]int
+	inflightLock sync.Mutex
+
+	// disabledOps are the operations rejected for every path
+	disabledOps map[logical.Operation]bool
+
+	// rateLimiter limits the rate of client requests, ahead of any other
+	// limit. It is nil if there is no global limit.
+	rateLimiter *rateLimiter
+
+	// logger is used to log requests at the debug level. The global
+	// logLevel can be overridden by the LogLevel of a mount.
+	logger   *log.Logger
+	logLevel string
+
+	// tracer emits spans around resolving the mount of a request and
+	// handling it in the backend
+	tracer Tracer
 }
 
 // NewRouter returns a new router
 func NewRouter() *Router {
 	r := &Router{
-		root: radix.New(),
+		root:     radix.New(),
+		inflight: make(map[string]int),
+		tracer:   noopTracer{},
 	}
 	return r
 }
 
+// withoutMounts returns a new router without any mounts, keeping the
+// settings of the router
+func (r *Router) withoutMounts() *Router {
+	r.l.RLock()
+	defer r.l.RUnlock()
+	r.inflightLock.Lock()
+	defer r.inflightLock.Unlock()
+
+	n := NewRouter()
+	n.tokenLimit = r.tokenLimit
+	n.disabledOps = r.disabledOps
+	n.rateLimiter = r.rateLimiter
+	n.logger = r.logger
+	n.logLevel = r.logLevel
+	n.tracer = r.tracer
+	return n
+}
+
+// SetTokenConcurrencyLimit sets the maximum number of requests a single
+// token can have in flight. A limit of zero disables the check.
+func (r *Router) SetTokenConcurrencyLimit(limit int) {
+	r.inflightLock.Lock()
+	defer r.inflightLock.Unlock()
+	r.tokenLimit = limit
+}
+
+// SetDisabledOperations sets the operations that are rejected for every
+// path, regardless of the backend or policy
+func (r *Router) SetDisabledOperations(ops []logical.Operation) {
+	disabled := make(map[logical.Operation]bool, len(ops))
+	for _, op := range ops {
+		disabled[op] = true
+	}
+
+	r.l.Lock()
+	defer r.l.Unlock()
+	r.disabledOps = disabled
+}
+
+// SetGlobalRateLimit limits the rate of client requests to rate per
+// second, allowing bursts of up to burst requests. A rate of zero
+// disables the limit. Requests made by the core itself, such as lease
+// revocations and rollbacks, are never limited, nor are the health and
+// seal status endpoints.
+func (r *Router) SetGlobalRateLimit(rate, burst int) {
+	var limiter *rateLimiter
+	if rate > 0 {
+		limiter = newRateLimiter(rate, burst)
+	}
+
+	r.l.Lock()
+	defer r.l.Unlock()
+	r.rateLimiter = limiter
+}
+
+// allowClientRequest takes a client request from the global rate limit,
+// returning false if the limit is exceeded
+func (r *Router) allowClientRequest() bool {
+	r.l.RLock()
+	limiter := r.rateLimiter
+	r.l.RUnlock()
+	return limiter == nil || limiter.allow()
+}
+
+// SetTracer sets the Tracer used to emit spans around requests. A nil
+// tracer emits nothing.
+func (r *Router) SetTracer(tracer Tracer) {
+	if tracer == nil {
+		tracer = noopTracer{}
+	}
+
+	r.l.Lock()
+	defer r.l.Unlock()
+	r.tracer = tracer
+}
+
+// SetRequestLogger sets the logger used to log requests and the global
+// log level. Requests are logged at debug if the level of their mount,
+// or the global level if the mount does not set one, is debug or lower.
+// The logger must not filter by level itself, or mounts that lower their
+// level below the global level are not logged.
+func (r *Router) SetRequestLogger(logger *log.Logger, level string) {
+	level = strings.ToLower(level)
+	if logLevelIndex(level) < 0 {
+		level = "info"
+	}
+
+	r.l.Lock()
+	defer r.l.Unlock()
+	r.logger = logger
+	r.logLevel = level
+}
+
+// logRequest is used to log the detail of a routed request at debug if
+// the effective level of its mount allows it
+func (r *Router) logRequest(re *routeEntry, req *logical.Request, path string, err error) {
+	r.l.RLock()
+	logger, global := r.logger, r.logLevel
+	r.l.RUnlock()
+	if logger == nil {
+		return
+	}
+
+	level := re.mountEntry.Config.LogLevel
+	if level == "" {
+		level = global
+	}
+	if logLevelIndex(level) > logLevelIndex("debug") {
+		return
+	}
+
+	logger.Printf("[DEBUG] router: request operation=%s path=%s mount=%s remote_addr=%s err=%v",
+		req.Operation, path, req.MountPoint, req.RemoteAddr, err)
+}
+
+// rollbackRequest is used to invoke the compensating actions registered
+// by the backend for a failed request, in reverse order. Every action
+// is attempted; failures are logged.
+func (r *Router) rollbackRequest(req *logical.Request, path string) {
+	r.l.RLock()
+	logger := r.logger
+	r.l.RUnlock()
+
+	for i := len(req.Rollbacks) - 1; i >= 0; i-- {
+		if err := req.Rollbacks[i](); err != nil && logger != nil {
+			logger.Printf("[ERR] router: rollback %d of failed request to '%s' failed: %v",
+				i+1, path, err)
+		}
+	}
+}
+
+// logLevels are the supported log levels, from the most to the
+// least verbose
+var logLevels = []string{"trace", "debug", "info", "warn", "err"}
+
+// logLevelIndex returns the verbosity rank of a log level, or -1 if
+// the level is unknown
+func logLevelIndex(level string) int {
+	level = strings.ToLower(level)
+	for i, l := range logLevels {
+		if l == level {
+			return i
+		}
+	}
+	return -1
+}
+
+// acquireToken reserves an in-flight slot for the token, returning
+// false if the token is already at the limit
+func (r *Router) acquireToken(token string) bool {
+	r.inflightLock.Lock()
+	defer r.inflightLock.Unlock()
+	if r.tokenLimit > 0 && r.inflight[token] >= r.tokenLimit {
+		return false
+	}
+	r.inflight[token]++
+	return true
+}
+
+// releaseToken releases an in-flight slot reserved by acquireToken
+func (r *Router) releaseToken(token string) {
+	r.inflightLock.Lock()
+	defer r.inflightLock.Unlock()
+	r.inflight[token]--
+	if r.inflight[token] <= 0 {
+		delete(r.inflight, token)
+	}
+}
+
 // routeEntry is used to represent a mount point in the router
 type routeEntry struct {
 	tainted     bool
+	initErr     error
 	backend     logical.Backend
 	mountEntry  *MountEntry
 	storageView *BarrierView
 	rootPaths   *radix.Tree
 	loginPaths  *radix.Tree
+
+	// deprecatedPaths maps deprecated paths to their deprecatedPath
+	deprecatedPaths *radix.Tree
+
+	// lastAccessed is the time of the last client request to the mount
+	// in nanoseconds, or the time it was mounted. Updated atomically.
+	lastAccessed int64
+
+	// responseCache holds the responses of reads, if the mount has a
+	// response cache TTL
+	responseCache *responseCache
+
+	// inflightLock protects quarantined and the requests in flight to
+	// the mount. idleCh, if set, is closed once none are in flight.
+	inflightLock sync.Mutex
+	quarantined  bool
+	inflight     int
+	idleCh       chan struct{}
+}
+
+// enter is used to track a request to the mount as in flight. It
+// returns false if the mount is quarantined.
+func (re *routeEntry) enter() bool {
+	re.inflightLock.Lock()
+	defer re.inflightLock.Unlock()
+	if re.quarantined {
+		return false
+	}
+	re.inflight++
+	return true
+}
+
+// exit is used to release a request tracked by enter
+func (re *routeEntry) exit() {
+	re.inflightLock.Lock()
+	defer re.inflightLock.Unlock()
+	re.inflight--
+	if re.inflight == 0 && re.idleCh != nil {
+		close(re.idleCh)
+		re.idleCh = nil
+	}
+}
+
+// responseCacheTTL returns how long responses of the mount are cached,
+// or zero if they are not
+func (re *routeEntry) responseCacheTTL() time.Duration {
+	if re.mountEntry == nil {
+		return 0
+	}
+	return re.mountEntry.Config.ResponseCacheTTL
+}
+
+// updateResponseCache caches the response of a read, or invalidates the
+// cached responses affected by any other operation
+func (re *routeEntry) updateResponseCache(req *logical.Request, resp *logical.Response, err error, generation uint64) {
+	switch req.Operation {
+	case logical.ReadOperation:
+		ttl := re.responseCacheTTL()
+		if ttl > 0 && cacheableResponse(resp, err) {
+			re.responseCache.put(req.Path, req.ClientToken, resp, generation, time.Now().Add(ttl))
+		}
+	case logical.ListOperation, logical.HelpOperation:
+	default:
+		// A write may change what is read at other paths of the mount,
+		// such as the credentials generated for a role, so every response
+		// of the mount is dropped
+		re.responseCache.clear()
+	}
 }
 
 // SaltID is used to apply a salt and hash to an ID to make sure its not reversable
@@ -43,14 +337,15 @@ func (re *routeEntry) SaltID(id string) string {
 }
 
 // Mount is used to expose a logical backend at a given prefix, using a unique salt,
-// and the barrier view for that path.
+// and the barrier view for that path. A prefix may be nested under another
+// mount, in which case requests are routed to the longest matching prefix.
 func (r *Router) Mount(backend logical.Backend, prefix string, mountEntry *MountEntry, storageView *BarrierView) error {
 	r.l.Lock()
 	defer r.l.Unlock()
 
-	// Check if this is a nested mount
-	if existing, _, ok := r.root.LongestPrefix(prefix); ok && existing != "" {
-		return fmt.Errorf("cannot mount under existing mount '%s'", existing)
+	// Check if this duplicates a mount
+	if _, ok := r.root.Get(prefix); ok {
+		return fmt.Errorf("cannot mount at existing mount '%s'", prefix)
 	}
 
 	// Build the paths
@@ -67,13 +362,18 @@ func (r *Router) Mount(backend logical.Backend, prefix string, mountEntry *Mount
 		storageView: storageView,
 		rootPaths:   pathsToRadix(paths.Root),
 		loginPaths:  pathsToRadix(paths.Unauthenticated),
+
+		deprecatedPaths: deprecatedPathsToRadix(paths.Deprecated),
+		lastAccessed:    time.Now().UnixNano(),
+		responseCache:   newResponseCache(),
 	}
 	r.root.Insert(prefix, re)
 
 	return nil
 }
 
-// Unmount is used to remove a logical backend from a given prefix
+// Unmount is used to remove a logical backend from a given prefix. Mounts
+// nested under the prefix are kept.
 func (r *Router) Unmount(prefix string) error {
 	r.l.Lock()
 	defer r.l.Unlock()
@@ -104,6 +404,55 @@ func (r *Router) Remount(src, dst string) error {
 	return nil
 }
 
+// Swap is used to exchange the backends mounted at two paths. The mount
+// entries of the backends at a and b are replaced by entryA and entryB,
+// which hold their new paths.
+func (r *Router) Swap(a, b string, entryA, entryB *MountEntry) error {
+	r.l.Lock()
+	defer r.l.Unlock()
+
+	// Check for existing mounts
+	rawA, ok := r.root.Get(a)
+	if !ok {
+		return fmt.Errorf("no mount at '%s'", a)
+	}
+	rawB, ok := r.root.Get(b)
+	if !ok {
+		return fmt.Errorf("no mount at '%s'", b)
+	}
+
+	// Update the mount points
+	rawA.(*routeEntry).mountEntry = entryA
+	rawB.(*routeEntry).mountEntry = entryB
+	r.root.Insert(a, rawB)
+	r.root.Insert(b, rawA)
+	return nil
+}
+
+// LastAccessed returns the time of the last client request to the mount
+// at the given path, or the time it was mounted if it was never used
+func (r *Router) LastAccessed(path string) (time.Time, bool) {
+	r.l.RLock()
+	raw, ok := r.root.Get(path)
+	r.l.RUnlock()
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(0, atomic.LoadInt64(&raw.(*routeEntry).lastAccessed)), true
+}
+
+// markAccessed records a client request to the mount serving the path.
+// Requests made by the core itself, such as rollbacks, are not recorded,
+// so that they do not keep unused mounts fresh.
+func (r *Router) markAccessed(path string) {
+	r.l.RLock()
+	_, raw, ok := r.root.LongestPrefix(path)
+	r.l.RUnlock()
+	if ok {
+		atomic.StoreInt64(&raw.(*routeEntry).lastAccessed, time.Now().UnixNano())
+	}
+}
+
 // Taint is used to mark a path as tainted. This means only RollbackOperation
 // RenewOperation requests are allowed to proceed
 func (r *Router) Taint(path string) error {
@@ -116,6 +465,79 @@ func (r *Router) Taint(path string) error {
 	return nil
 }
 
+// Quarantine is used to mark or unmark a path as quarantined. Requests to
+// a quarantined path are rejected without reaching the backend.
+func (r *Router) Quarantine(path string, on bool) error {
+	r.l.Lock()
+	defer r.l.Unlock()
+	_, raw, ok := r.root.LongestPrefix(path)
+	if ok {
+		re := raw.(*routeEntry)
+		re.inflightLock.Lock()
+		re.quarantined = on
+		re.inflightLock.Unlock()
+	}
+	return nil
+}
+
+// Drain is used to wait until no request is in flight to the mount of a
+// path. It is used once the mount is quarantined, so that no request
+// remains that could still read or write its storage. An error is
+// returned if requests are still in flight after the timeout.
+func (r *Router) Drain(path string, timeout time.Duration) error {
+	r.l.RLock()
+	_, raw, ok := r.root.LongestPrefix(path)
+	r.l.RUnlock()
+	if !ok {
+		return nil
+	}
+
+	re := raw.(*routeEntry)
+	re.inflightLock.Lock()
+	if re.inflight == 0 {
+		re.inflightLock.Unlock()
+		return nil
+	}
+	if re.idleCh == nil {
+		re.idleCh = make(chan struct{})
+	}
+	idleCh := re.idleCh
+	re.inflightLock.Unlock()
+
+	select {
+	case <-idleCh:
+		return nil
+	case <-time.After(timeout):
+		return fmt.Errorf("requests to '%s' still in flight after %s", path, timeout)
+	}
+}
+
+// SetInitError is used to mark the backend at a path as not ready to
+// serve requests, returning the given error for every request. A nil
+// error marks the backend as ready.
+func (r *Router) SetInitError(path string, err error) error {
+	r.l.Lock()
+	defer r.l.Unlock()
+	raw, ok := r.root.Get(path)
+	if ok {
+		raw.(*routeEntry).initErr = err
+	}
+	return nil
+}
+
+// ClearResponseCache is used to remove the cached responses of the
+// mount at the given path
+func (r *Router) ClearResponseCache(path string) error {
+	r.l.Lock()
+	defer r.l.Unlock()
+	_, raw, ok := r.root.LongestPrefix(path)
+	if !ok {
+		return fmt.Errorf("no mount for path '%s'", path)
+	}
+	raw.(*routeEntry).responseCache.clear()
+	return nil
+}
+
 // Untaint is used to unmark a path as tainted.
 func (r *Router) Untaint(path string) error {
 	r.l.Lock()
@@ -127,7 +549,9 @@ func (r *Router) Untaint(path string) error {
 	return nil
 }
 
-// MatchingMount returns the mount prefix that would be used for a path
+// MatchingMount returns the mount prefix that would be used for a path,
+// using the same longest prefix match as Route. It returns an empty
+// string if no mount matches.
 func (r *Router) MatchingMount(path string) string {
 	r.l.RLock()
 	mount, _, ok := r.root.LongestPrefix(path)
@@ -138,6 +562,23 @@ func (r *Router) MatchingMount(path string) string {
 	return mount
 }
 
+// HandlesOperation checks if the backend mounted at the path handles the
+// operation on it. Backends that do not implement
+// logical.OperationChecker are assumed not to.
+func (r *Router) HandlesOperation(op logical.Operation, path string) bool {
+	r.l.RLock()
+	mount, raw, ok := r.root.LongestPrefix(path)
+	r.l.RUnlock()
+	if !ok {
+		return false
+	}
+	checker, ok := raw.(*routeEntry).backend.(logical.OperationChecker)
+	if !ok {
+		return false
+	}
+	return checker.HandlesOperation(op, strings.TrimPrefix(path, mount))
+}
+
 // MatchingView returns the view used for a path
 func (r *Router) MatchingStorageView(path string) *BarrierView {
 	r.l.RLock()
@@ -184,8 +625,22 @@ func (r *Router) MatchingSystemView(path string) logical.SystemView {
 
 // Route is used to route a given request
 func (r *Router) Route(req *logical.Request) (*logical.Response, error) {
+	return r.routeRequest(req, nil)
+}
+
+// routeRequest is used to route a request. If the parent span is not nil,
+// the spans of resolving the mount and handling the request are its
+// children.
+func (r *Router) routeRequest(req *logical.Request, parent Span) (*logical.Response, error) {
 	// Find the mount point
 	r.l.RLock()
+	tracer := r.tracer
+	span := startSpan(tracer, parent, SpanResolveMount, req.Operation)
+	if r.disabledOps[req.Operation] {
+		r.l.RUnlock()
+		endSpan(span, ErrOperationDisabled)
+		return logical.ErrorResponse(fmt.Sprintf("operation disabled: '%s'", req.Operation)), ErrOperationDisabled
+	}
 	mount, raw, ok := r.root.LongestPrefix(req.Path)
 	if !ok {
 		// Re-check for a backend by appending a slash. This lets "foo" mean
@@ -194,9 +649,12 @@ func (r *Router) Route(req *logical.Request) (*logical.Response, error) {
 		mount, raw, ok = r.root.LongestPrefix(req.Path)
 	}
 	r.l.RUnlock()
+	span.SetAttribute(SpanAttrMount, mount)
 	if !ok {
+		endSpan(span, logical.ErrUnsupportedPath)
 		return logical.ErrorResponse(fmt.Sprintf("no handler for route '%s'", req.Path)), logical.ErrUnsupportedPath
 	}
+	endSpan(span, nil)
 	defer metrics.MeasureSince([]string{"route", string(req.Operation),
 		strings.Replace(mount, "/", "-", -1)}, time.Now())
 	re := raw.(*routeEntry)
@@ -211,6 +669,29 @@ func (r *Router) Route(req *logical.Request) (*logical.Response, error) {
 		}
 	}
 
+	// If the mount is quarantined, we reject everything but leave the
+	// backend mounted so it can be released later
+	if !re.enter() {
+		return logical.ErrorResponse(fmt.Sprintf("mount quarantined: '%s'", mount)), ErrMountQuarantined
+	}
+	defer re.exit()
+
+	// If the backend is not initialized, reject everything until it is
+	r.l.RLock()
+	initErr := re.initErr
+	r.l.RUnlock()
+	if initErr != nil {
+		return logical.ErrorResponse(fmt.Sprintf("%v: '%s'", initErr, mount)), initErr
+	}
+
+	// Limit the number of requests in flight for a single token
+	if req.ClientToken != "" {
+		if !r.acquireToken(req.ClientToken) {
+			return logical.ErrorResponse(ErrTooManyConcurrentRequests.Error()), ErrTooManyConcurrentRequests
+		}
+		defer r.releaseToken(req.ClientToken)
+	}
+
 	// Determine if this path is an unauthenticated path before we modify it
 	loginPath := r.LoginPath(req.Path)
 
@@ -243,6 +724,10 @@ func (r *Router) Route(req *logical.Request) (*logical.Response, error) {
 		req.Connection = nil
 	}
 
+	// The MFA payload is only used by the core to check the policies
+	mfaPayload := req.MFAPayload
+	req.MFAPayload = nil
+
 	// Reset the request before returning
 	defer func() {
 		req.Path = original
@@ -250,10 +735,101 @@ func (r *Router) Route(req *logical.Request) (*logical.Response, error) {
 		req.Connection = originalConn
 		req.Storage = nil
 		req.ClientToken = clientToken
+		req.MFAPayload = mfaPayload
+		req.Rollbacks = nil
 	}()
 
-	// Invoke the backend
-	return re.backend.HandleRequest(req)
+	// Allow the backend to reject the request before it is handled
+	if v, ok := re.backend.(logical.Validator); ok {
+		if err := v.Validate(req); err != nil {
+			return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+		}
+	}
+
+	// Serve reads from the response cache, if enabled for the mount
+	var resp *logical.Response
+	var err error
+	var cached bool
+	var cacheGeneration uint64
+	if req.Operation == logical.ReadOperation && re.responseCacheTTL() > 0 {
+		resp, cacheGeneration, cached = re.responseCache.get(req.Path, req.ClientToken, time.Now())
+	}
+
+	if !cached {
+		// Invoke the backend
+		span := startSpan(tracer, parent, SpanHandle, req.Operation)
+		span.SetAttribute(SpanAttrMount, mount)
+		resp, err = re.backend.HandleRequest(req)
+		endSpan(span, err)
+		r.logRequest(re, req, original, err)
+
+		// Undo the changes of a partially applied operation
+		if err != nil {
+			r.rollbackRequest(req, original)
+		}
+		re.updateResponseCache(req, resp, err, cacheGeneration)
+	}
+
+	// Warn about deprecated paths. A read without a response is not
+	// found, so it is left without one.
+	if warning, ok := re.deprecationWarning(mount, req.Path); ok {
+		if resp == nil && err == nil && req.Operation != logical.ReadOperation {
+			resp = &logical.Response{}
+		}
+		if resp != nil {
+			resp.AddWarning(warning)
+		}
+	}
+
+	// Give list responses a stable shape regardless of the backend so that
+	// clients can paginate uniformly
+	if req.Operation == logical.ListOperation && err == nil && resp != nil && !resp.IsError() {
+		newListResponse(resp).apply(resp)
+	}
+	return resp, err
+}
+
+// ListResponse is the normalized result of a list operation. If the
+// backend returned only part of the keys, NextToken carries the
+// continuation token for the next page and Truncated is set.
+type ListResponse struct {
+	Keys      []string `json:"keys" structs:"keys" mapstructure:"keys"`
+	NextToken string   `json:"next_token" structs:"next_token" mapstructure:"next_token"`
+	Truncated bool     `json:"truncated" structs:"truncated" mapstructure:"truncated"`
+}
+
+// newListResponse builds a ListResponse out of the data returned by a
+// backend for a list operation
+func newListResponse(resp *logical.Response) *ListResponse {
+	lr := &ListResponse{
+		Keys: []string{},
+	}
+	switch keys := resp.Data["keys"].(type) {
+	case []string:
+		if keys != nil {
+			lr.Keys = keys
+		}
+	case []interface{}:
+		for _, raw := range keys {
+			if key, ok := raw.(string); ok {
+				lr.Keys = append(lr.Keys, key)
+			}
+		}
+	}
+	lr.NextToken, _ = resp.Data["next_token"].(string)
+	lr.Truncated = lr.NextToken != ""
+	return lr
+}
+
+// apply sets the fields of the ListResponse in the response data,
+// keeping any other fields returned by the backend
+func (lr *ListResponse) apply(resp *logical.Response) {
+	if resp.Data == nil {
+		resp.Data = make(map[string]interface{})
+	}
+	resp.Data["keys"] = lr.Keys
+	resp.Data["next_token"] = lr.NextToken
+	resp.Data["truncated"] = lr.Truncated
 }
 
 // RootPath checks if the given path requires root privileges
@@ -314,6 +890,54 @@ func (r *Router) LoginPath(path string) bool {
 	return match == remain
 }
 
+// deprecatedPath is the replacement of a deprecated path
+type deprecatedPath struct {
+	prefixMatch bool
+	replacement string
+}
+
+// deprecatedPathsToRadix converts the mapping of deprecated paths to
+// their replacements to a radix tree
+func deprecatedPathsToRadix(paths map[string]string) *radix.Tree {
+	tree := radix.New()
+	for path, replacement := range paths {
+		// Check if this is a prefix or exact match
+		prefixMatch := len(path) >= 1 && path[len(path)-1] == '*'
+		if prefixMatch {
+			path = path[:len(path)-1]
+		}
+
+		tree.Insert(path, &deprecatedPath{
+			prefixMatch: prefixMatch,
+			replacement: replacement,
+		})
+	}
+
+	return tree
+}
+
+// deprecationWarning returns the warning for a request to the given path
+// relative to the mount, if the path is deprecated
+func (re *routeEntry) deprecationWarning(mount, path string) (string, bool) {
+	match, raw, ok := re.deprecatedPaths.LongestPrefix(path)
+	if !ok {
+		return "", false
+	}
+	dp := raw.(*deprecatedPath)
+	if dp.prefixMatch && !strings.HasPrefix(path, match) {
+		return "", false
+	}
+	if !dp.prefixMatch && match != path {
+		return "", false
+	}
+
+	if dp.replacement == "" {
+		return fmt.Sprintf("path '%s' is deprecated", mount+path), true
+	}
+	return fmt.Sprintf("path '%s' is deprecated; use '%s' instead",
+		mount+path, mount+dp.replacement), true
+}
+
 // pathsToRadix converts a the mapping of special paths to a mapping
 // of special paths to radix trees.
 func pathsToRadix(paths []string) *radix.Tree {
@@ -1,7 +1,10 @@
 package vault
 
 import (
+	"bytes"
 	"fmt"
+	"log"
+	"reflect"
 	"strings"
 	"sync"
 	"testing"
@@ -14,11 +17,12 @@ import (
 type NoopBackend struct {
 	sync.Mutex
 
-	Root     []string
-	Login    []string
-	Paths    []string
-	Requests []*logical.Request
-	Response *logical.Response
+	Root       []string
+	Login      []string
+	Deprecated map[string]string
+	Paths      []string
+	Requests   []*logical.Request
+	Response   *logical.Response
 }
 
 func (n *NoopBackend) HandleRequest(req *logical.Request) (*logical.Response, error) {
@@ -39,6 +43,7 @@ func (n *NoopBackend) SpecialPaths() *logical.Paths {
 	return &logical.Paths{
 		Root:            n.Root,
 		Unauthenticated: n.Login,
+		Deprecated:      n.Deprecated,
 	}
 }
 
@@ -65,7 +70,7 @@ func TestRouter_Mount(t *testing.T) {
 	}
 
 	err = r.Mount(n, "prod/aws/", &MountEntry{UUID: uuid.GenerateUUID()}, view)
-	if !strings.Contains(err.Error(), "cannot mount under existing mount") {
+	if err == nil || !strings.Contains(err.Error(), "cannot mount at existing mount") {
 		t.Fatalf("err: %v", err)
 	}
 
@@ -127,6 +132,104 @@ func TestRouter_Unmount(t *testing.T) {
 	}
 }
 
+func TestRouter_Mount_Nested(t *testing.T) {
+	r := NewRouter()
+	_, barrier, _ := mockBarrier(t)
+	view := NewBarrierView(barrier, "logical/")
+
+	parent, child := &NoopBackend{}, &NoopBackend{}
+	if err := r.Mount(parent, "secret/", &MountEntry{UUID: uuid.GenerateUUID()}, view); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := r.Mount(child, "secret/foo/", &MountEntry{UUID: uuid.GenerateUUID()}, view); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The most specific mount wins
+	if path := r.MatchingMount("secret/foo/bar"); path != "secret/foo/" {
+		t.Fatalf("bad: %s", path)
+	}
+	if path := r.MatchingMount("secret/bar"); path != "secret/" {
+		t.Fatalf("bad: %s", path)
+	}
+	for _, path := range []string{"secret/foo/bar", "secret/bar"} {
+		if _, err := r.Route(&logical.Request{Path: path}); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+	if len(child.Paths) != 1 || child.Paths[0] != "bar" {
+		t.Fatalf("bad: %v", child.Paths)
+	}
+	if len(parent.Paths) != 1 || parent.Paths[0] != "bar" {
+		t.Fatalf("bad: %v", parent.Paths)
+	}
+
+	// Unmounting the nested mount falls back to the parent
+	if err := r.Unmount("secret/foo/"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if path := r.MatchingMount("secret/foo/bar"); path != "secret/" {
+		t.Fatalf("bad: %s", path)
+	}
+	if _, err := r.Route(&logical.Request{Path: "secret/foo/bar"}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(parent.Paths) != 2 || parent.Paths[1] != "foo/bar" {
+		t.Fatalf("bad: %v", parent.Paths)
+	}
+
+	// Unmounting the parent keeps nested mounts
+	if err := r.Mount(child, "secret/foo/", &MountEntry{UUID: uuid.GenerateUUID()}, view); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := r.Unmount("secret/"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if path := r.MatchingMount("secret/foo/bar"); path != "secret/foo/" {
+		t.Fatalf("bad: %s", path)
+	}
+	if path := r.MatchingMount("secret/bar"); path != "" {
+		t.Fatalf("bad: %s", path)
+	}
+}
+
+func TestRouter_MatchingMount_Concurrent(t *testing.T) {
+	r := NewRouter()
+	_, barrier, _ := mockBarrier(t)
+	view := NewBarrierView(barrier, "logical/")
+
+	n := &NoopBackend{}
+	if err := r.Mount(n, "auth/", &MountEntry{UUID: uuid.GenerateUUID()}, view); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Lookups see either mount while the nested one comes and goes
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			r.Mount(n, "auth/github/", &MountEntry{UUID: uuid.GenerateUUID()}, view)
+			r.Unmount("auth/github/")
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		switch path := r.MatchingMount("auth/github/login"); path {
+		case "auth/", "auth/github/":
+		default:
+			t.Fatalf("bad: %s", path)
+		}
+	}
+	wg.Wait()
+
+	if path := r.MatchingMount("auth/github/login"); path != "auth/" {
+		t.Fatalf("bad: %s", path)
+	}
+	if path := r.MatchingMount("secret/foo"); path != "" {
+		t.Fatalf("bad: %s", path)
+	}
+}
+
 func TestRouter_Remount(t *testing.T) {
 	r := NewRouter()
 	_, barrier, _ := mockBarrier(t)
@@ -283,6 +386,39 @@ func TestRouter_Taint(t *testing.T) {
 	}
 }
 
+func TestRouter_SetInitError(t *testing.T) {
+	r := NewRouter()
+	_, barrier, _ := mockBarrier(t)
+	view := NewBarrierView(barrier, "logical/")
+
+	n := &NoopBackend{}
+	err := r.Mount(n, "prod/aws/", &MountEntry{UUID: uuid.GenerateUUID()}, view)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	req := &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "prod/aws/foo",
+	}
+
+	// Only the exact mount path is affected
+	r.SetInitError("prod/aws/foo/", ErrBackendNotReady)
+	if _, err := r.Route(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	r.SetInitError("prod/aws/", ErrBackendNotReady)
+	if _, err := r.Route(req); err != ErrBackendNotReady {
+		t.Fatalf("err: %v", err)
+	}
+
+	r.SetInitError("prod/aws/", nil)
+	if _, err := r.Route(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
+
 func TestRouter_Untaint(t *testing.T) {
 	r := NewRouter()
 	_, barrier, _ := mockBarrier(t)
@@ -314,6 +450,453 @@ func TestRouter_Untaint(t *testing.T) {
 	}
 }
 
+// blockingBackend holds every request until release is closed
+type blockingBackend struct {
+	NoopBackend
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingBackend) HandleRequest(req *logical.Request) (*logical.Response, error) {
+	b.started <- struct{}{}
+	<-b.release
+	return nil, nil
+}
+
+func TestRouter_TokenConcurrencyLimit(t *testing.T) {
+	r := NewRouter()
+	r.SetTokenConcurrencyLimit(2)
+	_, barrier, _ := mockBarrier(t)
+	view := NewBarrierView(barrier, "logical/")
+
+	n := &blockingBackend{
+		started: make(chan struct{}, 10),
+		release: make(chan struct{}),
+	}
+	err := r.Mount(n, "prod/aws/", &MountEntry{UUID: uuid.GenerateUUID()}, view)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	route := func(token string) error {
+		req := &logical.Request{
+			Operation:   logical.ReadOperation,
+			Path:        "prod/aws/foo",
+			ClientToken: token,
+		}
+		_, err := r.Route(req)
+		return err
+	}
+
+	// Fill up the limit for one token
+	errCh := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			errCh <- route("foo")
+		}()
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case <-n.started:
+		case <-time.After(time.Second):
+			t.Fatalf("request not started")
+		}
+	}
+
+	// The excess request should be rejected
+	for i := 0; i < 3; i++ {
+		if err := route("foo"); err != ErrTooManyConcurrentRequests {
+			t.Fatalf("err: %v", err)
+		}
+	}
+
+	// Another token is not affected
+	go func() {
+		errCh <- route("bar")
+	}()
+	select {
+	case <-n.started:
+	case <-time.After(time.Second):
+		t.Fatalf("request not started")
+	}
+
+	// Completed requests release their slots
+	close(n.release)
+	for i := 0; i < 3; i++ {
+		if err := <-errCh; err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+	if err := route("foo"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+// validatingBackend requires a "name" field on writes
+type validatingBackend struct {
+	NoopBackend
+}
+
+func (b *validatingBackend) Validate(req *logical.Request) error {
+	if req.Operation != logical.WriteOperation {
+		return nil
+	}
+	if _, ok := req.Data["name"]; !ok {
+		return fmt.Errorf("missing name")
+	}
+	return nil
+}
+
+func TestRouter_Validate(t *testing.T) {
+	r := NewRouter()
+	_, barrier, _ := mockBarrier(t)
+	view := NewBarrierView(barrier, "logical/")
+
+	n := &validatingBackend{}
+	err := r.Mount(n, "prod/aws/", &MountEntry{UUID: uuid.GenerateUUID()}, view)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Rejected before reaching the handler
+	req := &logical.Request{
+		Operation: logical.WriteOperation,
+		Path:      "prod/aws/foo",
+		Data:      map[string]interface{}{},
+	}
+	resp, err := r.Route(req)
+	if err != logical.ErrInvalidRequest {
+		t.Fatalf("err: %v", err)
+	}
+	if resp == nil || resp.Data["error"] != "missing name" {
+		t.Fatalf("bad: %#v", resp)
+	}
+	if len(n.Paths) != 0 {
+		t.Fatalf("bad: %v", n.Paths)
+	}
+	if req.Path != "prod/aws/foo" {
+		t.Fatalf("bad: %s", req.Path)
+	}
+
+	// Valid requests are handled
+	req.Data["name"] = "bar"
+	if _, err := r.Route(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !reflect.DeepEqual(n.Paths, []string{"foo"}) {
+		t.Fatalf("bad: %v", n.Paths)
+	}
+}
+
+// multiStepBackend writes a role and then its policy, failing the
+// second step. Each completed step registers a compensating action.
+type multiStepBackend struct {
+	NoopBackend
+
+	undone []string
+}
+
+func (b *multiStepBackend) HandleRequest(req *logical.Request) (*logical.Response, error) {
+	if err := req.Storage.Put(&logical.StorageEntry{Key: "role/foo"}); err != nil {
+		return nil, err
+	}
+	req.AddRollback(func() error {
+		b.undone = append(b.undone, "role/foo")
+		return req.Storage.Delete("role/foo")
+	})
+
+	if err := req.Storage.Put(&logical.StorageEntry{Key: "policy/foo"}); err != nil {
+		return nil, err
+	}
+	req.AddRollback(func() error {
+		b.undone = append(b.undone, "policy/foo")
+		return req.Storage.Delete("policy/foo")
+	})
+
+	return nil, fmt.Errorf("failed to write grant")
+}
+
+func TestRouter_Rollback(t *testing.T) {
+	r := NewRouter()
+	_, barrier, _ := mockBarrier(t)
+	view := NewBarrierView(barrier, "logical/")
+
+	n := &multiStepBackend{}
+	err := r.Mount(n, "prod/aws/", &MountEntry{UUID: uuid.GenerateUUID()}, view)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	req := &logical.Request{
+		Operation: logical.WriteOperation,
+		Path:      "prod/aws/foo",
+	}
+	if _, err := r.Route(req); err == nil || err.Error() != "failed to write grant" {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Compensated in reverse order
+	if !reflect.DeepEqual(n.undone, []string{"policy/foo", "role/foo"}) {
+		t.Fatalf("bad: %v", n.undone)
+	}
+	keys, err := CollectKeys(view)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(keys) != 0 {
+		t.Fatalf("bad: %v", keys)
+	}
+	if req.Rollbacks != nil {
+		t.Fatalf("bad: %v", req.Rollbacks)
+	}
+}
+
+func TestRouter_DeprecatedPaths(t *testing.T) {
+	r := NewRouter()
+	_, barrier, _ := mockBarrier(t)
+	view := NewBarrierView(barrier, "logical/")
+
+	n := &NoopBackend{
+		Deprecated: map[string]string{
+			"creds":    "credentials",
+			"legacy/*": "current/",
+			"removed":  "",
+		},
+	}
+	err := r.Mount(n, "prod/aws/", &MountEntry{UUID: uuid.GenerateUUID()}, view)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	cases := map[string]string{
+		"prod/aws/creds":         "path 'prod/aws/creds' is deprecated; use 'prod/aws/credentials' instead",
+		"prod/aws/legacy/foo":    "path 'prod/aws/legacy/foo' is deprecated; use 'prod/aws/current/' instead",
+		"prod/aws/removed":       "path 'prod/aws/removed' is deprecated",
+		"prod/aws/credentials":   "",
+		"prod/aws/creds/foo":     "",
+		"prod/aws/current/foo":   "",
+		"prod/aws/legacyish/foo": "",
+	}
+	for path, expected := range cases {
+		req := &logical.Request{
+			Operation: logical.WriteOperation,
+			Path:      path,
+		}
+		resp, err := r.Route(req)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if expected == "" {
+			if resp != nil {
+				t.Fatalf("bad: %s: %#v", path, resp)
+			}
+			continue
+		}
+		if resp == nil || !reflect.DeepEqual(resp.Warnings(), []string{expected}) {
+			t.Fatalf("bad: %s: %#v", path, resp)
+		}
+	}
+
+	// Deprecated paths are still served
+	if len(n.Paths) != len(cases) {
+		t.Fatalf("bad: %v", n.Paths)
+	}
+
+	// A read that is not found remains so
+	req := &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "prod/aws/creds",
+	}
+	resp, err := r.Route(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if resp != nil {
+		t.Fatalf("bad: %#v", resp)
+	}
+}
+
+func TestRouter_DisabledOperations(t *testing.T) {
+	r := NewRouter()
+	r.SetDisabledOperations([]logical.Operation{logical.ListOperation})
+	_, barrier, _ := mockBarrier(t)
+	view := NewBarrierView(barrier, "logical/")
+
+	n := &NoopBackend{}
+	err := r.Mount(n, "prod/aws/", &MountEntry{UUID: uuid.GenerateUUID()}, view)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Lists are rejected before dispatch
+	req := &logical.Request{
+		Operation: logical.ListOperation,
+		Path:      "prod/aws/",
+	}
+	resp, err := r.Route(req)
+	if err != ErrOperationDisabled {
+		t.Fatalf("err: %v", err)
+	}
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+	if len(n.Paths) != 0 {
+		t.Fatalf("bad: %v", n.Paths)
+	}
+
+	// Reads still work
+	req = &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "prod/aws/foo",
+	}
+	if _, err := r.Route(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !reflect.DeepEqual(n.Paths, []string{"foo"}) {
+		t.Fatalf("bad: %v", n.Paths)
+	}
+}
+
+func TestRouter_RequestLogging(t *testing.T) {
+	var buf bytes.Buffer
+	r := NewRouter()
+	r.SetRequestLogger(log.New(&buf, "", 0), "info")
+	_, barrier, _ := mockBarrier(t)
+	view := NewBarrierView(barrier, "logical/")
+
+	debug := &MountEntry{UUID: uuid.GenerateUUID(), Config: MountConfig{LogLevel: "debug"}}
+	if err := r.Mount(&NoopBackend{}, "prod/aws/", debug, view); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	info := &MountEntry{UUID: uuid.GenerateUUID()}
+	if err := r.Mount(&NoopBackend{}, "prod/db/", info, view); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for _, path := range []string{"prod/aws/foo", "prod/db/foo"} {
+		req := &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      path,
+		}
+		if _, err := r.Route(req); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+
+	// Only the mount at debug logs the request
+	out := buf.String()
+	if !strings.Contains(out, "[DEBUG] router: request operation=read path=prod/aws/foo mount=prod/aws/") {
+		t.Fatalf("bad: %s", out)
+	}
+	if strings.Contains(out, "prod/db/") {
+		t.Fatalf("bad: %s", out)
+	}
+
+	// Raising the global level logs every mount
+	buf.Reset()
+	r.SetRequestLogger(log.New(&buf, "", 0), "debug")
+	req := &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "prod/db/foo",
+	}
+	if _, err := r.Route(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !strings.Contains(buf.String(), "[DEBUG] router: request operation=read path=prod/db/foo") {
+		t.Fatalf("bad: %s", buf.String())
+	}
+}
+
+func TestRouter_ListPagination(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+	for _, key := range []string{"a", "b", "c", "d", "e"} {
+		req := logical.TestRequest(t, logical.WriteOperation, "secret/"+key)
+		req.Data["value"] = key
+		req.ClientToken = root
+		if _, err := c.HandleRequest(req); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+
+	list := func(token string) *ListResponse {
+		req := logical.TestRequest(t, logical.ListOperation, "secret/")
+		req.Data["limit"] = 2
+		req.Data["next_token"] = token
+		req.ClientToken = root
+		resp, err := c.HandleRequest(req)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		return newListResponse(resp)
+	}
+
+	var keys []string
+	var pages []*ListResponse
+	for lr := list(""); ; lr = list(lr.NextToken) {
+		keys = append(keys, lr.Keys...)
+		pages = append(pages, lr)
+		if !lr.Truncated {
+			break
+		}
+		if len(pages) > 5 {
+			t.Fatalf("bad: %#v", pages)
+		}
+	}
+
+	if len(pages) != 3 {
+		t.Fatalf("bad: %#v", pages)
+	}
+	if !pages[0].Truncated || pages[0].NextToken != "b" {
+		t.Fatalf("bad: %#v", pages[0])
+	}
+	if last := pages[2]; last.Truncated || last.NextToken != "" {
+		t.Fatalf("bad: %#v", last)
+	}
+	if !reflect.DeepEqual(keys, []string{"a", "b", "c", "d", "e"}) {
+		t.Fatalf("bad: %v", keys)
+	}
+}
+
+func TestRouter_ListResponse_ExtraFields(t *testing.T) {
+	r := NewRouter()
+	_, barrier, _ := mockBarrier(t)
+	view := NewBarrierView(barrier, "logical/")
+
+	roles := map[string]interface{}{
+		"foo": map[string]interface{}{"ttl": 60},
+	}
+	n := &NoopBackend{
+		Response: &logical.Response{
+			Data: map[string]interface{}{
+				"keys":  []interface{}{"foo", "bar"},
+				"roles": roles,
+			},
+		},
+	}
+	if err := r.Mount(n, "prod/aws/", &MountEntry{UUID: uuid.GenerateUUID()}, view); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	req := &logical.Request{
+		Operation: logical.ListOperation,
+		Path:      "prod/aws/roles",
+	}
+	resp, err := r.Route(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	expected := map[string]interface{}{
+		"keys":       []string{"foo", "bar"},
+		"next_token": "",
+		"truncated":  false,
+		"roles":      roles,
+	}
+	if !reflect.DeepEqual(resp.Data, expected) {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+}
+
 func TestPathsToRadix(t *testing.T) {
 	// Provide real paths
 	paths := []string{
@@ -338,3 +921,306 @@ func TestPathsToRadix(t *testing.T) {
 		t.Fatalf("bad: %v (sub/bar)", raw)
 	}
 }
+
+func TestRouter_GlobalRateLimit(t *testing.T) {
+	r := NewRouter()
+	r.SetGlobalRateLimit(1, 2)
+
+	for i := 0; i < 2; i++ {
+		if !r.allowClientRequest() {
+			t.Fatalf("request %d limited", i)
+		}
+	}
+	if r.allowClientRequest() {
+		t.Fatalf("expected limit")
+	}
+
+	// The limit is kept when the mounts are reset
+	if r.withoutMounts().allowClientRequest() {
+		t.Fatalf("expected limit")
+	}
+
+	// Routing is not limited, as the core also routes its own requests
+	_, barrier, _ := mockBarrier(t)
+	view := NewBarrierView(barrier, "logical/")
+	n := &NoopBackend{}
+	if err := r.Mount(n, "prod/aws/", &MountEntry{UUID: uuid.GenerateUUID()}, view); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	req := &logical.Request{
+		Operation: logical.RollbackOperation,
+		Path:      "prod/aws/",
+	}
+	if _, err := r.Route(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Disabling the limit
+	r.SetGlobalRateLimit(0, 0)
+	if !r.allowClientRequest() {
+		t.Fatalf("unexpected limit")
+	}
+}
+
+func TestRouter_ResponseCache(t *testing.T) {
+	r := NewRouter()
+	_, barrier, _ := mockBarrier(t)
+	view := NewBarrierView(barrier, "logical/")
+
+	n := &NoopBackend{
+		Response: &logical.Response{
+			Data: map[string]interface{}{"value": "foo"},
+		},
+	}
+	me := &MountEntry{UUID: uuid.GenerateUUID()}
+	me.Config.ResponseCacheTTL = time.Hour
+	if err := r.Mount(n, "prod/aws/", me, view); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	route := func(op logical.Operation, token string) *logical.Response {
+		req := &logical.Request{
+			Operation:   op,
+			Path:        "prod/aws/foo",
+			ClientToken: token,
+		}
+		resp, err := r.Route(req)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		return resp
+	}
+
+	// Reads within the TTL are served from the cache
+	route(logical.ReadOperation, "a")
+	resp := route(logical.ReadOperation, "a")
+	if len(n.Paths) != 1 {
+		t.Fatalf("bad: %v", n.Paths)
+	}
+	if resp == nil || resp.Data["value"] != "foo" {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	// Modifying a served response does not affect the cache
+	resp.Data["value"] = "bar"
+	if resp := route(logical.ReadOperation, "a"); resp.Data["value"] != "foo" {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	// Responses are scoped to the token
+	route(logical.ReadOperation, "b")
+	if len(n.Paths) != 2 {
+		t.Fatalf("bad: %v", n.Paths)
+	}
+
+	// A write invalidates the path for every token
+	route(logical.WriteOperation, "a")
+	route(logical.ReadOperation, "a")
+	route(logical.ReadOperation, "b")
+	if len(n.Paths) != 5 {
+		t.Fatalf("bad: %v", n.Paths)
+	}
+
+	// A write to another path of the mount also invalidates it
+	req := &logical.Request{
+		Operation: logical.WriteOperation,
+		Path:      "prod/aws/bar",
+	}
+	if _, err := r.Route(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	route(logical.ReadOperation, "a")
+	if len(n.Paths) != 7 {
+		t.Fatalf("bad: %v", n.Paths)
+	}
+
+	// Nested values of a served response are copies
+	n.Response = &logical.Response{
+		Data: map[string]interface{}{
+			"nested": map[string]interface{}{"value": "foo"},
+		},
+	}
+	route(logical.DeleteOperation, "a")
+	resp = route(logical.ReadOperation, "a")
+	resp.Data["nested"].(map[string]interface{})["value"] = "bar"
+	resp = route(logical.ReadOperation, "a")
+	if v := resp.Data["nested"].(map[string]interface{})["value"]; v != "foo" {
+		t.Fatalf("bad: %v", v)
+	}
+	if len(n.Paths) != 9 {
+		t.Fatalf("bad: %v", n.Paths)
+	}
+
+	// Renewable secrets are never cached
+	n.Response = &logical.Response{
+		Secret: &logical.Secret{
+			LeaseOptions: logical.LeaseOptions{Renewable: true},
+		},
+		Data: map[string]interface{}{"value": "foo"},
+	}
+	route(logical.DeleteOperation, "a")
+	route(logical.ReadOperation, "a")
+	route(logical.ReadOperation, "a")
+	if len(n.Paths) != 12 {
+		t.Fatalf("bad: %v", n.Paths)
+	}
+}
+
+func TestRouter_ResponseCache_Passthrough(t *testing.T) {
+	r := NewRouter()
+	_, barrier, _ := mockBarrier(t)
+	view := NewBarrierView(barrier, "logical/")
+
+	me := &MountEntry{UUID: uuid.GenerateUUID()}
+	me.Config.ResponseCacheTTL = time.Hour
+	if err := r.Mount(testPassthroughBackend(), "secret/", me, view); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	req := &logical.Request{
+		Operation: logical.WriteOperation,
+		Path:      "secret/test",
+		Data:      map[string]interface{}{"value": "foo"},
+	}
+	if _, err := r.Route(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	read := func() *logical.Response {
+		req := &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "secret/test",
+		}
+		resp, err := r.Route(req)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if resp == nil || resp.Secret == nil {
+			t.Fatalf("bad: %#v", resp)
+		}
+		return resp
+	}
+	read()
+
+	// Change the stored value behind the backend, which is not seen
+	// while the response is cached
+	entry := &logical.StorageEntry{
+		Key:   "test",
+		Value: []byte(`{"value":"bar"}`),
+	}
+	if err := view.Put(entry); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if resp := read(); resp.Data["value"] != "foo" || resp.Secret.TTL != 24*time.Hour {
+		t.Fatalf("bad: %#v %#v", resp.Data, resp.Secret)
+	}
+
+	if err := r.ClearResponseCache("secret/"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if resp := read(); resp.Data["value"] != "bar" {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+}
+
+func TestRouter_ResponseCache_Expiry(t *testing.T) {
+	r := NewRouter()
+	_, barrier, _ := mockBarrier(t)
+	view := NewBarrierView(barrier, "logical/")
+
+	n := &NoopBackend{
+		Response: &logical.Response{
+			Data: map[string]interface{}{"value": "foo"},
+		},
+	}
+	me := &MountEntry{UUID: uuid.GenerateUUID()}
+	me.Config.ResponseCacheTTL = 50 * time.Millisecond
+	if err := r.Mount(n, "prod/aws/", me, view); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	read := func() {
+		req := &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "prod/aws/foo",
+		}
+		if _, err := r.Route(req); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+
+	read()
+	read()
+	if len(n.Paths) != 1 {
+		t.Fatalf("bad: %v", n.Paths)
+	}
+
+	// Once expired the backend is invoked again
+	time.Sleep(100 * time.Millisecond)
+	read()
+	if len(n.Paths) != 2 {
+		t.Fatalf("bad: %v", n.Paths)
+	}
+
+	// Clearing the cache drops the response
+	if err := r.ClearResponseCache("prod/aws/"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	read()
+	if len(n.Paths) != 3 {
+		t.Fatalf("bad: %v", n.Paths)
+	}
+}
+
+func TestRouter_Drain(t *testing.T) {
+	r := NewRouter()
+	_, barrier, _ := mockBarrier(t)
+	view := NewBarrierView(barrier, "logical/")
+
+	n := &NoopBackend{}
+	err := r.Mount(n, "prod/aws/", &MountEntry{UUID: uuid.GenerateUUID()}, view)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Nothing in flight
+	if err := r.Drain("prod/aws/", time.Millisecond); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Hold a request in flight, then quarantine the mount
+	n.Lock()
+	go r.Route(&logical.Request{Path: "prod/aws/foo"})
+	raw, _ := r.root.Get("prod/aws/")
+	re := raw.(*routeEntry)
+	for i := 0; ; i++ {
+		re.inflightLock.Lock()
+		inflight := re.inflight
+		re.inflightLock.Unlock()
+		if inflight > 0 {
+			break
+		}
+		if i > 100 {
+			t.Fatalf("request not in flight")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := r.Quarantine("prod/aws/", true); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The request is still in flight
+	if err := r.Drain("prod/aws/", 10*time.Millisecond); err == nil {
+		t.Fatalf("expected error")
+	}
+
+	// New requests are rejected, and the drain finishes once the
+	// request in flight does
+	if _, err := r.Route(&logical.Request{Path: "prod/aws/bar"}); err != ErrMountQuarantined {
+		t.Fatalf("err: %v", err)
+	}
+	n.Unlock()
+	if err := r.Drain("prod/aws/", 5*time.Second); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
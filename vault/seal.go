@@ -0,0 +1,149 @@
+package vault
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"time"
+
+	"github.com/armon/go-metrics"
+)
+
+const (
+	// coreRecoveryKeyPath is the path used to store the recovery key
+	// within the barrier, if the master key is stored by a seal
+	coreRecoveryKeyPath = "core/recovery-key"
+)
+
+// Seal is an extension point for storing the master key on behalf of
+// the operators, for example in a hardware security module. Vault does
+// not ship an implementation: a Seal is only used when a program
+// embedding the core sets CoreConfig.Seal, and the server never does.
+//
+// A Vault using a seal unseals without key shares, by UnsealWithStoredKey.
+// Instead, its seal configuration describes the shares of a recovery
+// key, which are required to rekey.
+type Seal interface {
+	// Type returns the seal type, which must not be SealTypeShamir
+	Type() string
+
+	// SetStoredKey stores the master key
+	SetStoredKey(key []byte) error
+
+	// StoredKey returns the stored master key
+	StoredKey() ([]byte, error)
+}
+
+// UnsealWithStoredKey is used to unseal the Vault using the master key
+// stored by the seal, without key shares
+func (c *Core) UnsealWithStoredKey() (bool, error) {
+	defer metrics.MeasureSince([]string{"core", "unseal_with_stored_key"}, time.Now())
+
+	if c.seal == nil {
+		return false, ErrNoStoredKey
+	}
+	if err := c.checkSealType(c.seal.Type()); err != nil {
+		return false, err
+	}
+
+	// Ensure the barrier is initialized
+	config, err := c.SealConfig()
+	if err != nil {
+		return false, err
+	}
+	if config == nil {
+		return false, ErrNotInit
+	}
+
+	c.stateLock.Lock()
+	defer c.stateLock.Unlock()
+
+	// Check if already unsealed
+	if !c.sealed {
+		return true, nil
+	}
+
+	if err := c.checkStorage(false); err != nil {
+		return false, err
+	}
+
+	masterKey, err := c.seal.StoredKey()
+	if err != nil {
+		c.logger.Printf("[ERR] core: failed to read stored master key: %v", err)
+		return false, fmt.Errorf("failed to read stored master key: %v", err)
+	}
+	defer memzero(masterKey)
+	return c.unsealBarrier(masterKey)
+}
+
+// initRecoveryKey is used during initialization to store the master key
+// in the seal and generate the recovery key, which is split into shares
+// in place of the master key. The recovery key must be stored using
+// storeRecoveryKey once the barrier is unsealed.
+func (c *Core) initRecoveryKey(masterKey []byte, config *SealConfig) ([]byte, [][]byte, error) {
+	if err := c.seal.SetStoredKey(masterKey); err != nil {
+		c.logger.Printf("[ERR] core: failed to store master key: %v", err)
+		return nil, nil, fmt.Errorf("failed to store master key: %v", err)
+	}
+
+	recoveryKey, err := c.barrier.GenerateKey()
+	if err != nil {
+		c.logger.Printf("[ERR] core: failed to generate recovery key: %v", err)
+		return nil, nil, fmt.Errorf("recovery key generation failed: %v", err)
+	}
+	shares, err := c.splitMasterKey(recoveryKey, config)
+	if err != nil {
+		return nil, nil, err
+	}
+	return recoveryKey, shares.SecretShares, nil
+}
+
+// storeRecoveryKey is used to store the recovery key in the barrier
+func (c *Core) storeRecoveryKey(key []byte) error {
+	if err := c.barrier.Put(&Entry{Key: coreRecoveryKeyPath, Value: key}); err != nil {
+		c.logger.Printf("[ERR] core: failed to store recovery key: %v", err)
+		return fmt.Errorf("failed to store recovery key: %v", err)
+	}
+	return nil
+}
+
+// verifyRecoveryKey is used to verify a recovery key recovered from its
+// shares against the stored recovery key
+func (c *Core) verifyRecoveryKey(key []byte) error {
+	entry, err := c.barrier.Get(coreRecoveryKeyPath)
+	if err != nil {
+		c.logger.Printf("[ERR] core: failed to read recovery key: %v", err)
+		return fmt.Errorf("failed to read recovery key: %v", err)
+	}
+	if entry == nil {
+		return fmt.Errorf("no recovery key found")
+	}
+	if subtle.ConstantTimeCompare(entry.Value, key) != 1 {
+		return fmt.Errorf("recovery key verification failed")
+	}
+	return nil
+}
+
+// rekeyRecoveryKey is used to replace the recovery key after the current
+// one is verified. The master key stored by the seal is not changed.
+func (c *Core) rekeyRecoveryKey(key []byte, config *SealConfig) (*RekeyResult, error) {
+	if err := c.verifyRecoveryKey(key); err != nil {
+		c.logger.Printf("[ERR] core: rekey aborted, %v", err)
+		return nil, err
+	}
+
+	newKey, err := c.barrier.GenerateKey()
+	if err != nil {
+		c.logger.Printf("[ERR] core: failed to generate recovery key: %v", err)
+		return nil, fmt.Errorf("recovery key generation failed: %v", err)
+	}
+	results, err := c.splitMasterKey(newKey, config)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.storeRecoveryKey(newKey); err != nil {
+		return nil, err
+	}
+	c.logger.Printf("[INFO] core: recovery key rekeyed (shares: %d, threshold: %d)",
+		config.SecretShares, config.SecretThreshold)
+	return results, nil
+}
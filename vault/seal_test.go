@@ -0,0 +1,177 @@
+package vault
+
+import (
+	"testing"
+
+	"github.com/hashicorp/vault/physical"
+)
+
+// testSealType is the seal type of testSeal
+const testSealType = "test"
+
+// testSeal stores the master key in memory
+type testSeal struct {
+	key []byte
+}
+
+func (s *testSeal) Type() string {
+	return testSealType
+}
+
+func (s *testSeal) SetStoredKey(key []byte) error {
+	s.key = append([]byte(nil), key...)
+	return nil
+}
+
+func (s *testSeal) StoredKey() ([]byte, error) {
+	return append([]byte(nil), s.key...), nil
+}
+
+// shamirTypeSeal claims the seal type reserved for key shares
+type shamirTypeSeal struct {
+	testSeal
+}
+
+func (s *shamirTypeSeal) Type() string {
+	return SealTypeShamir
+}
+
+func TestNewCore_badSealType(t *testing.T) {
+	conf := &CoreConfig{
+		Physical:         physical.NewInmem(),
+		DisableMlock:     true,
+		RequiredSealType: SealTypeShamir,
+		Seal:             &testSeal{},
+	}
+	if _, err := NewCore(conf); err == nil {
+		t.Fatalf("should fail")
+	}
+
+	conf = &CoreConfig{
+		Physical:     physical.NewInmem(),
+		DisableMlock: true,
+		Seal:         &shamirTypeSeal{},
+	}
+	if _, err := NewCore(conf); err == nil {
+		t.Fatalf("should fail")
+	}
+}
+
+func TestCore_Init_Shamir(t *testing.T) {
+	c := TestCore(t)
+	res, err := c.Initialize(&SealConfig{
+		SecretShares:    5,
+		SecretThreshold: 3,
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(res.UnsealShares) != 5 || len(res.RecoveryShares) != 0 || res.RootToken == "" {
+		t.Fatalf("bad: %#v", res)
+	}
+
+	// Without a seal storing the master key there is nothing to unseal with
+	if _, err := c.UnsealWithStoredKey(); err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+func TestCore_Init_StoredKey(t *testing.T) {
+	c := TestCore(t)
+	seal := &testSeal{}
+	c.seal = seal
+
+	res, err := c.Initialize(&SealConfig{
+		SecretShares:    5,
+		SecretThreshold: 3,
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(res.UnsealShares) != 0 || len(res.RecoveryShares) != 5 || res.RootToken == "" {
+		t.Fatalf("bad: %#v", res)
+	}
+	if len(seal.key) == 0 {
+		t.Fatalf("master key not stored")
+	}
+
+	// The recovery shares cannot unseal
+	if _, err := c.Unseal(res.RecoveryShares[0]); err != ErrSealTypeNotPermitted {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The stored key unseals
+	unseal, err := c.UnsealWithStoredKey()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !unseal {
+		t.Fatalf("should be unsealed")
+	}
+
+	// The root token is usable
+	if te, err := c.tokenStore.Lookup(res.RootToken); err != nil || te == nil {
+		t.Fatalf("bad: %v %v", te, err)
+	}
+}
+
+func TestCore_Rekey_RecoveryKey(t *testing.T) {
+	c := TestCore(t)
+	seal := &testSeal{}
+	c.seal = seal
+
+	res, err := c.Initialize(&SealConfig{
+		SecretShares:    3,
+		SecretThreshold: 2,
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := c.UnsealWithStoredKey(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	masterKey := append([]byte(nil), seal.key...)
+
+	// Shares of another recovery key are rejected
+	other := TestCore(t)
+	other.seal = &testSeal{}
+	otherRes, err := other.Initialize(&SealConfig{
+		SecretShares:    3,
+		SecretThreshold: 2,
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := c.RekeyInit(&SealConfig{SecretShares: 1, SecretThreshold: 1}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		_, err = c.RekeyUpdate(otherRes.RecoveryShares[i])
+	}
+	if err == nil {
+		t.Fatalf("expected error")
+	}
+
+	// A threshold of the recovery shares replaces the recovery key
+	var result *RekeyResult
+	for i := 0; i < 2; i++ {
+		result, err = c.RekeyUpdate(res.RecoveryShares[i])
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+	if result == nil || len(result.SecretShares) != 1 {
+		t.Fatalf("bad: %#v", result)
+	}
+	if err := c.verifyRecoveryKey(result.SecretShares[0]); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The master key is unchanged
+	if err := c.barrier.VerifyMaster(masterKey); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := c.UnsealWithStoredKey(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
@@ -0,0 +1,142 @@
+package vault
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/hashicorp/vault/shamir"
+)
+
+// RefreshShares is used to invalidate every outstanding unseal key
+// share, for example when an operator holding one leaves, without
+// going through a rekey. Given a threshold of the current shares, it
+// returns new shares with the same number of shares and threshold as
+// the current seal configuration, encrypted with the given PGP keys if
+// any.
+//
+// Re-splitting the same master key would not invalidate anything, since
+// any threshold of the old shares would still combine into it. Instead
+// the master key protecting the keyring is replaced, while the keyring
+// itself is untouched: the encryption keys of the barrier do not change
+// and no data is re-encrypted.
+func (c *Core) RefreshShares(keys [][]byte, pgpKeys []string) (*RekeyResult, error) {
+	// Verify the key lengths
+	min, max := c.barrier.KeyLength()
+	max += shamir.ShareOverhead
+	for _, key := range keys {
+		if len(key) < min {
+			return nil, &ErrInvalidKey{fmt.Sprintf("key is shorter than minimum %d bytes", min)}
+		}
+		if len(key) > max {
+			return nil, &ErrInvalidKey{fmt.Sprintf("key is longer than maximum %d bytes", max)}
+		}
+	}
+
+	// Get the seal configuration
+	config, err := c.SealConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	// Ensure the barrier is initialized
+	if config == nil {
+		return nil, ErrNotInit
+	}
+
+	// Keep the shares and threshold, only the PGP keys may change
+	newConfig := &SealConfig{
+		SecretShares:    config.SecretShares,
+		SecretThreshold: config.SecretThreshold,
+		PGPKeys:         pgpKeys,
+	}
+	if err := newConfig.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid seal configuration: %v", err)
+	}
+
+	// Ensure we are already unsealed
+	c.stateLock.RLock()
+	defer c.stateLock.RUnlock()
+	if c.sealed {
+		return nil, ErrSealed
+	}
+	if c.standby {
+		return nil, ErrStandby
+	}
+
+	// Prevent a refresh from racing with a rekey
+	c.rekeyLock.Lock()
+	defer c.rekeyLock.Unlock()
+	if c.rekeyConfig != nil {
+		return nil, fmt.Errorf("rekey in progress")
+	}
+
+	// Ignore duplicate shares
+	var unique [][]byte
+	for _, key := range keys {
+		duplicate := false
+		for _, existing := range unique {
+			if bytes.Equal(existing, key) {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			unique = append(unique, key)
+		}
+	}
+	if len(unique) < config.SecretThreshold {
+		return nil, fmt.Errorf("refreshing the shares requires %d keys, have %d",
+			config.SecretThreshold, len(unique))
+	}
+
+	// Recover the master key
+	var masterKey []byte
+	if config.SecretThreshold == 1 {
+		masterKey = unique[0]
+	} else {
+		masterKey, err = shamir.Combine(unique)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compute master key: %v", err)
+		}
+	}
+
+	// The shares are of the recovery key if the master key is stored
+	// by the seal
+	if c.seal != nil {
+		return c.rekeyRecoveryKey(masterKey, newConfig)
+	}
+
+	// Verify the master key
+	if err := c.barrier.VerifyMaster(masterKey); err != nil {
+		c.logger.Printf("[ERR] core: share refresh aborted, master key verification failed: %v", err)
+		return nil, err
+	}
+
+	// Generate a new master key
+	newMasterKey, err := c.barrier.GenerateKey()
+	if err != nil {
+		c.logger.Printf("[ERR] core: failed to generate master key: %v", err)
+		return nil, fmt.Errorf("master key generation failed: %v", err)
+	}
+
+	// Split the new master key before switching to it, so a failure
+	// leaves the current shares valid
+	results, err := c.splitMasterKey(newMasterKey, newConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	// Re-encrypt the keyring with the new master key
+	if err := c.barrier.Rekey(newMasterKey); err != nil {
+		c.logger.Printf("[ERR] core: failed to refresh barrier master key: %v", err)
+		return nil, fmt.Errorf("failed to refresh barrier master key: %v", err)
+	}
+	c.logger.Printf("[INFO] core: unseal key shares refreshed (shares: %d, threshold: %d)",
+		newConfig.SecretShares, newConfig.SecretThreshold)
+
+	// Unseal progress of a sealed node holds shares of the old key
+	if err := c.clearUnsealProgress(); err != nil {
+		c.logger.Printf("[ERR] core: %v", err)
+	}
+	return results, nil
+}
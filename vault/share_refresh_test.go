@@ -0,0 +1,123 @@
+package vault
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestCore_RefreshShares(t *testing.T) {
+	c, master, root := TestCoreUnsealed(t)
+
+	// Split into several shares first
+	if err := c.RekeyInit(&SealConfig{SecretThreshold: 3, SecretShares: 5}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	oldResult, err := c.RekeyUpdate(master)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	oldConf, err := c.SealConfig()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Store some data
+	req := &logical.Request{
+		Operation: logical.WriteOperation,
+		Path:      "secret/foo",
+		Data: map[string]interface{}{
+			"foo": "bar",
+		},
+		ClientToken: root,
+	}
+	if _, err := c.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	term, err := c.barrier.ActiveKeyInfo()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Below the threshold the shares are not refreshed
+	if _, err := c.RefreshShares(oldResult.SecretShares[:2], nil); err == nil {
+		t.Fatalf("expected error")
+	}
+	dup := [][]byte{oldResult.SecretShares[0], oldResult.SecretShares[0], oldResult.SecretShares[1]}
+	if _, err := c.RefreshShares(dup, nil); err == nil {
+		t.Fatalf("expected error")
+	}
+
+	result, err := c.RefreshShares(oldResult.SecretShares[2:], nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if result == nil || len(result.SecretShares) != 5 {
+		t.Fatalf("bad: %#v", result)
+	}
+
+	// The seal configuration and the encryption key are unchanged
+	conf, err := c.SealConfig()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !reflect.DeepEqual(conf, oldConf) {
+		t.Fatalf("bad: %#v", conf)
+	}
+	newTerm, err := c.barrier.ActiveKeyInfo()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !reflect.DeepEqual(newTerm, term) {
+		t.Fatalf("bad: %#v %#v", newTerm, term)
+	}
+
+	// The old shares no longer unseal
+	if err := c.Seal(root); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		_, err = c.Unseal(oldResult.SecretShares[i])
+	}
+	if err == nil {
+		t.Fatalf("expected error")
+	}
+	if sealed, _ := c.Sealed(); !sealed {
+		t.Fatalf("should be sealed")
+	}
+
+	// The new shares do, and the existing data still decrypts
+	for i := 0; i < 3; i++ {
+		if _, err := c.Unseal(result.SecretShares[i]); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+	if sealed, _ := c.Sealed(); sealed {
+		t.Fatalf("should be unsealed")
+	}
+	req.Operation = logical.ReadOperation
+	req.Data = nil
+	resp, err := c.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if resp == nil || resp.Data["foo"] != "bar" {
+		t.Fatalf("bad: %#v", resp)
+	}
+}
+
+func TestCore_RefreshShares_InvalidMaster(t *testing.T) {
+	c, master, _ := TestCoreUnsealed(t)
+
+	bad := TestKeyCopy(master)
+	bad[0]++
+	if _, err := c.RefreshShares([][]byte{bad}, nil); err == nil {
+		t.Fatalf("expected error")
+	}
+
+	// The original key still works
+	if _, err := c.RefreshShares([][]byte{master}, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
@@ -0,0 +1,175 @@
+package vault
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/physical"
+)
+
+const (
+	// StorageFailureReadOnly rejects all further writes once the storage
+	// failure threshold is reached, until the Vault is unsealed again
+	StorageFailureReadOnly = "read-only"
+
+	// StorageFailureSeal seals the Vault once the storage failure
+	// threshold is reached
+	StorageFailureSeal = "seal"
+
+	// storageFailurePath is the request path used to audit a transition
+	// triggered by storage failures
+	storageFailurePath = "sys/storage-failure"
+)
+
+var (
+	// ErrReadOnly is returned if a write is attempted after repeated
+	// storage write failures have put the Vault into read-only mode
+	ErrReadOnly = errors.New("Vault is in read-only mode due to storage failures")
+)
+
+// storageBreaker wraps the physical backend and counts consecutive write
+// failures. Once the threshold is reached the breaker trips, invoking
+// the trip function once. In read-only mode, writes are rejected while
+// the breaker is tripped.
+type storageBreaker struct {
+	physical.Backend
+
+	threshold int
+	readOnly  bool
+	trip      func(error)
+
+	failures int
+	tripped  bool
+	l        sync.Mutex
+}
+
+// newStorageBreaker is used to wrap the given backend in a breaker
+func newStorageBreaker(b physical.Backend, threshold int, action string) *storageBreaker {
+	return &storageBreaker{
+		Backend:   b,
+		threshold: threshold,
+		readOnly:  action == StorageFailureReadOnly,
+	}
+}
+
+func (s *storageBreaker) Put(entry *physical.Entry) error {
+	if err := s.checkReadOnly(); err != nil {
+		return err
+	}
+	return s.record(s.Backend.Put(entry))
+}
+
+func (s *storageBreaker) Delete(key string) error {
+	if err := s.checkReadOnly(); err != nil {
+		return err
+	}
+	return s.record(s.Backend.Delete(key))
+}
+
+// Tripped checks if the breaker has tripped
+func (s *storageBreaker) Tripped() bool {
+	s.l.Lock()
+	defer s.l.Unlock()
+	return s.tripped
+}
+
+// Reset is used to close the breaker and clear the failure count
+func (s *storageBreaker) Reset() {
+	s.l.Lock()
+	defer s.l.Unlock()
+	s.failures = 0
+	s.tripped = false
+}
+
+func (s *storageBreaker) checkReadOnly() error {
+	s.l.Lock()
+	defer s.l.Unlock()
+	if s.readOnly && s.tripped {
+		return ErrReadOnly
+	}
+	return nil
+}
+
+// record is used to track the result of a write, tripping the breaker
+// once the threshold of consecutive failures is reached
+func (s *storageBreaker) record(err error) error {
+	s.l.Lock()
+	defer s.l.Unlock()
+	if err == nil {
+		s.failures = 0
+		return nil
+	}
+
+	s.failures++
+	if s.failures >= s.threshold && !s.tripped {
+		s.tripped = true
+		if s.trip != nil {
+			// The write may be made while holding the state lock, so the
+			// transition must not block on it
+			go s.trip(err)
+		}
+	}
+	return err
+}
+
+// StorageReadOnly checks if the Vault rejects writes due to repeated
+// storage write failures
+func (c *Core) StorageReadOnly() bool {
+	return c.storageBreaker != nil && c.storageBreaker.readOnly && c.storageBreaker.Tripped()
+}
+
+// modifiesStorage checks if an operation modifies state, and so is
+// rejected while storage is read-only
+func modifiesStorage(op logical.Operation) bool {
+	switch op {
+	case logical.WriteOperation, logical.DeleteOperation:
+		return true
+	default:
+		return false
+	}
+}
+
+// storageFailureTripped is invoked once the storage breaker trips. It
+// logs and audits the transition, and seals the Vault if configured.
+func (c *Core) storageFailureTripped(lastErr error) {
+	action := StorageFailureReadOnly
+	if !c.storageBreaker.readOnly {
+		action = StorageFailureSeal
+	}
+	reason := fmt.Errorf("%d consecutive storage write failures, last: %v",
+		c.storageBreaker.threshold, lastErr)
+
+	if action == StorageFailureSeal {
+		c.stateLock.Lock()
+		defer c.stateLock.Unlock()
+	} else {
+		c.stateLock.RLock()
+		defer c.stateLock.RUnlock()
+	}
+	if c.sealed {
+		return
+	}
+	c.logger.Printf("[ERR] core: %v, transitioning to %s", reason, action)
+
+	// Create an audit trail of the transition
+	if c.auditBroker != nil {
+		req := &logical.Request{
+			Operation: logical.WriteOperation,
+			Path:      storageFailurePath,
+			Data: map[string]interface{}{
+				"action": action,
+			},
+		}
+		if err := c.auditBroker.LogRequest(&logical.Auth{}, req, reason); err != nil {
+			c.logger.Printf("[ERR] core: failed to audit storage failure transition: %v", err)
+		}
+	}
+
+	if action == StorageFailureSeal {
+		if err := c.sealInternal(); err != nil {
+			c.logger.Printf("[ERR] core: failed to seal after storage failures: %v", err)
+		}
+	}
+}
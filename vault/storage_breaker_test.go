@@ -0,0 +1,203 @@
+package vault
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/audit"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/physical"
+)
+
+// failingPhysical fails every write once failWrites is set
+type failingPhysical struct {
+	physical.Backend
+
+	failWrites bool
+	l          sync.Mutex
+}
+
+func (f *failingPhysical) setFailWrites(fail bool) {
+	f.l.Lock()
+	defer f.l.Unlock()
+	f.failWrites = fail
+}
+
+func (f *failingPhysical) Put(entry *physical.Entry) error {
+	f.l.Lock()
+	defer f.l.Unlock()
+	if f.failWrites {
+		return errors.New("disk full")
+	}
+	return f.Backend.Put(entry)
+}
+
+func (f *failingPhysical) Delete(key string) error {
+	f.l.Lock()
+	defer f.l.Unlock()
+	if f.failWrites {
+		return errors.New("disk full")
+	}
+	return f.Backend.Delete(key)
+}
+
+func testStorageBreakerCore(t *testing.T, action string) (*Core, *failingPhysical, *NoopAudit, []byte, string) {
+	inm := &failingPhysical{Backend: physical.NewInmem()}
+	c, err := NewCore(&CoreConfig{
+		Physical:                inm,
+		DisableMlock:            true,
+		StorageFailureThreshold: 3,
+		StorageFailureAction:    action,
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	key, root := TestCoreInit(t, c)
+	if _, err := c.Unseal(TestKeyCopy(key)); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	noop := &NoopAudit{}
+	c.auditBackends["noop"] = func(config *audit.BackendConfig) (audit.Backend, error) {
+		noop.Config = config
+		return noop, nil
+	}
+	me := &MountEntry{
+		Path: "noop/",
+		Type: "noop",
+	}
+	if err := c.enableAudit(me); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	return c, inm, noop, key, root
+}
+
+// testWaitStorageFailure waits for the storage failure transition to be
+// audited. The audit is made while holding the state lock.
+func testWaitStorageFailure(t *testing.T, c *Core, noop *NoopAudit) *logical.Request {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		c.stateLock.Lock()
+		for _, req := range noop.Req {
+			if req.Path == storageFailurePath {
+				c.stateLock.Unlock()
+				return req
+			}
+		}
+		c.stateLock.Unlock()
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("storage failure not audited")
+	return nil
+}
+
+func testWriteSecret(c *Core, root string) error {
+	req := &logical.Request{
+		Operation: logical.WriteOperation,
+		Path:      "secret/foo",
+		Data: map[string]interface{}{
+			"foo": "bar",
+		},
+		ClientToken: root,
+	}
+	_, err := c.HandleRequest(req)
+	return err
+}
+
+func TestCore_StorageFailure_ReadOnly(t *testing.T) {
+	c, inm, noop, key, root := testStorageBreakerCore(t, StorageFailureReadOnly)
+
+	// Intermittent failures do not trip the breaker
+	inm.setFailWrites(true)
+	for i := 0; i < 2; i++ {
+		if err := testWriteSecret(c, root); err == nil {
+			t.Fatalf("expected error")
+		}
+	}
+	inm.setFailWrites(false)
+	if err := testWriteSecret(c, root); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if c.StorageReadOnly() {
+		t.Fatalf("should not be read-only")
+	}
+
+	// Consecutive failures do
+	inm.setFailWrites(true)
+	for i := 0; i < 3; i++ {
+		if err := testWriteSecret(c, root); err == nil {
+			t.Fatalf("expected error")
+		}
+	}
+	if !c.StorageReadOnly() {
+		t.Fatalf("should be read-only")
+	}
+	req := testWaitStorageFailure(t, c, noop)
+	if req.Data["action"] != StorageFailureReadOnly {
+		t.Fatalf("bad: %#v", req)
+	}
+
+	// Writes are rejected even once storage recovers, reads are served
+	inm.setFailWrites(false)
+	if err := testWriteSecret(c, root); err != ErrReadOnly {
+		t.Fatalf("err: %v", err)
+	}
+	req = &logical.Request{
+		Operation:   logical.ReadOperation,
+		Path:        "secret/foo",
+		ClientToken: root,
+	}
+	if _, err := c.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if sealed, _ := c.Sealed(); sealed {
+		t.Fatalf("should not be sealed")
+	}
+
+	// Unsealing again clears the read-only mode
+	if err := c.Seal(root); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := c.Unseal(TestKeyCopy(key)); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := testWriteSecret(c, root); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestCore_StorageFailure_Seal(t *testing.T) {
+	c, inm, noop, _, root := testStorageBreakerCore(t, StorageFailureSeal)
+
+	inm.setFailWrites(true)
+	for i := 0; i < 3; i++ {
+		if err := testWriteSecret(c, root); err == nil {
+			t.Fatalf("expected error")
+		}
+	}
+	req := testWaitStorageFailure(t, c, noop)
+	if req.Data["action"] != StorageFailureSeal {
+		t.Fatalf("bad: %#v", req)
+	}
+
+	if sealed, _ := c.Sealed(); !sealed {
+		t.Fatalf("should be sealed")
+	}
+	if c.StorageReadOnly() {
+		t.Fatalf("should not be read-only")
+	}
+}
+
+func TestNewCore_badStorageFailureAction(t *testing.T) {
+	conf := &CoreConfig{
+		Physical:                physical.NewInmem(),
+		DisableMlock:            true,
+		StorageFailureThreshold: 3,
+		StorageFailureAction:    "panic",
+	}
+	if _, err := NewCore(conf); err == nil {
+		t.Fatalf("should fail")
+	}
+}
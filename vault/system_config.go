@@ -0,0 +1,151 @@
+package vault
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const (
+	// coreSystemConfigPath is used to store the system configuration
+	// set at runtime, overriding the server configuration
+	coreSystemConfigPath = "core/system-config"
+)
+
+// systemConfig is the system configuration set at runtime. Zero values
+// are unset and fall back to the server configuration.
+type systemConfig struct {
+	DefaultLeaseTTL time.Duration `json:"default_lease_ttl"`
+	MaxLeaseTTL     time.Duration `json:"max_lease_ttl"`
+}
+
+// leaseTTLs returns the system default and max lease TTLs, used when no
+// mount overrides them
+func (c *Core) leaseTTLs() (def, max time.Duration) {
+	c.systemConfigLock.RLock()
+	defer c.systemConfigLock.RUnlock()
+	def, max = c.defaultLeaseTTL, c.maxLeaseTTL
+	if c.systemConfig != nil {
+		if c.systemConfig.DefaultLeaseTTL != 0 {
+			def = c.systemConfig.DefaultLeaseTTL
+		}
+		if c.systemConfig.MaxLeaseTTL != 0 {
+			max = c.systemConfig.MaxLeaseTTL
+		}
+	}
+	return
+}
+
+// SetDefaultLeaseTTL is used to set the system default lease TTL. Zero
+// restores the configured default. This only applies to leases issued
+// from now on.
+func (c *Core) SetDefaultLeaseTTL(ttl time.Duration) error {
+	return c.updateSystemConfig(func(conf *systemConfig) {
+		conf.DefaultLeaseTTL = ttl
+	})
+}
+
+// SetMaxLeaseTTL is used to set the system max lease TTL. Zero restores
+// the configured max. This only applies to leases issued from now on;
+// existing leases are not shortened.
+func (c *Core) SetMaxLeaseTTL(ttl time.Duration) error {
+	return c.updateSystemConfig(func(conf *systemConfig) {
+		conf.MaxLeaseTTL = ttl
+	})
+}
+
+// updateSystemConfig is used to validate and persist a change to the
+// system configuration
+func (c *Core) updateSystemConfig(update func(*systemConfig)) error {
+	c.stateLock.RLock()
+	defer c.stateLock.RUnlock()
+	if c.sealed {
+		return ErrSealed
+	}
+	if c.standby {
+		return ErrStandby
+	}
+
+	c.systemConfigLock.Lock()
+	defer c.systemConfigLock.Unlock()
+
+	newConf := &systemConfig{}
+	if c.systemConfig != nil {
+		*newConf = *c.systemConfig
+	}
+	update(newConf)
+
+	// Verify the resulting TTLs
+	if newConf.DefaultLeaseTTL < 0 || newConf.MaxLeaseTTL < 0 {
+		return fmt.Errorf("lease TTL cannot be negative")
+	}
+	def, max := c.defaultLeaseTTL, c.maxLeaseTTL
+	if newConf.DefaultLeaseTTL != 0 {
+		def = newConf.DefaultLeaseTTL
+	}
+	if newConf.MaxLeaseTTL != 0 {
+		max = newConf.MaxLeaseTTL
+	}
+	if def > max {
+		return fmt.Errorf("default lease TTL of %d exceeds the max lease TTL of %d",
+			int(def.Seconds()), int(max.Seconds()))
+	}
+
+	if err := c.persistSystemConfig(newConf); err != nil {
+		return err
+	}
+	c.systemConfig = newConf
+	c.logger.Printf("[INFO] core: system lease TTLs set (default: %s, max: %s)", def, max)
+	return nil
+}
+
+// persistSystemConfig is used to write the system configuration
+func (c *Core) persistSystemConfig(conf *systemConfig) error {
+	raw, err := json.Marshal(conf)
+	if err != nil {
+		c.logger.Printf("[ERR] core: failed to encode system config: %v", err)
+		return err
+	}
+
+	entry := &Entry{
+		Key:   coreSystemConfigPath,
+		Value: raw,
+	}
+	if err := c.barrier.Put(entry); err != nil {
+		c.logger.Printf("[ERR] core: failed to persist system config: %v", err)
+		return err
+	}
+	return nil
+}
+
+// loadSystemConfig is invoked as part of postUnseal to load the system
+// configuration
+func (c *Core) loadSystemConfig() error {
+	raw, err := c.barrier.Get(coreSystemConfigPath)
+	if err != nil {
+		c.logger.Printf("[ERR] core: failed to read system config: %v", err)
+		return err
+	}
+
+	var conf *systemConfig
+	if raw != nil {
+		conf = &systemConfig{}
+		if err := json.Unmarshal(raw.Value, conf); err != nil {
+			c.logger.Printf("[ERR] core: failed to decode system config: %v", err)
+			return err
+		}
+	}
+
+	c.systemConfigLock.Lock()
+	c.systemConfig = conf
+	c.systemConfigLock.Unlock()
+	return nil
+}
+
+// unloadSystemConfig is used to clear the system configuration when
+// the Vault is sealed
+func (c *Core) unloadSystemConfig() {
+	c.systemConfigLock.Lock()
+	c.systemConfig = nil
+	c.systemConfigLock.Unlock()
+}
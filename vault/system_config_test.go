@@ -0,0 +1,107 @@
+package vault
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+func testReadSecretLease(t *testing.T, c *Core, root string, data map[string]interface{}) *logical.Secret {
+	req := &logical.Request{
+		Operation:   logical.WriteOperation,
+		Path:        "secret/test",
+		Data:        data,
+		ClientToken: root,
+	}
+	if _, err := c.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	req.Operation = logical.ReadOperation
+	req.Data = nil
+	resp, err := c.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if resp == nil || resp.Secret == nil || resp.Secret.LeaseID == "" {
+		t.Fatalf("bad: %#v", resp)
+	}
+	return resp.Secret
+}
+
+func TestCore_SetLeaseTTLs(t *testing.T) {
+	c, key, root := TestCoreUnsealed(t)
+
+	// Issued with the configured default
+	before := testReadSecretLease(t, c, root, map[string]interface{}{"foo": "bar"})
+	if before.TTL != c.defaultLeaseTTL {
+		t.Fatalf("bad: %#v", before)
+	}
+	le, err := c.expiration.loadEntry(before.LeaseID)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	expireTime := le.ExpireTime
+
+	if err := c.SetDefaultLeaseTTL(time.Hour); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := c.SetMaxLeaseTTL(2 * time.Hour); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// New leases use the new default and are capped by the new max
+	after := testReadSecretLease(t, c, root, map[string]interface{}{"foo": "bar"})
+	if after.TTL != time.Hour {
+		t.Fatalf("bad: %#v", after)
+	}
+	after = testReadSecretLease(t, c, root, map[string]interface{}{"foo": "bar", "ttl": "10h"})
+	if after.TTL != 2*time.Hour {
+		t.Fatalf("bad: %#v", after)
+	}
+
+	// Existing leases are not shortened
+	le, err = c.expiration.loadEntry(before.LeaseID)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if le == nil || !le.ExpireTime.Equal(expireTime) {
+		t.Fatalf("bad: %#v", le)
+	}
+
+	// The default may not exceed the max
+	if err := c.SetDefaultLeaseTTL(3 * time.Hour); err == nil {
+		t.Fatalf("expected error")
+	}
+	if err := c.SetMaxLeaseTTL(-time.Hour); err == nil {
+		t.Fatalf("expected error")
+	}
+
+	// Persisted across a restart
+	conf := &CoreConfig{
+		Physical:     c.physical,
+		DisableMlock: true,
+	}
+	c2, err := NewCore(conf)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := c2.Unseal(TestKeyCopy(key)); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if def, max := c2.leaseTTLs(); def != time.Hour || max != 2*time.Hour {
+		t.Fatalf("bad: %s %s", def, max)
+	}
+
+	// Zero restores the configuration
+	if err := c2.SetMaxLeaseTTL(0); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := c2.SetDefaultLeaseTTL(0); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if def, max := c2.leaseTTLs(); def != c2.defaultLeaseTTL || max != c2.maxLeaseTTL {
+		t.Fatalf("bad: %s %s", def, max)
+	}
+}
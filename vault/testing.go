@@ -106,7 +106,7 @@ func TestCoreInit(t *testing.T, core *Core) ([]byte, string) {
 	if err != nil {
 		t.Fatalf("err: %s", err)
 	}
-	return result.SecretShares[0], result.RootToken
+	return result.UnsealShares[0], result.RootToken
 }
 
 // TestCoreUnsealed returns a pure in-memory core that is already
@@ -255,6 +255,10 @@ func (n *noopAudit) LogResponse(a *logical.Auth, r *logical.Request, re *logical
 	return nil
 }
 
+func (n *noopAudit) GetHash(data string) string {
+	return audit.HashString(n.Config.Salt, data)
+}
+
 type rawHTTP struct{}
 
 func (n *rawHTTP) HandleRequest(req *logical.Request) (*logical.Response, error) {
@@ -1,14 +1,18 @@
 package vault
 
 import (
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/armon/go-metrics"
-	"github.com/hashicorp/uuid"
 	"github.com/hashicorp/vault/helper/salt"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
@@ -27,11 +31,23 @@ const (
 	// tokenSubPath is the sub-path used for the token store
 	// view. This is nested under the system view.
 	tokenSubPath = "token/"
+
+	// minTokenIDLength is the minimum number of random bytes in a
+	// generated token ID, matching the entropy of a UUID
+	minTokenIDLength = 16
 )
 
 var (
 	// displayNameSanitize is used to sanitize a display name given to a token.
 	displayNameSanitize = regexp.MustCompile("[^a-zA-Z0-9-]")
+
+	// ErrTooManyPolicies is returned if a token would have more policies
+	// than permitted
+	ErrTooManyPolicies = errors.New("token exceeds the maximum number of policies")
+
+	// ErrTokenDepthExceeded is returned if a token would be nested
+	// deeper in the token hierarchy than permitted
+	ErrTokenDepthExceeded = errors.New("token exceeds the maximum token hierarchy depth")
 )
 
 // TokenStore is used to manage client tokens. Tokens are used for
@@ -48,6 +64,27 @@ type TokenStore struct {
 	cubbyholeBackend *CubbyholeBackend
 
 	policyLookupFunc func(string) (*Policy, error)
+
+	// mfaEnrollFunc enrolls the identity of a token in an MFA method,
+	// returning the new secret
+	mfaEnrollFunc func(*TokenEntry, string) (string, error)
+
+	// maxPolicies limits the number of policies of a token, not
+	// counting the root and default policies. Zero is unlimited.
+	maxPolicies int
+
+	// idLength is the number of random bytes in generated token IDs.
+	// Zero generates a UUID. idRand is the source of the random bytes.
+	idLength int
+	idRand   io.Reader
+
+	// maxDepth limits the depth of a token in the token hierarchy.
+	// Orphan tokens have a depth of zero. Zero is unlimited.
+	maxDepth int
+
+	// entryLock serializes the updates of stored token entries, which
+	// are read again under it so that concurrent updates are not lost
+	entryLock sync.Mutex
 }
 
 // NewTokenStore is used to construct a token store that is
@@ -58,12 +95,17 @@ func NewTokenStore(c *Core, config *logical.BackendConfig) (*TokenStore, error)
 
 	// Initialize the store
 	t := &TokenStore{
-		view: view,
+		view:        view,
+		maxPolicies: c.maxPoliciesPerToken,
+		idLength:    c.tokenIDLength,
+		idRand:      c.entropyReader(),
+		maxDepth:    c.maxTokenDepth,
 	}
 
 	if c.policyStore != nil {
 		t.policyLookupFunc = c.policyStore.GetPolicy
 	}
+	t.mfaEnrollFunc = c.enrollMFA
 
 	// Setup the salt
 	salt, err := salt.NewSalt(view, &salt.Config{
@@ -85,6 +127,7 @@ func NewTokenStore(c *Core, config *logical.BackendConfig) (*TokenStore, error)
 			Root: []string{
 				"revoke-prefix/*",
 				"revoke-orphan/*",
+				"mfa-enroll/*",
 			},
 		},
 
@@ -255,6 +298,28 @@ func NewTokenStore(c *Core, config *logical.BackendConfig) (*TokenStore, error)
 				HelpSynopsis:    strings.TrimSpace(tokenRenewHelp),
 				HelpDescription: strings.TrimSpace(tokenRenewHelp),
 			},
+
+			&framework.Path{
+				Pattern: "mfa-enroll/(?P<method>.+)",
+
+				Fields: map[string]*framework.FieldSchema{
+					"method": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: "MFA method to enroll in",
+					},
+					"token": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: "Token whose identity to enroll",
+					},
+				},
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.WriteOperation: t.handleMFAEnroll,
+				},
+
+				HelpSynopsis:    strings.TrimSpace(tokenMFAEnrollHelp),
+				HelpDescription: strings.TrimSpace(tokenMFAEnrollHelp),
+			},
 		},
 	}
 
@@ -274,6 +339,55 @@ type TokenEntry struct {
 	NumUses      int               // Used to restrict the number of uses (zero is unlimited). This is to support one-time-tokens (generalized).
 	CreationTime int64             // Time of token creation
 	TTL          time.Duration     // Duration set when token was created
+
+	ExplicitMaxTTL time.Duration // If set, the token cannot be renewed past this duration since creation
+	Period         time.Duration // If set, the token is periodic and every renewal resets its TTL to this value
+
+	BoundCIDRs []string // If set, the token may only be used from one of these networks
+
+	PolicySnapshot bool // If set, renewals keep the policies the token was created with
+
+	MFAIdentity string // The login identity MFA methods are enrolled for, shared with child tokens
+}
+
+// mfaIdentity returns the login identity the token is enrolled in MFA
+// methods for. Tokens stored before the identity was recorded use the
+// path and display name they were created with.
+func (te *TokenEntry) mfaIdentity() string {
+	if te.MFAIdentity != "" {
+		return te.MFAIdentity
+	}
+	return te.Path + "\x00" + te.DisplayName
+}
+
+// AllowsRemoteAddr checks if the token may be used by a client at the
+// given address. A token bound to CIDRs cannot be used by a client
+// without a known address.
+func (te *TokenEntry) AllowsRemoteAddr(remoteAddr string) bool {
+	if len(te.BoundCIDRs) == 0 {
+		return true
+	}
+	ip := net.ParseIP(remoteAddr)
+	if ip == nil {
+		return false
+	}
+	for _, raw := range te.BoundCIDRs {
+		_, cidr, err := net.ParseCIDR(raw)
+		if err == nil && cidr.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// TokenInfo is the information about a token after it has been renewed
+type TokenInfo struct {
+	Policies       []string
+	Path           string
+	TTL            time.Duration
+	ExplicitMaxTTL time.Duration
+	Period         time.Duration
+	Renewable      bool
 }
 
 // SetExpirationManager is used to provide the token store with
@@ -302,13 +416,92 @@ func (ts *TokenStore) rootToken() (*TokenEntry, error) {
 	return te, nil
 }
 
+// generateID is used to generate a new token ID. A UUID is used unless
+// a longer ID was configured, which is then hex encoded.
+func (ts *TokenStore) generateID() (string, error) {
+	if ts.idLength == 0 {
+		return generateUUIDFrom(ts.idRand)
+	}
+	if ts.idLength < minTokenIDLength {
+		return "", fmt.Errorf("token ID length must be at least %d bytes", minTokenIDLength)
+	}
+	buf := make([]byte, ts.idLength)
+	if _, err := io.ReadFull(ts.idRand, buf); err != nil {
+		return "", fmt.Errorf("failed to generate token ID: %v", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// checkPolicyCount is used to check that the number of policies does
+// not exceed the limit. The root and default policies are not counted.
+func (ts *TokenStore) checkPolicyCount(policies []string) error {
+	if ts.maxPolicies <= 0 {
+		return nil
+	}
+	count := 0
+	for _, p := range policies {
+		if p != "root" && p != "default" {
+			count++
+		}
+	}
+	if count > ts.maxPolicies {
+		return ErrTooManyPolicies
+	}
+	return nil
+}
+
+// checkDepth is used to check that a child of the given parent token
+// does not exceed the depth limit. The depth of the parent is found by
+// walking up the hierarchy, which stops once the limit is reached.
+func (ts *TokenStore) checkDepth(parent string) error {
+	if ts.maxDepth <= 0 || parent == "" {
+		return nil
+	}
+	depth := 1
+	for parent != "" {
+		if depth > ts.maxDepth {
+			return ErrTokenDepthExceeded
+		}
+		te, err := ts.Lookup(parent)
+		if err != nil {
+			return err
+		}
+		if te == nil {
+			break
+		}
+		parent = te.Parent
+		depth++
+	}
+	return nil
+}
+
 // Create is used to create a new token entry. The entry is assigned
 // a newly generated ID if not provided.
 func (ts *TokenStore) create(entry *TokenEntry) error {
 	defer metrics.MeasureSince([]string{"token", "create"}, time.Now())
+	// Limit the number of policies
+	if err := ts.checkPolicyCount(entry.Policies); err != nil {
+		return err
+	}
+
+	// Limit the depth in the token hierarchy
+	if err := ts.checkDepth(entry.Parent); err != nil {
+		return err
+	}
+
+	// A token that does not share the identity of the token creating it
+	// is enrolled in MFA methods as the login that created it
+	if entry.MFAIdentity == "" {
+		entry.MFAIdentity = entry.mfaIdentity()
+	}
+
 	// Generate an ID if necessary
 	if entry.ID == "" {
-		entry.ID = uuid.GenerateUUID()
+		id, err := ts.generateID()
+		if err != nil {
+			return err
+		}
+		entry.ID = id
 	}
 	saltedId := ts.SaltID(entry.ID)
 
@@ -357,8 +550,21 @@ func (ts *TokenStore) UseToken(te *TokenEntry) error {
 		return nil
 	}
 
-	// Decrement the count
-	te.NumUses -= 1
+	// Decrement the count of the stored entry, so that a concurrent
+	// update of the token, such as a renewal changing its policies, is
+	// not overwritten
+	ts.entryLock.Lock()
+	defer ts.entryLock.Unlock()
+	entry, err := ts.lookupSalted(ts.SaltID(te.ID))
+	if err != nil {
+		return err
+	}
+	if entry == nil || entry.NumUses == 0 {
+		te.NumUses -= 1
+		return nil
+	}
+	entry.NumUses -= 1
+	te.NumUses = entry.NumUses
 
 	// Revoke the token if there are no remaining uses.
 	// XXX: There is a race condition here with parallel
@@ -366,10 +572,14 @@ func (ts *TokenStore) UseToken(te *TokenEntry) error {
 	// some global coordination to avoid, as we must ensure
 	// no requests using the same restricted token are handled
 	// in parallel.
-	if te.NumUses == 0 {
+	if entry.NumUses == 0 {
 		return ts.Revoke(te.ID)
 	}
+	return ts.persistEntry(entry)
+}
 
+// persistEntry writes the entry of a token under its primary ID
+func (ts *TokenStore) persistEntry(te *TokenEntry) error {
 	// Marshal the entry
 	enc, err := json.Marshal(te)
 	if err != nil {
@@ -377,8 +587,7 @@ func (ts *TokenStore) UseToken(te *TokenEntry) error {
 	}
 
 	// Write under the primary ID
-	saltedId := ts.SaltID(te.ID)
-	path := lookupPrefix + saltedId
+	path := lookupPrefix + ts.SaltID(te.ID)
 	le := &logical.StorageEntry{Key: path, Value: enc}
 	if err := ts.view.Put(le); err != nil {
 		return fmt.Errorf("failed to persist entry: %v", err)
@@ -392,7 +601,30 @@ func (ts *TokenStore) Lookup(id string) (*TokenEntry, error) {
 	if id == "" {
 		return nil, fmt.Errorf("cannot lookup blank token")
 	}
-	return ts.lookupSalted(ts.SaltID(id))
+	entry, err := ts.lookupSalted(ts.SaltID(id))
+	if err != nil || entry == nil {
+		return entry, err
+	}
+
+	// An expired token is treated as missing, even if it has not yet
+	// been revoked by the expiration manager
+	expireTime, err := ts.expireTime(entry)
+	if err != nil {
+		return nil, err
+	}
+	if !expireTime.IsZero() && !time.Now().UTC().Before(expireTime) {
+		return nil, nil
+	}
+	return entry, nil
+}
+
+// expireTime returns the time the lease of the token expires, which is
+// zero if the token does not expire
+func (ts *TokenStore) expireTime(te *TokenEntry) (time.Time, error) {
+	if ts.expiration == nil {
+		return time.Time{}, nil
+	}
+	return ts.expiration.tokenExpireTime(te.Path, te.ID)
 }
 
 // lookupSlated is used to find a token given its salted ID
@@ -439,21 +671,21 @@ func (ts *TokenStore) revokeSalted(saltedId string) error {
 
 	// Nuke the primary key first
 	path := lookupPrefix + saltedId
-	if ts.view.Delete(path); err != nil {
+	if err := ts.view.Delete(path); err != nil {
 		return fmt.Errorf("failed to delete entry: %v", err)
 	}
 
 	// Clear the secondary index if any
 	if entry != nil && entry.Parent != "" {
 		path := parentPrefix + ts.SaltID(entry.Parent) + "/" + saltedId
-		if ts.view.Delete(path); err != nil {
+		if err := ts.view.Delete(path); err != nil {
 			return fmt.Errorf("failed to delete entry: %v", err)
 		}
 	}
 
 	// Revoke all secrets under this token
 	if entry != nil {
-		if err := ts.expiration.RevokeByToken(entry.ID); err != nil {
+		if _, err := ts.expiration.RevokeByToken(entry.ID); err != nil {
 			return err
 		}
 	}
@@ -547,14 +779,17 @@ func (ts *TokenStore) handleCreateCommon(
 
 	// Read and parse the fields
 	var data struct {
-		ID          string
-		Policies    []string
-		Metadata    map[string]string `mapstructure:"meta"`
-		NoParent    bool              `mapstructure:"no_parent"`
-		Lease       string
-		TTL         string
-		DisplayName string `mapstructure:"display_name"`
-		NumUses     int    `mapstructure:"num_uses"`
+		ID             string
+		Policies       []string
+		Metadata       map[string]string `mapstructure:"meta"`
+		NoParent       bool              `mapstructure:"no_parent"`
+		Lease          string
+		TTL            string
+		DisplayName    string `mapstructure:"display_name"`
+		NumUses        int    `mapstructure:"num_uses"`
+		ExplicitMaxTTL string `mapstructure:"explicit_max_ttl"`
+		Period         string
+		BoundCIDRs     []string `mapstructure:"bound_cidrs"`
 	}
 	if err := mapstructure.WeakDecode(req.Data, &data); err != nil {
 		return logical.ErrorResponse(fmt.Sprintf(
@@ -567,6 +802,14 @@ func (ts *TokenStore) handleCreateCommon(
 			logical.ErrInvalidRequest
 	}
 
+	// Verify the bound CIDRs can be parsed
+	for _, raw := range data.BoundCIDRs {
+		if _, _, err := net.ParseCIDR(raw); err != nil {
+			return logical.ErrorResponse(fmt.Sprintf(
+				"invalid bound CIDR '%s': %v", raw, err)), logical.ErrInvalidRequest
+		}
+	}
+
 	// Setup the token entry
 	te := TokenEntry{
 		Parent:       req.ClientToken,
@@ -575,6 +818,7 @@ func (ts *TokenStore) handleCreateCommon(
 		DisplayName:  "token",
 		NumUses:      data.NumUses,
 		CreationTime: time.Now().Unix(),
+		BoundCIDRs:   data.BoundCIDRs,
 	}
 
 	// Attach the given display name if any
@@ -603,6 +847,13 @@ func (ts *TokenStore) handleCreateCommon(
 	}
 	te.Policies = data.Policies
 
+	// Share the MFA identity of the parent, so that a child token cannot
+	// be used to start over without the enrolled factor. Operators with
+	// sudo create tokens for a new identity.
+	if !isSudo {
+		te.MFAIdentity = parent.mfaIdentity()
+	}
+
 	// Only allow an orphan token if the client has sudo policy
 	if data.NoParent {
 		if !isSudo {
@@ -639,6 +890,36 @@ func (ts *TokenStore) handleCreateCommon(
 		te.TTL = dur
 	}
 
+	// Parse the explicit max TTL if any
+	if data.ExplicitMaxTTL != "" {
+		dur, err := time.ParseDuration(data.ExplicitMaxTTL)
+		if err != nil {
+			return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+		}
+		if dur < 0 {
+			return logical.ErrorResponse("explicit_max_ttl must be positive"), logical.ErrInvalidRequest
+		}
+		te.ExplicitMaxTTL = dur
+	}
+
+	// Parse the period if any. Periodic tokens can be renewed forever,
+	// so only allow them to be created with root or sudo privileges.
+	if data.Period != "" {
+		if !isSudo {
+			return logical.ErrorResponse("root or sudo privileges required to create periodic token"),
+				logical.ErrInvalidRequest
+		}
+		dur, err := time.ParseDuration(data.Period)
+		if err != nil {
+			return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+		}
+		if dur < 0 {
+			return logical.ErrorResponse("period must be positive"), logical.ErrInvalidRequest
+		}
+		te.Period = dur
+		te.TTL = dur
+	}
+
 	sysView := ts.System()
 
 	// Set the default lease if non-provided, root tokens are exempt
@@ -651,6 +932,11 @@ func (ts *TokenStore) handleCreateCommon(
 		te.TTL = sysView.MaxLeaseTTL()
 	}
 
+	// Limit the lease duration by the explicit max TTL
+	if te.ExplicitMaxTTL > 0 && te.TTL > te.ExplicitMaxTTL {
+		te.TTL = te.ExplicitMaxTTL
+	}
+
 	// Create the token
 	if err := ts.create(&te); err != nil {
 		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
@@ -790,6 +1076,22 @@ func (ts *TokenStore) handleLookup(
 		return logical.ErrorResponse("bad token"), logical.ErrPermissionDenied
 	}
 
+	// Report the policies the token currently has
+	if err := ts.refreshPolicies(out); err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+
+	// Determine the remaining TTL from the lease, which is extended
+	// by renewals
+	expireTime, err := ts.expireTime(out)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+	var ttl time.Duration
+	if !expireTime.IsZero() {
+		ttl = expireTime.Sub(time.Now().UTC())
+	}
+
 	// Generate a response. We purposely omit the parent reference otherwise
 	// you could escalate your privileges.
 	resp := &logical.Response{
@@ -802,7 +1104,8 @@ func (ts *TokenStore) handleLookup(
 			"num_uses":      out.NumUses,
 			"orphan":        false,
 			"creation_time": int(out.CreationTime),
-			"ttl":           int(out.TTL.Seconds()),
+			"creation_ttl":  int(out.TTL.Seconds()),
+			"ttl":           int(ttl.Seconds()),
 		},
 	}
 
@@ -810,6 +1113,14 @@ func (ts *TokenStore) handleLookup(
 		resp.Data["orphan"] = true
 	}
 
+	if len(out.BoundCIDRs) > 0 {
+		resp.Data["bound_cidrs"] = out.BoundCIDRs
+	}
+
+	if out.PolicySnapshot {
+		resp.Data["policy_snapshot"] = true
+	}
+
 	return resp, nil
 }
 
@@ -819,6 +1130,38 @@ func (ts *TokenStore) handleRenewSelf(
 	return ts.handleRenew(req, data)
 }
 
+// handleMFAEnroll handles the auth/token/mfa-enroll/method path for
+// enrolling the identity of a token in an MFA method. It requires sudo,
+// so that enrolling is an operator action rather than a capability of
+// the token being protected, and replaces any previous secret.
+func (ts *TokenStore) handleMFAEnroll(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	method := data.Get("method").(string)
+	id := data.Get("token").(string)
+	if id == "" {
+		return logical.ErrorResponse("missing token ID"), logical.ErrInvalidRequest
+	}
+
+	te, err := ts.Lookup(id)
+	if err != nil {
+		return nil, err
+	}
+	if te == nil {
+		return logical.ErrorResponse("token not found"), logical.ErrInvalidRequest
+	}
+
+	secret, err := ts.mfaEnrollFunc(te, method)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"method": method,
+			"secret": secret,
+		},
+	}, nil
+}
+
 // handleRenew handles the auth/token/renew/id path for renewal of tokens.
 // This is used to prevent token expiration and revocation.
 func (ts *TokenStore) handleRenew(
@@ -844,10 +1187,13 @@ func (ts *TokenStore) handleRenew(
 	}
 
 	// Renew the token and its children
-	auth, err := ts.expiration.RenewToken(out.Path, out.ID, increment)
-	if err != nil {
+	auth, err := ts.renew(out, increment)
+	if verr, ok := err.(*logical.VaultError); ok && verr.Code == logical.ErrCodeInvalidRequest {
 		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
 	}
+	if err != nil {
+		return nil, err
+	}
 
 	// Generate the response
 	resp := &logical.Response{
@@ -856,6 +1202,141 @@ func (ts *TokenStore) handleRenew(
 	return resp, nil
 }
 
+// RenewSelf is used by a client to renew its own token by the given
+// increment, returning the updated token information. The renewal is
+// handled as a request to auth/token/renew-self, so it is checked
+// against the policies of the token, uses it and is audited. A token
+// that cannot be renewed is refused with an invalid request error;
+// other errors, such as storage failures, are returned as they are.
+func (c *Core) RenewSelf(token string, increment time.Duration) (*TokenInfo, error) {
+	req := &logical.Request{
+		Operation:   logical.WriteOperation,
+		Path:        "auth/token/renew-self",
+		ClientToken: token,
+		Data: map[string]interface{}{
+			"increment": int(increment / time.Second),
+		},
+	}
+	resp, err := c.HandleRequest(req)
+	if err == logical.ErrInvalidRequest && resp != nil && resp.IsError() {
+		return nil, logical.NewInvalidRequest("%v", resp.Data["error"])
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	info := &TokenInfo{}
+	if resp != nil && resp.Auth != nil {
+		info.Policies = resp.Auth.Policies
+		info.TTL = resp.Auth.TTL
+		info.Renewable = resp.Auth.Renewable
+	}
+
+	// Report the token as renewed, unless this was its last use
+	c.stateLock.RLock()
+	defer c.stateLock.RUnlock()
+	if c.sealed {
+		return nil, ErrSealed
+	}
+	te, err := c.tokenStore.Lookup(token)
+	if err != nil {
+		return nil, err
+	}
+	if te != nil {
+		info.Policies = te.Policies
+		info.Path = te.Path
+		info.ExplicitMaxTTL = te.ExplicitMaxTTL
+		info.Period = te.Period
+	}
+	return info, nil
+}
+
+// renew is used to renew the given token by the increment. A periodic
+// token is always renewed for its period, and no token is renewed past
+// its explicit max TTL.
+func (ts *TokenStore) renew(te *TokenEntry, increment time.Duration) (*logical.Auth, error) {
+	if increment < 0 {
+		return nil, logical.NewInvalidRequest("increment must be greater than 0")
+	}
+
+	// Periodic tokens reset to their period
+	if te.Period > 0 {
+		increment = te.Period
+	}
+
+	// Limit the increment to the remaining explicit max TTL
+	if te.ExplicitMaxTTL > 0 {
+		maxTime := time.Unix(te.CreationTime, 0).Add(te.ExplicitMaxTTL)
+		remaining := maxTime.Sub(time.Now())
+		if remaining <= 0 {
+			return nil, logical.NewInvalidRequest("token has reached its explicit max TTL of %s", te.ExplicitMaxTTL)
+		}
+		if increment == 0 || increment > remaining {
+			increment = remaining
+		}
+	}
+
+	auth, err := ts.expiration.RenewToken(te.Path, te.ID, increment)
+	if verr, ok := err.(*logical.VaultError); ok && verr.Code == logical.ErrCodeInvalidRequest {
+		return nil, logical.NewInvalidRequest("token is not renewable: %v", err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if auth == nil || len(auth.Policies) == 0 {
+		return auth, nil
+	}
+
+	// Pick up the policies returned by the backend, unless the token
+	// keeps the policies it was created with
+	if te.PolicySnapshot {
+		auth.Policies = te.Policies
+	} else if err := ts.updatePolicies(te, auth.Policies); err != nil {
+		return nil, err
+	}
+	return auth, nil
+}
+
+// refreshPolicies updates the token to the policies its credential
+// backend currently maps it to. Tokens that keep the policies they were
+// created with, and tokens not created by a login, are left unchanged.
+func (ts *TokenStore) refreshPolicies(te *TokenEntry) error {
+	if te.PolicySnapshot || ts.expiration == nil || strings.HasPrefix(te.Path, "auth/token/") {
+		return nil
+	}
+
+	policies, err := ts.expiration.tokenPolicies(te.Path, te.ID)
+	if err != nil || len(policies) == 0 {
+		return err
+	}
+	return ts.updatePolicies(te, policies)
+}
+
+// updatePolicies replaces the policies of the given token if they
+// changed. The entry is read again and only its policies are changed, so
+// that a concurrent update of the token, such as a use, is not lost.
+func (ts *TokenStore) updatePolicies(te *TokenEntry, policies []string) error {
+	if strListSubset(te.Policies, policies) && strListSubset(policies, te.Policies) {
+		return nil
+	}
+	if err := ts.checkPolicyCount(policies); err != nil {
+		return err
+	}
+
+	ts.entryLock.Lock()
+	defer ts.entryLock.Unlock()
+	entry, err := ts.lookupSalted(ts.SaltID(te.ID))
+	if err != nil {
+		return err
+	}
+	te.Policies = policies
+	if entry == nil {
+		return nil
+	}
+	entry.Policies = policies
+	return ts.persistEntry(entry)
+}
+
 func (ts *TokenStore) destroyCubbyhole(saltedID string) error {
 	if ts.cubbyholeBackend == nil {
 		// Should only ever happen in testing
@@ -878,4 +1359,5 @@ as revocation of tokens. The tokens are renewable if associated with a lease.`
 	tokenRevokePrefixHelp = `This endpoint will delete all tokens generated under a prefix with their child tokens.`
 	tokenRenewHelp        = `This endpoint will renew the given token and prevent expiration.`
 	tokenRenewSelfHelp    = `This endpoint will renew the token used to call it and prevent expiration.`
+	tokenMFAEnrollHelp    = `This endpoint will enroll the identity of a token in an MFA method and return the secret. Requires sudo.`
 )
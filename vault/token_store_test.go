@@ -1,13 +1,16 @@
 package vault
 
 import (
+	"encoding/hex"
 	"log"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/hashicorp/uuid"
+	"github.com/hashicorp/vault/audit"
 	"github.com/hashicorp/vault/logical"
 )
 
@@ -209,6 +212,47 @@ func TestTokenStore_UseToken(t *testing.T) {
 	}
 }
 
+func TestTokenStore_UpdatePolicies_UseToken(t *testing.T) {
+	_, ts, _ := mockTokenStore(t)
+
+	ent := &TokenEntry{Path: "test", Policies: []string{"dev"}, NumUses: 3}
+	if err := ts.create(ent); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Use the token and change its policies through separate copies of
+	// its entry, as concurrent requests do
+	used, err := ts.Lookup(ent.ID)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	renewed, err := ts.Lookup(ent.ID)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := ts.UseToken(used); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := ts.updatePolicies(renewed, []string{"dev", "ops"}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := ts.UseToken(renewed); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Neither update is lost
+	out, err := ts.Lookup(ent.ID)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if out.NumUses != 1 || !reflect.DeepEqual(out.Policies, []string{"dev", "ops"}) {
+		t.Fatalf("bad: %#v", out)
+	}
+	if renewed.NumUses != 1 {
+		t.Fatalf("bad: %#v", renewed)
+	}
+}
+
 func TestTokenStore_Revoke(t *testing.T) {
 	_, ts, _ := mockTokenStore(t)
 
@@ -235,6 +279,41 @@ func TestTokenStore_Revoke(t *testing.T) {
 	}
 }
 
+func TestTokenStore_Revoke_DeletesEntry(t *testing.T) {
+	_, ts, _ := mockTokenStore(t)
+
+	parent := &TokenEntry{Path: "test", Policies: []string{"dev"}}
+	if err := ts.create(parent); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	ent := &TokenEntry{Path: "test", Policies: []string{"dev"}, Parent: parent.ID}
+	if err := ts.create(ent); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	salted := ts.SaltID(ent.ID)
+	paths := []string{
+		lookupPrefix + salted,
+		parentPrefix + ts.SaltID(parent.ID) + "/" + salted,
+	}
+	for _, path := range paths {
+		if out, err := ts.view.Get(path); err != nil || out == nil {
+			t.Fatalf("missing %s: %v", path, err)
+		}
+	}
+
+	if err := ts.Revoke(ent.ID); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The stored entry and its parent index are removed
+	for _, path := range paths {
+		if out, err := ts.view.Get(path); err != nil || out != nil {
+			t.Fatalf("not deleted %s: %v", path, err)
+		}
+	}
+}
+
 func TestTokenStore_Revoke_Leases(t *testing.T) {
 	_, ts, _ := mockTokenStore(t)
 
@@ -418,6 +497,7 @@ func TestTokenStore_HandleRequest_CreateToken_DisplayName(t *testing.T) {
 		Path:        "auth/token/create",
 		DisplayName: "token-foo-bar-baz",
 		TTL:         0,
+		MFAIdentity: "auth/token/create\x00token-foo-bar-baz",
 	}
 	out, err := ts.Lookup(resp.Auth.ClientToken)
 	if err != nil {
@@ -449,6 +529,7 @@ func TestTokenStore_HandleRequest_CreateToken_NumUses(t *testing.T) {
 		DisplayName: "token",
 		NumUses:     1,
 		TTL:         0,
+		MFAIdentity: "auth/token/create\x00token",
 	}
 	out, err := ts.Lookup(resp.Auth.ClientToken)
 	if err != nil {
@@ -473,6 +554,19 @@ func TestTokenStore_HandleRequest_CreateToken_NumUses_Invalid(t *testing.T) {
 	}
 }
 
+func TestTokenStore_HandleRequest_CreateToken_BoundCIDRs_Invalid(t *testing.T) {
+	_, ts, root := mockTokenStore(t)
+
+	req := logical.TestRequest(t, logical.WriteOperation, "create")
+	req.ClientToken = root
+	req.Data["bound_cidrs"] = []string{"10.0.0.0/8", "10.0.0.1"}
+
+	resp, err := ts.HandleRequest(req)
+	if err != logical.ErrInvalidRequest {
+		t.Fatalf("err: %v %v", err, resp)
+	}
+}
+
 func TestTokenStore_HandleRequest_CreateToken_NumUses_Restricted(t *testing.T) {
 	_, ts, root := mockTokenStore(t)
 
@@ -511,6 +605,7 @@ func TestTokenStore_HandleRequest_CreateToken_NoPolicy(t *testing.T) {
 		Path:        "auth/token/create",
 		DisplayName: "token",
 		TTL:         0,
+		MFAIdentity: "auth/token/create\x00token",
 	}
 	out, err := ts.Lookup(resp.Auth.ClientToken)
 	if err != nil {
@@ -868,6 +963,7 @@ func TestTokenStore_HandleRequest_Lookup(t *testing.T) {
 		"display_name": "root",
 		"orphan":       true,
 		"num_uses":     0,
+		"creation_ttl": 0,
 		"ttl":          0,
 	}
 	delete(resp.Data, "creation_time")
@@ -894,7 +990,8 @@ func TestTokenStore_HandleRequest_Lookup(t *testing.T) {
 		"display_name": "token",
 		"orphan":       false,
 		"num_uses":     0,
-		"ttl":          2592000,
+		"creation_ttl": 2592000,
+		"ttl":          0,
 	}
 	delete(resp.Data, "creation_time")
 	if !reflect.DeepEqual(resp.Data, exp) {
@@ -942,6 +1039,86 @@ func TestTokenStore_HandleRequest_RevokePrefix(t *testing.T) {
 	}
 }
 
+func TestTokenStore_Lookup_Expired(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+	req := logical.TestRequest(t, logical.WriteOperation, "auth/token/create")
+	req.ClientToken = root
+	req.Data["ttl"] = "1s"
+	resp, err := c.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v %v", err, resp)
+	}
+	client := resp.Auth.ClientToken
+
+	// The remaining TTL is reported
+	req = logical.TestRequest(t, logical.ReadOperation, "auth/token/lookup-self")
+	req.ClientToken = client
+	resp, err = c.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v %v", err, resp)
+	}
+	if ttl := resp.Data["ttl"].(int); ttl < 0 || ttl > 1 {
+		t.Fatalf("bad: %d", ttl)
+	}
+	if resp.Data["creation_ttl"].(int) != 1 {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	// Stop the revocation timers, so the token is only expired lazily
+	c.expiration.Stop()
+	time.Sleep(1100 * time.Millisecond)
+
+	out, err := c.tokenStore.Lookup(client)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if out != nil {
+		t.Fatalf("bad: %#v", out)
+	}
+	req = logical.TestRequest(t, logical.ReadOperation, "secret/foo")
+	req.ClientToken = client
+	if _, err := c.HandleRequest(req); err != logical.ErrPermissionDenied {
+		t.Fatalf("err: %v", err)
+	}
+
+	// A lease that cannot be revoked, sorting before the token's
+	le := &leaseEntry{
+		LeaseID:    "auth/token/create/0",
+		Path:       "auth/token/create",
+		Auth:       &logical.Auth{},
+		IssueTime:  time.Now().UTC(),
+		ExpireTime: time.Now().UTC().Add(-time.Minute),
+	}
+	if err := c.expiration.persistEntry(le); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The token is not revoked until swept, and the failing lease does
+	// not hold it up
+	saltedID := c.tokenStore.SaltID(client)
+	if out, err := c.tokenStore.lookupSalted(saltedID); err != nil || out == nil {
+		t.Fatalf("bad: %#v %v", out, err)
+	}
+	n, err := c.expiration.sweepExpiredTokens(time.Now().UTC(), nil)
+	if err == nil || !strings.Contains(err.Error(), le.LeaseID) {
+		t.Fatalf("err: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("bad: %d", n)
+	}
+	if out, err := c.tokenStore.lookupSalted(saltedID); err != nil || out != nil {
+		t.Fatalf("bad: %#v %v", out, err)
+	}
+
+	// The sweep stops on seal
+	if err := c.Seal(root); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if c.tokenSweepStopCh != nil || c.tokenSweepDoneCh != nil {
+		t.Fatalf("sweep not stopped")
+	}
+}
+
 func TestTokenStore_HandleRequest_LookupSelf(t *testing.T) {
 	_, ts, root := mockTokenStore(t)
 	req := logical.TestRequest(t, logical.ReadOperation, "lookup-self")
@@ -962,6 +1139,7 @@ func TestTokenStore_HandleRequest_LookupSelf(t *testing.T) {
 		"display_name": "root",
 		"orphan":       true,
 		"num_uses":     0,
+		"creation_ttl": 0,
 		"ttl":          0,
 	}
 	delete(resp.Data, "creation_time")
@@ -1014,6 +1192,44 @@ func TestTokenStore_HandleRequest_Renew(t *testing.T) {
 	}
 }
 
+func TestTokenStore_HandleRequest_Renew_BackendNotReady(t *testing.T) {
+	exp := mockExpiration(t)
+	ts := exp.tokenStore
+
+	noop := &NoopBackend{}
+	_, barrier, _ := mockBarrier(t)
+	view := NewBarrierView(barrier, "auth/")
+	exp.router.Mount(noop, "auth/github/", &MountEntry{UUID: uuid.GenerateUUID()}, view)
+
+	// Create a token issued by the credential backend
+	ent := &TokenEntry{Path: "auth/github/login", Policies: []string{"foo"}}
+	if err := ts.create(ent); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	auth := &logical.Auth{
+		ClientToken: ent.ID,
+		LeaseOptions: logical.LeaseOptions{
+			TTL:       time.Hour,
+			Renewable: true,
+		},
+	}
+	if err := exp.RegisterAuth("auth/github/login", auth); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The backend not being ready is not an invalid request, and keeps
+	// its code so the client knows to retry
+	exp.router.SetInitError("auth/github/", ErrBackendNotReady)
+	req := logical.TestRequest(t, logical.WriteOperation, "renew/"+ent.ID)
+	resp, err := ts.HandleRequest(req)
+	if err != ErrBackendNotReady {
+		t.Fatalf("err: %v %v", err, resp)
+	}
+	if resp != nil {
+		t.Fatalf("bad: %#v", resp)
+	}
+}
+
 func TestTokenStore_HandleRequest_RenewSelf(t *testing.T) {
 	exp := mockExpiration(t)
 	ts := exp.tokenStore
@@ -1059,6 +1275,357 @@ func TestTokenStore_HandleRequest_RenewSelf(t *testing.T) {
 	}
 }
 
+func TestCore_RenewSelf(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+
+	req := logical.TestRequest(t, logical.WriteOperation, "auth/token/create")
+	req.ClientToken = root
+	req.Data["ttl"] = "1h"
+	resp, err := c.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v %v", err, resp)
+	}
+	client := resp.Auth.ClientToken
+
+	info, err := c.RenewSelf(client, 30*time.Minute)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !info.Renewable || info.TTL != 30*time.Minute {
+		t.Fatalf("bad: %#v", info)
+	}
+	if info.Path != "auth/token/create" {
+		t.Fatalf("bad: %#v", info)
+	}
+
+	// Negative increments are rejected
+	if _, err := c.RenewSelf(client, -time.Hour); err == nil {
+		t.Fatalf("expected error")
+	}
+
+	// Unknown tokens are denied
+	if _, err := c.RenewSelf("foobar", time.Hour); err != logical.ErrPermissionDenied {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestCore_RenewSelf_NotRenewable(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+
+	// The root token has no lease and cannot be renewed, which is told
+	// apart from other failures
+	_, err := c.RenewSelf(root, time.Hour)
+	if err == nil || !strings.Contains(err.Error(), "not renewable") {
+		t.Fatalf("err: %v", err)
+	}
+	if verr, ok := err.(*logical.VaultError); !ok || verr.Code != logical.ErrCodeInvalidRequest {
+		t.Fatalf("err: %#v", err)
+	}
+}
+
+func TestCore_RenewSelf_Audit(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+	var noop *NoopAudit
+	c.auditBackends["noop"] = func(config *audit.BackendConfig) (audit.Backend, error) {
+		noop = &NoopAudit{
+			Config: config,
+		}
+		return noop, nil
+	}
+	req := logical.TestRequest(t, logical.WriteOperation, "sys/audit/noop")
+	req.Data["type"] = "noop"
+	req.ClientToken = root
+	if _, err := c.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	req = logical.TestRequest(t, logical.WriteOperation, "auth/token/create")
+	req.ClientToken = root
+	req.Data["ttl"] = "1h"
+	resp, err := c.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v %v", err, resp)
+	}
+	client := resp.Auth.ClientToken
+
+	noop.Req = nil
+	noop.RespReq = nil
+	if _, err := c.RenewSelf(client, time.Hour); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(noop.Req) != 1 || noop.Req[0].Path != "auth/token/renew-self" {
+		t.Fatalf("bad: %#v", noop.Req)
+	}
+	if len(noop.RespReq) != 1 || noop.RespReq[0].Path != "auth/token/renew-self" {
+		t.Fatalf("bad: %#v", noop.RespReq)
+	}
+}
+
+func TestCore_RenewSelf_Period(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+
+	req := logical.TestRequest(t, logical.WriteOperation, "auth/token/create")
+	req.ClientToken = root
+	req.Data["period"] = "30m"
+	resp, err := c.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v %v", err, resp)
+	}
+	if resp.Auth.TTL != 30*time.Minute {
+		t.Fatalf("bad: %#v", resp.Auth)
+	}
+
+	// The increment is ignored in favor of the period
+	info, err := c.RenewSelf(resp.Auth.ClientToken, 5*time.Hour)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if info.TTL != 30*time.Minute || info.Period != 30*time.Minute {
+		t.Fatalf("bad: %#v", info)
+	}
+}
+
+func TestCore_RenewSelf_ExplicitMaxTTL(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+
+	req := logical.TestRequest(t, logical.WriteOperation, "auth/token/create")
+	req.ClientToken = root
+	req.Data["ttl"] = "1h"
+	req.Data["explicit_max_ttl"] = "2h"
+	resp, err := c.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v %v", err, resp)
+	}
+
+	// The increment is clamped to the time left before the max
+	info, err := c.RenewSelf(resp.Auth.ClientToken, 5*time.Hour)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if info.TTL > 2*time.Hour || info.TTL < time.Hour {
+		t.Fatalf("bad: %#v", info)
+	}
+	if info.ExplicitMaxTTL != 2*time.Hour {
+		t.Fatalf("bad: %#v", info)
+	}
+}
+
+func TestCore_RenewSelf_PolicySnapshot(t *testing.T) {
+	for _, snapshot := range []bool{false, true} {
+		noop := &NoopBackend{
+			Login: []string{"login"},
+		}
+		loginResponse := func(policies ...string) *logical.Response {
+			return &logical.Response{
+				Auth: &logical.Auth{
+					LeaseOptions: logical.LeaseOptions{
+						TTL:       time.Hour,
+						Renewable: true,
+					},
+					Policies:       policies,
+					PolicySnapshot: snapshot,
+				},
+			}
+		}
+		noop.Response = loginResponse("dev")
+
+		c, _, root := TestCoreUnsealed(t)
+		c.credentialBackends["noop"] = func(conf *logical.BackendConfig) (logical.Backend, error) {
+			return noop, nil
+		}
+		req := logical.TestRequest(t, logical.WriteOperation, "sys/auth/foo")
+		req.Data["type"] = "noop"
+		req.ClientToken = root
+		if _, err := c.HandleRequest(req); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+
+		// The token may renew itself
+		req = logical.TestRequest(t, logical.WriteOperation, "sys/policy/dev")
+		req.Data["rules"] = `path "auth/token/renew-self" { policy = "write" }`
+		req.ClientToken = root
+		if _, err := c.HandleRequest(req); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+
+		resp, err := c.HandleRequest(&logical.Request{Path: "auth/foo/login"})
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		client := resp.Auth.ClientToken
+
+		// The groups of the user changed, so the backend now maps it to
+		// other policies
+		noop.Response = loginResponse("dev", "ops")
+		expect := []string{"dev", "ops"}
+		if snapshot {
+			expect = []string{"dev"}
+		}
+
+		// A lookup picks up the current policies
+		req = logical.TestRequest(t, logical.ReadOperation, "auth/token/lookup/"+client)
+		req.ClientToken = root
+		resp, err = c.HandleRequest(req)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if !reflect.DeepEqual(resp.Data["policies"], expect) {
+			t.Fatalf("bad: %v %#v", snapshot, resp.Data)
+		}
+
+		// The groups of the user changed again, and a renewal picks up
+		// the current policies as well
+		noop.Response = loginResponse("dev", "ops", "qa")
+		if !snapshot {
+			expect = []string{"dev", "ops", "qa"}
+		}
+		info, err := c.RenewSelf(client, time.Hour)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if !reflect.DeepEqual(info.Policies, expect) {
+			t.Fatalf("bad: %v %#v", snapshot, info)
+		}
+
+		// The token is looked up with the same policies
+		req = logical.TestRequest(t, logical.ReadOperation, "auth/token/lookup/"+client)
+		req.ClientToken = root
+		resp, err = c.HandleRequest(req)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if !reflect.DeepEqual(resp.Data["policies"], expect) {
+			t.Fatalf("bad: %v %#v", snapshot, resp.Data)
+		}
+		if _, ok := resp.Data["policy_snapshot"]; ok != snapshot {
+			t.Fatalf("bad: %v %#v", snapshot, resp.Data)
+		}
+	}
+}
+
+func TestTokenStore_HandleRequest_CreateToken_Period_NonRoot(t *testing.T) {
+	_, ts, root := mockTokenStore(t)
+	testMakeToken(t, ts, root, "client", []string{"foo"})
+
+	req := logical.TestRequest(t, logical.WriteOperation, "create")
+	req.ClientToken = "client"
+	req.Data["period"] = "30m"
+	resp, err := ts.HandleRequest(req)
+	if err != logical.ErrInvalidRequest {
+		t.Fatalf("err: %v %v", err, resp)
+	}
+}
+
+func TestTokenStore_HandleRequest_CreateToken_MaxPolicies(t *testing.T) {
+	_, ts, root := mockTokenStore(t)
+	ts.maxPolicies = 2
+
+	create := func(policies []string) error {
+		req := logical.TestRequest(t, logical.WriteOperation, "create")
+		req.ClientToken = root
+		req.Data["policies"] = policies
+		_, err := ts.HandleRequest(req)
+		return err
+	}
+
+	// At the limit
+	if err := create([]string{"foo", "bar"}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The default policy is not counted
+	if err := create([]string{"foo", "bar", "default"}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Above the limit
+	if err := create([]string{"foo", "bar", "baz"}); err != logical.ErrInvalidRequest {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Root tokens are exempt
+	if _, err := ts.rootToken(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestTokenStore_HandleRequest_CreateToken_MaxDepth(t *testing.T) {
+	_, ts, root := mockTokenStore(t)
+	ts.maxDepth = 2
+
+	create := func(path, parent string) (string, error) {
+		req := logical.TestRequest(t, logical.WriteOperation, path)
+		req.ClientToken = parent
+		resp, err := ts.HandleRequest(req)
+		if err != nil {
+			return "", err
+		}
+		return resp.Auth.ClientToken, nil
+	}
+
+	// Up to the limit
+	child, err := create("create", root)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	grandchild, err := create("create", child)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Beyond the limit
+	if _, err := create("create", grandchild); err != logical.ErrInvalidRequest {
+		t.Fatalf("err: %v", err)
+	}
+	ent := &TokenEntry{Path: "test", Parent: grandchild, Policies: []string{"dev"}}
+	if err := ts.create(ent); err != ErrTokenDepthExceeded {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Orphan tokens start a new hierarchy
+	orphan, err := create("create-orphan", grandchild)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	child, err = create("create", orphan)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := create("create", child); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestTokenStore_CreateToken_IDLength(t *testing.T) {
+	_, ts, _ := mockTokenStore(t)
+	ts.idLength = 32
+
+	ent := &TokenEntry{Path: "test", Policies: []string{"dev", "ops"}}
+	if err := ts.create(ent); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(ent.ID) != 64 {
+		t.Fatalf("bad: %s", ent.ID)
+	}
+	if _, err := hex.DecodeString(ent.ID); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	out, err := ts.Lookup(ent.ID)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !reflect.DeepEqual(out, ent) {
+		t.Fatalf("bad: %#v", out)
+	}
+
+	// Below the entropy floor
+	ts.idLength = minTokenIDLength - 1
+	if err := ts.create(&TokenEntry{Path: "test"}); err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
 func testMakeToken(t *testing.T, ts *TokenStore, root, client string, policy []string) {
 	req := logical.TestRequest(t, logical.WriteOperation, "create")
 	req.ClientToken = root
@@ -0,0 +1,104 @@
+package vault
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+const (
+	// tokenSweepInterval is how often the leases of tokens are scanned
+	// for tokens that expired but were not revoked
+	tokenSweepInterval = time.Minute
+)
+
+// startTokenSweep is invoked as part of postUnseal to periodically
+// revoke expired tokens. Tokens are normally revoked by the timer of
+// their lease, the sweep catches the ones whose revocation failed.
+func (c *Core) startTokenSweep() {
+	ticker := time.NewTicker(tokenSweepInterval)
+	c.tokenSweepStopCh = make(chan struct{})
+	c.tokenSweepDoneCh = make(chan struct{})
+	go func(mgr *ExpirationManager, stopCh, doneCh chan struct{}) {
+		defer close(doneCh)
+		defer ticker.Stop()
+		c.runTokenSweep(mgr, ticker.C, stopCh)
+	}(c.expiration, c.tokenSweepStopCh, c.tokenSweepDoneCh)
+}
+
+// stopTokenSweep is invoked as part of preSeal to stop the sweep. It
+// waits for a sweep in progress to finish, so it must be called before
+// the expiration manager is stopped.
+func (c *Core) stopTokenSweep() {
+	if c.tokenSweepStopCh != nil {
+		close(c.tokenSweepStopCh)
+		<-c.tokenSweepDoneCh
+		c.tokenSweepStopCh = nil
+		c.tokenSweepDoneCh = nil
+	}
+}
+
+// runTokenSweep revokes expired tokens on every tick until stopped
+func (c *Core) runTokenSweep(mgr *ExpirationManager, tickCh <-chan time.Time, stopCh chan struct{}) {
+	for {
+		select {
+		case now := <-tickCh:
+			n, err := mgr.sweepExpiredTokens(now.UTC(), stopCh)
+			if err != nil {
+				c.logger.Printf("[ERR] core: failed to sweep expired tokens: %v", err)
+			}
+			if n > 0 {
+				c.logger.Printf("[INFO] core: revoked %d expired tokens", n)
+			}
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+// sweepExpiredTokens revokes the tokens whose lease expired before now,
+// returning how many were revoked. A lease that fails to revoke does not
+// hold up the others; the errors are returned together. Leases with a
+// pending timer are revoked when it fires, so only the others are loaded.
+// It returns early if stopCh is closed.
+func (m *ExpirationManager) sweepExpiredTokens(now time.Time, stopCh chan struct{}) (int, error) {
+	sub := m.idView.SubView(credentialRoutePrefix)
+	existing, err := CollectKeys(sub)
+	if err != nil {
+		return 0, err
+	}
+
+	var revoked int
+	var result error
+	for _, key := range existing {
+		select {
+		case <-stopCh:
+			return revoked, result
+		default:
+		}
+
+		leaseID := credentialRoutePrefix + key
+		m.pendingLock.Lock()
+		_, pending := m.pending[leaseID]
+		m.pendingLock.Unlock()
+		if pending {
+			continue
+		}
+
+		le, err := m.loadEntry(leaseID)
+		if err != nil {
+			result = multierror.Append(result, fmt.Errorf("failed to load '%s': %v", leaseID, err))
+			continue
+		}
+		if le == nil || le.Auth == nil || le.ExpireTime.IsZero() || now.Before(le.ExpireTime) {
+			continue
+		}
+		if err := m.Revoke(leaseID); err != nil {
+			result = multierror.Append(result, fmt.Errorf("failed to revoke '%s': %v", leaseID, err))
+			continue
+		}
+		revoked++
+	}
+	return revoked, result
+}
@@ -0,0 +1,83 @@
+package vault
+
+import (
+	"github.com/hashicorp/vault/logical"
+)
+
+// Names of the spans emitted while handling a request
+const (
+	SpanRequest      = "vault.request"
+	SpanACLCheck     = "vault.acl_check"
+	SpanResolveMount = "vault.resolve_mount"
+	SpanHandle       = "vault.handle"
+)
+
+// Attributes set on every span
+const (
+	SpanAttrMount     = "vault.mount"
+	SpanAttrOperation = "vault.operation"
+	SpanAttrOutcome   = "vault.outcome"
+)
+
+// Tracer is used to emit spans around the stages of handling a request:
+// checking the ACL, resolving the mount and handling it in the backend.
+// The stages of a client request are children of a span of the whole
+// request. It can be backed by a distributed tracing system such as
+// OpenTelemetry.
+type Tracer interface {
+	// StartSpan starts a span with the given name
+	StartSpan(name string) Span
+}
+
+// Span is a stage of handling a request, started by a Tracer
+type Span interface {
+	// StartChild starts a span with the given name as a child of the span
+	StartChild(name string) Span
+
+	// SetAttribute sets an attribute of the span
+	SetAttribute(key, value string)
+
+	// End finishes the span
+	End()
+}
+
+// noopTracer is the default Tracer, which emits nothing
+type noopTracer struct{}
+
+func (noopTracer) StartSpan(string) Span { return noopSpan{} }
+
+type noopSpan struct{}
+
+func (noopSpan) StartChild(string) Span      { return noopSpan{} }
+func (noopSpan) SetAttribute(string, string) {}
+func (noopSpan) End()                        {}
+
+// startSpan starts a span for the operation. It is a child of the parent
+// span if it is not nil, and is otherwise started with the tracer.
+func startSpan(tracer Tracer, parent Span, name string, op logical.Operation) Span {
+	var span Span
+	if parent != nil {
+		span = parent.StartChild(name)
+	} else {
+		span = tracer.StartSpan(name)
+	}
+	span.SetAttribute(SpanAttrOperation, string(op))
+	return span
+}
+
+// requestTracer returns the tracer of the router
+func (r *Router) requestTracer() Tracer {
+	r.l.RLock()
+	defer r.l.RUnlock()
+	return r.tracer
+}
+
+// endSpan records the outcome of a span and finishes it
+func endSpan(span Span, err error) {
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+	}
+	span.SetAttribute(SpanAttrOutcome, outcome)
+	span.End()
+}
@@ -0,0 +1,157 @@
+package vault
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+// recordingTracer records the spans that were ended
+type recordingTracer struct {
+	l     sync.Mutex
+	spans []*recordingSpan
+}
+
+type recordingSpan struct {
+	tracer *recordingTracer
+	parent *recordingSpan
+	name   string
+	attrs  map[string]string
+}
+
+func (t *recordingTracer) StartSpan(name string) Span {
+	return &recordingSpan{tracer: t, name: name, attrs: make(map[string]string)}
+}
+
+func (s *recordingSpan) StartChild(name string) Span {
+	return &recordingSpan{tracer: s.tracer, parent: s, name: name, attrs: make(map[string]string)}
+}
+
+func (s *recordingSpan) SetAttribute(key, value string) {
+	s.attrs[key] = value
+}
+
+func (s *recordingSpan) End() {
+	s.tracer.l.Lock()
+	defer s.tracer.l.Unlock()
+	s.tracer.spans = append(s.tracer.spans, s)
+}
+
+func (t *recordingTracer) reset() []*recordingSpan {
+	t.l.Lock()
+	defer t.l.Unlock()
+	spans := t.spans
+	t.spans = nil
+	return spans
+}
+
+func TestCore_Tracer(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+	tracer := &recordingTracer{}
+	c.router.SetTracer(tracer)
+
+	req := logical.TestRequest(t, logical.ReadOperation, "secret/foo")
+	req.ClientToken = root
+	if _, err := c.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	attrs := map[string]string{
+		SpanAttrMount:     "secret/",
+		SpanAttrOperation: "read",
+		SpanAttrOutcome:   "success",
+	}
+	spans := tracer.reset()
+	names := []string{SpanACLCheck, SpanResolveMount, SpanHandle, SpanRequest}
+	if len(spans) != len(names) {
+		t.Fatalf("bad: %d spans", len(spans))
+	}
+	for i, span := range spans {
+		if span.name != names[i] {
+			t.Fatalf("bad: %s %s", span.name, names[i])
+		}
+		if !reflect.DeepEqual(span.attrs, attrs) {
+			t.Fatalf("bad: %s %#v", span.name, span.attrs)
+		}
+	}
+
+	// The stages are children of the span of the request
+	request := spans[len(spans)-1]
+	if request.parent != nil {
+		t.Fatalf("bad: %#v", request.parent)
+	}
+	for _, span := range spans[:len(spans)-1] {
+		if span.parent != request {
+			t.Fatalf("bad: %s %#v", span.name, span.parent)
+		}
+	}
+
+	// A denied request ends after the ACL check
+	req = logical.TestRequest(t, logical.ReadOperation, "secret/foo")
+	req.ClientToken = "bad"
+	if _, err := c.HandleRequest(req); err == nil {
+		t.Fatalf("expected error")
+	}
+	spans = tracer.reset()
+	if len(spans) != 2 || spans[0].name != SpanACLCheck || spans[0].attrs[SpanAttrOutcome] != "error" {
+		t.Fatalf("bad: %#v", spans)
+	}
+	if spans[1].name != SpanRequest || spans[1].attrs[SpanAttrOutcome] != "error" {
+		t.Fatalf("bad: %#v", spans)
+	}
+}
+
+func TestRouter_Tracer(t *testing.T) {
+	r := NewRouter()
+	_, barrier, _ := mockBarrier(t)
+	view := NewBarrierView(barrier, "logical/")
+	tracer := &recordingTracer{}
+	r.SetTracer(tracer)
+
+	n := &NoopBackend{}
+	if err := r.Mount(n, "prod/aws/", &MountEntry{UUID: "foo"}, view); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// An unknown path fails to resolve
+	if _, err := r.Route(&logical.Request{Operation: logical.WriteOperation, Path: "missing/foo"}); err == nil {
+		t.Fatalf("expected error")
+	}
+	spans := tracer.reset()
+	if len(spans) != 1 || spans[0].name != SpanResolveMount || spans[0].attrs[SpanAttrOutcome] != "error" {
+		t.Fatalf("bad: %#v", spans)
+	}
+
+	// A routed request resolves the mount and is handled by the backend
+	if _, err := r.Route(&logical.Request{Operation: logical.DeleteOperation, Path: "prod/aws/foo"}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	attrs := map[string]string{
+		SpanAttrMount:     "prod/aws/",
+		SpanAttrOperation: "delete",
+		SpanAttrOutcome:   "success",
+	}
+	spans = tracer.reset()
+	if len(spans) != 2 || spans[0].name != SpanResolveMount || spans[1].name != SpanHandle {
+		t.Fatalf("bad: %#v", spans)
+	}
+	for _, span := range spans {
+		if !reflect.DeepEqual(span.attrs, attrs) {
+			t.Fatalf("bad: %s %#v", span.name, span.attrs)
+		}
+		if span.parent != nil {
+			t.Fatalf("bad: %s %#v", span.name, span.parent)
+		}
+	}
+
+	// Setting no tracer disables tracing
+	r.SetTracer(nil)
+	if _, err := r.Route(&logical.Request{Operation: logical.ReadOperation, Path: "prod/aws/foo"}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if spans := tracer.reset(); len(spans) != 0 {
+		t.Fatalf("bad: %#v", spans)
+	}
+}
@@ -0,0 +1,146 @@
+package vault
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hashicorp/vault/physical"
+)
+
+const (
+	// coreUnsealProgressPath is the path used to persist the key shares
+	// provided so far, if enabled. It is outside of the barrier, which
+	// is sealed while the shares are collected.
+	coreUnsealProgressPath = "core/unseal-progress"
+
+	// unsealProgressKeySize is the size of the key used to encrypt the
+	// persisted unseal progress
+	unsealProgressKeySize = 32
+)
+
+// unsealProgress is the persisted unseal progress. It records the seal
+// configuration the shares were provided for, so that progress is not
+// resumed after the configuration changes. A rekey or a refresh of the
+// shares also clears it.
+type unsealProgress struct {
+	SecretShares    int      `json:"secret_shares"`
+	SecretThreshold int      `json:"secret_threshold"`
+	Parts           [][]byte `json:"parts"`
+}
+
+// unsealProgressAEAD returns the AEAD used to encrypt the persisted
+// unseal progress
+func (c *Core) unsealProgressAEAD() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(c.unsealProgressKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// persistUnsealProgress is used to store the key shares provided so far,
+// encrypted with the unseal progress key, or to remove them if there are
+// none. It does nothing unless an unseal progress key is configured.
+// The stateLock must be held.
+func (c *Core) persistUnsealProgress(config *SealConfig) error {
+	if c.unsealProgressKey == nil {
+		return nil
+	}
+	if len(c.unlockParts) == 0 {
+		return c.clearUnsealProgress()
+	}
+
+	plain, err := json.Marshal(&unsealProgress{
+		SecretShares:    config.SecretShares,
+		SecretThreshold: config.SecretThreshold,
+		Parts:           c.unlockParts,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode unseal progress: %v", err)
+	}
+	defer memzero(plain)
+
+	gcm, err := c.unsealProgressAEAD()
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	value := gcm.Seal(nonce, nonce, plain, []byte(coreUnsealProgressPath))
+
+	pe := &physical.Entry{
+		Key:   coreUnsealProgressPath,
+		Value: value,
+	}
+	if err := c.physical.Put(pe); err != nil {
+		return fmt.Errorf("failed to persist unseal progress: %v", err)
+	}
+	return nil
+}
+
+// clearUnsealProgress is used to remove the persisted unseal progress.
+// A rekey or a refresh of the shares must clear it, since the shares it
+// holds no longer combine into the master key, even if the seal
+// configuration keeps the same shares and threshold.
+func (c *Core) clearUnsealProgress() error {
+	if err := c.physical.Delete(coreUnsealProgressPath); err != nil {
+		return fmt.Errorf("failed to clear unseal progress: %v", err)
+	}
+	return nil
+}
+
+// loadUnsealProgress is used to resume the unseal progress persisted
+// before a restart. Progress for another seal configuration is discarded.
+// The stateLock must be held.
+func (c *Core) loadUnsealProgress() error {
+	if c.unsealProgressKey == nil {
+		return nil
+	}
+	pe, err := c.physical.Get(coreUnsealProgressPath)
+	if err != nil {
+		return fmt.Errorf("failed to read unseal progress: %v", err)
+	}
+	if pe == nil {
+		return nil
+	}
+
+	gcm, err := c.unsealProgressAEAD()
+	if err != nil {
+		return err
+	}
+	if len(pe.Value) < gcm.NonceSize() {
+		return fmt.Errorf("unseal progress is too short")
+	}
+	nonce, raw := pe.Value[:gcm.NonceSize()], pe.Value[gcm.NonceSize():]
+	plain, err := gcm.Open(nil, nonce, raw, []byte(coreUnsealProgressPath))
+	if err != nil {
+		return fmt.Errorf("failed to decrypt unseal progress: %v", err)
+	}
+	defer memzero(plain)
+
+	var progress unsealProgress
+	if err := json.Unmarshal(plain, &progress); err != nil {
+		return fmt.Errorf("failed to decode unseal progress: %v", err)
+	}
+
+	config, err := c.SealConfig()
+	if err != nil {
+		return err
+	}
+	if config == nil || config.SecretShares != progress.SecretShares ||
+		config.SecretThreshold != progress.SecretThreshold ||
+		len(progress.Parts) >= config.SecretThreshold {
+		c.logger.Printf("[WARN] core: discarding unseal progress of another seal configuration")
+		return c.physical.Delete(coreUnsealProgressPath)
+	}
+
+	c.unlockParts = progress.Parts
+	c.logger.Printf("[INFO] core: resumed unseal progress, have %d of %d keys",
+		len(c.unlockParts), config.SecretThreshold)
+	return nil
+}
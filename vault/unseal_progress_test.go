@@ -0,0 +1,193 @@
+package vault
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/hashicorp/vault/physical"
+)
+
+func testUnsealProgressCore(t *testing.T, inm physical.Backend, key []byte) *Core {
+	c, err := NewCore(&CoreConfig{
+		Physical:          inm,
+		DisableMlock:      true,
+		UnsealProgressKey: key,
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	return c
+}
+
+func TestNewCore_badUnsealProgressKey(t *testing.T) {
+	conf := &CoreConfig{
+		Physical:          physical.NewInmem(),
+		DisableMlock:      true,
+		UnsealProgressKey: []byte("too short"),
+	}
+	if _, err := NewCore(conf); err == nil {
+		t.Fatalf("should fail")
+	}
+}
+
+func TestCore_UnsealProgress_Resume(t *testing.T) {
+	inm := physical.NewInmem()
+	key := bytes.Repeat([]byte{0x42}, unsealProgressKeySize)
+	c := testUnsealProgressCore(t, inm, key)
+	res, err := c.Initialize(&SealConfig{
+		SecretShares:    5,
+		SecretThreshold: 3,
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if unseal, err := c.Unseal(TestKeyCopy(res.UnsealShares[i])); err != nil || unseal {
+			t.Fatalf("bad: %v %v", unseal, err)
+		}
+	}
+
+	// The persisted shares are encrypted
+	pe, err := inm.Get(coreUnsealProgressPath)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if pe == nil {
+		t.Fatalf("progress not persisted")
+	}
+	for i := 0; i < 2; i++ {
+		if bytes.Contains(pe.Value, res.UnsealShares[i]) {
+			t.Fatalf("share stored in plaintext")
+		}
+	}
+
+	// A restart without the key starts over
+	if progress := testUnsealProgressCore(t, inm, nil).SecretProgress(); progress != 0 {
+		t.Fatalf("bad: %d", progress)
+	}
+	other := bytes.Repeat([]byte{0x43}, unsealProgressKeySize)
+	if progress := testUnsealProgressCore(t, inm, other).SecretProgress(); progress != 0 {
+		t.Fatalf("bad: %d", progress)
+	}
+
+	// A restart with the key resumes
+	c = testUnsealProgressCore(t, inm, key)
+	if progress := c.SecretProgress(); progress != 2 {
+		t.Fatalf("bad: %d", progress)
+	}
+	unseal, err := c.Unseal(TestKeyCopy(res.UnsealShares[2]))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !unseal {
+		t.Fatalf("should be unsealed")
+	}
+
+	// The progress is removed once used
+	if pe, err := inm.Get(coreUnsealProgressPath); err != nil || pe != nil {
+		t.Fatalf("bad: %v %v", pe, err)
+	}
+}
+
+func TestCore_UnsealProgress_Reset(t *testing.T) {
+	inm := physical.NewInmem()
+	key := bytes.Repeat([]byte{0x42}, unsealProgressKeySize)
+	c := testUnsealProgressCore(t, inm, key)
+	res, err := c.Initialize(&SealConfig{
+		SecretShares:    5,
+		SecretThreshold: 3,
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := c.Unseal(TestKeyCopy(res.UnsealShares[0])); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	c.ResetUnsealProcess()
+	if pe, err := inm.Get(coreUnsealProgressPath); err != nil || pe != nil {
+		t.Fatalf("bad: %v %v", pe, err)
+	}
+	if progress := testUnsealProgressCore(t, inm, key).SecretProgress(); progress != 0 {
+		t.Fatalf("bad: %d", progress)
+	}
+}
+
+func TestCore_UnsealProgress_ConfigChanged(t *testing.T) {
+	inm := physical.NewInmem()
+	key := bytes.Repeat([]byte{0x42}, unsealProgressKeySize)
+	c := testUnsealProgressCore(t, inm, key)
+	res, err := c.Initialize(&SealConfig{
+		SecretShares:    5,
+		SecretThreshold: 3,
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := c.Unseal(TestKeyCopy(res.UnsealShares[0])); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Progress for another seal configuration is discarded
+	c.unlockParts = [][]byte{res.UnsealShares[0]}
+	if err := c.persistUnsealProgress(&SealConfig{SecretShares: 3, SecretThreshold: 2}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if progress := testUnsealProgressCore(t, inm, key).SecretProgress(); progress != 0 {
+		t.Fatalf("bad: %d", progress)
+	}
+	if pe, err := inm.Get(coreUnsealProgressPath); err != nil || pe != nil {
+		t.Fatalf("bad: %v %v", pe, err)
+	}
+}
+
+func TestCore_UnsealProgress_ClearedBySharesChange(t *testing.T) {
+	for _, name := range []string{"rekey", "refresh"} {
+		inm := physical.NewInmem()
+		key := bytes.Repeat([]byte{0x42}, unsealProgressKeySize)
+		c := testUnsealProgressCore(t, inm, key)
+		res, err := c.Initialize(&SealConfig{
+			SecretShares:    5,
+			SecretThreshold: 3,
+		})
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		for i := 0; i < 3; i++ {
+			if _, err := c.Unseal(TestKeyCopy(res.UnsealShares[i])); err != nil {
+				t.Fatalf("err: %v", err)
+			}
+		}
+
+		// Another node sharing the storage persists its progress
+		other := testUnsealProgressCore(t, inm, key)
+		if _, err := other.Unseal(TestKeyCopy(res.UnsealShares[3])); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+
+		// Replace the shares, keeping the shares and threshold
+		switch name {
+		case "rekey":
+			if err := c.RekeyInit(&SealConfig{SecretShares: 5, SecretThreshold: 3}); err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			for i := 0; i < 3; i++ {
+				if _, err := c.RekeyUpdate(TestKeyCopy(res.UnsealShares[i])); err != nil {
+					t.Fatalf("err: %v", err)
+				}
+			}
+		case "refresh":
+			if _, err := c.RefreshShares(res.UnsealShares[:3], nil); err != nil {
+				t.Fatalf("err: %v", err)
+			}
+		}
+
+		// The progress holds a share of the old key and is discarded
+		if pe, err := inm.Get(coreUnsealProgressPath); err != nil || pe != nil {
+			t.Fatalf("%s: bad: %v %v", name, pe, err)
+		}
+		if progress := testUnsealProgressCore(t, inm, key).SecretProgress(); progress != 0 {
+			t.Fatalf("%s: bad: %d", name, progress)
+		}
+	}
+}
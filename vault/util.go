@@ -3,8 +3,15 @@ package vault
 import (
 	"crypto/rand"
 	"fmt"
+	"io"
+	"unicode"
+	"unicode/utf8"
 )
 
+// uuidRand is the source of randomness of generateUUID. It is only
+// replaced by tests.
+var uuidRand io.Reader = rand.Reader
+
 // memzero is used to zero out a byte buffer. This specific format is optimized
 // by the compiler to use memclr to improve performance. See this code review:
 // https://codereview.appspot.com/137880043
@@ -23,6 +30,27 @@ func randbytes(n int) []byte {
 	return buf
 }
 
+// generateUUID is used to generate a random UUID from uuidRand. Unlike
+// uuid.GenerateUUID, a failure to read random bytes is returned.
+func generateUUID() (string, error) {
+	return generateUUIDFrom(uuidRand)
+}
+
+// generateUUIDFrom is like generateUUID, reading from the given source
+func generateUUIDFrom(r io.Reader) (string, error) {
+	buf := make([]byte, 16)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return "", fmt.Errorf("failed to read random bytes: %v", err)
+	}
+
+	return fmt.Sprintf("%08x-%04x-%04x-%04x-%12x",
+		buf[0:4],
+		buf[4:6],
+		buf[6:8],
+		buf[8:10],
+		buf[10:16]), nil
+}
+
 // strListContains looks for a string in a list of strings.
 func strListContains(haystack []string, needle string) bool {
 	for _, item := range haystack {
@@ -33,6 +61,20 @@ func strListContains(haystack []string, needle string) bool {
 	return false
 }
 
+// strListUnion returns a new list with the items of both lists, without
+// duplicates.
+func strListUnion(a, b []string) []string {
+	result := make([]string, 0, len(a)+len(b))
+	for _, list := range [][]string{a, b} {
+		for _, item := range list {
+			if !strListContains(result, item) {
+				result = append(result, item)
+			}
+		}
+	}
+	return result
+}
+
 // strListSubset checks if a given list is a subset
 // of another set
 func strListSubset(super, sub []string) bool {
@@ -43,3 +85,18 @@ func strListSubset(super, sub []string) bool {
 	}
 	return true
 }
+
+// validateUTF8 checks that a mount name or key is valid UTF-8 and does not
+// contain control characters, which could corrupt the JSON persisted in
+// the mount tables or the log output. The kind is used in the error.
+func validateUTF8(kind, s string) error {
+	if !utf8.ValidString(s) {
+		return fmt.Errorf("%s contains invalid UTF-8", kind)
+	}
+	for _, r := range s {
+		if unicode.IsControl(r) {
+			return fmt.Errorf("%s contains control character %q", kind, r)
+		}
+	}
+	return nil
+}
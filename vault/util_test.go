@@ -1,6 +1,10 @@
 package vault
 
-import "testing"
+import (
+	"bytes"
+	"regexp"
+	"testing"
+)
 
 func TestMemZero(t *testing.T) {
 	b := []byte{1, 2, 3, 4}
@@ -62,3 +66,21 @@ func TestStrListSubset(t *testing.T) {
 		t.Fatalf("Bad")
 	}
 }
+
+func TestGenerateUUID(t *testing.T) {
+	id, err := generateUUID()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`).MatchString(id) {
+		t.Fatalf("bad: %s", id)
+	}
+
+	// A failure to read random bytes is returned
+	old := uuidRand
+	defer func() { uuidRand = old }()
+	uuidRand = bytes.NewReader(make([]byte, 8))
+	if _, err := generateUUID(); err == nil {
+		t.Fatalf("expected error")
+	}
+}